@@ -35,3 +35,11 @@ export interface ShortcutInfo {
 	startDir: string;
 }
 
+export interface UploadSessionInfo {
+	uploadId: string;
+	gameName: string;
+	percentage: number;
+	startedAt: number; // Unix ms
+	idleSecs: number;
+	paused?: boolean;
+}
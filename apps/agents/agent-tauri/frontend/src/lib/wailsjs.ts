@@ -3,7 +3,13 @@
 
 import { invoke } from '@tauri-apps/api/core';
 import { listen, type UnlistenFn } from '@tauri-apps/api/event';
-import type { AgentStatus, VersionInfo, SteamUserInfo, ShortcutInfo } from '$lib/types';
+import type {
+	AgentStatus,
+	VersionInfo,
+	SteamUserInfo,
+	ShortcutInfo,
+	UploadSessionInfo
+} from '$lib/types';
 
 // ---------------------------------------------------------------------------
 // Runtime events (Wails-compatible wrapper)
@@ -31,6 +37,12 @@ interface AuthorizedHubDto {
 	lastSeen: string;
 }
 
+// Pairing allowlist (matches backend HubAllowlistDto).
+export interface HubAllowlist {
+	enabled: boolean;
+	hubIds: string[];
+}
+
 // ---------------------------------------------------------------------------
 // Status / Version
 // ---------------------------------------------------------------------------
@@ -88,3 +100,15 @@ export const SetConsoleLogEnabled = (enabled: boolean) =>
 
 export const GetAuthorizedHubs = () => invoke<AuthorizedHubDto[]>('get_authorized_hubs');
 export const RevokeHub = (hubId: string) => invoke<void>('revoke_hub', { hubId });
+export const GetHubAllowlist = () => invoke<HubAllowlist>('get_hub_allowlist');
+export const SetHubAllowlist = (allowlist: HubAllowlist) =>
+	invoke<void>('set_hub_allowlist', { allowlist });
+
+// ---------------------------------------------------------------------------
+// Uploads
+// ---------------------------------------------------------------------------
+
+export const ListUploads = () => invoke<UploadSessionInfo[]>('list_uploads');
+// idleSecs = 0 uses the agent default (10 minutes).
+export const PruneUploads = (idleSecs = 0) =>
+	invoke<UploadSessionInfo[]>('prune_uploads', { idleSecs });
@@ -0,0 +1,295 @@
+//! Artwork that couldn't be applied to a shortcut, kept on disk so it can
+//! be retried once Steam's CEF debugger answers, even across agent restarts.
+//!
+//! Each app gets a directory named after its AppID holding one file per
+//! artwork type; `queue.json` lists the apps, their images and how many
+//! retries they've had.
+
+use std::path::PathBuf;
+use std::sync::Mutex;
+use std::sync::atomic::{AtomicBool, Ordering};
+
+use capydeploy_steam::ArtworkScope;
+use serde::{Deserialize, Serialize};
+
+use crate::helpers::parse_artwork_type;
+use crate::state::PendingArtwork;
+
+/// Retries per app before its artwork is given up on.
+pub const MAX_ARTWORK_ATTEMPTS: u32 = 5;
+
+const INDEX_FILE: &str = "queue.json";
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct QueuedApp {
+    app_id: u32,
+    #[serde(default)]
+    all_users: bool,
+    #[serde(default)]
+    attempts: u32,
+    images: Vec<QueuedImage>,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct QueuedImage {
+    artwork_type: String,
+    content_type: String,
+}
+
+/// Queued artwork of one app, due for another try.
+pub struct RetryBatch {
+    pub app_id: u32,
+    pub scope: ArtworkScope,
+    pub items: Vec<PendingArtwork>,
+}
+
+/// Durable queue of artwork keyed by AppID.
+pub struct ArtworkRetryQueue {
+    dir: PathBuf,
+    apps: Mutex<Vec<QueuedApp>>,
+    retrying: AtomicBool,
+}
+
+impl ArtworkRetryQueue {
+    /// Loads the queue kept in `dir`. A missing or unreadable index
+    /// starts empty.
+    pub fn open(dir: PathBuf) -> Self {
+        let apps = std::fs::read(dir.join(INDEX_FILE))
+            .ok()
+            .and_then(|data| serde_json::from_slice(&data).ok())
+            .unwrap_or_default();
+        Self {
+            dir,
+            apps: Mutex::new(apps),
+            retrying: AtomicBool::new(false),
+        }
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.apps.lock().unwrap().is_empty()
+    }
+
+    /// Queues `items` for `app_id`, replacing images of the same type
+    /// queued before, and starts its retry count over.
+    pub fn push(
+        &self,
+        app_id: u32,
+        scope: ArtworkScope,
+        items: &[PendingArtwork],
+    ) -> std::io::Result<()> {
+        let app_dir = self.dir.join(app_id.to_string());
+        std::fs::create_dir_all(&app_dir)?;
+
+        let mut apps = self.apps.lock().unwrap();
+        let pos = match apps.iter().position(|a| a.app_id == app_id) {
+            Some(pos) => pos,
+            None => {
+                apps.push(QueuedApp {
+                    app_id,
+                    all_users: false,
+                    attempts: 0,
+                    images: Vec::new(),
+                });
+                apps.len() - 1
+            }
+        };
+        let app = &mut apps[pos];
+        app.all_users = scope == ArtworkScope::AllUsersWithShortcut;
+        app.attempts = 0;
+        for pa in items {
+            // The type names the file, so only known ones are kept.
+            if parse_artwork_type(&pa.artwork_type).is_none() {
+                continue;
+            }
+            std::fs::write(app_dir.join(&pa.artwork_type), &pa.data)?;
+            app.images.retain(|i| i.artwork_type != pa.artwork_type);
+            app.images.push(QueuedImage {
+                artwork_type: pa.artwork_type.clone(),
+                content_type: pa.content_type.clone(),
+            });
+        }
+        if app.images.is_empty() {
+            apps.remove(pos);
+        }
+        self.save(&apps)
+    }
+
+    /// Reads back everything queued. Images whose file went missing are
+    /// left out.
+    pub fn batches(&self) -> Vec<RetryBatch> {
+        let apps = self.apps.lock().unwrap();
+        apps.iter()
+            .map(|app| {
+                let app_dir = self.dir.join(app.app_id.to_string());
+                let items = app
+                    .images
+                    .iter()
+                    .filter_map(|img| {
+                        let data = std::fs::read(app_dir.join(&img.artwork_type)).ok()?;
+                        Some(PendingArtwork {
+                            artwork_type: img.artwork_type.clone(),
+                            content_type: img.content_type.clone(),
+                            data,
+                        })
+                    })
+                    .collect();
+                RetryBatch {
+                    app_id: app.app_id,
+                    scope: if app.all_users {
+                        ArtworkScope::AllUsersWithShortcut
+                    } else {
+                        ArtworkScope::Owner
+                    },
+                    items,
+                }
+            })
+            .collect()
+    }
+
+    /// Records a retry of `app_id`, where the types in `failed` still
+    /// didn't apply. The rest are forgotten, and so is the whole app once
+    /// nothing is left or it ran out of attempts.
+    pub fn record(&self, app_id: u32, failed: &[String]) -> std::io::Result<()> {
+        let mut apps = self.apps.lock().unwrap();
+        let Some(pos) = apps.iter().position(|a| a.app_id == app_id) else {
+            return Ok(());
+        };
+        let app_dir = self.dir.join(app_id.to_string());
+        let app = &mut apps[pos];
+        app.attempts += 1;
+        let give_up = app.attempts >= MAX_ARTWORK_ATTEMPTS;
+        app.images.retain(|img| {
+            let keep = !give_up && failed.contains(&img.artwork_type);
+            if !keep {
+                let _ = std::fs::remove_file(app_dir.join(&img.artwork_type));
+            }
+            keep
+        });
+        if app.images.is_empty() {
+            if give_up {
+                tracing::warn!(
+                    app_id,
+                    "giving up on artwork after {MAX_ARTWORK_ATTEMPTS} tries"
+                );
+            }
+            apps.remove(pos);
+            let _ = std::fs::remove_dir_all(&app_dir);
+        }
+        self.save(&apps)
+    }
+
+    /// Claims the queue for a retry pass; `false` while another one runs.
+    pub fn begin_pass(&self) -> bool {
+        !self.retrying.swap(true, Ordering::SeqCst)
+    }
+
+    pub fn end_pass(&self) {
+        self.retrying.store(false, Ordering::SeqCst);
+    }
+
+    fn save(&self, apps: &[QueuedApp]) -> std::io::Result<()> {
+        std::fs::create_dir_all(&self.dir)?;
+        let tmp = self.dir.join(format!("{INDEX_FILE}.tmp"));
+        std::fs::write(&tmp, serde_json::to_vec_pretty(apps)?)?;
+        std::fs::rename(&tmp, self.dir.join(INDEX_FILE))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn temp_queue_dir() -> PathBuf {
+        std::env::temp_dir().join(format!("capydeploy-artwork-retry-{}", uuid::Uuid::new_v4()))
+    }
+
+    fn image(artwork_type: &str, data: &[u8]) -> PendingArtwork {
+        PendingArtwork {
+            artwork_type: artwork_type.into(),
+            content_type: "image/png".into(),
+            data: data.to_vec(),
+        }
+    }
+
+    #[test]
+    fn survives_reopening() {
+        let dir = temp_queue_dir();
+        let queue = ArtworkRetryQueue::open(dir.clone());
+        queue
+            .push(
+                42,
+                ArtworkScope::AllUsersWithShortcut,
+                &[image("grid", b"G"), image("hero", b"H")],
+            )
+            .unwrap();
+
+        let reopened = ArtworkRetryQueue::open(dir.clone());
+        let batches = reopened.batches();
+        assert_eq!(batches.len(), 1);
+        assert_eq!(batches[0].app_id, 42);
+        assert_eq!(batches[0].scope, ArtworkScope::AllUsersWithShortcut);
+        assert_eq!(batches[0].items.len(), 2);
+        assert_eq!(batches[0].items[1].data, b"H");
+
+        let _ = std::fs::remove_dir_all(dir);
+    }
+
+    #[test]
+    fn applied_images_are_forgotten() {
+        let dir = temp_queue_dir();
+        let queue = ArtworkRetryQueue::open(dir.clone());
+        queue
+            .push(
+                7,
+                ArtworkScope::Owner,
+                &[image("grid", b"G"), image("logo", b"L")],
+            )
+            .unwrap();
+
+        queue.record(7, &["logo".to_string()]).unwrap();
+        let batches = queue.batches();
+        assert_eq!(batches[0].items.len(), 1);
+        assert_eq!(batches[0].items[0].artwork_type, "logo");
+        assert!(!dir.join("7").join("grid").exists());
+
+        queue.record(7, &[]).unwrap();
+        assert!(queue.is_empty());
+        assert!(!dir.join("7").exists());
+
+        let _ = std::fs::remove_dir_all(dir);
+    }
+
+    #[test]
+    fn gives_up_after_max_attempts() {
+        let dir = temp_queue_dir();
+        let queue = ArtworkRetryQueue::open(dir.clone());
+        queue
+            .push(9, ArtworkScope::Owner, &[image("icon", b"I")])
+            .unwrap();
+
+        let failed = vec!["icon".to_string()];
+        for _ in 1..MAX_ARTWORK_ATTEMPTS {
+            queue.record(9, &failed).unwrap();
+            assert!(!queue.is_empty());
+        }
+        queue.record(9, &failed).unwrap();
+        assert!(queue.is_empty());
+
+        let _ = std::fs::remove_dir_all(dir);
+    }
+
+    #[test]
+    fn unknown_types_are_not_written() {
+        let dir = temp_queue_dir();
+        let queue = ArtworkRetryQueue::open(dir.clone());
+        queue
+            .push(3, ArtworkScope::Owner, &[image("../escape", b"X")])
+            .unwrap();
+        assert!(queue.is_empty());
+        assert!(!dir.join("escape").exists());
+
+        let _ = std::fs::remove_dir_all(dir);
+    }
+}
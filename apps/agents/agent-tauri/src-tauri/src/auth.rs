@@ -2,9 +2,11 @@
 //!
 //! Port of Go `apps/agents/desktop/auth/auth.go`.
 
+use std::collections::{HashMap, HashSet};
 use std::time::{Duration, Instant};
 
 use base64::Engine;
+use chrono::{DateTime, Utc};
 
 /// Code length (6 digits).
 const CODE_LENGTH: usize = 6;
@@ -12,10 +14,18 @@ const CODE_LENGTH: usize = 6;
 const CODE_EXPIRY: Duration = Duration::from_secs(60);
 /// Token length in random bytes.
 const TOKEN_LENGTH: usize = 32;
-/// Max failed attempts before rate limiting.
-const MAX_FAILED_ATTEMPTS: u32 = 3;
-/// Rate limit duration after max failed attempts.
+/// Wrong codes one source may try per pairing code before it is locked out.
+const MAX_FAILED_ATTEMPTS: u32 = 5;
+/// How long a source stays locked out after too many wrong codes.
 const RATE_LIMIT_DURATION: Duration = Duration::from_secs(300);
+/// Pairing codes one source IP may request per [`CODE_REQUEST_WINDOW`].
+const MAX_CODES_PER_SOURCE: usize = 5;
+const CODE_REQUEST_WINDOW: Duration = Duration::from_secs(300);
+/// Token lifetime when the config doesn't set one.
+pub const DEFAULT_TOKEN_TTL_DAYS: u32 = 90;
+/// A token presented with less than this left is replaced (capped at half
+/// the lifetime, so short lifetimes don't renew on every connect).
+const TOKEN_RENEWAL_DAYS: i64 = 14;
 
 /// Active pairing session.
 #[derive(Debug, Clone)]
@@ -24,67 +34,110 @@ pub struct PairingSession {
     pub hub_id: String,
     pub hub_name: String,
     pub hub_platform: String,
+    /// IP the code was requested from; only it may confirm the code.
+    pub source: String,
     pub expires_at: Instant,
 }
 
+/// Wrong pairing codes from one source IP.
+#[derive(Debug, Default)]
+struct SourceFailures {
+    attempts: u32,
+    locked_until: Option<Instant>,
+}
+
 /// Manages pairing codes and token validation.
 pub struct AuthManager {
     pending: Option<PairingSession>,
-    failed_attempts: u32,
-    rate_limit_until: Option<Instant>,
+    /// Failed attempts and lockouts per source IP, so one client guessing
+    /// codes can't lock out another.
+    failures: HashMap<String, SourceFailures>,
+    /// Hub IDs allowed to pair; `None` lets any Hub pair.
+    allowlist: Option<HashSet<String>>,
+    /// Recent code requests per source IP.
+    code_requests: HashMap<String, Vec<Instant>>,
 }
 
 impl AuthManager {
     pub fn new() -> Self {
         Self {
             pending: None,
-            failed_attempts: 0,
-            rate_limit_until: None,
+            failures: HashMap::new(),
+            allowlist: None,
+            code_requests: HashMap::new(),
+        }
+    }
+
+    /// Restricts pairing to the given Hub IDs, or lifts the restriction
+    /// with `None`. Hubs already paired keep their tokens. A pending
+    /// pairing from a Hub no longer allowed is dropped.
+    pub fn set_allowlist(&mut self, ids: Option<Vec<String>>) {
+        self.allowlist = ids.map(|ids| ids.into_iter().collect());
+        if let Some(session) = &self.pending
+            && !self.is_allowed(&session.hub_id)
+        {
+            self.pending = None;
         }
     }
 
-    /// Generates a 6-digit pairing code for the given Hub.
+    /// Whether the Hub may pair.
+    pub fn is_allowed(&self, hub_id: &str) -> bool {
+        self.allowlist
+            .as_ref()
+            .is_none_or(|ids| ids.contains(hub_id))
+    }
+
+    /// Generates a 6-digit pairing code for the given Hub, connecting from
+    /// `source` (its IP). Each source may only ask for a few codes in a
+    /// row, so a client can't churn codes to get more guesses.
     pub fn generate_code(
         &mut self,
         hub_id: &str,
         hub_name: &str,
         hub_platform: &str,
+        source: &str,
     ) -> Result<String, AuthError> {
-        // Check rate limiting
-        if let Some(until) = self.rate_limit_until {
-            if Instant::now() < until {
-                return Err(AuthError::RateLimited);
-            }
-            self.rate_limit_until = None;
+        if !self.is_allowed(hub_id) {
+            return Err(AuthError::HubNotAllowed);
+        }
+        self.check_locked_out(source)?;
+
+        let now = Instant::now();
+        self.code_requests
+            .retain(|_, times| times.iter().any(|t| now - *t < CODE_REQUEST_WINDOW));
+        let requests = self.code_requests.entry(source.to_string()).or_default();
+        requests.retain(|t| now - *t < CODE_REQUEST_WINDOW);
+        if requests.len() >= MAX_CODES_PER_SOURCE {
+            return Err(AuthError::TooManyCodeRequests);
         }
+        requests.push(now);
 
         let code = generate_numeric_code(CODE_LENGTH)?;
 
+        // Attempts count against one code; lockouts outlive it.
+        self.failures.retain(|_, f| f.locked_until.is_some());
         self.pending = Some(PairingSession {
             code: code.clone(),
             hub_id: hub_id.to_string(),
             hub_name: hub_name.to_string(),
             hub_platform: hub_platform.to_string(),
+            source: source.to_string(),
             expires_at: Instant::now() + CODE_EXPIRY,
         });
 
         Ok(code)
     }
 
-    /// Validates a pairing code. Returns a token on success.
+    /// Validates a pairing code sent from `source` (its IP). Returns a
+    /// token on success.
     pub fn validate_code(
         &mut self,
         hub_id: &str,
         _hub_name: &str,
         code: &str,
+        source: &str,
     ) -> Result<String, AuthError> {
-        // Check rate limiting
-        if let Some(until) = self.rate_limit_until {
-            if Instant::now() < until {
-                return Err(AuthError::RateLimited);
-            }
-            self.rate_limit_until = None;
-        }
+        self.check_locked_out(source)?;
 
         let session = self.pending.as_ref().ok_or(AuthError::NoPendingPairing)?;
 
@@ -94,16 +147,9 @@ impl AuthManager {
             return Err(AuthError::CodeExpired);
         }
 
-        // Check Hub ID matches
-        if session.hub_id != hub_id {
-            self.record_failure();
-            return Err(AuthError::CodeInvalid);
-        }
-
-        // Validate code
-        if session.code != code {
-            self.record_failure();
-            return Err(AuthError::CodeInvalid);
+        // Check Hub ID, source and code
+        if session.hub_id != hub_id || session.source != source || session.code != code {
+            return Err(self.record_failure(source));
         }
 
         // Generate token
@@ -111,20 +157,41 @@ impl AuthManager {
 
         // Clear state
         self.pending = None;
-        self.failed_attempts = 0;
+        self.failures.remove(source);
 
         Ok(token)
     }
 
-    /// Checks if a Hub's token matches one in the authorized list.
+    /// Checks a Hub's token against the authorized list and its expiry.
+    ///
+    /// Tokens stored before expiry existed have none; they are accepted
+    /// once and renewed, which gives them one. The token a renewal replaced
+    /// is recognized until the Hub uses the new one.
     pub fn validate_token(
         authorized_hubs: &[crate::config::AuthorizedHub],
         hub_id: &str,
         token: &str,
-    ) -> bool {
-        authorized_hubs
-            .iter()
-            .any(|h| h.id == hub_id && h.token == token)
+        ttl_days: u32,
+        now: DateTime<Utc>,
+    ) -> TokenCheck {
+        let Some(hub) = authorized_hubs.iter().find(|h| h.id == hub_id) else {
+            return TokenCheck::Invalid;
+        };
+        if hub.token == token {
+            return check_expiry(&hub.token_expires_at, ttl_days, now);
+        }
+        if !hub.previous_token.is_empty() && hub.previous_token == token {
+            return match check_expiry(&hub.previous_token_expires_at, ttl_days, now) {
+                TokenCheck::Expired => TokenCheck::Expired,
+                _ => TokenCheck::Resend,
+            };
+        }
+        TokenCheck::Invalid
+    }
+
+    /// Issues a fresh token, replacing one close to expiry.
+    pub fn renew_token() -> Result<String, AuthError> {
+        generate_token(TOKEN_LENGTH)
     }
 
     /// Returns the pending pairing session (if not expired).
@@ -140,15 +207,86 @@ impl AuthManager {
         self.pending = None;
     }
 
-    fn record_failure(&mut self) {
-        self.failed_attempts += 1;
-        if self.failed_attempts >= MAX_FAILED_ATTEMPTS {
-            self.rate_limit_until = Some(Instant::now() + RATE_LIMIT_DURATION);
-            self.failed_attempts = 0;
+    /// Refuses a source while its lockout lasts.
+    fn check_locked_out(&mut self, source: &str) -> Result<(), AuthError> {
+        let Some(until) = self.failures.get(source).and_then(|f| f.locked_until) else {
+            return Ok(());
+        };
+        if Instant::now() < until {
+            return Err(AuthError::RateLimited);
+        }
+        self.failures.remove(source);
+        Ok(())
+    }
+
+    /// Counts a wrong guess from `source`. Too many lock that source out;
+    /// if it requested the pending code, the code is burned too, so its
+    /// next attempt needs a fresh one. Other sources are unaffected.
+    fn record_failure(&mut self, source: &str) -> AuthError {
+        let failures = self.failures.entry(source.to_string()).or_default();
+        failures.attempts += 1;
+        if failures.attempts < MAX_FAILED_ATTEMPTS {
+            return AuthError::CodeInvalid;
+        }
+        failures.attempts = 0;
+        failures.locked_until = Some(Instant::now() + RATE_LIMIT_DURATION);
+        if self.pending.as_ref().is_some_and(|p| p.source == source) {
+            self.pending = None;
         }
+        AuthError::LockedOut
+    }
+}
+
+/// Outcome of [`AuthManager::validate_token`].
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum TokenCheck {
+    /// Unknown Hub or wrong token.
+    Invalid,
+    /// The token was valid but has expired; the Hub must pair again.
+    Expired,
+    Valid,
+    /// Valid, but close to expiry: a new token should be issued.
+    Renew,
+    /// The token replaced by the last renewal, still unexpired: the Hub
+    /// never got the new token, which should be sent again.
+    Resend,
+}
+
+/// Checks a token's expiry, `expires_at` in RFC 3339 (empty for tokens
+/// issued before tokens expired).
+fn check_expiry(expires_at: &str, ttl_days: u32, now: DateTime<Utc>) -> TokenCheck {
+    if expires_at.is_empty() {
+        return TokenCheck::Renew;
+    }
+    let Ok(expires_at) = DateTime::parse_from_rfc3339(expires_at) else {
+        return TokenCheck::Expired;
+    };
+    let expires_at = expires_at.with_timezone(&Utc);
+    if now >= expires_at {
+        return TokenCheck::Expired;
+    }
+    let ttl = chrono::Duration::days(i64::from(token_ttl_days(ttl_days)));
+    let window = chrono::Duration::days(TOKEN_RENEWAL_DAYS).min(ttl / 2);
+    if expires_at - now <= window {
+        TokenCheck::Renew
+    } else {
+        TokenCheck::Valid
+    }
+}
+
+/// Token lifetime in days, with 0 meaning the default.
+pub fn token_ttl_days(configured: u32) -> u32 {
+    match configured {
+        0 => DEFAULT_TOKEN_TTL_DAYS,
+        n => n,
     }
 }
 
+/// Expiry, as RFC 3339, of a token issued at `now`.
+pub fn token_expiry(now: DateTime<Utc>, ttl_days: u32) -> String {
+    (now + chrono::Duration::days(i64::from(token_ttl_days(ttl_days)))).to_rfc3339()
+}
+
 /// Auth errors.
 #[derive(Debug, thiserror::Error)]
 pub enum AuthError {
@@ -158,8 +296,15 @@ pub enum AuthError {
     CodeInvalid,
     #[error("too many failed attempts, try again later")]
     RateLimited,
+    /// This guess was one too many; pairing is now rate limited.
+    #[error("too many failed attempts, pairing locked for 5 minutes")]
+    LockedOut,
+    #[error("too many pairing requests, try again later")]
+    TooManyCodeRequests,
     #[error("no pending pairing")]
     NoPendingPairing,
+    #[error("this Hub is not on the agent's allowlist")]
+    HubNotAllowed,
 }
 
 /// Generates a random n-digit numeric code.
@@ -182,3 +327,283 @@ fn generate_token(length: usize) -> Result<String, AuthError> {
     rand::thread_rng().fill_bytes(&mut bytes);
     Ok(base64::engine::general_purpose::URL_SAFE.encode(&bytes))
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::AuthorizedHub;
+
+    fn hub(token_expires_at: String) -> Vec<AuthorizedHub> {
+        vec![AuthorizedHub {
+            id: "hub-1".into(),
+            name: "Hub".into(),
+            platform: String::new(),
+            token: "tok".into(),
+            paired_at: String::new(),
+            last_seen: String::new(),
+            token_expires_at,
+            previous_token: String::new(),
+            previous_token_expires_at: String::new(),
+        }]
+    }
+
+    fn check(hubs: &[AuthorizedHub], now: DateTime<Utc>) -> TokenCheck {
+        AuthManager::validate_token(hubs, "hub-1", "tok", 0, now)
+    }
+
+    #[test]
+    fn fresh_token_is_valid() {
+        let now = Utc::now();
+        assert_eq!(check(&hub(token_expiry(now, 0)), now), TokenCheck::Valid);
+    }
+
+    #[test]
+    fn wrong_token_is_invalid() {
+        let now = Utc::now();
+        let hubs = hub(token_expiry(now, 0));
+        assert_eq!(
+            AuthManager::validate_token(&hubs, "hub-1", "other", 0, now),
+            TokenCheck::Invalid
+        );
+        assert_eq!(
+            AuthManager::validate_token(&hubs, "hub-2", "tok", 0, now),
+            TokenCheck::Invalid
+        );
+    }
+
+    #[test]
+    fn expired_token_is_rejected() {
+        let issued = Utc::now() - chrono::Duration::days(91);
+        assert_eq!(
+            check(&hub(token_expiry(issued, 0)), Utc::now()),
+            TokenCheck::Expired
+        );
+        assert_eq!(
+            check(&hub("not a date".into()), Utc::now()),
+            TokenCheck::Expired
+        );
+    }
+
+    #[test]
+    fn token_near_expiry_is_renewed() {
+        let now = Utc::now();
+        let issued = now - chrono::Duration::days(80);
+        assert_eq!(check(&hub(token_expiry(issued, 0)), now), TokenCheck::Renew);
+        // Just outside the 14-day window.
+        let issued = now - chrono::Duration::days(75);
+        assert_eq!(check(&hub(token_expiry(issued, 0)), now), TokenCheck::Valid);
+    }
+
+    #[test]
+    fn renewal_window_is_capped_for_short_lifetimes() {
+        let now = Utc::now();
+        let hubs = hub(token_expiry(now - chrono::Duration::days(2), 10));
+        assert_eq!(
+            AuthManager::validate_token(&hubs, "hub-1", "tok", 10, now),
+            TokenCheck::Valid
+        );
+        let hubs = hub(token_expiry(now - chrono::Duration::days(6), 10));
+        assert_eq!(
+            AuthManager::validate_token(&hubs, "hub-1", "tok", 10, now),
+            TokenCheck::Renew
+        );
+    }
+
+    #[test]
+    fn token_without_expiry_is_renewed() {
+        assert_eq!(check(&hub(String::new()), Utc::now()), TokenCheck::Renew);
+    }
+
+    #[test]
+    fn old_token_works_until_renewed_token_is_used() {
+        let now = Utc::now();
+        let mut hubs = hub(token_expiry(now - chrono::Duration::days(80), 0));
+        assert_eq!(check(&hubs, now), TokenCheck::Renew);
+        hubs[0].renew_token("new", &token_expiry(now, 0));
+
+        // The reply carrying "new" was lost: the Hub comes back with the
+        // old token and is handed the new one again.
+        assert_eq!(check(&hubs, now), TokenCheck::Resend);
+        assert_eq!(check(&hubs, now), TokenCheck::Resend);
+        assert_eq!(hubs[0].token, "new");
+
+        // First use of the new token retires the old one.
+        assert_eq!(
+            AuthManager::validate_token(&hubs, "hub-1", "new", 0, now),
+            TokenCheck::Valid
+        );
+        assert!(hubs[0].retire_previous_token());
+        assert_eq!(check(&hubs, now), TokenCheck::Invalid);
+        assert!(!hubs[0].retire_previous_token());
+    }
+
+    #[test]
+    fn expired_old_token_is_not_resent() {
+        let now = Utc::now();
+        let mut hubs = hub(token_expiry(now - chrono::Duration::days(89), 0));
+        hubs[0].renew_token("new", &token_expiry(now, 0));
+        let later = now + chrono::Duration::days(2);
+        assert_eq!(check(&hubs, later), TokenCheck::Expired);
+    }
+
+    fn pending_code(auth: &AuthManager) -> String {
+        auth.pending_pairing().unwrap().code.clone()
+    }
+
+    /// A code guaranteed not to be the pending one.
+    fn wrong_code(auth: &AuthManager) -> String {
+        if pending_code(auth) == "000000" {
+            "111111".into()
+        } else {
+            "000000".into()
+        }
+    }
+
+    #[test]
+    fn repeated_wrong_codes_lock_pairing_out() {
+        let mut auth = AuthManager::new();
+        auth.generate_code("hub-1", "Hub", "linux", "10.0.0.1")
+            .unwrap();
+        let wrong = wrong_code(&auth);
+
+        for _ in 1..MAX_FAILED_ATTEMPTS {
+            assert!(matches!(
+                auth.validate_code("hub-1", "Hub", &wrong, "10.0.0.1"),
+                Err(AuthError::CodeInvalid)
+            ));
+        }
+        let code = pending_code(&auth);
+        assert!(matches!(
+            auth.validate_code("hub-1", "Hub", &wrong, "10.0.0.1"),
+            Err(AuthError::LockedOut)
+        ));
+
+        // The code is burned, and even the right one is refused now.
+        assert!(auth.pending_pairing().is_none());
+        assert!(matches!(
+            auth.validate_code("hub-1", "Hub", &code, "10.0.0.1"),
+            Err(AuthError::RateLimited)
+        ));
+        assert!(matches!(
+            auth.generate_code("hub-1", "Hub", "linux", "10.0.0.1"),
+            Err(AuthError::RateLimited)
+        ));
+    }
+
+    #[test]
+    fn lockout_ends_and_success_resets_failures() {
+        let mut auth = AuthManager::new();
+        auth.generate_code("hub-1", "Hub", "linux", "10.0.0.1")
+            .unwrap();
+        let wrong = wrong_code(&auth);
+        for _ in 0..MAX_FAILED_ATTEMPTS {
+            let _ = auth.validate_code("hub-1", "Hub", &wrong, "10.0.0.1");
+        }
+
+        // Once the lockout has passed, pairing works again.
+        auth.failures.get_mut("10.0.0.1").unwrap().locked_until =
+            Some(Instant::now() - Duration::from_secs(1));
+        auth.generate_code("hub-1", "Hub", "linux", "10.0.0.1")
+            .unwrap();
+        let wrong = wrong_code(&auth);
+        assert!(matches!(
+            auth.validate_code("hub-1", "Hub", &wrong, "10.0.0.1"),
+            Err(AuthError::CodeInvalid)
+        ));
+        let code = pending_code(&auth);
+        assert!(
+            auth.validate_code("hub-1", "Hub", &code, "10.0.0.1")
+                .is_ok()
+        );
+        assert!(auth.failures.is_empty());
+    }
+
+    #[test]
+    fn lockout_is_per_source() {
+        let mut auth = AuthManager::new();
+        auth.generate_code("hub-1", "Hub", "linux", "10.0.0.1")
+            .unwrap();
+        let code = pending_code(&auth);
+        let wrong = wrong_code(&auth);
+
+        // Someone else on the network guesses until locked out.
+        for _ in 1..MAX_FAILED_ATTEMPTS {
+            assert!(matches!(
+                auth.validate_code("hub-1", "Hub", &wrong, "10.0.0.9"),
+                Err(AuthError::CodeInvalid)
+            ));
+        }
+        assert!(matches!(
+            auth.validate_code("hub-1", "Hub", &wrong, "10.0.0.9"),
+            Err(AuthError::LockedOut)
+        ));
+        assert!(matches!(
+            auth.generate_code("hub-9", "Other", "linux", "10.0.0.9"),
+            Err(AuthError::RateLimited)
+        ));
+        // Even the right code is refused from a source that didn't ask
+        // for it.
+        assert!(matches!(
+            auth.validate_code("hub-1", "Hub", &code, "10.0.0.9"),
+            Err(AuthError::RateLimited)
+        ));
+
+        // The Hub that requested the code still pairs with it.
+        assert_eq!(pending_code(&auth), code);
+        assert!(
+            auth.validate_code("hub-1", "Hub", &code, "10.0.0.1")
+                .is_ok()
+        );
+    }
+
+    #[test]
+    fn code_requests_are_limited_per_source() {
+        let mut auth = AuthManager::new();
+        for _ in 0..MAX_CODES_PER_SOURCE {
+            auth.generate_code("hub-1", "Hub", "linux", "10.0.0.1")
+                .unwrap();
+        }
+        assert!(matches!(
+            auth.generate_code("hub-1", "Hub", "linux", "10.0.0.1"),
+            Err(AuthError::TooManyCodeRequests)
+        ));
+        // Other sources are unaffected.
+        assert!(
+            auth.generate_code("hub-2", "Hub", "linux", "10.0.0.2")
+                .is_ok()
+        );
+
+        // Requests older than the window no longer count.
+        for t in auth.code_requests.get_mut("10.0.0.1").unwrap() {
+            *t -= CODE_REQUEST_WINDOW;
+        }
+        assert!(
+            auth.generate_code("hub-1", "Hub", "linux", "10.0.0.1")
+                .is_ok()
+        );
+    }
+
+    #[test]
+    fn allowlist_limits_pairing() {
+        let mut auth = AuthManager::new();
+        assert!(
+            auth.generate_code("hub-2", "Hub 2", "linux", "10.0.0.2")
+                .is_ok()
+        );
+
+        auth.set_allowlist(Some(vec!["hub-1".into()]));
+        // The pending pairing of a Hub that's no longer allowed is dropped.
+        assert!(auth.pending_pairing().is_none());
+        assert!(matches!(
+            auth.generate_code("hub-2", "Hub 2", "linux", "10.0.0.2"),
+            Err(AuthError::HubNotAllowed)
+        ));
+        assert!(
+            auth.generate_code("hub-1", "Hub 1", "linux", "10.0.0.1")
+                .is_ok()
+        );
+
+        auth.set_allowlist(None);
+        assert!(auth.is_allowed("hub-2"));
+    }
+}
@@ -3,7 +3,7 @@ use std::sync::Arc;
 use tauri::{Emitter, State};
 
 use crate::state::AgentState;
-use crate::types::AuthorizedHubDto;
+use crate::types::{AuthorizedHubDto, HubAllowlistDto};
 
 #[tauri::command]
 pub async fn get_authorized_hubs(
@@ -58,3 +58,46 @@ pub async fn revoke_hub(
     super::emit_status(&app, &state).await;
     Ok(())
 }
+
+#[tauri::command]
+pub async fn get_hub_allowlist(
+    state: State<'_, Arc<AgentState>>,
+) -> Result<HubAllowlistDto, String> {
+    let config = state.config.lock().await;
+    Ok(HubAllowlistDto {
+        enabled: config.hub_allowlist_enabled,
+        hub_ids: config.hub_allowlist.clone(),
+    })
+}
+
+/// Saves the pairing allowlist. While enabled, only the listed Hubs can
+/// pair; already paired Hubs are unaffected.
+#[tauri::command]
+pub async fn set_hub_allowlist(
+    allowlist: HubAllowlistDto,
+    state: State<'_, Arc<AgentState>>,
+) -> Result<(), String> {
+    // Drops blanks and repeats, keeping the order the user gave.
+    let mut seen = std::collections::HashSet::new();
+    let hub_ids: Vec<String> = allowlist
+        .hub_ids
+        .iter()
+        .map(|id| id.trim().to_string())
+        .filter(|id| !id.is_empty() && seen.insert(id.clone()))
+        .collect();
+
+    let mut config = state.config.lock().await;
+    config.hub_allowlist_enabled = allowlist.enabled;
+    config.hub_allowlist = hub_ids;
+    config.save().map_err(|e| e.to_string())?;
+    let ids = config.pairing_allowlist();
+    drop(config);
+
+    tracing::info!(
+        enabled = allowlist.enabled,
+        hubs = ids.as_ref().map_or(0, Vec::len),
+        "updated pairing allowlist"
+    );
+    state.auth.lock().await.set_allowlist(ids);
+    Ok(())
+}
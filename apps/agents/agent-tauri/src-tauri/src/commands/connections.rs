@@ -14,6 +14,9 @@ pub async fn set_accept_connections(
     state.accept_connections.store(accept, Ordering::Relaxed);
     tracing::info!("Accept connections: {accept}");
 
+    // Tell the Hub before it is dropped below.
+    super::notify_config_changed(&state).await;
+
     if !accept {
         // Disconnect current Hub — same cleanup as disconnect_hub.
         *state.connected_hub.lock().await = None;
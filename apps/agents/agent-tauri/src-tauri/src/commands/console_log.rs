@@ -28,6 +28,8 @@ pub async fn set_console_log_enabled(
     config.save().map_err(|e| e.to_string())?;
     drop(config);
 
+    super::notify_config_changed(&state).await;
+
     tracing::info!("Console log enabled: {enabled}");
     super::emit_status(&app, &state).await;
     Ok(())
@@ -27,6 +27,8 @@ pub async fn select_install_path(
     config.save().map_err(|e| e.to_string())?;
     drop(config);
 
+    super::notify_config_changed(&state).await;
+
     tracing::info!("Install path changed to: {path}");
     super::emit_status(&app, &state).await;
     Ok(path)
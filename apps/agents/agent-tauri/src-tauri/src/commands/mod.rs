@@ -6,6 +6,7 @@ pub mod settings;
 pub mod status;
 pub mod steam;
 pub mod telemetry;
+pub mod uploads;
 
 use std::sync::Arc;
 use std::sync::atomic::Ordering;
@@ -14,6 +15,7 @@ use tauri::{AppHandle, Emitter};
 
 use capydeploy_protocol::constants::MessageType;
 use capydeploy_protocol::envelope::Message;
+use capydeploy_protocol::messages::ConfigChangedEvent;
 
 use crate::state::AgentState;
 use crate::types::{AgentStatusDto, ConnectedHubDto};
@@ -72,6 +74,23 @@ pub fn notify_telemetry_status(state: &AgentState, enabled: bool, interval: i32)
     );
 }
 
+/// Pushes the agent's current settings to the Hub after any of them
+/// change, so its cached copy stays in sync.
+pub async fn notify_config_changed(state: &AgentState) {
+    let event = {
+        let config = state.config.lock().await;
+        ConfigChangedEvent {
+            install_path: config.install_path.clone(),
+            telemetry_enabled: config.telemetry_enabled,
+            telemetry_interval: config.telemetry_interval,
+            console_log_enabled: config.console_log_enabled,
+            name: config.name.clone(),
+            accept_connections: Some(state.accept_connections.load(Ordering::Relaxed)),
+        }
+    };
+    send_hub_event(state, MessageType::ConfigChanged, &event);
+}
+
 /// Notifies the Hub of the current console log status.
 pub fn notify_console_log_status(state: &AgentState, enabled: bool) {
     use capydeploy_protocol::console_log::ConsoleLogStatusEvent;
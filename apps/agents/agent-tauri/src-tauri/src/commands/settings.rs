@@ -19,6 +19,8 @@ pub async fn set_name(
     config.save().map_err(|e| e.to_string())?;
     drop(config);
 
+    super::notify_config_changed(&state).await;
+
     tracing::info!("Agent name changed to: {name}");
 
     // TODO: restart mDNS discovery with new name
@@ -47,6 +49,8 @@ pub async fn set_install_path(
     config.save().map_err(|e| e.to_string())?;
     drop(config);
 
+    super::notify_config_changed(&state).await;
+
     tracing::info!("Install path changed to: {path}");
     super::emit_status(&app, &state).await;
     Ok(())
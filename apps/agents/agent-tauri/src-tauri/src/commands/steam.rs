@@ -155,6 +155,17 @@ pub async fn delete_shortcut(
     app_id: u32,
     state: State<'_, Arc<AgentState>>,
     app: AppHandle,
+) -> Result<(), String> {
+    remove_shortcut(&state, &app, &user_id, app_id).await
+}
+
+/// Removes a shortcut through Steam and cleans up the Agent's record of it
+/// and its artwork.
+pub(crate) async fn remove_shortcut(
+    state: &AgentState,
+    app: &AppHandle,
+    user_id: &str,
+    app_id: u32,
 ) -> Result<(), String> {
     // Ensure CEF debug file exists.
     let ctrl = capydeploy_steam::Controller::new();
@@ -178,7 +189,7 @@ pub async fn delete_shortcut(
 
     // Delete artwork files (best-effort).
     if let Ok(sm) = capydeploy_steam::ShortcutManager::new() {
-        let _ = sm.delete_artwork(&user_id, app_id);
+        let _ = sm.delete_artwork(user_id, app_id);
     }
 
     let _ = app.emit("shortcuts:changed", &());
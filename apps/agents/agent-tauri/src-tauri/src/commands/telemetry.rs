@@ -29,6 +29,8 @@ pub async fn set_telemetry_enabled(
     // Notify Hub over WS
     super::notify_telemetry_status(&state, enabled, interval);
 
+    super::notify_config_changed(&state).await;
+
     tracing::info!("Telemetry enabled: {enabled}");
     super::emit_status(&app, &state).await;
     Ok(())
@@ -55,6 +57,8 @@ pub async fn set_telemetry_interval(
             .await;
     }
 
+    super::notify_config_changed(&state).await;
+
     tracing::info!("Telemetry interval changed to: {seconds}s");
     super::emit_status(&app, &state).await;
     Ok(())
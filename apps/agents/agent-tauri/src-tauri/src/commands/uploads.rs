@@ -0,0 +1,33 @@
+use std::sync::Arc;
+use std::time::Duration;
+
+use tauri::State;
+
+use capydeploy_protocol::constants::DEFAULT_UPLOAD_PRUNE_IDLE_SECS;
+use capydeploy_protocol::messages::UploadSessionInfo;
+
+use crate::state::AgentState;
+
+#[tauri::command]
+pub async fn list_uploads(
+    state: State<'_, Arc<AgentState>>,
+) -> Result<Vec<UploadSessionInfo>, String> {
+    Ok(crate::handlers::upload::list_upload_sessions(&state).await)
+}
+
+/// Discards uploads idle for `idle_secs` (0 = the protocol default) and
+/// deletes their partial folders, without restarting the agent.
+#[tauri::command]
+pub async fn prune_uploads(
+    idle_secs: u64,
+    state: State<'_, Arc<AgentState>>,
+) -> Result<Vec<UploadSessionInfo>, String> {
+    let idle_secs = match idle_secs {
+        0 => DEFAULT_UPLOAD_PRUNE_IDLE_SECS,
+        n => n,
+    };
+    Ok(
+        crate::handlers::upload::prune_upload_sessions(&state, Duration::from_secs(idle_secs))
+            .await,
+    )
+}
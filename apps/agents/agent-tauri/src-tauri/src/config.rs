@@ -18,6 +18,34 @@ pub struct AuthorizedHub {
     pub token: String,
     pub paired_at: String,
     pub last_seen: String,
+    /// When the token stops being accepted, RFC 3339. Empty for tokens
+    /// issued before tokens expired.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub token_expires_at: String,
+    /// Token replaced by the last renewal. It stays accepted until the Hub
+    /// first connects with the new one, in case the reply carrying the new
+    /// token never reached it.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub previous_token: String,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub previous_token_expires_at: String,
+}
+
+impl AuthorizedHub {
+    /// Switches to a renewed token, keeping the current one as the
+    /// previous token.
+    pub fn renew_token(&mut self, token: &str, expires_at: &str) {
+        self.previous_token = std::mem::replace(&mut self.token, token.to_string());
+        self.previous_token_expires_at =
+            std::mem::replace(&mut self.token_expires_at, expires_at.to_string());
+    }
+
+    /// Stops accepting the previous token, once the Hub has used the new
+    /// one. Returns whether there was one.
+    pub fn retire_previous_token(&mut self) -> bool {
+        self.previous_token_expires_at.clear();
+        !std::mem::take(&mut self.previous_token).is_empty()
+    }
 }
 
 /// On-disk config format (matches Go `config.Config`).
@@ -36,6 +64,26 @@ struct ConfigFile {
     console_log_enabled: bool,
     #[serde(default, skip_serializing_if = "Vec::is_empty")]
     authorized_hubs: Vec<AuthorizedHub>,
+    #[serde(default, skip_serializing_if = "is_zero")]
+    max_binary_frame_size: u64,
+    #[serde(default, skip_serializing_if = "is_zero_u32")]
+    token_ttl_days: u32,
+    #[serde(default, skip_serializing_if = "is_false")]
+    hub_allowlist_enabled: bool,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    hub_allowlist: Vec<String>,
+}
+
+fn is_false(v: &bool) -> bool {
+    !*v
+}
+
+fn is_zero(v: &u64) -> bool {
+    *v == 0
+}
+
+fn is_zero_u32(v: &u32) -> bool {
+    *v == 0
 }
 
 /// Agent configuration.
@@ -47,6 +95,14 @@ pub struct AgentConfig {
     pub telemetry_interval: i32,
     pub console_log_enabled: bool,
     pub authorized_hubs: Vec<AuthorizedHub>,
+    /// Largest binary frame accepted from the Hub (0 = pick from RAM).
+    pub max_binary_frame_size: u64,
+    /// Lifetime of Hub tokens in days (0 = 90).
+    pub token_ttl_days: u32,
+    /// Only Hubs in `hub_allowlist` may pair.
+    pub hub_allowlist_enabled: bool,
+    /// Hub IDs allowed to pair while the allowlist is enabled.
+    pub hub_allowlist: Vec<String>,
     file_path: PathBuf,
 }
 
@@ -66,6 +122,10 @@ impl Default for AgentConfig {
             telemetry_interval: 2,
             console_log_enabled: false,
             authorized_hubs: Vec::new(),
+            max_binary_frame_size: 0,
+            token_ttl_days: 0,
+            hub_allowlist_enabled: false,
+            hub_allowlist: Vec::new(),
             file_path: config_file_path().unwrap_or_else(|_| PathBuf::from("/tmp/config.json")),
         }
     }
@@ -95,6 +155,10 @@ impl AgentConfig {
                 }
                 config.console_log_enabled = file.console_log_enabled;
                 config.authorized_hubs = file.authorized_hubs;
+                config.max_binary_frame_size = file.max_binary_frame_size;
+                config.token_ttl_days = file.token_ttl_days;
+                config.hub_allowlist_enabled = file.hub_allowlist_enabled;
+                config.hub_allowlist = file.hub_allowlist;
             } else {
                 tracing::warn!(
                     path = %file_path.display(),
@@ -119,6 +183,10 @@ impl AgentConfig {
             telemetry_interval: self.telemetry_interval,
             console_log_enabled: self.console_log_enabled,
             authorized_hubs: self.authorized_hubs.clone(),
+            max_binary_frame_size: self.max_binary_frame_size,
+            token_ttl_days: self.token_ttl_days,
+            hub_allowlist_enabled: self.hub_allowlist_enabled,
+            hub_allowlist: self.hub_allowlist.clone(),
         };
 
         let json = serde_json::to_string_pretty(&file)?;
@@ -143,6 +211,37 @@ impl AgentConfig {
         self.authorized_hubs.retain(|h| h.id != hub_id);
     }
 
+    /// Replaces a Hub's token after renewal. The old token is still
+    /// accepted until the Hub connects with the new one.
+    pub fn renew_hub_token(&mut self, hub_id: &str, token: &str, expires_at: &str) {
+        if let Some(hub) = self.authorized_hubs.iter_mut().find(|h| h.id == hub_id) {
+            hub.renew_token(token, expires_at);
+        }
+    }
+
+    /// Retires a Hub's pre-renewal token once it has used the new one.
+    /// Returns whether there was one.
+    pub fn retire_previous_hub_token(&mut self, hub_id: &str) -> bool {
+        self.authorized_hubs
+            .iter_mut()
+            .find(|h| h.id == hub_id)
+            .is_some_and(AuthorizedHub::retire_previous_token)
+    }
+
+    /// A Hub's current token.
+    pub fn hub_token(&self, hub_id: &str) -> Option<&str> {
+        self.authorized_hubs
+            .iter()
+            .find(|h| h.id == hub_id)
+            .map(|h| h.token.as_str())
+    }
+
+    /// The Hub IDs allowed to pair, or `None` when any Hub may pair.
+    pub fn pairing_allowlist(&self) -> Option<Vec<String>> {
+        self.hub_allowlist_enabled
+            .then(|| self.hub_allowlist.clone())
+    }
+
     /// Updates the last_seen timestamp for a Hub.
     pub fn update_hub_last_seen(&mut self, hub_id: &str, last_seen: &str) {
         if let Some(hub) = self.authorized_hubs.iter_mut().find(|h| h.id == hub_id) {
@@ -164,8 +263,12 @@ fn set_permissions_0600(path: &std::path::Path) {
 }
 
 fn config_file_path() -> anyhow::Result<PathBuf> {
-    let config_dir = config_base_dir()?;
-    Ok(config_dir.join("capydeploy-agent").join("config.json"))
+    Ok(agent_dir()?.join("config.json"))
+}
+
+/// Directory holding the agent's config and other persisted state.
+pub(crate) fn agent_dir() -> anyhow::Result<PathBuf> {
+    Ok(config_base_dir()?.join("capydeploy-agent"))
 }
 
 fn config_base_dir() -> anyhow::Result<PathBuf> {
@@ -13,6 +13,9 @@ use crate::handler::TauriAgentHandler;
 use crate::state::AgentState;
 use crate::types::AgentStatusDto;
 
+/// How often queued artwork is retried while CEF is down.
+const ARTWORK_RETRY_INTERVAL: Duration = Duration::from_secs(60);
+
 /// Starts the WS server and mDNS discovery.
 pub async fn start_server(handle: AppHandle, state: Arc<AgentState>) {
     let handler = TauriAgentHandler {
@@ -26,6 +29,8 @@ pub async fn start_server(handle: AppHandle, state: Arc<AgentState>) {
 
     let server_config = ServerConfig {
         port: 0, // OS-assigned
+        max_binary_frame_size: state.max_binary_frame_size,
+        verbose: state.verbose.clone(),
     };
 
     // Share the same AtomicBool: when the Tauri command toggles it,
@@ -45,11 +50,32 @@ pub async fn start_server(handle: AppHandle, state: Arc<AgentState>) {
 
     let port = server.port().await;
     *state.server_port.lock().await = port;
+    state
+        .server_started_at
+        .store(chrono::Utc::now().timestamp_millis(), Ordering::Relaxed);
     tracing::info!("Agent server listening on port {port}");
 
+    // The port is OS-assigned, so it can only be opened once bound.
+    #[cfg(target_os = "linux")]
+    let firewall_rules = crate::helpers::firewall::ensure_rules(port).await;
+
     // Emit initial status
     emit_status(&handle, &state).await;
 
+    // Artwork queued while CEF was down, including by an earlier run.
+    let retry_state = state.clone();
+    tokio::spawn(async move {
+        let mut tick = tokio::time::interval(ARTWORK_RETRY_INTERVAL);
+        loop {
+            tokio::select! {
+                _ = tick.tick() => {
+                    crate::handlers::retry_queued_artwork(retry_state.artwork_retry.clone()).await;
+                }
+                _ = retry_state.shutdown_token.cancelled() => break,
+            }
+        }
+    });
+
     // Start mDNS discovery advertisement
     let mut discovery = start_discovery(&agent_name, port);
 
@@ -71,6 +97,11 @@ pub async fn start_server(handle: AppHandle, state: Arc<AgentState>) {
         let _ = disc.stop();
     }
 
+    #[cfg(target_os = "linux")]
+    if let Some(rules) = firewall_rules {
+        rules.remove().await;
+    }
+
     tracing::info!("agent shutdown complete");
 }
 
@@ -12,7 +12,8 @@ use std::sync::atomic::Ordering;
 use tauri::{AppHandle, Emitter};
 
 use capydeploy_agent_server::{
-    BinaryArtworkHeader, BinaryChunkHeader, Handler, HandlerFuture, Sender,
+    BinaryArtworkBatchHeader, BinaryArtworkHeader, BinaryChunkHeader, Handler, HandlerFuture,
+    Sender,
 };
 use capydeploy_protocol::constants::MessageType;
 use capydeploy_protocol::envelope::Message;
@@ -43,38 +44,106 @@ impl Handler for TauriAgentHandler {
         Box::pin(self.handle_get_info(sender, msg))
     }
 
+    fn on_get_info_lite(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_get_info_lite(sender, msg))
+    }
+
     fn on_get_config(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_get_config(sender, msg))
     }
 
+    fn on_set_install_path(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_set_install_path(sender, msg))
+    }
+
+    fn on_self_test(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_self_test(sender, msg))
+    }
+
+    fn on_can_deploy(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_can_deploy(sender, msg))
+    }
+
+    fn on_browse_directory(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_browse_directory(sender, msg))
+    }
+
+    fn on_get_disk_usage(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_get_disk_usage(sender, msg))
+    }
+
     fn on_get_steam_users(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_get_steam_users(sender, msg))
     }
 
+    fn on_get_steam_libraries(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_get_steam_libraries(sender, msg))
+    }
+
+    fn on_get_steam_status(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_get_steam_status(sender, msg))
+    }
+
+    fn on_list_connected_hubs(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_list_connected_hubs(sender, msg))
+    }
+
     fn on_list_shortcuts(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_list_shortcuts(sender, msg))
     }
 
+    fn on_export_shortcut(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_export_shortcut(sender, msg))
+    }
+
     fn on_create_shortcut(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_create_shortcut(sender, msg))
     }
 
+    fn on_create_shortcuts_batch(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_create_shortcuts_batch(sender, msg))
+    }
+
     fn on_delete_shortcut(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_delete_shortcut(sender, msg))
     }
 
+    fn on_update_shortcut(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_update_shortcut(sender, msg))
+    }
+
     fn on_delete_game(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_delete_game(sender, msg))
     }
 
+    fn on_delete_games_batch(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_delete_games_batch(sender, msg))
+    }
+
+    fn on_rename_game(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_rename_game(sender, msg))
+    }
+
+    fn on_launch_game(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_launch_game(sender, msg))
+    }
+
     fn on_apply_artwork(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_apply_artwork(sender, msg))
     }
 
+    fn on_get_artwork(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_get_artwork(sender, msg))
+    }
+
     fn on_restart_steam(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_restart_steam(sender, msg))
     }
 
+    fn on_check_cef(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_check_cef(sender, msg))
+    }
+
     fn on_init_upload(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_init_upload(sender, msg))
     }
@@ -100,6 +169,26 @@ impl Handler for TauriAgentHandler {
         Box::pin(self.handle_cancel_upload(sender, msg))
     }
 
+    fn on_pause_upload(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_set_upload_paused(sender, msg, true))
+    }
+
+    fn on_resume_upload(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_set_upload_paused(sender, msg, false))
+    }
+
+    fn on_get_upload_status(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_get_upload_status(sender, msg))
+    }
+
+    fn on_list_uploads(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_list_uploads(sender, msg))
+    }
+
+    fn on_prune_uploads(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_prune_uploads(sender, msg))
+    }
+
     fn on_binary_artwork(
         &self,
         sender: Sender,
@@ -109,6 +198,15 @@ impl Handler for TauriAgentHandler {
         Box::pin(self.handle_binary_artwork(sender, header, data))
     }
 
+    fn on_binary_artwork_batch(
+        &self,
+        sender: Sender,
+        header: BinaryArtworkBatchHeader,
+        images: Vec<Vec<u8>>,
+    ) -> HandlerFuture<'_> {
+        Box::pin(self.handle_binary_artwork_batch(sender, header, images))
+    }
+
     fn on_set_console_log_filter(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_set_console_log_filter(sender, msg))
     }
@@ -117,6 +215,14 @@ impl Handler for TauriAgentHandler {
         Box::pin(self.handle_set_console_log_enabled(sender, msg))
     }
 
+    fn on_set_verbose(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_set_verbose(sender, msg))
+    }
+
+    fn on_subscribe_logs(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(self.handle_subscribe_logs(sender, msg))
+    }
+
     fn on_set_game_log_wrapper(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(self.handle_set_game_log_wrapper(sender, msg))
     }
@@ -1,12 +1,28 @@
-use capydeploy_agent_server::{BinaryArtworkHeader, Sender};
-use capydeploy_protocol::constants::MessageType;
+use std::io::Read;
+use std::sync::Arc;
+use std::time::Duration;
+
+use capydeploy_agent_server::{BinaryArtworkBatchHeader, BinaryArtworkHeader, Sender};
+use capydeploy_protocol::constants::{ARTWORK_PREVIEW_MAX_SIZE, MessageType};
 use capydeploy_protocol::envelope::Message;
 use capydeploy_protocol::messages;
+use capydeploy_protocol::types::ArtworkConfig;
 
+use crate::artwork_retry::ArtworkRetryQueue;
 use crate::handler::TauriAgentHandler;
 use crate::helpers::{ext_from_content_type, parse_artwork_type};
 use crate::state::PendingArtwork;
 
+/// Longest a single remote artwork download may take.
+const ARTWORK_DOWNLOAD_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// Replies to an artwork frame with its outcome.
+fn reply_artwork(sender: &Sender, id: &str, resp: messages::ArtworkImageResponse) {
+    if let Ok(reply) = Message::new(id, MessageType::ArtworkImageResponse, Some(&resp)) {
+        let _ = sender.send_msg(reply);
+    }
+}
+
 impl TauriAgentHandler {
     pub(crate) async fn handle_binary_artwork(
         &self,
@@ -15,12 +31,60 @@ impl TauriAgentHandler {
         data: Vec<u8>,
     ) {
         tracing::info!(
-            "Received artwork image: appID={}, type={}, contentType={}, size={}",
+            "Received artwork image: appID={}, type={}, contentType={}, size={}, offset={}, total={}",
             header.app_id,
             header.artwork_type,
             header.content_type,
-            data.len()
+            data.len(),
+            header.offset,
+            header.total_size
         );
+        // A completed chunked image is acknowledged with its full size.
+        let respond = |success: bool, error: String, received: u64| {
+            reply_artwork(
+                &sender,
+                &header.id,
+                messages::ArtworkImageResponse {
+                    success,
+                    artwork_type: header.artwork_type.clone(),
+                    error,
+                    received,
+                },
+            );
+        };
+
+        // Reassemble chunked images and verify their checksum.
+        let frame = capydeploy_transfer::ArtworkFrame {
+            offset: header.offset,
+            total_size: header.total_size,
+            checksum: &header.checksum,
+            data: &data,
+        };
+        let key = format!("{}/{}", header.app_id, header.artwork_type);
+        let assembled = self.state.artwork_chunks.lock().await.accept(&key, frame);
+        let data = match assembled {
+            Ok(capydeploy_transfer::Assembled::Complete(data)) => data,
+            Ok(capydeploy_transfer::Assembled::Incomplete { received }) => {
+                respond(true, String::new(), received);
+                return;
+            }
+            Err(e) => {
+                tracing::warn!("rejected artwork {key}: {e}");
+                respond(false, e.to_string(), 0);
+                return;
+            }
+        };
+
+        // Never save something that isn't the declared image format, such
+        // as a CDN error page labelled image/png.
+        let content_type = match capydeploy_transfer::validate_image(&header.content_type, &data) {
+            Ok(ct) => ct.to_string(),
+            Err(e) => {
+                tracing::warn!("rejected artwork {key}: {e}");
+                respond(false, e.to_string(), 0);
+                return;
+            }
+        };
 
         if header.app_id == 0 {
             // Store for later — applied during complete_upload with real AppID
@@ -30,88 +94,113 @@ impl TauriAgentHandler {
                 .await
                 .push(PendingArtwork {
                     artwork_type: header.artwork_type.clone(),
-                    content_type: header.content_type.clone(),
+                    content_type,
                     data,
                 });
             tracing::info!("Stored pending artwork: type={}", header.artwork_type);
-
-            let resp = messages::ArtworkImageResponse {
-                success: true,
-                artwork_type: header.artwork_type,
-                error: String::new(),
-            };
-            if let Ok(reply) = Message::new(
-                header.id.clone(),
-                MessageType::ArtworkImageResponse,
-                Some(&resp),
-            ) {
-                let _ = sender.send_msg(reply);
-            }
+            respond(true, String::new(), header.total_size);
             return;
         }
 
         // Apply artwork immediately for known AppID
-        let art_type = match parse_artwork_type(&header.artwork_type) {
-            Some(t) => t,
-            None => {
-                let resp = messages::ArtworkImageResponse {
-                    success: false,
-                    artwork_type: header.artwork_type,
-                    error: "unknown artwork type".into(),
-                };
-                if let Ok(reply) = Message::new(
-                    header.id.clone(),
-                    MessageType::ArtworkImageResponse,
-                    Some(&resp),
-                ) {
-                    let _ = sender.send_msg(reply);
-                }
-                return;
-            }
+        let Some(art_type) = parse_artwork_type(&header.artwork_type) else {
+            respond(false, "unknown artwork type".into(), 0);
+            return;
         };
 
-        let ext = ext_from_content_type(&header.content_type);
+        let ext = ext_from_content_type(&content_type);
 
         let result = (|| -> Result<(), String> {
-            let users = capydeploy_steam::get_users().map_err(|e| e.to_string())?;
-            let user = users
-                .first()
-                .ok_or_else(|| "no Steam users found".to_string())?;
             let sm = capydeploy_steam::ShortcutManager::new().map_err(|e| e.to_string())?;
-            sm.save_artwork(&user.id, header.app_id, art_type, &data, ext)
+            let users = sm
+                .artwork_users(
+                    header.app_id,
+                    capydeploy_steam::active_user_id().as_deref(),
+                    capydeploy_steam::ArtworkScope::Owner,
+                )
+                .map_err(|e| e.to_string())?;
+            sm.save_artwork(&users[0], header.app_id, art_type, &data, ext)
                 .map_err(|e| e.to_string())
         })();
 
         match result {
-            Ok(()) => {
-                let resp = messages::ArtworkImageResponse {
-                    success: true,
-                    artwork_type: header.artwork_type,
-                    error: String::new(),
-                };
-                if let Ok(reply) = Message::new(
-                    header.id.clone(),
-                    MessageType::ArtworkImageResponse,
-                    Some(&resp),
-                ) {
-                    let _ = sender.send_msg(reply);
-                }
-            }
+            Ok(()) => respond(true, String::new(), header.total_size),
             Err(e) => {
                 tracing::error!("failed to apply artwork image: {e}");
-                let resp = messages::ArtworkImageResponse {
-                    success: false,
-                    artwork_type: header.artwork_type,
-                    error: e,
-                };
-                if let Ok(reply) = Message::new(
-                    header.id.clone(),
-                    MessageType::ArtworkImageResponse,
-                    Some(&resp),
-                ) {
-                    let _ = sender.send_msg(reply);
+                respond(false, e, 0);
+            }
+        }
+    }
+
+    /// Handles an `apply_artwork_batch` frame: every image is validated and
+    /// either stored as pending (AppID 0) or applied right away through one
+    /// CEF client, and the Hub gets the outcome per artwork type.
+    pub(crate) async fn handle_binary_artwork_batch(
+        &self,
+        sender: Sender,
+        header: BinaryArtworkBatchHeader,
+        images: Vec<Vec<u8>>,
+    ) {
+        tracing::info!(
+            "Received artwork batch: appID={}, images={}",
+            header.app_id,
+            header.items.len()
+        );
+        let mut applied = Vec::new();
+        let mut failed = Vec::new();
+        let mut fail = |art_type: &str, error: String| {
+            tracing::warn!("rejected artwork {}/{art_type}: {error}", header.app_id);
+            failed.push(messages::ArtworkFailed {
+                art_type: art_type.to_string(),
+                error,
+            });
+        };
+
+        let mut accepted = Vec::with_capacity(images.len());
+        for (item, data) in header.items.iter().zip(images) {
+            if !item.checksum.is_empty()
+                && !capydeploy_transfer::checksum_bytes(&data).eq_ignore_ascii_case(&item.checksum)
+            {
+                fail(&item.artwork_type, "checksum mismatch".into());
+                continue;
+            }
+            match capydeploy_transfer::validate_image(&item.content_type, &data) {
+                Ok(ct) => accepted.push(PendingArtwork {
+                    artwork_type: item.artwork_type.clone(),
+                    content_type: ct.to_string(),
+                    data,
+                }),
+                Err(e) => fail(&item.artwork_type, e.to_string()),
+            }
+        }
+
+        if header.app_id == 0 {
+            // Stored for complete_upload, like single images.
+            applied = accepted.iter().map(|pa| pa.artwork_type.clone()).collect();
+            self.state.pending_artwork.lock().await.extend(accepted);
+        } else {
+            let mut cef = open_cef_session().await;
+            for pa in &accepted {
+                match apply_artwork_item(
+                    cef.as_mut(),
+                    header.app_id,
+                    pa,
+                    capydeploy_steam::ArtworkScope::Owner,
+                )
+                .await
+                {
+                    Ok(()) => applied.push(pa.artwork_type.clone()),
+                    Err(e) => fail(&pa.artwork_type, e),
                 }
             }
+            if let Some(cef) = cef {
+                cef.close().await;
+            }
+        }
+
+        let resp = messages::ArtworkResponse { applied, failed };
+        if let Ok(reply) = Message::new(&header.id, MessageType::ArtworkResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
         }
     }
 
@@ -120,67 +209,429 @@ impl TauriAgentHandler {
     ///
     /// Spawns a background task so the handler doesn't block waiting for
     /// multiple sequential CEF calls.
-    pub(crate) fn apply_pending_artwork(&self, app_id: u32, artwork_items: Vec<PendingArtwork>) {
-        use base64::Engine;
+    ///
+    /// The filesystem fallback writes to the account the shortcut belongs
+    /// to, or to every account with the shortcut per `scope`. Images that
+    /// neither took are queued for [`retry_queued_artwork`].
+    pub(crate) fn apply_pending_artwork(
+        &self,
+        app_id: u32,
+        artwork_items: Vec<PendingArtwork>,
+        scope: capydeploy_steam::ArtworkScope,
+    ) {
+        let queue = self.state.artwork_retry.clone();
+        tokio::spawn(apply_or_queue(queue, app_id, artwork_items, scope));
+    }
 
+    /// Downloads the URLs of a new shortcut's [`ArtworkConfig`] in the
+    /// background and applies them like pending artwork. The Hub leaves
+    /// SteamGridDB images to the agent this way instead of relaying them.
+    pub(crate) fn apply_remote_artwork(
+        &self,
+        app_id: u32,
+        cfg: ArtworkConfig,
+        scope: capydeploy_steam::ArtworkScope,
+    ) {
+        let queue = self.state.artwork_retry.clone();
         tokio::spawn(async move {
-            let cef = capydeploy_steam::CefClient::new();
+            let (images, failed) = download_remote_artwork(&cfg).await;
+            for f in failed {
+                tracing::warn!(
+                    "failed to download {} artwork (appID {app_id}): {}",
+                    f.art_type,
+                    f.error
+                );
+            }
+            apply_or_queue(queue, app_id, images, scope).await;
+        });
+    }
 
-            for pa in &artwork_items {
-                let asset_type = match capydeploy_steam::artwork_type_to_cef_asset(&pa.artwork_type)
-                {
-                    Some(t) => t,
-                    None => {
-                        tracing::warn!("unknown artwork type: {}", pa.artwork_type);
-                        continue;
-                    }
-                };
+    /// Handles `apply_artwork`: downloads the http(s) URLs of the request
+    /// and applies them to an existing shortcut, answering with the outcome
+    /// per artwork type.
+    pub(crate) async fn handle_apply_artwork(&self, sender: Sender, msg: Message) {
+        let req: messages::ApplyArtworkRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+        let Some(cfg) = req.artwork else {
+            let _ = sender.send_error(&msg, 400, "no artwork given");
+            return;
+        };
 
-                let b64 = base64::engine::general_purpose::STANDARD.encode(&pa.data);
+        let (images, mut failed) = download_remote_artwork(&cfg).await;
+        let mut applied = Vec::new();
+        let mut cef = open_cef_session().await;
+        for pa in &images {
+            match apply_artwork_item(
+                cef.as_mut(),
+                req.app_id,
+                pa,
+                capydeploy_steam::ArtworkScope::Owner,
+            )
+            .await
+            {
+                Ok(()) => applied.push(pa.artwork_type.clone()),
+                Err(error) => failed.push(messages::ArtworkFailed {
+                    art_type: pa.artwork_type.clone(),
+                    error,
+                }),
+            }
+        }
+        if let Some(cef) = cef {
+            cef.close().await;
+        }
 
-                match cef.set_custom_artwork(app_id, &b64, asset_type).await {
-                    Ok(()) => {
-                        tracing::info!(
-                            "Applied artwork via CEF: appID={}, type={}",
-                            app_id,
-                            pa.artwork_type
-                        );
-                        continue;
-                    }
+        let resp = messages::ArtworkResponse { applied, failed };
+        if let Ok(reply) = msg.reply(MessageType::ArtworkResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    pub(crate) async fn handle_get_artwork(&self, sender: Sender, msg: Message) {
+        let req: messages::GetArtworkRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        match tokio::task::spawn_blocking(move || read_applied_artwork(&req)).await {
+            Ok(Ok(resp)) => {
+                if let Ok(reply) = msg.reply(MessageType::AppliedArtworkResponse, Some(&resp)) {
+                    let _ = sender.send_msg(reply);
+                }
+            }
+            Ok(Err(e)) => {
+                tracing::warn!("failed to read artwork: {e}");
+                let _ = sender.send_error(&msg, 500, &e);
+            }
+            Err(_) => {
+                let _ = sender.send_error(&msg, 500, "internal error");
+            }
+        }
+    }
+}
+
+/// Applies queued artwork if CEF answers; does nothing (and uses up no
+/// attempts) while it doesn't, or while another pass is running.
+pub(crate) async fn retry_queued_artwork(queue: Arc<ArtworkRetryQueue>) {
+    if queue.is_empty() || !queue.begin_pass() {
+        return;
+    }
+    if capydeploy_steam::Controller::new().is_cef_available().await
+        && let Ok(mut cef) = capydeploy_steam::CefClient::new().session().await
+    {
+        let q = queue.clone();
+        let batches = tokio::task::spawn_blocking(move || q.batches())
+            .await
+            .unwrap_or_default();
+        for batch in batches {
+            let mut failed = Vec::new();
+            for pa in &batch.items {
+                match apply_artwork_item(Some(&mut cef), batch.app_id, pa, batch.scope).await {
+                    Ok(()) => tracing::info!(
+                        "applied queued {} artwork (appID {})",
+                        pa.artwork_type,
+                        batch.app_id
+                    ),
                     Err(e) => {
                         tracing::warn!(
-                            "CEF artwork failed for {} (appID {}), falling back to filesystem: {e}",
+                            "retry of {} artwork (appID {}) failed: {e}",
                             pa.artwork_type,
-                            app_id
+                            batch.app_id
                         );
+                        failed.push(pa.artwork_type.clone());
                     }
                 }
+            }
+            let q = queue.clone();
+            let app_id = batch.app_id;
+            if let Ok(Err(e)) = tokio::task::spawn_blocking(move || q.record(app_id, &failed)).await
+            {
+                tracing::warn!("failed to update artwork retry queue: {e}");
+            }
+        }
+        cef.close().await;
+    }
+    queue.end_pass();
+}
 
-                // Filesystem fallback (requires Steam restart to show).
-                let sm = match capydeploy_steam::ShortcutManager::new() {
-                    Ok(sm) => sm,
-                    Err(e) => {
-                        tracing::warn!("failed to init ShortcutManager for artwork fallback: {e}");
-                        continue;
-                    }
-                };
-                let users = capydeploy_steam::get_users().unwrap_or_default();
-                if users.is_empty() {
-                    tracing::warn!("no Steam users found for artwork fallback");
-                    continue;
-                }
-                let art_type = match parse_artwork_type(&pa.artwork_type) {
-                    Some(t) => t,
-                    None => continue,
-                };
-                let ext = ext_from_content_type(&pa.content_type);
-                if let Err(e) = sm.save_artwork(&users[0].id, app_id, art_type, &pa.data, ext) {
-                    tracing::warn!(
-                        "filesystem artwork fallback failed for {}: {e}",
-                        pa.artwork_type
-                    );
+/// Applies `items` through one CEF session and queues the ones that didn't
+/// take for [`retry_queued_artwork`].
+async fn apply_or_queue(
+    queue: Arc<ArtworkRetryQueue>,
+    app_id: u32,
+    items: Vec<PendingArtwork>,
+    scope: capydeploy_steam::ArtworkScope,
+) {
+    if items.is_empty() {
+        return;
+    }
+    let mut cef = open_cef_session().await;
+    let mut failed = Vec::new();
+    for pa in items {
+        if let Err(e) = apply_artwork_item(cef.as_mut(), app_id, &pa, scope).await {
+            tracing::warn!(
+                "failed to apply {} artwork (appID {app_id}), queued for retry: {e}",
+                pa.artwork_type
+            );
+            failed.push(pa);
+        }
+    }
+    if let Some(cef) = cef {
+        cef.close().await;
+    }
+    if failed.is_empty() {
+        return;
+    }
+    match tokio::task::spawn_blocking(move || queue.push(app_id, scope, &failed)).await {
+        Ok(Err(e)) => tracing::warn!("failed to queue artwork for retry: {e}"),
+        Err(e) => tracing::warn!("failed to queue artwork for retry: {e}"),
+        Ok(Ok(())) => {}
+    }
+}
+
+/// The artwork types set in `cfg` with their sources, in protocol names.
+fn artwork_sources(cfg: &ArtworkConfig) -> impl Iterator<Item = (&'static str, &str)> {
+    [
+        ("grid", cfg.grid.as_str()),
+        ("banner", cfg.banner.as_str()),
+        ("hero", cfg.hero.as_str()),
+        ("logo", cfg.logo.as_str()),
+        ("icon", cfg.icon.as_str()),
+    ]
+    .into_iter()
+    .filter(|(_, src)| !src.is_empty())
+}
+
+/// Downloads every artwork of `cfg`. Only http(s) URLs are fetched; other
+/// sources, failed downloads and anything that isn't an image end up in
+/// the failures.
+async fn download_remote_artwork(
+    cfg: &ArtworkConfig,
+) -> (Vec<PendingArtwork>, Vec<messages::ArtworkFailed>) {
+    let mut images = Vec::new();
+    let mut failed = Vec::new();
+    let http = reqwest::Client::builder()
+        .timeout(ARTWORK_DOWNLOAD_TIMEOUT)
+        .build();
+    for (art_type, url) in artwork_sources(cfg) {
+        let result = match &http {
+            Ok(http) => download_artwork(http, art_type, url).await,
+            Err(e) => Err(e.to_string()),
+        };
+        match result {
+            Ok(pa) => images.push(pa),
+            Err(error) => failed.push(messages::ArtworkFailed {
+                art_type: art_type.to_string(),
+                error,
+            }),
+        }
+    }
+    (images, failed)
+}
+
+async fn download_artwork(
+    http: &reqwest::Client,
+    art_type: &str,
+    url: &str,
+) -> Result<PendingArtwork, String> {
+    if !url.starts_with("http://") && !url.starts_with("https://") {
+        return Err(format!("not an http(s) URL: {url}"));
+    }
+    let too_large = || {
+        format!(
+            "image exceeds the {}-byte limit",
+            capydeploy_transfer::MAX_ARTWORK_BYTES
+        )
+    };
+
+    let resp = http.get(url).send().await.map_err(|e| e.to_string())?;
+    let status = resp.status();
+    if !status.is_success() {
+        return Err(format!("download failed: HTTP {}", status.as_u16()));
+    }
+    if resp
+        .content_length()
+        .is_some_and(|len| len > capydeploy_transfer::MAX_ARTWORK_BYTES)
+    {
+        return Err(too_large());
+    }
+    let data = resp.bytes().await.map_err(|e| e.to_string())?.to_vec();
+    if data.len() as u64 > capydeploy_transfer::MAX_ARTWORK_BYTES {
+        return Err(too_large());
+    }
+
+    // CDNs label images loosely, so go by the bytes rather than the header.
+    let content_type = capydeploy_transfer::validate_image("", &data).map_err(|e| e.to_string())?;
+    tracing::info!(
+        "Downloaded {art_type} artwork: {url} ({content_type}, {} bytes)",
+        data.len()
+    );
+    Ok(PendingArtwork {
+        artwork_type: art_type.to_string(),
+        content_type: content_type.to_string(),
+        data,
+    })
+}
+
+/// Opens one CEF session for a run of artwork; `None` when Steam can't be
+/// reached, in which case only the filesystem is written.
+async fn open_cef_session() -> Option<capydeploy_steam::CefSession> {
+    match capydeploy_steam::CefClient::new().session().await {
+        Ok(session) => Some(session),
+        Err(e) => {
+            tracing::warn!("CEF unavailable, applying artwork to the filesystem only: {e}");
+            None
+        }
+    }
+}
+
+/// Applies one image via the CEF session, falling back to (or, for a wider
+/// `scope`, also writing) the grid folder of each matching Steam account.
+///
+/// Succeeds when CEF or at least one account took the image.
+async fn apply_artwork_item(
+    cef: Option<&mut capydeploy_steam::CefSession>,
+    app_id: u32,
+    pa: &PendingArtwork,
+    scope: capydeploy_steam::ArtworkScope,
+) -> Result<(), String> {
+    use base64::Engine;
+
+    let Some(asset_type) = capydeploy_steam::artwork_type_to_cef_asset(&pa.artwork_type) else {
+        return Err(format!("unknown artwork type: {}", pa.artwork_type));
+    };
+
+    let b64 = base64::engine::general_purpose::STANDARD.encode(&pa.data);
+
+    let cef_result = match cef {
+        Some(cef) => cef.set_custom_artwork(app_id, &b64, asset_type).await,
+        None => Err(capydeploy_steam::SteamError::Cef("no CEF session".into())),
+    };
+    let applied_via_cef = match cef_result {
+        Ok(()) => {
+            tracing::info!(
+                "Applied artwork via CEF: appID={}, type={}",
+                app_id,
+                pa.artwork_type
+            );
+            true
+        }
+        Err(e) => {
+            tracing::warn!(
+                "CEF artwork failed for {} (appID {}), falling back to filesystem: {e}",
+                pa.artwork_type,
+                app_id
+            );
+            false
+        }
+    };
+    if applied_via_cef && scope == capydeploy_steam::ArtworkScope::Owner {
+        return Ok(());
+    }
+
+    // Filesystem fallback (requires Steam restart to show).
+    let fallback = |e: String| if applied_via_cef { Ok(()) } else { Err(e) };
+    let sm = match capydeploy_steam::ShortcutManager::new() {
+        Ok(sm) => sm,
+        Err(e) => {
+            tracing::warn!("failed to init ShortcutManager for artwork fallback: {e}");
+            return fallback(e.to_string());
+        }
+    };
+    let users = match sm.artwork_users(app_id, capydeploy_steam::active_user_id().as_deref(), scope)
+    {
+        Ok(users) => users,
+        Err(e) => {
+            tracing::warn!("no Steam users found for artwork fallback: {e}");
+            return fallback(e.to_string());
+        }
+    };
+    let Some(art_type) = parse_artwork_type(&pa.artwork_type) else {
+        return fallback(format!("unknown artwork type: {}", pa.artwork_type));
+    };
+    let ext = ext_from_content_type(&pa.content_type);
+    // CEF only reaches the signed-in account, which is the owner.
+    let skip = usize::from(applied_via_cef);
+    let mut result = fallback("no Steam account to write artwork for".into());
+    for user_id in users.iter().skip(skip) {
+        match sm.save_artwork(user_id, app_id, art_type, &pa.data, ext) {
+            Ok(()) => result = Ok(()),
+            Err(e) => {
+                tracing::warn!(
+                    "filesystem artwork fallback failed for {} (user {user_id}): {e}",
+                    pa.artwork_type
+                );
+                if result.is_err() {
+                    result = Err(e.to_string());
                 }
             }
+        }
+    }
+    result
+}
+
+/// Reads the artwork files on `req.app_id` from the grid directory.
+fn read_applied_artwork(
+    req: &messages::GetArtworkRequest,
+) -> Result<messages::AppliedArtworkResponse, String> {
+    let sm = capydeploy_steam::ShortcutManager::new().map_err(|e| e.to_string())?;
+    let user_id = if req.user_id.is_empty() {
+        let users = sm
+            .artwork_users(
+                req.app_id,
+                capydeploy_steam::active_user_id().as_deref(),
+                capydeploy_steam::ArtworkScope::Owner,
+            )
+            .map_err(|e| e.to_string())?;
+        users[0].clone()
+    } else {
+        req.user_id.clone()
+    };
+    let mut found = sm
+        .find_existing_artwork(&user_id, req.app_id)
+        .map_err(|e| e.to_string())?;
+
+    let mut resp = messages::AppliedArtworkResponse {
+        app_id: req.app_id,
+        images: Vec::new(),
+        missing: Vec::new(),
+    };
+    for art_type in capydeploy_steam::ArtworkType::all() {
+        let Some(path) = found.remove(art_type) else {
+            resp.missing.push(art_type.to_string());
+            continue;
+        };
+        let size = std::fs::metadata(&path)
+            .map_err(|e| format!("{path}: {e}"))?
+            .len();
+        let too_large = size > ARTWORK_PREVIEW_MAX_SIZE;
+        // Oversized files are only sniffed for their type.
+        let data = if too_large {
+            let mut head = [0u8; 16];
+            let n = std::fs::File::open(&path)
+                .and_then(|mut f| f.read(&mut head))
+                .map_err(|e| format!("{path}: {e}"))?;
+            head[..n].to_vec()
+        } else {
+            std::fs::read(&path).map_err(|e| format!("{path}: {e}"))?
+        };
+        let content_type = capydeploy_transfer::detect_image_type(&data)
+            .unwrap_or("application/octet-stream")
+            .to_string();
+        resp.images.push(messages::AppliedArtwork {
+            artwork_type: art_type.to_string(),
+            content_type,
+            size,
+            data: if too_large { Vec::new() } else { data },
+            too_large,
         });
     }
+    Ok(resp)
 }
@@ -6,6 +6,7 @@ impl TauriAgentHandler {
 
         // Clear WS sender
         *self.state.hub_sender.lock().unwrap() = None;
+        self.state.log_stream.unsubscribe();
 
         // Stop collectors
         self.state.telemetry_collector.stop().await;
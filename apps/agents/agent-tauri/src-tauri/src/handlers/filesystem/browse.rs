@@ -1,6 +1,6 @@
-//! Browse handlers: fs_list, fs_mkdir, fs_delete, fs_rename.
+//! Browse handlers: fs_list, fs_mkdir, fs_delete, fs_rename, browse_directory.
 
-use std::path::Path;
+use std::path::{Path, PathBuf};
 use std::time::UNIX_EPOCH;
 
 use capydeploy_agent_server::Sender;
@@ -8,9 +8,52 @@ use capydeploy_protocol::constants::{self, MessageType};
 use capydeploy_protocol::envelope::Message;
 use capydeploy_protocol::messages;
 
+use super::resolve_home;
 use crate::handler::TauriAgentHandler;
+use crate::helpers::expand_path;
 
 impl TauriAgentHandler {
+    /// Directories the Hub may choose an install target under.
+    pub(crate) async fn install_scope(&self) -> capydeploy_file_ops::DirectoryScope {
+        let configured = {
+            let config = self.state.config.lock().await;
+            PathBuf::from(expand_path(&config.install_path))
+        };
+        capydeploy_file_ops::DirectoryScope::new(capydeploy_file_ops::install_roots(Some(
+            &configured,
+        )))
+    }
+
+    pub(crate) async fn handle_browse_directory(&self, sender: Sender, msg: Message) {
+        let req: messages::BrowseDirectoryRequest = match msg.parse_payload() {
+            Ok(r) => r.unwrap_or_default(),
+            Err(_) => {
+                let _ =
+                    sender.send_error(&msg, constants::WS_ERR_CODE_BAD_REQUEST, "invalid payload");
+                return;
+            }
+        };
+
+        let scope = self.install_scope().await;
+        let result = tokio::task::spawn_blocking(move || browse_install_target(&scope, &req)).await;
+
+        match result {
+            Ok(Ok(resp)) => {
+                if let Ok(reply) = msg.reply(MessageType::BrowseDirectoryResponse, Some(&resp)) {
+                    let _ = sender.send_msg(reply);
+                }
+            }
+            Ok(Err(e)) => {
+                tracing::warn!("browse_directory denied: {e}");
+                let _ = sender.send_error(&msg, constants::WS_ERR_CODE_BAD_REQUEST, &e);
+            }
+            Err(e) => {
+                tracing::error!("browse_directory task panicked: {e}");
+                let _ = sender.send_error(&msg, constants::WS_ERR_CODE_INTERNAL, "internal error");
+            }
+        }
+    }
+
     pub(crate) async fn handle_fs_list(&self, sender: Sender, msg: Message) {
         let req: messages::FsListRequest = match msg.parse_payload() {
             Ok(Some(r)) => r,
@@ -222,3 +265,56 @@ fn read_dir_entries(
 
     Ok((entries, truncated))
 }
+
+/// Lists (optionally creating) an install target inside `scope`.
+fn browse_install_target(
+    scope: &capydeploy_file_ops::DirectoryScope,
+    req: &messages::BrowseDirectoryRequest,
+) -> Result<messages::BrowseDirectoryResponse, String> {
+    let roots: Vec<String> = scope
+        .roots()
+        .iter()
+        .map(|r| r.to_string_lossy().into_owned())
+        .collect();
+
+    if req.path.is_empty() {
+        let entries = roots
+            .iter()
+            .map(|r| messages::DirectoryEntry {
+                name: r.clone(),
+                path: r.clone(),
+            })
+            .collect();
+        return Ok(messages::BrowseDirectoryResponse {
+            path: String::new(),
+            parent: String::new(),
+            roots,
+            entries,
+        });
+    }
+
+    let path = resolve_home(&req.path).ok_or("cannot resolve home directory")?;
+    let dir = if req.create {
+        scope.create(&path)?
+    } else {
+        scope.resolve(&path)?
+    };
+    let entries = capydeploy_file_ops::list_directory(&dir)?
+        .into_iter()
+        .filter(|e| e.is_dir)
+        .map(|e| messages::DirectoryEntry {
+            name: e.name,
+            path: e.path,
+        })
+        .collect();
+
+    Ok(messages::BrowseDirectoryResponse {
+        path: dir.to_string_lossy().into_owned(),
+        parent: scope
+            .parent_of(&dir)
+            .map(|p| p.to_string_lossy().into_owned())
+            .unwrap_or_default(),
+        roots,
+        entries,
+    })
+}
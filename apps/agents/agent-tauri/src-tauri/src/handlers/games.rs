@@ -1,12 +1,22 @@
 use tauri::Emitter;
 
 use capydeploy_agent_server::Sender;
-use capydeploy_protocol::constants::MessageType;
+use capydeploy_protocol::constants::{
+    MessageType, WS_ERR_CODE_CONFLICT, WS_ERR_CODE_INTERNAL, WS_ERR_CODE_NOT_FOUND,
+    WS_ERR_CODE_UNAVAILABLE,
+};
 use capydeploy_protocol::envelope::Message;
 use capydeploy_protocol::messages;
 
 use crate::handler::TauriAgentHandler;
 use crate::helpers::{delete_game_directory, expand_path};
+use crate::state::TrackedShortcut;
+
+/// Longest Steam may take to remove a shortcut.
+const REMOVE_SHORTCUT_TIMEOUT: std::time::Duration = std::time::Duration::from_secs(15);
+
+/// Longest Steam may take to accept a launch.
+const LAUNCH_TIMEOUT: std::time::Duration = std::time::Duration::from_secs(15);
 
 impl TauriAgentHandler {
     pub(crate) async fn handle_get_steam_users(&self, sender: Sender, msg: Message) {
@@ -33,6 +43,46 @@ impl TauriAgentHandler {
         }
     }
 
+    pub(crate) async fn handle_get_steam_libraries(&self, sender: Sender, msg: Message) {
+        let folders = capydeploy_steam::Paths::new().and_then(|paths| {
+            capydeploy_steam::load_library_folders(&paths.library_folders_vdf_path())
+        });
+        match folders {
+            Ok(folders) => {
+                let libraries = folders
+                    .iter()
+                    .map(|f| messages::SteamLibrary {
+                        path: f.path.to_string_lossy().into_owned(),
+                        label: f.label.clone(),
+                        install_path: f.install_dir().to_string_lossy().into_owned(),
+                    })
+                    .collect();
+                let resp = messages::SteamLibrariesResponse { libraries };
+                if let Ok(reply) = msg.reply(MessageType::SteamLibrariesResponse, Some(&resp)) {
+                    let _ = sender.send_msg(reply);
+                }
+            }
+            Err(e) => {
+                tracing::error!("failed to list Steam libraries: {e}");
+                let _ = sender.send_error(&msg, 500, &e.to_string());
+            }
+        }
+    }
+
+    pub(crate) async fn handle_get_steam_status(&self, sender: Sender, msg: Message) {
+        let ctrl = capydeploy_steam::Controller::new();
+        let resp = messages::SteamStatusResponse {
+            running: ctrl.is_running().await,
+            gaming_mode: ctrl.is_gaming_mode(),
+            path: capydeploy_steam::Paths::new()
+                .map(|paths| paths.base_dir().to_string_lossy().into_owned())
+                .unwrap_or_default(),
+        };
+        if let Ok(reply) = msg.reply(MessageType::SteamStatusResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
     pub(crate) async fn handle_delete_game(&self, sender: Sender, msg: Message) {
         let req: messages::DeleteGameRequest = match msg.parse_payload() {
             Ok(Some(r)) => r,
@@ -42,20 +92,6 @@ impl TauriAgentHandler {
             }
         };
 
-        // Get first Steam user (same as Go agent: automatic, Hub only sends appId).
-        let users = match capydeploy_steam::get_users() {
-            Ok(u) if !u.is_empty() => u,
-            Ok(_) => {
-                let _ = sender.send_error(&msg, 500, "no Steam users found");
-                return;
-            }
-            Err(e) => {
-                let _ = sender.send_error(&msg, 500, &format!("failed to get Steam users: {e}"));
-                return;
-            }
-        };
-        let user_id = &users[0].id;
-
         // Load shortcuts from VDF to find game name and directory.
         let sm = match capydeploy_steam::ShortcutManager::new() {
             Ok(sm) => sm,
@@ -66,31 +102,20 @@ impl TauriAgentHandler {
             }
         };
 
+        // The Hub only sends the AppID; the account listing it owns the
+        // shortcut and its artwork.
+        let Some(user_id) = self.shortcut_owner(&sender, &msg, &sm, req.app_id) else {
+            return;
+        };
+        let user_id = &user_id;
+
         let vdf_path = sm.shortcuts_path(user_id);
         let shortcuts = capydeploy_steam::load_shortcuts_vdf(std::path::Path::new(&vdf_path))
             .unwrap_or_default();
 
-        let shortcut = shortcuts.iter().find(|sc| sc.app_id == req.app_id);
-        let (game_name, game_dir) = match shortcut {
-            Some(sc) => {
-                // VDF may store paths with surrounding quotes.
-                let dir = sc.start_dir.trim_matches('"').to_string();
-                (sc.name.clone(), dir)
-            }
-            None => {
-                // Shortcut may exist only in tracked list (CEF not flushed to VDF yet).
-                let tracked = self.state.tracked_shortcuts.lock().await;
-                match tracked.iter().find(|ts| ts.app_id == req.app_id) {
-                    Some(ts) => {
-                        let dir = ts.start_dir.trim_matches('"').to_string();
-                        (ts.name.clone(), dir)
-                    }
-                    None => {
-                        let _ = sender.send_error(&msg, 404, "game not found");
-                        return;
-                    }
-                }
-            }
+        let Some((game_name, game_dir)) = self.locate_game(&shortcuts, req.app_id).await else {
+            let _ = sender.send_error(&msg, 404, "game not found");
+            return;
         };
 
         // Notify Hub + local UI about delete start.
@@ -111,8 +136,7 @@ impl TauriAgentHandler {
             20.0,
             "Eliminando shortcut...",
         );
-        let cef_timeout = std::time::Duration::from_secs(15);
-        let cef_result = tokio::time::timeout(cef_timeout, async {
+        let cef_result = tokio::time::timeout(REMOVE_SHORTCUT_TIMEOUT, async {
             let cef_client = capydeploy_steam::CefClient::new();
             cef_client.remove_shortcut(req.app_id).await
         })
@@ -129,17 +153,6 @@ impl TauriAgentHandler {
             }
         }
 
-        // Remove from tracked shortcuts list and mark as deleted.
-        {
-            let mut tracked = self.state.tracked_shortcuts.lock().await;
-            tracked.retain(|s| s.app_id != req.app_id);
-        }
-        {
-            let mut deleted = self.state.deleted_app_ids.lock().await;
-            deleted.insert(req.app_id);
-        }
-
-        // Delete game directory (best-effort with safety checks).
         self.emit_operation(
             &sender,
             "delete",
@@ -148,25 +161,8 @@ impl TauriAgentHandler {
             50.0,
             "Eliminando archivos...",
         );
-        if !game_dir.is_empty() {
-            let expanded = expand_path(&game_dir);
-            if let Err(e) = delete_game_directory(&expanded) {
-                tracing::warn!("failed to delete game directory: {e}");
-            }
-        }
-
-        // Delete artwork files from Steam grid folder (best-effort).
-        self.emit_operation(
-            &sender,
-            "delete",
-            "progress",
-            &game_name,
-            80.0,
-            "Eliminando artwork...",
-        );
-        if let Err(e) = sm.delete_artwork(user_id, req.app_id) {
-            tracing::warn!("failed to delete artwork: {e}");
-        }
+        self.remove_game_files(&sm, user_id, req.app_id, &game_dir)
+            .await;
 
         tracing::info!(
             "Deleted game '{}' (AppID: {}) for user {}",
@@ -198,9 +194,409 @@ impl TauriAgentHandler {
         }
     }
 
+    /// Deletes every game of the request under one ShortcutManager and one
+    /// CEF connection, reporting a single progress sequence for the batch.
+    /// Steam is restarted at most once, at the end, when the Hub asks for it.
+    pub(crate) async fn handle_delete_games_batch(&self, sender: Sender, msg: Message) {
+        let req: messages::DeleteGamesBatchRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        match capydeploy_steam::get_users() {
+            Ok(u) if !u.is_empty() => {}
+            Ok(_) => {
+                let _ = sender.send_error(&msg, 500, "no Steam users found");
+                return;
+            }
+            Err(e) => {
+                let _ = sender.send_error(&msg, 500, &format!("failed to get Steam users: {e}"));
+                return;
+            }
+        }
+
+        let sm = match capydeploy_steam::ShortcutManager::new() {
+            Ok(sm) => sm,
+            Err(e) => {
+                let _ =
+                    sender.send_error(&msg, 500, &format!("failed to init ShortcutManager: {e}"));
+                return;
+            }
+        };
+        // Each game is looked up in the shortcuts of the account owning it.
+        let active_user = capydeploy_steam::active_user_id();
+        let mut shortcuts_by_user = std::collections::HashMap::new();
+
+        let total = req.app_ids.len();
+        let label = format!("{total} juegos");
+        self.emit_operation(&sender, "delete", "start", &label, 0.0, "Eliminando...");
+
+        let ctrl = capydeploy_steam::Controller::new();
+        if let Err(e) = ctrl.ensure_cef_debug_file() {
+            tracing::warn!("failed to ensure CEF debug file: {e}");
+        }
+        // Without Steam the files are still removed; the shortcuts go away
+        // with the restart, if one was requested.
+        let mut session = match tokio::time::timeout(
+            REMOVE_SHORTCUT_TIMEOUT,
+            capydeploy_steam::CefClient::new().session(),
+        )
+        .await
+        {
+            Ok(Ok(s)) => Some(s),
+            Ok(Err(e)) => {
+                tracing::warn!("CEF unavailable for batch delete: {e} — continuing cleanup");
+                None
+            }
+            Err(_) => {
+                tracing::warn!("CEF connection timed out — continuing cleanup");
+                None
+            }
+        };
+
+        let mut results = Vec::with_capacity(total);
+        for (i, &app_id) in req.app_ids.iter().enumerate() {
+            let user_id = match sm.shortcut_owner(app_id, active_user.as_deref()) {
+                Ok(id) => id,
+                Err(e) => {
+                    results.push(messages::GameDeleteResult {
+                        app_id,
+                        success: false,
+                        game_name: String::new(),
+                        error: e.to_string(),
+                    });
+                    continue;
+                }
+            };
+            let shortcuts = shortcuts_by_user.entry(user_id.clone()).or_insert_with(|| {
+                let vdf_path = sm.shortcuts_path(&user_id);
+                capydeploy_steam::load_shortcuts_vdf(std::path::Path::new(&vdf_path))
+                    .unwrap_or_default()
+            });
+            let Some((game_name, game_dir)) = self.locate_game(shortcuts, app_id).await else {
+                results.push(messages::GameDeleteResult {
+                    app_id,
+                    success: false,
+                    game_name: String::new(),
+                    error: "game not found".into(),
+                });
+                continue;
+            };
+            self.emit_operation(
+                &sender,
+                "delete",
+                "progress",
+                &label,
+                (i * 100 / total) as f64,
+                &format!("Eliminando {game_name}..."),
+            );
+
+            if let Some(session) = session.as_mut() {
+                match tokio::time::timeout(REMOVE_SHORTCUT_TIMEOUT, session.remove_shortcut(app_id))
+                    .await
+                {
+                    Ok(Ok(())) => tracing::info!("removed shortcut via CEF for AppID {app_id}"),
+                    Ok(Err(e)) => tracing::warn!("CEF remove_shortcut failed for {app_id}: {e}"),
+                    Err(_) => tracing::warn!("CEF remove_shortcut timed out for {app_id}"),
+                }
+            }
+            self.remove_game_files(&sm, &user_id, app_id, &game_dir)
+                .await;
+            tracing::info!("Deleted game '{game_name}' (AppID: {app_id}) for user {user_id}");
+
+            results.push(messages::GameDeleteResult {
+                app_id,
+                success: true,
+                game_name,
+                error: String::new(),
+            });
+        }
+        if let Some(session) = session {
+            session.close().await;
+        }
+
+        let deleted = results.iter().any(|r| r.success);
+        let steam_restarted = if req.restart_steam && deleted {
+            self.emit_operation(
+                &sender,
+                "delete",
+                "progress",
+                &label,
+                95.0,
+                "Reiniciando Steam...",
+            );
+            let restart = ctrl.restart().await;
+            if !restart.success {
+                tracing::warn!(
+                    "failed to restart Steam after batch delete: {}",
+                    restart.message
+                );
+            }
+            restart.success
+        } else {
+            false
+        };
+
+        self.emit_operation(&sender, "delete", "complete", &label, 100.0, "Eliminado");
+        if deleted {
+            let _ = self.app_handle.emit("shortcuts:changed", &());
+        }
+
+        let resp = messages::DeleteGamesBatchResponse {
+            results,
+            steam_restarted,
+        };
+        if let Ok(reply) = msg.reply(MessageType::DeleteGamesBatchResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    /// Name and install dir of the game behind `app_id`, from shortcuts.vdf
+    /// or, when Steam hasn't flushed it yet, the tracked shortcuts.
+    async fn locate_game(
+        &self,
+        shortcuts: &[capydeploy_protocol::types::ShortcutInfo],
+        app_id: u32,
+    ) -> Option<(String, String)> {
+        // VDF may store paths with surrounding quotes.
+        if let Some(sc) = shortcuts.iter().find(|sc| sc.app_id == app_id) {
+            return Some((sc.name.clone(), sc.start_dir.trim_matches('"').to_string()));
+        }
+        let tracked = self.state.tracked_shortcuts.lock().await;
+        tracked
+            .iter()
+            .find(|ts| ts.app_id == app_id)
+            .map(|ts| (ts.name.clone(), ts.start_dir.trim_matches('"').to_string()))
+    }
+
+    /// Forgets the shortcut and deletes the game's files, artwork and boot
+    /// video. Everything is best-effort; failures are only logged.
+    async fn remove_game_files(
+        &self,
+        sm: &capydeploy_steam::ShortcutManager,
+        user_id: &str,
+        app_id: u32,
+        game_dir: &str,
+    ) {
+        self.state
+            .tracked_shortcuts
+            .lock()
+            .await
+            .retain(|s| s.app_id != app_id);
+        self.state.deleted_app_ids.lock().await.insert(app_id);
+
+        if !game_dir.is_empty() {
+            let expanded = expand_path(game_dir);
+            if let Err(e) = delete_game_directory(&expanded) {
+                tracing::warn!("failed to delete game directory: {e}");
+            }
+        }
+        if let Err(e) = sm.delete_artwork(user_id, app_id) {
+            tracing::warn!("failed to delete artwork: {e}");
+        }
+        if let Err(e) = sm.delete_boot_video(app_id) {
+            tracing::warn!("failed to delete boot video: {e}");
+        }
+    }
+
+    pub(crate) async fn handle_rename_game(&self, sender: Sender, msg: Message) {
+        let req: messages::RenameGameRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let new_name = req.new_name.trim().to_string();
+        if new_name.is_empty() {
+            let _ = sender.send_error(&msg, 400, "new name must not be empty");
+            return;
+        }
+
+        let sm = match capydeploy_steam::ShortcutManager::new() {
+            Ok(sm) => sm,
+            Err(e) => {
+                let _ =
+                    sender.send_error(&msg, 500, &format!("failed to init ShortcutManager: {e}"));
+                return;
+            }
+        };
+        let Some(user_id) = self.shortcut_owner(&sender, &msg, &sm, req.app_id) else {
+            return;
+        };
+
+        let Some(shortcut) = self.find_shortcut(&sm, &user_id, req.app_id).await else {
+            let _ = sender.send_error(&msg, 404, "game not found");
+            return;
+        };
+
+        if let Err(e) = self.rename_shortcut(&shortcut, &new_name).await {
+            tracing::error!("rename of AppID {} failed: {e}", req.app_id);
+            let _ = sender.send_error(&msg, 500, &format!("rename failed: {e}"));
+            return;
+        }
+
+        tracing::info!(
+            "Renamed game '{}' -> '{}' (AppID {})",
+            shortcut.name,
+            new_name,
+            req.app_id
+        );
+
+        let _ = self.app_handle.emit("shortcuts:changed", &());
+
+        let resp = messages::RenameGameResponse {
+            old_app_id: req.app_id,
+            app_id: req.app_id,
+            name: new_name,
+            artwork_migrated: 0,
+        };
+        if let Ok(reply) = msg.reply(MessageType::OperationResult, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    /// Renames `shortcut` through Steam, then updates the tracked list.
+    /// Steam keeps the AppID, so the artwork and listings stay with it.
+    pub(crate) async fn rename_shortcut(
+        &self,
+        shortcut: &TrackedShortcut,
+        new_name: &str,
+    ) -> Result<(), capydeploy_steam::SteamError> {
+        let cef_timeout = std::time::Duration::from_secs(15);
+        tokio::time::timeout(cef_timeout, async {
+            let cef_client = capydeploy_steam::CefClient::new();
+            cef_client
+                .set_shortcut_name(shortcut.app_id, new_name)
+                .await
+        })
+        .await
+        .unwrap_or_else(|_| {
+            Err(capydeploy_steam::SteamError::Timeout(
+                "CEF set_shortcut_name".into(),
+            ))
+        })?;
+
+        record_rename(
+            &mut self.state.tracked_shortcuts.lock().await,
+            shortcut,
+            new_name,
+        );
+        Ok(())
+    }
+
+    /// Account owning the shortcut behind `app_id`. Replies with an error
+    /// and returns `None` when there is no Steam user to pick.
+    pub(crate) fn shortcut_owner(
+        &self,
+        sender: &Sender,
+        msg: &Message,
+        sm: &capydeploy_steam::ShortcutManager,
+        app_id: u32,
+    ) -> Option<String> {
+        match sm.shortcut_owner(app_id, capydeploy_steam::active_user_id().as_deref()) {
+            Ok(user_id) => Some(user_id),
+            Err(capydeploy_steam::SteamError::UserNotFound) => {
+                let _ = sender.send_error(msg, 500, "no Steam users found");
+                None
+            }
+            Err(e) => {
+                let _ = sender.send_error(msg, 500, &format!("failed to get Steam users: {e}"));
+                None
+            }
+        }
+    }
+
+    /// Looks up a shortcut by AppID in shortcuts.vdf, falling back to the
+    /// tracked list (CEF-created shortcuts may not be flushed to VDF yet).
+    pub(crate) async fn find_shortcut(
+        &self,
+        sm: &capydeploy_steam::ShortcutManager,
+        user_id: &str,
+        app_id: u32,
+    ) -> Option<TrackedShortcut> {
+        let vdf_path = sm.shortcuts_path(user_id);
+        let shortcuts = capydeploy_steam::load_shortcuts_vdf(std::path::Path::new(&vdf_path))
+            .unwrap_or_default();
+        if let Some(sc) = shortcuts.iter().find(|sc| sc.app_id == app_id) {
+            return Some(TrackedShortcut {
+                app_id,
+                name: sc.name.clone(),
+                exe: sc.exe.trim_matches('"').to_string(),
+                start_dir: sc.start_dir.trim_matches('"').to_string(),
+            });
+        }
+
+        let tracked = self.state.tracked_shortcuts.lock().await;
+        tracked.iter().find(|ts| ts.app_id == app_id).cloned()
+    }
+
+    pub(crate) async fn handle_launch_game(&self, sender: Sender, msg: Message) {
+        let req: messages::LaunchGameRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let ctrl = capydeploy_steam::Controller::new();
+        if !ctrl.is_cef_available().await {
+            let _ = sender.send_error(&msg, WS_ERR_CODE_UNAVAILABLE, "Steam is not running");
+            return;
+        }
+        // On the Deck's desktop mode a launched game opens outside gamescope.
+        if cfg!(target_os = "linux") && !ctrl.is_gaming_mode() {
+            let _ = sender.send_error(&msg, WS_ERR_CODE_CONFLICT, "Steam is not in gaming mode");
+            return;
+        }
+
+        let launched = tokio::time::timeout(
+            LAUNCH_TIMEOUT,
+            capydeploy_steam::CefClient::new().launch_app(req.app_id),
+        )
+        .await;
+        match launched {
+            Ok(Ok(true)) => {
+                tracing::info!("Launched AppID {}", req.app_id);
+                let resp = messages::LaunchGameResponse {
+                    app_id: req.app_id,
+                    launched: true,
+                };
+                if let Ok(reply) = msg.reply(MessageType::LaunchGameResponse, Some(&resp)) {
+                    let _ = sender.send_msg(reply);
+                }
+            }
+            Ok(Ok(false)) => {
+                let _ = sender.send_error(&msg, WS_ERR_CODE_NOT_FOUND, "game not found");
+            }
+            Ok(Err(e)) => {
+                tracing::error!("launch of AppID {} failed: {e}", req.app_id);
+                let _ =
+                    sender.send_error(&msg, WS_ERR_CODE_INTERNAL, &format!("launch failed: {e}"));
+            }
+            Err(_) => {
+                let _ = sender.send_error(
+                    &msg,
+                    WS_ERR_CODE_UNAVAILABLE,
+                    "Steam did not answer the launch request",
+                );
+            }
+        }
+    }
+
     pub(crate) async fn handle_restart_steam(&self, sender: Sender, msg: Message) {
         let ctrl = capydeploy_steam::Controller::new();
         let result = ctrl.restart().await;
+        if result.success {
+            tokio::spawn(crate::handlers::retry_queued_artwork(
+                self.state.artwork_retry.clone(),
+            ));
+        }
         let resp = messages::RestartSteamResponse {
             success: result.success,
             message: result.message,
@@ -209,4 +605,73 @@ impl TauriAgentHandler {
             let _ = sender.send_msg(reply);
         }
     }
+
+    /// Reports whether CEF answers. When it doesn't, the debug file is
+    /// created so that a restart (`restart_steam`, with the user's consent
+    /// on the Hub) brings it up.
+    pub(crate) async fn handle_check_cef(&self, sender: Sender, msg: Message) {
+        let ctrl = capydeploy_steam::Controller::new();
+        let available = ctrl.is_cef_available().await;
+        if !available && let Err(e) = ctrl.ensure_cef_debug_file() {
+            tracing::warn!("failed to ensure CEF debug file: {e}");
+        }
+        if available {
+            tokio::spawn(crate::handlers::retry_queued_artwork(
+                self.state.artwork_retry.clone(),
+            ));
+        }
+        let steam_running = ctrl.is_running().await;
+        let resp = messages::CefStatusResponse {
+            available,
+            steam_running,
+            restart_pending: !available && steam_running,
+        };
+        if let Ok(reply) = msg.reply(MessageType::CefStatusResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+}
+
+/// Records a rename in the tracked list. The shortcut keeps its AppID.
+fn record_rename(tracked: &mut Vec<TrackedShortcut>, shortcut: &TrackedShortcut, new_name: &str) {
+    match tracked.iter_mut().find(|ts| ts.app_id == shortcut.app_id) {
+        Some(ts) => ts.name = new_name.to_string(),
+        None => tracked.push(TrackedShortcut {
+            name: new_name.to_string(),
+            ..shortcut.clone()
+        }),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn shortcut(app_id: u32, name: &str) -> TrackedShortcut {
+        TrackedShortcut {
+            app_id,
+            name: name.into(),
+            exe: "/games/a/game.sh".into(),
+            start_dir: "/games/a".into(),
+        }
+    }
+
+    #[test]
+    fn rename_keeps_app_id() {
+        let old = shortcut(3_000_000_001, "Old Name");
+        let mut tracked = vec![old.clone(), shortcut(3_000_000_002, "Other")];
+
+        record_rename(&mut tracked, &old, "New Name");
+        assert_eq!(tracked.len(), 2);
+        assert_eq!(tracked[0].app_id, old.app_id);
+        assert_eq!(tracked[0].name, "New Name");
+        assert_eq!(tracked[1].name, "Other");
+
+        // A shortcut only in shortcuts.vdf is tracked under its own AppID.
+        let mut tracked = Vec::new();
+        record_rename(&mut tracked, &old, "New Name");
+        assert_eq!(tracked.len(), 1);
+        assert_eq!(tracked[0].app_id, old.app_id);
+        assert_eq!(tracked[0].exe, old.exe);
+    }
 }
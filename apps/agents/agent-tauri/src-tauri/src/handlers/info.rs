@@ -1,26 +1,98 @@
 use std::sync::atomic::Ordering;
 
 use capydeploy_agent_server::Sender;
-use capydeploy_protocol::constants::MessageType;
+use capydeploy_protocol::constants::{self, MessageType};
 use capydeploy_protocol::envelope::Message;
 use capydeploy_protocol::messages;
 
 use crate::handler::TauriAgentHandler;
-use crate::helpers::generate_agent_id;
+use crate::helpers::{expand_path, generate_agent_id};
 
 impl TauriAgentHandler {
     pub(crate) async fn handle_get_info(&self, sender: Sender, msg: Message) {
-        let config = self.state.config.lock().await;
+        let steam_login_state = capydeploy_steam::Paths::new()
+            .map(|p| capydeploy_steam::get_login_state(&p))
+            .unwrap_or(capydeploy_steam::LoginState::Unknown);
+
+        let lite = self.agent_info_lite().await;
+        let (started_at, start_reason) = self.server_start();
         let info = capydeploy_protocol::types::AgentInfo {
+            id: lite.id,
+            name: lite.name,
+            platform: lite.platform,
+            version: lite.version,
+            accept_connections: lite.accept_connections,
+            supported_image_formats: capydeploy_transfer::SUPPORTED_IMAGE_FORMATS
+                .iter()
+                .map(|f| f.to_string())
+                .collect(),
+            steam_login_state: steam_login_state.as_str().into(),
+            max_concurrent_uploads: self.state.upload_limiter.max() as u32,
+            verbose: self.state.verbose.load(Ordering::Relaxed),
+            protocol_version: constants::PROTOCOL_VERSION,
+            started_at,
+            start_reason,
+        };
+        let resp = messages::InfoResponse { agent: info };
+        if let Ok(reply) = msg.reply(MessageType::InfoResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    /// Replies with identity and accept state only; skips the Steam probe.
+    pub(crate) async fn handle_get_info_lite(&self, sender: Sender, msg: Message) {
+        let resp = messages::InfoLiteResponse {
+            agent: self.agent_info_lite().await,
+        };
+        if let Ok(reply) = msg.reply(MessageType::InfoLiteResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    /// When the WS server started and why, for `AgentInfo` and the status
+    /// sent on connect. Nothing is reported before it has bound.
+    pub(crate) fn server_start(&self) -> (i64, String) {
+        let started_at = self.state.server_started_at.load(Ordering::Relaxed);
+        let reason = if started_at > 0 {
+            constants::AGENT_START_INITIAL.into()
+        } else {
+            String::new()
+        };
+        (started_at, reason)
+    }
+
+    async fn agent_info_lite(&self) -> capydeploy_protocol::types::AgentInfoLite {
+        let config = self.state.config.lock().await;
+        capydeploy_protocol::types::AgentInfoLite {
             id: generate_agent_id(&config.name),
             name: config.name.clone(),
             platform: std::env::consts::OS.into(),
             version: env!("CAPYDEPLOY_VERSION").into(),
             accept_connections: self.state.accept_connections.load(Ordering::Relaxed),
-            supported_image_formats: vec!["png".into(), "jpg".into(), "jpeg".into(), "webp".into()],
+        }
+    }
+
+    /// Lists the authorized Hubs connected to this agent. The agent serves
+    /// one Hub at a time, so this is the asking Hub once it is authorized.
+    pub(crate) async fn handle_list_connected_hubs(&self, sender: Sender, msg: Message) {
+        let hub = self.state.connected_hub.lock().await;
+        let Some(hub) = hub.as_ref() else {
+            let _ = sender.send_error(
+                &msg,
+                constants::WS_ERR_CODE_UNAUTHORIZED,
+                "hub not authorized",
+            );
+            return;
         };
-        let resp = messages::InfoResponse { agent: info };
-        if let Ok(reply) = msg.reply(MessageType::InfoResponse, Some(&resp)) {
+        let resp = messages::ConnectedHubsResponse {
+            hubs: vec![messages::ConnectedHubEntry {
+                name: hub.name.clone(),
+                ip: hub.ip.clone(),
+                connected_since: hub.connected_since,
+                current: true,
+            }],
+        };
+        if let Ok(reply) = msg.reply(MessageType::ConnectedHubsResponse, Some(&resp)) {
             let _ = sender.send_msg(reply);
         }
     }
@@ -34,4 +106,158 @@ impl TauriAgentHandler {
             let _ = sender.send_msg(reply);
         }
     }
+
+    /// Changes the default install directory. The path is created inside
+    /// the install scope, so the Hub can't point installs at system dirs.
+    pub(crate) async fn handle_set_install_path(&self, sender: Sender, msg: Message) {
+        let req: messages::SetInstallPathRequest = match msg.parse_payload() {
+            Ok(Some(r)) if !r.path.trim().is_empty() => r,
+            _ => {
+                let _ =
+                    sender.send_error(&msg, constants::WS_ERR_CODE_BAD_REQUEST, "path required");
+                return;
+            }
+        };
+
+        let scope = self.install_scope().await;
+        let path = std::path::PathBuf::from(expand_path(req.path.trim()));
+        let resolved = match tokio::task::spawn_blocking(move || scope.create(&path)).await {
+            Ok(Ok(p)) => p,
+            Ok(Err(e)) => {
+                tracing::warn!("set_install_path denied: {e}");
+                let _ = sender.send_error(&msg, constants::WS_ERR_CODE_BAD_REQUEST, &e);
+                return;
+            }
+            Err(e) => {
+                tracing::error!("set_install_path task panicked: {e}");
+                let _ = sender.send_error(&msg, constants::WS_ERR_CODE_INTERNAL, "internal error");
+                return;
+            }
+        };
+
+        let install_path = resolved.to_string_lossy().into_owned();
+        {
+            let mut config = self.state.config.lock().await;
+            config.install_path = install_path.clone();
+            if let Err(e) = config.save() {
+                tracing::error!("failed to save install path: {e}");
+                let _ = sender.send_error(
+                    &msg,
+                    constants::WS_ERR_CODE_INTERNAL,
+                    &format!("failed to save config: {e}"),
+                );
+                return;
+            }
+        }
+        tracing::info!("Install path changed by Hub to: {install_path}");
+
+        let resp = messages::ConfigResponse { install_path };
+        if let Ok(reply) = msg.reply(MessageType::ConfigResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+        crate::commands::notify_config_changed(&self.state).await;
+        self.emit_status_changed().await;
+    }
+
+    pub(crate) async fn handle_self_test(&self, sender: Sender, msg: Message) {
+        let install_path = {
+            let config = self.state.config.lock().await;
+            expand_path(&config.install_path)
+        };
+        let steam = capydeploy_steam::Paths::new().ok();
+
+        let resp = capydeploy_file_ops::run_self_test(
+            steam.as_ref(),
+            std::path::Path::new(&install_path),
+            || async {
+                let cef = capydeploy_steam::CefClient::new();
+                cef.evaluate_void("1").await.map_err(|e| e.to_string())
+            },
+        )
+        .await;
+
+        if !resp.passed {
+            let failed: Vec<&str> = resp
+                .checks
+                .iter()
+                .filter(|c| !c.passed)
+                .map(|c| c.name.as_str())
+                .collect();
+            tracing::warn!(?failed, "self-test found failing subsystems");
+        }
+
+        if let Ok(reply) = msg.reply(MessageType::SelfTestResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    pub(crate) async fn handle_can_deploy(&self, sender: Sender, msg: Message) {
+        let req: messages::CanDeployRequest = match msg.parse_payload() {
+            Ok(r) => r.unwrap_or_default(),
+            Err(_) => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let install_path = {
+            let config = self.state.config.lock().await;
+            std::path::PathBuf::from(expand_path(&config.install_path))
+        };
+        let steam = capydeploy_steam::Paths::new().ok();
+
+        let preflight = capydeploy_file_ops::DeployPreflight {
+            accept_connections: self.state.accept_connections.load(Ordering::Relaxed),
+            steam: steam.as_ref(),
+            install_path: &install_path,
+            required_bytes: req.total_size.max(0) as u64,
+            available_bytes: capydeploy_file_ops::available_space(&install_path),
+        };
+        let resp = capydeploy_file_ops::check_can_deploy(&preflight, || async {
+            let cef = capydeploy_steam::CefClient::new();
+            cef.evaluate_void("1").await.map_err(|e| e.to_string())
+        })
+        .await;
+
+        if !resp.can_deploy {
+            let codes: Vec<&str> = resp.reasons.iter().map(|r| r.code.as_str()).collect();
+            tracing::info!(?codes, "deploy preflight blocked");
+        }
+
+        if let Ok(reply) = msg.reply(MessageType::CanDeployResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    pub(crate) async fn handle_get_disk_usage(&self, sender: Sender, msg: Message) {
+        let req: messages::GetDiskUsageRequest = match msg.parse_payload() {
+            Ok(r) => r.unwrap_or_default(),
+            Err(_) => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let path = if req.path.is_empty() {
+            let config = self.state.config.lock().await;
+            expand_path(&config.install_path)
+        } else {
+            expand_path(&req.path)
+        };
+        let path = std::path::PathBuf::from(path);
+
+        match tokio::task::spawn_blocking(move || capydeploy_file_ops::disk_usage(&path)).await {
+            Ok(Some(usage)) => {
+                if let Ok(reply) = msg.reply(MessageType::DiskUsageResponse, Some(&usage)) {
+                    let _ = sender.send_msg(reply);
+                }
+            }
+            Ok(None) => {
+                let _ = sender.send_error(&msg, 500, "cannot determine disk usage");
+            }
+            Err(_) => {
+                let _ = sender.send_error(&msg, 500, "internal error");
+            }
+        }
+    }
 }
@@ -6,4 +6,6 @@ mod info;
 mod pairing;
 mod shortcuts;
 mod telemetry;
-mod upload;
+pub(crate) mod upload;
+
+pub(crate) use artwork::retry_queued_artwork;
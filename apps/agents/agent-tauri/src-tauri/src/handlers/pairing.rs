@@ -8,10 +8,15 @@ use capydeploy_protocol::constants::{
 };
 use capydeploy_protocol::envelope::Message;
 use capydeploy_protocol::messages;
+use capydeploy_protocol::profile::{
+    BINARY_FRAMING_VERSION, ProtocolOffer, ProtocolProfile, SUPPORTED_COMPRESSION,
+};
 
+use crate::auth::{AuthError, AuthManager, TokenCheck, token_expiry};
 use crate::config::AuthorizedHub;
 use crate::handler::TauriAgentHandler;
 use crate::state::ConnectedHubInfo;
+use crate::types::SuspiciousPairingDto;
 
 impl TauriAgentHandler {
     pub(crate) async fn handle_hub_connected(&self, sender: Sender, msg: Message) {
@@ -42,24 +47,63 @@ impl TauriAgentHandler {
 
         // If Hub provided a token, validate it
         if !req.token.is_empty() && !req.hub_id.is_empty() {
-            let config = self.state.config.lock().await;
-            if crate::auth::AuthManager::validate_token(
+            let mut config = self.state.config.lock().await;
+            let now = chrono::Utc::now();
+            match AuthManager::validate_token(
                 &config.authorized_hubs,
                 &req.hub_id,
                 &req.token,
+                config.token_ttl_days,
+                now,
             ) {
-                tracing::info!("Hub {} authenticated with valid token", req.name);
-                // Update last_seen
-                drop(config);
-                let mut config = self.state.config.lock().await;
-                config.update_hub_last_seen(&req.hub_id, &chrono::Utc::now().to_rfc3339());
-                let _ = config.save();
-                drop(config);
+                check @ (TokenCheck::Valid | TokenCheck::Renew | TokenCheck::Resend) => {
+                    tracing::info!("Hub {} authenticated with valid token", req.name);
+                    config.update_hub_last_seen(&req.hub_id, &now.to_rfc3339());
 
-                self.accept_hub(&sender, &msg, &req).await;
-                return;
+                    // The Hub used its current token, so it has the one
+                    // from the last renewal; the old one is done.
+                    if check != TokenCheck::Resend && config.retire_previous_hub_token(&req.hub_id)
+                    {
+                        tracing::info!("Hub {} switched to its renewed token", req.name);
+                    }
+
+                    // Hand out a fresh token before this one runs out; the
+                    // Hub stores it from the status reply.
+                    let mut renewed_token = String::new();
+                    if check == TokenCheck::Resend {
+                        // The reply with the renewed token was lost.
+                        tracing::info!(
+                            "Hub {} sent its old token, resending the new one",
+                            req.name
+                        );
+                        renewed_token = config.hub_token(&req.hub_id).unwrap_or_default().into();
+                    } else if check == TokenCheck::Renew {
+                        match AuthManager::renew_token() {
+                            Ok(token) => {
+                                let expires_at = token_expiry(now, config.token_ttl_days);
+                                config.renew_hub_token(&req.hub_id, &token, &expires_at);
+                                tracing::info!(
+                                    "Renewed token for Hub {} (expires {expires_at})",
+                                    req.name
+                                );
+                                renewed_token = token;
+                            }
+                            Err(e) => tracing::warn!("Failed to renew hub token: {e}"),
+                        }
+                    }
+                    let _ = config.save();
+                    drop(config);
+
+                    self.accept_hub(&sender, &msg, &req, renewed_token).await;
+                    return;
+                }
+                TokenCheck::Expired => {
+                    tracing::info!("Hub {} token expired, requiring pairing", req.name);
+                }
+                TokenCheck::Invalid => {
+                    tracing::info!("Hub {} provided invalid token, requiring pairing", req.name);
+                }
             }
-            tracing::info!("Hub {} provided invalid token, requiring pairing", req.name);
         }
 
         // Hub not authorized — require pairing
@@ -68,9 +112,21 @@ impl TauriAgentHandler {
             return;
         }
 
-        // Generate pairing code
+        // In allowlist mode, unknown Hubs don't even get a code.
         let mut auth = self.state.auth.lock().await;
-        match auth.generate_code(&req.hub_id, &req.name, &req.platform) {
+        if !auth.is_allowed(&req.hub_id) {
+            tracing::warn!(
+                "Rejecting pairing from Hub {} ({}): not on the allowlist",
+                req.name,
+                req.hub_id
+            );
+            let _ = self.app_handle.emit("pairing:rejected", &req.name);
+            let _ = sender.send_error(&msg, 403, &AuthError::HubNotAllowed.to_string());
+            return;
+        }
+
+        // Generate pairing code
+        match auth.generate_code(&req.hub_id, &req.name, &req.platform, &sender.remote_ip()) {
             Ok(code) => {
                 tracing::info!("Pairing required for Hub {}, code: {}", req.name, code);
 
@@ -86,6 +142,18 @@ impl TauriAgentHandler {
                     let _ = sender.send_msg(reply);
                 }
             }
+            Err(e @ AuthError::TooManyCodeRequests) => {
+                tracing::warn!(
+                    "Refusing pairing code for Hub {} from {}: {e}",
+                    req.name,
+                    sender.remote_ip()
+                );
+                self.emit_suspicious_pairing(&sender, &req.name, &e);
+                let _ = sender.send_error(&msg, 429, &e.to_string());
+            }
+            Err(e @ AuthError::RateLimited) => {
+                let _ = sender.send_error(&msg, 429, &e.to_string());
+            }
             Err(e) => {
                 tracing::error!("Failed to generate pairing code: {e}");
                 let _ = sender.send_error(&msg, 500, &e.to_string());
@@ -93,6 +161,16 @@ impl TauriAgentHandler {
         }
     }
 
+    /// Tells the local UI about pairing traffic that looks like guessing.
+    fn emit_suspicious_pairing(&self, sender: &Sender, hub_name: &str, reason: &AuthError) {
+        let event = SuspiciousPairingDto {
+            hub_name: hub_name.to_string(),
+            ip: sender.remote_ip(),
+            reason: reason.to_string(),
+        };
+        let _ = self.app_handle.emit("pairing:suspicious", &event);
+    }
+
     pub(crate) async fn handle_pair_confirm(&self, sender: Sender, msg: Message) {
         let req: messages::PairConfirmRequest = match msg.parse_payload() {
             Ok(Some(r)) => r,
@@ -117,13 +195,20 @@ impl TauriAgentHandler {
             }
         };
 
-        match auth.validate_code(&session.hub_id, &session.hub_name, &req.code) {
+        match auth.validate_code(
+            &session.hub_id,
+            &session.hub_name,
+            &req.code,
+            &sender.remote_ip(),
+        ) {
             Ok(token) => {
                 tracing::info!("Pairing successful for Hub {}", session.hub_name);
 
                 // Store authorized Hub in config
                 let mut config = self.state.config.lock().await;
-                let now = chrono::Utc::now().to_rfc3339();
+                let paired_at = chrono::Utc::now();
+                let now = paired_at.to_rfc3339();
+                let token_expires_at = token_expiry(paired_at, config.token_ttl_days);
                 config.add_authorized_hub(AuthorizedHub {
                     id: session.hub_id.clone(),
                     name: session.hub_name.clone(),
@@ -131,6 +216,9 @@ impl TauriAgentHandler {
                     token: token.clone(),
                     paired_at: now.clone(),
                     last_seen: now,
+                    token_expires_at,
+                    previous_token: String::new(),
+                    previous_token_expires_at: String::new(),
                 });
                 let _ = config.save();
                 drop(config);
@@ -153,13 +241,21 @@ impl TauriAgentHandler {
                 *self.state.connected_hub.lock().await = Some(ConnectedHubInfo {
                     id: session.hub_id,
                     name: session.hub_name,
-                    ip: String::new(),
+                    ip: sender.remote_ip(),
+                    connected_since: std::time::SystemTime::now()
+                        .duration_since(std::time::UNIX_EPOCH)
+                        .map(|d| d.as_millis() as i64)
+                        .unwrap_or_default(),
+                    profile: ProtocolProfile::default(),
                 });
 
                 self.emit_status_changed().await;
             }
             Err(e) => {
                 tracing::warn!("Pairing failed: {e}");
+                if matches!(e, AuthError::LockedOut) {
+                    self.emit_suspicious_pairing(&sender, &session.hub_name, &e);
+                }
                 let resp = messages::PairFailedResponse {
                     reason: e.to_string(),
                 };
@@ -175,6 +271,7 @@ impl TauriAgentHandler {
         sender: &Sender,
         msg: &Message,
         req: &messages::HubConnectedRequest,
+        renewed_token: String,
     ) {
         // Store WS sender for telemetry/console-log forwarding
         tracing::debug!(
@@ -183,14 +280,8 @@ impl TauriAgentHandler {
         );
         *self.state.hub_sender.lock().unwrap() = Some(sender.clone());
 
-        // Update connected hub state
-        *self.state.connected_hub.lock().await = Some(ConnectedHubInfo {
-            id: req.hub_id.clone(),
-            name: req.name.clone(),
-            ip: String::new(),
-        });
-
         // Build agent status response
+        let (started_at, start_reason) = self.server_start();
         let config = self.state.config.lock().await;
         let resp = messages::AgentStatusResponse {
             name: config.name.clone(),
@@ -204,9 +295,47 @@ impl TauriAgentHandler {
             capabilities: vec![
                 capydeploy_data_channel::CAPABILITY_TCP_DATA_CHANNEL.into(),
                 capydeploy_protocol::constants::CAPABILITY_FILE_BROWSER.into(),
+                capydeploy_protocol::constants::CAPABILITY_CHUNKED_ARTWORK.into(),
+                capydeploy_protocol::constants::CAPABILITY_ARTWORK_BATCH.into(),
+                capydeploy_protocol::constants::CAPABILITY_SHORTCUT_BATCH.into(),
+                capydeploy_protocol::constants::CAPABILITY_DELETE_BATCH.into(),
+                capydeploy_protocol::constants::CAPABILITY_DISK_USAGE.into(),
+                capydeploy_protocol::constants::CAPABILITY_AGENT_LOGS.into(),
+                capydeploy_protocol::constants::CAPABILITY_PAUSE_UPLOAD.into(),
             ],
+            max_binary_frame_size: self.state.max_binary_frame_size as u64,
+            agent_time: std::time::SystemTime::now()
+                .duration_since(std::time::UNIX_EPOCH)
+                .map(|d| d.as_millis() as i64)
+                .unwrap_or_default(),
+            framing_version: BINARY_FRAMING_VERSION,
+            compression: SUPPORTED_COMPRESSION
+                .iter()
+                .map(|c| c.to_string())
+                .collect(),
+            renewed_token,
+            started_at,
+            start_reason,
         };
 
+        // The Hub computes the same profile from this reply.
+        let profile = ProtocolProfile::negotiate(
+            &ProtocolOffer::from_agent(&resp),
+            &ProtocolOffer::from_hub(req),
+        )
+        .unwrap_or_default();
+        tracing::debug!(?profile, "negotiated protocol profile");
+        sender.set_profile(profile.clone());
+
+        // Update connected hub state
+        *self.state.connected_hub.lock().await = Some(ConnectedHubInfo {
+            id: req.hub_id.clone(),
+            name: req.name.clone(),
+            ip: sender.remote_ip(),
+            connected_since: resp.agent_time,
+            profile,
+        });
+
         // Start collectors based on config
         let telemetry_enabled = config.telemetry_enabled;
         let telemetry_interval = config.telemetry_interval;
@@ -1,9 +1,23 @@
+use std::time::Duration;
+
+use tauri::Emitter;
+
 use capydeploy_agent_server::Sender;
 use capydeploy_protocol::constants::MessageType;
 use capydeploy_protocol::envelope::Message;
+use capydeploy_protocol::launch_options::{check_launch_options, normalize_launch_options};
 use capydeploy_protocol::messages;
+use capydeploy_protocol::portable::PortableShortcut;
 
 use crate::handler::TauriAgentHandler;
+use crate::state::TrackedShortcut;
+
+/// Longest a single shortcut in a batch may take to create.
+const BATCH_SHORTCUT_TIMEOUT: Duration = Duration::from_secs(15);
+
+/// Longest Steam may take to apply the launch options and start dir of an
+/// `update_shortcut`.
+const UPDATE_SHORTCUT_TIMEOUT: Duration = Duration::from_secs(15);
 
 impl TauriAgentHandler {
     pub(crate) async fn handle_list_shortcuts(&self, sender: Sender, msg: Message) {
@@ -16,7 +30,7 @@ impl TauriAgentHandler {
         };
 
         let user_id = req.user_id.to_string();
-        let list = match capydeploy_steam::ShortcutManager::new() {
+        let mut list = match capydeploy_steam::ShortcutManager::new() {
             Ok(sm) => {
                 let vdf_path = sm.shortcuts_path(&user_id);
                 capydeploy_steam::load_shortcuts_vdf(std::path::Path::new(&vdf_path))
@@ -28,24 +42,382 @@ impl TauriAgentHandler {
             }
         };
 
+        // Flag Windows executables that have no compat tool assigned.
+        if cfg!(target_os = "linux")
+            && let Ok(paths) = capydeploy_steam::Paths::new()
+        {
+            let mapping = capydeploy_steam::load_compat_tool_mapping(&paths.config_vdf_path())
+                .unwrap_or_else(|e| {
+                    tracing::warn!("failed to read compat tool mapping: {e}");
+                    Default::default()
+                });
+            capydeploy_steam::annotate_compat_tools(&mut list, &mapping, true);
+        }
+
+        // Folder sizes mean walking every game, so keep it off the runtime.
+        let list = tokio::task::spawn_blocking(move || {
+            capydeploy_steam::annotate_install_state(&mut list);
+            list
+        })
+        .await
+        .unwrap_or_default();
+
         let resp = messages::ShortcutsListResponse { shortcuts: list };
         if let Ok(reply) = msg.reply(MessageType::ShortcutsResponse, Some(&resp)) {
             let _ = sender.send_msg(reply);
         }
     }
 
+    pub(crate) async fn handle_export_shortcut(&self, sender: Sender, msg: Message) {
+        let req: messages::ExportShortcutRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let sm = match capydeploy_steam::ShortcutManager::new() {
+            Ok(sm) => sm,
+            Err(e) => {
+                let _ =
+                    sender.send_error(&msg, 500, &format!("failed to init ShortcutManager: {e}"));
+                return;
+            }
+        };
+        let Some(user_id) = self.shortcut_owner(&sender, &msg, &sm, req.app_id) else {
+            return;
+        };
+
+        let vdf_path = sm.shortcuts_path(&user_id);
+        let shortcuts = capydeploy_steam::load_shortcuts_vdf(std::path::Path::new(&vdf_path))
+            .unwrap_or_default();
+        let Some(info) = shortcuts.iter().find(|sc| sc.app_id == req.app_id) else {
+            let _ = sender.send_error(&msg, 404, "shortcut not found");
+            return;
+        };
+
+        let compat_tool = capydeploy_steam::Paths::new()
+            .ok()
+            .and_then(|paths| {
+                capydeploy_steam::load_compat_tool_mapping(&paths.config_vdf_path()).ok()
+            })
+            .and_then(|mapping| mapping.get(&req.app_id).cloned())
+            .unwrap_or_default();
+
+        let resp = messages::ExportShortcutResponse {
+            shortcut: PortableShortcut::from_shortcut(info, &compat_tool),
+        };
+        if let Ok(reply) = msg.reply(MessageType::ExportShortcutResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
     pub(crate) async fn handle_create_shortcut(&self, sender: Sender, msg: Message) {
         // TODO: implement VDF write for shortcut creation
         let _ = sender.send_error(&msg, 501, "shortcut creation not yet implemented");
     }
 
+    /// Creates every shortcut of the request over one CEF connection,
+    /// starting or restarting Steam at most once to reach it.
+    pub(crate) async fn handle_create_shortcuts_batch(&self, sender: Sender, msg: Message) {
+        let req: messages::CreateShortcutsBatchRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let ctrl = capydeploy_steam::Controller::new();
+        if let Err(e) = ctrl.ensure_cef_debug_file() {
+            tracing::warn!("failed to ensure CEF debug file: {e}");
+        }
+        let steam_restarted = !ctrl.is_cef_available().await;
+        if let Err(e) = ctrl.ensure_running().await {
+            let _ = sender.send_error(&msg, 503, &format!("Steam is not reachable: {e}"));
+            return;
+        }
+        let mut session = match capydeploy_steam::CefClient::new().session().await {
+            Ok(s) => s,
+            Err(e) => {
+                let _ = sender.send_error(&msg, 503, &format!("Steam is not reachable: {e}"));
+                return;
+            }
+        };
+
+        let mut results = Vec::with_capacity(req.shortcuts.len());
+        let mut tracked = Vec::new();
+        for cfg in req.shortcuts {
+            // The compat tool is assigned below so a missing one can be
+            // reported back.
+            let shortcut = capydeploy_steam::NewShortcut {
+                name: cfg.name.clone(),
+                exe: cfg.exe.clone(),
+                start_dir: cfg.start_dir.clone(),
+                launch_options: normalize_launch_options(&cfg.launch_options),
+                compat_tool: String::new(),
+            };
+
+            let created =
+                tokio::time::timeout(BATCH_SHORTCUT_TIMEOUT, session.create_shortcut(&shortcut))
+                    .await
+                    .unwrap_or_else(|_| {
+                        Err(capydeploy_steam::SteamError::Timeout(
+                            "AddShortcut did not answer".into(),
+                        ))
+                    });
+            let result = match created {
+                Ok(app_id) => {
+                    tracing::info!("Created shortcut '{}' with AppID {app_id}", cfg.name);
+                    let mut warnings = Vec::new();
+                    let compat_tool = compat_tool_for(&cfg.compat_tool, &cfg.exe);
+                    if !compat_tool.is_empty()
+                        && let Some(w) = assign_compat_tool(&mut session, app_id, compat_tool).await
+                    {
+                        warnings.push(w);
+                    }
+                    if cfg.mark_recent
+                        && let Err(e) = session.mark_recent(app_id).await
+                    {
+                        tracing::warn!("failed to mark {app_id} as recent: {e}");
+                    }
+                    tracked.push(TrackedShortcut {
+                        app_id,
+                        name: cfg.name.clone(),
+                        exe: cfg.exe,
+                        start_dir: cfg.start_dir,
+                    });
+                    messages::ShortcutCreateResult {
+                        name: cfg.name,
+                        success: true,
+                        app_id,
+                        error: String::new(),
+                        warnings,
+                    }
+                }
+                Err(e) => {
+                    tracing::error!("failed to create shortcut '{}': {e}", cfg.name);
+                    messages::ShortcutCreateResult {
+                        name: cfg.name,
+                        success: false,
+                        app_id: 0,
+                        error: e.to_string(),
+                        warnings: Vec::new(),
+                    }
+                }
+            };
+            results.push(result);
+        }
+        session.close().await;
+
+        if !tracked.is_empty() {
+            // Track the shortcuts in memory (VDF may not be flushed yet).
+            self.state.tracked_shortcuts.lock().await.extend(tracked);
+            let _ = self.app_handle.emit("shortcuts:changed", &());
+        }
+
+        let resp = messages::CreateShortcutsBatchResponse {
+            results,
+            steam_restarted,
+        };
+        if let Ok(reply) = msg.reply(MessageType::CreateShortcutsBatchResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
     pub(crate) async fn handle_delete_shortcut(&self, sender: Sender, msg: Message) {
-        // TODO: implement VDF write for shortcut deletion
-        let _ = sender.send_error(&msg, 501, "shortcut deletion not yet implemented");
+        let req: messages::DeleteShortcutRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let user_id = req.user_id.to_string();
+        let resolved = capydeploy_steam::ShortcutManager::new()
+            .and_then(|sm| sm.resolve_delete_target(&user_id, &req));
+        let app_id = match resolved {
+            Ok(id) => id,
+            Err(e) => {
+                let code = match e {
+                    capydeploy_steam::SteamError::ShortcutNotFound(_) => 404,
+                    capydeploy_steam::SteamError::AmbiguousShortcut(_) => 409,
+                    _ => 500,
+                };
+                let _ = sender.send_error(&msg, code, &e.to_string());
+                return;
+            }
+        };
+
+        if let Err(e) =
+            crate::commands::steam::remove_shortcut(&self.state, &self.app_handle, &user_id, app_id)
+                .await
+        {
+            let _ = sender.send_error(&msg, 500, &e);
+            return;
+        }
+
+        tracing::info!("Deleted shortcut {app_id} for user {user_id}");
+        let resp = messages::OperationResult {
+            success: true,
+            message: format!("shortcut {app_id} deleted"),
+        };
+        if let Ok(reply) = msg.reply(MessageType::OperationResult, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    pub(crate) async fn handle_update_shortcut(&self, sender: Sender, msg: Message) {
+        let req: messages::UpdateShortcutRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+        if req.is_empty() {
+            let _ = sender.send_error(&msg, 400, "nothing to update");
+            return;
+        }
+        let new_name = req.name.as_deref().map(str::trim);
+        if new_name == Some("") {
+            let _ = sender.send_error(&msg, 400, "name must not be empty");
+            return;
+        }
+
+        let user_id = req.user_id.to_string();
+        let sm = match capydeploy_steam::ShortcutManager::new() {
+            Ok(sm) => sm,
+            Err(e) => {
+                let _ =
+                    sender.send_error(&msg, 500, &format!("failed to init ShortcutManager: {e}"));
+                return;
+            }
+        };
+        let Some(shortcut) = self.find_shortcut(&sm, &user_id, req.app_id).await else {
+            let _ = sender.send_error(&msg, 404, "shortcut not found");
+            return;
+        };
+
+        let mut warnings = Vec::new();
+        let launch_options = req.launch_options.as_deref().map(normalize_launch_options);
+        for issue in launch_options.iter().flat_map(|o| check_launch_options(o)) {
+            warnings.push(format!("launch options: {issue}"));
+        }
+
+        // Launch options and start dir share one CEF session.
+        if launch_options.is_some() || req.start_dir.is_some() {
+            let updated = tokio::time::timeout(UPDATE_SHORTCUT_TIMEOUT, async {
+                let mut cef = capydeploy_steam::CefClient::new().session().await?;
+                let result = async {
+                    if let Some(options) = &launch_options {
+                        cef.set_shortcut_launch_options(req.app_id, options).await?;
+                    }
+                    if let Some(dir) = &req.start_dir {
+                        cef.set_shortcut_start_dir(req.app_id, dir).await?;
+                    }
+                    Ok::<_, capydeploy_steam::SteamError>(())
+                }
+                .await;
+                cef.close().await;
+                result
+            })
+            .await
+            .unwrap_or_else(|_| {
+                Err(capydeploy_steam::SteamError::Timeout(
+                    "CEF shortcut update".into(),
+                ))
+            });
+            if let Err(e) = updated {
+                tracing::error!("update of shortcut {} failed: {e}", req.app_id);
+                let _ = sender.send_error(&msg, 500, &format!("update failed: {e}"));
+                return;
+            }
+            if let Some(dir) = &req.start_dir {
+                let mut tracked = self.state.tracked_shortcuts.lock().await;
+                if let Some(ts) = tracked.iter_mut().find(|ts| ts.app_id == req.app_id) {
+                    ts.start_dir = dir.clone();
+                }
+            }
+        }
+
+        if let Some(name) = new_name
+            && name != shortcut.name
+        {
+            if let Err(e) = self.rename_shortcut(&shortcut, name).await {
+                tracing::error!("rename of AppID {} failed: {e}", req.app_id);
+                let _ = sender.send_error(&msg, 500, &format!("rename failed: {e}"));
+                return;
+            }
+            tracing::info!(
+                "Renamed shortcut '{}' -> '{name}' (AppID {})",
+                shortcut.name,
+                req.app_id
+            );
+        }
+
+        // Steam has no call for tags; they live only in shortcuts.vdf.
+        if let Some(tags) = &req.tags {
+            let path = std::path::PathBuf::from(sm.shortcuts_path(&user_id));
+            match capydeploy_steam::set_shortcut_tags_vdf(&path, req.app_id, tags) {
+                Ok(true) => warnings.push("tags show after Steam restarts".into()),
+                Ok(false) => {
+                    warnings.push("Steam has not saved the shortcut yet; tags not written".into())
+                }
+                Err(e) => warnings.push(format!("failed to write tags: {e}")),
+            }
+        }
+
+        tracing::info!("Updated shortcut {}", req.app_id);
+        let _ = self.app_handle.emit("shortcuts:changed", &());
+
+        let resp = messages::UpdateShortcutResponse {
+            old_app_id: req.app_id,
+            app_id: req.app_id,
+            warnings,
+        };
+        if let Ok(reply) = msg.reply(MessageType::OperationResult, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
     }
+}
+
+/// Compat tool for a new shortcut: the one the Hub asked for or, on Linux,
+/// Proton for Windows executables.
+pub(crate) fn compat_tool_for<'a>(requested: &'a str, exe: &str) -> &'a str {
+    if !requested.is_empty() {
+        requested
+    } else if cfg!(target_os = "linux") && exe.to_lowercase().ends_with(".exe") {
+        "proton_experimental"
+    } else {
+        ""
+    }
+}
 
-    pub(crate) async fn handle_apply_artwork(&self, sender: Sender, msg: Message) {
-        // TODO: implement URL-based artwork download + apply
-        let _ = sender.send_error(&msg, 501, "apply_artwork not yet implemented");
+/// Assigns `tool` to a freshly created shortcut. Returns a warning for the
+/// operation result when the tool isn't installed or couldn't be set; the
+/// shortcut itself stays.
+pub(crate) async fn assign_compat_tool(
+    cef: &mut capydeploy_steam::CefSession,
+    app_id: u32,
+    tool: &str,
+) -> Option<String> {
+    match cef.specify_installed_compat_tool(app_id, tool).await {
+        Ok(true) => {
+            tracing::info!("Set compat tool {tool} for AppID {app_id}");
+            None
+        }
+        Ok(false) => {
+            tracing::warn!("compat tool {tool} is not installed; AppID {app_id} keeps the default");
+            Some(format!(
+                "compatibility tool {tool} is not installed; the shortcut uses Steam's default"
+            ))
+        }
+        Err(e) => {
+            tracing::warn!("failed to set compat tool {tool} for AppID {app_id}: {e}");
+            Some(format!("failed to set compatibility tool {tool}: {e}"))
+        }
     }
 }
@@ -6,6 +6,7 @@ use capydeploy_protocol::envelope::Message;
 use capydeploy_protocol::messages;
 
 use crate::handler::TauriAgentHandler;
+use crate::logging::LOG_BACKLOG;
 
 impl TauriAgentHandler {
     pub(crate) async fn handle_set_console_log_filter(&self, sender: Sender, msg: Message) {
@@ -67,6 +68,54 @@ impl TauriAgentHandler {
         if let Ok(reply) = msg.reply(MessageType::SetConsoleLogEnabled, Some(&resp)) {
             let _ = sender.send_msg(reply);
         }
+        crate::commands::notify_config_changed(&self.state).await;
+    }
+
+    pub(crate) async fn handle_set_verbose(&self, sender: Sender, msg: Message) {
+        let req: messages::SetVerboseRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        self.state.verbose.store(req.enabled, Ordering::Relaxed);
+        self.state.log_filter.set_verbose(req.enabled);
+        tracing::info!("Verbose logging (remote): {}", req.enabled);
+
+        let resp = messages::SetVerboseResponse {
+            enabled: req.enabled,
+        };
+        if let Ok(reply) = msg.reply(MessageType::SetVerbose, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    pub(crate) async fn handle_subscribe_logs(&self, sender: Sender, msg: Message) {
+        let req: messages::SubscribeLogsRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let lines = if req.enabled {
+            let backlog = (req.backlog as usize).min(LOG_BACKLOG);
+            self.state.log_stream.subscribe(sender.clone(), backlog)
+        } else {
+            self.state.log_stream.unsubscribe();
+            Vec::new()
+        };
+
+        let resp = messages::SubscribeLogsResponse {
+            enabled: req.enabled,
+            lines,
+        };
+        if let Ok(reply) = msg.reply(MessageType::SubscribeLogsResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
     }
 
     pub(crate) async fn handle_set_game_log_wrapper(&self, sender: Sender, msg: Message) {
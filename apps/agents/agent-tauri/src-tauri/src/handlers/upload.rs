@@ -1,3 +1,4 @@
+use std::collections::{HashMap, HashSet};
 use std::path::PathBuf;
 
 use tauri::Emitter;
@@ -5,13 +6,42 @@ use tokio_util::sync::CancellationToken;
 
 use capydeploy_agent_server::{BinaryChunkHeader, Sender};
 use capydeploy_data_channel::server::TcpDataServer;
-use capydeploy_protocol::constants::MessageType;
+use capydeploy_file_ops::UploadBase;
+use capydeploy_protocol::constants::{
+    CAPABILITY_TCP_DATA_CHANNEL, DEFAULT_UPLOAD_PRUNE_IDLE_SECS, DEPLOY_BLOCKER_DISK_FULL,
+    DEPLOY_BLOCKER_INVALID_PATH, DEPLOY_BLOCKER_PATH_NOT_WRITABLE, DEPLOY_BLOCKER_UPLOAD_LIMIT,
+    MessageType, STEAM_LIBRARY_GAMES_DIR, WS_ERR_CODE_BAD_REQUEST, WS_ERR_CODE_CONFLICT,
+    WS_ERR_CODE_INTERNAL, WS_ERR_CODE_NOT_FOUND, WS_ERR_CODE_UPLOAD_PAUSED, WS_MAX_MESSAGE_SIZE,
+};
 use capydeploy_protocol::envelope::Message;
+use capydeploy_protocol::launch_options::{check_launch_options, normalize_launch_options};
 use capydeploy_protocol::messages;
+use capydeploy_transfer::{Compression, TransferError};
 
+use super::shortcuts::{assign_compat_tool, compat_tool_for};
 use crate::handler::TauriAgentHandler;
-use crate::helpers::expand_path;
-use crate::state::{TrackedShortcut, UploadSession};
+use crate::helpers::{check_game_directory, expand_path};
+use crate::state::{AgentState, TrackedShortcut, UploadSession};
+
+/// Binary chunks a Hub may keep in flight per upload. Chunks are written
+/// at their own offsets, so arrival order doesn't matter.
+const MAX_CONCURRENT_CHUNKS: u32 = 4;
+/// How often an upload's progress is saved beside its staging folder.
+const STAGING_STATE_INTERVAL: std::time::Duration = std::time::Duration::from_secs(5);
+
+/// Codec to accept for `requested`. Both known codecs are supported; for
+/// anything else chunks come raw.
+fn accept_compression(requested: &str) -> Compression {
+    Compression::from_name(requested).unwrap_or_default()
+}
+
+/// Codec name for the init response; empty when chunks come raw.
+fn compression_reply(codec: Compression) -> String {
+    match codec {
+        Compression::None => String::new(),
+        codec => codec.name().to_string(),
+    }
+}
 
 impl TauriAgentHandler {
     pub(crate) async fn handle_init_upload(&self, sender: Sender, msg: Message) {
@@ -33,32 +63,235 @@ impl TauriAgentHandler {
             return;
         }
 
+        // Every file must land inside the game directory.
+        if let Some((file, e)) = req.files.iter().find_map(|f| {
+            capydeploy_transfer::validate_upload_path(&f.relative_path)
+                .err()
+                .map(|e| (&f.relative_path, e))
+        }) {
+            let _ = sender.send_error(&msg, 400, &format!("invalid file path {file:?}: {e}"));
+            return;
+        }
+
+        if req.dry_run {
+            self.preview_upload(&sender, &msg, &req).await;
+            return;
+        }
+
+        if !req.resume_upload_id.is_empty() {
+            self.resume_upload(&sender, &msg, &req).await;
+            return;
+        }
+
+        let Some(permit) = self.state.upload_limiter.try_acquire() else {
+            let max = self.state.upload_limiter.max();
+            let _ = sender.send_error(
+                &msg,
+                WS_ERR_CODE_CONFLICT,
+                &format!("agent accepts at most {max} concurrent upload(s)"),
+            );
+            return;
+        };
+
         let upload_id = uuid::Uuid::new_v4().to_string();
+        let compression = accept_compression(&req.compression);
 
-        // Resolve the game installation directory.
-        let config = self.state.config.lock().await;
-        let base_path = expand_path(&config.install_path);
-        drop(config);
+        // Resolve the game installation directory. A target picked by the
+        // Hub must stay inside the agent's allowed roots.
+        let base_path = if !req.config.library_path.is_empty() {
+            let library = PathBuf::from(expand_path(&req.config.library_path));
+            let scope = self.install_scope().await;
+            match tokio::task::spawn_blocking(move || prepare_library(&scope, &library)).await {
+                Ok(Ok(dir)) => dir.to_string_lossy().into_owned(),
+                Ok(Err(e)) => {
+                    let _ = sender.send_error(&msg, 400, &format!("invalid Steam library: {e}"));
+                    return;
+                }
+                Err(_) => {
+                    let _ = sender.send_error(&msg, 500, "internal error");
+                    return;
+                }
+            }
+        } else {
+            let configured = expand_path(&self.state.config.lock().await.install_path);
+            match capydeploy_file_ops::upload_base(
+                &expand_path(&req.config.install_path),
+                &configured,
+            ) {
+                UploadBase::Configured(dir) => {
+                    if !req.config.install_path.trim().is_empty() {
+                        tracing::warn!(
+                            "ignoring relative install path {:?}, using {}",
+                            req.config.install_path,
+                            dir.display()
+                        );
+                    }
+                    dir.to_string_lossy().into_owned()
+                }
+                UploadBase::Requested(requested) => {
+                    let scope = self.install_scope().await;
+                    match tokio::task::spawn_blocking(move || scope.create(&requested)).await {
+                        Ok(Ok(dir)) => dir.to_string_lossy().into_owned(),
+                        Ok(Err(e)) => {
+                            let _ =
+                                sender.send_error(&msg, 400, &format!("invalid install path: {e}"));
+                            return;
+                        }
+                        Err(_) => {
+                            let _ = sender.send_error(&msg, 500, "internal error");
+                            return;
+                        }
+                    }
+                }
+            }
+        };
+        // The Hub may place the game in a subfolder of that directory.
+        let base_path = match capydeploy_transfer::resolve_install_subpath(
+            std::path::Path::new(&base_path),
+            &req.config.install_subpath,
+        ) {
+            Ok(dir) => dir.to_string_lossy().into_owned(),
+            Err(e) => {
+                let _ = sender.send_error(&msg, 400, &format!("invalid install subfolder: {e}"));
+                return;
+            }
+        };
         let game_path = PathBuf::from(&base_path).join(&req.config.game_name);
-        tokio::fs::create_dir_all(&game_path).await.ok();
+
+        // Refuse before any bytes move if Steam already has this game,
+        // unless the Hub asked to replace it.
+        let conflict = self
+            .shortcut_conflict(&req.config.game_name, &game_path, &req.config.executable)
+            .await;
+        let replace_app_id = match conflict {
+            Some(conflict) if !req.config.overwrite => {
+                let message = format!(
+                    "Steam already has a shortcut for '{}' (AppID {})",
+                    conflict.name, conflict.app_id
+                );
+                match msg.reply_error_with(WS_ERR_CODE_CONFLICT, message, &conflict) {
+                    Ok(reply) => {
+                        let _ = sender.send_msg(reply);
+                    }
+                    Err(_) => {
+                        let _ = sender.send_error(&msg, 500, "internal error");
+                    }
+                }
+                return;
+            }
+            conflict => conflict.map(|c| c.app_id),
+        };
+
+        // A clean install replaces the whole folder once the upload is
+        // complete; refuse now if it couldn't be removed then.
+        if req.config.clean_install
+            && game_path.is_dir()
+            && let Err(e) = check_game_directory(&game_path.to_string_lossy())
+        {
+            let _ = sender.send_error(&msg, 400, &format!("cannot clean install: {e}"));
+            return;
+        }
+
+        // Files are written to a staging folder and only moved into place
+        // once complete, so an interrupted upload never leaves a game that
+        // looks installed but isn't. One left by an upload of the same
+        // files, e.g. before the agent restarted, is resumed.
+        let staging = {
+            let (target, files) = (game_path.clone(), req.files.clone());
+            tokio::task::spawn_blocking(move || {
+                let staging = capydeploy_transfer::choose_staging_dir(&target);
+                capydeploy_transfer::prepare_staging(&staging.dir, &files)
+                    .map(|staged| (staging, staged))
+            })
+            .await
+        };
+        let (staging, staged) = match staging {
+            Ok(Ok(staging)) => staging,
+            Ok(Err(e)) => {
+                let _ = sender.send_error(&msg, 500, &format!("cannot create staging folder: {e}"));
+                return;
+            }
+            Err(_) => {
+                let _ = sender.send_error(&msg, 500, "internal error");
+                return;
+            }
+        };
+        if let Some(warning) = &staging.warning {
+            tracing::warn!("{warning}");
+        }
+
+        // Files from an earlier upload to the same place that haven't
+        // changed since needn't be sent again.
+        let skip_files = if req.config.clean_install {
+            Vec::new()
+        } else {
+            let (root, files) = (game_path.clone(), req.files.clone());
+            tokio::task::spawn_blocking(move || capydeploy_transfer::unchanged_files(&root, &files))
+                .await
+                .unwrap_or_default()
+        };
+        let skipped: HashSet<String> = skip_files.iter().cloned().collect();
+        let (stale, resume_from): (HashMap<String, i64>, HashMap<String, i64>) = staged
+            .into_iter()
+            .partition(|(path, _)| skipped.contains(path));
+        // Staged copies of files now installed unchanged would overwrite
+        // them when merged.
+        for path in stale.keys() {
+            if let Ok(path) = capydeploy_transfer::safe_join(&staging.dir, path) {
+                let _ = std::fs::remove_file(path);
+            }
+        }
+        let mut received: HashMap<String, i64> = req
+            .files
+            .iter()
+            .filter(|f| skipped.contains(&f.relative_path))
+            .map(|f| (f.relative_path.clone(), f.size))
+            .collect();
+        received.extend(resume_from.clone());
+        if !resume_from.is_empty() {
+            tracing::info!(
+                "Resuming upload of '{}' into {} ({} files partly staged)",
+                req.config.game_name,
+                staging.dir.display(),
+                resume_from.len()
+            );
+        }
 
         // Start TCP data channel listener.
         let dc_cancel = CancellationToken::new();
-        let tcp_server = TcpDataServer::new(game_path.clone(), dc_cancel.clone());
+        let tcp_server = TcpDataServer::new(staging.dir.clone(), dc_cancel.clone());
 
-        let session = UploadSession {
+        let mut session = UploadSession {
             id: upload_id.clone(),
             game_name: req.config.game_name.clone(),
-            install_path: req.config.install_path.clone(),
+            install_path: base_path.clone(),
             executable: req.config.executable.clone(),
             total_size: req.total_size,
-            transferred: 0,
+            transferred: received.values().sum(),
             current_file: String::new(),
+            files: req.files.clone(),
+            received,
+            pending: HashMap::new(),
+            skipped,
+            compression,
             active: true,
+            paused: false,
             last_progress_pct: 0.0,
             last_progress_time: std::time::Instant::now(),
+            started_at: std::time::SystemTime::now()
+                .duration_since(std::time::UNIX_EPOCH)
+                .map(|d| d.as_millis() as i64)
+                .unwrap_or_default(),
+            last_activity: std::time::Instant::now(),
             data_channel_cancel: Some(dc_cancel),
+            permit,
+            replace_app_id,
+            staging_dir: staging.dir,
+            clean_install: req.config.clean_install,
+            throughput: capydeploy_transfer::ThroughputMeter::new(),
+            state_saved_at: None,
         };
+        save_staging_state(&mut session);
 
         self.state
             .uploads
@@ -67,11 +300,12 @@ impl TauriAgentHandler {
             .insert(upload_id.clone(), session);
 
         tracing::info!(
-            "Upload session created: {} for game '{}' ({} bytes, {} files)",
+            "Upload session created: {} for game '{}' ({} bytes, {} files, {} unchanged)",
             upload_id,
             req.config.game_name,
             req.total_size,
-            req.files.len()
+            req.files.len(),
+            skip_files.len()
         );
 
         self.emit_operation(
@@ -85,24 +319,40 @@ impl TauriAgentHandler {
 
         // Bind TCP data channel *before* sending the response so the Hub
         // receives tcp_port/tcp_token in the InitUploadResponse itself.
-        let (tcp_port, tcp_token, tcp_listener) = match tcp_server.listen().await {
-            Ok((dc_info, listener)) => (
-                Some(dc_info.port),
-                Some(dc_info.token.clone()),
-                Some((dc_info, listener)),
-            ),
-            Err(e) => {
-                tracing::warn!("TCP data channel listen failed: {e}");
-                (None, None, None)
+        // Hubs that didn't negotiate the channel upload over the WebSocket.
+        let tcp_negotiated = self
+            .state
+            .connected_hub
+            .lock()
+            .await
+            .as_ref()
+            .is_none_or(|hub| hub.profile.supports(CAPABILITY_TCP_DATA_CHANNEL));
+        let (tcp_port, tcp_token, tcp_listener) = if !tcp_negotiated {
+            (None, None, None)
+        } else {
+            match tcp_server.listen().await {
+                Ok((dc_info, listener)) => (
+                    Some(dc_info.port),
+                    Some(dc_info.token.clone()),
+                    Some((dc_info, listener)),
+                ),
+                Err(e) => {
+                    tracing::warn!("TCP data channel listen failed: {e}");
+                    (None, None, None)
+                }
             }
         };
 
         let resp = messages::InitUploadResponseFull {
             upload_id: upload_id.clone(),
             chunk_size: 4_194_304, // 4MB
-            resume_from: None,
+            resume_from: (!resume_from.is_empty()).then_some(resume_from),
             tcp_port,
             tcp_token: tcp_token.clone(),
+            max_concurrent_chunks: MAX_CONCURRENT_CHUNKS,
+            compression: compression_reply(compression),
+            skip_files,
+            preview: None,
         };
         if let Ok(reply) = msg.reply(MessageType::UploadInitResponse, Some(&resp)) {
             let _ = sender.send_msg(reply);
@@ -130,10 +380,11 @@ impl TauriAgentHandler {
                 while let Some((bytes, file)) = progress_rx.recv().await {
                     let mut uploads = state_progress.uploads.lock().await;
                     if let Some(session) = uploads.get_mut(&uid_progress) {
-                        session.transferred = bytes;
+                        session.transferred = session.skipped_bytes() + bytes;
                         session.current_file = file.clone();
+                        session.record_streamed(bytes, &file);
+                        session.throughput.record(session.transferred);
                         let pct = session.percentage();
-                        let total = session.total_size;
                         let elapsed = session.last_progress_time.elapsed();
                         let should_emit = pct >= 100.0
                             || (pct - session.last_progress_pct) >= 2.0
@@ -141,14 +392,9 @@ impl TauriAgentHandler {
                         if should_emit {
                             session.last_progress_pct = pct;
                             session.last_progress_time = std::time::Instant::now();
+                            save_staging_state(session);
+                            let evt = session.progress_event();
                             drop(uploads);
-                            let evt = messages::UploadProgressEvent {
-                                upload_id: uid_progress.clone(),
-                                transferred_bytes: bytes,
-                                total_bytes: total,
-                                current_file: file,
-                                percentage: pct,
-                            };
                             if let Ok(m) = Message::new(
                                 uuid::Uuid::new_v4().to_string(),
                                 MessageType::UploadProgress,
@@ -198,6 +444,173 @@ impl TauriAgentHandler {
         });
     }
 
+    /// Answers a dry run: resolves where the game would go and checks that
+    /// it is writable, that the files fit and whether Steam already has a
+    /// shortcut for the game. Nothing is created and no session is opened.
+    async fn preview_upload(
+        &self,
+        sender: &Sender,
+        msg: &Message,
+        req: &messages::InitUploadRequestFull,
+    ) {
+        let scope = self.install_scope().await;
+        let configured = expand_path(&self.state.config.lock().await.install_path);
+        let (config, files, total_size) = (req.config.clone(), req.files.clone(), req.total_size);
+        let checked = tokio::task::spawn_blocking(move || {
+            preview_target(&scope, &configured, &config, &files, total_size)
+        })
+        .await;
+        let Ok((mut preview, skip_files)) = checked else {
+            let _ = sender.send_error(msg, 500, "internal error");
+            return;
+        };
+
+        let limiter = &self.state.upload_limiter;
+        if limiter.active() >= limiter.max() {
+            preview.blockers.push(messages::DeployBlocker {
+                code: DEPLOY_BLOCKER_UPLOAD_LIMIT.into(),
+                message: format!(
+                    "agent accepts at most {} concurrent upload(s)",
+                    limiter.max()
+                ),
+            });
+        }
+        if !preview.game_path.is_empty() && !req.config.overwrite {
+            preview.conflict = self
+                .shortcut_conflict(
+                    &req.config.game_name,
+                    std::path::Path::new(&preview.game_path),
+                    &req.config.executable,
+                )
+                .await;
+        }
+
+        tracing::info!(
+            "Upload dry run for '{}': {} blocker(s), {} warning(s)",
+            req.config.game_name,
+            preview.blockers.len(),
+            preview.warnings.len()
+        );
+
+        let resp = messages::InitUploadResponseFull {
+            upload_id: String::new(),
+            chunk_size: 0,
+            resume_from: None,
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: String::new(),
+            skip_files,
+            preview: Some(preview),
+        };
+        if let Ok(reply) = msg.reply(MessageType::UploadInitResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    /// The Steam shortcut an upload of `name` into `game_path` would
+    /// duplicate: one with the same name or the same executable.
+    async fn shortcut_conflict(
+        &self,
+        name: &str,
+        game_path: &std::path::Path,
+        executable: &str,
+    ) -> Option<messages::ShortcutConflict> {
+        let exe = if executable.is_empty() {
+            String::new()
+        } else {
+            game_path.join(executable).to_string_lossy().into_owned()
+        };
+        let found = {
+            let (name, exe) = (name.to_string(), exe.clone());
+            tokio::task::spawn_blocking(move || {
+                installed_shortcuts()
+                    .into_iter()
+                    .find(|s| same_game(&s.name, &s.exe, &name, &exe))
+            })
+            .await
+            .ok()
+            .flatten()
+        };
+        if let Some(s) = found {
+            return Some(messages::ShortcutConflict {
+                app_id: s.app_id,
+                exe: s.exe.trim_matches('"').to_string(),
+                name: s.name,
+            });
+        }
+        // Shortcuts made this session may not be in the VDF yet.
+        self.state
+            .tracked_shortcuts
+            .lock()
+            .await
+            .iter()
+            .find(|s| same_game(&s.name, &s.exe, name, &exe))
+            .map(|s| messages::ShortcutConflict {
+                app_id: s.app_id,
+                name: s.name.clone(),
+                exe: s.exe.clone(),
+            })
+    }
+
+    /// Re-opens an upload whose Hub went away, e.g. after a Hub restart,
+    /// and reports the bytes already written per file. The rest arrives
+    /// over the WebSocket.
+    async fn resume_upload(
+        &self,
+        sender: &Sender,
+        msg: &Message,
+        req: &messages::InitUploadRequestFull,
+    ) {
+        let mut uploads = self.state.uploads.lock().await;
+        let Some(session) = uploads
+            .get_mut(&req.resume_upload_id)
+            .filter(|s| s.active && s.game_name == req.config.game_name && s.files == req.files)
+        else {
+            drop(uploads);
+            let _ = sender.send_error(msg, 404, "upload not found");
+            return;
+        };
+
+        // A data channel transfer from the previous connection is dead.
+        if let Some(cancel) = session.data_channel_cancel.take() {
+            cancel.cancel();
+        }
+        session.last_activity = std::time::Instant::now();
+        let resume_from = session.resume_offsets();
+        session.transferred = resume_from.values().sum();
+        session.throughput.restart();
+        // The Hub may have changed its compression setting since.
+        session.compression = accept_compression(&req.compression);
+        let compression = session.compression;
+        let upload_id = session.id.clone();
+        let transferred = session.transferred;
+        drop(uploads);
+
+        tracing::info!(
+            "Upload session resumed: {} for game '{}' ({} of {} bytes received)",
+            upload_id,
+            req.config.game_name,
+            transferred,
+            req.total_size
+        );
+
+        let resp = messages::InitUploadResponseFull {
+            upload_id,
+            chunk_size: 4_194_304, // 4MB
+            resume_from: Some(resume_from),
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: MAX_CONCURRENT_CHUNKS,
+            compression: compression_reply(compression),
+            skip_files: Vec::new(),
+            preview: None,
+        };
+        if let Ok(reply) = msg.reply(MessageType::UploadInitResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
     pub(crate) async fn handle_upload_chunk(&self, sender: Sender, msg: Message) {
         // Handled by binary path — JSON chunks are a fallback
         let _ = sender.send_error(&msg, 501, "use binary chunk protocol");
@@ -210,7 +623,7 @@ impl TauriAgentHandler {
         data: Vec<u8>,
     ) {
         // ── Phase 1 (async): extract session info, drop lock ──────────
-        let game_path = {
+        let (staging_dir, compression) = {
             let uploads = self.state.uploads.lock().await;
             let session = match uploads.get(&header.upload_id) {
                 Some(s) if s.active => s,
@@ -222,31 +635,79 @@ impl TauriAgentHandler {
                     return;
                 }
             };
+            if session.paused {
+                let _ = sender.send_msg(Message::error(
+                    &header.id,
+                    WS_ERR_CODE_UPLOAD_PAUSED,
+                    "upload is paused",
+                ));
+                return;
+            }
 
-            let config = self.state.config.lock().await;
-            let base_path = expand_path(&config.install_path);
-            drop(config);
-            PathBuf::from(&base_path).join(&session.game_name)
-        };
-
-        // ── Phase 2 (spawn_blocking): disk I/O off the tokio runtime ──
-        let chunk_len = data.len() as i64;
-        let chunk = capydeploy_transfer::Chunk {
-            offset: header.offset,
-            size: data.len(),
-            data,
-            file_path: header.file_path.clone(),
-            checksum: header.checksum.clone(),
+            (session.staging_dir.clone(), session.compression)
         };
 
-        let write_path = game_path.clone();
+        // ── Phase 2 (spawn_blocking): decompress + disk I/O off the tokio runtime ──
+        let compressed = header.compressed;
+        let offset = header.offset;
+        let file_path = header.file_path.clone();
+        let checksum = header.checksum.clone();
+        let write_path = staging_dir;
         let write_result = tokio::task::spawn_blocking(move || {
+            let data = if !compressed {
+                data
+            } else if compression == Compression::None {
+                return Err(TransferError::Compression(
+                    "compressed chunk without a negotiated codec".into(),
+                ));
+            } else {
+                // A raw chunk never exceeds a frame, so neither may this.
+                compression.decompress(&data, WS_MAX_MESSAGE_SIZE)?
+            };
+            let chunk = capydeploy_transfer::Chunk {
+                offset,
+                size: data.len(),
+                data,
+                file_path,
+                checksum,
+            };
             let mut writer = capydeploy_transfer::ChunkWriter::new(&write_path);
-            writer.write_chunk(&chunk)
+            writer.write_chunk(&chunk)?;
+            Ok(chunk.size as i64)
         })
         .await;
 
-        match write_result {
+        let chunk_len = match write_result {
+            Ok(Err(TransferError::InvalidPath(e))) => {
+                // Only this chunk is refused; the Hub gets an answer
+                // instead of waiting for the ACK.
+                tracing::warn!(
+                    upload_id = %header.upload_id,
+                    "rejecting chunk for {:?}: {e}",
+                    header.file_path
+                );
+                let _ = sender.send_msg(Message::error(
+                    &header.id,
+                    WS_ERR_CODE_BAD_REQUEST,
+                    format!("invalid file path: {e}"),
+                ));
+                return;
+            }
+            Ok(Err(TransferError::Compression(e))) => {
+                // A chunk that doesn't decompress is refused like a bad
+                // path, so the Hub fails now rather than at its timeout.
+                tracing::warn!(
+                    upload_id = %header.upload_id,
+                    "rejecting chunk for {:?}: {e}",
+                    header.file_path
+                );
+                let _ = sender.send_msg(Message::error(
+                    &header.id,
+                    WS_ERR_CODE_BAD_REQUEST,
+                    format!("cannot decompress chunk: {e}"),
+                ));
+                return;
+            }
             Ok(Err(e)) => {
                 // Disk write failed — mark session inactive.
                 let mut uploads = self.state.uploads.lock().await;
@@ -254,6 +715,11 @@ impl TauriAgentHandler {
                     s.active = false;
                 }
                 tracing::error!("failed to write chunk: {e}");
+                let _ = sender.send_msg(Message::error(
+                    &header.id,
+                    WS_ERR_CODE_INTERNAL,
+                    format!("failed to write chunk: {e}"),
+                ));
                 return;
             }
             Err(e) => {
@@ -263,10 +729,15 @@ impl TauriAgentHandler {
                     s.active = false;
                 }
                 tracing::error!("chunk write task failed: {e}");
+                let _ = sender.send_msg(Message::error(
+                    &header.id,
+                    WS_ERR_CODE_INTERNAL,
+                    "chunk write task failed",
+                ));
                 return;
             }
-            Ok(Ok(())) => {}
-        }
+            Ok(Ok(len)) => len,
+        };
 
         // ── Phase 3 (async): update state, throttled progress, ACK ────
         let mut uploads = self.state.uploads.lock().await;
@@ -280,9 +751,10 @@ impl TauriAgentHandler {
 
         session.transferred += chunk_len;
         session.current_file = header.file_path.clone();
+        session.record_chunk(&header.file_path, header.offset, chunk_len);
+        session.throughput.record(session.transferred);
         let percentage = session.percentage();
         let upload_id = session.id.clone();
-        let total = session.total_size;
         let transferred = session.transferred;
         let game_name = session.game_name.clone();
 
@@ -292,20 +764,15 @@ impl TauriAgentHandler {
             || (percentage - session.last_progress_pct) >= 2.0
             || elapsed >= std::time::Duration::from_millis(500);
 
+        let progress_evt = should_emit.then(|| session.progress_event());
         if should_emit {
             session.last_progress_pct = percentage;
             session.last_progress_time = std::time::Instant::now();
+            save_staging_state(session);
         }
         drop(uploads);
 
-        if should_emit {
-            let progress_evt = messages::UploadProgressEvent {
-                upload_id: upload_id.clone(),
-                transferred_bytes: transferred,
-                total_bytes: total,
-                current_file: header.file_path.clone(),
-                percentage,
-            };
+        if let Some(progress_evt) = progress_evt {
             self.send_event(&sender, MessageType::UploadProgress, &progress_evt);
             self.emit_operation(&sender, "install", "progress", &game_name, percentage, "");
         }
@@ -349,10 +816,129 @@ impl TauriAgentHandler {
             cancel.cancel();
         }
 
-        let config = self.state.config.lock().await;
-        let base_path = expand_path(&config.install_path);
-        drop(config);
-        let game_path = PathBuf::from(&base_path).join(&session.game_name);
+        let game_path = PathBuf::from(&session.install_path).join(&session.game_name);
+
+        // Check the received files against the Hub's manifest before they
+        // are moved into place. Skipped ones are already in the game folder.
+        if !req.verify.is_empty() {
+            let (installed, staged): (Vec<_>, Vec<_>) = req
+                .verify
+                .iter()
+                .cloned()
+                .partition(|f| session.skipped.contains(&f.relative_path));
+            let (root, staging) = (game_path.clone(), session.staging_dir.clone());
+            let mismatched = tokio::task::spawn_blocking(move || {
+                let mut mismatched = capydeploy_transfer::verify_installed(&staging, &staged);
+                mismatched.extend(capydeploy_transfer::verify_installed(&root, &installed));
+                // Resent files are written from scratch.
+                for m in &mismatched {
+                    if let Ok(path) = capydeploy_transfer::safe_join(&staging, &m.relative_path) {
+                        let _ = std::fs::remove_file(path);
+                    }
+                }
+                mismatched
+            })
+            .await;
+            let mismatched = match mismatched {
+                Ok(m) => m,
+                Err(e) => {
+                    tracing::error!("verification task failed: {e}");
+                    let _ = sender.send_error(&msg, 500, "internal error");
+                    return;
+                }
+            };
+            if !mismatched.is_empty() {
+                tracing::warn!(
+                    "Upload {} failed verification: {} file(s) to resend",
+                    req.upload_id,
+                    mismatched.len()
+                );
+                let mut session = session;
+                session.reopen_for(mismatched.iter().map(|m| m.relative_path.as_str()));
+                self.state
+                    .uploads
+                    .lock()
+                    .await
+                    .insert(req.upload_id.clone(), session);
+
+                let resp = messages::CompleteUploadResponseFull {
+                    success: false,
+                    path: game_path.to_string_lossy().into(),
+                    app_id: 0,
+                    warnings: Vec::new(),
+                    mismatched,
+                };
+                if let Ok(reply) = msg.reply(MessageType::OperationResult, Some(&resp)) {
+                    let _ = sender.send_msg(reply);
+                }
+                return;
+            }
+        }
+
+        // Stamp the Hub's modification times so the next upload here can
+        // skip what hasn't changed.
+        let (root, files): (_, Vec<_>) = (
+            session.staging_dir.clone(),
+            session
+                .files
+                .iter()
+                .filter(|f| !session.skipped.contains(&f.relative_path))
+                .cloned()
+                .collect(),
+        );
+        match tokio::task::spawn_blocking(move || {
+            capydeploy_transfer::stamp_modified(&root, &files)
+        })
+        .await
+        {
+            Ok(Ok(())) => {}
+            Ok(Err(e)) => tracing::warn!("failed to set file modification times: {e}"),
+            Err(e) => tracing::warn!("modification time task failed: {e}"),
+        }
+
+        let (staging, target, clean) = (
+            session.staging_dir.clone(),
+            game_path.clone(),
+            session.clean_install,
+        );
+        let installed = tokio::task::spawn_blocking(move || {
+            // The old install is only removed once the new one is in place.
+            let moved = if clean {
+                check_game_directory(&target.to_string_lossy())
+                    .map_err(TransferError::InvalidPath)?;
+                capydeploy_transfer::replace_into_place(&staging, &target)
+            } else {
+                capydeploy_transfer::merge_into_place(&staging, &target)
+            };
+            if moved.is_ok() {
+                capydeploy_transfer::clear_staging_state(&staging);
+            }
+            moved
+        })
+        .await;
+        match installed {
+            Ok(Ok(capydeploy_transfer::MoveMethod::Copied)) => tracing::info!(
+                "Staged upload copied into {} (different filesystem)",
+                game_path.display()
+            ),
+            Ok(Ok(_)) => {}
+            Ok(Err(e)) => {
+                tracing::error!("failed to move upload into {}: {e}", game_path.display());
+                // Kept so the Hub's cancel can remove the staged files.
+                self.state
+                    .uploads
+                    .lock()
+                    .await
+                    .insert(req.upload_id.clone(), session);
+                let _ = sender.send_error(&msg, 500, &format!("cannot install game: {e}"));
+                return;
+            }
+            Err(e) => {
+                tracing::error!("install task failed: {e}");
+                let _ = sender.send_error(&msg, 500, "internal error");
+                return;
+            }
+        }
 
         tracing::info!(
             "Upload completed: {} -> {}",
@@ -385,12 +971,23 @@ impl TauriAgentHandler {
             success: true,
             path: game_path.to_string_lossy().into(),
             app_id: 0,
+            warnings: Vec::new(),
+            mismatched: Vec::new(),
         };
 
         // Create shortcut if requested
         #[allow(clippy::collapsible_if)]
         if req.create_shortcut {
-            if let Some(shortcut_cfg) = req.shortcut {
+            if let Some(mut shortcut_cfg) = req.shortcut {
+                // Older Hubs don't check launch options; warn instead of
+                // refusing, since Steam accepts the string either way.
+                shortcut_cfg.launch_options =
+                    normalize_launch_options(&shortcut_cfg.launch_options);
+                for issue in check_launch_options(&shortcut_cfg.launch_options) {
+                    tracing::warn!("launch options for {}: {issue}", shortcut_cfg.name);
+                    resp.warnings.push(format!("launch options: {issue}"));
+                }
+
                 let exe_name = std::path::Path::new(&shortcut_cfg.exe)
                     .file_name()
                     .and_then(|n| n.to_str())
@@ -398,29 +995,56 @@ impl TauriAgentHandler {
                 let full_exe = game_path.join(exe_name).to_string_lossy().into_owned();
                 let start_dir = game_path.to_string_lossy().into_owned();
 
-                // Ensure CEF debug file exists.
+                // Ensure CEF debug file exists. A fresh one only takes
+                // effect after Steam restarts, so the calls below will
+                // most likely fail.
                 let ctrl = capydeploy_steam::Controller::new();
-                if let Err(e) = ctrl.ensure_cef_debug_file() {
-                    tracing::warn!("failed to ensure CEF debug file: {e}");
+                match ctrl.ensure_cef_debug_file() {
+                    Ok(true) if ctrl.is_running().await => {
+                        resp.warnings.push(
+                            "Steam remote debugging was just enabled; restart Steam so \
+                             shortcuts and artwork apply"
+                                .into(),
+                        );
+                    }
+                    Ok(_) => {}
+                    Err(e) => tracing::warn!("failed to ensure CEF debug file: {e}"),
                 }
 
-                // Create shortcut via CEF API (like Go agent's manager.Create).
-                let cef = capydeploy_steam::CefClient::new();
+                // Create shortcut via CEF API (like Go agent's manager.Create),
+                // keeping the connection for the follow-up calls.
                 let cef_timeout = std::time::Duration::from_secs(15);
+                let added = tokio::time::timeout(cef_timeout, async {
+                    let mut cef = capydeploy_steam::CefClient::new().session().await?;
+                    if let Some(old) = session.replace_app_id {
+                        match cef.remove_shortcut(old).await {
+                            Ok(()) => tracing::info!("Removed shortcut {old} being overwritten"),
+                            Err(e) => tracing::warn!("failed to remove shortcut {old}: {e}"),
+                        }
+                    }
+                    match cef
+                        .add_shortcut(
+                            &shortcut_cfg.name,
+                            &full_exe,
+                            &start_dir,
+                            &shortcut_cfg.launch_options,
+                        )
+                        .await
+                    {
+                        Ok(app_id) => Ok::<_, capydeploy_steam::SteamError>((cef, app_id)),
+                        Err(e) => {
+                            cef.close().await;
+                            Err(e)
+                        }
+                    }
+                })
+                .await;
 
-                match tokio::time::timeout(
-                    cef_timeout,
-                    cef.add_shortcut(
-                        &shortcut_cfg.name,
-                        &full_exe,
-                        &start_dir,
-                        &shortcut_cfg.launch_options,
-                    ),
-                )
-                .await
-                {
-                    Ok(Ok(app_id)) => {
+                let mut created = false;
+                match added {
+                    Ok(Ok((mut cef, app_id))) => {
                         resp.app_id = app_id;
+                        created = true;
                         tracing::info!(
                             "Created shortcut '{}' with AppID {} (exe={})",
                             shortcut_cfg.name,
@@ -434,18 +1058,34 @@ impl TauriAgentHandler {
                             tracing::warn!("failed to set shortcut name: {e}");
                         }
 
-                        // On Linux, auto-set Proton for Windows executables.
-                        #[cfg(target_os = "linux")]
-                        if full_exe.to_lowercase().ends_with(".exe")
-                            && let Err(e) =
-                                cef.specify_compat_tool(app_id, "proton_experimental").await
+                        let compat_tool = compat_tool_for(&shortcut_cfg.compat_tool, &full_exe);
+                        if !compat_tool.is_empty()
+                            && let Some(w) = assign_compat_tool(&mut cef, app_id, compat_tool).await
                         {
-                            tracing::warn!("failed to set Proton compat tool: {e}");
+                            resp.warnings.push(w);
+                        }
+
+                        if shortcut_cfg.mark_recent {
+                            match cef.mark_recent(app_id).await {
+                                Ok(true) => tracing::info!("Marked AppID {app_id} as recent"),
+                                Ok(false) => resp.warnings.push(
+                                    "Steam does not support marking games as recent; \
+                                     it may not appear first in Recent"
+                                        .into(),
+                                ),
+                                Err(e) => resp
+                                    .warnings
+                                    .push(format!("failed to mark game as recent: {e}")),
+                            }
                         }
+                        cef.close().await;
 
                         // Track the shortcut in memory (VDF may not be flushed yet).
                         {
                             let mut tracked = self.state.tracked_shortcuts.lock().await;
+                            if let Some(old) = session.replace_app_id {
+                                tracked.retain(|s| s.app_id != old);
+                            }
                             tracked.push(TrackedShortcut {
                                 app_id,
                                 name: shortcut_cfg.name.clone(),
@@ -470,13 +1110,42 @@ impl TauriAgentHandler {
                     }
                 }
 
+                if shortcut_cfg.mark_recent && !created {
+                    resp.warnings.push(
+                        "shortcut was not created through Steam; not marked as recent".into(),
+                    );
+                }
+                if !shortcut_cfg.compat_tool.is_empty() && !created {
+                    resp.warnings.push(format!(
+                        "shortcut was not created through Steam; compatibility tool {} not set",
+                        shortcut_cfg.compat_tool
+                    ));
+                }
+
                 // Apply pending artwork using the real app_id from CEF.
                 let mut pending = self.state.pending_artwork.lock().await;
                 let artwork_items: Vec<_> = pending.drain(..).collect();
                 drop(pending);
 
+                let scope = if shortcut_cfg.artwork_all_users {
+                    capydeploy_steam::ArtworkScope::AllUsersWithShortcut
+                } else {
+                    capydeploy_steam::ArtworkScope::Owner
+                };
                 if !artwork_items.is_empty() {
-                    self.apply_pending_artwork(resp.app_id, artwork_items);
+                    self.apply_pending_artwork(resp.app_id, artwork_items, scope);
+                }
+                // SteamGridDB artwork comes as URLs for the agent to fetch.
+                if let Some(remote) = shortcut_cfg.artwork.take() {
+                    self.apply_remote_artwork(resp.app_id, remote, scope);
+                }
+
+                if !shortcut_cfg.boot_video.is_empty()
+                    && let Some(warning) =
+                        install_boot_video(&game_path, resp.app_id, &shortcut_cfg.boot_video)
+                {
+                    tracing::warn!("{warning}");
+                    resp.warnings.push(warning);
                 }
             }
         }
@@ -497,24 +1166,7 @@ impl TauriAgentHandler {
 
         let mut uploads = self.state.uploads.lock().await;
         if let Some(session) = uploads.remove(&req.upload_id) {
-            // Cancel TCP data channel if active.
-            if let Some(cancel) = &session.data_channel_cancel {
-                cancel.cancel();
-            }
-
-            let config = self.state.config.lock().await;
-            let base_path = expand_path(&config.install_path);
-            drop(config);
-            let game_path = PathBuf::from(&base_path).join(&session.game_name);
-
-            // Clean up partial files
-            if let Err(e) = std::fs::remove_dir_all(&game_path) {
-                tracing::warn!(
-                    "failed to clean up partial upload at {}: {e}",
-                    game_path.display()
-                );
-            }
-
+            let game_path = discard_session(&session);
             tracing::info!(
                 "Upload cancelled: {} (cleaned {})",
                 req.upload_id,
@@ -534,4 +1186,370 @@ impl TauriAgentHandler {
             let _ = sender.send_msg(reply);
         }
     }
+
+    /// Handles `pause_upload` and `resume_upload`. A paused session keeps
+    /// everything received so far.
+    pub(crate) async fn handle_set_upload_paused(
+        &self,
+        sender: Sender,
+        msg: Message,
+        paused: bool,
+    ) {
+        let req: messages::UploadControlRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let mut uploads = self.state.uploads.lock().await;
+        let Some(session) = uploads.get_mut(&req.upload_id) else {
+            let _ = sender.send_error(&msg, WS_ERR_CODE_NOT_FOUND, "upload not found");
+            return;
+        };
+        if paused {
+            session.pause();
+            // A paused upload may well outlive the agent.
+            session.state_saved_at = None;
+            save_staging_state(session);
+        } else {
+            session.resume();
+        }
+        drop(uploads);
+        tracing::info!(
+            "Upload {}: {}",
+            if paused { "paused" } else { "resumed" },
+            req.upload_id
+        );
+
+        let resp = messages::OperationResult {
+            success: true,
+            message: if paused { "paused" } else { "resumed" }.into(),
+        };
+        if let Ok(reply) = msg.reply(MessageType::OperationResult, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    /// Reports how far an upload got, so a Hub that reconnected mid-upload
+    /// can pick up where the agent is.
+    pub(crate) async fn handle_get_upload_status(&self, sender: Sender, msg: Message) {
+        let req: messages::GetUploadStatusRequest = match msg.parse_payload() {
+            Ok(Some(r)) => r,
+            _ => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+
+        let status = self
+            .state
+            .uploads
+            .lock()
+            .await
+            .get(&req.upload_id)
+            .map(|session| session.progress_event());
+
+        let Some(status) = status else {
+            let _ = sender.send_error(&msg, WS_ERR_CODE_NOT_FOUND, "upload not found");
+            return;
+        };
+        if let Ok(reply) = msg.reply(MessageType::UploadProgress, Some(&status)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    pub(crate) async fn handle_list_uploads(&self, sender: Sender, msg: Message) {
+        let resp = messages::UploadsResponse {
+            uploads: list_upload_sessions(&self.state).await,
+        };
+        if let Ok(reply) = msg.reply(MessageType::UploadsResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+
+    pub(crate) async fn handle_prune_uploads(&self, sender: Sender, msg: Message) {
+        let req: messages::PruneUploadsRequest = match msg.parse_payload() {
+            Ok(r) => r.unwrap_or_default(),
+            Err(_) => {
+                let _ = sender.send_error(&msg, 400, "invalid payload");
+                return;
+            }
+        };
+        let idle_secs = match req.idle_secs {
+            0 => DEFAULT_UPLOAD_PRUNE_IDLE_SECS,
+            n => n,
+        };
+
+        let resp = messages::PruneUploadsResponse {
+            pruned: prune_upload_sessions(&self.state, std::time::Duration::from_secs(idle_secs))
+                .await,
+        };
+        if let Ok(reply) = msg.reply(MessageType::PruneUploadsResponse, Some(&resp)) {
+            let _ = sender.send_msg(reply);
+        }
+    }
+}
+
+/// Stops a removed upload session and deletes its staging folder; an
+/// installed game it was updating is left alone. Returns the folder.
+fn discard_session(session: &UploadSession) -> PathBuf {
+    // Cancel TCP data channel if active.
+    if let Some(cancel) = &session.data_channel_cancel {
+        cancel.cancel();
+    }
+
+    // Clean up partial files
+    if let Err(e) = std::fs::remove_dir_all(&session.staging_dir) {
+        tracing::warn!(
+            "failed to clean up partial upload at {}: {e}",
+            session.staging_dir.display()
+        );
+    }
+    capydeploy_transfer::clear_staging_state(&session.staging_dir);
+    session.staging_dir.clone()
+}
+
+/// Saves how far `session` got beside its staging folder, so an upload of
+/// the same files after the agent lost the session resumes there. Saved
+/// at most every [`STAGING_STATE_INTERVAL`], off the async runtime; the
+/// staged files are synced to disk first and only synced bytes count.
+fn save_staging_state(session: &mut UploadSession) {
+    if session
+        .state_saved_at
+        .is_some_and(|at| at.elapsed() < STAGING_STATE_INTERVAL)
+    {
+        return;
+    }
+    session.state_saved_at = Some(std::time::Instant::now());
+    let dir = session.staging_dir.clone();
+    let files = session.files.clone();
+    let received: HashMap<String, i64> = session
+        .resume_offsets()
+        .into_iter()
+        .filter(|(path, _)| !session.skipped.contains(path))
+        .collect();
+    tokio::task::spawn_blocking(move || {
+        if let Err(e) = capydeploy_transfer::save_staging_state(&dir, &files, &received) {
+            tracing::warn!("failed to save upload state for {}: {e}", dir.display());
+        }
+    });
+}
+
+/// Upload sessions the agent holds, oldest first.
+pub(crate) async fn list_upload_sessions(state: &AgentState) -> Vec<messages::UploadSessionInfo> {
+    let mut sessions: Vec<_> = state
+        .uploads
+        .lock()
+        .await
+        .values()
+        .map(UploadSession::info)
+        .collect();
+    sessions.sort_by_key(|s| s.started_at);
+    sessions
+}
+
+/// Discards the upload sessions that received nothing for `idle`, freeing
+/// their upload slots and disk space. Paused sessions are kept. Returns
+/// the discarded sessions.
+pub(crate) async fn prune_upload_sessions(
+    state: &AgentState,
+    idle: std::time::Duration,
+) -> Vec<messages::UploadSessionInfo> {
+    let mut uploads = state.uploads.lock().await;
+    let stale: Vec<String> = uploads
+        .iter()
+        .filter(|(_, s)| !s.paused && s.last_activity.elapsed() >= idle)
+        .map(|(id, _)| id.clone())
+        .collect();
+
+    let mut pruned = Vec::with_capacity(stale.len());
+    for id in stale {
+        if let Some(session) = uploads.remove(&id) {
+            let game_path = discard_session(&session);
+            tracing::info!(
+                "Pruned idle upload: {id} (idle {}s, cleaned {})",
+                session.last_activity.elapsed().as_secs(),
+                game_path.display()
+            );
+            pruned.push(session.info());
+        }
+    }
+    let none_left = uploads.is_empty();
+    drop(uploads);
+
+    // Buffered artwork belongs to an upload; with none left it is orphaned.
+    if !pruned.is_empty() && none_left {
+        state.pending_artwork.lock().await.clear();
+    }
+    pruned
+}
+
+/// Installs the game's boot video as a Steam startup movie.
+///
+/// Returns a warning when the video can't be used; a bad video never fails
+/// the upload.
+/// Creates the games folder on a Steam library and registers the library
+/// with Steam so it shows up after the next restart. Returns the folder
+/// games are installed under.
+fn prepare_library(
+    scope: &capydeploy_file_ops::DirectoryScope,
+    library: &std::path::Path,
+) -> Result<PathBuf, String> {
+    let dir = scope.create(&library.join(STEAM_LIBRARY_GAMES_DIR))?;
+    let paths = capydeploy_steam::Paths::new().map_err(|e| e.to_string())?;
+    match capydeploy_steam::ensure_library_folder(&paths.library_folders_vdf_path(), library) {
+        Ok(true) => tracing::info!("registered Steam library {}", library.display()),
+        Ok(false) => {}
+        Err(e) => return Err(e.to_string()),
+    }
+    Ok(dir)
+}
+
+/// Resolves the game directory for a dry run the way `handle_init_upload`
+/// would, minus creating it, and measures what would be sent. Returns the
+/// preview and the files that would be skipped as unchanged.
+fn preview_target(
+    scope: &capydeploy_file_ops::DirectoryScope,
+    configured: &str,
+    config: &capydeploy_protocol::types::UploadConfig,
+    files: &[messages::FileEntry],
+    total_size: i64,
+) -> (messages::UploadPreview, Vec<String>) {
+    let mut preview = messages::UploadPreview {
+        transfer_bytes: total_size,
+        ..Default::default()
+    };
+    let base = if !config.library_path.is_empty() {
+        let dir = PathBuf::from(expand_path(&config.library_path)).join(STEAM_LIBRARY_GAMES_DIR);
+        scope
+            .check_create(&dir)
+            .map(|_| dir)
+            .map_err(|e| format!("invalid Steam library: {e}"))
+    } else {
+        match capydeploy_file_ops::upload_base(&expand_path(&config.install_path), configured) {
+            UploadBase::Configured(dir) => Ok(dir),
+            UploadBase::Requested(dir) => scope
+                .check_create(&dir)
+                .map(|_| dir)
+                .map_err(|e| format!("invalid install path: {e}")),
+        }
+    }
+    .and_then(|base| {
+        capydeploy_transfer::resolve_install_subpath(&base, &config.install_subpath)
+            .map_err(|e| format!("invalid install subfolder: {e}"))
+    });
+    let base = match base {
+        Ok(base) => base,
+        Err(message) => {
+            preview.blockers.push(messages::DeployBlocker {
+                code: DEPLOY_BLOCKER_INVALID_PATH.into(),
+                message,
+            });
+            return (preview, Vec::new());
+        }
+    };
+
+    let game_path = base.join(&config.game_name);
+    preview.game_path = game_path.to_string_lossy().into_owned();
+    if let Err(message) = capydeploy_file_ops::probe_writable(&game_path) {
+        preview.blockers.push(messages::DeployBlocker {
+            code: DEPLOY_BLOCKER_PATH_NOT_WRITABLE.into(),
+            message,
+        });
+    }
+
+    let existing = game_path.is_dir();
+    if existing && config.clean_install {
+        match check_game_directory(&preview.game_path) {
+            Ok(()) => preview.warnings.push(format!(
+                "everything in {} will be deleted first",
+                preview.game_path
+            )),
+            Err(e) => preview.blockers.push(messages::DeployBlocker {
+                code: DEPLOY_BLOCKER_INVALID_PATH.into(),
+                message: format!("cannot clean install: {e}"),
+            }),
+        }
+    }
+    let skip_files = if existing && !config.clean_install {
+        capydeploy_transfer::unchanged_files(&game_path, files)
+    } else {
+        Vec::new()
+    };
+    let skipped: HashSet<&str> = skip_files.iter().map(String::as_str).collect();
+    preview.transfer_bytes -= files
+        .iter()
+        .filter(|f| skipped.contains(f.relative_path.as_str()))
+        .map(|f| f.size)
+        .sum::<i64>();
+
+    preview.available_bytes = capydeploy_file_ops::available_space(&game_path);
+    if let Some(free) = preview.available_bytes
+        && free < preview.transfer_bytes.max(0) as u64
+    {
+        const GB: f64 = 1024.0 * 1024.0 * 1024.0;
+        preview.blockers.push(messages::DeployBlocker {
+            code: DEPLOY_BLOCKER_DISK_FULL.into(),
+            message: format!(
+                "{:.1} GB needed, {:.1} GB free in {}",
+                preview.transfer_bytes as f64 / GB,
+                free as f64 / GB,
+                preview.game_path
+            ),
+        });
+    }
+    (preview, skip_files)
+}
+
+/// Whether a shortcut with `shortcut_name` and `shortcut_exe` stands for
+/// the game `name` at `exe`. Steam stores the exe quoted.
+fn same_game(shortcut_name: &str, shortcut_exe: &str, name: &str, exe: &str) -> bool {
+    shortcut_name == name || (!exe.is_empty() && shortcut_exe.trim_matches('"') == exe)
+}
+
+/// Shortcuts in every Steam user's shortcuts.vdf.
+fn installed_shortcuts() -> Vec<capydeploy_protocol::types::ShortcutInfo> {
+    let Ok(paths) = capydeploy_steam::Paths::new() else {
+        return Vec::new();
+    };
+    let users = capydeploy_steam::get_users_with_paths(&paths).unwrap_or_default();
+    users
+        .iter()
+        .filter(|u| u.has_shortcuts)
+        .filter_map(|u| capydeploy_steam::load_shortcuts_vdf(&paths.shortcuts_path(&u.id)).ok())
+        .flatten()
+        .collect()
+}
+
+fn install_boot_video(game_path: &std::path::Path, app_id: u32, rel: &str) -> Option<String> {
+    let rel_path = std::path::Path::new(rel);
+    if rel_path.is_absolute()
+        || rel_path
+            .components()
+            .any(|c| matches!(c, std::path::Component::ParentDir))
+    {
+        return Some(format!(
+            "boot video skipped: path must be inside the game folder ({rel})"
+        ));
+    }
+
+    let paths = match capydeploy_steam::Paths::new() {
+        Ok(p) => p,
+        Err(e) => return Some(format!("boot video skipped: {e}")),
+    };
+    match capydeploy_steam::install_boot_video(&paths, app_id, &game_path.join(rel_path)) {
+        Ok(capydeploy_steam::BootVideoOutcome::Installed(dest)) => {
+            tracing::info!(
+                "Installed boot video for AppID {app_id} at {}",
+                dest.display()
+            );
+            None
+        }
+        Ok(capydeploy_steam::BootVideoOutcome::Skipped(reason)) => {
+            Some(format!("boot video skipped: {reason}"))
+        }
+        Err(e) => Some(format!("boot video not installed: {e}")),
+    }
 }
@@ -5,6 +5,26 @@
 /// - Must be within the user's home directory
 /// - Must be at least 2 levels deep from home (e.g. ~/Games/MyGame, not ~/Games)
 pub(crate) fn delete_game_directory(path: &str) -> Result<(), String> {
+    check_game_directory(path)?;
+    let abs_path = std::path::Path::new(path);
+
+    match std::fs::metadata(abs_path) {
+        Ok(meta) if meta.is_dir() => {}
+        Ok(_) => {
+            return Err(format!("path is not a directory: {}", abs_path.display()));
+        }
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(()),
+        Err(e) => {
+            return Err(format!("cannot stat path: {e}"));
+        }
+    }
+
+    std::fs::remove_dir_all(abs_path).map_err(|e| format!("failed to remove directory: {e}"))
+}
+
+/// Checks that `path` is a place [`delete_game_directory`] may remove,
+/// without touching it.
+pub(crate) fn check_game_directory(path: &str) -> Result<(), String> {
     let abs_path = std::path::Path::new(path);
     if !abs_path.is_absolute() {
         return Err(format!("refusing to delete relative path: {path}"));
@@ -32,17 +52,5 @@ pub(crate) fn delete_game_directory(path: &str) -> Result<(), String> {
             abs_path.display()
         ));
     }
-
-    match std::fs::metadata(abs_path) {
-        Ok(meta) if meta.is_dir() => {}
-        Ok(_) => {
-            return Err(format!("path is not a directory: {}", abs_path.display()));
-        }
-        Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(()),
-        Err(e) => {
-            return Err(format!("cannot stat path: {e}"));
-        }
-    }
-
-    std::fs::remove_dir_all(abs_path).map_err(|e| format!("failed to remove directory: {e}"))
+    Ok(())
 }
@@ -0,0 +1,156 @@
+//! Opens the agent's ports in the host firewall on Linux.
+//!
+//! Distros such as Bazzite ship firewalld enabled, which silently blocks
+//! the OS-assigned WebSocket port and mDNS. firewalld (via `firewall-cmd`)
+//! and ufw are supported; rules are runtime-only where the tool allows it
+//! and removed again on shutdown. Everything here is best effort: a
+//! missing tool or missing privileges only logs a warning.
+
+use tokio::process::Command;
+
+/// mDNS multicast port used for discovery.
+const MDNS_PORT: u16 = 5353;
+
+/// Comment tagging ufw rules added by the agent.
+const UFW_COMMENT: &str = "capydeploy-agent";
+
+#[derive(Debug, Clone, Copy)]
+enum Backend {
+    Firewalld,
+    Ufw,
+}
+
+/// Firewall rules added by the agent, to remove on shutdown.
+#[derive(Debug)]
+pub(crate) struct FirewallRules {
+    backend: Backend,
+    /// Rules as `port/proto`, only those that weren't already open.
+    added: Vec<String>,
+}
+
+/// Opens `port`/tcp and the mDNS port in the active firewall. Returns
+/// `None` when no supported firewall is active or nothing was added.
+pub(crate) async fn ensure_rules(port: u16) -> Option<FirewallRules> {
+    let backend = detect_backend().await?;
+    let wanted = [format!("{port}/tcp"), format!("{MDNS_PORT}/udp")];
+
+    let mut added = Vec::new();
+    for rule in wanted {
+        match add_rule(backend, &rule).await {
+            Ok(true) => {
+                tracing::info!(?backend, rule = %rule, "opened firewall port");
+                added.push(rule);
+            }
+            Ok(false) => tracing::debug!(?backend, rule = %rule, "firewall port already open"),
+            Err(e) => tracing::warn!(
+                ?backend,
+                rule = %rule,
+                "could not open firewall port, Hubs may not reach the agent: {e}"
+            ),
+        }
+    }
+
+    (!added.is_empty()).then_some(FirewallRules { backend, added })
+}
+
+impl FirewallRules {
+    /// Removes the rules added by [`ensure_rules`].
+    pub(crate) async fn remove(self) {
+        for rule in &self.added {
+            match remove_rule(self.backend, rule).await {
+                Ok(()) => {
+                    tracing::info!(backend = ?self.backend, rule = %rule, "closed firewall port")
+                }
+                Err(e) => tracing::warn!(
+                    backend = ?self.backend,
+                    rule = %rule,
+                    "failed to close firewall port: {e}"
+                ),
+            }
+        }
+    }
+}
+
+/// Finds the running firewall. firewalld wins when both are installed, as
+/// ufw is then usually inactive.
+async fn detect_backend() -> Option<Backend> {
+    if let Ok(out) = run("firewall-cmd", &["--state"]).await
+        && out.status.success()
+    {
+        return Some(Backend::Firewalld);
+    }
+    match run("ufw", &["status"]).await {
+        Ok(out) if out.status.success() => {
+            let stdout = String::from_utf8_lossy(&out.stdout);
+            stdout
+                .lines()
+                .any(|l| l.trim() == "Status: active")
+                .then_some(Backend::Ufw)
+        }
+        Ok(out) => {
+            // ufw refuses `status` without root, so the state is unknown.
+            tracing::warn!(
+                "ufw status failed, firewall ports not managed: {}",
+                String::from_utf8_lossy(&out.stderr).trim()
+            );
+            None
+        }
+        Err(_) => {
+            tracing::debug!("no supported firewall found (firewalld, ufw)");
+            None
+        }
+    }
+}
+
+/// Adds a rule. Returns `false` when it was already present, so it isn't
+/// removed on shutdown.
+async fn add_rule(backend: Backend, rule: &str) -> Result<bool, String> {
+    match backend {
+        Backend::Firewalld => {
+            if run("firewall-cmd", &[&format!("--query-port={rule}")])
+                .await
+                .is_ok_and(|out| out.status.success())
+            {
+                return Ok(false);
+            }
+            // Runtime only, so a rule left behind by a crash goes away on
+            // the next reload or reboot.
+            let out = run("firewall-cmd", &[&format!("--add-port={rule}")]).await?;
+            check(&out)?;
+            Ok(true)
+        }
+        Backend::Ufw => {
+            let out = run("ufw", &["allow", rule, "comment", UFW_COMMENT]).await?;
+            check(&out)?;
+            Ok(!String::from_utf8_lossy(&out.stdout).contains("Skipping"))
+        }
+    }
+}
+
+async fn remove_rule(backend: Backend, rule: &str) -> Result<(), String> {
+    let out = match backend {
+        Backend::Firewalld => run("firewall-cmd", &[&format!("--remove-port={rule}")]).await?,
+        Backend::Ufw => run("ufw", &["delete", "allow", rule]).await?,
+    };
+    check(&out)
+}
+
+async fn run(program: &str, args: &[&str]) -> Result<std::process::Output, String> {
+    Command::new(program)
+        .args(args)
+        .stdin(std::process::Stdio::null())
+        .output()
+        .await
+        .map_err(|e| format!("failed to run {program}: {e}"))
+}
+
+fn check(out: &std::process::Output) -> Result<(), String> {
+    if out.status.success() {
+        return Ok(());
+    }
+    let stderr = String::from_utf8_lossy(&out.stderr);
+    Err(match stderr.trim() {
+        "" => format!("exited with {}", out.status),
+        msg => msg.to_string(),
+    })
+}
@@ -1,11 +1,13 @@
 pub(crate) mod artwork_utils;
 pub(crate) mod file_ops;
+#[cfg(target_os = "linux")]
+pub(crate) mod firewall;
 pub(crate) mod identity;
 pub(crate) mod network;
 pub(crate) mod paths;
 
 pub(crate) use artwork_utils::{ext_from_content_type, parse_artwork_type};
-pub(crate) use file_ops::delete_game_directory;
+pub(crate) use file_ops::{check_game_directory, delete_game_directory};
 pub(crate) use identity::generate_agent_id;
 pub(crate) use network::local_ips;
 pub(crate) use paths::expand_path;
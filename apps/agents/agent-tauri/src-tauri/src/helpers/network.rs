@@ -1,22 +1,7 @@
-/// Returns local non-loopback IPv4 addresses, excluding link-local (169.254.x.x).
+/// Returns the local addresses Hubs can reach, IPv4 first.
 pub(crate) fn local_ips() -> Vec<String> {
-    if_addrs::get_if_addrs()
-        .unwrap_or_default()
-        .into_iter()
-        .filter_map(|iface| {
-            if iface.is_loopback() {
-                return None;
-            }
-            match iface.addr.ip() {
-                std::net::IpAddr::V4(ip) => {
-                    // Skip link-local (169.254.x.x)
-                    if ip.octets()[0] == 169 && ip.octets()[1] == 254 {
-                        return None;
-                    }
-                    Some(ip.to_string())
-                }
-                _ => None,
-            }
-        })
+    capydeploy_discovery::get_local_ips()
+        .iter()
+        .map(|ip| ip.to_string())
         .collect()
 }
@@ -1,3 +1,4 @@
+mod artwork_retry;
 mod auth;
 mod commands;
 mod config;
@@ -5,28 +6,23 @@ mod events;
 mod handler;
 mod handlers;
 mod helpers;
+mod logging;
 mod state;
 mod types;
 
 use std::collections::HashMap;
 use std::sync::Arc;
-use std::sync::atomic::AtomicBool;
+use std::sync::atomic::{AtomicBool, AtomicI64};
 
 use capydeploy_protocol::constants::MessageType;
 use capydeploy_protocol::envelope::Message;
 use tokio_util::sync::CancellationToken;
-use tracing_subscriber::EnvFilter;
 
 use config::AgentConfig;
 use state::AgentState;
 
 pub fn run() {
-    tracing_subscriber::fmt()
-        .with_env_filter(
-            EnvFilter::try_from_default_env()
-                .unwrap_or_else(|_| EnvFilter::new("info,capydeploy=debug")),
-        )
-        .init();
+    let (log_filter, log_stream) = logging::init();
 
     let cfg = AgentConfig::load().unwrap_or_default();
 
@@ -98,15 +94,42 @@ pub fn run() {
 
     let shutdown_token = CancellationToken::new();
 
+    let max_binary_frame_size = match cfg.max_binary_frame_size {
+        0 => capydeploy_protocol::constants::default_binary_frame_limit(
+            capydeploy_telemetry::total_memory_bytes(),
+        ),
+        n => capydeploy_protocol::constants::binary_frame_limit(n),
+    };
+    tracing::info!(max_binary_frame_size, "binary frame limit");
+
+    let mut auth = auth::AuthManager::new();
+    auth.set_allowlist(cfg.pairing_allowlist());
+
     let agent_state = AgentState {
         accept_connections: Arc::new(AtomicBool::new(true)),
         telemetry_enabled: Arc::new(AtomicBool::new(cfg.telemetry_enabled)),
         console_log_enabled: Arc::new(AtomicBool::new(cfg.console_log_enabled)),
         connected_hub: Arc::new(tokio::sync::Mutex::new(None)),
         server_port: Arc::new(tokio::sync::Mutex::new(0)),
+        server_started_at: Arc::new(AtomicI64::new(0)),
         uploads: Arc::new(tokio::sync::Mutex::new(HashMap::new())),
+        upload_limiter: capydeploy_transfer::UploadLimiter::new(
+            capydeploy_protocol::constants::DEFAULT_MAX_CONCURRENT_UPLOADS as usize,
+        ),
+        max_binary_frame_size,
+        verbose: Arc::new(AtomicBool::new(false)),
+        log_filter,
+        log_stream,
         pending_artwork: Arc::new(tokio::sync::Mutex::new(Vec::new())),
-        auth: Arc::new(tokio::sync::Mutex::new(auth::AuthManager::new())),
+        artwork_retry: Arc::new(artwork_retry::ArtworkRetryQueue::open(
+            config::agent_dir()
+                .unwrap_or_else(|_| std::env::temp_dir().join("capydeploy-agent"))
+                .join("artwork-retry"),
+        )),
+        artwork_chunks: Arc::new(tokio::sync::Mutex::new(
+            capydeploy_transfer::ArtworkAssembler::new(),
+        )),
+        auth: Arc::new(tokio::sync::Mutex::new(auth)),
         config: Arc::new(tokio::sync::Mutex::new(cfg)),
         hub_sender,
         telemetry_collector,
@@ -160,8 +183,13 @@ pub fn run() {
             // Auth
             commands::auth::get_authorized_hubs,
             commands::auth::revoke_hub,
+            commands::auth::get_hub_allowlist,
+            commands::auth::set_hub_allowlist,
             // Files
             commands::files::select_install_path,
+            // Uploads
+            commands::uploads::list_uploads,
+            commands::uploads::prune_uploads,
         ])
         .build(tauri::generate_context!())
         .expect("error building tauri application");
@@ -0,0 +1,306 @@
+//! Tracing setup with a runtime switch for verbose logging, plus the
+//! stream that forwards log lines to a subscribed Hub.
+
+use std::cell::Cell;
+use std::collections::VecDeque;
+use std::fmt::{self, Write};
+use std::sync::{Arc, Mutex};
+
+use capydeploy_agent_server::Sender;
+use capydeploy_protocol::constants::MessageType;
+use capydeploy_protocol::envelope::Message;
+use capydeploy_protocol::messages::AgentLogLine;
+use tracing::field::{Field, Visit};
+use tracing::{Event, Subscriber};
+use tracing_subscriber::layer::Context;
+use tracing_subscriber::prelude::*;
+use tracing_subscriber::{EnvFilter, Layer, Registry, reload};
+
+/// Filter used unless `RUST_LOG` is set.
+const DEFAULT_FILTER: &str = "info,capydeploy=debug";
+
+/// Filter while verbose logging is on (overrides `RUST_LOG`).
+const VERBOSE_FILTER: &str = "debug,capydeploy=trace";
+
+/// Recent lines kept for a Hub that subscribes after the fact.
+pub const LOG_BACKLOG: usize = 500;
+
+/// Keys whose values never leave the agent. `bearer` is followed by a
+/// space, the others by `=` or `:`.
+const SECRET_KEYS: &[&str] = &[
+    "token",
+    "password",
+    "secret",
+    "api_key",
+    "apikey",
+    "authorization",
+    "bearer",
+];
+
+const REDACTED: &str = "[redacted]";
+
+/// Handle for swapping the active log filter.
+pub struct LogFilter {
+    handle: reload::Handle<EnvFilter, Registry>,
+}
+
+impl LogFilter {
+    /// Switches between the verbose and the normal filter.
+    pub fn set_verbose(&self, verbose: bool) {
+        let filter = if verbose {
+            EnvFilter::new(VERBOSE_FILTER)
+        } else {
+            base_filter()
+        };
+        if let Err(e) = self.handle.reload(filter) {
+            tracing::warn!("failed to change log filter: {e}");
+        }
+    }
+}
+
+/// Installs the global subscriber and returns its filter handle and the
+/// stream Hubs subscribe to.
+pub fn init() -> (LogFilter, Arc<LogStream>) {
+    let (filter, handle) = reload::Layer::new(base_filter());
+    let stream = Arc::new(LogStream::default());
+    tracing_subscriber::registry()
+        .with(filter)
+        .with(tracing_subscriber::fmt::layer())
+        .with(HubLogLayer {
+            stream: stream.clone(),
+        })
+        .init();
+    (LogFilter { handle }, stream)
+}
+
+fn base_filter() -> EnvFilter {
+    EnvFilter::try_from_default_env().unwrap_or_else(|_| EnvFilter::new(DEFAULT_FILTER))
+}
+
+/// Recent log lines and the Hub, if any, that receives new ones.
+#[derive(Default)]
+pub struct LogStream {
+    inner: Mutex<LogStreamInner>,
+}
+
+#[derive(Default)]
+struct LogStreamInner {
+    recent: VecDeque<AgentLogLine>,
+    subscriber: Option<Sender>,
+}
+
+impl LogStream {
+    /// Streams new lines to `sender` and returns up to `backlog` recent
+    /// ones, oldest first. Replaces any previous subscriber.
+    pub fn subscribe(&self, sender: Sender, backlog: usize) -> Vec<AgentLogLine> {
+        let mut inner = self.inner.lock().unwrap();
+        inner.subscriber = Some(sender);
+        let skip = inner.recent.len().saturating_sub(backlog);
+        inner.recent.iter().skip(skip).cloned().collect()
+    }
+
+    /// Stops streaming; the backlog keeps filling.
+    pub fn unsubscribe(&self) {
+        self.inner.lock().unwrap().subscriber = None;
+    }
+
+    fn push(&self, line: AgentLogLine) {
+        let mut inner = self.inner.lock().unwrap();
+        if let Some(sender) = &inner.subscriber {
+            let id = uuid::Uuid::new_v4().to_string();
+            if let Ok(msg) = Message::new(id, MessageType::LogLine, Some(&line)) {
+                // A closed or full channel drops the line; the next
+                // subscribe gets it from the backlog.
+                let _ = sender.send_msg(msg);
+            }
+        }
+        if inner.recent.len() == LOG_BACKLOG {
+            inner.recent.pop_front();
+        }
+        inner.recent.push_back(line);
+    }
+}
+
+thread_local! {
+    /// Set while a line is being recorded, so logging done by the send
+    /// path itself doesn't recurse.
+    static RECORDING: Cell<bool> = const { Cell::new(false) };
+}
+
+/// Tracing layer feeding [`LogStream`].
+struct HubLogLayer {
+    stream: Arc<LogStream>,
+}
+
+impl<S: Subscriber> Layer<S> for HubLogLayer {
+    fn on_event(&self, event: &Event<'_>, _ctx: Context<'_, S>) {
+        if RECORDING.with(|r| r.replace(true)) {
+            return;
+        }
+
+        let mut visitor = LineVisitor::default();
+        event.record(&mut visitor);
+        let meta = event.metadata();
+        let line = AgentLogLine {
+            timestamp: chrono::Utc::now().timestamp_millis(),
+            level: meta.level().as_str().to_ascii_lowercase(),
+            target: meta.target().to_string(),
+            message: redact(&(visitor.message + &visitor.fields)),
+        };
+        self.stream.push(line);
+
+        RECORDING.with(|r| r.set(false));
+    }
+}
+
+/// Renders an event as `message key=value ...`, dropping the values of
+/// secret-looking fields.
+#[derive(Default)]
+struct LineVisitor {
+    message: String,
+    fields: String,
+}
+
+impl Visit for LineVisitor {
+    fn record_debug(&mut self, field: &Field, value: &dyn fmt::Debug) {
+        let name = field.name();
+        if name == "message" {
+            let _ = write!(self.message, "{value:?}");
+        } else if is_secret_key(name) {
+            let _ = write!(self.fields, " {name}={REDACTED}");
+        } else {
+            let _ = write!(self.fields, " {name}={value:?}");
+        }
+    }
+}
+
+fn is_secret_key(name: &str) -> bool {
+    let name = name.to_ascii_lowercase();
+    SECRET_KEYS.iter().any(|k| name.contains(k))
+}
+
+/// Replaces the values following secret keys (`token=...`, `"token":"..."`,
+/// `Bearer ...`) with a placeholder.
+fn redact(text: &str) -> String {
+    let lower = text.to_ascii_lowercase();
+    let bytes = lower.as_bytes();
+    let mut out = String::with_capacity(text.len());
+    let mut copied = 0;
+
+    let mut i = 0;
+    while i < bytes.len() {
+        let Some(key) = SECRET_KEYS
+            .iter()
+            .find(|k| bytes[i..].starts_with(k.as_bytes()))
+        else {
+            i += 1;
+            continue;
+        };
+        let mut j = i + key.len();
+        i = j;
+
+        if *key == "bearer" {
+            if j >= bytes.len() || bytes[j] != b' ' {
+                continue;
+            }
+        } else {
+            while j < bytes.len() && matches!(bytes[j], b'"' | b'\'' | b' ') {
+                j += 1;
+            }
+            if j >= bytes.len() || !matches!(bytes[j], b'=' | b':') {
+                continue;
+            }
+            j += 1;
+        }
+        while j < bytes.len() && matches!(bytes[j], b'"' | b'\'' | b' ') {
+            j += 1;
+        }
+
+        let start = j;
+        j = value_end(bytes, j);
+        // `Authorization: Bearer xyz`: the credential is the next word.
+        if matches!(&lower[start..j], "bearer" | "basic") && bytes.get(j) == Some(&b' ') {
+            j = value_end(bytes, j + 1);
+        }
+        if j > start {
+            out.push_str(&text[copied..start]);
+            out.push_str(REDACTED);
+            copied = j;
+        }
+        i = j;
+    }
+    out.push_str(&text[copied..]);
+    out
+}
+
+fn value_end(bytes: &[u8], mut j: usize) -> usize {
+    while j < bytes.len()
+        && !matches!(
+            bytes[j],
+            b' ' | b'"' | b'\'' | b',' | b'}' | b'&' | b';' | b'\n'
+        )
+    {
+        j += 1;
+    }
+    j
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn redacts_key_value_pairs() {
+        assert_eq!(
+            redact("hub accepted token=abc123 from 10.0.0.2"),
+            "hub accepted token=[redacted] from 10.0.0.2"
+        );
+        assert_eq!(
+            redact(r#"payload {"token":"abc","name":"Deck"}"#),
+            r#"payload {"token":"[redacted]","name":"Deck"}"#
+        );
+        assert_eq!(redact("renewed_token: xyz"), "renewed_token: [redacted]");
+        assert_eq!(
+            redact("Authorization: Bearer eyJhbGci"),
+            "Authorization: [redacted]"
+        );
+        assert_eq!(redact("sent Bearer eyJhbGci"), "sent Bearer [redacted]");
+        assert_eq!(redact("API_KEY=k&other=1"), "API_KEY=[redacted]&other=1");
+    }
+
+    #[test]
+    fn leaves_other_text_alone() {
+        for text in [
+            "tokens reset after corruption",
+            "token expired, pairing again",
+            "copied 3 files to /home/deck/Games/ñandú",
+            "",
+        ] {
+            assert_eq!(redact(text), text);
+        }
+    }
+
+    #[test]
+    fn secret_field_names() {
+        assert!(is_secret_key("token"));
+        assert!(is_secret_key("hub_token"));
+        assert!(is_secret_key("SteamGridDB_API_Key"));
+        assert!(!is_secret_key("path"));
+    }
+
+    #[test]
+    fn backlog_keeps_the_newest_lines() {
+        let stream = LogStream::default();
+        for n in 0..LOG_BACKLOG + 10 {
+            stream.push(AgentLogLine {
+                timestamp: n as i64,
+                level: "info".into(),
+                target: String::new(),
+                message: format!("line {n}"),
+            });
+        }
+        let inner = stream.inner.lock().unwrap();
+        assert_eq!(inner.recent.len(), LOG_BACKLOG);
+        assert_eq!(inner.recent.front().unwrap().timestamp, 10);
+    }
+}
@@ -1,10 +1,13 @@
-use std::collections::{HashMap, HashSet};
+use std::collections::{BTreeMap, HashMap, HashSet};
 use std::sync::Arc;
-use std::sync::atomic::AtomicBool;
+use std::sync::atomic::{AtomicBool, AtomicI64};
 
 use tokio::sync::Mutex;
 use tokio_util::sync::CancellationToken;
 
+use capydeploy_protocol::messages::FileEntry;
+use capydeploy_protocol::profile::ProtocolProfile;
+
 use crate::auth::AuthManager;
 use crate::config::AgentConfig;
 use crate::handlers::filesystem::FsSandbox;
@@ -16,8 +19,26 @@ pub struct AgentState {
     pub accept_connections: Arc<AtomicBool>,
     pub connected_hub: Arc<Mutex<Option<ConnectedHubInfo>>>,
     pub server_port: Arc<Mutex<u16>>,
+    /// When the WS server bound its port, in Unix milliseconds; reported
+    /// in `AgentInfo` as the agent's start time.
+    pub server_started_at: Arc<AtomicI64>,
     pub uploads: Arc<Mutex<HashMap<String, UploadSession>>>,
+    /// Caps concurrent upload sessions; advertised in `AgentInfo`.
+    pub upload_limiter: capydeploy_transfer::UploadLimiter,
+    /// Largest binary frame read from the Hub; advertised in
+    /// `AgentStatusResponse` so the Hub chunks below it.
+    pub max_binary_frame_size: usize,
+    /// Verbose logging, toggled by the Hub via `set_verbose`; shared with
+    /// the WS server. Not persisted.
+    pub verbose: Arc<AtomicBool>,
+    pub log_filter: crate::logging::LogFilter,
+    /// Recent log lines, streamed to the Hub while it's subscribed.
+    pub log_stream: Arc<crate::logging::LogStream>,
     pub pending_artwork: Arc<Mutex<Vec<PendingArtwork>>>,
+    /// Artwork that failed to apply, retried once CEF answers.
+    pub artwork_retry: Arc<crate::artwork_retry::ArtworkRetryQueue>,
+    /// Chunked artwork images still being received.
+    pub artwork_chunks: Arc<Mutex<capydeploy_transfer::ArtworkAssembler>>,
     pub telemetry_enabled: Arc<AtomicBool>,
     pub console_log_enabled: Arc<AtomicBool>,
     /// WS sender to the connected Hub (sync Mutex for use in sync callbacks).
@@ -57,6 +78,10 @@ pub struct ConnectedHubInfo {
     pub id: String,
     pub name: String,
     pub ip: String,
+    /// When the Hub connected, in Unix milliseconds.
+    pub connected_since: i64,
+    /// Protocol settings negotiated with this Hub.
+    pub profile: ProtocolProfile,
 }
 
 /// An active upload session.
@@ -64,18 +89,52 @@ pub struct ConnectedHubInfo {
 pub struct UploadSession {
     pub id: String,
     pub game_name: String,
+    /// Resolved base directory the game is installed under.
     pub install_path: String,
     pub executable: String,
     pub total_size: i64,
     pub transferred: i64,
     pub current_file: String,
+    /// Upload manifest, in the order the Hub sends the files.
+    pub files: Vec<FileEntry>,
+    /// Bytes written per file, from its start. Reported to a Hub resuming
+    /// the upload.
+    pub received: HashMap<String, i64>,
+    /// Chunks written past a gap in `received`, by file and start offset,
+    /// with their end. Merged into `received` once the gap fills.
+    pub pending: HashMap<String, BTreeMap<i64, i64>>,
+    /// Files already installed unchanged, which the Hub doesn't send.
+    pub skipped: HashSet<String>,
+    /// Codec the Hub compresses binary chunks with.
+    pub compression: capydeploy_transfer::Compression,
     pub active: bool,
+    /// Held by the Hub with `pause_upload`: chunks are refused and the
+    /// session is never pruned as idle.
+    pub paused: bool,
     /// Last progress percentage emitted to the Hub (for throttling).
     pub last_progress_pct: f64,
     /// Last time a progress event was emitted (time-based safety net).
     pub last_progress_time: std::time::Instant,
+    /// When the session was created, in Unix milliseconds.
+    pub started_at: i64,
+    /// Last time data arrived or the Hub resumed the session.
+    pub last_activity: std::time::Instant,
     /// Cancel token for an active TCP data channel (None if using WS).
     pub data_channel_cancel: Option<tokio_util::sync::CancellationToken>,
+    /// Upload slot held for the session's lifetime.
+    pub permit: capydeploy_transfer::UploadPermit,
+    /// Existing shortcut the Hub asked to overwrite; removed when the new
+    /// one is created.
+    pub replace_app_id: Option<u32>,
+    /// Where files are written until the upload completes and they are
+    /// moved into the game folder.
+    pub staging_dir: std::path::PathBuf,
+    /// Replace the game folder instead of merging into it.
+    pub clean_install: bool,
+    /// Speed of the transfer, for the progress events.
+    pub throughput: capydeploy_transfer::ThroughputMeter,
+    /// When progress was last saved beside the staging folder.
+    pub state_saved_at: Option<std::time::Instant>,
 }
 
 impl UploadSession {
@@ -85,6 +144,142 @@ impl UploadSession {
         }
         self.transferred as f64 / self.total_size as f64 * 100.0
     }
+
+    /// Records a binary chunk of `len` bytes written at `offset`. Chunks
+    /// can arrive out of order when the Hub pipelines them; one past a gap
+    /// is held until the gap fills, as only the contiguous prefix of each
+    /// file is safe to resume from.
+    pub fn record_chunk(&mut self, file: &str, offset: i64, len: i64) {
+        self.last_activity = std::time::Instant::now();
+        let received = self.received.entry(file.to_string()).or_insert(0);
+        if offset > *received {
+            self.pending
+                .entry(file.to_string())
+                .or_default()
+                .insert(offset, offset + len);
+            return;
+        }
+        *received = (*received).max(offset + len);
+        if let Some(pending) = self.pending.get_mut(file) {
+            while let Some(entry) = pending.first_entry() {
+                if *entry.key() > *received {
+                    break;
+                }
+                *received = (*received).max(entry.remove());
+            }
+            if pending.is_empty() {
+                self.pending.remove(file);
+            }
+        }
+    }
+
+    /// Bytes of `file` received so far and its size in the manifest.
+    pub fn file_progress(&self, file: &str) -> (i64, i64) {
+        let received = self.received.get(file).copied().unwrap_or(0);
+        let size = self
+            .files
+            .iter()
+            .find(|f| f.relative_path == file)
+            .map_or(0, |f| f.size);
+        (received, size)
+    }
+
+    /// Total size of the skipped files.
+    pub fn skipped_bytes(&self) -> i64 {
+        self.files
+            .iter()
+            .filter(|f| self.skipped.contains(&f.relative_path))
+            .map(|f| f.size)
+            .sum()
+    }
+
+    /// Records data channel progress: `total` bytes streamed so far, with
+    /// `file` being written. Files stream whole and in manifest order, so
+    /// the ones before `file` are complete. Skipped files aren't streamed.
+    pub fn record_streamed(&mut self, total: i64, file: &str) {
+        self.last_activity = std::time::Instant::now();
+        let mut before = 0;
+        for entry in &self.files {
+            if self.skipped.contains(&entry.relative_path) {
+                continue;
+            }
+            if entry.relative_path == file {
+                let written = (total - before).clamp(0, entry.size);
+                self.received.insert(file.to_string(), written);
+                return;
+            }
+            self.received
+                .insert(entry.relative_path.clone(), entry.size);
+            before += entry.size;
+        }
+    }
+
+    pub fn pause(&mut self) {
+        self.paused = true;
+        self.throughput.restart();
+    }
+
+    /// Lifts a pause. The idle clock and the speed measurement restart, as
+    /// a resumed session is about to receive data again.
+    pub fn resume(&mut self) {
+        self.paused = false;
+        self.last_activity = std::time::Instant::now();
+        self.throughput.restart();
+    }
+
+    /// Reopens a completed session whose `files` failed verification.
+    /// They count as not received, so the Hub can send them again.
+    pub fn reopen_for<'a>(&mut self, files: impl IntoIterator<Item = &'a str>) {
+        for file in files {
+            self.received.remove(file);
+            self.pending.remove(file);
+            self.skipped.remove(file);
+        }
+        self.transferred = self.received.values().sum();
+        self.active = true;
+        self.data_channel_cancel = None;
+        self.last_activity = std::time::Instant::now();
+        self.throughput.restart();
+    }
+
+    /// Progress of the upload, with `current_file` as the file in flight.
+    pub fn progress_event(&self) -> capydeploy_protocol::messages::UploadProgressEvent {
+        let (file_transferred, file_total) = self.file_progress(&self.current_file);
+        capydeploy_protocol::messages::UploadProgressEvent {
+            upload_id: self.id.clone(),
+            transferred_bytes: self.transferred,
+            total_bytes: self.total_size,
+            current_file: self.current_file.clone(),
+            percentage: self.percentage(),
+            file_transferred_bytes: file_transferred,
+            file_total_bytes: file_total,
+            bytes_per_sec: self.throughput.bytes_per_sec(),
+            estimated_seconds_remaining: self
+                .throughput
+                .eta_secs(self.total_size - self.transferred),
+        }
+    }
+
+    /// Summary for `list_uploads`.
+    pub fn info(&self) -> capydeploy_protocol::messages::UploadSessionInfo {
+        capydeploy_protocol::messages::UploadSessionInfo {
+            upload_id: self.id.clone(),
+            game_name: self.game_name.clone(),
+            percentage: self.percentage(),
+            started_at: self.started_at,
+            idle_secs: self.last_activity.elapsed().as_secs(),
+            paused: self.paused,
+        }
+    }
+
+    /// Bytes to skip per file when the Hub resumes the upload.
+    pub fn resume_offsets(&self) -> HashMap<String, i64> {
+        self.received
+            .iter()
+            .filter(|&(_, &offset)| offset > 0)
+            .map(|(path, &offset)| (path.clone(), offset))
+            .collect()
+    }
 }
 
 /// Artwork data buffered until shortcut creation provides a real AppID.
@@ -40,6 +40,23 @@ pub struct AuthorizedHubDto {
     pub last_seen: String,
 }
 
+/// Pairing allowlist settings for the frontend.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct HubAllowlistDto {
+    pub enabled: bool,
+    pub hub_ids: Vec<String>,
+}
+
+/// Pairing attempts that look like code guessing (`pairing:suspicious`).
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SuspiciousPairingDto {
+    pub hub_name: String,
+    pub ip: String,
+    pub reason: String,
+}
+
 /// Version info DTO.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
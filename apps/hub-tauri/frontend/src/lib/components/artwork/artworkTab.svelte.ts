@@ -1,4 +1,5 @@
 import type { GridData, ImageData, ImageFilters } from '$lib/types';
+import { GetCachedArtwork, PrefetchArtwork } from '$lib/wailsjs';
 
 // --- Types ---
 
@@ -67,12 +68,27 @@ export const TAB_CONFIGS: ArtworkTabConfig[] = [
 	}
 ];
 
+/** SteamGridDB artwork type fetched by each tab (used for prefetch). */
+const SGDB_ART_TYPE: Record<ArtworkTabId, string> = {
+	capsule: 'grid',
+	wide: 'grid',
+	hero: 'hero',
+	logo: 'logo',
+	icon: 'icon'
+};
+
 // --- Helpers ---
 
 export function isAnimatedThumb(thumb: string): boolean {
 	return thumb?.includes('.webm') || false;
 }
 
+/** Thumbnail source: the Hub's cached copy (e.g. from prefetch) when it has one, else the remote URL. */
+export async function thumbSrc(url: string, gameID: number): Promise<string> {
+	if (!gameID) return url;
+	return (await GetCachedArtwork(url, gameID).catch(() => null)) || url;
+}
+
 // --- Composable ---
 
 export function createArtworkTab(
@@ -98,6 +114,10 @@ export function createArtworkTab(
 		hasMore = loaded.length >= 50;
 		const animCount = filtered.filter((p) => isAnimatedThumb(p.thumb)).length;
 		page++;
+		if (hasMore) {
+			// Warm the Hub's image cache with the next page while the user browses.
+			PrefetchArtwork(gameID, SGDB_ART_TYPE[config.id], filters, page).catch(() => {});
+		}
 		return `Loaded ${filtered.length} ${config.label.toLowerCase()}s${animCount ? ` (${animCount} animated)` : ''}`;
 	}
 
@@ -4,6 +4,7 @@ import type { ConnectionStatus } from '$lib/types';
 function createConnectionStore() {
 	const { subscribe, set, update } = writable<ConnectionStatus>({
 		connected: false,
+		state: 'disconnected',
 		agentId: '',
 		agentName: '',
 		platform: '',
@@ -11,7 +12,10 @@ function createConnectionStore() {
 		port: 0,
 		ips: [],
 		supportedImageFormats: [],
-		capabilities: []
+		capabilities: [],
+		maxConcurrentUploads: 0,
+		agentStartedAt: 0,
+		agentStartReason: ''
 	});
 
 	return {
@@ -20,6 +24,7 @@ function createConnectionStore() {
 		update,
 		reset: () => set({
 			connected: false,
+			state: 'disconnected',
 			agentId: '',
 			agentName: '',
 			platform: '',
@@ -27,7 +32,10 @@ function createConnectionStore() {
 			port: 0,
 			ips: [],
 			supportedImageFormats: [],
-			capabilities: []
+			capabilities: [],
+			maxConcurrentUploads: 0,
+		agentStartedAt: 0,
+		agentStartReason: ''
 		})
 	};
 }
@@ -22,10 +22,22 @@ export interface DiscoveredAgent {
 	discoveredAt: string;
 	lastSeen: string;
 	online: boolean;
+	// Added by address rather than found via mDNS.
+	manual?: boolean;
 }
 
+// Lifecycle of the connection to an agent
+export type ConnectionState =
+	| 'disconnected'
+	| 'connecting'
+	| 'pairing'
+	| 'connected'
+	| 'reconnecting'
+	| 'lost';
+
 export interface ConnectionStatus {
 	connected: boolean;
+	state: ConnectionState;
 	agentId: string;
 	agentName: string;
 	platform: string;
@@ -34,6 +46,211 @@ export interface ConnectionStatus {
 	ips: string[];
 	supportedImageFormats: string[];
 	capabilities: string[];
+	maxConcurrentUploads: number;
+	// When the agent's server started (Unix ms, 0 if unknown) and why.
+	agentStartedAt: number;
+	agentStartReason: string;
+}
+
+// Steam account state reported by the agent
+export type SteamLoginState = 'logged_in' | 'offline' | 'logged_out' | 'unknown';
+
+// Steam process state on the agent
+export interface SteamStatus {
+	running: boolean;
+	gamingMode?: boolean;
+	path?: string;
+}
+
+// Steam CEF debugger reachability on the agent
+export interface CEFStatus {
+	available: boolean;
+	steamRunning: boolean;
+	// Steam has to restart before CEF answers.
+	restartPending?: boolean;
+}
+
+// Agent self-test (active subsystem probes)
+export interface SelfTestCheck {
+	name: string;
+	passed: boolean;
+	detail?: string;
+	durationMs?: number;
+}
+
+export interface SelfTestReport {
+	passed: boolean;
+	checks: SelfTestCheck[];
+}
+
+// Deploy preflight verdict (agent can_deploy)
+export interface DeployBlocker {
+	code:
+		| 'disk_full'
+		| 'path_not_writable'
+		| 'steam_not_installed'
+		| 'not_accepting_connections'
+		| 'cef_not_ready'
+		| 'invalid_path'
+		| 'upload_limit';
+	message: string;
+}
+
+export interface CanDeployVerdict {
+	canDeploy: boolean;
+	reasons: DeployBlocker[];
+}
+
+// Guided setup of a new agent
+export interface ProvisionOptions {
+	installPath?: string;
+	cefWaitSecs?: number;
+}
+
+export interface ProvisionSummary {
+	agentId: string;
+	paired: boolean;
+	installPath: string;
+	cefReady: boolean;
+	selfTest: SelfTestReport | null;
+	blockers: DeployBlocker[];
+	warnings: string[];
+	ready: boolean;
+}
+
+// Install target browsing on the agent
+export interface DirectoryEntry {
+	name: string;
+	path: string;
+}
+
+export interface DirectoryListing {
+	path?: string;
+	parent?: string;
+	roots: string[];
+	entries: DirectoryEntry[];
+}
+
+// Steam library folder on the agent (e.g. an SD card)
+export interface SteamLibrary {
+	path: string;
+	label?: string;
+	installPath: string;
+}
+
+// Hub connected to the agent
+export interface ConnectedHub {
+	name: string;
+	ip?: string;
+	connectedSince: number; // Unix ms
+	current?: boolean; // This Hub
+}
+
+// Persistent upload queue
+export interface QueuedDeploy {
+	id: string;
+	setupId: string;
+	agentId: string;
+	status: 'pending' | 'active' | 'failed';
+	queuedAt: number;
+	scheduledAt?: number;
+	// Higher starts first; omitted means 0.
+	priority?: number;
+	lastError?: string;
+}
+
+// Deployment history
+export interface DeployRecord {
+	id: string;
+	setupId: string;
+	gameName: string;
+	agentId: string;
+	agentName?: string;
+	finishedAt: number;
+	success: boolean;
+	error?: string;
+	totalBytes: number;
+	appId?: number;
+	durationMs?: number;
+}
+
+// Pushed on `agent:config-changed` when the agent's settings change.
+export interface AgentConfigChanged {
+	installPath: string;
+	telemetryEnabled: boolean;
+	telemetryInterval: number;
+	consoleLogEnabled: boolean;
+	name?: string;
+	acceptConnections?: boolean;
+}
+
+export interface DeployEstimate {
+	totalBytes: number;
+	bytesPerSec: number;
+	durationSecs: number;
+	source: 'history' | 'probe';
+}
+
+// Dry run of a deploy: what would be sent and what would go wrong
+export interface DeployPreview {
+	agentId: string;
+	fileCount: number;
+	totalBytes: number;
+	transferBytes: number;
+	skippedFiles: number;
+	gamePath: string;
+	availableBytes?: number;
+	blockers: DeployBlocker[];
+	warnings: string[];
+	conflict?: ShortcutConflict;
+}
+
+// An existing Steam shortcut the deploy would duplicate.
+export interface ShortcutConflict {
+	appId: number;
+	name: string;
+	exe?: string;
+}
+
+export interface HistoryFilter {
+	agentId?: string;
+	since?: number;
+	until?: number;
+	limit?: number;
+}
+
+export interface QueueResumable {
+	agentId: string;
+	pending: number;
+}
+
+// Emitted as `connection:auto-connect-failed` when the startup connection
+// to the last used agent didn't go through
+export interface AutoConnectFailed {
+	agentId: string;
+	agentName: string;
+	pairingRequired: boolean;
+	error?: string;
+}
+
+// Emitted as `config:recovered` when corrupted settings were reset on startup
+export interface ConfigRecovered {
+	backups: string[];
+	tokensReset: boolean;
+}
+
+// Emitted as `connection:clock-skew` when the agent's clock is off
+export interface ClockSkewWarning {
+	agentId: string;
+	offsetMs: number;
+	message: string;
+}
+
+// Emitted as `discovery:agent-moved` when a connected agent changes address
+export interface AgentMoved {
+	agentId: string;
+	oldIps: string[];
+	newIps: string[];
 }
 
 // Filesystem types
@@ -59,6 +276,7 @@ export interface GameSetup {
 	local_path: string;
 	executable: string;
 	launch_options?: string;
+	compat_tool?: string;
 	tags?: string;
 	install_path: string;
 	griddb_game_id?: number;
@@ -67,6 +285,12 @@ export interface GameSetup {
 	hero_image?: string;
 	logo_image?: string;
 	icon_image?: string;
+	boot_video?: string;
+	mark_recent?: boolean;
+	artwork_all_users?: boolean;
+	library_path?: string;
+	install_subpath?: string;
+	clean_install?: boolean;
 }
 
 export interface InstalledGame {
@@ -74,6 +298,61 @@ export interface InstalledGame {
 	path: string;
 	size: string;
 	appId?: number;
+	needsCompatTool?: boolean;
+	tags?: string[];
+	sizeBytes: number;
+	broken?: boolean;
+}
+
+// Hub-side filter for the installed-games list; unset fields match all.
+export interface InstalledGamesFilter {
+	tags?: string[];
+	minSize?: number;
+	maxSize?: number;
+	brokenOnly?: boolean;
+}
+
+// Artwork read back from an installed game's shortcut
+export interface AppliedArtwork {
+	artworkType: string;
+	contentType: string;
+	size: number;
+	dataUri: string; // Empty when too large to fetch
+	tooLarge?: boolean;
+}
+
+export interface GameArtwork {
+	images: AppliedArtwork[];
+	missing: string[];
+}
+
+// Changes to an installed game's shortcut; unset fields stay as they are.
+export interface ShortcutUpdate {
+	name?: string;
+	launchOptions?: string;
+	tags?: string[];
+	startDir?: string;
+}
+
+// Outcome of deleting several games at once, one result per AppID in order.
+export interface DeleteGamesResult {
+	results: { appId: number; success: boolean; gameName?: string; error?: string }[];
+	steamRestarted?: boolean;
+}
+
+// Games this Hub deployed to the agent, selected for a wipe.
+export interface WipePlan {
+	games: InstalledGame[];
+	preserved: number;
+	reclaimableBytes: number;
+}
+
+export interface WipeReport {
+	removed: string[];
+	failed?: { appId: number; name: string; error: string }[];
+	preserved: number;
+	reclaimedBytes: number;
+	steamRestarted: boolean;
 }
 
 export interface UploadProgress {
@@ -81,6 +360,14 @@ export interface UploadProgress {
 	status: string;
 	error?: string;
 	done: boolean;
+	// Set for deploys, so progress from several agents can be told apart.
+	agentId?: string;
+	// File the agent is writing and its own progress (0-1), when reported.
+	currentFile?: string;
+	fileProgress?: number;
+	// Transfer speed and time left as the agent measures them.
+	bytesPerSec?: number;
+	etaSecs?: number;
 }
 
 // Telemetry types
@@ -175,6 +462,14 @@ export interface ConsoleLogBatch {
 	dropped: number;
 }
 
+// Line of the agent's own log, pushed as `logs:line` while subscribed
+export interface AgentLogLine {
+	timestamp: number;
+	level: string;
+	target?: string;
+	message: string;
+}
+
 // Game log wrapper types
 export interface GameLogWrapperStatus {
 	wrappers: Record<number, boolean>;
@@ -13,6 +13,13 @@ export function formatBytes(bytes: number): string {
 	return parseFloat((bytes / Math.pow(k, i)).toFixed(1)) + ' ' + sizes[i];
 }
 
+export function formatDuration(secs: number): string {
+	if (secs < 60) return `${Math.max(1, Math.round(secs))} s`;
+	const mins = Math.round(secs / 60);
+	if (mins < 60) return `${mins} min`;
+	return `${Math.floor(mins / 60)} h ${mins % 60} min`;
+}
+
 export function truncatePath(path: string, maxLen: number): string {
 	if (path.length <= maxLen) return path;
 	return '...' + path.slice(-maxLen + 3);
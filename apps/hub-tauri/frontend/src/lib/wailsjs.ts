@@ -5,8 +5,11 @@ import { invoke } from '@tauri-apps/api/core';
 import { listen, type UnlistenFn } from '@tauri-apps/api/event';
 import type {
 	DiscoveredAgent, ConnectionStatus, VersionInfo, HubInfo,
-	GameSetup, InstalledGame, SearchResult, ImageData, ArtworkFileResult,
-	FsListResponse
+	GameSetup, InstalledGame, InstalledGamesFilter, SearchResult, ImageData, ArtworkFileResult,
+	FsListResponse, SelfTestReport, QueuedDeploy, CanDeployVerdict, SteamLoginState, SteamStatus, CEFStatus,
+	DirectoryListing, SteamLibrary, DeployRecord, HistoryFilter, DeployEstimate, DeployPreview, ProvisionOptions, ProvisionSummary,
+	WipePlan, WipeReport, ConnectedHub, ShortcutUpdate, DeleteGamesResult, AgentLogLine,
+	GameArtwork
 } from '$lib/types';
 
 // ---------------------------------------------------------------------------
@@ -33,10 +36,49 @@ export function EventsOff(_name: string): void {
 
 export const GetDiscoveredAgents = () => invoke<DiscoveredAgent[]>('get_discovered_agents');
 export const RefreshDiscovery = () => invoke<DiscoveredAgent[]>('refresh_discovery');
+export const PauseDiscovery = () => invoke<void>('pause_discovery');
+export const ResumeDiscovery = () => invoke<void>('resume_discovery');
+export const GetDiscoveryPaused = () => invoke<boolean>('get_discovery_paused');
+// For networks that block mDNS; the agent's port is shown in its status panel.
+export const AddManualAgent = (host: string, port: number) =>
+	invoke<DiscoveredAgent>('add_manual_agent', { host, port });
+export const RemoveManualAgent = (agentID: string) =>
+	invoke<void>('remove_manual_agent', { agentId: agentID });
 export const ConnectAgent = (agentID: string) => invoke<string>('connect_agent', { agentId: agentID });
+export const RepairAgent = (agentID: string) => invoke<string>('repair_agent', { agentId: agentID });
 export const DisconnectAgent = () => invoke<void>('disconnect_agent');
+// Connect to the last used agent when it is discovered at startup.
+export const GetAutoReconnect = () => invoke<boolean>('get_auto_reconnect');
+export const SetAutoReconnect = (enabled: boolean) =>
+	invoke<void>('set_auto_reconnect', { enabled });
 export const GetConnectionStatus = () => invoke<ConnectionStatus>('get_connection_status');
+// Additional agents stay connected alongside the active one (must be paired).
+export const ConnectAdditionalAgent = (agentID: string) =>
+	invoke<ConnectionStatus>('connect_additional_agent', { agentId: agentID });
+export const DisconnectAgentById = (agentID: string) =>
+	invoke<void>('disconnect_agent_by_id', { agentId: agentID });
+export const GetConnectedAgents = () => invoke<ConnectionStatus[]>('get_connected_agents');
 export const GetAgentInstallPath = () => invoke<string>('get_agent_install_path');
+export const RunAgentSelfTest = () => invoke<SelfTestReport>('run_agent_self_test');
+// Pairing, if needed, goes through the usual dialog (ConfirmPairing).
+export const ProvisionAgent = (agentID: string, opts: ProvisionOptions = {}) =>
+	invoke<ProvisionSummary>('provision_agent', { agentId: agentID, opts });
+export const ImportAgentToken = (agentID: string, token: string) =>
+	invoke<void>('import_agent_token', { agentId: agentID, token });
+export const ExportAgentToken = (agentID: string, confirmed: boolean) =>
+	invoke<string>('export_agent_token', { agentId: agentID, confirmed });
+export const CanDeploy = (setupID?: string) =>
+	invoke<CanDeployVerdict>('can_deploy', { setupId: setupID ?? null });
+export const GetSteamLoginState = () => invoke<SteamLoginState | ''>('get_steam_login_state');
+export const GetAgentSteamStatus = () => invoke<SteamStatus>('get_agent_steam_status');
+export const CheckAgentCEF = () => invoke<CEFStatus>('check_agent_cef');
+export const RestartAgentSteam = () =>
+	invoke<{ success: boolean; message: string }>('restart_agent_steam');
+export const BrowseAgentDirectory = (path: string, create = false) =>
+	invoke<DirectoryListing>('browse_agent_directory', { path: path || null, create });
+export const GetAgentSteamLibraries = () =>
+	invoke<{ libraries: SteamLibrary[] }>('get_agent_steam_libraries');
+export const GetAgentConnectedHubs = () => invoke<ConnectedHub[]>('get_agent_connected_hubs');
 
 // ---------------------------------------------------------------------------
 // Console log commands
@@ -46,6 +88,12 @@ export const SetConsoleLogFilter = (levelMask: number) =>
 	invoke<void>('set_console_log_filter', { levelMask });
 export const SetConsoleLogEnabled = (enabled: boolean) =>
 	invoke<void>('set_console_log_enabled', { enabled });
+export const SetAgentVerbose = (enabled: boolean) =>
+	invoke<boolean>('set_agent_verbose', { enabled });
+
+// Streams the agent's own log as `logs:line` events; returns recent lines.
+export const SubscribeAgentLogs = (enabled: boolean, backlog?: number) =>
+	invoke<AgentLogLine[]>('subscribe_agent_logs', { enabled, backlog });
 
 // ---------------------------------------------------------------------------
 // Game log wrapper
@@ -76,21 +124,74 @@ export const CancelPairing = () => invoke<void>('cancel_pairing');
 
 export const GetGameSetups = () => invoke<GameSetup[]>('get_game_setups');
 export const AddGameSetup = (setup: any) => invoke<void>('add_game_setup', { setup });
+export const CreateSetupFromDroppedPath = (path: string) =>
+	invoke<GameSetup>('create_setup_from_dropped_path', { path });
+export const ImportShortcutSetup = (json: string, localPath: string) =>
+	invoke<GameSetup>('import_shortcut_setup', { json, localPath });
 export const UpdateGameSetup = (id: string, setup: any) =>
 	invoke<void>('update_game_setup', { id, setup });
 export const RemoveGameSetup = (id: string) => invoke<void>('remove_game_setup', { id });
+export const GetDefaultLaunchOptions = () => invoke<string>('get_default_launch_options');
+export const SetDefaultLaunchOptions = (template: string) =>
+	invoke<void>('set_default_launch_options', { template });
+export const PreviewLaunchOptions = (setup: any) =>
+	invoke<string>('preview_launch_options', { setup });
+export const CheckLaunchOptions = (options: string) =>
+	invoke<string[]>('check_launch_option_issues', { options });
 export const SelectFolder = () => invoke<string>('select_folder');
-export const UploadGame = (id: string) => invoke<void>('upload_game', { id });
+// `overwrite` replaces a shortcut the agent already has for the game.
+export const UploadGame = (id: string, overwrite = false) =>
+	invoke<void>('upload_game', { id, overwrite });
+// Validates the deploy on the agent without sending files.
+export const PreviewUploadGame = (id: string) => invoke<DeployPreview>('preview_upload_game', { id });
+export const BroadcastUploadGame = (id: string, agentIDs: string[]) =>
+	invoke<void>('broadcast_upload_game', { id, agentIds: agentIDs });
 export const CancelUpload = () => invoke<void>('cancel_upload');
+// Uploads over the TCP data channel can't be paused.
+export const PauseUpload = () => invoke<void>('pause_upload');
+export const ResumeUpload = () => invoke<void>('resume_upload');
+export const StartWatchDeploy = (setupID: string) =>
+	invoke<void>('start_watch_deploy', { setupId: setupID });
+export const StopWatchDeploy = () => invoke<void>('stop_watch_deploy');
+export const EnqueueDeploy = (setupID: string, scheduledAt?: number) =>
+	invoke<string>('enqueue_deploy', { setupId: setupID, scheduledAt: scheduledAt ?? null });
+export const GetUploadQueue = () => invoke<QueuedDeploy[]>('get_upload_queue');
+export const RemoveFromUploadQueue = (id: string) =>
+	invoke<boolean>('remove_from_upload_queue', { id });
+export const ReorderUploadQueue = (order: string[]) =>
+	invoke<QueuedDeploy[]>('reorder_upload_queue', { order });
+export const SetQueuedDeployPriority = (id: string, priority: number) =>
+	invoke<boolean>('set_queued_deploy_priority', { id, priority });
+export const RetryQueuedDeploy = (id: string) => invoke<boolean>('retry_queued_deploy', { id });
+export const ProcessUploadQueue = () => invoke<number>('process_upload_queue');
+export const GetDeployHistory = (filter?: HistoryFilter) =>
+	invoke<DeployRecord[]>('get_deploy_history', { filter: filter ?? null });
+export const EstimateDeploy = (setupID: string) =>
+	invoke<DeployEstimate>('estimate_deploy', { setupId: setupID });
 
 // ---------------------------------------------------------------------------
 // Installed games commands
 // ---------------------------------------------------------------------------
 
-export const GetInstalledGames = (agentID: string) =>
-	invoke<InstalledGame[]>('get_installed_games', { agentId: agentID });
+export const GetInstalledGames = (agentID: string, filter?: InstalledGamesFilter) =>
+	invoke<InstalledGame[]>('get_installed_games', { agentId: agentID, filter: filter ?? null });
 export const DeleteGame = (agentID: string, appID: number) =>
 	invoke<void>('delete_game', { agentId: agentID, appId: appID });
+export const DeleteGames = (appIDs: number[]) =>
+	invoke<DeleteGamesResult>('delete_games', { appIds: appIDs });
+export const PlanWipeDeployedGames = () => invoke<WipePlan>('plan_wipe_deployed_games');
+export const WipeDeployedGames = (appIDs: number[]) =>
+	invoke<WipeReport>('wipe_deployed_games', { appIds: appIDs });
+export const RenameGame = (appID: number, newName: string) =>
+	invoke<number>('rename_game', { appId: appID, newName });
+export const UpdateShortcut = (appID: number, update: ShortcutUpdate) =>
+	invoke<number>('update_shortcut', { appId: appID, update });
+export const LaunchGame = (appID: number) => invoke<boolean>('launch_game', { appId: appID });
+export const ExportShortcut = (appID: number) =>
+	invoke<string>('export_shortcut', { appId: appID });
+// Artwork currently on the game's shortcut; oversized files come without a dataUri.
+export const GetGameArtwork = (appID: number) =>
+	invoke<GameArtwork>('get_game_artwork', { appId: appID });
 export const UpdateGameArtwork = (
 	appID: number,
 	grid: string,
@@ -122,6 +223,19 @@ export const OpenCacheFolder = () => invoke<void>('open_cache_folder');
 export const GetImageCacheEnabled = () => invoke<boolean>('get_image_cache_enabled');
 export const SetImageCacheEnabled = (enabled: boolean) =>
 	invoke<void>('set_image_cache_enabled', { enabled });
+export const GetImageCacheMaxSize = () => invoke<number>('get_image_cache_max_size');
+export const SetImageCacheMaxSize = (bytes: number) =>
+	invoke<void>('set_image_cache_max_size', { bytes });
+export const GetUploadRateLimit = () => invoke<number>('get_upload_rate_limit');
+export const SetUploadRateLimit = (bytesPerSec: number) =>
+	invoke<void>('set_upload_rate_limit', { bytesPerSec });
+export const GetUploadChunkConcurrency = () => invoke<number>('get_upload_chunk_concurrency');
+export const SetUploadChunkConcurrency = (chunks: number) =>
+	invoke<void>('set_upload_chunk_concurrency', { chunks });
+export const GetUploadCompression = () => invoke<string>('get_upload_compression');
+export const SetUploadCompression = (codec: string) =>
+	invoke<void>('set_upload_compression', { codec });
+export const ReportConfigRecovery = () => invoke<void>('report_config_recovery');
 
 // ---------------------------------------------------------------------------
 // Filesystem browser
@@ -149,18 +263,26 @@ export const FsCancelTransfer = () => invoke<void>('fs_cancel_transfer');
 // ---------------------------------------------------------------------------
 
 export const SelectArtworkFile = () => invoke<ArtworkFileResult>('select_artwork_file');
-export const GetArtworkPreview = (url: string) => invoke<string>('get_artwork_preview', { url });
+export const GetArtworkPreview = (url: string, gameID?: number) =>
+	invoke<string>('get_artwork_preview', { url, gameId: gameID ?? null });
 
 // ---------------------------------------------------------------------------
 // SteamGridDB commands
 // ---------------------------------------------------------------------------
 
-export const SearchGames = (query: string) => invoke<SearchResult[]>('search_games', { query });
-export const GetGrids = (gameID: number, filters: any, page: number) =>
-	invoke<ImageData[]>('get_grids', { gameId: gameID, filters, page });
-export const GetHeroes = (gameID: number, filters: any, page: number) =>
-	invoke<ImageData[]>('get_heroes', { gameId: gameID, filters, page });
-export const GetLogos = (gameID: number, filters: any, page: number) =>
-	invoke<ImageData[]>('get_logos', { gameId: gameID, filters, page });
-export const GetIcons = (gameID: number, filters: any, page: number) =>
-	invoke<ImageData[]>('get_icons', { gameId: gameID, filters, page });
+export const SearchGames = (query: string, refresh?: boolean) =>
+	invoke<SearchResult[]>('search_games', { query, refresh });
+export const LookupBySteamAppID = (appID: number, refresh?: boolean) =>
+	invoke<SearchResult>('lookup_by_steam_app_id', { appId: appID, refresh });
+export const GetGrids = (gameID: number, filters: any, page: number, refresh?: boolean) =>
+	invoke<ImageData[]>('get_grids', { gameId: gameID, filters, page, refresh });
+export const GetHeroes = (gameID: number, filters: any, page: number, refresh?: boolean) =>
+	invoke<ImageData[]>('get_heroes', { gameId: gameID, filters, page, refresh });
+export const GetLogos = (gameID: number, filters: any, page: number, refresh?: boolean) =>
+	invoke<ImageData[]>('get_logos', { gameId: gameID, filters, page, refresh });
+export const GetIcons = (gameID: number, filters: any, page: number, refresh?: boolean) =>
+	invoke<ImageData[]>('get_icons', { gameId: gameID, filters, page, refresh });
+export const PrefetchArtwork = (gameID: number, artType: string, filters: any, page: number) =>
+	invoke<number>('prefetch_artwork', { gameId: gameID, artType, filters, page });
+export const GetCachedArtwork = (url: string, gameID: number) =>
+	invoke<string | null>('get_cached_artwork', { url, gameId: gameID });
@@ -9,7 +9,10 @@ use std::pin::Pin;
 use std::sync::Arc;
 
 use capydeploy_hub_connection::ConnectionManager;
-use capydeploy_protocol::constants::MessageType;
+use capydeploy_protocol::constants::{
+    CAPABILITY_ARTWORK_BATCH, CAPABILITY_CHUNKED_ARTWORK, CAPABILITY_DISK_USAGE,
+    CAPABILITY_TCP_DATA_CHANNEL, MessageType, WS_ERR_CODE_CONFLICT,
+};
 use capydeploy_protocol::envelope::Message;
 
 // ---------------------------------------------------------------------------
@@ -21,6 +24,10 @@ pub struct DeployAdapter {
     mgr: Arc<ConnectionManager>,
     agent_id: String,
     agent_ip: Option<std::net::IpAddr>,
+    max_frame_size: usize,
+    chunked_artwork: bool,
+    artwork_batch: bool,
+    disk_usage: bool,
 }
 
 impl DeployAdapter {
@@ -30,10 +37,15 @@ impl DeployAdapter {
             mgr,
             agent_id,
             agent_ip: None,
+            max_frame_size: capydeploy_protocol::constants::WS_MAX_MESSAGE_SIZE,
+            chunked_artwork: false,
+            artwork_batch: false,
+            disk_usage: false,
         }
     }
 
-    /// Creates a DeployAdapter with cached agent IP address.
+    /// Creates a DeployAdapter with the agent IP address and frame limit
+    /// taken from the negotiated protocol profile.
     pub fn with_agent_info(
         mgr: Arc<ConnectionManager>,
         agent_id: String,
@@ -42,7 +54,16 @@ impl DeployAdapter {
         Self {
             mgr,
             agent_id,
-            agent_ip: connected.agent.ips.first().copied(),
+            // Without the TCP data channel, uploads stay on the WebSocket.
+            agent_ip: connected
+                .profile
+                .supports(CAPABILITY_TCP_DATA_CHANNEL)
+                .then(|| connected.agent.preferred_ip())
+                .flatten(),
+            max_frame_size: connected.profile.max_binary_frame_size,
+            chunked_artwork: connected.profile.supports(CAPABILITY_CHUNKED_ARTWORK),
+            artwork_batch: connected.profile.supports(CAPABILITY_ARTWORK_BATCH),
+            disk_usage: connected.profile.supports(CAPABILITY_DISK_USAGE),
         }
     }
 }
@@ -58,9 +79,14 @@ impl capydeploy_hub_deploy::AgentConnection for DeployAdapter {
         let mgr = self.mgr.clone();
         let payload = payload.clone();
         Box::pin(async move {
-            mgr.send_request(msg_type, Some(&payload))
+            mgr.send_request_to(&self.agent_id, msg_type, Some(&payload))
                 .await
-                .map_err(|e| capydeploy_hub_deploy::DeployError::Agent(e.to_string()))
+                .map_err(|e| match e.details() {
+                    Some(conflict) if e.agent_code() == Some(WS_ERR_CODE_CONFLICT) => {
+                        capydeploy_hub_deploy::DeployError::ShortcutConflict(conflict)
+                    }
+                    _ => capydeploy_hub_deploy::DeployError::Agent(e.to_string()),
+                })
         })
     }
 
@@ -75,9 +101,15 @@ impl capydeploy_hub_deploy::AgentConnection for DeployAdapter {
         let header = header.clone();
         let data = data.to_vec();
         Box::pin(async move {
-            mgr.send_binary(&header, &data)
+            mgr.send_binary_to(&self.agent_id, &header, &data)
                 .await
-                .map_err(|e| capydeploy_hub_deploy::DeployError::Agent(e.to_string()))
+                .map_err(|e| {
+                    if e.is_upload_paused() {
+                        capydeploy_hub_deploy::DeployError::UploadPaused
+                    } else {
+                        capydeploy_hub_deploy::DeployError::Agent(e.to_string())
+                    }
+                })
         })
     }
 
@@ -88,6 +120,22 @@ impl capydeploy_hub_deploy::AgentConnection for DeployAdapter {
     fn agent_addr(&self) -> Option<std::net::IpAddr> {
         self.agent_ip
     }
+
+    fn max_binary_frame_size(&self) -> usize {
+        self.max_frame_size
+    }
+
+    fn supports_chunked_artwork(&self) -> bool {
+        self.chunked_artwork
+    }
+
+    fn supports_artwork_batch(&self) -> bool {
+        self.artwork_batch
+    }
+
+    fn supports_disk_usage(&self) -> bool {
+        self.disk_usage
+    }
 }
 
 // ---------------------------------------------------------------------------
@@ -116,7 +164,7 @@ impl capydeploy_hub_games::AgentConnection for GamesAdapter {
         let mgr = self.mgr.clone();
         let payload = payload.clone();
         Box::pin(async move {
-            mgr.send_request(msg_type, Some(&payload))
+            mgr.send_request_to(&self.agent_id, msg_type, Some(&payload))
                 .await
                 .map_err(|e| capydeploy_hub_games::GamesError::Agent(e.to_string()))
         })
@@ -132,7 +180,7 @@ impl capydeploy_hub_games::AgentConnection for GamesAdapter {
         let header = header.clone();
         let data = data.to_vec();
         Box::pin(async move {
-            mgr.send_binary(&header, &data)
+            mgr.send_binary_to(&self.agent_id, &header, &data)
                 .await
                 .map_err(|e| capydeploy_hub_games::GamesError::Agent(e.to_string()))
         })
@@ -1,10 +1,21 @@
 //! Connection-related Tauri commands.
 
-use tauri::State;
+use std::sync::atomic::Ordering;
+
+use tauri::{AppHandle, Emitter, Manager, State};
 use tracing::{debug, warn};
 
+use capydeploy_discovery::types::{DiscoveredAgent, ServiceInfo};
+
+use capydeploy_hub_connection::{ProvisionOptions, ProvisionSummary};
+use capydeploy_protocol::messages::{
+    BrowseDirectoryResponse, CanDeployResponse, CefStatusResponse, ConnectedHubEntry,
+    RestartSteamResponse, SelfTestResponse, SteamLibrariesResponse, SteamStatusResponse,
+};
+
+use crate::config::ManualAgent;
 use crate::state::HubState;
-use crate::types::{ConnectionStatusDto, DiscoveredAgentDto};
+use crate::types::{AutoConnectFailedDto, ConnectionStatusDto, DiscoveredAgentDto};
 
 #[tauri::command]
 pub async fn get_discovered_agents(
@@ -23,6 +34,179 @@ pub async fn refresh_discovery(
     Ok(agents.iter().map(DiscoveredAgentDto::from).collect())
 }
 
+/// Stops mDNS scanning; an active connection is kept.
+#[tauri::command]
+pub async fn pause_discovery(state: State<'_, HubState>) -> Result<(), String> {
+    state.connection_mgr.pause_discovery();
+    Ok(())
+}
+
+#[tauri::command]
+pub async fn resume_discovery(state: State<'_, HubState>) -> Result<(), String> {
+    state.connection_mgr.resume_discovery();
+    Ok(())
+}
+
+#[tauri::command]
+pub async fn get_discovery_paused(state: State<'_, HubState>) -> Result<bool, String> {
+    Ok(state.connection_mgr.is_discovery_paused())
+}
+
+/// Adds an agent by address, for networks where mDNS is blocked, and
+/// remembers it across restarts.
+#[tauri::command]
+pub async fn add_manual_agent(
+    state: State<'_, HubState>,
+    host: String,
+    port: u16,
+) -> Result<DiscoveredAgentDto, String> {
+    let agent = state
+        .connection_mgr
+        .add_manual_agent(&host, port)
+        .await
+        .map_err(|e| e.to_string())?;
+    remember_manual_agent(&state, &agent).await?;
+    Ok(DiscoveredAgentDto::from(&agent))
+}
+
+/// Forgets a manually added agent.
+#[tauri::command]
+pub async fn remove_manual_agent(
+    state: State<'_, HubState>,
+    agent_id: String,
+) -> Result<(), String> {
+    state.connection_mgr.remove_manual_agent(&agent_id).await;
+
+    let mut cfg = state.config.lock().await;
+    let before = cfg.manual_agents.len();
+    cfg.manual_agents.retain(|m| m.id != agent_id);
+    if cfg.manual_agents.len() != before {
+        cfg.save().map_err(|e| e.to_string())?;
+    }
+    Ok(())
+}
+
+/// Saves `agent` to the settings, replacing the entry with the same ID or
+/// address (the ID changes when the agent is renamed).
+async fn remember_manual_agent(state: &HubState, agent: &DiscoveredAgent) -> Result<(), String> {
+    let entry = ManualAgent {
+        id: agent.info.id.clone(),
+        name: agent.info.name.clone(),
+        platform: agent.info.platform.clone(),
+        host: agent.host.clone(),
+        port: agent.port,
+    };
+    let mut cfg = state.config.lock().await;
+    if cfg.manual_agents.contains(&entry) {
+        return Ok(());
+    }
+    cfg.manual_agents
+        .retain(|m| m.id != entry.id && (m.host != entry.host || m.port != entry.port));
+    cfg.manual_agents.push(entry);
+    cfg.save().map_err(|e| e.to_string())
+}
+
+/// Lists the saved manual agents at startup. Each is probed for fresh
+/// info; one that doesn't answer is listed offline from its saved identity.
+pub(crate) async fn restore_manual_agents(app: AppHandle) {
+    let saved = app
+        .state::<HubState>()
+        .config
+        .lock()
+        .await
+        .manual_agents
+        .clone();
+    for entry in saved {
+        let app = app.clone();
+        tauri::async_runtime::spawn(async move {
+            let state = app.state::<HubState>();
+            match state
+                .connection_mgr
+                .add_manual_agent(&entry.host, entry.port)
+                .await
+            {
+                Ok(agent) => {
+                    if let Err(e) = remember_manual_agent(&state, &agent).await {
+                        warn!(agent = %entry.id, "failed to save manual agent: {e}");
+                    }
+                }
+                Err(e) => {
+                    debug!(agent = %entry.id, host = %entry.host, "manual agent unreachable: {e}");
+                    let info = ServiceInfo {
+                        id: entry.id,
+                        name: entry.name,
+                        platform: entry.platform,
+                        version: String::new(),
+                        port: entry.port,
+                        ips: Vec::new(),
+                    }
+                    .to_agent_info();
+                    let mut agent = DiscoveredAgent::manual(info, &entry.host, entry.port);
+                    agent.last_seen = None;
+                    state.connection_mgr.insert_manual_agent(agent).await;
+                }
+            }
+        });
+    }
+}
+
+/// Connects to the agent the Hub last used once discovery reports it
+/// online. Tried at most once per run and only while no agent is connected;
+/// it never starts a pairing, so a revoked token falls back to the manual
+/// flow through `connection:auto-connect-failed`.
+pub(crate) async fn auto_connect_last_agent(app: AppHandle, agent: DiscoveredAgent) {
+    let state = app.state::<HubState>();
+    if !state.auto_connect_armed.load(Ordering::Relaxed) || agent.last_seen.is_none() {
+        return;
+    }
+    if state.config.lock().await.last_agent_id != agent.info.id {
+        return;
+    }
+    if state.connection_mgr.get_connected().await.is_some() {
+        state.auto_connect_armed.store(false, Ordering::Relaxed);
+        return;
+    }
+    if !state.auto_connect_armed.swap(false, Ordering::Relaxed) {
+        return;
+    }
+
+    debug!(agent = %agent.info.id, "connecting to last used agent");
+    let err = match state
+        .connection_mgr
+        .connect_additional_agent(&agent.info.id)
+        .await
+    {
+        Ok(_) => return,
+        Err(e) => e,
+    };
+    warn!(agent = %agent.info.id, "auto-connect failed: {err}");
+    let pairing_required = matches!(err, capydeploy_hub_connection::WsError::PairingFailed(_));
+    let dto = AutoConnectFailedDto {
+        agent_id: agent.info.id,
+        agent_name: agent.info.name,
+        pairing_required,
+        error: if pairing_required {
+            String::new()
+        } else {
+            err.to_string()
+        },
+    };
+    let _ = app.emit("connection:auto-connect-failed", &dto);
+}
+
+#[tauri::command]
+pub async fn get_auto_reconnect(state: State<'_, HubState>) -> Result<bool, String> {
+    Ok(state.config.lock().await.auto_reconnect)
+}
+
+/// Turns the startup connection to the last used agent on or off.
+#[tauri::command]
+pub async fn set_auto_reconnect(state: State<'_, HubState>, enabled: bool) -> Result<(), String> {
+    let mut cfg = state.config.lock().await;
+    cfg.auto_reconnect = enabled;
+    cfg.save().map_err(|e| e.to_string())
+}
+
 #[tauri::command]
 pub async fn connect_agent(state: State<'_, HubState>, agent_id: String) -> Result<String, String> {
     // Returns "connected" or "pairing_required" so the frontend can
@@ -34,20 +218,72 @@ pub async fn connect_agent(state: State<'_, HubState>, agent_id: String) -> Resu
     }
 }
 
+/// Discards the stored token and connects, forcing a fresh pairing.
+#[tauri::command]
+pub async fn repair_agent(state: State<'_, HubState>, agent_id: String) -> Result<String, String> {
+    match state.connection_mgr.repair_agent(&agent_id).await {
+        Ok(true) => Ok("pairing_required".into()),
+        Ok(false) => Ok("connected".into()),
+        Err(e) => Err(e.to_string()),
+    }
+}
+
 #[tauri::command]
 pub async fn disconnect_agent(state: State<'_, HubState>) -> Result<(), String> {
     state.connection_mgr.disconnect_agent().await;
     Ok(())
 }
 
+/// Connects to another agent, keeping the current ones, so a deploy can be
+/// broadcast to all of them. The agent must already be paired.
+#[tauri::command]
+pub async fn connect_additional_agent(
+    state: State<'_, HubState>,
+    agent_id: String,
+) -> Result<ConnectionStatusDto, String> {
+    state
+        .connection_mgr
+        .connect_additional_agent(&agent_id)
+        .await
+        .map(|agent| ConnectionStatusDto::from_connected(&agent))
+        .map_err(|e| e.to_string())
+}
+
+#[tauri::command]
+pub async fn disconnect_agent_by_id(
+    state: State<'_, HubState>,
+    agent_id: String,
+) -> Result<(), String> {
+    state.connection_mgr.disconnect_agent_by_id(&agent_id).await;
+    Ok(())
+}
+
+/// Lists every connected agent, the active one first.
+#[tauri::command]
+pub async fn get_connected_agents(
+    state: State<'_, HubState>,
+) -> Result<Vec<ConnectionStatusDto>, String> {
+    Ok(state
+        .connection_mgr
+        .get_connected_agents()
+        .await
+        .iter()
+        .map(ConnectionStatusDto::from_connected)
+        .collect())
+}
+
+/// Status of the active agent, the one single-agent commands talk to.
 #[tauri::command]
 pub async fn get_connection_status(
     state: State<'_, HubState>,
 ) -> Result<ConnectionStatusDto, String> {
-    match state.connection_mgr.get_connected().await {
-        Some(agent) => Ok(ConnectionStatusDto::from_connected(&agent)),
-        None => Ok(ConnectionStatusDto::disconnected()),
+    if let Some(agent) = state.connection_mgr.get_connected().await {
+        return Ok(ConnectionStatusDto::from_connected(&agent));
     }
+    Ok(match state.connection_mgr.active_state().await {
+        Some((agent_id, conn_state)) => ConnectionStatusDto::not_connected(agent_id, &conn_state),
+        None => ConnectionStatusDto::disconnected(),
+    })
 }
 
 #[tauri::command]
@@ -57,6 +293,12 @@ pub async fn confirm_pairing(
     code: String,
 ) -> Result<(), String> {
     debug!(agent_id = %agent_id, code_len = code.len(), "confirm_pairing called");
+    // A running provision_agent does the confirmation itself.
+    if let Some(tx) = state.provision_code.lock().await.take() {
+        return tx
+            .send(code)
+            .map_err(|_| "provisioning is no longer running".to_string());
+    }
     match state.connection_mgr.confirm_pairing(&agent_id, &code).await {
         Ok(_) => {
             debug!(agent_id = %agent_id, "pairing confirmed successfully");
@@ -71,6 +313,208 @@ pub async fn confirm_pairing(
 
 #[tauri::command]
 pub async fn cancel_pairing(state: State<'_, HubState>) -> Result<(), String> {
-    state.connection_mgr.disconnect_agent().await;
+    // Dropping the sender aborts a provisioning run waiting for a code.
+    state.provision_code.lock().await.take();
+    state.connection_mgr.cancel_pairing().await;
     Ok(())
 }
+
+#[tauri::command]
+pub async fn run_agent_self_test(state: State<'_, HubState>) -> Result<SelfTestResponse, String> {
+    state
+        .connection_mgr
+        .self_test()
+        .await
+        .map_err(|e| e.to_string())
+}
+
+/// Sets up a new agent in one call: connect, pair, set the install path,
+/// wait for CEF and run the self-test. If pairing is needed the usual
+/// pairing dialog appears and its code is routed here.
+#[tauri::command]
+pub async fn provision_agent(
+    state: State<'_, HubState>,
+    agent_id: String,
+    opts: ProvisionOptions,
+) -> Result<ProvisionSummary, String> {
+    let slot = state.provision_code.clone();
+    let result = capydeploy_hub_connection::provision(
+        state.connection_mgr.as_ref(),
+        &agent_id,
+        &opts,
+        |_| async move {
+            let (tx, rx) = tokio::sync::oneshot::channel();
+            *slot.lock().await = Some(tx);
+            rx.await.ok()
+        },
+    )
+    .await;
+    state.provision_code.lock().await.take();
+
+    match result {
+        Ok(summary) => {
+            debug!(agent_id = %agent_id, ready = summary.ready, "provisioning finished");
+            Ok(summary)
+        }
+        Err(e) => {
+            warn!(agent_id = %agent_id, error = %e, "provisioning failed");
+            Err(e.to_string())
+        }
+    }
+}
+
+/// Returns the connected agent's Steam login state (`logged_in`, `offline`,
+/// `logged_out` or `unknown`). Empty if the agent doesn't report it.
+#[tauri::command]
+pub async fn get_steam_login_state(state: State<'_, HubState>) -> Result<String, String> {
+    state
+        .connection_mgr
+        .get_info()
+        .await
+        .map(|info| info.agent.steam_login_state)
+        .map_err(|e| e.to_string())
+}
+
+/// Returns whether Steam is running on the connected agent and in gaming
+/// mode, so the UI can warn before a deploy that artwork may not apply.
+#[tauri::command]
+pub async fn get_agent_steam_status(
+    state: State<'_, HubState>,
+) -> Result<SteamStatusResponse, String> {
+    state
+        .connection_mgr
+        .steam_status()
+        .await
+        .map_err(|e| e.to_string())
+}
+
+/// Returns whether the connected agent's Steam CEF debugger answers and
+/// whether Steam needs a restart first.
+#[tauri::command]
+pub async fn check_agent_cef(state: State<'_, HubState>) -> Result<CefStatusResponse, String> {
+    state
+        .connection_mgr
+        .check_cef()
+        .await
+        .map_err(|e| e.to_string())
+}
+
+/// Restarts Steam on the connected agent once the user agreed to it.
+#[tauri::command]
+pub async fn restart_agent_steam(
+    state: State<'_, HubState>,
+) -> Result<RestartSteamResponse, String> {
+    state
+        .connection_mgr
+        .restart_steam()
+        .await
+        .map_err(|e| e.to_string())
+}
+
+/// Asks the connected agent whether it can accept a deploy of the given
+/// setup. The setup's local files are measured so the agent can check
+/// free space; without a setup only the other preconditions are checked.
+#[tauri::command]
+pub async fn can_deploy(
+    state: State<'_, HubState>,
+    setup_id: Option<String>,
+) -> Result<CanDeployResponse, String> {
+    let total_size = match setup_id {
+        Some(id) => {
+            let local_path = state
+                .config
+                .lock()
+                .await
+                .game_setups
+                .iter()
+                .find(|s| s.id == id)
+                .map(|s| s.local_path.clone())
+                .ok_or_else(|| format!("game setup '{id}' not found"))?;
+            tokio::task::spawn_blocking(move || {
+                capydeploy_hub_deploy::scan_files_for_upload(std::path::Path::new(&local_path))
+                    .map(|(_, size)| size)
+            })
+            .await
+            .map_err(|e| e.to_string())?
+            .map_err(|e| e.to_string())?
+        }
+        None => 0,
+    };
+
+    state
+        .connection_mgr
+        .can_deploy(total_size)
+        .await
+        .map_err(|e| e.to_string())
+}
+
+/// Browses directories on the connected agent to pick an install target.
+#[tauri::command]
+pub async fn browse_agent_directory(
+    state: State<'_, HubState>,
+    path: Option<String>,
+    create: bool,
+) -> Result<BrowseDirectoryResponse, String> {
+    state
+        .connection_mgr
+        .browse_directory(path.as_deref().unwrap_or_default(), create)
+        .await
+        .map_err(|e| e.to_string())
+}
+
+/// Lists the Steam libraries on the connected agent as deploy targets.
+#[tauri::command]
+pub async fn get_agent_steam_libraries(
+    state: State<'_, HubState>,
+) -> Result<SteamLibrariesResponse, String> {
+    state
+        .connection_mgr
+        .steam_libraries()
+        .await
+        .map_err(|e| e.to_string())
+}
+
+/// Lists the Hubs connected to the agent, so the UI can show who else is
+/// working on the device.
+#[tauri::command]
+pub async fn get_agent_connected_hubs(
+    state: State<'_, HubState>,
+) -> Result<Vec<ConnectedHubEntry>, String> {
+    state
+        .connection_mgr
+        .connected_hubs()
+        .await
+        .map_err(|e| e.to_string())
+}
+
+/// Stores a token for an agent paired on another machine, so the next
+/// connect authenticates without pairing.
+#[tauri::command]
+pub async fn import_agent_token(
+    state: State<'_, HubState>,
+    agent_id: String,
+    token: String,
+) -> Result<(), String> {
+    state
+        .connection_mgr
+        .import_agent_token(&agent_id, &token)
+        .map_err(|e| e.to_string())
+}
+
+/// Returns the stored token for an agent. The frontend must confirm with
+/// the user first and pass `confirmed = true`.
+#[tauri::command]
+pub async fn export_agent_token(
+    state: State<'_, HubState>,
+    agent_id: String,
+    confirmed: bool,
+) -> Result<String, String> {
+    if !confirmed {
+        return Err("token export requires confirmation".into());
+    }
+    state
+        .connection_mgr
+        .export_agent_token(&agent_id)
+        .map_err(|e| e.to_string())?
+        .ok_or_else(|| format!("no token stored for agent '{agent_id}'"))
+}
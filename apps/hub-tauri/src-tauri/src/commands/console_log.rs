@@ -2,8 +2,11 @@
 
 use tauri::State;
 
-use capydeploy_protocol::constants::MessageType;
-use capydeploy_protocol::messages::{SetConsoleLogEnabledRequest, SetConsoleLogFilterRequest};
+use capydeploy_protocol::constants::{CAPABILITY_AGENT_LOGS, MessageType};
+use capydeploy_protocol::messages::{
+    AgentLogLine, SetConsoleLogEnabledRequest, SetConsoleLogFilterRequest, SetVerboseRequest,
+    SetVerboseResponse, SubscribeLogsRequest, SubscribeLogsResponse,
+};
 
 use crate::state::HubState;
 
@@ -34,3 +37,47 @@ pub async fn set_console_log_enabled(
         .map(|_| ())
         .map_err(|e| e.to_string())
 }
+
+/// Turns verbose logging on the connected agent on or off, without a
+/// restart. Returns the state the agent confirmed.
+#[tauri::command]
+pub async fn set_agent_verbose(state: State<'_, HubState>, enabled: bool) -> Result<bool, String> {
+    let payload = SetVerboseRequest { enabled };
+    let resp = state
+        .connection_mgr
+        .send_request(MessageType::SetVerbose, Some(&payload))
+        .await
+        .map_err(|e| e.to_string())?;
+    let confirmed: Option<SetVerboseResponse> = resp.parse_payload().map_err(|e| e.to_string())?;
+    Ok(confirmed.map_or(enabled, |r| r.enabled))
+}
+
+/// Starts or stops streaming the connected agent's own log as
+/// `logs:line` events. Subscribing returns up to `backlog` recent lines.
+#[tauri::command]
+pub async fn subscribe_agent_logs(
+    state: State<'_, HubState>,
+    enabled: bool,
+    backlog: Option<u32>,
+) -> Result<Vec<AgentLogLine>, String> {
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected".to_string())?;
+    if !connected.profile.supports(CAPABILITY_AGENT_LOGS) {
+        return Err("agent does not support log streaming".into());
+    }
+
+    let payload = SubscribeLogsRequest {
+        enabled,
+        backlog: backlog.unwrap_or(0),
+    };
+    let resp = state
+        .connection_mgr
+        .send_request(MessageType::SubscribeLogs, Some(&payload))
+        .await
+        .map_err(|e| e.to_string())?;
+    let resp: Option<SubscribeLogsResponse> = resp.parse_payload().map_err(|e| e.to_string())?;
+    Ok(resp.map(|r| r.lines).unwrap_or_default())
+}
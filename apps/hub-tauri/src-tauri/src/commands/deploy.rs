@@ -1,12 +1,24 @@
-//! Deploy-related Tauri commands (game setup CRUD + upload).
+//! Deploy-related Tauri commands (game setup CRUD + upload + watch mode).
 
-use tauri::{AppHandle, Emitter, State};
+use std::path::Path;
+use std::time::Duration;
 
-use capydeploy_hub_deploy::GameSetup;
+use tauri::{AppHandle, Emitter, Manager, State};
+
+use capydeploy_hub_connection::ConnectedAgent;
+use capydeploy_hub_deploy::agent::AgentDeploy;
+use capydeploy_hub_deploy::{
+    DEFAULT_WATCH_DEBOUNCE, DeployEstimate, DeployPreview, DeployRecord, GameSetup, HistoryFilter,
+    QueuedDeploy, RedeployFn, UploadQueue, WatchDeploy, detect_setup, launch_template,
+    needs_agent_install_path, process_queue, resolve_launch_options, run_schedule,
+    setup_from_portable, uses_install_dir, validate_template,
+};
+use capydeploy_protocol::launch_options::{check_launch_options, normalize_launch_options};
+use tokio_util::sync::CancellationToken;
 
 use crate::agent_adapter::DeployAdapter;
 use crate::state::HubState;
-use crate::types::UploadProgressDto;
+use crate::types::{UploadProgressDto, WatchStatusDto};
 
 #[tauri::command]
 pub async fn get_game_setups(state: State<'_, HubState>) -> Result<Vec<GameSetup>, String> {
@@ -22,17 +34,66 @@ pub async fn add_game_setup(
     if setup.id.is_empty() {
         setup.id = uuid::Uuid::new_v4().to_string();
     }
+    validate_template(&setup.launch_options).map_err(|e| e.to_string())?;
+    setup
+        .validate_install_subpath()
+        .map_err(|e| e.to_string())?;
+    setup.launch_options = normalize_launch_options(&setup.launch_options);
     let mut cfg = state.config.lock().await;
     cfg.game_setups.push(setup);
     cfg.save().map_err(|e| e.to_string())
 }
 
+/// Creates and saves a setup for a build folder dropped onto the window,
+/// detecting the name and executable.
+#[tauri::command]
+pub async fn create_setup_from_dropped_path(
+    state: State<'_, HubState>,
+    path: String,
+) -> Result<GameSetup, String> {
+    let folder = std::path::PathBuf::from(path);
+    let mut setup = tokio::task::spawn_blocking(move || detect_setup(&folder))
+        .await
+        .map_err(|e| e.to_string())?
+        .map_err(|e| e.to_string())?;
+    setup.id = uuid::Uuid::new_v4().to_string();
+
+    let mut cfg = state.config.lock().await;
+    cfg.game_setups.push(setup.clone());
+    cfg.save().map_err(|e| e.to_string())?;
+    Ok(setup)
+}
+
+/// Creates and saves a setup from a shortcut definition exported on
+/// another Hub, pointing it at the local copy of the build.
+#[tauri::command]
+pub async fn import_shortcut_setup(
+    state: State<'_, HubState>,
+    json: String,
+    local_path: String,
+) -> Result<GameSetup, String> {
+    let shortcut: capydeploy_protocol::portable::PortableShortcut =
+        serde_json::from_str(&json).map_err(|e| format!("invalid shortcut definition: {e}"))?;
+    let mut setup = setup_from_portable(&shortcut, &local_path).map_err(|e| e.to_string())?;
+    setup.id = uuid::Uuid::new_v4().to_string();
+
+    let mut cfg = state.config.lock().await;
+    cfg.game_setups.push(setup.clone());
+    cfg.save().map_err(|e| e.to_string())?;
+    Ok(setup)
+}
+
 #[tauri::command]
 pub async fn update_game_setup(
     state: State<'_, HubState>,
     id: String,
-    setup: GameSetup,
+    mut setup: GameSetup,
 ) -> Result<(), String> {
+    validate_template(&setup.launch_options).map_err(|e| e.to_string())?;
+    setup
+        .validate_install_subpath()
+        .map_err(|e| e.to_string())?;
+    setup.launch_options = normalize_launch_options(&setup.launch_options);
     let mut cfg = state.config.lock().await;
     if let Some(existing) = cfg.game_setups.iter_mut().find(|s| s.id == id) {
         *existing = setup;
@@ -53,41 +114,210 @@ pub async fn remove_game_setup(state: State<'_, HubState>, id: String) -> Result
     cfg.save().map_err(|e| e.to_string())
 }
 
+#[tauri::command]
+pub async fn get_default_launch_options(state: State<'_, HubState>) -> Result<String, String> {
+    let cfg = state.config.lock().await;
+    Ok(cfg.default_launch_options.clone())
+}
+
+#[tauri::command]
+pub async fn set_default_launch_options(
+    state: State<'_, HubState>,
+    template: String,
+) -> Result<(), String> {
+    validate_template(&template).map_err(|e| e.to_string())?;
+    let mut cfg = state.config.lock().await;
+    cfg.default_launch_options = template;
+    cfg.save().map_err(|e| e.to_string())
+}
+
+/// Returns the launch options a setup would deploy with to the connected
+/// agent, after substitution.
+#[tauri::command]
+pub async fn preview_launch_options(
+    state: State<'_, HubState>,
+    setup: GameSetup,
+) -> Result<String, String> {
+    let default_launch_options = state.config.lock().await.default_launch_options.clone();
+    let template = launch_template(&setup, &default_launch_options);
+    let agent_install_path = if uses_install_dir(template) && needs_agent_install_path(&setup) {
+        match state.connection_mgr.get_config().await {
+            Ok(config) => config.install_path,
+            Err(e) => {
+                tracing::debug!("cannot read the agent's install path: {e}");
+                String::new()
+            }
+        }
+    } else {
+        String::new()
+    };
+    resolve_launch_options(&setup, &default_launch_options, &agent_install_path)
+        .map_err(|e| e.to_string())
+}
+
+/// Lists likely mistakes (unbalanced quotes, misplaced `%command%`) in
+/// launch options, so the UI can warn before saving.
+#[tauri::command]
+pub async fn check_launch_option_issues(options: String) -> Result<Vec<String>, String> {
+    Ok(check_launch_options(&options)
+        .iter()
+        .map(ToString::to_string)
+        .collect())
+}
+
+/// Deploys a saved setup to the connected agent. With `overwrite`, a
+/// shortcut the agent already has for the game is replaced; otherwise the
+/// agent refuses the upload before any files are sent.
 #[tauri::command]
 pub async fn upload_game(
     app: AppHandle,
     state: State<'_, HubState>,
     id: String,
+    overwrite: Option<bool>,
 ) -> Result<(), String> {
+    deploy_setup(&app, &state, &id, overwrite.unwrap_or(false)).await
+}
+
+/// Dry-runs a deploy of a saved setup to the connected agent: the files
+/// are scanned and the agent validates the upload, but nothing is sent.
+#[tauri::command]
+pub async fn preview_upload_game(
+    state: State<'_, HubState>,
+    id: String,
+) -> Result<DeployPreview, String> {
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected to any agent".to_string())?;
     let cfg = state.config.lock().await;
-    let setup = cfg
+    let mut setup = cfg
         .game_setups
         .iter()
         .find(|s| s.id == id)
         .cloned()
         .ok_or_else(|| format!("game setup '{id}' not found"))?;
+    let default_launch_options = cfg.default_launch_options.clone();
     drop(cfg);
+    // Variables are expanded per agent, against its own install directory.
+    setup.launch_options = launch_template(&setup, &default_launch_options).to_string();
+    validate_template(&setup.launch_options).map_err(|e| e.to_string())?;
 
+    let artwork = capydeploy_hub_deploy::build_artwork_assignment(&setup);
+    let config = capydeploy_hub_deploy::DeployConfig { setup, artwork };
+    let adapter = DeployAdapter::with_agent_info(
+        state.connection_mgr.clone(),
+        connected.agent.info.id.clone(),
+        &connected,
+    );
+    AgentDeploy::new(&adapter, CancellationToken::new())
+        .preview(&config)
+        .await
+        .map_err(|e| e.to_string())
+}
+
+/// Deploys a saved setup to several connected agents at once. Progress
+/// events carry the agent ID; the error lists every agent that failed.
+#[tauri::command]
+pub async fn broadcast_upload_game(
+    app: AppHandle,
+    state: State<'_, HubState>,
+    id: String,
+    agent_ids: Vec<String>,
+) -> Result<(), String> {
+    if agent_ids.is_empty() {
+        return Err("no agents selected".into());
+    }
+    let mut agents = Vec::with_capacity(agent_ids.len());
+    for agent_id in &agent_ids {
+        let connected = state
+            .connection_mgr
+            .get_connected_agent(agent_id)
+            .await
+            .ok_or_else(|| format!("agent '{agent_id}' is not connected"))?;
+        agents.push(connected);
+    }
+    deploy_setup_to(&app, &state, &id, agents, false).await
+}
+
+/// Deploys a saved setup to the connected agent, forwarding progress to
+/// the frontend as `upload:progress` events.
+async fn deploy_setup(
+    app: &AppHandle,
+    state: &HubState,
+    id: &str,
+    overwrite: bool,
+) -> Result<(), String> {
     let connected = state
         .connection_mgr
         .get_connected()
         .await
         .ok_or_else(|| "not connected to any agent".to_string())?;
+    deploy_setup_to(app, state, id, vec![connected], overwrite).await
+}
 
-    let mgr = state.connection_mgr.clone();
-    let agent_id = connected.agent.info.id.clone();
-    let adapter = DeployAdapter::with_agent_info(mgr, agent_id, &connected);
+/// Deploys a saved setup to the given agents concurrently and records each
+/// result in the deploy history.
+async fn deploy_setup_to(
+    app: &AppHandle,
+    state: &HubState,
+    id: &str,
+    agents: Vec<ConnectedAgent>,
+    overwrite: bool,
+) -> Result<(), String> {
+    let cfg = state.config.lock().await;
+    let mut setup = cfg
+        .game_setups
+        .iter()
+        .find(|s| s.id == id)
+        .cloned()
+        .ok_or_else(|| format!("game setup '{id}' not found"))?;
+    let default_launch_options = cfg.default_launch_options.clone();
+    let upload_rate_limit = cfg.upload_rate_limit;
+    let upload_chunk_concurrency = cfg.upload_chunk_concurrency;
+    let upload_compression =
+        capydeploy_hub_deploy::Compression::from_name(&cfg.upload_compression).unwrap_or_default();
+    drop(cfg);
+
+    // Variables are expanded per agent, against its own install directory.
+    setup.launch_options = launch_template(&setup, &default_launch_options).to_string();
+    validate_template(&setup.launch_options).map_err(|e| e.to_string())?;
+    for issue in check_launch_options(&setup.launch_options) {
+        tracing::warn!(setup = %setup.name, "launch options: {issue}");
+    }
+
+    let adapters: Vec<DeployAdapter> = agents
+        .iter()
+        .map(|connected| {
+            DeployAdapter::with_agent_info(
+                state.connection_mgr.clone(),
+                connected.agent.info.id.clone(),
+                connected,
+            )
+        })
+        .collect();
+    let setup_id = setup.id.clone();
+    let game_name = setup.name.clone();
+    let local_path = setup.local_path.clone();
 
     let artwork = capydeploy_hub_deploy::build_artwork_assignment(&setup);
     let deploy_config = capydeploy_hub_deploy::DeployConfig { setup, artwork };
 
     let mut orchestrator = capydeploy_hub_deploy::DeployOrchestrator::new();
+    if let Some(store) = &state.upload_resume {
+        orchestrator.set_resume_store(store.clone());
+    }
+    orchestrator.set_rate_limit(upload_rate_limit);
+    orchestrator.set_chunk_concurrency(upload_chunk_concurrency);
+    orchestrator.set_compression(upload_compression);
+    orchestrator.set_overwrite(overwrite);
 
     // Store cancel token so the UI can trigger cancellation.
     {
         let mut guard = state.deploy_cancel.lock().await;
         *guard = Some(orchestrator.cancel_token());
     }
+    *state.deploy_pause.lock().await = Some(orchestrator.pause_gate());
 
     let events_rx = orchestrator.take_events();
 
@@ -100,38 +330,71 @@ pub async fn upload_game(
             while let Some(event) = rx.recv().await {
                 let dto = match event {
                     capydeploy_hub_deploy::DeployEvent::Progress {
-                        progress, status, ..
+                        agent_id,
+                        progress,
+                        status,
                     } => UploadProgressDto {
                         progress,
                         status,
                         error: None,
                         done: false,
+                        agent_id,
+                        ..Default::default()
                     },
-                    capydeploy_hub_deploy::DeployEvent::Completed { .. } => UploadProgressDto {
-                        progress: 1.0,
-                        status: "completed".into(),
-                        error: None,
-                        done: true,
-                    },
-                    capydeploy_hub_deploy::DeployEvent::Failed { error, .. } => UploadProgressDto {
-                        progress: 0.0,
-                        status: "failed".into(),
-                        error: Some(error),
-                        done: true,
-                    },
+                    capydeploy_hub_deploy::DeployEvent::Completed { agent_id } => {
+                        UploadProgressDto {
+                            progress: 1.0,
+                            status: "completed".into(),
+                            error: None,
+                            done: true,
+                            agent_id,
+                            ..Default::default()
+                        }
+                    }
+                    capydeploy_hub_deploy::DeployEvent::Failed { agent_id, error } => {
+                        UploadProgressDto {
+                            progress: 0.0,
+                            status: "failed".into(),
+                            error: Some(error),
+                            done: true,
+                            agent_id,
+                            ..Default::default()
+                        }
+                    }
+                    capydeploy_hub_deploy::DeployEvent::Cancelled { agent_id } => {
+                        UploadProgressDto {
+                            progress: 0.0,
+                            status: "cancelled".into(),
+                            error: None,
+                            done: true,
+                            agent_id,
+                            ..Default::default()
+                        }
+                    }
                 };
                 let _ = app_clone.emit("upload:progress", &dto);
             }
         })
     });
 
-    let results = orchestrator.deploy(deploy_config, vec![&adapter]).await;
+    let started = std::time::Instant::now();
+    let results = orchestrator
+        .deploy(
+            deploy_config,
+            adapters
+                .iter()
+                .map(|a| a as &dyn capydeploy_hub_deploy::AgentConnection)
+                .collect(),
+        )
+        .await;
+    let duration_ms = started.elapsed().as_millis() as u64;
 
     // Clear cancel token now that deploy is done.
     {
         let mut guard = state.deploy_cancel.lock().await;
         *guard = None;
     }
+    *state.deploy_pause.lock().await = None;
 
     // Drop the orchestrator (and its events_tx sender) so the forwarder
     // channel closes and the task can drain remaining events.
@@ -140,20 +403,131 @@ pub async fn upload_game(
         let _ = handle.await;
     }
 
-    if let Some(result) = results.first()
-        && !result.success
-    {
-        return Err(result
-            .error
-            .clone()
-            .unwrap_or_else(|| "upload failed".into()));
+    let mut failures = Vec::new();
+    for (connected, result) in agents.iter().zip(&results) {
+        let record = DeployRecord {
+            id: String::new(),
+            setup_id: setup_id.clone(),
+            game_name: game_name.clone(),
+            agent_id: connected.agent.info.id.clone(),
+            agent_name: connected.agent.info.name.clone(),
+            finished_at: 0,
+            success: result.success,
+            error: result.error.clone().unwrap_or_default(),
+            total_bytes: 0,
+            app_id: result.app_id.unwrap_or_default(),
+            duration_ms,
+        };
+        record_deploy(state, record, local_path.clone()).await;
+
+        if !result.success {
+            failures.push((
+                connected.agent.info.name.clone(),
+                result
+                    .error
+                    .clone()
+                    .unwrap_or_else(|| "upload failed".into()),
+            ));
+        }
     }
 
-    Ok(())
+    match failures.as_slice() {
+        [] => Ok(()),
+        // A single-agent deploy reports the error as is.
+        [(_, error)] if agents.len() == 1 => Err(error.clone()),
+        _ => Err(failures
+            .iter()
+            .map(|(name, error)| format!("{name}: {error}"))
+            .collect::<Vec<_>>()
+            .join("; ")),
+    }
+}
+
+/// Appends a finished deploy to the history, measuring the local files.
+async fn record_deploy(state: &HubState, mut record: DeployRecord, local_path: String) {
+    let Some(history) = state.deploy_history.clone() else {
+        return;
+    };
+    let result = tokio::task::spawn_blocking(move || {
+        record.total_bytes = capydeploy_hub_deploy::scan_files_for_upload(Path::new(&local_path))
+            .map(|(_, size)| size)
+            .unwrap_or_default();
+        history.append(record)
+    })
+    .await;
+    if let Ok(Err(e)) = result {
+        tracing::warn!("failed to record deploy history: {e}");
+    }
+}
+
+/// Returns past deploys matching `filter`, newest first.
+#[tauri::command]
+pub async fn get_deploy_history(
+    state: State<'_, HubState>,
+    filter: Option<HistoryFilter>,
+) -> Result<Vec<DeployRecord>, String> {
+    let history = state
+        .deploy_history
+        .as_deref()
+        .ok_or_else(|| "deploy history unavailable".to_string())?;
+    Ok(history.query(&filter.unwrap_or_default()))
+}
+
+/// Estimates how long deploying a setup to the connected agent would take,
+/// from the agent's recent deploy throughput or, without history, a short
+/// bandwidth probe.
+#[tauri::command]
+pub async fn estimate_deploy(
+    state: State<'_, HubState>,
+    setup_id: String,
+) -> Result<DeployEstimate, String> {
+    let local_path = state
+        .config
+        .lock()
+        .await
+        .game_setups
+        .iter()
+        .find(|s| s.id == setup_id)
+        .map(|s| s.local_path.clone())
+        .ok_or_else(|| format!("game setup '{setup_id}' not found"))?;
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected to any agent".to_string())?;
+
+    let total_bytes = tokio::task::spawn_blocking(move || {
+        capydeploy_hub_deploy::scan_files_for_upload(Path::new(&local_path)).map(|(_, size)| size)
+    })
+    .await
+    .map_err(|e| e.to_string())?
+    .map_err(|e| e.to_string())?;
+
+    let agent_id = connected.agent.info.id.clone();
+    let history = state
+        .deploy_history
+        .as_deref()
+        .map(|h| {
+            h.query(&HistoryFilter {
+                agent_id: Some(agent_id.clone()),
+                ..Default::default()
+            })
+        })
+        .unwrap_or_default();
+    let adapter =
+        DeployAdapter::with_agent_info(state.connection_mgr.clone(), agent_id, &connected);
+    capydeploy_hub_deploy::estimate_deploy(&adapter, total_bytes, &history)
+        .await
+        .map_err(|e| e.to_string())
 }
 
+/// Cancels the running deploy, and the rest of the queue when it was
+/// started by `process_upload_queue`.
 #[tauri::command]
 pub async fn cancel_upload(state: State<'_, HubState>) -> Result<(), String> {
+    if let Some(token) = state.queue_cancel.lock().await.take() {
+        token.cancel();
+    }
     let mut guard = state.deploy_cancel.lock().await;
     if let Some(token) = guard.take() {
         token.cancel();
@@ -161,3 +535,232 @@ pub async fn cancel_upload(state: State<'_, HubState>) -> Result<(), String> {
     }
     Ok(())
 }
+
+/// Pauses the running deploy. Uploads over the TCP data channel aren't
+/// affected.
+#[tauri::command]
+pub async fn pause_upload(state: State<'_, HubState>) -> Result<(), String> {
+    let guard = state.deploy_pause.lock().await;
+    let gate = guard.as_ref().ok_or("no upload in progress")?;
+    gate.pause();
+    tracing::info!("deploy paused by user");
+    Ok(())
+}
+
+#[tauri::command]
+pub async fn resume_upload(state: State<'_, HubState>) -> Result<(), String> {
+    let guard = state.deploy_pause.lock().await;
+    let gate = guard.as_ref().ok_or("no upload in progress")?;
+    gate.resume();
+    tracing::info!("deploy resumed by user");
+    Ok(())
+}
+
+fn upload_queue(state: &HubState) -> Result<&UploadQueue, String> {
+    state
+        .upload_queue
+        .as_deref()
+        .ok_or_else(|| "upload queue unavailable".to_string())
+}
+
+/// Queues a setup for deploy to the connected agent and returns the queue ID.
+///
+/// With `scheduled_at` (Unix seconds) the deploy is held until then and
+/// started automatically once the agent is reachable.
+#[tauri::command]
+pub async fn enqueue_deploy(
+    state: State<'_, HubState>,
+    setup_id: String,
+    scheduled_at: Option<i64>,
+) -> Result<String, String> {
+    if !state
+        .config
+        .lock()
+        .await
+        .game_setups
+        .iter()
+        .any(|s| s.id == setup_id)
+    {
+        return Err(format!("game setup '{setup_id}' not found"));
+    }
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected to any agent".to_string())?;
+    upload_queue(&state)?
+        .enqueue_at(
+            &setup_id,
+            &connected.agent.info.id,
+            scheduled_at.unwrap_or_default(),
+        )
+        .map_err(|e| e.to_string())
+}
+
+#[tauri::command]
+pub async fn get_upload_queue(state: State<'_, HubState>) -> Result<Vec<QueuedDeploy>, String> {
+    Ok(upload_queue(&state)?.items())
+}
+
+#[tauri::command]
+pub async fn remove_from_upload_queue(
+    state: State<'_, HubState>,
+    id: String,
+) -> Result<bool, String> {
+    upload_queue(&state)?.remove(&id).map_err(|e| e.to_string())
+}
+
+/// Moves the listed queue items to the front, in the given order.
+#[tauri::command]
+pub async fn reorder_upload_queue(
+    state: State<'_, HubState>,
+    order: Vec<String>,
+) -> Result<Vec<QueuedDeploy>, String> {
+    let queue = upload_queue(&state)?;
+    queue.reorder(&order).map_err(|e| e.to_string())?;
+    Ok(queue.items())
+}
+
+/// Sets a queued deploy's priority; higher-priority items start first.
+#[tauri::command]
+pub async fn set_queued_deploy_priority(
+    state: State<'_, HubState>,
+    id: String,
+    priority: i32,
+) -> Result<bool, String> {
+    upload_queue(&state)?
+        .set_priority(&id, priority)
+        .map_err(|e| e.to_string())
+}
+
+#[tauri::command]
+pub async fn retry_queued_deploy(state: State<'_, HubState>, id: String) -> Result<bool, String> {
+    upload_queue(&state)?.retry(&id).map_err(|e| e.to_string())
+}
+
+/// Deploys the queued setups for the connected agent by priority and returns
+/// how many succeeded. Failed items stay in the queue.
+#[tauri::command]
+pub async fn process_upload_queue(
+    app: AppHandle,
+    state: State<'_, HubState>,
+) -> Result<usize, String> {
+    let queue = upload_queue(&state)?;
+    if state.deploy_cancel.lock().await.is_some() {
+        return Err("a deploy is already in progress".into());
+    }
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected to any agent".to_string())?;
+
+    let cancel = CancellationToken::new();
+    *state.queue_cancel.lock().await = Some(cancel.clone());
+    let hub_state: &HubState = &state;
+    let app = &app;
+    let result = process_queue(
+        queue,
+        &connected.agent.info.id,
+        &cancel,
+        move |item| async move { deploy_setup(app, hub_state, &item.setup_id, false).await },
+    )
+    .await
+    .map_err(|e| e.to_string());
+    *state.queue_cancel.lock().await = None;
+    result
+}
+
+/// How often a due scheduled deploy checks again for its agent.
+const SCHEDULE_RETRY: Duration = Duration::from_secs(30);
+
+/// Starts scheduled deploys as they come due, for the life of the Hub.
+/// A deploy waits while its agent is disconnected or another deploy runs.
+pub(crate) async fn run_deploy_schedule(app: AppHandle) {
+    let state = app.state::<HubState>();
+    let Some(queue) = state.upload_queue.clone() else {
+        return;
+    };
+
+    let hub_state: &HubState = &state;
+    let app = &app;
+    let ready = move |agent_id: String| async move {
+        hub_state.deploy_cancel.lock().await.is_none()
+            && hub_state
+                .connection_mgr
+                .get_connected()
+                .await
+                .is_some_and(|c| c.agent.info.id == agent_id)
+    };
+    let deploy = move |item: QueuedDeploy| async move {
+        deploy_setup(app, hub_state, &item.setup_id, false).await
+    };
+    if let Err(e) = run_schedule(&queue, SCHEDULE_RETRY, ready, deploy).await {
+        tracing::error!("deploy scheduler stopped: {e}");
+    }
+}
+
+#[tauri::command]
+pub async fn start_watch_deploy(
+    app: AppHandle,
+    state: State<'_, HubState>,
+    setup_id: String,
+) -> Result<(), String> {
+    let local_path = {
+        let cfg = state.config.lock().await;
+        cfg.game_setups
+            .iter()
+            .find(|s| s.id == setup_id)
+            .map(|s| s.local_path.clone())
+            .ok_or_else(|| format!("game setup '{setup_id}' not found"))?
+    };
+
+    let redeploy_app = app.clone();
+    let redeploy_id = setup_id.clone();
+    let redeploy: RedeployFn = Box::new(move || {
+        let app = redeploy_app.clone();
+        let id = redeploy_id.clone();
+        Box::pin(async move {
+            let state = app.state::<HubState>();
+            if state.deploy_cancel.lock().await.is_some() {
+                tracing::debug!("watch-deploy: deploy already in progress, skipping");
+                return;
+            }
+            // Every rebuild replaces the game deployed the time before.
+            if let Err(e) = deploy_setup(&app, &state, &id, true).await {
+                tracing::warn!(setup = %id, error = %e, "watch-deploy redeploy failed");
+            }
+        })
+    });
+
+    let session = WatchDeploy::start(Path::new(&local_path), DEFAULT_WATCH_DEBOUNCE, redeploy)
+        .map_err(|e| e.to_string())?;
+
+    // Replacing a previous session drops (and stops) it.
+    *state.watch_deploy.lock().await = Some(session);
+
+    let _ = app.emit(
+        "watch:status",
+        &WatchStatusDto {
+            active: true,
+            setup_id,
+        },
+    );
+    Ok(())
+}
+
+#[tauri::command]
+pub async fn stop_watch_deploy(app: AppHandle, state: State<'_, HubState>) -> Result<(), String> {
+    if let Some(session) = state.watch_deploy.lock().await.take() {
+        session.stop();
+        tracing::info!("watch-deploy stopped by user");
+    }
+    let _ = app.emit(
+        "watch:status",
+        &WatchStatusDto {
+            active: false,
+            setup_id: String::new(),
+        },
+    );
+    Ok(())
+}
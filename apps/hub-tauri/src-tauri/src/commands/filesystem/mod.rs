@@ -53,6 +53,7 @@ pub(super) fn emit_progress(
         status: status.to_string(),
         error: error.map(|s| s.to_string()),
         done,
+        ..Default::default()
     };
     let _ = app.emit("filebrowser:progress", &dto);
 }
@@ -100,10 +101,9 @@ pub(super) async fn agent_tcp_addr(
         .ok_or_else(|| "not connected".to_string())?;
     let agent_ip = connected
         .agent
-        .ips
-        .first()
+        .preferred_ip()
         .ok_or_else(|| "agent has no known IP address".to_string())?;
-    Ok(SocketAddr::new(*agent_ip, tcp_port))
+    Ok(SocketAddr::new(agent_ip, tcp_port))
 }
 
 pub(super) async fn count_remote_bytes(
@@ -1,18 +1,24 @@
 //! Installed games Tauri commands.
 
+use std::collections::HashSet;
+
 use tauri::State;
 
-use capydeploy_protocol::constants::MessageType;
-use capydeploy_protocol::messages::ConfigResponse;
+use capydeploy_hub_deploy::HistoryFilter;
+use capydeploy_hub_games::{ShortcutUpdate, WipePlan, WipeReport};
+
+use capydeploy_protocol::constants::{CAPABILITY_DELETE_BATCH, MessageType};
+use capydeploy_protocol::messages::{ConfigResponse, DeleteGamesBatchResponse, GameDeleteResult};
 
 use crate::agent_adapter::GamesAdapter;
 use crate::state::HubState;
-use crate::types::InstalledGameDto;
+use crate::types::{AppliedArtworkDto, GameArtworkDto, InstalledGameDto};
 
 #[tauri::command]
 pub async fn get_installed_games(
     state: State<'_, HubState>,
     _agent_id: String,
+    filter: Option<capydeploy_hub_games::GamesFilter>,
 ) -> Result<Vec<InstalledGameDto>, String> {
     let connected = state
         .connection_mgr
@@ -30,6 +36,8 @@ pub async fn get_installed_games(
         .await
         .map_err(|e| e.to_string())?;
 
+    let games = filter.unwrap_or_default().apply(games);
+
     Ok(games
         .into_iter()
         .map(|g| InstalledGameDto {
@@ -37,6 +45,9 @@ pub async fn get_installed_games(
             path: g.path,
             size: g.size,
             app_id: if g.app_id == 0 { None } else { Some(g.app_id) },
+            tags: g.tags,
+            size_bytes: g.size_bytes,
+            broken: g.broken,
         })
         .collect())
 }
@@ -65,6 +76,274 @@ pub async fn delete_game(
     Ok(())
 }
 
+/// Deletes several games at once. Agents that can't take a
+/// `delete_games_batch` get one `delete_game` per AppID instead.
+#[tauri::command]
+pub async fn delete_games(
+    state: State<'_, HubState>,
+    app_ids: Vec<u32>,
+) -> Result<DeleteGamesBatchResponse, String> {
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected".to_string())?;
+
+    let mgr = state.connection_mgr.clone();
+    let batch = connected.profile.supports(CAPABILITY_DELETE_BATCH);
+    let agent_id = connected.agent.info.id.clone();
+    let adapter = GamesAdapter::new(mgr, agent_id);
+
+    let games_mgr = capydeploy_hub_games::GamesManager::new(reqwest::Client::new());
+    if batch {
+        return games_mgr
+            .delete_games(&adapter, &app_ids, false)
+            .await
+            .map_err(|e| e.to_string());
+    }
+
+    let mut results = Vec::with_capacity(app_ids.len());
+    for app_id in app_ids {
+        let result = match games_mgr.delete_game(&adapter, app_id).await {
+            Ok(resp) => GameDeleteResult {
+                app_id,
+                success: true,
+                game_name: resp.game_name,
+                error: String::new(),
+            },
+            Err(e) => GameDeleteResult {
+                app_id,
+                success: false,
+                game_name: String::new(),
+                error: e.to_string(),
+            },
+        };
+        results.push(result);
+    }
+    Ok(DeleteGamesBatchResponse {
+        results,
+        steam_restarted: false,
+    })
+}
+
+/// AppIDs of the games this Hub successfully deployed to `agent_id`.
+fn deployed_app_ids(state: &HubState, agent_id: &str) -> Result<HashSet<u32>, String> {
+    let history = state
+        .deploy_history
+        .as_deref()
+        .ok_or_else(|| "deploy history unavailable".to_string())?;
+    let filter = HistoryFilter {
+        agent_id: Some(agent_id.to_string()),
+        ..Default::default()
+    };
+    Ok(history
+        .query(&filter)
+        .into_iter()
+        .filter(|r| r.success && r.app_id != 0)
+        .map(|r| r.app_id)
+        .collect())
+}
+
+/// Selects the connected agent's games that this Hub deployed.
+async fn plan_wipe(state: &HubState) -> Result<(GamesAdapter, WipePlan), String> {
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected".to_string())?;
+
+    let mgr = state.connection_mgr.clone();
+    let agent_id = connected.agent.info.id.clone();
+    let deployed = deployed_app_ids(state, &agent_id)?;
+    let adapter = GamesAdapter::new(mgr, agent_id);
+
+    let games_mgr = capydeploy_hub_games::GamesManager::new(reqwest::Client::new());
+    let games = games_mgr
+        .get_installed_games(&adapter)
+        .await
+        .map_err(|e| e.to_string())?;
+    Ok((adapter, WipePlan::new(games, &deployed)))
+}
+
+/// Lists the games [`wipe_deployed_games`] would remove, for the user to
+/// confirm.
+#[tauri::command]
+pub async fn plan_wipe_deployed_games(state: State<'_, HubState>) -> Result<WipePlan, String> {
+    plan_wipe(&state).await.map(|(_, plan)| plan)
+}
+
+/// Deletes the games the user confirmed from the plan of
+/// [`plan_wipe_deployed_games`], given by AppID, then restarts Steam once.
+/// Games this Hub didn't deploy are never touched, even if listed.
+#[tauri::command]
+pub async fn wipe_deployed_games(
+    state: State<'_, HubState>,
+    app_ids: Vec<u32>,
+) -> Result<WipeReport, String> {
+    let (adapter, mut plan) = plan_wipe(&state).await?;
+    plan.retain_confirmed(&app_ids.into_iter().collect());
+
+    let games_mgr = capydeploy_hub_games::GamesManager::new(reqwest::Client::new());
+    let report = games_mgr
+        .wipe_games(&adapter, &plan)
+        .await
+        .map_err(|e| e.to_string())?;
+    tracing::info!(
+        removed = report.removed.len(),
+        failed = report.failed.len(),
+        reclaimed_bytes = report.reclaimed_bytes,
+        "wiped deployed games"
+    );
+    Ok(report)
+}
+
+#[tauri::command]
+pub async fn rename_game(
+    state: State<'_, HubState>,
+    app_id: u32,
+    new_name: String,
+) -> Result<u32, String> {
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected".to_string())?;
+
+    let mgr = state.connection_mgr.clone();
+    let agent_id = connected.agent.info.id.clone();
+    let adapter = GamesAdapter::new(mgr, agent_id);
+
+    let games_mgr = capydeploy_hub_games::GamesManager::new(reqwest::Client::new());
+    let resp = games_mgr
+        .rename_game(&adapter, app_id, &new_name)
+        .await
+        .map_err(|e| e.to_string())?;
+    Ok(resp.app_id)
+}
+
+/// Changes the game's shortcut in place (name, launch options, tags, start
+/// dir), keeping its artwork. Returns the AppID it has afterwards.
+#[tauri::command]
+pub async fn update_shortcut(
+    state: State<'_, HubState>,
+    app_id: u32,
+    update: ShortcutUpdate,
+) -> Result<u32, String> {
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected".to_string())?;
+
+    let mgr = state.connection_mgr.clone();
+    let agent_id = connected.agent.info.id.clone();
+    let adapter = GamesAdapter::new(mgr, agent_id);
+
+    let games_mgr = capydeploy_hub_games::GamesManager::new(reqwest::Client::new());
+    let user_id = games_mgr
+        .first_steam_user(&adapter)
+        .await
+        .map_err(|e| e.to_string())?
+        .ok_or_else(|| "no Steam users on the agent".to_string())?;
+    let resp = games_mgr
+        .update_shortcut(&adapter, user_id, app_id, &update)
+        .await
+        .map_err(|e| e.to_string())?;
+    for warning in &resp.warnings {
+        tracing::warn!(app_id, "update shortcut: {warning}");
+    }
+    Ok(resp.app_id)
+}
+
+/// Starts the game through Steam on the connected agent. Returns whether
+/// Steam accepted the launch.
+#[tauri::command]
+pub async fn launch_game(state: State<'_, HubState>, app_id: u32) -> Result<bool, String> {
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected".to_string())?;
+
+    let mgr = state.connection_mgr.clone();
+    let agent_id = connected.agent.info.id.clone();
+    let adapter = GamesAdapter::new(mgr, agent_id);
+
+    let games_mgr = capydeploy_hub_games::GamesManager::new(reqwest::Client::new());
+    let resp = games_mgr
+        .launch_game(&adapter, app_id)
+        .await
+        .map_err(|e| e.to_string())?;
+    Ok(resp.launched)
+}
+
+/// Returns the game's shortcut definition as pretty JSON for sharing.
+#[tauri::command]
+pub async fn export_shortcut(state: State<'_, HubState>, app_id: u32) -> Result<String, String> {
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected".to_string())?;
+
+    let mgr = state.connection_mgr.clone();
+    let agent_id = connected.agent.info.id.clone();
+    let adapter = GamesAdapter::new(mgr, agent_id);
+
+    let games_mgr = capydeploy_hub_games::GamesManager::new(reqwest::Client::new());
+    let shortcut = games_mgr
+        .export_shortcut(&adapter, app_id)
+        .await
+        .map_err(|e| e.to_string())?;
+    serde_json::to_string_pretty(&shortcut).map_err(|e| e.to_string())
+}
+
+/// Reads back the artwork on the game's shortcut, as data URIs for
+/// preview.
+#[tauri::command]
+pub async fn get_game_artwork(
+    state: State<'_, HubState>,
+    app_id: u32,
+) -> Result<GameArtworkDto, String> {
+    let connected = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .ok_or_else(|| "not connected".to_string())?;
+
+    let mgr = state.connection_mgr.clone();
+    let agent_id = connected.agent.info.id.clone();
+    let adapter = GamesAdapter::new(mgr, agent_id);
+
+    let games_mgr = capydeploy_hub_games::GamesManager::new(reqwest::Client::new());
+    let resp = games_mgr
+        .get_artwork(&adapter, app_id)
+        .await
+        .map_err(|e| e.to_string())?;
+
+    use base64::Engine;
+    let images = resp
+        .images
+        .into_iter()
+        .map(|img| AppliedArtworkDto {
+            data_uri: if img.data.is_empty() {
+                String::new()
+            } else {
+                let b64 = base64::engine::general_purpose::STANDARD.encode(&img.data);
+                format!("data:{};base64,{b64}", img.content_type)
+            },
+            artwork_type: img.artwork_type,
+            content_type: img.content_type,
+            size: img.size,
+            too_large: img.too_large,
+        })
+        .collect();
+    Ok(GameArtworkDto {
+        images,
+        missing: resp.missing,
+    })
+}
+
 #[tauri::command]
 pub async fn update_game_artwork(
     state: State<'_, HubState>,
@@ -1,8 +1,8 @@
 //! Settings and hub info Tauri commands.
 
-use std::path::PathBuf;
+use tauri::{AppHandle, Emitter, State};
 
-use tauri::State;
+use capydeploy_steamgriddb::cache;
 
 use crate::state::HubState;
 use crate::types::{HubInfoDto, VersionInfoDto};
@@ -16,6 +16,21 @@ pub async fn get_version() -> Result<VersionInfoDto, String> {
     })
 }
 
+/// Emits `config:recovered` if settings were reset on startup because
+/// their files were corrupted. The frontend calls this once it listens for
+/// the event; later calls do nothing.
+#[tauri::command]
+pub async fn report_config_recovery(
+    app: AppHandle,
+    state: State<'_, HubState>,
+) -> Result<(), String> {
+    if let Some(recovery) = state.config_recovery.lock().await.take() {
+        app.emit("config:recovered", &recovery)
+            .map_err(|e| e.to_string())?;
+    }
+    Ok(())
+}
+
 #[tauri::command]
 pub async fn get_hub_info(state: State<'_, HubState>) -> Result<HubInfoDto, String> {
     let cfg = state.config.lock().await;
@@ -57,51 +72,128 @@ pub async fn set_steamgriddb_api_key(
 
 #[tauri::command]
 pub async fn get_cache_size() -> Result<u64, String> {
-    let cache_dir = image_cache_dir();
-    if !cache_dir.exists() {
-        return Ok(0);
-    }
-    let mut total = 0u64;
-    if let Ok(entries) = std::fs::read_dir(&cache_dir) {
-        for entry in entries.flatten() {
-            if let Ok(meta) = entry.metadata() {
-                total += meta.len();
-            }
-        }
-    }
-    Ok(total)
+    cache::get_cache_size().map_err(|e| e.to_string())
 }
 
 #[tauri::command]
 pub async fn clear_image_cache() -> Result<(), String> {
-    let cache_dir = image_cache_dir();
-    if cache_dir.exists() {
-        std::fs::remove_dir_all(&cache_dir).map_err(|e| e.to_string())?;
-    }
-    Ok(())
+    cache::clear_image_cache().map_err(|e| e.to_string())?;
+    cache::clear_metadata_cache().map_err(|e| e.to_string())
 }
 
 #[tauri::command]
 pub async fn open_cache_folder() -> Result<(), String> {
-    let cache_dir = image_cache_dir();
-    if !cache_dir.exists() {
-        std::fs::create_dir_all(&cache_dir).map_err(|e| e.to_string())?;
-    }
+    let cache_dir = cache::image_cache_dir().map_err(|e| e.to_string())?;
     open::that(&cache_dir).map_err(|e| e.to_string())
 }
 
 #[tauri::command]
-pub async fn get_image_cache_enabled() -> Result<bool, String> {
-    // Always enabled for now — matches Go behavior.
-    Ok(true)
+pub async fn get_image_cache_enabled(state: State<'_, HubState>) -> Result<bool, String> {
+    let cfg = state.config.lock().await;
+    Ok(cfg.image_cache_enabled)
+}
+
+#[tauri::command]
+pub async fn set_image_cache_enabled(
+    state: State<'_, HubState>,
+    enabled: bool,
+) -> Result<(), String> {
+    let mut cfg = state.config.lock().await;
+    cfg.image_cache_enabled = enabled;
+    cfg.save().map_err(|e| e.to_string())
+}
+
+#[tauri::command]
+pub async fn get_image_cache_max_size(state: State<'_, HubState>) -> Result<u64, String> {
+    let cfg = state.config.lock().await;
+    Ok(cfg.image_cache_max_size)
 }
 
+/// Sets the image cache size cap (0 = unlimited) and trims the cache to it.
 #[tauri::command]
-pub async fn set_image_cache_enabled(_enabled: bool) -> Result<(), String> {
-    // No-op for now.
+pub async fn set_image_cache_max_size(
+    state: State<'_, HubState>,
+    bytes: u64,
+) -> Result<(), String> {
+    let mut cfg = state.config.lock().await;
+    cfg.image_cache_max_size = bytes;
+    cfg.save().map_err(|e| e.to_string())?;
+    drop(cfg);
+
+    cache::set_image_cache_max_size(bytes);
+    let freed = tokio::task::spawn_blocking(cache::enforce_image_cache_max_size)
+        .await
+        .map_err(|e| e.to_string())?
+        .map_err(|e| e.to_string())?;
+    if freed > 0 {
+        tracing::info!(freed, "trimmed image cache to its new size cap");
+    }
     Ok(())
 }
 
+#[tauri::command]
+pub async fn get_upload_rate_limit(state: State<'_, HubState>) -> Result<u64, String> {
+    let cfg = state.config.lock().await;
+    Ok(cfg.upload_rate_limit)
+}
+
+/// Caps the bandwidth of game uploads, from the next deploy on.
+#[tauri::command]
+pub async fn set_upload_rate_limit(
+    state: State<'_, HubState>,
+    bytes_per_sec: u64,
+) -> Result<(), String> {
+    let mut cfg = state.config.lock().await;
+    cfg.upload_rate_limit = bytes_per_sec;
+    cfg.save().map_err(|e| e.to_string())
+}
+
+#[tauri::command]
+pub async fn get_upload_chunk_concurrency(state: State<'_, HubState>) -> Result<usize, String> {
+    let cfg = state.config.lock().await;
+    Ok(cfg.upload_chunk_concurrency)
+}
+
+/// Sets how many upload chunks are kept in flight per agent, from the next
+/// deploy on.
+#[tauri::command]
+pub async fn set_upload_chunk_concurrency(
+    state: State<'_, HubState>,
+    chunks: usize,
+) -> Result<(), String> {
+    if chunks == 0 {
+        return Err("at least one chunk must be in flight".into());
+    }
+    let mut cfg = state.config.lock().await;
+    cfg.upload_chunk_concurrency = chunks;
+    cfg.save().map_err(|e| e.to_string())
+}
+
+#[tauri::command]
+pub async fn get_upload_compression(state: State<'_, HubState>) -> Result<String, String> {
+    let cfg = state.config.lock().await;
+    let codec =
+        capydeploy_hub_deploy::Compression::from_name(&cfg.upload_compression).unwrap_or_default();
+    Ok(codec.name().to_string())
+}
+
+/// Sets the codec upload chunks are compressed with, from the next deploy
+/// on. Agents that don't support it get raw chunks.
+#[tauri::command]
+pub async fn set_upload_compression(
+    state: State<'_, HubState>,
+    codec: String,
+) -> Result<(), String> {
+    let codec = capydeploy_hub_deploy::Compression::from_name(&codec)
+        .ok_or_else(|| format!("unknown compression codec '{codec}'"))?;
+    let mut cfg = state.config.lock().await;
+    cfg.upload_compression = match codec {
+        capydeploy_hub_deploy::Compression::None => String::new(),
+        codec => codec.name().to_string(),
+    };
+    cfg.save().map_err(|e| e.to_string())
+}
+
 #[tauri::command]
 pub async fn get_game_log_directory(state: State<'_, HubState>) -> Result<String, String> {
     let cfg = state.config.lock().await;
@@ -117,28 +209,3 @@ pub async fn set_game_log_directory(
     cfg.game_log_dir = path;
     cfg.save().map_err(|e| e.to_string())
 }
-
-fn image_cache_dir() -> PathBuf {
-    #[cfg(target_os = "linux")]
-    {
-        let home = std::env::var("HOME").unwrap_or_else(|_| "/tmp".into());
-        PathBuf::from(home)
-            .join(".cache")
-            .join("capydeploy")
-            .join("images")
-    }
-    #[cfg(target_os = "windows")]
-    {
-        let local = std::env::var("LOCALAPPDATA").unwrap_or_else(|_| "C:\\Temp".into());
-        PathBuf::from(local)
-            .join("capydeploy")
-            .join("cache")
-            .join("images")
-    }
-    #[cfg(not(any(target_os = "linux", target_os = "windows")))]
-    {
-        PathBuf::from("/tmp")
-            .join("capydeploy-cache")
-            .join("images")
-    }
-}
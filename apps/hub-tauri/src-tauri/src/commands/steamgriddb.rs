@@ -2,38 +2,87 @@
 
 use tauri::State;
 
-use capydeploy_steamgriddb::{Client as SgdbClient, ImageData, SearchResult};
+use capydeploy_steamgriddb::{
+    Client as SgdbClient, DEFAULT_METADATA_CACHE_TTL, DEFAULT_PREFETCH_CONCURRENCY,
+    GRID_MIME_TYPES, ICON_MIME_TYPES, ImageData, ImageFilters, LOGO_MIME_TYPES, SearchResult,
+    cache,
+};
 
 use crate::state::HubState;
 use crate::types::ImageFiltersDto;
 
-/// Creates a SteamGridDB client from the current API key.
-async fn get_client(state: &State<'_, HubState>) -> Result<SgdbClient, String> {
+/// Creates a SteamGridDB client from the current API key. Search and
+/// listing responses are cached on disk along with the images, unless the
+/// image cache is disabled; `refresh` skips cached responses.
+async fn get_client(state: &State<'_, HubState>, refresh: bool) -> Result<SgdbClient, String> {
     let cfg = state.config.lock().await;
     if cfg.steamgriddb_api_key.is_empty() {
         return Err("SteamGridDB API key not set".into());
     }
-    SgdbClient::new(&cfg.steamgriddb_api_key).map_err(|e| e.to_string())
+    let client = SgdbClient::new(&cfg.steamgriddb_api_key).map_err(|e| e.to_string())?;
+    if !cfg.image_cache_enabled {
+        return Ok(client);
+    }
+    Ok(client
+        .with_metadata_cache_ttl(DEFAULT_METADATA_CACHE_TTL)
+        .with_metadata_refresh(refresh))
+}
+
+/// Converts the frontend filters, limiting formats to those the connected
+/// agent can apply. `None` means the agent supports none of the requested
+/// formats, so there is nothing to list.
+async fn agent_filters(
+    state: &State<'_, HubState>,
+    filters: ImageFiltersDto,
+    offered: &[&str],
+) -> Option<ImageFilters> {
+    let mut f = ImageFilters::from(filters);
+    let formats = state
+        .connection_mgr
+        .get_connected()
+        .await
+        .map(|c| c.agent.info.supported_image_formats)
+        .unwrap_or_default();
+    f.restrict_to_formats(&formats, offered).then_some(f)
 }
 
 #[tauri::command]
 pub async fn search_games(
     state: State<'_, HubState>,
     query: String,
+    refresh: Option<bool>,
 ) -> Result<Vec<SearchResult>, String> {
-    let client = get_client(&state).await?;
+    let client = get_client(&state, refresh.unwrap_or(false)).await?;
     client.search(&query).await.map_err(|e| e.to_string())
 }
 
+/// Finds the SteamGridDB game for a Steam AppID, so the artwork picker can
+/// select it without a name search.
+#[tauri::command]
+pub async fn lookup_by_steam_app_id(
+    state: State<'_, HubState>,
+    app_id: u32,
+    refresh: Option<bool>,
+) -> Result<SearchResult, String> {
+    let client = get_client(&state, refresh.unwrap_or(false)).await?;
+    client
+        .get_game_by_steam_app_id(app_id)
+        .await
+        .map_err(|e| e.to_string())
+}
+
 #[tauri::command]
 pub async fn get_grids(
     state: State<'_, HubState>,
     game_id: i32,
     filters: ImageFiltersDto,
     page: i32,
+    refresh: Option<bool>,
 ) -> Result<Vec<ImageData>, String> {
-    let client = get_client(&state).await?;
-    let f = capydeploy_steamgriddb::ImageFilters::from(filters);
+    let Some(f) = agent_filters(&state, filters, GRID_MIME_TYPES).await else {
+        return Ok(Vec::new());
+    };
+    let client = get_client(&state, refresh.unwrap_or(false)).await?;
     client
         .get_grids(game_id, Some(&f), page)
         .await
@@ -46,9 +95,12 @@ pub async fn get_heroes(
     game_id: i32,
     filters: ImageFiltersDto,
     page: i32,
+    refresh: Option<bool>,
 ) -> Result<Vec<ImageData>, String> {
-    let client = get_client(&state).await?;
-    let f = capydeploy_steamgriddb::ImageFilters::from(filters);
+    let Some(f) = agent_filters(&state, filters, GRID_MIME_TYPES).await else {
+        return Ok(Vec::new());
+    };
+    let client = get_client(&state, refresh.unwrap_or(false)).await?;
     client
         .get_heroes(game_id, Some(&f), page)
         .await
@@ -61,9 +113,12 @@ pub async fn get_logos(
     game_id: i32,
     filters: ImageFiltersDto,
     page: i32,
+    refresh: Option<bool>,
 ) -> Result<Vec<ImageData>, String> {
-    let client = get_client(&state).await?;
-    let f = capydeploy_steamgriddb::ImageFilters::from(filters);
+    let Some(f) = agent_filters(&state, filters, LOGO_MIME_TYPES).await else {
+        return Ok(Vec::new());
+    };
+    let client = get_client(&state, refresh.unwrap_or(false)).await?;
     client
         .get_logos(game_id, Some(&f), page)
         .await
@@ -76,38 +131,139 @@ pub async fn get_icons(
     game_id: i32,
     filters: ImageFiltersDto,
     page: i32,
+    refresh: Option<bool>,
 ) -> Result<Vec<ImageData>, String> {
-    let client = get_client(&state).await?;
-    let f = capydeploy_steamgriddb::ImageFilters::from(filters);
+    let Some(f) = agent_filters(&state, filters, ICON_MIME_TYPES).await else {
+        return Ok(Vec::new());
+    };
+    let client = get_client(&state, refresh.unwrap_or(false)).await?;
     client
         .get_icons(game_id, Some(&f), page)
         .await
         .map_err(|e| e.to_string())
 }
 
+/// Prefetches the images of one results page into the image cache.
+///
+/// Called by the artwork browser for the page after the one on screen.
+/// Returns immediately with the number of images queued; downloads run in
+/// the background. Does nothing when the image cache is disabled.
+#[tauri::command]
+pub async fn prefetch_artwork(
+    state: State<'_, HubState>,
+    game_id: i32,
+    art_type: String,
+    filters: ImageFiltersDto,
+    page: i32,
+) -> Result<usize, String> {
+    if !state.config.lock().await.image_cache_enabled {
+        return Ok(0);
+    }
+
+    let offered = match art_type.as_str() {
+        "grid" | "hero" => GRID_MIME_TYPES,
+        "logo" => LOGO_MIME_TYPES,
+        "icon" => ICON_MIME_TYPES,
+        other => return Err(format!("unknown artwork type: {other}")),
+    };
+    let Some(f) = agent_filters(&state, filters, offered).await else {
+        return Ok(0);
+    };
+    let client = get_client(&state, false).await?;
+    let images = match art_type.as_str() {
+        "grid" => client.get_grids(game_id, Some(&f), page).await,
+        "hero" => client.get_heroes(game_id, Some(&f), page).await,
+        "logo" => client.get_logos(game_id, Some(&f), page).await,
+        "icon" => client.get_icons(game_id, Some(&f), page).await,
+        other => return Err(format!("unknown artwork type: {other}")),
+    }
+    .map_err(|e| e.to_string())?;
+
+    // The browser only shows thumbnails; full-size images load on selection.
+    let urls: Vec<String> = images
+        .into_iter()
+        .map(|img| {
+            if img.thumb.is_empty() {
+                img.url
+            } else {
+                img.thumb
+            }
+        })
+        .collect();
+    let queued = urls.len();
+
+    tokio::spawn(async move {
+        match client
+            .prefetch_images(game_id, urls, DEFAULT_PREFETCH_CONCURRENCY)
+            .await
+        {
+            Ok(stats) => tracing::debug!(game_id, page, ?stats, "artwork prefetch finished"),
+            Err(e) => tracing::warn!(game_id, error = %e, "artwork prefetch failed"),
+        }
+    });
+
+    Ok(queued)
+}
+
+/// Returns a cached image as a data URL, or `None` when it isn't cached
+/// (or the cache is disabled) so the caller loads it from SteamGridDB.
+///
+/// Lets the artwork browser show thumbnails `prefetch_artwork` downloaded.
+#[tauri::command]
+pub async fn get_cached_artwork(
+    state: State<'_, HubState>,
+    url: String,
+    game_id: i32,
+) -> Result<Option<String>, String> {
+    if !state.config.lock().await.image_cache_enabled {
+        return Ok(None);
+    }
+    Ok(cache::get_cached_image(game_id, &url)
+        .ok()
+        .map(|(data, content_type)| to_data_url(&content_type, &data)))
+}
+
 #[tauri::command]
 pub async fn get_artwork_preview(
     state: State<'_, HubState>,
     url: String,
+    game_id: Option<i32>,
 ) -> Result<String, String> {
-    let client = get_client(&state).await?;
+    // Serve from the cache when the caller knows the game (prefetched pages).
+    let cache_game = match game_id {
+        Some(id) if state.config.lock().await.image_cache_enabled => Some(id),
+        _ => None,
+    };
+    if let Some(id) = cache_game
+        && let Ok((data, content_type)) = cache::get_cached_image(id, &url)
+    {
+        return Ok(to_data_url(&content_type, &data));
+    }
+
+    let client = get_client(&state, false).await?;
     let data = client
         .download_image(&url)
         .await
         .map_err(|e| e.to_string())?;
 
     // Detect content type from URL extension.
-    let content_type = if url.ends_with(".png") {
-        "image/png"
-    } else if url.ends_with(".webp") {
-        "image/webp"
-    } else if url.ends_with(".ico") {
-        "image/vnd.microsoft.icon"
+    let content_type = if url.ends_with(".ico") {
+        "image/vnd.microsoft.icon".to_string()
     } else {
-        "image/jpeg"
+        cache::content_type_for_url(&url)
     };
 
+    if let Some(id) = cache_game
+        && let Err(e) = cache::save_image_to_cache(id, &url, &data, &content_type)
+    {
+        tracing::warn!(url, error = %e, "failed to cache artwork preview");
+    }
+
+    Ok(to_data_url(&content_type, &data))
+}
+
+fn to_data_url(content_type: &str, data: &[u8]) -> String {
     use base64::Engine;
-    let b64 = base64::engine::general_purpose::STANDARD.encode(&data);
-    Ok(format!("data:{content_type};base64,{b64}"))
+    let b64 = base64::engine::general_purpose::STANDARD.encode(data);
+    format!("data:{content_type};base64,{b64}")
 }
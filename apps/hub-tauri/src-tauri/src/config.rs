@@ -6,6 +6,7 @@
 
 use std::path::PathBuf;
 
+use capydeploy_hub_settings::{backup_corrupt, write_atomic};
 use serde::{Deserialize, Serialize};
 
 // ---------------------------------------------------------------------------
@@ -21,6 +22,21 @@ struct HubIdentityFile {
     name: String,
     #[serde(default)]
     platform: String,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    manual_agents: Vec<ManualAgent>,
+}
+
+/// An Agent added by address, for networks where mDNS is blocked. The
+/// identity is kept so the Agent is listed even while it is offline.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ManualAgent {
+    pub id: String,
+    #[serde(default)]
+    pub name: String,
+    #[serde(default)]
+    pub platform: String,
+    pub host: String,
+    pub port: u16,
 }
 
 // ---------------------------------------------------------------------------
@@ -34,10 +50,24 @@ struct AppConfigFile {
     game_setups: Vec<capydeploy_hub_deploy::GameSetup>,
     #[serde(default)]
     steamgriddb_api_key: String,
-    #[serde(default)]
+    #[serde(default = "default_true")]
     image_cache_enabled: bool,
+    #[serde(default = "default_image_cache_max_size")]
+    image_cache_max_size: u64,
     #[serde(default)]
     game_log_directory: String,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    default_launch_options: String,
+    #[serde(default)]
+    upload_rate_limit: u64,
+    #[serde(default = "default_chunk_concurrency")]
+    upload_chunk_concurrency: usize,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    upload_compression: String,
+    #[serde(default = "default_true")]
+    auto_reconnect: bool,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    last_agent_id: String,
 }
 
 // ---------------------------------------------------------------------------
@@ -59,8 +89,47 @@ pub struct HubConfig {
     /// Directory for game log files.
     pub game_log_dir: String,
 
+    /// Whether SteamGridDB images are cached (and prefetched) on disk.
+    pub image_cache_enabled: bool,
+
+    /// Image cache size cap in bytes; 0 means unlimited.
+    pub image_cache_max_size: u64,
+
+    /// Launch option template used by setups without their own.
+    pub default_launch_options: String,
+
+    /// Upload bandwidth cap in bytes per second; 0 means unlimited.
+    pub upload_rate_limit: u64,
+
+    /// Upload chunks kept in flight per agent over WebSocket.
+    pub upload_chunk_concurrency: usize,
+
+    /// Codec for WebSocket upload chunks ("none", "gzip" or "zstd").
+    pub upload_compression: String,
+
+    /// Connect to the last used agent when discovery finds it at startup.
+    pub auto_reconnect: bool,
+
+    /// ID of the agent the Hub was last connected to.
+    pub last_agent_id: String,
+
     /// Saved game installation setups.
     pub game_setups: Vec<capydeploy_hub_deploy::GameSetup>,
+
+    /// Agents added by address (from capydeploy-hub/config.json).
+    pub manual_agents: Vec<ManualAgent>,
+}
+
+fn default_true() -> bool {
+    true
+}
+
+fn default_image_cache_max_size() -> u64 {
+    capydeploy_steamgriddb::DEFAULT_IMAGE_CACHE_MAX_SIZE
+}
+
+fn default_chunk_concurrency() -> usize {
+    capydeploy_hub_deploy::DEFAULT_CHUNK_CONCURRENCY
 }
 
 fn default_name() -> String {
@@ -90,79 +159,89 @@ impl Default for HubConfig {
             hub_id: default_hub_id(),
             steamgriddb_api_key: String::new(),
             game_log_dir: String::new(),
+            image_cache_enabled: true,
+            image_cache_max_size: default_image_cache_max_size(),
+            default_launch_options: String::new(),
+            upload_rate_limit: 0,
+            upload_chunk_concurrency: default_chunk_concurrency(),
+            upload_compression: String::new(),
+            auto_reconnect: true,
+            last_agent_id: String::new(),
             game_setups: Vec::new(),
+            manual_agents: Vec::new(),
         }
     }
 }
 
 impl HubConfig {
     /// Loads configuration from the Go Hub's JSON files.
-    pub fn load() -> anyhow::Result<Self> {
+    ///
+    /// A file that can't be parsed is moved aside and its settings start
+    /// from defaults; the backup paths are returned alongside the config
+    /// so the user can be told.
+    pub fn load() -> anyhow::Result<(Self, Vec<PathBuf>)> {
         let mut config = HubConfig::default();
+        let mut recovered = Vec::new();
 
         let identity_path = hub_identity_path()?;
-        if identity_path.exists() {
-            let content = std::fs::read_to_string(&identity_path)?;
-            if let Ok(identity) = serde_json::from_str::<HubIdentityFile>(&content) {
-                if !identity.id.is_empty() {
-                    config.hub_id = identity.id;
-                }
-                if !identity.name.is_empty() {
-                    config.name = identity.name;
-                }
-            } else {
-                tracing::warn!(
-                    path = %identity_path.display(),
-                    "failed to parse hub identity, using defaults"
-                );
+        if let Some(identity) = read_json::<HubIdentityFile>(&identity_path, &mut recovered)? {
+            if !identity.id.is_empty() {
+                config.hub_id = identity.id;
             }
+            if !identity.name.is_empty() {
+                config.name = identity.name;
+            }
+            config.manual_agents = identity.manual_agents;
         }
 
         let app_path = app_config_path()?;
-        if app_path.exists() {
-            let content = std::fs::read_to_string(&app_path)?;
-            if let Ok(app) = serde_json::from_str::<AppConfigFile>(&content) {
-                config.steamgriddb_api_key = app.steamgriddb_api_key;
-                config.game_log_dir = app.game_log_directory;
-                config.game_setups = app.game_setups;
-            } else {
-                tracing::warn!(
-                    path = %app_path.display(),
-                    "failed to parse app config, using defaults"
-                );
-            }
+        if let Some(app) = read_json::<AppConfigFile>(&app_path, &mut recovered)? {
+            config.steamgriddb_api_key = app.steamgriddb_api_key;
+            config.game_log_dir = app.game_log_directory;
+            config.image_cache_enabled = app.image_cache_enabled;
+            config.image_cache_max_size = app.image_cache_max_size;
+            config.default_launch_options = app.default_launch_options;
+            config.upload_rate_limit = app.upload_rate_limit;
+            config.upload_chunk_concurrency = app.upload_chunk_concurrency;
+            config.upload_compression = app.upload_compression;
+            config.auto_reconnect = app.auto_reconnect;
+            config.last_agent_id = app.last_agent_id;
+            config.game_setups = app.game_setups;
         }
 
-        Ok(config)
+        Ok((config, recovered))
     }
 
-    /// Saves configuration back to both JSON files.
+    /// Saves configuration back to both JSON files, replacing each
+    /// atomically.
     pub fn save(&self) -> anyhow::Result<()> {
         let identity_path = hub_identity_path()?;
-        if let Some(parent) = identity_path.parent() {
-            std::fs::create_dir_all(parent)?;
-        }
         let identity = HubIdentityFile {
             id: self.hub_id.clone(),
             name: self.name.clone(),
             platform: std::env::consts::OS.into(),
+            manual_agents: self.manual_agents.clone(),
         };
         let identity_json = serde_json::to_string_pretty(&identity)?;
-        std::fs::write(&identity_path, &identity_json)?;
+        write_atomic(&identity_path, identity_json.as_bytes())?;
         set_permissions_0600(&identity_path);
 
         let app_path = app_config_path()?;
-        if let Some(parent) = app_path.parent() {
-            std::fs::create_dir_all(parent)?;
-        }
         let app = AppConfigFile {
             game_setups: self.game_setups.clone(),
             steamgriddb_api_key: self.steamgriddb_api_key.clone(),
-            image_cache_enabled: true,
+            image_cache_enabled: self.image_cache_enabled,
+            image_cache_max_size: self.image_cache_max_size,
             game_log_directory: self.game_log_dir.clone(),
+            default_launch_options: self.default_launch_options.clone(),
+            upload_rate_limit: self.upload_rate_limit,
+            upload_chunk_concurrency: self.upload_chunk_concurrency,
+            upload_compression: self.upload_compression.clone(),
+            auto_reconnect: self.auto_reconnect,
+            last_agent_id: self.last_agent_id.clone(),
         };
         let app_json = serde_json::to_string_pretty(&app)?;
-        std::fs::write(&app_path, &app_json)?;
+        write_atomic(&app_path, app_json.as_bytes())?;
         set_permissions_0600(&app_path);
 
         tracing::debug!("configuration saved");
@@ -170,6 +249,31 @@ impl HubConfig {
     }
 }
 
+/// Reads a JSON config file, `None` if it doesn't exist. A corrupted file
+/// is backed up, its backup path pushed to `recovered`, and `None` returned.
+fn read_json<T: serde::de::DeserializeOwned>(
+    path: &std::path::Path,
+    recovered: &mut Vec<PathBuf>,
+) -> anyhow::Result<Option<T>> {
+    if !path.exists() {
+        return Ok(None);
+    }
+    let content = std::fs::read(path)?;
+    match serde_json::from_slice(&content) {
+        Ok(value) => Ok(Some(value)),
+        Err(e) => {
+            let backup = backup_corrupt(path)?;
+            tracing::warn!(
+                path = %path.display(),
+                backup = %backup.display(),
+                "config file is corrupted ({e}), reset to defaults"
+            );
+            recovered.push(backup);
+            Ok(None)
+        }
+    }
+}
+
 fn set_permissions_0600(path: &std::path::Path) {
     #[cfg(unix)]
     {
@@ -189,6 +293,28 @@ pub fn token_store_path() -> Option<PathBuf> {
         .map(|d| d.join("capydeploy-hub").join("tokens.json"))
 }
 
+/// Path of the persisted upload queue: `~/.config/capydeploy-hub/upload_queue.json`.
+pub fn upload_queue_path() -> Option<PathBuf> {
+    config_base_dir()
+        .ok()
+        .map(|d| d.join("capydeploy-hub").join("upload_queue.json"))
+}
+
+/// Path of the resume manifests of interrupted uploads:
+/// `~/.config/capydeploy-hub/upload_resume.json`.
+pub fn upload_resume_path() -> Option<PathBuf> {
+    config_base_dir()
+        .ok()
+        .map(|d| d.join("capydeploy-hub").join("upload_resume.json"))
+}
+
+/// Path of the deployment history: `~/.config/capydeploy-hub/deploy_history.json`.
+pub fn deploy_history_path() -> Option<PathBuf> {
+    config_base_dir()
+        .ok()
+        .map(|d| d.join("capydeploy-hub").join("deploy_history.json"))
+}
+
 fn hub_identity_path() -> anyhow::Result<PathBuf> {
     let config_dir = config_base_dir()?;
     Ok(config_dir.join("capydeploy-hub").join("config.json"))
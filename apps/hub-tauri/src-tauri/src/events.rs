@@ -9,11 +9,14 @@ use tracing::{debug, warn};
 use capydeploy_hub_connection::{ConnectionEvent, ConnectionManager, ConnectionState};
 use capydeploy_protocol::console_log::ConsoleLogBatch;
 use capydeploy_protocol::constants::MessageType;
+use capydeploy_protocol::messages::AgentLogLine;
 use capydeploy_protocol::telemetry::TelemetryStatusEvent;
 
+use crate::commands::connection::auto_connect_last_agent;
 use crate::state::HubState;
 use crate::types::{
-    ConnectionStatusDto, DiscoveredAgentDto, PairingRequiredDto, ReconnectingDto, UploadProgressDto,
+    AgentMovedDto, ConnectionStatusDto, DiscoveredAgentDto, NetworkChangedDto, PairingRequiredDto,
+    QueueResumableDto, ReconnectingDto, UploadProgressDto,
 };
 
 /// Main event loop that bridges Rust events to the Tauri frontend.
@@ -28,14 +31,10 @@ pub async fn event_loop(handle: AppHandle, mgr: Arc<ConnectionManager>) {
 
     while let Some(event) = rx.recv().await {
         match event {
-            ConnectionEvent::AgentFound(agent) => {
-                let dto = DiscoveredAgentDto::from(&agent);
-                let _ = handle.emit("discovery:agent-found", &dto);
-            }
-
-            ConnectionEvent::AgentUpdated(agent) => {
+            ConnectionEvent::AgentFound(agent) | ConnectionEvent::AgentUpdated(agent) => {
                 let dto = DiscoveredAgentDto::from(&agent);
                 let _ = handle.emit("discovery:agent-found", &dto);
+                tauri::async_runtime::spawn(auto_connect_last_agent(handle.clone(), agent));
             }
 
             ConnectionEvent::AgentLost(id) => {
@@ -46,8 +45,14 @@ pub async fn event_loop(handle: AppHandle, mgr: Arc<ConnectionManager>) {
                 let status = match state {
                     ConnectionState::Connected => {
                         if let Some(connected) = mgr.get_connected().await {
+                            // `connection:changed` follows the active agent;
+                            // other agents are listed by get_connected_agents.
+                            if connected.agent.info.id != agent_id {
+                                continue;
+                            }
                             let dto = ConnectionStatusDto::from_connected(&connected);
                             let _ = handle.emit("connection:changed", &dto);
+                            remember_last_agent(&handle, &agent_id).await;
 
                             // Emit initial telemetry/console-log status from agent_status
                             // so the frontend doesn't have to wait for a push event.
@@ -63,21 +68,41 @@ pub async fn event_loop(handle: AppHandle, mgr: Arc<ConnectionManager>) {
                             };
                             let _ = handle.emit("consolelog:status", &cl);
 
+                            // Offer to resume deploys queued for this agent
+                            // (possibly before a Hub restart).
+                            let hub_state = handle.state::<HubState>();
+                            if let Some(queue) = &hub_state.upload_queue {
+                                let pending = queue.pending_count(&connected.agent.info.id);
+                                if pending > 0 {
+                                    let dto = QueueResumableDto {
+                                        agent_id: connected.agent.info.id.clone(),
+                                        pending,
+                                    };
+                                    let _ = handle.emit("queue:resumable", &dto);
+                                }
+                            }
+
                             continue;
                         }
                         ConnectionStatusDto::disconnected()
                     }
                     _ => {
-                        // Clean up hub state when an agent fully disconnects.
-                        if matches!(state, ConnectionState::Disconnected) {
+                        // Clean up hub state when an agent fully disconnects;
+                        // keep it while reconnecting.
+                        if matches!(state, ConnectionState::Disconnected | ConnectionState::Lost) {
                             let hub_state = handle.state::<HubState>();
                             hub_state.telemetry_hub.lock().await.remove_agent(&agent_id);
                             hub_state.console_hub.lock().await.remove_agent(&agent_id);
                         }
+                        if mgr
+                            .get_connected()
+                            .await
+                            .is_some_and(|active| active.agent.info.id != agent_id)
+                        {
+                            continue;
+                        }
 
-                        let mut dto = ConnectionStatusDto::disconnected();
-                        dto.agent_id = agent_id;
-                        dto
+                        ConnectionStatusDto::not_connected(agent_id, &state)
                     }
                 };
                 let _ = handle.emit("connection:changed", &status);
@@ -119,6 +144,58 @@ pub async fn event_loop(handle: AppHandle, mgr: Arc<ConnectionManager>) {
                 let _ = handle.emit("protocol:version-warning", &dto);
             }
 
+            ConnectionEvent::ClockSkew {
+                agent_id,
+                offset_ms,
+                message,
+            } => {
+                #[derive(serde::Serialize, Clone)]
+                #[serde(rename_all = "camelCase")]
+                struct ClockSkewDto {
+                    agent_id: String,
+                    offset_ms: i64,
+                    message: String,
+                }
+                let dto = ClockSkewDto {
+                    agent_id,
+                    offset_ms,
+                    message,
+                };
+                let _ = handle.emit("connection:clock-skew", &dto);
+            }
+
+            ConnectionEvent::NetworkChanged { addresses } => {
+                let dto = NetworkChangedDto {
+                    addresses: addresses.iter().map(|ip| ip.to_string()).collect(),
+                };
+                let _ = handle.emit("network:changed", &dto);
+            }
+
+            ConnectionEvent::AgentMoved {
+                agent_id,
+                old_ips,
+                new_ips,
+            } => {
+                let dto = AgentMovedDto {
+                    agent_id: agent_id.clone(),
+                    old_ips: old_ips.iter().map(|ip| ip.to_string()).collect(),
+                    new_ips: new_ips.iter().map(|ip| ip.to_string()).collect(),
+                };
+                let _ = handle.emit("discovery:agent-moved", &dto);
+
+                // The status shows the agent's addresses.
+                if let Some(connected) = mgr.get_connected().await
+                    && connected.agent.info.id == agent_id
+                {
+                    let dto = ConnectionStatusDto::from_connected(&connected);
+                    let _ = handle.emit("connection:changed", &dto);
+                }
+            }
+
+            ConnectionEvent::DiscoveryPaused(paused) => {
+                let _ = handle.emit("discovery:paused", paused);
+            }
+
             ConnectionEvent::AgentEvent {
                 agent_id,
                 msg_type,
@@ -155,6 +232,30 @@ pub async fn event_loop(handle: AppHandle, mgr: Arc<ConnectionManager>) {
                         }
                     }
 
+                    MessageType::ConfigChanged => {
+                        // The connection manager already refreshed its cache.
+                        if let Some(config) = message
+                            .parse_payload::<capydeploy_protocol::messages::ConfigChangedEvent>()
+                            .ok()
+                            .flatten()
+                        {
+                            let _ = handle.emit("agent:config-changed", &config);
+                            let tel = TelemetryStatusEvent {
+                                enabled: config.telemetry_enabled,
+                                interval: config.telemetry_interval,
+                            };
+                            let _ = handle.emit("telemetry:status", &tel);
+
+                            // The status shows the agent's name.
+                            if let Some(connected) = mgr.get_connected().await
+                                && connected.agent.info.id == agent_id
+                            {
+                                let dto = ConnectionStatusDto::from_connected(&connected);
+                                let _ = handle.emit("connection:changed", &dto);
+                            }
+                        }
+                    }
+
                     MessageType::ConsoleLogData => {
                         if let Some(batch) =
                             message.parse_payload::<ConsoleLogBatch>().ok().flatten()
@@ -168,6 +269,12 @@ pub async fn event_loop(handle: AppHandle, mgr: Arc<ConnectionManager>) {
                         }
                     }
 
+                    MessageType::LogLine => {
+                        if let Some(line) = message.parse_payload::<AgentLogLine>().ok().flatten() {
+                            let _ = handle.emit("logs:line", &line);
+                        }
+                    }
+
                     MessageType::ConsoleLogStatus => {
                         if let Some(status) = message
                             .parse_payload::<capydeploy_protocol::console_log::ConsoleLogStatusEvent>()
@@ -185,25 +292,26 @@ pub async fn event_loop(handle: AppHandle, mgr: Arc<ConnectionManager>) {
 
                     MessageType::UploadProgress => {
                         if let Some(progress) = message
-                            .parse_payload::<capydeploy_protocol::types::UploadProgress>()
+                            .parse_payload::<capydeploy_protocol::messages::UploadProgressEvent>()
                             .ok()
                             .flatten()
                         {
-                            // percentage() returns 0-100, frontend expects 0.0-1.0.
+                            // Protocol uses 0-100 scale, frontend expects 0.0-1.0.
+                            // Completion and failure arrive as operation events.
                             let dto = UploadProgressDto {
-                                progress: progress.percentage() / 100.0,
-                                status: format!("{:?}", progress.status),
-                                error: if progress.error.is_empty() {
-                                    None
-                                } else {
-                                    Some(progress.error.clone())
-                                },
-                                done: matches!(
-                                    progress.status,
-                                    capydeploy_protocol::types::UploadStatus::Completed
-                                        | capydeploy_protocol::types::UploadStatus::Failed
-                                        | capydeploy_protocol::types::UploadStatus::Cancelled
-                                ),
+                                progress: progress.percentage / 100.0,
+                                status: "progress".into(),
+                                error: None,
+                                done: false,
+                                agent_id: agent_id.clone(),
+                                file_progress: (progress.file_total_bytes > 0).then(|| {
+                                    progress.file_transferred_bytes as f64
+                                        / progress.file_total_bytes as f64
+                                }),
+                                bytes_per_sec: (progress.bytes_per_sec > 0.0)
+                                    .then_some(progress.bytes_per_sec),
+                                eta_secs: progress.estimated_seconds_remaining,
+                                current_file: progress.current_file,
                             };
                             let _ = handle.emit("upload:progress", &dto);
                         }
@@ -252,6 +360,8 @@ pub async fn event_loop(handle: AppHandle, mgr: Arc<ConnectionManager>) {
                                     Some(evt.message.clone())
                                 },
                                 done: is_terminal,
+                                agent_id: agent_id.clone(),
+                                ..Default::default()
                             };
                             let _ = handle.emit("upload:progress", &dto);
                         }
@@ -265,3 +375,16 @@ pub async fn event_loop(handle: AppHandle, mgr: Arc<ConnectionManager>) {
         }
     }
 }
+
+/// Saves the active agent so the next start can connect to it again.
+async fn remember_last_agent(handle: &AppHandle, agent_id: &str) {
+    let hub_state = handle.state::<HubState>();
+    let mut cfg = hub_state.config.lock().await;
+    if cfg.last_agent_id == agent_id {
+        return;
+    }
+    cfg.last_agent_id = agent_id.to_string();
+    if let Err(e) = cfg.save() {
+        warn!("failed to save last agent: {e}");
+    }
+}
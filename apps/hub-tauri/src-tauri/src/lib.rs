@@ -6,11 +6,13 @@ mod state;
 mod types;
 
 use std::sync::Arc;
+use std::sync::atomic::AtomicBool;
 
 use tracing_subscriber::EnvFilter;
 
 use capydeploy_hub_connection::ConnectionManager;
 use capydeploy_hub_connection::pairing::TokenStore;
+use capydeploy_hub_deploy::{DEFAULT_HISTORY_LIMIT, DeployHistory, ResumeStore, UploadQueue};
 use capydeploy_hub_telemetry::TelemetryHub;
 
 use config::HubConfig;
@@ -24,7 +26,8 @@ pub fn run() {
         )
         .init();
 
-    let cfg = HubConfig::load().unwrap_or_default();
+    let (cfg, recovered) = HubConfig::load().unwrap_or_default();
+    capydeploy_steamgriddb::cache::set_image_cache_max_size(cfg.image_cache_max_size);
 
     let identity = capydeploy_hub_connection::HubIdentity {
         name: cfg.name.clone(),
@@ -42,9 +45,47 @@ pub fn run() {
         })
         .map(Arc::new);
 
+    let tokens_backup = token_store
+        .as_ref()
+        .and_then(|s| s.recovered_backup().map(|p| p.to_path_buf()));
+    let config_recovery =
+        (!recovered.is_empty() || tokens_backup.is_some()).then(|| types::ConfigRecoveredDto {
+            backups: recovered
+                .iter()
+                .chain(&tokens_backup)
+                .map(|p| p.display().to_string())
+                .collect(),
+            tokens_reset: tokens_backup.is_some(),
+        });
+
     let mgr = Arc::new(ConnectionManager::new(identity, token_store));
     let mgr_shutdown = mgr.clone();
 
+    let upload_queue = config::upload_queue_path()
+        .and_then(|path| {
+            UploadQueue::new(path)
+                .map_err(|e| tracing::warn!("failed to load upload queue: {e}"))
+                .ok()
+        })
+        .map(Arc::new);
+
+    let upload_resume = config::upload_resume_path()
+        .and_then(|path| {
+            ResumeStore::new(path)
+                .map_err(|e| tracing::warn!("failed to load upload resume state: {e}"))
+                .ok()
+        })
+        .map(Arc::new);
+
+    let deploy_history = config::deploy_history_path()
+        .and_then(|path| {
+            DeployHistory::new(path, DEFAULT_HISTORY_LIMIT)
+                .map_err(|e| tracing::warn!("failed to load deploy history: {e}"))
+                .ok()
+        })
+        .map(Arc::new);
+
+    let auto_connect = cfg.auto_reconnect && !cfg.last_agent_id.is_empty();
     let hub_state = HubState {
         connection_mgr: mgr.clone(),
         telemetry_hub: Arc::new(tokio::sync::Mutex::new(TelemetryHub::new())),
@@ -53,6 +94,15 @@ pub fn run() {
         )),
         config: Arc::new(tokio::sync::Mutex::new(cfg)),
         deploy_cancel: Arc::new(tokio::sync::Mutex::new(None)),
+        queue_cancel: Arc::new(tokio::sync::Mutex::new(None)),
+        deploy_pause: Arc::new(tokio::sync::Mutex::new(None)),
+        watch_deploy: Arc::new(tokio::sync::Mutex::new(None)),
+        upload_queue,
+        upload_resume,
+        deploy_history,
+        provision_code: Arc::new(tokio::sync::Mutex::new(None)),
+        config_recovery: Arc::new(tokio::sync::Mutex::new(config_recovery)),
+        auto_connect_armed: Arc::new(AtomicBool::new(auto_connect)),
     };
 
     let fs_transfer_state = commands::filesystem::FsTransferState::new();
@@ -68,17 +118,46 @@ pub fn run() {
             tauri::async_runtime::spawn(async move {
                 events::event_loop(handle, mgr_clone).await;
             });
+            tauri::async_runtime::spawn(commands::deploy::run_deploy_schedule(
+                app.handle().clone(),
+            ));
+            tauri::async_runtime::spawn(commands::connection::restore_manual_agents(
+                app.handle().clone(),
+            ));
             Ok(())
         })
         .invoke_handler(tauri::generate_handler![
             // Connection
             commands::connection::get_discovered_agents,
             commands::connection::refresh_discovery,
+            commands::connection::pause_discovery,
+            commands::connection::resume_discovery,
+            commands::connection::get_discovery_paused,
+            commands::connection::add_manual_agent,
+            commands::connection::remove_manual_agent,
             commands::connection::connect_agent,
+            commands::connection::get_auto_reconnect,
+            commands::connection::set_auto_reconnect,
+            commands::connection::repair_agent,
             commands::connection::disconnect_agent,
+            commands::connection::connect_additional_agent,
+            commands::connection::disconnect_agent_by_id,
+            commands::connection::get_connected_agents,
             commands::connection::get_connection_status,
             commands::connection::confirm_pairing,
             commands::connection::cancel_pairing,
+            commands::connection::run_agent_self_test,
+            commands::connection::provision_agent,
+            commands::connection::can_deploy,
+            commands::connection::browse_agent_directory,
+            commands::connection::get_agent_steam_libraries,
+            commands::connection::get_agent_connected_hubs,
+            commands::connection::get_steam_login_state,
+            commands::connection::get_agent_steam_status,
+            commands::connection::check_agent_cef,
+            commands::connection::restart_agent_steam,
+            commands::connection::import_agent_token,
+            commands::connection::export_agent_token,
             // Settings
             commands::settings::get_version,
             commands::settings::get_hub_info,
@@ -91,31 +170,74 @@ pub fn run() {
             commands::settings::open_cache_folder,
             commands::settings::get_image_cache_enabled,
             commands::settings::set_image_cache_enabled,
+            commands::settings::get_image_cache_max_size,
+            commands::settings::set_image_cache_max_size,
+            commands::settings::get_upload_rate_limit,
+            commands::settings::set_upload_rate_limit,
+            commands::settings::get_upload_chunk_concurrency,
+            commands::settings::set_upload_chunk_concurrency,
+            commands::settings::get_upload_compression,
+            commands::settings::set_upload_compression,
+            commands::settings::report_config_recovery,
             commands::settings::get_game_log_directory,
             commands::settings::set_game_log_directory,
             // Deploy
             commands::deploy::get_game_setups,
             commands::deploy::add_game_setup,
+            commands::deploy::create_setup_from_dropped_path,
+            commands::deploy::import_shortcut_setup,
             commands::deploy::update_game_setup,
             commands::deploy::remove_game_setup,
+            commands::deploy::get_default_launch_options,
+            commands::deploy::set_default_launch_options,
+            commands::deploy::preview_launch_options,
+            commands::deploy::check_launch_option_issues,
             commands::deploy::upload_game,
+            commands::deploy::preview_upload_game,
+            commands::deploy::broadcast_upload_game,
             commands::deploy::cancel_upload,
+            commands::deploy::pause_upload,
+            commands::deploy::resume_upload,
+            commands::deploy::start_watch_deploy,
+            commands::deploy::stop_watch_deploy,
+            commands::deploy::enqueue_deploy,
+            commands::deploy::get_upload_queue,
+            commands::deploy::remove_from_upload_queue,
+            commands::deploy::reorder_upload_queue,
+            commands::deploy::set_queued_deploy_priority,
+            commands::deploy::retry_queued_deploy,
+            commands::deploy::process_upload_queue,
+            commands::deploy::get_deploy_history,
+            commands::deploy::estimate_deploy,
             // Games
             commands::games::get_installed_games,
             commands::games::delete_game,
+            commands::games::delete_games,
+            commands::games::plan_wipe_deployed_games,
+            commands::games::wipe_deployed_games,
+            commands::games::rename_game,
+            commands::games::update_shortcut,
+            commands::games::launch_game,
+            commands::games::export_shortcut,
+            commands::games::get_game_artwork,
             commands::games::update_game_artwork,
             commands::games::set_game_log_wrapper,
             commands::games::get_agent_install_path,
             // SteamGridDB
             commands::steamgriddb::search_games,
+            commands::steamgriddb::lookup_by_steam_app_id,
             commands::steamgriddb::get_grids,
             commands::steamgriddb::get_heroes,
             commands::steamgriddb::get_logos,
             commands::steamgriddb::get_icons,
+            commands::steamgriddb::prefetch_artwork,
+            commands::steamgriddb::get_cached_artwork,
             commands::steamgriddb::get_artwork_preview,
             // Console log
             commands::console_log::set_console_log_filter,
             commands::console_log::set_console_log_enabled,
+            commands::console_log::set_agent_verbose,
+            commands::console_log::subscribe_agent_logs,
             // File dialogs
             commands::files::select_folder,
             commands::files::select_artwork_file,
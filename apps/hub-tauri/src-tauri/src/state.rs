@@ -1,13 +1,16 @@
 use std::sync::Arc;
+use std::sync::atomic::AtomicBool;
 
 use tokio::sync::Mutex;
 use tokio_util::sync::CancellationToken;
 
 use capydeploy_hub_connection::ConnectionManager;
 use capydeploy_hub_console_log::ConsoleLogHub;
+use capydeploy_hub_deploy::{DeployHistory, PauseGate, ResumeStore, UploadQueue, WatchDeploy};
 use capydeploy_hub_telemetry::TelemetryHub;
 
 use crate::config::HubConfig;
+use crate::types::ConfigRecoveredDto;
 
 /// Shared application state managed by Tauri.
 pub struct HubState {
@@ -17,4 +20,26 @@ pub struct HubState {
     pub config: Arc<Mutex<HubConfig>>,
     /// Active deploy cancellation token (set during upload, cleared after).
     pub deploy_cancel: Arc<Mutex<Option<CancellationToken>>>,
+    /// Pause switch of the active deploy (set and cleared with `deploy_cancel`).
+    pub deploy_pause: Arc<Mutex<Option<PauseGate>>>,
+    /// Stops `process_upload_queue` from starting further items (set while
+    /// the queue is processed).
+    pub queue_cancel: Arc<Mutex<Option<CancellationToken>>>,
+    /// Active watch-deploy session, if any.
+    pub watch_deploy: Arc<Mutex<Option<WatchDeploy>>>,
+    /// Persistent upload queue (`None` if it could not be loaded).
+    pub upload_queue: Option<Arc<UploadQueue>>,
+    /// Interrupted uploads to resume (`None` if they could not be loaded).
+    pub upload_resume: Option<Arc<ResumeStore>>,
+    /// Finished deploys (`None` if the history could not be loaded).
+    pub deploy_history: Option<Arc<DeployHistory>>,
+    /// Set while `provision_agent` waits for a pairing code; the pairing
+    /// dialog's `confirm_pairing` delivers the code through it.
+    pub provision_code: Arc<Mutex<Option<tokio::sync::oneshot::Sender<String>>>>,
+    /// Settings reset on startup because their files were corrupted; taken
+    /// by the first `report_config_recovery`.
+    pub config_recovery: Arc<Mutex<Option<ConfigRecoveredDto>>>,
+    /// Set until the startup connection to the last used agent has been
+    /// tried (or made unnecessary by a manual connection).
+    pub auto_connect_armed: Arc<AtomicBool>,
 }
@@ -5,6 +5,8 @@
 
 use serde::{Deserialize, Serialize};
 
+use capydeploy_hub_connection::ConnectionState;
+
 /// Discovered agent info sent to the frontend.
 ///
 /// `DiscoveredAgent` has `#[serde(skip)]` on `ips`, `discovered_at`, `last_seen`,
@@ -22,6 +24,8 @@ pub struct DiscoveredAgentDto {
     pub discovered_at: String,
     pub last_seen: String,
     pub online: bool,
+    /// Added by address rather than found via mDNS.
+    pub manual: bool,
 }
 
 impl From<&capydeploy_discovery::types::DiscoveredAgent> for DiscoveredAgentDto {
@@ -42,7 +46,14 @@ impl From<&capydeploy_discovery::types::DiscoveredAgent> for DiscoveredAgentDto
                 .last_seen
                 .map(|t| format!("{:.0}s ago", t.elapsed().as_secs_f64()))
                 .unwrap_or_default(),
-            online: a.last_seen.is_some_and(|t| t.elapsed().as_secs() < 120),
+            // Manual agents aren't re-announced; they count as online once
+            // they answered a probe.
+            online: if a.manual {
+                a.last_seen.is_some()
+            } else {
+                a.last_seen.is_some_and(|t| t.elapsed().as_secs() < 120)
+            },
+            manual: a.manual,
         }
     }
 }
@@ -52,6 +63,9 @@ impl From<&capydeploy_discovery::types::DiscoveredAgent> for DiscoveredAgentDto
 #[serde(rename_all = "camelCase")]
 pub struct ConnectionStatusDto {
     pub connected: bool,
+    /// `disconnected`, `connecting`, `pairing`, `connected`,
+    /// `reconnecting` or `lost`.
+    pub state: String,
     pub agent_id: String,
     pub agent_name: String,
     pub platform: String,
@@ -60,12 +74,18 @@ pub struct ConnectionStatusDto {
     pub ips: Vec<String>,
     pub supported_image_formats: Vec<String>,
     pub capabilities: Vec<String>,
+    pub max_concurrent_uploads: u32,
+    /// When the agent's server started, Unix milliseconds (0 if the agent
+    /// doesn't say), and why.
+    pub agent_started_at: i64,
+    pub agent_start_reason: String,
 }
 
 impl ConnectionStatusDto {
     pub fn disconnected() -> Self {
         Self {
             connected: false,
+            state: ConnectionState::Disconnected.name().into(),
             agent_id: String::new(),
             agent_name: String::new(),
             platform: String::new(),
@@ -74,12 +94,25 @@ impl ConnectionStatusDto {
             ips: Vec::new(),
             supported_image_formats: Vec::new(),
             capabilities: Vec::new(),
+            max_concurrent_uploads: 0,
+            agent_started_at: 0,
+            agent_start_reason: String::new(),
+        }
+    }
+
+    /// Status of an Agent that isn't connected, such as one reconnecting.
+    pub fn not_connected(agent_id: String, state: &ConnectionState) -> Self {
+        Self {
+            agent_id,
+            state: state.name().into(),
+            ..Self::disconnected()
         }
     }
 
     pub fn from_connected(agent: &capydeploy_hub_connection::ConnectedAgent) -> Self {
         Self {
             connected: true,
+            state: ConnectionState::Connected.name().into(),
             agent_id: agent.agent.info.id.clone(),
             agent_name: agent.agent.info.name.clone(),
             platform: agent.agent.info.platform.clone(),
@@ -87,7 +120,10 @@ impl ConnectionStatusDto {
             port: agent.agent.port,
             ips: agent.agent.ips.iter().map(|ip| ip.to_string()).collect(),
             supported_image_formats: agent.agent.info.supported_image_formats.clone(),
-            capabilities: agent.status.capabilities.clone(),
+            capabilities: agent.profile.capabilities.iter().cloned().collect(),
+            max_concurrent_uploads: agent.agent.info.max_concurrent_uploads,
+            agent_started_at: agent.status.started_at,
+            agent_start_reason: agent.status.start_reason.clone(),
         }
     }
 }
@@ -119,13 +155,69 @@ pub struct PairingRequiredDto {
 }
 
 /// Upload progress DTO matching frontend expectations.
-#[derive(Debug, Clone, Serialize, Deserialize)]
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
 pub struct UploadProgressDto {
     pub progress: f64,
     pub status: String,
     #[serde(skip_serializing_if = "Option::is_none")]
     pub error: Option<String>,
     pub done: bool,
+    /// Agent the progress belongs to, so a deploy to several agents can be
+    /// told apart. Empty for transfers not tied to one.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub agent_id: String,
+    /// File the agent is writing, when it reported one.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub current_file: String,
+    /// Progress through `current_file`, 0.0-1.0.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file_progress: Option<f64>,
+    /// Transfer speed the agent measured, when it reported one.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub bytes_per_sec: Option<f64>,
+    /// Seconds the agent expects the transfer to take from here.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub eta_secs: Option<u64>,
+}
+
+/// Watch-deploy status event payload.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WatchStatusDto {
+    pub active: bool,
+    pub setup_id: String,
+}
+
+/// Emitted when a reconnected agent has deploys waiting in the upload queue.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct QueueResumableDto {
+    pub agent_id: String,
+    pub pending: usize,
+}
+
+/// Emitted as `connection:auto-connect-failed` when the startup connection
+/// to the last used agent didn't go through.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AutoConnectFailedDto {
+    pub agent_id: String,
+    pub agent_name: String,
+    /// The agent wants to be paired again.
+    pub pairing_required: bool,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub error: String,
+}
+
+/// Emitted when corrupted settings files were reset on startup.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ConfigRecoveredDto {
+    /// Where the unreadable files were moved.
+    pub backups: Vec<String>,
+    /// The pairing tokens were among them, so agents must be paired again.
+    pub tokens_reset: bool,
 }
 
 /// Installed game DTO.
@@ -137,6 +229,12 @@ pub struct InstalledGameDto {
     pub size: String,
     #[serde(skip_serializing_if = "Option::is_none")]
     pub app_id: Option<u32>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub tags: Vec<String>,
+    #[serde(default)]
+    pub size_bytes: u64,
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub broken: bool,
 }
 
 /// Artwork file result from local file selection.
@@ -149,6 +247,27 @@ pub struct ArtworkFileResultDto {
     pub size: u64,
 }
 
+/// Artwork on an installed game's shortcut, for preview.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GameArtworkDto {
+    pub images: Vec<AppliedArtworkDto>,
+    pub missing: Vec<String>,
+}
+
+/// One artwork image read back from the agent. `data_uri` is empty when
+/// the file was too large to fetch.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AppliedArtworkDto {
+    pub artwork_type: String,
+    pub content_type: String,
+    pub size: u64,
+    pub data_uri: String,
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub too_large: bool,
+}
+
 /// Reconnecting event payload.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -158,6 +277,24 @@ pub struct ReconnectingDto {
     pub next_retry_secs: f64,
 }
 
+/// Network change event payload.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct NetworkChangedDto {
+    /// Local IP addresses on the new network.
+    pub addresses: Vec<String>,
+}
+
+/// Emitted as `discovery:agent-moved` when a connected or reconnecting
+/// agent shows up on new addresses.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AgentMovedDto {
+    pub agent_id: String,
+    pub old_ips: Vec<String>,
+    pub new_ips: Vec<String>,
+}
+
 /// SteamGridDB image filters (received from frontend).
 #[derive(Debug, Clone, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -12,6 +12,14 @@ pub struct BinaryChunkHeader {
     pub offset: i64,
     #[serde(default, skip_serializing_if = "String::is_empty")]
     pub checksum: String,
+    /// Whether the payload is compressed with the upload's codec. The
+    /// checksum is of the decompressed bytes.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub compressed: bool,
+}
+
+fn is_false(v: &bool) -> bool {
+    !v
 }
 
 /// Header for binary artwork images.
@@ -24,9 +32,44 @@ pub struct BinaryArtworkHeader {
     pub app_id: u32,
     pub artwork_type: String,
     pub content_type: String,
+    /// Byte offset of this chunk within the image.
+    #[serde(default)]
+    pub offset: u64,
+    /// Size of the whole image when it's sent in chunks; 0 for an image
+    /// in a single frame.
+    #[serde(default)]
+    pub total_size: u64,
+    /// Hex SHA-256 of the whole image; required for chunked images.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub checksum: String,
 }
 
-/// Parsed binary message — either a chunk or an artwork image.
+/// Header for a batch of artwork images for one app.
+///
+/// The payload holds one sub-frame per item, in the same order: a 4-byte
+/// big-endian length followed by the image bytes.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BinaryArtworkBatchHeader {
+    pub id: String,
+    #[serde(rename = "type")]
+    pub msg_type: String,
+    pub app_id: u32,
+    pub items: Vec<ArtworkBatchItem>,
+}
+
+/// One image of an artwork batch.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ArtworkBatchItem {
+    pub artwork_type: String,
+    pub content_type: String,
+    /// Hex SHA-256 of the image.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub checksum: String,
+}
+
+/// Parsed binary message — a chunk, an artwork image or an artwork batch.
 #[derive(Debug)]
 pub enum BinaryMessage {
     Chunk {
@@ -37,6 +80,11 @@ pub enum BinaryMessage {
         header: BinaryArtworkHeader,
         data: Vec<u8>,
     },
+    /// `images[i]` belongs to `header.items[i]`.
+    ArtworkBatch {
+        header: BinaryArtworkBatchHeader,
+        images: Vec<Vec<u8>>,
+    },
 }
 
 /// Parses a raw binary WebSocket frame into a [`BinaryMessage`].
@@ -66,7 +114,19 @@ pub fn parse_binary_message(data: &[u8]) -> Result<BinaryMessage, ParseError> {
     let peek: HeaderPeek =
         serde_json::from_slice(header_bytes).map_err(|e| ParseError::InvalidJson(e.to_string()))?;
 
-    if peek.msg_type.as_deref() == Some("artwork_image") {
+    if peek.msg_type.as_deref() == Some("apply_artwork_batch") {
+        let header: BinaryArtworkBatchHeader = serde_json::from_slice(header_bytes)
+            .map_err(|e| ParseError::InvalidJson(e.to_string()))?;
+        let images = split_sub_frames(&payload)?;
+        if images.len() != header.items.len() {
+            return Err(ParseError::InvalidBatch(format!(
+                "{} items declared, {} images sent",
+                header.items.len(),
+                images.len()
+            )));
+        }
+        Ok(BinaryMessage::ArtworkBatch { header, images })
+    } else if peek.msg_type.as_deref() == Some("artwork_image") {
         let header: BinaryArtworkHeader = serde_json::from_slice(header_bytes)
             .map_err(|e| ParseError::InvalidJson(e.to_string()))?;
         Ok(BinaryMessage::Artwork {
@@ -83,6 +143,38 @@ pub fn parse_binary_message(data: &[u8]) -> Result<BinaryMessage, ParseError> {
     }
 }
 
+/// Splits an artwork batch payload into its length-prefixed sub-frames.
+fn split_sub_frames(mut payload: &[u8]) -> Result<Vec<Vec<u8>>, ParseError> {
+    let mut frames = Vec::new();
+    while !payload.is_empty() {
+        let Some((len, rest)) = payload.split_first_chunk::<4>() else {
+            return Err(ParseError::InvalidBatch(
+                "truncated sub-frame length".into(),
+            ));
+        };
+        let len = u32::from_be_bytes(*len) as usize;
+        if rest.len() < len {
+            return Err(ParseError::InvalidBatch(format!(
+                "sub-frame of {len} bytes has only {}",
+                rest.len()
+            )));
+        }
+        frames.push(rest[..len].to_vec());
+        payload = &rest[len..];
+    }
+    Ok(frames)
+}
+
+/// Joins images into an artwork batch payload.
+pub fn encode_sub_frames(images: &[&[u8]]) -> Vec<u8> {
+    let mut buf = Vec::with_capacity(images.iter().map(|i| 4 + i.len()).sum());
+    for image in images {
+        buf.extend_from_slice(&(image.len() as u32).to_be_bytes());
+        buf.extend_from_slice(image);
+    }
+    buf
+}
+
 /// Encodes a binary message for sending over WebSocket.
 #[allow(dead_code)] // Will be used by handler implementations in future crates.
 pub fn encode_binary_message<T: Serialize>(
@@ -116,6 +208,9 @@ pub enum ParseError {
 
     #[error("invalid header JSON: {0}")]
     InvalidJson(String),
+
+    #[error("invalid artwork batch: {0}")]
+    InvalidBatch(String),
 }
 
 #[cfg(test)]
@@ -153,6 +248,7 @@ mod tests {
                 assert_eq!(header.file_path, "game.exe");
                 assert_eq!(header.offset, 0);
                 assert_eq!(header.checksum, "abc123");
+                assert!(!header.compressed);
                 assert_eq!(data, payload);
             }
             _ => panic!("expected Chunk variant"),
@@ -181,12 +277,90 @@ mod tests {
                 assert_eq!(header.app_id, 12345);
                 assert_eq!(header.artwork_type, "grid");
                 assert_eq!(header.content_type, "image/png");
+                assert_eq!(header.total_size, 0);
                 assert_eq!(data, payload);
             }
             _ => panic!("expected Artwork variant"),
         }
     }
 
+    #[test]
+    fn parse_chunked_artwork_message() {
+        let header = serde_json::to_vec(&serde_json::json!({
+            "id": "msg-4",
+            "type": "artwork_image",
+            "appId": 12345,
+            "artworkType": "hero",
+            "contentType": "image/png",
+            "offset": 4096,
+            "totalSize": 10000,
+            "checksum": "abc123"
+        }))
+        .unwrap();
+
+        let frame = make_binary_frame(&header, b"chunk");
+        match parse_binary_message(&frame).unwrap() {
+            BinaryMessage::Artwork { header, .. } => {
+                assert_eq!(header.offset, 4096);
+                assert_eq!(header.total_size, 10000);
+                assert_eq!(header.checksum, "abc123");
+            }
+            _ => panic!("expected Artwork variant"),
+        }
+    }
+
+    #[test]
+    fn parse_artwork_batch_message() {
+        let header = serde_json::to_vec(&serde_json::json!({
+            "id": "msg-5",
+            "type": "apply_artwork_batch",
+            "appId": 12345,
+            "items": [
+                {"artworkType": "grid", "contentType": "image/png"},
+                {"artworkType": "icon", "contentType": "image/png", "checksum": "abc"}
+            ]
+        }))
+        .unwrap();
+        let payload = encode_sub_frames(&[b"grid-bytes", b"icon"]);
+
+        let frame = make_binary_frame(&header, &payload);
+        match parse_binary_message(&frame).unwrap() {
+            BinaryMessage::ArtworkBatch { header, images } => {
+                assert_eq!(header.app_id, 12345);
+                assert_eq!(header.items.len(), 2);
+                assert_eq!(header.items[1].artwork_type, "icon");
+                assert_eq!(header.items[1].checksum, "abc");
+                assert_eq!(images, vec![b"grid-bytes".to_vec(), b"icon".to_vec()]);
+            }
+            _ => panic!("expected ArtworkBatch variant"),
+        }
+    }
+
+    #[test]
+    fn parse_artwork_batch_rejects_mismatch() {
+        let header = serde_json::to_vec(&serde_json::json!({
+            "id": "msg-6",
+            "type": "apply_artwork_batch",
+            "appId": 1,
+            "items": [{"artworkType": "grid", "contentType": "image/png"}]
+        }))
+        .unwrap();
+
+        let two = make_binary_frame(&header, &encode_sub_frames(&[b"a", b"b"]));
+        assert!(matches!(
+            parse_binary_message(&two),
+            Err(ParseError::InvalidBatch(_))
+        ));
+
+        let mut truncated = encode_sub_frames(&[b"abcdef"]);
+        truncated.truncate(7);
+        let frame = make_binary_frame(&header, &truncated);
+        assert!(matches!(
+            parse_binary_message(&frame),
+            Err(ParseError::InvalidBatch(_))
+        ));
+    }
+
     #[test]
     fn parse_too_short() {
         let result = parse_binary_message(&[0, 0, 0]);
@@ -216,6 +390,7 @@ mod tests {
             file_path: "test.bin".into(),
             offset: 512,
             checksum: String::new(),
+            compressed: true,
         };
         let payload = b"roundtrip data";
 
@@ -227,6 +402,7 @@ mod tests {
                 assert_eq!(h.id, "r-1");
                 assert_eq!(h.upload_id, "u-1");
                 assert_eq!(h.offset, 512);
+                assert!(h.compressed);
                 assert_eq!(data, payload);
             }
             _ => panic!("expected Chunk"),
@@ -1,11 +1,13 @@
 //! Hub connection management: read/write pumps, ping/pong, send buffering.
 
-use std::sync::Arc;
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Arc, RwLock};
 
 use capydeploy_protocol::constants::{
-    MessageType, WS_MAX_MESSAGE_SIZE, WS_PING_PERIOD, WS_PONG_WAIT,
+    MessageType, WS_ERR_CODE_NOT_IMPLEMENTED, WS_PING_PERIOD, WS_PONG_WAIT,
 };
 use capydeploy_protocol::envelope::Message;
+use capydeploy_protocol::profile::ProtocolProfile;
 use futures_util::{SinkExt, StreamExt};
 use tokio::sync::mpsc;
 use tokio_tungstenite::tungstenite::protocol::Message as WsMessage;
@@ -30,6 +32,8 @@ pub struct HubMeta {
 #[derive(Clone)]
 pub struct Sender {
     tx: mpsc::Sender<WsMessage>,
+    remote_addr: Arc<str>,
+    profile: Arc<RwLock<Option<ProtocolProfile>>>,
 }
 
 impl Sender {
@@ -61,6 +65,32 @@ impl Sender {
         !self.tx.is_closed()
     }
 
+    /// Returns the Hub's IP address, without the port.
+    pub fn remote_ip(&self) -> String {
+        self.remote_addr
+            .parse::<std::net::SocketAddr>()
+            .map(|a| a.ip().to_string())
+            .unwrap_or_else(|_| self.remote_addr.to_string())
+    }
+
+    /// Records the profile negotiated with this Hub. Requests for
+    /// capabilities it lacks are then answered with
+    /// [`WS_ERR_CODE_NOT_IMPLEMENTED`] before reaching the handler.
+    pub fn set_profile(&self, profile: ProtocolProfile) {
+        if let Ok(mut slot) = self.profile.write() {
+            *slot = Some(profile);
+        }
+    }
+
+    /// Whether the Hub negotiated `capability`. True while no profile has
+    /// been set, e.g. during pairing.
+    pub fn peer_supports(&self, capability: &str) -> bool {
+        match self.profile.read() {
+            Ok(profile) => profile.as_ref().is_none_or(|p| p.supports(capability)),
+            Err(_) => true,
+        }
+    }
+
     /// Sends a WebSocket close frame with [`WS_CLOSE_TOKEN_REVOKED`] code,
     /// signalling the Hub that reconnection should NOT be attempted.
     ///
@@ -119,12 +149,15 @@ impl HubConnection {
 ///
 /// Returns the [`HubConnection`] handle. The pumps run as background
 /// tokio tasks and stop when the connection is closed or the cancel
-/// token is triggered.
+/// token is triggered. Frames larger than `max_size` bytes are dropped.
+/// While `verbose` is set, every received message is logged at info level.
 pub fn spawn_connection<S, H>(
     ws_stream: S,
     meta: HubMeta,
     handler: Arc<H>,
     server_cancel: CancellationToken,
+    max_size: usize,
+    verbose: Arc<AtomicBool>,
 ) -> HubConnection
 where
     S: futures_util::Stream<Item = Result<WsMessage, tokio_tungstenite::tungstenite::Error>>
@@ -135,7 +168,11 @@ where
 {
     let (tx, rx) = mpsc::channel::<WsMessage>(SEND_BUFFER_SIZE);
     let cancel = server_cancel.child_token();
-    let sender = Sender { tx };
+    let sender = Sender {
+        tx,
+        remote_addr: meta.remote_addr.as_str().into(),
+        profile: Arc::default(),
+    };
 
     let (ws_sink, ws_stream) = ws_stream.split();
 
@@ -149,7 +186,15 @@ where
     let read_sender = sender.clone();
     let conn_meta = meta.clone();
     let read_join = tokio::spawn(async move {
-        read_pump(ws_stream, read_sender, read_handler, read_cancel.clone()).await;
+        read_pump(
+            ws_stream,
+            read_sender,
+            read_handler,
+            read_cancel.clone(),
+            max_size,
+            verbose,
+        )
+        .await;
         // When read pump exits, cancel the write pump too.
         read_cancel.cancel();
         handler.on_hub_disconnected().await;
@@ -202,8 +247,14 @@ where
 }
 
 /// Read pump: reads WS frames and dispatches to the handler.
-async fn read_pump<S, H>(mut stream: S, sender: Sender, handler: Arc<H>, cancel: CancellationToken)
-where
+async fn read_pump<S, H>(
+    mut stream: S,
+    sender: Sender,
+    handler: Arc<H>,
+    cancel: CancellationToken,
+    max_size: usize,
+    verbose: Arc<AtomicBool>,
+) where
     S: futures_util::Stream<Item = Result<WsMessage, tokio_tungstenite::tungstenite::Error>>
         + Send
         + Unpin,
@@ -230,17 +281,20 @@ where
                     Some(Ok(ws_msg)) => {
                         match ws_msg {
                             WsMessage::Text(text) => {
-                                if text.len() > WS_MAX_MESSAGE_SIZE {
-                                    tracing::error!("message exceeds max size ({} > {})", text.len(), WS_MAX_MESSAGE_SIZE);
+                                if text.len() > max_size {
+                                    tracing::error!("message exceeds max size ({} > {})", text.len(), max_size);
                                     continue;
                                 }
-                                dispatch_text(&handler, &sender, &text).await;
+                                dispatch_text(&handler, &sender, &text, verbose.load(Ordering::Relaxed)).await;
                             }
                             WsMessage::Binary(data) => {
-                                if data.len() > WS_MAX_MESSAGE_SIZE {
-                                    tracing::error!("binary message exceeds max size ({} > {})", data.len(), WS_MAX_MESSAGE_SIZE);
+                                if data.len() > max_size {
+                                    tracing::error!("binary message exceeds max size ({} > {})", data.len(), max_size);
                                     continue;
                                 }
+                                if verbose.load(Ordering::Relaxed) {
+                                    tracing::info!(bytes = data.len(), "hub binary frame");
+                                }
                                 dispatch_binary(&handler, &sender, &data).await;
                             }
                             WsMessage::Pong(_) => {
@@ -270,7 +324,7 @@ where
 }
 
 /// Dispatches a text (JSON) message to the appropriate handler method.
-async fn dispatch_text<H: Handler>(handler: &Arc<H>, sender: &Sender, text: &str) {
+async fn dispatch_text<H: Handler>(handler: &Arc<H>, sender: &Sender, text: &str, verbose: bool) {
     let msg: Message = match serde_json::from_str(text) {
         Ok(m) => m,
         Err(e) => {
@@ -279,7 +333,22 @@ async fn dispatch_text<H: Handler>(handler: &Arc<H>, sender: &Sender, text: &str
         }
     };
 
-    tracing::debug!(msg_type = ?msg.msg_type, id = %msg.id, "dispatching message");
+    if verbose {
+        tracing::info!(msg_type = ?msg.msg_type, id = %msg.id, bytes = text.len(), "dispatching message");
+    } else {
+        tracing::debug!(msg_type = ?msg.msg_type, id = %msg.id, "dispatching message");
+    }
+
+    if let Some(capability) = msg.msg_type.required_capability()
+        && !sender.peer_supports(capability)
+    {
+        let _ = sender.send_error(
+            &msg,
+            WS_ERR_CODE_NOT_IMPLEMENTED,
+            &format!("capability `{capability}` was not negotiated"),
+        );
+        return;
+    }
 
     let s = sender.clone();
     match msg.msg_type {
@@ -287,20 +356,44 @@ async fn dispatch_text<H: Handler>(handler: &Arc<H>, sender: &Sender, text: &str
         MessageType::PairConfirm => handler.on_pair_confirm(s, msg).await,
         MessageType::Ping => handler.on_ping(s, msg).await,
         MessageType::GetInfo => handler.on_get_info(s, msg).await,
+        MessageType::GetInfoLite => handler.on_get_info_lite(s, msg).await,
         MessageType::GetConfig => handler.on_get_config(s, msg).await,
+        MessageType::SetInstallPath => handler.on_set_install_path(s, msg).await,
+        MessageType::SelfTest => handler.on_self_test(s, msg).await,
+        MessageType::CanDeploy => handler.on_can_deploy(s, msg).await,
+        MessageType::BrowseDirectory => handler.on_browse_directory(s, msg).await,
+        MessageType::GetDiskUsage => handler.on_get_disk_usage(s, msg).await,
         MessageType::GetSteamUsers => handler.on_get_steam_users(s, msg).await,
+        MessageType::GetSteamLibraries => handler.on_get_steam_libraries(s, msg).await,
+        MessageType::GetSteamStatus => handler.on_get_steam_status(s, msg).await,
+        MessageType::ListConnectedHubs => handler.on_list_connected_hubs(s, msg).await,
         MessageType::ListShortcuts => handler.on_list_shortcuts(s, msg).await,
+        MessageType::ExportShortcut => handler.on_export_shortcut(s, msg).await,
         MessageType::CreateShortcut => handler.on_create_shortcut(s, msg).await,
+        MessageType::CreateShortcutsBatch => handler.on_create_shortcuts_batch(s, msg).await,
         MessageType::DeleteShortcut => handler.on_delete_shortcut(s, msg).await,
         MessageType::DeleteGame => handler.on_delete_game(s, msg).await,
+        MessageType::DeleteGamesBatch => handler.on_delete_games_batch(s, msg).await,
+        MessageType::UpdateShortcut => handler.on_update_shortcut(s, msg).await,
+        MessageType::RenameGame => handler.on_rename_game(s, msg).await,
+        MessageType::LaunchGame => handler.on_launch_game(s, msg).await,
         MessageType::ApplyArtwork => handler.on_apply_artwork(s, msg).await,
+        MessageType::GetArtwork => handler.on_get_artwork(s, msg).await,
         MessageType::RestartSteam => handler.on_restart_steam(s, msg).await,
+        MessageType::CheckCef => handler.on_check_cef(s, msg).await,
         MessageType::InitUpload => handler.on_init_upload(s, msg).await,
         MessageType::UploadChunk => handler.on_upload_chunk(s, msg).await,
         MessageType::CompleteUpload => handler.on_complete_upload(s, msg).await,
         MessageType::CancelUpload => handler.on_cancel_upload(s, msg).await,
+        MessageType::PauseUpload => handler.on_pause_upload(s, msg).await,
+        MessageType::ResumeUpload => handler.on_resume_upload(s, msg).await,
+        MessageType::GetUploadStatus => handler.on_get_upload_status(s, msg).await,
+        MessageType::ListUploads => handler.on_list_uploads(s, msg).await,
+        MessageType::PruneUploads => handler.on_prune_uploads(s, msg).await,
         MessageType::SetConsoleLogFilter => handler.on_set_console_log_filter(s, msg).await,
         MessageType::SetConsoleLogEnabled => handler.on_set_console_log_enabled(s, msg).await,
+        MessageType::SetVerbose => handler.on_set_verbose(s, msg).await,
+        MessageType::SubscribeLogs => handler.on_subscribe_logs(s, msg).await,
         MessageType::SetGameLogWrapper => handler.on_set_game_log_wrapper(s, msg).await,
         MessageType::FsList => handler.on_fs_list(s, msg).await,
         MessageType::FsMkdir => handler.on_fs_mkdir(s, msg).await,
@@ -327,8 +420,66 @@ async fn dispatch_binary<H: Handler>(handler: &Arc<H>, sender: &Sender, data: &[
                 .on_binary_artwork(sender.clone(), header, data)
                 .await;
         }
+        Ok(BinaryMessage::ArtworkBatch { header, images }) => {
+            handler
+                .on_binary_artwork_batch(sender.clone(), header, images)
+                .await;
+        }
         Err(e) => {
             tracing::error!("failed to parse binary message: {e}");
         }
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::handler::HandlerFuture;
+
+    struct NoopHandler;
+
+    impl Handler for NoopHandler {
+        fn on_hub_connected(&self, _sender: Sender, _msg: Message) -> HandlerFuture<'_> {
+            Box::pin(async {})
+        }
+    }
+
+    fn test_sender() -> (Sender, mpsc::Receiver<WsMessage>) {
+        let (tx, rx) = mpsc::channel(8);
+        let sender = Sender {
+            tx,
+            remote_addr: "127.0.0.1:9999".into(),
+            profile: Arc::default(),
+        };
+        (sender, rx)
+    }
+
+    async fn dispatch(sender: &Sender, rx: &mut mpsc::Receiver<WsMessage>, text: &str) -> Message {
+        dispatch_text(&Arc::new(NoopHandler), sender, text, false).await;
+        match rx.recv().await {
+            Some(WsMessage::Text(t)) => serde_json::from_str(&t).unwrap(),
+            other => panic!("expected a text reply, got {other:?}"),
+        }
+    }
+
+    #[tokio::test]
+    async fn unnegotiated_capability_is_refused() {
+        let (sender, mut rx) = test_sender();
+        let request = r#"{"id":"1","type":"subscribe_logs","payload":{"enabled":true}}"#;
+
+        // Without a profile the request reaches the handler.
+        let reply = dispatch(&sender, &mut rx, request).await;
+        let err = reply.error.unwrap();
+        assert_eq!(err.code, WS_ERR_CODE_NOT_IMPLEMENTED);
+        assert!(!err.message.contains("negotiated"));
+
+        // Legacy Hubs don't get agent logs.
+        sender.set_profile(ProtocolProfile::default());
+        let reply = dispatch(&sender, &mut rx, request).await;
+        let err = reply.error.unwrap();
+        assert_eq!(err.code, WS_ERR_CODE_NOT_IMPLEMENTED);
+        assert!(err.message.contains("agent_logs"), "{}", err.message);
+        assert!(!sender.peer_supports("agent_logs"));
+        assert!(sender.peer_supports("file_browser"));
+    }
+}
@@ -54,6 +54,13 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `get_info_lite`.
+    fn on_get_info_lite(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `get_config`.
     fn on_get_config(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -61,6 +68,41 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `set_install_path`.
+    fn on_set_install_path(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `self_test`.
+    fn on_self_test(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `can_deploy`.
+    fn on_can_deploy(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `browse_directory`.
+    fn on_browse_directory(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `get_disk_usage`.
+    fn on_get_disk_usage(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `get_steam_users`.
     fn on_get_steam_users(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -68,6 +110,27 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `get_steam_libraries`.
+    fn on_get_steam_libraries(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `get_steam_status`.
+    fn on_get_steam_status(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `list_connected_hubs`.
+    fn on_list_connected_hubs(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `list_shortcuts`.
     fn on_list_shortcuts(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -75,6 +138,13 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `export_shortcut`.
+    fn on_export_shortcut(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `create_shortcut`.
     fn on_create_shortcut(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -82,6 +152,13 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `create_shortcuts_batch`.
+    fn on_create_shortcuts_batch(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `delete_shortcut`.
     fn on_delete_shortcut(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -89,6 +166,13 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `update_shortcut`.
+    fn on_update_shortcut(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `delete_game`.
     fn on_delete_game(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -96,6 +180,27 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `delete_games_batch`.
+    fn on_delete_games_batch(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `rename_game`.
+    fn on_rename_game(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `launch_game`.
+    fn on_launch_game(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `apply_artwork`.
     fn on_apply_artwork(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -103,6 +208,13 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `get_artwork`.
+    fn on_get_artwork(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `restart_steam`.
     fn on_restart_steam(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -110,6 +222,13 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `check_cef`.
+    fn on_check_cef(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `init_upload`.
     fn on_init_upload(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -138,6 +257,41 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `pause_upload`.
+    fn on_pause_upload(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `resume_upload`.
+    fn on_resume_upload(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `get_upload_status`.
+    fn on_get_upload_status(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `list_uploads`.
+    fn on_list_uploads(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `prune_uploads`.
+    fn on_prune_uploads(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `set_console_log_filter`.
     fn on_set_console_log_filter(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -152,6 +306,20 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for `set_verbose`.
+    fn on_set_verbose(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
+    /// Called for `subscribe_logs`.
+    fn on_subscribe_logs(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
+        Box::pin(async move {
+            let _ = sender.send_error(&msg, 501, "not implemented");
+        })
+    }
+
     /// Called for `set_game_log_wrapper`.
     fn on_set_game_log_wrapper(&self, sender: Sender, msg: Message) -> HandlerFuture<'_> {
         Box::pin(async move {
@@ -237,6 +405,20 @@ pub trait Handler: Send + Sync + 'static {
         })
     }
 
+    /// Called for a batch of artwork images for one app.
+    fn on_binary_artwork_batch(
+        &self,
+        sender: Sender,
+        header: crate::binary::BinaryArtworkBatchHeader,
+        images: Vec<Vec<u8>>,
+    ) -> HandlerFuture<'_> {
+        let _ = (header, images);
+        Box::pin(async move {
+            tracing::warn!("binary artwork batch received but handler not implemented");
+            let _ = sender;
+        })
+    }
+
     /// Called when the Hub disconnects (cleanup hook).
     fn on_hub_disconnected(&self) -> HandlerFuture<'_> {
         Box::pin(async {})
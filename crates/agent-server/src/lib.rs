@@ -11,7 +11,10 @@ mod connection;
 mod handler;
 mod server;
 
-pub use binary::{BinaryArtworkHeader, BinaryChunkHeader, BinaryMessage, parse_binary_message};
+pub use binary::{
+    ArtworkBatchItem, BinaryArtworkBatchHeader, BinaryArtworkHeader, BinaryChunkHeader,
+    BinaryMessage, encode_sub_frames, parse_binary_message,
+};
 pub use connection::{HubConnection, Sender};
 pub use handler::{Handler, HandlerFuture};
 pub use server::{AgentServer, ServerConfig};
@@ -3,7 +3,7 @@
 //! Listens on a TCP port, upgrades HTTP GET `/ws` to WebSocket, and
 //! accepts a single Hub connection at a time.
 
-use std::net::SocketAddr;
+use std::net::{Ipv6Addr, SocketAddr};
 use std::sync::Arc;
 use std::sync::atomic::{AtomicBool, Ordering};
 
@@ -12,7 +12,7 @@ use tokio::sync::Mutex;
 use tokio_tungstenite::accept_async_with_config;
 use tokio_util::sync::CancellationToken;
 
-use capydeploy_protocol::constants::WS_MAX_MESSAGE_SIZE;
+use capydeploy_protocol::constants::binary_frame_limit;
 
 use crate::ServerError;
 use crate::connection::{self, HubConnection, HubMeta};
@@ -23,6 +23,13 @@ use crate::handler::Handler;
 pub struct ServerConfig {
     /// TCP port to listen on (0 = OS-assigned).
     pub port: u16,
+    /// Largest WebSocket frame read from the Hub, in bytes (0 = 50 MB).
+    /// Advertised to the Hub so it sizes chunks and images to fit.
+    pub max_binary_frame_size: usize,
+    /// Verbose logging flag, shared like the accept flag so a handler
+    /// (e.g. `set_verbose`) can toggle it while the server runs. When set,
+    /// every message received from the Hub is logged at info level.
+    pub verbose: Arc<AtomicBool>,
 }
 
 /// The agent WebSocket server.
@@ -31,6 +38,8 @@ pub struct ServerConfig {
 /// to the provided [`Handler`].
 pub struct AgentServer<H: Handler> {
     port: u16,
+    max_frame_size: usize,
+    verbose: Arc<AtomicBool>,
     handler: Arc<H>,
     hub_conn: Mutex<Option<HubConnection>>,
     cancel: CancellationToken,
@@ -49,6 +58,8 @@ impl<H: Handler> AgentServer<H> {
     pub fn new(config: ServerConfig, handler: H, accept: Arc<AtomicBool>) -> Arc<Self> {
         Arc::new(Self {
             port: config.port,
+            max_frame_size: binary_frame_limit(config.max_binary_frame_size as u64),
+            verbose: config.verbose,
             handler: Arc::new(handler),
             hub_conn: Mutex::new(None),
             cancel: CancellationToken::new(),
@@ -69,6 +80,16 @@ impl<H: Handler> AgentServer<H> {
         self.local_addr.lock().await.map(|a| a.port()).unwrap_or(0)
     }
 
+    /// Returns the effective frame size limit, after clamping.
+    pub fn max_binary_frame_size(&self) -> usize {
+        self.max_frame_size
+    }
+
+    /// Returns `true` if verbose message logging is on.
+    pub fn is_verbose(&self) -> bool {
+        self.verbose.load(Ordering::Relaxed)
+    }
+
     /// Returns `true` if a Hub is currently connected and alive.
     pub async fn has_hub(&self) -> bool {
         let lock = self.hub_conn.lock().await;
@@ -100,8 +121,13 @@ impl<H: Handler> AgentServer<H> {
     ///
     /// Binds to the configured port and accepts WebSocket connections.
     pub async fn run(self: &Arc<Self>) -> Result<(), ServerError> {
-        let addr: SocketAddr = ([0, 0, 0, 0], self.port).into();
-        let listener = TcpListener::bind(addr).await?;
+        let listener = match bind_dual_stack(self.port) {
+            Ok(listener) => listener,
+            Err(e) => {
+                tracing::debug!("IPv6 unavailable ({e}), listening on IPv4 only");
+                TcpListener::bind(SocketAddr::from(([0, 0, 0, 0], self.port))).await?
+            }
+        };
 
         let local_addr = listener.local_addr()?;
         *self.local_addr.lock().await = Some(local_addr);
@@ -162,10 +188,10 @@ impl<H: Handler> AgentServer<H> {
             }
         }
 
-        // WebSocket upgrade with size limits matching our protocol constants.
+        // WebSocket upgrade; the read limit is the frame size we advertise.
         let mut ws_config = tokio_tungstenite::tungstenite::protocol::WebSocketConfig::default();
-        ws_config.max_message_size = Some(WS_MAX_MESSAGE_SIZE);
-        ws_config.max_frame_size = Some(WS_MAX_MESSAGE_SIZE);
+        ws_config.max_message_size = Some(self.max_frame_size);
+        ws_config.max_frame_size = Some(self.max_frame_size);
         let ws_stream = accept_async_with_config(stream, Some(ws_config)).await?;
         tracing::info!(%peer_addr, "WebSocket connection established");
 
@@ -181,6 +207,8 @@ impl<H: Handler> AgentServer<H> {
             meta,
             Arc::clone(&self.handler),
             self.cancel.clone(),
+            self.max_frame_size,
+            Arc::clone(&self.verbose),
         );
 
         // Store the connection.
@@ -196,10 +224,28 @@ impl<H: Handler> AgentServer<H> {
     }
 }
 
+/// Listens on `port` on every interface, for IPv4 and IPv6 Hubs alike.
+///
+/// The IPv6 wildcard socket is made dual-stack explicitly since Windows
+/// (unlike Linux) defaults to IPv6-only.
+fn bind_dual_stack(port: u16) -> std::io::Result<TcpListener> {
+    use socket2::{Domain, Protocol, Socket, Type};
+
+    let socket = Socket::new(Domain::IPV6, Type::STREAM, Some(Protocol::TCP))?;
+    socket.set_only_v6(false)?;
+    #[cfg(unix)]
+    socket.set_reuse_address(true)?;
+    socket.bind(&SocketAddr::from((Ipv6Addr::UNSPECIFIED, port)).into())?;
+    socket.listen(1024)?;
+    socket.set_nonblocking(true)?;
+    TcpListener::from_std(socket.into())
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
     use crate::handler::HandlerFuture;
+    use capydeploy_protocol::constants::MessageType;
     use capydeploy_protocol::envelope::Message;
 
     /// Minimal test handler.
@@ -233,7 +279,10 @@ mod tests {
     #[tokio::test]
     async fn server_binds_dynamic_port() {
         let handler = TestHandler::new();
-        let config = ServerConfig { port: 0 };
+        let config = ServerConfig {
+            port: 0,
+            ..Default::default()
+        };
         let server = AgentServer::new(config, handler, accept_flag(true));
         let server2 = Arc::clone(&server);
 
@@ -269,7 +318,10 @@ mod tests {
     #[tokio::test]
     async fn server_accepts_ws_connection() {
         let handler = TestHandler::new();
-        let config = ServerConfig { port: 0 };
+        let config = ServerConfig {
+            port: 0,
+            ..Default::default()
+        };
         let server = AgentServer::new(config, handler, accept_flag(true));
         let server2 = Arc::clone(&server);
 
@@ -296,10 +348,43 @@ mod tests {
         handle.await.unwrap();
     }
 
+    #[tokio::test]
+    async fn server_accepts_ipv6_connection() {
+        if std::net::TcpListener::bind("[::1]:0").is_err() {
+            return; // No IPv6 on this host.
+        }
+        let handler = TestHandler::new();
+        let config = ServerConfig {
+            port: 0,
+            ..Default::default()
+        };
+        let server = AgentServer::new(config, handler, accept_flag(true));
+        let server2 = Arc::clone(&server);
+
+        let handle = tokio::spawn(async move {
+            server2.run().await.unwrap();
+        });
+
+        tokio::time::sleep(std::time::Duration::from_millis(50)).await;
+        let port = server.port().await;
+
+        let url = format!("ws://[::1]:{port}");
+        let (ws, _) = tokio_tungstenite::connect_async(&url).await.unwrap();
+        tokio::time::sleep(std::time::Duration::from_millis(50)).await;
+        assert!(server.has_hub().await);
+
+        drop(ws);
+        server.shutdown();
+        handle.await.unwrap();
+    }
+
     #[tokio::test]
     async fn server_rejects_second_connection() {
         let handler = TestHandler::new();
-        let config = ServerConfig { port: 0 };
+        let config = ServerConfig {
+            port: 0,
+            ..Default::default()
+        };
         let server = AgentServer::new(config, handler, accept_flag(true));
         let server2 = Arc::clone(&server);
 
@@ -335,7 +420,10 @@ mod tests {
         use futures_util::SinkExt;
 
         let handler = TestHandler::new();
-        let config = ServerConfig { port: 0 };
+        let config = ServerConfig {
+            port: 0,
+            ..Default::default()
+        };
         let server = AgentServer::new(config, handler, accept_flag(true));
         let server2 = Arc::clone(&server);
 
@@ -374,4 +462,248 @@ mod tests {
         server.shutdown();
         handle.await.unwrap();
     }
+
+    #[tokio::test]
+    async fn server_enforces_binary_frame_limit() {
+        use capydeploy_protocol::constants::WS_MIN_BINARY_FRAME_SIZE;
+        use futures_util::SinkExt;
+        use tokio_tungstenite::tungstenite::Message as WsMessage;
+
+        let handler = TestHandler::new();
+        let config = ServerConfig {
+            port: 0,
+            max_binary_frame_size: WS_MIN_BINARY_FRAME_SIZE,
+            ..Default::default()
+        };
+        let server = AgentServer::new(config, handler, accept_flag(true));
+        assert_eq!(server.max_binary_frame_size(), WS_MIN_BINARY_FRAME_SIZE);
+        let server2 = Arc::clone(&server);
+
+        let handle = tokio::spawn(async move {
+            server2.run().await.unwrap();
+        });
+
+        tokio::time::sleep(std::time::Duration::from_millis(50)).await;
+        let port = server.port().await;
+        let url = format!("ws://127.0.0.1:{port}");
+
+        let (mut ws, _) = tokio_tungstenite::connect_async(&url).await.unwrap();
+        tokio::time::sleep(std::time::Duration::from_millis(50)).await;
+
+        // A frame within the limit keeps the connection.
+        ws.send(WsMessage::Binary(vec![0u8; 1024].into()))
+            .await
+            .unwrap();
+        tokio::time::sleep(std::time::Duration::from_millis(50)).await;
+        assert!(server.has_hub().await);
+
+        // An oversized frame is a protocol error and drops the Hub.
+        let _ = ws
+            .send(WsMessage::Binary(
+                vec![0u8; WS_MIN_BINARY_FRAME_SIZE + 1].into(),
+            ))
+            .await;
+        tokio::time::sleep(std::time::Duration::from_millis(200)).await;
+        assert!(!server.has_hub().await);
+
+        drop(ws);
+        server.shutdown();
+        handle.await.unwrap();
+    }
+
+    /// Handler that applies `set_verbose` to the server's shared flag,
+    /// as the agent app does.
+    struct VerboseHandler {
+        verbose: Arc<AtomicBool>,
+    }
+
+    impl Handler for VerboseHandler {
+        fn on_hub_connected(
+            &self,
+            _sender: connection::Sender,
+            _msg: Message,
+        ) -> HandlerFuture<'_> {
+            Box::pin(async {})
+        }
+
+        fn on_set_verbose(&self, sender: connection::Sender, msg: Message) -> HandlerFuture<'_> {
+            Box::pin(async move {
+                let req: capydeploy_protocol::messages::SetVerboseRequest =
+                    msg.parse_payload().unwrap().unwrap();
+                self.verbose.store(req.enabled, Ordering::Relaxed);
+                let resp = capydeploy_protocol::messages::SetVerboseResponse {
+                    enabled: req.enabled,
+                };
+                let _ = sender.send_msg(msg.reply(MessageType::SetVerbose, Some(&resp)).unwrap());
+            })
+        }
+    }
+
+    /// Handler that tracks the connected Hub for `list_connected_hubs`,
+    /// as the agent app does.
+    struct HubListHandler {
+        hub: std::sync::Mutex<Option<capydeploy_protocol::messages::ConnectedHubEntry>>,
+    }
+
+    impl Handler for HubListHandler {
+        fn on_hub_connected(&self, sender: connection::Sender, msg: Message) -> HandlerFuture<'_> {
+            let req: capydeploy_protocol::messages::HubConnectedRequest =
+                msg.parse_payload().unwrap().unwrap();
+            *self.hub.lock().unwrap() = Some(capydeploy_protocol::messages::ConnectedHubEntry {
+                name: req.name,
+                ip: sender.remote_ip(),
+                connected_since: 1,
+                current: true,
+            });
+            Box::pin(async {})
+        }
+
+        fn on_hub_disconnected(&self) -> HandlerFuture<'_> {
+            *self.hub.lock().unwrap() = None;
+            Box::pin(async {})
+        }
+
+        fn on_list_connected_hubs(
+            &self,
+            sender: connection::Sender,
+            msg: Message,
+        ) -> HandlerFuture<'_> {
+            let resp = capydeploy_protocol::messages::ConnectedHubsResponse {
+                hubs: self.hub.lock().unwrap().iter().cloned().collect(),
+            };
+            let _ = sender.send_msg(
+                msg.reply(MessageType::ConnectedHubsResponse, Some(&resp))
+                    .unwrap(),
+            );
+            Box::pin(async {})
+        }
+    }
+
+    #[tokio::test]
+    async fn connected_hubs_follow_connections() {
+        use futures_util::{SinkExt, StreamExt};
+        use tokio_tungstenite::tungstenite::Message as WsMessage;
+
+        type Ws = tokio_tungstenite::WebSocketStream<
+            tokio_tungstenite::MaybeTlsStream<tokio::net::TcpStream>,
+        >;
+
+        async fn send(ws: &mut Ws, msg_type: &str, payload: serde_json::Value) {
+            let msg = serde_json::json!({ "id": msg_type, "type": msg_type, "payload": payload });
+            ws.send(WsMessage::Text(msg.to_string().into()))
+                .await
+                .unwrap();
+        }
+
+        async fn list(ws: &mut Ws) -> Vec<String> {
+            send(ws, "list_connected_hubs", serde_json::json!({})).await;
+            let reply = loop {
+                match ws.next().await.unwrap().unwrap() {
+                    WsMessage::Text(text) => break serde_json::from_str::<Message>(&text).unwrap(),
+                    _ => continue,
+                }
+            };
+            let resp: capydeploy_protocol::messages::ConnectedHubsResponse =
+                reply.parse_payload().unwrap().unwrap();
+            resp.hubs
+                .into_iter()
+                .map(|h| format!("{}@{}", h.name, h.ip))
+                .collect()
+        }
+
+        let handler = HubListHandler {
+            hub: std::sync::Mutex::new(None),
+        };
+        let config = ServerConfig {
+            port: 0,
+            ..Default::default()
+        };
+        let server = AgentServer::new(config, handler, accept_flag(true));
+        let server2 = Arc::clone(&server);
+        let handle = tokio::spawn(async move {
+            server2.run().await.unwrap();
+        });
+
+        tokio::time::sleep(std::time::Duration::from_millis(50)).await;
+        let url = format!("ws://127.0.0.1:{}", server.port().await);
+
+        let (mut alice, _) = tokio_tungstenite::connect_async(&url).await.unwrap();
+        send(
+            &mut alice,
+            "hub_connected",
+            serde_json::json!({ "name": "Alice", "version": "1" }),
+        )
+        .await;
+        assert_eq!(list(&mut alice).await, ["Alice@127.0.0.1"]);
+
+        // A new Hub replaces the old connection.
+        let (mut bob, _) = tokio_tungstenite::connect_async(&url).await.unwrap();
+        send(
+            &mut bob,
+            "hub_connected",
+            serde_json::json!({ "name": "Bob", "version": "1" }),
+        )
+        .await;
+        assert_eq!(list(&mut bob).await, ["Bob@127.0.0.1"]);
+
+        drop(bob);
+        tokio::time::sleep(std::time::Duration::from_millis(100)).await;
+        assert!(server.handler.hub.lock().unwrap().is_none());
+
+        drop(alice);
+        server.shutdown();
+        handle.await.unwrap();
+    }
+
+    #[tokio::test]
+    async fn set_verbose_toggles_server_logging() {
+        use futures_util::{SinkExt, StreamExt};
+        use tokio_tungstenite::tungstenite::Message as WsMessage;
+
+        let verbose = Arc::new(AtomicBool::new(false));
+        let config = ServerConfig {
+            port: 0,
+            verbose: verbose.clone(),
+            ..Default::default()
+        };
+        let handler = VerboseHandler {
+            verbose: verbose.clone(),
+        };
+        let server = AgentServer::new(config, handler, accept_flag(true));
+        assert!(!server.is_verbose());
+        let server2 = Arc::clone(&server);
+
+        let handle = tokio::spawn(async move {
+            server2.run().await.unwrap();
+        });
+
+        tokio::time::sleep(std::time::Duration::from_millis(50)).await;
+        let port = server.port().await;
+        let url = format!("ws://127.0.0.1:{port}");
+        let (mut ws, _) = tokio_tungstenite::connect_async(&url).await.unwrap();
+
+        for enabled in [true, false] {
+            let msg = serde_json::json!({
+                "id": format!("verbose-{enabled}"),
+                "type": "set_verbose",
+                "payload": { "enabled": enabled }
+            });
+            ws.send(WsMessage::Text(msg.to_string().into()))
+                .await
+                .unwrap();
+
+            let reply = loop {
+                match ws.next().await.unwrap().unwrap() {
+                    WsMessage::Text(text) => break serde_json::from_str::<Message>(&text).unwrap(),
+                    _ => continue,
+                }
+            };
+            assert_eq!(reply.msg_type, MessageType::SetVerbose);
+            assert_eq!(server.is_verbose(), enabled);
+        }
+
+        drop(ws);
+        server.shutdown();
+        handle.await.unwrap();
+    }
 }
@@ -6,6 +6,7 @@
 use std::net::SocketAddr;
 use std::path::PathBuf;
 
+use capydeploy_transfer::RateLimiter;
 use md5::{Digest, Md5};
 use tokio::io::{AsyncReadExt, BufWriter};
 use tokio::net::TcpStream;
@@ -34,6 +35,27 @@ impl TcpDataClient {
         files: &[(PathBuf, String)],
         cancel: CancellationToken,
         progress_tx: mpsc::Sender<(i64, String)>,
+    ) -> Result<i64, DataChannelError> {
+        Self::connect_and_send_limited(
+            addr,
+            token,
+            files,
+            cancel,
+            progress_tx,
+            &RateLimiter::unlimited(),
+        )
+        .await
+    }
+
+    /// Like [`connect_and_send`](Self::connect_and_send), but sends no
+    /// faster than `limiter` allows.
+    pub async fn connect_and_send_limited(
+        addr: SocketAddr,
+        token: &str,
+        files: &[(PathBuf, String)],
+        cancel: CancellationToken,
+        progress_tx: mpsc::Sender<(i64, String)>,
+        limiter: &RateLimiter,
     ) -> Result<i64, DataChannelError> {
         // Connect with timeout + cancellation.
         let stream = tokio::select! {
@@ -117,6 +139,14 @@ impl TcpDataClient {
                     ));
                 }
 
+                tokio::select! {
+                    biased;
+                    _ = cancel.cancelled() => {
+                        return Err(DataChannelError::Cancelled);
+                    }
+                    _ = limiter.acquire(n) => {}
+                }
+
                 hasher.update(&buf[..n]);
                 tokio::io::AsyncWriteExt::write_all(&mut writer, &buf[..n]).await?;
                 remaining -= n as u64;
@@ -351,6 +381,50 @@ mod tests {
         assert_eq!(received_data, b"BINARY_DATA_HERE");
     }
 
+    /// The limit applies across all files of a transfer.
+    #[tokio::test]
+    async fn client_send_respects_rate_limit() {
+        let server_dir = tempfile::tempdir().unwrap();
+        let client_dir = tempfile::tempdir().unwrap();
+
+        let file1_path = client_dir.path().join("a.bin");
+        let file2_path = client_dir.path().join("b.bin");
+        std::fs::write(&file1_path, vec![1u8; 1500]).unwrap();
+        std::fs::write(&file2_path, vec![2u8; 1500]).unwrap();
+        let files = vec![
+            (file1_path, "a.bin".to_string()),
+            (file2_path, "b.bin".to_string()),
+        ];
+
+        let cancel = CancellationToken::new();
+        let server = TcpDataServer::new(server_dir.path().to_path_buf(), cancel.clone());
+        let (info, listener) = server.listen().await.unwrap();
+        let addr: SocketAddr = format!("127.0.0.1:{}", info.port).parse().unwrap();
+        let token = info.token.clone();
+        let (s_tx, _s_rx) = mpsc::channel(64);
+        let (c_tx, _c_rx) = mpsc::channel(64);
+        let server_handle =
+            tokio::spawn(async move { server.accept_and_receive(listener, &token, s_tx).await });
+
+        // 2000 bytes go out as the first burst, the last 1000 after 0.5s.
+        let limiter = RateLimiter::new(2000);
+        let start = std::time::Instant::now();
+        let sent = TcpDataClient::connect_and_send_limited(
+            addr,
+            &info.token,
+            &files,
+            cancel,
+            c_tx,
+            &limiter,
+        )
+        .await
+        .unwrap();
+
+        assert_eq!(sent, 3000);
+        assert!(start.elapsed() >= std::time::Duration::from_millis(450));
+        assert_eq!(server_handle.await.unwrap().unwrap(), 3000);
+    }
+
     /// Test subdirectory creation during transfer.
     #[tokio::test]
     async fn client_server_subdirectories() {
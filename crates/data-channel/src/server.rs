@@ -125,10 +125,16 @@ impl TcpDataServer {
                 }
             };
 
-            // Validate path (same rules as transfer crate).
+            // Validate path (same rules as transfer crate), then make sure
+            // no symlink in the game folder points the write elsewhere.
             validate_upload_path(&header.relative_path)?;
-
-            let file_path = self.base_path.join(&header.relative_path);
+            let file_path = capydeploy_transfer::safe_join(&self.base_path, &header.relative_path)
+                .map_err(|e| {
+                    DataChannelError::InvalidPath(match e {
+                        capydeploy_transfer::TransferError::InvalidPath(m) => m,
+                        e => e.to_string(),
+                    })
+                })?;
             if let Some(parent) = file_path.parent() {
                 tokio::fs::create_dir_all(parent).await?;
             }
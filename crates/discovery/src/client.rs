@@ -8,13 +8,19 @@ use mdns_sd::{ServiceDaemon, ServiceEvent};
 use tokio::sync::mpsc;
 
 use crate::DiscoveryError;
-use crate::types::{DEFAULT_TTL, DiscoveredAgent, DiscoveryEvent, EventType, SERVICE_NAME};
+use crate::netwatch::{NETWORK_POLL_INTERVAL, NetworkChange, NetworkWatcher};
+use crate::types::{
+    DEFAULT_TTL, DiscoveredAgent, DiscoveryEvent, EventType, SERVICE_NAME, is_reachable_ip,
+    sort_by_preference,
+};
 
 /// Discovers agents on the local network via mDNS/DNS-SD.
 pub struct Client {
     agents: Arc<RwLock<HashMap<String, DiscoveredAgent>>>,
     events_tx: mpsc::Sender<DiscoveryEvent>,
     events_rx: Option<mpsc::Receiver<DiscoveryEvent>>,
+    network_tx: mpsc::Sender<NetworkChange>,
+    network_rx: Option<mpsc::Receiver<NetworkChange>>,
     timeout: Duration,
 }
 
@@ -22,10 +28,13 @@ impl Client {
     /// Creates a new mDNS discovery client.
     pub fn new() -> Self {
         let (tx, rx) = mpsc::channel(16);
+        let (network_tx, network_rx) = mpsc::channel(4);
         Self {
             agents: Arc::new(RwLock::new(HashMap::new())),
             events_tx: tx,
             events_rx: Some(rx),
+            network_tx,
+            network_rx: Some(network_rx),
             timeout: Duration::from_secs(DEFAULT_TTL),
         }
     }
@@ -40,6 +49,11 @@ impl Client {
         self.events_rx.take()
     }
 
+    /// Takes the network change receiver. Can only be called once.
+    pub fn take_network_events(&mut self) -> Option<mpsc::Receiver<NetworkChange>> {
+        self.network_rx.take()
+    }
+
     /// Performs a one-time mDNS query and returns discovered agents.
     pub async fn discover(
         &self,
@@ -88,40 +102,83 @@ impl Client {
     ///
     /// Creates a single `ServiceDaemon` that browses for the entire
     /// session, avoiding the repeated create/destroy cycle that causes
-    /// noisy shutdown errors in the `mdns_sd` crate.
+    /// noisy shutdown errors in the `mdns_sd` crate. The daemon is only
+    /// recreated when the local network changes, since it stays bound to
+    /// the interfaces that existed when it started.
+    ///
+    /// While `paused` is true the daemon is shut down and nothing is sent
+    /// on the network; browsing restarts when it flips back to false.
     pub async fn start_continuous_discovery(
         &self,
         mut cancel: tokio::sync::watch::Receiver<bool>,
+        mut paused: tokio::sync::watch::Receiver<bool>,
         prune_interval: Duration,
     ) {
-        let daemon = match ServiceDaemon::new() {
-            Ok(d) => d,
-            Err(e) => {
-                eprintln!("discovery: failed to create mDNS daemon: {e}");
-                return;
+        let service_type = format!("{SERVICE_NAME}.local.");
+        let start_browser = || {
+            let daemon = match ServiceDaemon::new() {
+                Ok(d) => d,
+                Err(e) => {
+                    eprintln!("discovery: failed to create mDNS daemon: {e}");
+                    return None;
+                }
+            };
+            match daemon.browse(&service_type) {
+                Ok(rx) => Some((daemon, rx)),
+                Err(e) => {
+                    eprintln!("discovery: failed to browse mDNS: {e}");
+                    let _ = daemon.shutdown();
+                    None
+                }
             }
         };
 
-        let service_type = format!("{SERVICE_NAME}.local.");
-        let event_rx = match daemon.browse(&service_type) {
-            Ok(rx) => rx,
-            Err(e) => {
-                eprintln!("discovery: failed to browse mDNS: {e}");
-                let _ = daemon.shutdown();
+        let mut browser = None;
+        if !*paused.borrow_and_update() {
+            browser = start_browser();
+            if browser.is_none() {
                 return;
             }
-        };
+        }
+        let mut watcher = NetworkWatcher::system();
 
         let mut prune_ticker = tokio::time::interval(prune_interval);
         prune_ticker.tick().await; // consume first immediate tick
+        let mut network_ticker = tokio::time::interval(NETWORK_POLL_INTERVAL);
+        network_ticker.tick().await;
 
         loop {
+            if *paused.borrow_and_update() {
+                if let Some((daemon, _)) = browser.take() {
+                    let _ = daemon.shutdown();
+                }
+                tokio::select! {
+                    changed = paused.changed() => {
+                        if changed.is_err() {
+                            return;
+                        }
+                    }
+                    _ = cancel.changed() => return,
+                }
+                if !*paused.borrow() {
+                    browser = start_browser();
+                }
+                continue;
+            }
+
+            let event_rx = browser.as_ref().map(|(_, rx)| rx.clone());
             tokio::select! {
                 // Receive mDNS events via spawn_blocking (recv is blocking).
-                result = tokio::task::spawn_blocking({
-                    let rx = event_rx.clone();
-                    move || rx.recv_timeout(Duration::from_millis(500))
-                }) => {
+                result = async move {
+                    match event_rx {
+                        Some(rx) => tokio::task::spawn_blocking(move || {
+                            rx.recv_timeout(Duration::from_millis(500))
+                        })
+                        .await,
+                        // No browser until the network comes back.
+                        None => std::future::pending().await,
+                    }
+                } => {
                     if let Ok(Ok(event)) = result {
                         self.process_event(&event);
                     }
@@ -130,15 +187,52 @@ impl Client {
                 _ = prune_ticker.tick() => {
                     self.prune_stale_agents().await;
                 }
+                // Restart browsing on the new network.
+                _ = network_ticker.tick() => {
+                    if let Some(change) = watcher.poll() {
+                        self.reset_for_network_change(change);
+                        if let Some((daemon, _)) = browser.take() {
+                            let _ = daemon.shutdown();
+                        }
+                        browser = start_browser();
+                    } else if browser.is_none() {
+                        browser = start_browser();
+                    }
+                }
+                // Pausing is handled at the top of the loop.
+                Ok(()) = paused.changed() => {}
                 // Cancellation signal.
                 _ = cancel.changed() => {
-                    let _ = daemon.shutdown();
+                    if let Some((daemon, _)) = browser.take() {
+                        let _ = daemon.shutdown();
+                    }
                     return;
                 }
             }
         }
     }
 
+    /// Flushes every tracked agent after a network change.
+    ///
+    /// Each agent is reported as lost (it is unreachable from the new
+    /// network until mDNS finds it again), then the change itself is
+    /// published to [`take_network_events`](Self::take_network_events).
+    /// Returns the number of agents flushed.
+    pub fn reset_for_network_change(&self, change: NetworkChange) -> usize {
+        let flushed: Vec<DiscoveredAgent> = {
+            let mut agents = self.agents.write().unwrap();
+            agents.drain().map(|(_, agent)| agent).collect()
+        };
+        for agent in &flushed {
+            let _ = self.events_tx.try_send(DiscoveryEvent {
+                event_type: EventType::Lost,
+                agent: agent.clone(),
+            });
+        }
+        let _ = self.network_tx.try_send(change);
+        flushed.len()
+    }
+
     /// Processes an mDNS service event into a `DiscoveredAgent`.
     fn process_event(&self, event: &ServiceEvent) -> Option<DiscoveredAgent> {
         let ServiceEvent::ServiceResolved(info) = event else {
@@ -152,6 +246,12 @@ impl Client {
             version: String::new(),
             accept_connections: false,
             supported_image_formats: vec![],
+            steam_login_state: String::new(),
+            max_concurrent_uploads: capydeploy_protocol::constants::DEFAULT_MAX_CONCURRENT_UPLOADS,
+            verbose: false,
+            protocol_version: 0,
+            started_at: 0,
+            start_reason: String::new(),
         };
 
         // Parse TXT records
@@ -175,24 +275,14 @@ impl Client {
             agent_info.name = info.get_hostname().to_string();
         }
 
-        // Collect IPs (filter out loopback and link-local)
-        let ips: Vec<IpAddr> = info
+        // Collect reachable IPs, IPv4 first.
+        let mut ips: Vec<IpAddr> = info
             .get_addresses()
             .iter()
-            .filter_map(|ip| {
-                let ip4 = match ip {
-                    std::net::IpAddr::V4(v4) => *v4,
-                    _ => return None,
-                };
-                if ip4.octets()[0] == 127 {
-                    return None;
-                }
-                if ip4.octets()[0] == 169 && ip4.octets()[1] == 254 {
-                    return None;
-                }
-                Some(IpAddr::V4(ip4))
-            })
+            .copied()
+            .filter(is_reachable_ip)
             .collect();
+        sort_by_preference(&mut ips);
 
         let now = Instant::now();
         let agent = DiscoveredAgent {
@@ -202,6 +292,7 @@ impl Client {
             ips,
             discovered_at: Some(now),
             last_seen: Some(now),
+            manual: false,
         };
 
         // Update or add agent
@@ -290,15 +381,45 @@ mod tests {
                 version: "0.1.0".into(),
                 accept_connections: true,
                 supported_image_formats: vec![],
+                steam_login_state: String::new(),
+                max_concurrent_uploads:
+                    capydeploy_protocol::constants::DEFAULT_MAX_CONCURRENT_UPLOADS,
+                verbose: false,
+                protocol_version: 0,
+                started_at: 0,
+                start_reason: String::new(),
             },
             host: "test.local".into(),
             port: 8765,
             ips: vec!["192.168.1.10".parse().unwrap()],
             discovered_at: Some(Instant::now()),
             last_seen: Some(Instant::now()),
+            manual: false,
         }
     }
 
+    #[tokio::test]
+    async fn paused_discovery_stays_quiet_until_cancelled() {
+        let mut client = Client::new();
+        let mut events = client.take_events().unwrap();
+        let (cancel_tx, cancel_rx) = tokio::sync::watch::channel(false);
+        let (_paused_tx, paused_rx) = tokio::sync::watch::channel(true);
+
+        let run = async {
+            client
+                .start_continuous_discovery(cancel_rx, paused_rx, Duration::from_millis(50))
+                .await
+        };
+        let stop = async {
+            tokio::time::sleep(Duration::from_millis(200)).await;
+            cancel_tx.send(true).unwrap();
+        };
+        tokio::time::timeout(Duration::from_secs(2), async { tokio::join!(run, stop) })
+            .await
+            .expect("paused loop should exit on cancel");
+        assert!(events.try_recv().is_err());
+    }
+
     #[test]
     fn client_agent_tracking() {
         let client = Client::new();
@@ -340,4 +461,35 @@ mod tests {
         client.clear();
         assert!(client.get_agents().is_empty());
     }
+
+    #[test]
+    fn network_change_flushes_agents_and_notifies() {
+        let mut client = Client::new();
+        let mut events = client.take_events().unwrap();
+        let mut network = client.take_network_events().unwrap();
+
+        {
+            let mut agents = client.agents.write().unwrap();
+            agents.insert("a1".into(), make_agent("a1"));
+            agents.insert("a2".into(), make_agent("a2"));
+        }
+
+        // Simulate switching from home Wi-Fi to another network.
+        let addrs = std::sync::Mutex::new(vec!["192.168.1.5".parse::<IpAddr>().unwrap()]);
+        let mut watcher = NetworkWatcher::new(|| addrs.lock().unwrap().clone());
+        *addrs.lock().unwrap() = vec!["10.8.0.2".parse().unwrap()];
+        let change = watcher.poll().expect("interface change");
+
+        assert_eq!(client.reset_for_network_change(change.clone()), 2);
+        assert!(client.get_agents().is_empty());
+
+        let mut lost = Vec::new();
+        while let Ok(e) = events.try_recv() {
+            assert_eq!(e.event_type, EventType::Lost);
+            lost.push(e.agent.info.id);
+        }
+        lost.sort();
+        assert_eq!(lost, vec!["a1", "a2"]);
+        assert_eq!(network.try_recv().unwrap(), change);
+    }
 }
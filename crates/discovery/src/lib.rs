@@ -1,14 +1,17 @@
 pub mod client;
+pub mod netwatch;
 pub mod platform;
 pub mod server;
 pub mod types;
 
 // Re-export primary types.
 pub use client::Client;
+pub use netwatch::{NETWORK_POLL_INTERVAL, NetworkChange, NetworkWatcher};
 pub use platform::detect_platform;
 pub use server::{Server, get_hostname, get_local_ips};
 pub use types::{
-    DEFAULT_TTL, DiscoveredAgent, DiscoveryEvent, EventType, SERVICE_NAME, ServiceInfo,
+    DEFAULT_TTL, DiscoveredAgent, DiscoveryEvent, EventType, SERVICE_NAME, ServiceInfo, host_port,
+    is_reachable_ip, sort_by_preference,
 };
 
 /// Errors for discovery operations.
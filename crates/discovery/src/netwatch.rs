@@ -0,0 +1,127 @@
+//! Local network change detection.
+//!
+//! mDNS has no notion of "the network went away": when the Hub moves to
+//! another Wi-Fi or a VPN comes up, agents from the old network linger in
+//! the cache until their TTL expires. The watcher polls the local interface
+//! addresses and reports when the set changes, so discovery can be reset.
+
+use std::net::IpAddr;
+use std::time::Duration;
+
+use crate::server::get_local_ips;
+
+/// How often local addresses are polled during continuous discovery.
+pub const NETWORK_POLL_INTERVAL: Duration = Duration::from_secs(5);
+
+/// A change in the local interface addresses.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct NetworkChange {
+    /// Addresses before the change (sorted).
+    pub previous: Vec<IpAddr>,
+    /// Addresses after the change (sorted).
+    pub current: Vec<IpAddr>,
+}
+
+/// Polls local addresses and reports changes.
+///
+/// The address source is injectable so tests can simulate interface changes.
+pub struct NetworkWatcher<F = fn() -> Vec<IpAddr>> {
+    probe: F,
+    last: Vec<IpAddr>,
+}
+
+impl NetworkWatcher {
+    /// Creates a watcher over the system's reachable addresses.
+    pub fn system() -> Self {
+        Self::new(get_local_ips as fn() -> Vec<IpAddr>)
+    }
+}
+
+impl<F: Fn() -> Vec<IpAddr>> NetworkWatcher<F> {
+    /// Creates a watcher, taking the initial snapshot from `probe`.
+    pub fn new(probe: F) -> Self {
+        let last = snapshot(&probe);
+        Self { probe, last }
+    }
+
+    /// Returns the addresses seen on the last poll.
+    pub fn addresses(&self) -> &[IpAddr] {
+        &self.last
+    }
+
+    /// Re-reads the addresses; returns the change if the set differs.
+    pub fn poll(&mut self) -> Option<NetworkChange> {
+        let current = snapshot(&self.probe);
+        if current == self.last {
+            return None;
+        }
+        let previous = std::mem::replace(&mut self.last, current.clone());
+        Some(NetworkChange { previous, current })
+    }
+}
+
+fn snapshot(probe: &impl Fn() -> Vec<IpAddr>) -> Vec<IpAddr> {
+    let mut ips = probe();
+    ips.sort();
+    ips.dedup();
+    ips
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::{Arc, Mutex};
+
+    fn ip(s: &str) -> IpAddr {
+        s.parse().unwrap()
+    }
+
+    fn simulated(initial: Vec<IpAddr>) -> (Arc<Mutex<Vec<IpAddr>>>, impl Fn() -> Vec<IpAddr>) {
+        let addrs = Arc::new(Mutex::new(initial));
+        let probe = {
+            let addrs = addrs.clone();
+            move || addrs.lock().unwrap().clone()
+        };
+        (addrs, probe)
+    }
+
+    #[test]
+    fn unchanged_addresses_report_nothing() {
+        let (_, probe) = simulated(vec![ip("192.168.1.5")]);
+        let mut watcher = NetworkWatcher::new(probe);
+        assert!(watcher.poll().is_none());
+        assert!(watcher.poll().is_none());
+    }
+
+    #[test]
+    fn order_and_duplicates_are_ignored() {
+        let (addrs, probe) = simulated(vec![ip("10.0.0.2"), ip("192.168.1.5")]);
+        let mut watcher = NetworkWatcher::new(probe);
+        *addrs.lock().unwrap() = vec![ip("192.168.1.5"), ip("10.0.0.2"), ip("10.0.0.2")];
+        assert!(watcher.poll().is_none());
+    }
+
+    #[test]
+    fn wifi_switch_is_reported_once() {
+        let (addrs, probe) = simulated(vec![ip("192.168.1.5")]);
+        let mut watcher = NetworkWatcher::new(probe);
+
+        *addrs.lock().unwrap() = vec![ip("10.1.0.7")];
+        let change = watcher.poll().expect("change");
+        assert_eq!(change.previous, vec![ip("192.168.1.5")]);
+        assert_eq!(change.current, vec![ip("10.1.0.7")]);
+        assert_eq!(watcher.addresses(), &[ip("10.1.0.7")]);
+
+        assert!(watcher.poll().is_none());
+    }
+
+    #[test]
+    fn vpn_interface_coming_up_is_a_change() {
+        let (addrs, probe) = simulated(vec![ip("192.168.1.5")]);
+        let mut watcher = NetworkWatcher::new(probe);
+
+        addrs.lock().unwrap().push(ip("100.64.0.3"));
+        let change = watcher.poll().expect("change");
+        assert_eq!(change.current, vec![ip("100.64.0.3"), ip("192.168.1.5")]);
+    }
+}
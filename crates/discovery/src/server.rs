@@ -4,7 +4,7 @@ use mdns_sd::{ServiceDaemon, ServiceInfo as MdnsServiceInfo};
 use tokio_util::sync::CancellationToken;
 
 use crate::DiscoveryError;
-use crate::types::{SERVICE_NAME, ServiceInfo};
+use crate::types::{SERVICE_NAME, ServiceInfo, is_reachable_ip, sort_by_preference};
 
 /// Advertises an agent on the local network via mDNS/DNS-SD.
 pub struct Server {
@@ -106,31 +106,22 @@ impl Drop for Server {
     }
 }
 
-/// Returns local non-loopback IPv4 addresses, excluding link-local (169.254.x.x).
+/// Returns the local addresses other machines can reach, IPv4 first.
+///
+/// IPv6 addresses are included so agents on IPv6-only networks can still
+/// be found; see [`is_reachable_ip`] for what is filtered out.
 pub fn get_local_ips() -> Vec<IpAddr> {
-    let mut ips = Vec::new();
-
     let Ok(interfaces) = if_addrs::get_if_addrs() else {
-        return ips;
+        return Vec::new();
     };
 
-    for iface in interfaces {
-        if iface.is_loopback() {
-            continue;
-        }
-        if let IpAddr::V4(ipv4) = iface.ip() {
-            // Skip loopback
-            if ipv4.octets()[0] == 127 {
-                continue;
-            }
-            // Skip link-local (169.254.x.x / APIPA)
-            if ipv4.octets()[0] == 169 && ipv4.octets()[1] == 254 {
-                continue;
-            }
-            ips.push(IpAddr::V4(ipv4));
-        }
-    }
-
+    let mut ips: Vec<IpAddr> = interfaces
+        .iter()
+        .filter(|iface| !iface.is_loopback())
+        .map(|iface| iface.ip())
+        .filter(is_reachable_ip)
+        .collect();
+    sort_by_preference(&mut ips);
     ips
 }
 
@@ -1,5 +1,5 @@
 use std::fmt;
-use std::net::IpAddr;
+use std::net::{IpAddr, Ipv6Addr, SocketAddr};
 use std::time::{Duration, Instant};
 
 use capydeploy_protocol::AgentInfo;
@@ -23,15 +23,40 @@ pub struct DiscoveredAgent {
     pub discovered_at: Option<Instant>,
     #[serde(skip)]
     pub last_seen: Option<Instant>,
+    /// Added by address rather than found via mDNS.
+    #[serde(default)]
+    pub manual: bool,
 }
 
 impl DiscoveredAgent {
+    /// An agent the user added by address. `host` is a hostname or an
+    /// unbracketed IP literal.
+    pub fn manual(info: AgentInfo, host: &str, port: u16) -> Self {
+        let now = Instant::now();
+        Self {
+            info,
+            host: host.to_string(),
+            port,
+            ips: host.parse::<IpAddr>().into_iter().collect(),
+            discovered_at: Some(now),
+            last_seen: Some(now),
+            manual: true,
+        }
+    }
+
+    /// Returns the IP to connect to: IPv4 when the agent has one, else the
+    /// most routable IPv6 address.
+    pub fn preferred_ip(&self) -> Option<IpAddr> {
+        self.ips.iter().copied().min_by_key(ip_preference)
+    }
+
     /// Returns the address (IP:port or host:port) for connecting to the agent.
+    /// IPv6 literals are bracketed (`[fe80::1]:8765`).
     pub fn address(&self) -> String {
-        if let Some(ip) = self.ips.first() {
-            format!("{ip}:{}", self.port)
+        if let Some(ip) = self.preferred_ip() {
+            SocketAddr::new(ip, self.port).to_string()
         } else {
-            format!("{}:{}", self.host, self.port)
+            host_port(&self.host, self.port)
         }
     }
 
@@ -49,6 +74,46 @@ impl DiscoveredAgent {
     }
 }
 
+/// Formats `host:port`, bracketing `host` if it is an IPv6 literal.
+pub fn host_port(host: &str, port: u16) -> String {
+    match host.parse::<Ipv6Addr>() {
+        Ok(ip) => SocketAddr::new(ip.into(), port).to_string(),
+        Err(_) => format!("{host}:{port}"),
+    }
+}
+
+/// Whether `ip` can reach an agent from another machine.
+///
+/// Loopback, unspecified and multicast addresses are rejected, as are IPv4
+/// link-local (169.254.x.x / APIPA) ones, which only show up when DHCP
+/// failed. IPv6 link-local addresses are kept: on IPv6-only networks they
+/// may be all an agent has.
+pub fn is_reachable_ip(ip: &IpAddr) -> bool {
+    if ip.is_loopback() || ip.is_unspecified() || ip.is_multicast() {
+        return false;
+    }
+    match ip {
+        IpAddr::V4(v4) => !v4.is_link_local(),
+        IpAddr::V6(_) => true,
+    }
+}
+
+/// Sort key for connecting: IPv4 first for compatibility, then routable
+/// IPv6, then IPv6 link-local.
+fn ip_preference(ip: &IpAddr) -> u8 {
+    match ip {
+        IpAddr::V4(_) => 0,
+        IpAddr::V6(v6) if v6.is_unicast_link_local() => 2,
+        IpAddr::V6(_) => 1,
+    }
+}
+
+/// Orders `ips` by connection preference (see [`DiscoveredAgent::preferred_ip`]),
+/// keeping the original order within each family.
+pub fn sort_by_preference(ips: &mut [IpAddr]) {
+    ips.sort_by_key(ip_preference);
+}
+
 /// Information for advertising an agent via mDNS.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct ServiceInfo {
@@ -71,6 +136,12 @@ impl ServiceInfo {
             version: self.version.clone(),
             accept_connections: false,
             supported_image_formats: vec![],
+            steam_login_state: String::new(),
+            max_concurrent_uploads: capydeploy_protocol::constants::DEFAULT_MAX_CONCURRENT_UPLOADS,
+            verbose: false,
+            protocol_version: 0,
+            started_at: 0,
+            start_reason: String::new(),
         }
     }
 }
@@ -113,12 +184,20 @@ mod tests {
                 version: "0.1.0".into(),
                 accept_connections: true,
                 supported_image_formats: vec![],
+                steam_login_state: String::new(),
+                max_concurrent_uploads:
+                    capydeploy_protocol::constants::DEFAULT_MAX_CONCURRENT_UPLOADS,
+                verbose: false,
+                protocol_version: 0,
+                started_at: 0,
+                start_reason: String::new(),
             },
             host: "test.local".into(),
             port: 8765,
             ips: vec!["192.168.1.100".parse().unwrap()],
             discovered_at: Some(Instant::now()),
             last_seen: Some(Instant::now()),
+            manual: false,
         }
     }
 
@@ -141,6 +220,82 @@ mod tests {
         assert_eq!(agent.websocket_address(), "ws://192.168.1.100:8765/ws");
     }
 
+    #[test]
+    fn address_brackets_ipv6() {
+        let mut agent = test_agent();
+        agent.ips = vec!["fe80::1".parse().unwrap()];
+        assert_eq!(agent.address(), "[fe80::1]:8765");
+        assert_eq!(agent.websocket_address(), "ws://[fe80::1]:8765/ws");
+    }
+
+    #[test]
+    fn address_brackets_ipv6_host() {
+        let mut agent = test_agent();
+        agent.ips.clear();
+        agent.host = "2001:db8::7".into();
+        assert_eq!(agent.websocket_address(), "ws://[2001:db8::7]:8765/ws");
+    }
+
+    #[test]
+    fn manual_agent_from_address() {
+        let info = test_agent().info;
+        let agent = DiscoveredAgent::manual(info.clone(), "192.168.1.50", 9000);
+        assert!(agent.manual);
+        assert_eq!(agent.ips, vec!["192.168.1.50".parse::<IpAddr>().unwrap()]);
+        assert_eq!(agent.websocket_address(), "ws://192.168.1.50:9000/ws");
+
+        let agent = DiscoveredAgent::manual(info.clone(), "2001:db8::7", 8765);
+        assert_eq!(agent.websocket_address(), "ws://[2001:db8::7]:8765/ws");
+
+        let agent = DiscoveredAgent::manual(info, "deck.lan", 8765);
+        assert!(agent.ips.is_empty());
+        assert_eq!(agent.websocket_address(), "ws://deck.lan:8765/ws");
+    }
+
+    #[test]
+    fn address_prefers_ipv4() {
+        let mut agent = test_agent();
+        agent.ips = vec![
+            "fe80::1".parse().unwrap(),
+            "2001:db8::7".parse().unwrap(),
+            "192.168.1.100".parse().unwrap(),
+        ];
+        assert_eq!(agent.address(), "192.168.1.100:8765");
+
+        agent.ips.pop();
+        assert_eq!(agent.address(), "[2001:db8::7]:8765");
+    }
+
+    #[test]
+    fn reachable_ips() {
+        let ok = ["192.168.1.100", "2001:db8::7", "fe80::1", "fd00::5"];
+        for ip in ok {
+            assert!(is_reachable_ip(&ip.parse().unwrap()), "{ip}");
+        }
+        let rejected = [
+            "127.0.0.1",
+            "169.254.3.4",
+            "::1",
+            "::",
+            "ff02::fb",
+            "0.0.0.0",
+        ];
+        for ip in rejected {
+            assert!(!is_reachable_ip(&ip.parse().unwrap()), "{ip}");
+        }
+    }
+
+    #[test]
+    fn sort_by_preference_puts_ipv4_first() {
+        let mut ips: Vec<IpAddr> = ["fe80::1", "10.0.0.2", "2001:db8::7", "10.0.0.1"]
+            .iter()
+            .map(|s| s.parse().unwrap())
+            .collect();
+        sort_by_preference(&mut ips);
+        let got: Vec<String> = ips.iter().map(|ip| ip.to_string()).collect();
+        assert_eq!(got, ["10.0.0.2", "10.0.0.1", "2001:db8::7", "fe80::1"]);
+    }
+
     #[test]
     fn is_stale_fresh() {
         let agent = test_agent();
@@ -97,6 +97,124 @@ pub fn platform_roots() -> Vec<PathBuf> {
     }
 }
 
+/// Restricts directory browsing to a set of allowed roots.
+///
+/// Paths are canonicalized before the check, so `..` segments and symlinks
+/// can't escape a root.
+#[derive(Debug, Clone)]
+pub struct DirectoryScope {
+    roots: Vec<PathBuf>,
+}
+
+impl DirectoryScope {
+    /// Creates a scope over `roots`. Roots that don't exist are dropped.
+    pub fn new(roots: impl IntoIterator<Item = PathBuf>) -> Self {
+        let mut canonical: Vec<PathBuf> = Vec::new();
+        for root in roots {
+            if let Ok(c) = std::fs::canonicalize(&root)
+                && c.is_dir()
+                && !canonical.contains(&c)
+            {
+                canonical.push(c);
+            }
+        }
+        Self { roots: canonical }
+    }
+
+    /// Returns the canonical allowed roots.
+    pub fn roots(&self) -> &[PathBuf] {
+        &self.roots
+    }
+
+    /// Resolves an existing directory, failing if it lies outside every root.
+    pub fn resolve(&self, path: &Path) -> Result<PathBuf, String> {
+        let canonical = std::fs::canonicalize(path)
+            .map_err(|e| format!("failed to resolve path {}: {e}", path.display()))?;
+        if !self.contains(&canonical) {
+            return Err(format!(
+                "access denied: {} is outside allowed roots",
+                path.display()
+            ));
+        }
+        if !canonical.is_dir() {
+            return Err(format!("not a directory: {}", canonical.display()));
+        }
+        Ok(canonical)
+    }
+
+    /// Creates `path` (and missing parents) inside the scope and returns it
+    /// resolved.
+    ///
+    /// The path must be absolute without `..` segments, and its nearest
+    /// existing ancestor must already be inside a root.
+    pub fn create(&self, path: &Path) -> Result<PathBuf, String> {
+        self.check_create(path)?;
+        std::fs::create_dir_all(path)
+            .map_err(|e| format!("failed to create directory {}: {e}", path.display()))?;
+        self.resolve(path)
+    }
+
+    /// Checks that [`create`](Self::create) would accept `path`, without
+    /// creating anything. Returns the nearest existing ancestor, resolved.
+    pub fn check_create(&self, path: &Path) -> Result<PathBuf, String> {
+        if !path.is_absolute()
+            || path
+                .components()
+                .any(|c| matches!(c, std::path::Component::ParentDir))
+        {
+            return Err(format!(
+                "invalid path {}: must be absolute without '..'",
+                path.display()
+            ));
+        }
+        let existing = path
+            .ancestors()
+            .find(|a| a.exists())
+            .ok_or_else(|| format!("no existing ancestor for {}", path.display()))?;
+        self.resolve(existing)
+    }
+
+    /// Returns the parent of a resolved directory, or `None` at a root.
+    pub fn parent_of(&self, dir: &Path) -> Option<PathBuf> {
+        if self.roots.iter().any(|r| r == dir) {
+            return None;
+        }
+        dir.parent()
+            .filter(|p| self.contains(p))
+            .map(Path::to_path_buf)
+    }
+
+    fn contains(&self, canonical: &Path) -> bool {
+        self.roots.iter().any(|r| canonical.starts_with(r))
+    }
+}
+
+/// Roots the Hub may pick an install directory under: the home directory,
+/// removable media (SD cards on handhelds), and the configured install path
+/// if it lives elsewhere.
+pub fn install_roots(configured: Option<&Path>) -> Vec<PathBuf> {
+    let mut roots = Vec::new();
+
+    #[cfg(target_os = "windows")]
+    let home = std::env::var_os("USERPROFILE");
+    #[cfg(not(target_os = "windows"))]
+    let home = std::env::var_os("HOME");
+    if let Some(home) = home {
+        roots.push(PathBuf::from(home));
+    }
+
+    #[cfg(target_os = "linux")]
+    roots.push(PathBuf::from("/run/media"));
+
+    #[cfg(target_os = "windows")]
+    roots.extend(platform_roots());
+
+    if let Some(path) = configured {
+        roots.push(path.to_path_buf());
+    }
+    roots
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -168,4 +286,102 @@ mod tests {
         assert!(json.contains("\"name\":\"Games\""));
         assert!(json.contains("\"isDir\":true"));
     }
+
+    #[test]
+    fn scope_lists_nested_tree() {
+        let tmp = tempfile::tempdir().unwrap();
+        let root = tmp.path().join("home");
+        std::fs::create_dir_all(root.join("Games").join("Celeste")).unwrap();
+        std::fs::create_dir_all(root.join("Games").join("Hades")).unwrap();
+        std::fs::create_dir_all(root.join("Music")).unwrap();
+
+        let scope = DirectoryScope::new([root.clone(), tmp.path().join("missing")]);
+        assert_eq!(scope.roots().len(), 1);
+
+        let top = scope.resolve(&root).unwrap();
+        let names: Vec<String> = list_directory(&top)
+            .unwrap()
+            .into_iter()
+            .map(|e| e.name)
+            .collect();
+        assert_eq!(names, vec!["Games", "Music"]);
+        assert_eq!(scope.parent_of(&top), None);
+
+        let games = scope.resolve(&root.join("Games")).unwrap();
+        let names: Vec<String> = list_directory(&games)
+            .unwrap()
+            .into_iter()
+            .map(|e| e.name)
+            .collect();
+        assert_eq!(names, vec!["Celeste", "Hades"]);
+        assert_eq!(scope.parent_of(&games), Some(top));
+    }
+
+    #[test]
+    fn scope_rejects_traversal_outside_root() {
+        let tmp = tempfile::tempdir().unwrap();
+        let root = tmp.path().join("home");
+        std::fs::create_dir_all(root.join("Games")).unwrap();
+        std::fs::create_dir_all(tmp.path().join("secret")).unwrap();
+        let scope = DirectoryScope::new([root.clone()]);
+
+        let err = scope.resolve(&root.join("..").join("secret")).unwrap_err();
+        assert!(err.contains("outside allowed roots"), "{err}");
+        assert!(scope.resolve(tmp.path()).is_err());
+
+        let err = scope.create(&root.join("..").join("escape")).unwrap_err();
+        assert!(err.contains("'..'"), "{err}");
+        assert!(!tmp.path().join("escape").exists());
+
+        let err = scope
+            .create(&tmp.path().join("outside").join("new"))
+            .unwrap_err();
+        assert!(err.contains("outside allowed roots"), "{err}");
+        assert!(!tmp.path().join("outside").exists());
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn scope_rejects_symlink_escape() {
+        let tmp = tempfile::tempdir().unwrap();
+        let root = tmp.path().join("home");
+        std::fs::create_dir_all(&root).unwrap();
+        std::fs::create_dir_all(tmp.path().join("secret")).unwrap();
+        std::os::unix::fs::symlink(tmp.path().join("secret"), root.join("link")).unwrap();
+        let scope = DirectoryScope::new([root.clone()]);
+
+        assert!(scope.resolve(&root.join("link")).is_err());
+        assert!(scope.create(&root.join("link").join("new")).is_err());
+    }
+
+    #[test]
+    fn scope_creates_target_inside_root() {
+        let tmp = tempfile::tempdir().unwrap();
+        let root = tmp.path().join("home");
+        std::fs::create_dir_all(&root).unwrap();
+        let scope = DirectoryScope::new([root.clone()]);
+
+        let created = scope.create(&root.join("Games").join("New")).unwrap();
+        assert!(created.is_dir());
+        assert!(created.ends_with("Games/New"));
+        assert!(scope.create(Path::new("relative/dir")).is_err());
+    }
+
+    #[test]
+    fn scope_checks_target_without_creating() {
+        let tmp = tempfile::tempdir().unwrap();
+        let root = tmp.path().join("home");
+        std::fs::create_dir_all(&root).unwrap();
+        let scope = DirectoryScope::new([root.clone()]);
+
+        let target = root.join("Games").join("New");
+        let existing = scope.check_create(&target).unwrap();
+        assert_eq!(existing, std::fs::canonicalize(&root).unwrap());
+        assert!(!root.join("Games").exists());
+        assert!(
+            scope
+                .check_create(&tmp.path().join("outside").join("new"))
+                .is_err()
+        );
+    }
 }
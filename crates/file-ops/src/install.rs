@@ -2,22 +2,60 @@
 
 use std::path::{Path, PathBuf};
 
+/// Base directory an upload installs under.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum UploadBase {
+    /// The agent's configured install path.
+    Configured(PathBuf),
+    /// An absolute path the Hub asked for. Callers must check it against
+    /// the allowed install roots before writing there.
+    Requested(PathBuf),
+}
+
+impl UploadBase {
+    /// The directory, whichever side chose it.
+    pub fn path(&self) -> &Path {
+        match self {
+            Self::Configured(p) | Self::Requested(p) => p,
+        }
+    }
+}
+
+/// Picks the base directory for an upload.
+///
+/// An absolute `requested` path (after `~` expansion) wins. A relative or
+/// empty one is ignored in favour of `configured`, and an empty
+/// `configured` falls back to [`default_install_path`](crate::default_install_path).
+pub fn upload_base(requested: &str, configured: &str) -> UploadBase {
+    let requested = requested.trim();
+    if !requested.is_empty() {
+        let path = expand_home(requested);
+        if path.is_absolute() {
+            return UploadBase::Requested(path);
+        }
+    }
+    let configured = configured.trim();
+    if configured.is_empty() {
+        UploadBase::Configured(crate::default_install_path())
+    } else {
+        UploadBase::Configured(expand_home(configured))
+    }
+}
+
 /// Resolves the full installation path for a game.
 ///
-/// Priority: `custom_path` > `base_path`. If neither is provided,
-/// falls back to [`default_install_path`](crate::default_install_path).
+/// An absolute `custom_path` wins over `base_path`; see [`upload_base`].
 pub fn resolve_install_path(
     game_name: &str,
     custom_path: Option<&str>,
     base_path: Option<&str>,
 ) -> PathBuf {
-    let base = match (custom_path, base_path) {
-        (Some(custom), _) if !custom.is_empty() => expand_home(custom),
-        (_, Some(base)) if !base.is_empty() => expand_home(base),
-        _ => crate::default_install_path(),
-    };
-
-    base.join(game_name)
+    upload_base(
+        custom_path.unwrap_or_default(),
+        base_path.unwrap_or_default(),
+    )
+    .path()
+    .join(game_name)
 }
 
 /// Ensures the install directory exists, creating it if necessary.
@@ -88,6 +126,39 @@ mod tests {
         assert_eq!(path, PathBuf::from("/custom/MyGame"));
     }
 
+    #[test]
+    fn resolve_relative_custom_uses_base() {
+        let path = resolve_install_path("MyGame", Some("games/here"), Some("/base"));
+        assert_eq!(path, PathBuf::from("/base/MyGame"));
+    }
+
+    #[test]
+    fn upload_base_absolute_request_wins() {
+        assert_eq!(
+            upload_base("/mnt/sd/Games", "/home/deck/Games"),
+            UploadBase::Requested(PathBuf::from("/mnt/sd/Games"))
+        );
+        assert!(matches!(
+            upload_base("~/Other", "/home/deck/Games"),
+            UploadBase::Requested(p) if p.is_absolute() && p.ends_with("Other")
+        ));
+    }
+
+    #[test]
+    fn upload_base_relative_or_empty_uses_configured() {
+        for requested in ["", "  ", "Games", "../etc", "./sub"] {
+            assert_eq!(
+                upload_base(requested, "/home/deck/Games"),
+                UploadBase::Configured(PathBuf::from("/home/deck/Games")),
+                "requested {requested:?}"
+            );
+        }
+        assert_eq!(
+            upload_base("", ""),
+            UploadBase::Configured(crate::default_install_path())
+        );
+    }
+
     #[test]
     fn resolve_fallback_to_default() {
         // When no custom or base path, should use default (HOME/Games).
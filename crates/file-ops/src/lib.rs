@@ -1,15 +1,24 @@
 //! File operations for game installation, deletion and filesystem browsing.
 //!
 //! Provides safe path resolution, directory management, permission handling,
-//! and filesystem browsing for the agent.
+//! filesystem browsing, and deploy preflight checks for the agent.
 
 mod browse;
 mod delete;
 mod install;
+mod preflight;
+mod selftest;
 
-pub use browse::{DirEntry, list_directory, platform_roots};
+pub use browse::{DirEntry, DirectoryScope, install_roots, list_directory, platform_roots};
 pub use delete::{delete_artwork, delete_game_directory, grid_dir};
-pub use install::{ensure_install_dir, resolve_install_path, set_executable};
+pub use install::{
+    UploadBase, ensure_install_dir, resolve_install_path, set_executable, upload_base,
+};
+pub use preflight::{DeployPreflight, available_space, check_can_deploy, disk_usage};
+pub use selftest::{
+    CEF_PROBE_TIMEOUT, CHECK_CEF, CHECK_INSTALL_PATH, CHECK_SHORTCUTS, CHECK_STEAM_PATHS,
+    probe_writable, run_self_test,
+};
 
 /// Default game installation directory name under `$HOME`.
 pub const DEFAULT_GAMES_DIR: &str = "Games";
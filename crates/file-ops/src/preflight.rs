@@ -0,0 +1,404 @@
+//! Deploy preflight: decides whether the agent can accept a deploy.
+//!
+//! All conditions are evaluated so the Hub can show every blocker at once
+//! instead of discovering them one failed upload at a time.
+
+use std::future::Future;
+use std::path::Path;
+
+use capydeploy_protocol::constants::{
+    DEPLOY_BLOCKER_CEF_NOT_READY, DEPLOY_BLOCKER_DISK_FULL, DEPLOY_BLOCKER_NOT_ACCEPTING,
+    DEPLOY_BLOCKER_PATH_NOT_WRITABLE, DEPLOY_BLOCKER_STEAM_NOT_INSTALLED,
+};
+use capydeploy_protocol::messages::{CanDeployResponse, DeployBlocker, DiskUsage};
+use capydeploy_steam::{Paths, get_users_with_paths};
+
+use crate::selftest::{CEF_PROBE_TIMEOUT, probe_install_path};
+
+/// Agent state evaluated by [`check_can_deploy`].
+#[derive(Clone)]
+pub struct DeployPreflight<'a> {
+    /// Whether the agent currently accepts connections.
+    pub accept_connections: bool,
+    /// Steam installation, `None` if it could not be located.
+    pub steam: Option<&'a Paths>,
+    /// Base directory games are installed into.
+    pub install_path: &'a Path,
+    /// Bytes the deploy needs (0 = unknown).
+    pub required_bytes: u64,
+    /// Free bytes on the install volume (`None` = unknown).
+    pub available_bytes: Option<u64>,
+}
+
+/// Evaluates every deploy precondition and returns the combined verdict.
+///
+/// `cef_probe` is only run when Steam is installed, and is bounded by
+/// [`CEF_PROBE_TIMEOUT`].
+pub async fn check_can_deploy<F, Fut>(pre: &DeployPreflight<'_>, cef_probe: F) -> CanDeployResponse
+where
+    F: FnOnce() -> Fut,
+    Fut: Future<Output = Result<(), String>>,
+{
+    let mut reasons = Vec::new();
+
+    if !pre.accept_connections {
+        reasons.push(blocker(
+            DEPLOY_BLOCKER_NOT_ACCEPTING,
+            "agent is not accepting connections".into(),
+        ));
+    }
+
+    let steam_ok = match steam_status(pre.steam) {
+        Ok(()) => true,
+        Err(msg) => {
+            reasons.push(blocker(DEPLOY_BLOCKER_STEAM_NOT_INSTALLED, msg));
+            false
+        }
+    };
+
+    if let Err(msg) = probe_install_path(pre.install_path) {
+        reasons.push(blocker(DEPLOY_BLOCKER_PATH_NOT_WRITABLE, msg));
+    }
+
+    if let Some(available) = pre.available_bytes
+        && (available < pre.required_bytes || available == 0)
+    {
+        reasons.push(blocker(
+            DEPLOY_BLOCKER_DISK_FULL,
+            format!(
+                "{} free in {}, {} needed",
+                format_bytes(available),
+                pre.install_path.display(),
+                format_bytes(pre.required_bytes)
+            ),
+        ));
+    }
+
+    if steam_ok {
+        let cef = match tokio::time::timeout(CEF_PROBE_TIMEOUT, cef_probe()).await {
+            Ok(res) => res,
+            Err(_) => Err(format!(
+                "no response within {}s",
+                CEF_PROBE_TIMEOUT.as_secs()
+            )),
+        };
+        if let Err(e) = cef {
+            reasons.push(blocker(
+                DEPLOY_BLOCKER_CEF_NOT_READY,
+                format!("Steam CEF debugger unavailable: {e}"),
+            ));
+        }
+    }
+
+    CanDeployResponse {
+        can_deploy: reasons.is_empty(),
+        reasons,
+    }
+}
+
+/// Returns the free space on the volume holding `path`, in bytes.
+///
+/// `path` does not need to exist yet; its nearest existing ancestor is
+/// queried instead. Returns `None` if the space cannot be determined.
+pub fn available_space(path: &Path) -> Option<u64> {
+    disk_usage(path).map(|usage| usage.free_bytes)
+}
+
+/// Returns the size, free and used space of the filesystem holding `path`.
+///
+/// Like [`available_space`], a missing `path` is measured at its nearest
+/// existing ancestor. `free_bytes` is the space available to this process.
+pub fn disk_usage(path: &Path) -> Option<DiskUsage> {
+    let existing = path.ancestors().find(|p| p.exists())?;
+    let (total_bytes, free_bytes, used_bytes) = disk_usage_at(existing)?;
+    Some(DiskUsage {
+        path: path.to_string_lossy().into_owned(),
+        total_bytes,
+        free_bytes,
+        used_bytes,
+    })
+}
+
+/// `(total, free, used)` bytes of the filesystem holding `path`.
+#[cfg(unix)]
+fn disk_usage_at(path: &Path) -> Option<(u64, u64, u64)> {
+    use std::ffi::CString;
+    use std::os::unix::ffi::OsStrExt;
+
+    let c_path = CString::new(path.as_os_str().as_bytes()).ok()?;
+    let mut stat = std::mem::MaybeUninit::<libc::statvfs>::zeroed();
+    // SAFETY: c_path is a valid NUL-terminated string and stat points to
+    // writable memory of the right type.
+    let ret = unsafe { libc::statvfs(c_path.as_ptr(), stat.as_mut_ptr()) };
+    if ret != 0 {
+        return None;
+    }
+    // SAFETY: statvfs succeeded, so the struct is initialized.
+    let stat = unsafe { stat.assume_init() };
+    let frsize = stat.f_frsize as u64;
+    let blocks = stat.f_blocks as u64;
+    Some((
+        blocks * frsize,
+        stat.f_bavail as u64 * frsize,
+        blocks.saturating_sub(stat.f_bfree as u64) * frsize,
+    ))
+}
+
+#[cfg(windows)]
+fn disk_usage_at(path: &Path) -> Option<(u64, u64, u64)> {
+    use std::os::windows::ffi::OsStrExt;
+    use windows_sys::Win32::Storage::FileSystem::GetDiskFreeSpaceExW;
+
+    let wide: Vec<u16> = path.as_os_str().encode_wide().chain(Some(0)).collect();
+    let mut free: u64 = 0;
+    let mut total: u64 = 0;
+    let mut total_free: u64 = 0;
+    // SAFETY: wide is NUL-terminated and the out-pointers are valid u64s.
+    let ret = unsafe { GetDiskFreeSpaceExW(wide.as_ptr(), &mut free, &mut total, &mut total_free) };
+    (ret != 0).then(|| (total, free, total.saturating_sub(total_free)))
+}
+
+#[cfg(not(any(unix, windows)))]
+fn disk_usage_at(_path: &Path) -> Option<(u64, u64, u64)> {
+    None
+}
+
+fn steam_status(steam: Option<&Paths>) -> Result<(), String> {
+    let paths = steam.ok_or("Steam installation not found")?;
+    let users = get_users_with_paths(paths).map_err(|e| e.to_string())?;
+    if users.is_empty() {
+        return Err("no Steam user has logged in on this device".into());
+    }
+    Ok(())
+}
+
+fn blocker(code: &str, message: String) -> DeployBlocker {
+    DeployBlocker {
+        code: code.into(),
+        message,
+    }
+}
+
+fn format_bytes(bytes: u64) -> String {
+    const UNITS: &[&str] = &["B", "KB", "MB", "GB", "TB"];
+    let mut value = bytes as f64;
+    let mut unit = 0;
+    while value >= 1024.0 && unit < UNITS.len() - 1 {
+        value /= 1024.0;
+        unit += 1;
+    }
+    if unit == 0 {
+        format!("{bytes} B")
+    } else {
+        format!("{value:.1} {}", UNITS[unit])
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    fn fake_steam(root: &Path) -> Paths {
+        std::fs::create_dir_all(root.join("userdata").join("12345").join("config")).unwrap();
+        Paths::with_base(root)
+    }
+
+    fn codes(resp: &CanDeployResponse) -> Vec<&str> {
+        resp.reasons.iter().map(|r| r.code.as_str()).collect()
+    }
+
+    async fn cef_ok() -> Result<(), String> {
+        Ok(())
+    }
+
+    async fn cef_down() -> Result<(), String> {
+        Err("connection refused".into())
+    }
+
+    struct Fixture {
+        _tmp: tempfile::TempDir,
+        steam: Paths,
+        install: PathBuf,
+    }
+
+    fn fixture() -> Fixture {
+        let tmp = tempfile::tempdir().unwrap();
+        let steam = fake_steam(&tmp.path().join("steam"));
+        let install = tmp.path().join("Games");
+        Fixture {
+            _tmp: tmp,
+            steam,
+            install,
+        }
+    }
+
+    fn healthy(f: &Fixture) -> DeployPreflight<'_> {
+        DeployPreflight {
+            accept_connections: true,
+            steam: Some(&f.steam),
+            install_path: &f.install,
+            required_bytes: 1024,
+            available_bytes: Some(1 << 30),
+        }
+    }
+
+    #[tokio::test]
+    async fn healthy_agent_can_deploy() {
+        let f = fixture();
+        let resp = check_can_deploy(&healthy(&f), cef_ok).await;
+        assert!(resp.can_deploy, "{resp:?}");
+        assert!(resp.reasons.is_empty());
+    }
+
+    #[tokio::test]
+    async fn not_accepting_connections() {
+        let f = fixture();
+        let pre = DeployPreflight {
+            accept_connections: false,
+            ..healthy(&f)
+        };
+        let resp = check_can_deploy(&pre, cef_ok).await;
+        assert!(!resp.can_deploy);
+        assert_eq!(codes(&resp), vec![DEPLOY_BLOCKER_NOT_ACCEPTING]);
+    }
+
+    #[tokio::test]
+    async fn steam_missing_skips_cef_probe() {
+        let f = fixture();
+        let pre = DeployPreflight {
+            steam: None,
+            ..healthy(&f)
+        };
+        let resp = check_can_deploy(&pre, || async {
+            panic!("cef probe must not run without Steam")
+        })
+        .await;
+        assert_eq!(codes(&resp), vec![DEPLOY_BLOCKER_STEAM_NOT_INSTALLED]);
+    }
+
+    #[tokio::test]
+    async fn steam_without_users_is_not_installed() {
+        let tmp = tempfile::tempdir().unwrap();
+        let root = tmp.path().join("steam");
+        std::fs::create_dir_all(root.join("userdata")).unwrap();
+        let steam = Paths::with_base(&root);
+        let install = tmp.path().join("Games");
+        let pre = DeployPreflight {
+            accept_connections: true,
+            steam: Some(&steam),
+            install_path: &install,
+            required_bytes: 0,
+            available_bytes: None,
+        };
+        let resp = check_can_deploy(&pre, cef_ok).await;
+        assert_eq!(codes(&resp), vec![DEPLOY_BLOCKER_STEAM_NOT_INSTALLED]);
+    }
+
+    #[tokio::test]
+    async fn unwritable_install_path() {
+        let f = fixture();
+        std::fs::write(&f.install, b"not a dir").unwrap();
+        let resp = check_can_deploy(&healthy(&f), cef_ok).await;
+        assert_eq!(codes(&resp), vec![DEPLOY_BLOCKER_PATH_NOT_WRITABLE]);
+    }
+
+    #[tokio::test]
+    async fn insufficient_disk_space() {
+        let f = fixture();
+        let pre = DeployPreflight {
+            required_bytes: 5 * 1024 * 1024 * 1024,
+            available_bytes: Some(1024 * 1024 * 1024),
+            ..healthy(&f)
+        };
+        let resp = check_can_deploy(&pre, cef_ok).await;
+        assert_eq!(codes(&resp), vec![DEPLOY_BLOCKER_DISK_FULL]);
+        assert!(
+            resp.reasons[0].message.contains("1.0 GB free"),
+            "{}",
+            resp.reasons[0].message
+        );
+        assert!(resp.reasons[0].message.contains("5.0 GB needed"));
+    }
+
+    #[tokio::test]
+    async fn zero_free_space_blocks_unknown_size() {
+        let f = fixture();
+        let pre = DeployPreflight {
+            required_bytes: 0,
+            available_bytes: Some(0),
+            ..healthy(&f)
+        };
+        let resp = check_can_deploy(&pre, cef_ok).await;
+        assert_eq!(codes(&resp), vec![DEPLOY_BLOCKER_DISK_FULL]);
+    }
+
+    #[tokio::test]
+    async fn unknown_free_space_does_not_block() {
+        let f = fixture();
+        let pre = DeployPreflight {
+            required_bytes: u64::MAX,
+            available_bytes: None,
+            ..healthy(&f)
+        };
+        assert!(check_can_deploy(&pre, cef_ok).await.can_deploy);
+    }
+
+    #[tokio::test]
+    async fn cef_not_ready() {
+        let f = fixture();
+        let resp = check_can_deploy(&healthy(&f), cef_down).await;
+        assert_eq!(codes(&resp), vec![DEPLOY_BLOCKER_CEF_NOT_READY]);
+        assert!(resp.reasons[0].message.contains("connection refused"));
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn hung_cef_is_not_ready() {
+        let f = fixture();
+        let resp = check_can_deploy(&healthy(&f), || async {
+            std::future::pending::<Result<(), String>>().await
+        })
+        .await;
+        assert_eq!(codes(&resp), vec![DEPLOY_BLOCKER_CEF_NOT_READY]);
+    }
+
+    #[tokio::test]
+    async fn reports_all_blockers_at_once() {
+        let f = fixture();
+        std::fs::write(&f.install, b"not a dir").unwrap();
+        let pre = DeployPreflight {
+            accept_connections: false,
+            available_bytes: Some(10),
+            ..healthy(&f)
+        };
+        let resp = check_can_deploy(&pre, cef_down).await;
+        assert!(!resp.can_deploy);
+        assert_eq!(
+            codes(&resp),
+            vec![
+                DEPLOY_BLOCKER_NOT_ACCEPTING,
+                DEPLOY_BLOCKER_PATH_NOT_WRITABLE,
+                DEPLOY_BLOCKER_DISK_FULL,
+                DEPLOY_BLOCKER_CEF_NOT_READY,
+            ]
+        );
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn disk_usage_reports_consistent_sizes() {
+        let tmp = tempfile::tempdir().unwrap();
+        let usage = disk_usage(tmp.path()).unwrap();
+        assert_eq!(usage.path, tmp.path().to_string_lossy());
+        assert!(usage.total_bytes > 0);
+        assert!(usage.free_bytes <= usage.total_bytes);
+        assert!(usage.used_bytes <= usage.total_bytes);
+    }
+
+    #[test]
+    fn available_space_of_missing_dir_uses_ancestor() {
+        let tmp = tempfile::tempdir().unwrap();
+        let missing = tmp.path().join("a").join("b");
+        assert!(available_space(&missing).is_some());
+    }
+}
@@ -0,0 +1,263 @@
+//! Agent self-test: actively probes each subsystem the agent depends on.
+//!
+//! Each probe runs independently so one broken subsystem never hides the
+//! state of the others. Probes that depend on an earlier one (shortcuts
+//! need Steam paths) fail with a detail explaining what they were missing.
+
+use std::future::Future;
+use std::path::Path;
+use std::time::{Duration, Instant};
+
+use capydeploy_protocol::messages::{SelfTestCheck, SelfTestResponse};
+use capydeploy_steam::{Paths, get_users_with_paths, load_shortcuts_vdf};
+
+/// Check name for Steam installation / userdata discovery.
+pub const CHECK_STEAM_PATHS: &str = "steam_paths";
+/// Check name for parsing every user's `shortcuts.vdf`.
+pub const CHECK_SHORTCUTS: &str = "shortcuts";
+/// Check name for writing to the install directory.
+pub const CHECK_INSTALL_PATH: &str = "install_path";
+/// Check name for reaching Steam's CEF debugger.
+pub const CHECK_CEF: &str = "cef";
+
+/// Upper bound for the CEF probe; a hung debugger must not stall the report.
+pub const CEF_PROBE_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Name of the temporary file written by the install path probe.
+const PROBE_FILE: &str = ".capydeploy-selftest";
+
+/// Runs all self-test probes and returns the combined report.
+///
+/// `steam` is `None` when the Steam installation could not be located.
+/// `cef_probe` performs a round trip to the CEF debugger; it is bounded
+/// by [`CEF_PROBE_TIMEOUT`].
+pub async fn run_self_test<F, Fut>(
+    steam: Option<&Paths>,
+    install_path: &Path,
+    cef_probe: F,
+) -> SelfTestResponse
+where
+    F: FnOnce() -> Fut,
+    Fut: Future<Output = Result<(), String>>,
+{
+    let mut checks = vec![
+        timed(CHECK_STEAM_PATHS, || probe_steam_paths(steam)),
+        timed(CHECK_SHORTCUTS, || probe_shortcuts(steam)),
+        timed(CHECK_INSTALL_PATH, || probe_install_path(install_path)),
+    ];
+
+    let start = Instant::now();
+    let cef = match tokio::time::timeout(CEF_PROBE_TIMEOUT, cef_probe()).await {
+        Ok(res) => res.map(|()| String::new()),
+        Err(_) => Err(format!(
+            "no response within {}s",
+            CEF_PROBE_TIMEOUT.as_secs()
+        )),
+    };
+    checks.push(to_check(CHECK_CEF, cef, start));
+
+    SelfTestResponse {
+        passed: checks.iter().all(|c| c.passed),
+        checks,
+    }
+}
+
+fn timed(name: &str, probe: impl FnOnce() -> Result<String, String>) -> SelfTestCheck {
+    let start = Instant::now();
+    to_check(name, probe(), start)
+}
+
+fn to_check(name: &str, res: Result<String, String>, start: Instant) -> SelfTestCheck {
+    let (passed, detail) = match res {
+        Ok(detail) => (true, detail),
+        Err(detail) => (false, detail),
+    };
+    SelfTestCheck {
+        name: name.into(),
+        passed,
+        detail,
+        duration_ms: start.elapsed().as_millis() as i64,
+    }
+}
+
+fn probe_steam_paths(steam: Option<&Paths>) -> Result<String, String> {
+    let paths = steam.ok_or("steam installation not found")?;
+    let users = get_users_with_paths(paths).map_err(|e| e.to_string())?;
+    if users.is_empty() {
+        return Err(format!(
+            "no steam users in {}",
+            paths.user_data_dir().display()
+        ));
+    }
+    Ok(format!("{} user(s)", users.len()))
+}
+
+fn probe_shortcuts(steam: Option<&Paths>) -> Result<String, String> {
+    let paths = steam.ok_or("skipped: steam installation not found")?;
+    let users = get_users_with_paths(paths).map_err(|e| format!("skipped: {e}"))?;
+
+    let mut total = 0;
+    for user in users.iter().filter(|u| u.has_shortcuts) {
+        let shortcuts = load_shortcuts_vdf(&paths.shortcuts_path(&user.id))
+            .map_err(|e| format!("user {}: {e}", user.id))?;
+        total += shortcuts.len();
+    }
+    Ok(format!("{total} shortcut(s)"))
+}
+
+/// Checks that files could be written under `path` without creating it:
+/// a probe file is written to its nearest existing ancestor and removed.
+pub fn probe_writable(path: &Path) -> Result<(), String> {
+    let existing = path
+        .ancestors()
+        .find(|a| a.is_dir())
+        .ok_or_else(|| format!("no existing ancestor for {}", path.display()))?;
+    let probe = existing.join(PROBE_FILE);
+    std::fs::write(&probe, b"ok")
+        .map_err(|e| format!("cannot write to {}: {e}", existing.display()))?;
+    let _ = std::fs::remove_file(&probe);
+    Ok(())
+}
+
+pub(crate) fn probe_install_path(install_path: &Path) -> Result<String, String> {
+    std::fs::create_dir_all(install_path)
+        .map_err(|e| format!("cannot create {}: {e}", install_path.display()))?;
+
+    let probe = install_path.join(PROBE_FILE);
+    std::fs::write(&probe, b"ok")
+        .map_err(|e| format!("cannot write to {}: {e}", install_path.display()))?;
+    let _ = std::fs::remove_file(&probe);
+
+    Ok(install_path.display().to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    /// Builds a fake Steam root with one user and the given shortcuts.vdf bytes.
+    fn fake_steam(root: &Path, shortcuts: Option<&[u8]>) -> Paths {
+        let config = root.join("userdata").join("12345").join("config");
+        std::fs::create_dir_all(&config).unwrap();
+        if let Some(data) = shortcuts {
+            std::fs::write(config.join("shortcuts.vdf"), data).unwrap();
+        }
+        Paths::with_base(root)
+    }
+
+    /// Minimal valid shortcuts.vdf with no entries.
+    const EMPTY_VDF: &[u8] = b"\x00shortcuts\x00\x08\x08";
+
+    fn check<'a>(report: &'a SelfTestResponse, name: &str) -> &'a SelfTestCheck {
+        report.checks.iter().find(|c| c.name == name).unwrap()
+    }
+
+    async fn cef_ok() -> Result<(), String> {
+        Ok(())
+    }
+
+    #[tokio::test]
+    async fn all_subsystems_healthy() {
+        let tmp = tempfile::tempdir().unwrap();
+        let steam = fake_steam(&tmp.path().join("steam"), Some(EMPTY_VDF));
+        let install = tmp.path().join("Games");
+
+        let report = run_self_test(Some(&steam), &install, cef_ok).await;
+
+        assert!(report.passed, "{report:?}");
+        assert_eq!(report.checks.len(), 4);
+        assert_eq!(check(&report, CHECK_STEAM_PATHS).detail, "1 user(s)");
+        assert_eq!(check(&report, CHECK_SHORTCUTS).detail, "0 shortcut(s)");
+        assert!(install.is_dir());
+        assert!(!install.join(PROBE_FILE).exists());
+    }
+
+    #[test]
+    fn probe_writable_leaves_target_alone() {
+        let tmp = tempfile::tempdir().unwrap();
+        let target = tmp.path().join("Games").join("New");
+        probe_writable(&target).unwrap();
+        assert!(!tmp.path().join("Games").exists());
+        assert!(!tmp.path().join(PROBE_FILE).exists());
+    }
+
+    #[tokio::test]
+    async fn missing_steam_fails_steam_checks_only() {
+        let tmp = tempfile::tempdir().unwrap();
+        let report = run_self_test(None, tmp.path(), cef_ok).await;
+
+        assert!(!report.passed);
+        assert!(!check(&report, CHECK_STEAM_PATHS).passed);
+        let shortcuts = check(&report, CHECK_SHORTCUTS);
+        assert!(!shortcuts.passed);
+        assert!(shortcuts.detail.starts_with("skipped"));
+        assert!(check(&report, CHECK_INSTALL_PATH).passed);
+        assert!(check(&report, CHECK_CEF).passed);
+    }
+
+    #[tokio::test]
+    async fn corrupt_shortcuts_fail_shortcut_check() {
+        let tmp = tempfile::tempdir().unwrap();
+        let steam = fake_steam(&tmp.path().join("steam"), Some(b"\x02garbage"));
+
+        let report = run_self_test(Some(&steam), tmp.path(), cef_ok).await;
+
+        assert!(!report.passed);
+        assert!(check(&report, CHECK_STEAM_PATHS).passed);
+        let shortcuts = check(&report, CHECK_SHORTCUTS);
+        assert!(!shortcuts.passed);
+        assert!(
+            shortcuts.detail.contains("user 12345"),
+            "{}",
+            shortcuts.detail
+        );
+    }
+
+    #[tokio::test]
+    async fn unwritable_install_path_fails() {
+        let tmp = tempfile::tempdir().unwrap();
+        let steam = fake_steam(&tmp.path().join("steam"), None);
+        // A regular file where the install directory should be.
+        let blocker: PathBuf = tmp.path().join("Games");
+        std::fs::write(&blocker, b"not a dir").unwrap();
+
+        let report = run_self_test(Some(&steam), &blocker, cef_ok).await;
+
+        assert!(!report.passed);
+        assert!(!check(&report, CHECK_INSTALL_PATH).passed);
+        assert!(check(&report, CHECK_STEAM_PATHS).passed);
+        assert!(check(&report, CHECK_SHORTCUTS).passed);
+    }
+
+    #[tokio::test]
+    async fn cef_failure_is_reported() {
+        let tmp = tempfile::tempdir().unwrap();
+        let steam = fake_steam(&tmp.path().join("steam"), None);
+
+        let report = run_self_test(Some(&steam), tmp.path(), || async {
+            Err("connection refused".to_string())
+        })
+        .await;
+
+        assert!(!report.passed);
+        let cef = check(&report, CHECK_CEF);
+        assert!(!cef.passed);
+        assert_eq!(cef.detail, "connection refused");
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn hung_cef_times_out() {
+        let tmp = tempfile::tempdir().unwrap();
+        let steam = fake_steam(&tmp.path().join("steam"), None);
+
+        let report = run_self_test(Some(&steam), tmp.path(), || async {
+            std::future::pending::<Result<(), String>>().await
+        })
+        .await;
+
+        let cef = check(&report, CHECK_CEF);
+        assert!(!cef.passed);
+        assert!(cef.detail.contains("no response"));
+    }
+}
@@ -0,0 +1,155 @@
+//! Clock skew between the Hub and an Agent.
+//!
+//! The Agent stamps its `agent_status` reply with its wall clock. The Hub
+//! compares that against the midpoint of the handshake, so network delay
+//! only adds uncertainty (half the round trip) rather than false skew.
+
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+
+use tokio::sync::mpsc;
+use tracing::{debug, warn};
+
+use crate::types::ConnectionEvent;
+
+/// Skew beyond which the Hub warns that the Agent's clock is wrong.
+pub const CLOCK_SKEW_WARN: Duration = Duration::from_secs(120);
+
+/// Measured offset of an Agent's clock from the Hub's.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct ClockSkew {
+    /// Agent clock minus Hub clock, in milliseconds (positive = ahead).
+    pub offset_ms: i64,
+    /// Half the handshake round trip; the true offset is within
+    /// `offset_ms ± uncertainty_ms`.
+    pub uncertainty_ms: i64,
+}
+
+impl ClockSkew {
+    /// Estimates the skew from the Agent's timestamp and the Hub times
+    /// (Unix ms) when the handshake was sent and the reply received.
+    /// Returns `None` if the Agent didn't report its time.
+    pub fn measure(agent_time_ms: i64, sent_ms: i64, received_ms: i64) -> Option<Self> {
+        if agent_time_ms <= 0 {
+            return None;
+        }
+        let rtt = (received_ms - sent_ms).max(0);
+        let midpoint = sent_ms + rtt / 2;
+        Some(Self {
+            offset_ms: agent_time_ms - midpoint,
+            uncertainty_ms: rtt / 2,
+        })
+    }
+
+    /// Whether the skew exceeds `threshold` even at the most favourable
+    /// end of the uncertainty range.
+    pub fn exceeds(&self, threshold: Duration) -> bool {
+        let certain = self.offset_ms.abs() - self.uncertainty_ms;
+        certain > threshold.as_millis() as i64
+    }
+
+    /// A user-facing description, e.g. "agent clock is 5m 3s ahead of this
+    /// computer".
+    pub fn describe(&self) -> String {
+        let secs = self.offset_ms.unsigned_abs() / 1000;
+        let amount = match (secs / 3600, secs / 60 % 60, secs % 60) {
+            (0, 0, s) => format!("{s}s"),
+            (0, m, s) => format!("{m}m {s}s"),
+            (h, m, _) => format!("{h}h {m}m"),
+        };
+        let direction = if self.offset_ms > 0 {
+            "ahead of"
+        } else {
+            "behind"
+        };
+        format!("agent clock is {amount} {direction} this computer")
+    }
+}
+
+/// Measures the skew after a handshake and, if it's significant, logs a
+/// warning and emits [`ConnectionEvent::ClockSkew`].
+pub(crate) async fn report_skew(
+    events_tx: &mpsc::Sender<ConnectionEvent>,
+    agent_id: &str,
+    agent_time_ms: i64,
+    sent_ms: i64,
+    received_ms: i64,
+) {
+    let Some(skew) = ClockSkew::measure(agent_time_ms, sent_ms, received_ms) else {
+        return;
+    };
+    if !skew.exceeds(CLOCK_SKEW_WARN) {
+        debug!(agent = %agent_id, offset_ms = skew.offset_ms, "agent clock in sync");
+        return;
+    }
+    let message = skew.describe();
+    warn!(agent = %agent_id, offset_ms = skew.offset_ms, "{message}; fix the device clock");
+    let _ = events_tx
+        .send(ConnectionEvent::ClockSkew {
+            agent_id: agent_id.to_string(),
+            offset_ms: skew.offset_ms,
+            message,
+        })
+        .await;
+}
+
+/// Current wall clock in Unix milliseconds.
+pub(crate) fn unix_millis() -> i64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_millis() as i64)
+        .unwrap_or_default()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const T: i64 = 1_700_000_000_000;
+
+    #[test]
+    fn measures_offset_from_handshake_midpoint() {
+        // Sent at T, replied 200 ms later; agent stamped T+100 (in sync).
+        let skew = ClockSkew::measure(T + 100, T, T + 200).unwrap();
+        assert_eq!(skew.offset_ms, 0);
+        assert_eq!(skew.uncertainty_ms, 100);
+
+        let behind = ClockSkew::measure(T - 3_600_000, T, T).unwrap();
+        assert_eq!(behind.offset_ms, -3_600_000);
+
+        assert!(ClockSkew::measure(0, T, T).is_none());
+    }
+
+    #[test]
+    fn warning_threshold() {
+        let at = |offset_ms, uncertainty_ms| ClockSkew {
+            offset_ms,
+            uncertainty_ms,
+        };
+        assert!(!at(0, 0).exceeds(CLOCK_SKEW_WARN));
+        assert!(!at(120_000, 0).exceeds(CLOCK_SKEW_WARN));
+        assert!(at(120_001, 0).exceeds(CLOCK_SKEW_WARN));
+        assert!(at(-300_000, 0).exceeds(CLOCK_SKEW_WARN));
+        // A slow handshake can't prove the skew.
+        assert!(!at(125_000, 10_000).exceeds(CLOCK_SKEW_WARN));
+    }
+
+    #[test]
+    fn describes_direction_and_size() {
+        let skew = |offset_ms| ClockSkew {
+            offset_ms,
+            uncertainty_ms: 0,
+        };
+        assert_eq!(
+            skew(303_000).describe(),
+            "agent clock is 5m 3s ahead of this computer"
+        );
+        assert_eq!(
+            skew(-7_380_000).describe(),
+            "agent clock is 2h 3m behind this computer"
+        );
+        assert_eq!(
+            skew(-45_000).describe(),
+            "agent clock is 45s behind this computer"
+        );
+    }
+}
@@ -1,29 +1,37 @@
 //! mDNS discovery methods for [`ConnectionManager`].
 
+use std::collections::HashMap;
 use std::time::Duration;
 
-use tracing::warn;
+use tokio::sync::RwLock;
+use tracing::{info, warn};
 
 use capydeploy_discovery::client::Client as DiscoveryClient;
-use capydeploy_discovery::types::EventType;
+use capydeploy_discovery::types::{DiscoveredAgent, EventType, host_port};
 
 use crate::manager::ConnectionManager;
-use crate::reconnection::cancel_reconnect_for;
+use crate::reconnection::{ReconnectTokens, cancel_reconnect_for, handle_agent_moved};
 use crate::types::{ConnectionEvent, ConnectionState};
+use crate::ws_client::{WsClient, WsError};
 
 impl ConnectionManager {
     /// Starts continuous mDNS discovery in the background.
     pub async fn start_discovery(&self, interval: Duration) {
         let cancel_rx = self.cancel_rx.clone();
+        let paused_rx = self.discovery_paused.subscribe();
 
         // Take events before spawning.
-        let events_rx = self.discovery.lock().await.take_events();
+        let (events_rx, mut network_rx) = {
+            let mut disc = self.discovery.lock().await;
+            (disc.take_events(), disc.take_network_events())
+        };
 
         // Start the mDNS browsing loop.
         let discovery = self.discovery.clone();
         tokio::spawn(async move {
             let disc = discovery.lock().await;
-            disc.start_continuous_discovery(cancel_rx, interval).await;
+            disc.start_continuous_discovery(cancel_rx, paused_rx, interval)
+                .await;
         });
 
         // Start the event forwarding loop.
@@ -33,39 +41,62 @@ impl ConnectionManager {
             let events_tx = self.events_tx.clone();
             let cancel_rx = self.cancel_rx.clone();
             let reconnect_cancel = self.reconnect_cancel.clone();
+            let paused = self.discovery_paused.subscribe();
+            let ctx = self.ws_context();
 
             tokio::spawn(async move {
                 let mut cancel = cancel_rx;
                 loop {
                     tokio::select! {
                         _ = cancel.changed() => break,
+                        Some(change) = async {
+                            match network_rx.as_mut() {
+                                Some(rx) => rx.recv().await,
+                                None => std::future::pending().await,
+                            }
+                        } => {
+                            // Agents found on the old network are unreachable now;
+                            // drop them all rather than waiting for their TTL.
+                            // Manually added ones stay until the user removes them.
+                            let stale: Vec<String> = discovered
+                                .read()
+                                .await
+                                .values()
+                                .filter(|a| !a.manual)
+                                .map(|a| a.info.id.clone())
+                                .collect();
+                            for id in stale {
+                                forget_agent(&discovered, &state, &reconnect_cancel, &id).await;
+                                let _ = events_tx.send(ConnectionEvent::AgentLost(id)).await;
+                            }
+                            info!(previous = ?change.previous, current = ?change.current, "local network changed, discovery reset");
+                            let _ = events_tx.send(ConnectionEvent::NetworkChanged {
+                                addresses: change.current,
+                            }).await;
+                        }
                         event = rx.recv() => {
                             match event {
+                                // Late results from a daemon that was just paused.
+                                Some(e) if *paused.borrow() && e.event_type != EventType::Lost => {}
                                 Some(e) => {
                                     match e.event_type {
                                         EventType::Discovered => {
                                             let id = e.agent.info.id.clone();
                                             discovered.write().await.insert(id.clone(), e.agent.clone());
+                                            // A reconnecting agent found again after being lost.
+                                            handle_agent_moved(&ctx, &e.agent).await;
                                             state.write().await.insert(id, ConnectionState::Discovered);
                                             let _ = events_tx.send(ConnectionEvent::AgentFound(e.agent)).await;
                                         }
                                         EventType::Updated => {
                                             let id = e.agent.info.id.clone();
                                             discovered.write().await.insert(id, e.agent.clone());
+                                            handle_agent_moved(&ctx, &e.agent).await;
                                             let _ = events_tx.send(ConnectionEvent::AgentUpdated(e.agent)).await;
                                         }
                                         EventType::Lost => {
                                             let id = e.agent.info.id.clone();
-                                            discovered.write().await.remove(&id);
-                                            // Don't kill reconnect or remove state while reconnecting —
-                                            // the reconnect loop uses last_known_address as fallback.
-                                            let is_reconnecting = state.read().await
-                                                .get(&id)
-                                                .is_some_and(|s| matches!(s, ConnectionState::Reconnecting { .. }));
-                                            if !is_reconnecting {
-                                                state.write().await.remove(&id);
-                                                cancel_reconnect_for(&reconnect_cancel, &id);
-                                            }
+                                            forget_agent(&discovered, &state, &reconnect_cancel, &id).await;
                                             let _ = events_tx.send(ConnectionEvent::AgentLost(id)).await;
                                         }
                                     }
@@ -85,6 +116,9 @@ impl ConnectionManager {
     /// seconds, and merges the results into the tracked set. Any new or
     /// updated agents are emitted through the existing events channel.
     pub async fn refresh_discovery(&self) {
+        if self.is_discovery_paused() {
+            return;
+        }
         let client = DiscoveryClient::new();
         match client.discover(Duration::from_secs(3)).await {
             Ok(agents) => {
@@ -118,4 +152,128 @@ impl ConnectionManager {
             }
         }
     }
+
+    /// Adds an Agent by address, for networks where mDNS is blocked.
+    ///
+    /// The Agent is asked for its info over WebSocket without a handshake,
+    /// then listed like a discovered one so [`connect_agent`](Self::connect_agent)
+    /// works as usual. IPv6 literals may be bracketed.
+    pub async fn add_manual_agent(
+        &self,
+        host: &str,
+        port: u16,
+    ) -> Result<DiscoveredAgent, WsError> {
+        let host = host.trim().trim_start_matches('[').trim_end_matches(']');
+        let url = format!("ws://{}/ws", host_port(host, port));
+        let info = WsClient::probe_info(&url).await?;
+        info!(agent = %info.id, %url, "manually added agent");
+
+        let agent = DiscoveredAgent::manual(info, host, port);
+        self.insert_manual_agent(agent.clone()).await;
+        Ok(agent)
+    }
+
+    /// Lists a manually added Agent without probing it, e.g. one restored
+    /// from settings that is offline right now.
+    pub async fn insert_manual_agent(&self, agent: DiscoveredAgent) {
+        let id = agent.info.id.clone();
+        let is_new = self
+            .discovered
+            .write()
+            .await
+            .insert(id.clone(), agent.clone())
+            .is_none();
+
+        let event = if is_new {
+            self.state
+                .write()
+                .await
+                .insert(id, ConnectionState::Discovered);
+            ConnectionEvent::AgentFound(agent)
+        } else {
+            ConnectionEvent::AgentUpdated(agent)
+        };
+        let _ = self.events_tx.send(event).await;
+    }
+
+    /// Removes a manually added Agent from the list. Returns false if
+    /// `agent_id` isn't a manual Agent.
+    pub async fn remove_manual_agent(&self, agent_id: &str) -> bool {
+        let manual = self
+            .discovered
+            .read()
+            .await
+            .get(agent_id)
+            .is_some_and(|a| a.manual);
+        if manual {
+            forget_agent(
+                &self.discovered,
+                &self.state,
+                &self.reconnect_cancel,
+                agent_id,
+            )
+            .await;
+            let _ = self
+                .events_tx
+                .send(ConnectionEvent::AgentLost(agent_id.to_string()))
+                .await;
+        }
+        manual
+    }
+
+    /// Stops all mDNS traffic until [`resume_discovery`](Self::resume_discovery).
+    ///
+    /// Agents already discovered stay listed and an active connection is
+    /// left alone. Returns false if discovery was already paused.
+    pub fn pause_discovery(&self) -> bool {
+        self.set_discovery_paused(true)
+    }
+
+    /// Restarts mDNS browsing after [`pause_discovery`](Self::pause_discovery).
+    /// Returns false if discovery wasn't paused.
+    pub fn resume_discovery(&self) -> bool {
+        self.set_discovery_paused(false)
+    }
+
+    /// Whether discovery is currently paused.
+    pub fn is_discovery_paused(&self) -> bool {
+        *self.discovery_paused.borrow()
+    }
+
+    fn set_discovery_paused(&self, paused: bool) -> bool {
+        let changed = self.discovery_paused.send_if_modified(|p| {
+            let changed = *p != paused;
+            *p = paused;
+            changed
+        });
+        if changed {
+            info!(paused, "discovery state changed");
+            let _ = self
+                .events_tx
+                .try_send(ConnectionEvent::DiscoveryPaused(paused));
+        }
+        changed
+    }
+}
+
+/// Removes a lost agent from the discovered set.
+///
+/// Don't kill reconnect or remove state while reconnecting — the reconnect
+/// loop uses last_known_address as fallback.
+async fn forget_agent(
+    discovered: &RwLock<HashMap<String, DiscoveredAgent>>,
+    state: &RwLock<HashMap<String, ConnectionState>>,
+    reconnect_cancel: &ReconnectTokens,
+    id: &str,
+) {
+    discovered.write().await.remove(id);
+    let is_reconnecting = state
+        .read()
+        .await
+        .get(id)
+        .is_some_and(|s| matches!(s, ConnectionState::Reconnecting { .. }));
+    if !is_reconnecting {
+        state.write().await.remove(id);
+        cancel_reconnect_for(reconnect_cancel, id);
+    }
 }
@@ -3,16 +3,22 @@
 //! Provides WebSocket client, mDNS discovery integration,
 //! and Hub-Agent pairing flow.
 
+pub mod clock;
 mod discovery;
 pub mod manager;
 pub mod pairing;
 pub(crate) mod pairing_flow;
+pub mod provision;
 pub(crate) mod pumps;
 pub(crate) mod reconnection;
 pub mod types;
 pub mod ws_client;
 
+pub use clock::{CLOCK_SKEW_WARN, ClockSkew};
 pub use manager::ConnectionManager;
 pub use pairing::TokenStore;
-pub use types::{ConnectedAgent, ConnectionEvent, ConnectionState, HubIdentity, ReconnectConfig};
+pub use provision::{ProvisionOptions, ProvisionSummary, ProvisionTarget, provision};
+pub use types::{
+    ConnectedAgent, ConnectionEvent, ConnectionState, HeartbeatConfig, HubIdentity, ReconnectConfig,
+};
 pub use ws_client::{HandshakeResult, WsClient, WsError};
@@ -3,13 +3,15 @@
 //! Auto-discovers Agents via mDNS, manages WebSocket client lifecycles,
 //! tracks connection state, and reconnects automatically with exponential
 //! backoff on unexpected disconnects.
+//!
+//! Several Agents can be connected at once. One of them is the *active*
+//! Agent, which the single-agent calls such as [`ConnectionManager::get_info`]
+//! talk to; the `*_to` variants address any connected Agent by ID.
 
 use std::collections::HashMap;
 use std::sync::Arc;
-use std::sync::atomic::{AtomicBool, Ordering};
 
 use tokio::sync::{Mutex, RwLock, mpsc, watch};
-use tokio_util::sync::CancellationToken;
 use tracing::{debug, info, warn};
 
 use capydeploy_discovery::client::Client as DiscoveryClient;
@@ -18,12 +20,24 @@ use capydeploy_protocol::constants::{
     self, MessageType, PROTOCOL_VERSION, check_protocol_compatibility,
 };
 use capydeploy_protocol::envelope::Message;
-use capydeploy_protocol::messages::{HubConnectedRequest, InfoResponse};
+use capydeploy_protocol::messages::{
+    BrowseDirectoryRequest, BrowseDirectoryResponse, CanDeployRequest, CanDeployResponse,
+    CefStatusResponse, ConfigResponse, ConnectedHubEntry, ConnectedHubsResponse, DiskUsage,
+    GetDiskUsageRequest, GetUploadStatusRequest, HubConnectedRequest, InfoLiteResponse,
+    InfoResponse, PruneUploadsRequest, PruneUploadsResponse, RestartSteamResponse,
+    SelfTestResponse, SetInstallPathRequest, SteamLibrariesResponse, SteamStatusResponse,
+    UploadProgressEvent, UploadSessionInfo, UploadsResponse,
+};
+use capydeploy_protocol::profile::{ProtocolOffer, ProtocolProfile};
+use capydeploy_protocol::types::AgentInfoLite;
 
-use crate::pairing::TokenStore;
-use crate::reconnection::{WsContext, cancel_any_reconnect, setup_ws_callbacks};
+use crate::pairing::{PairingError, TokenStore};
+use crate::reconnection::{
+    ClientMap, ReconnectTokens, WsContext, cancel_any_reconnect, cancel_reconnect_for,
+    setup_ws_callbacks,
+};
 use crate::types::{
-    ConnectedAgent, ConnectionEvent, ConnectionState, HubIdentity, ReconnectConfig,
+    ConnectedAgent, ConnectionEvent, ConnectionState, HeartbeatConfig, HubIdentity, ReconnectConfig,
 };
 use crate::ws_client::{HandshakeResult, WsClient, WsError};
 
@@ -33,8 +47,13 @@ pub struct ConnectionManager {
     pub(crate) discovery: Arc<Mutex<DiscoveryClient>>,
     pub(crate) token_store: Option<Arc<TokenStore>>,
     pub(crate) discovered: Arc<RwLock<HashMap<String, DiscoveredAgent>>>,
-    pub(crate) connected: Arc<RwLock<Option<(String, ConnectedAgent)>>>,
-    pub(crate) ws_client: Arc<Mutex<Option<WsClient>>>,
+    /// Connected Agents, keyed by agent ID.
+    pub(crate) connected: Arc<RwLock<HashMap<String, ConnectedAgent>>>,
+    /// Clients of the connected (or pairing) Agents. Shared so requests can
+    /// run concurrently without holding the lock.
+    pub(crate) ws_clients: Arc<ClientMap>,
+    /// Agent the single-agent calls talk to.
+    pub(crate) active: Arc<RwLock<Option<String>>>,
     /// Agent ID for a connection in pairing state.
     pub(crate) pairing_agent_id: Arc<Mutex<Option<String>>>,
     pub(crate) events_tx: mpsc::Sender<ConnectionEvent>,
@@ -42,14 +61,17 @@ pub struct ConnectionManager {
     pub(crate) cancel_tx: watch::Sender<bool>,
     pub(crate) cancel_rx: watch::Receiver<bool>,
     pub(crate) state: Arc<RwLock<HashMap<String, ConnectionState>>>,
-    /// Cancel token for the active reconnect loop, keyed by agent ID.
-    pub(crate) reconnect_cancel: Arc<std::sync::Mutex<Option<(String, CancellationToken)>>>,
-    /// Set to true when the user explicitly disconnects.
-    pub(crate) manual_disconnect: Arc<AtomicBool>,
+    /// Cancel tokens for the running reconnect loops, keyed by agent ID.
+    pub(crate) reconnect_cancel: Arc<ReconnectTokens>,
     /// Reconnection backoff configuration.
     pub(crate) reconnect_config: ReconnectConfig,
-    /// Last successfully connected WebSocket URL for reconnect fallback.
-    pub(crate) last_known_addr: Arc<Mutex<Option<(String, DiscoveredAgent)>>>,
+    /// Ping timings for Agent connections.
+    pub(crate) heartbeat: HeartbeatConfig,
+    /// Last successfully connected WebSocket URL per agent, for reconnect
+    /// fallback.
+    pub(crate) last_known_addr: Arc<Mutex<HashMap<String, (String, DiscoveredAgent)>>>,
+    /// True while mDNS discovery is paused by the user.
+    pub(crate) discovery_paused: watch::Sender<bool>,
 }
 
 impl ConnectionManager {
@@ -63,21 +85,30 @@ impl ConnectionManager {
             discovery: Arc::new(Mutex::new(DiscoveryClient::new())),
             token_store,
             discovered: Arc::new(RwLock::new(HashMap::new())),
-            connected: Arc::new(RwLock::new(None)),
-            ws_client: Arc::new(Mutex::new(None)),
+            connected: Arc::new(RwLock::new(HashMap::new())),
+            ws_clients: Arc::new(ClientMap::default()),
+            active: Arc::new(RwLock::new(None)),
             pairing_agent_id: Arc::new(Mutex::new(None)),
             events_tx,
             events_rx: Mutex::new(Some(events_rx)),
             cancel_tx,
             cancel_rx,
             state: Arc::new(RwLock::new(HashMap::new())),
-            reconnect_cancel: Arc::new(std::sync::Mutex::new(None)),
-            manual_disconnect: Arc::new(AtomicBool::new(false)),
+            reconnect_cancel: Arc::new(ReconnectTokens::default()),
             reconnect_config: ReconnectConfig::default(),
-            last_known_addr: Arc::new(Mutex::new(None)),
+            heartbeat: HeartbeatConfig::default(),
+            last_known_addr: Arc::new(Mutex::new(HashMap::new())),
+            discovery_paused: watch::channel(false).0,
         }
     }
 
+    /// Replaces the keepalive and send-queue settings used for new
+    /// connections.
+    pub fn with_heartbeat(mut self, heartbeat: HeartbeatConfig) -> Self {
+        self.heartbeat = heartbeat;
+        self
+    }
+
     /// Takes the event receiver. Can only be called once.
     pub async fn take_events(&self) -> Option<mpsc::Receiver<ConnectionEvent>> {
         self.events_rx.lock().await.take()
@@ -88,9 +119,29 @@ impl ConnectionManager {
         self.discovered.read().await.values().cloned().collect()
     }
 
-    /// Returns the currently connected Agent, if any.
+    /// Returns the active Agent, if it is connected.
     pub async fn get_connected(&self) -> Option<ConnectedAgent> {
-        self.connected.read().await.as_ref().map(|(_, a)| a.clone())
+        let active = self.active.read().await.clone()?;
+        self.get_connected_agent(&active).await
+    }
+
+    /// Returns a connected Agent by ID.
+    pub async fn get_connected_agent(&self, agent_id: &str) -> Option<ConnectedAgent> {
+        self.connected.read().await.get(agent_id).cloned()
+    }
+
+    /// Returns every connected Agent, the active one first.
+    pub async fn get_connected_agents(&self) -> Vec<ConnectedAgent> {
+        let active = self.active.read().await.clone();
+        let mut agents: Vec<ConnectedAgent> =
+            self.connected.read().await.values().cloned().collect();
+        agents.sort_by_key(|a| {
+            (
+                Some(&a.agent.info.id) != active.as_ref(),
+                a.agent.info.name.clone(),
+            )
+        });
+        agents
     }
 
     /// Returns the connection state for an Agent.
@@ -98,16 +149,103 @@ impl ConnectionManager {
         self.state.read().await.get(agent_id).cloned()
     }
 
-    /// Connects to an Agent by ID.
+    /// Returns the Agent the Hub is connected to or trying to reach, with
+    /// its state, preferring the active Agent. An Agent whose connection
+    /// was lost is only returned when no other is active.
+    pub async fn active_state(&self) -> Option<(String, ConnectionState)> {
+        let active = self.active.read().await.clone();
+        self.state
+            .read()
+            .await
+            .iter()
+            .filter(|(_, s)| {
+                !matches!(
+                    s,
+                    ConnectionState::Discovered | ConnectionState::Disconnected
+                )
+            })
+            .min_by_key(|(id, s)| {
+                (
+                    matches!(s, ConnectionState::Lost),
+                    Some(*id) != active.as_ref(),
+                )
+            })
+            .map(|(id, s)| (id.clone(), s.clone()))
+    }
+
+    /// Connects to an Agent by ID and makes it the active Agent.
+    ///
+    /// The previously active Agent is disconnected; Agents connected with
+    /// [`connect_additional_agent`](Self::connect_additional_agent) stay
+    /// connected, and switching to one of them reuses its connection.
     ///
     /// If the Agent requires pairing, `ConnectionEvent::PairingNeeded` is
     /// emitted and the WsClient is kept alive for [`confirm_pairing`](Self::confirm_pairing).
     pub async fn connect_agent(&self, agent_id: &str) -> Result<ConnectedAgent, WsError> {
-        // Cancel any active reconnect and reset manual disconnect flag.
-        cancel_any_reconnect(&self.reconnect_cancel);
-        self.manual_disconnect.store(false, Ordering::Relaxed);
+        cancel_reconnect_for(&self.reconnect_cancel, agent_id);
 
         // Find the discovered agent.
+        if !self.discovered.read().await.contains_key(agent_id) {
+            return Err(WsError::Closed);
+        }
+
+        let previous = self.active.read().await.clone();
+        if previous.as_deref() != Some(agent_id)
+            && let Some(existing) = self.get_connected_agent(agent_id).await
+        {
+            if let Some(previous) = previous {
+                self.close_agent(&previous).await;
+            }
+            self.abandon_pairing().await;
+            *self.active.write().await = Some(agent_id.to_string());
+            info!(agent = %agent_id, "switched active agent");
+            return Ok(existing);
+        }
+
+        // Drop the previous active connection and any abandoned pairing.
+        if let Some(previous) = previous {
+            self.close_agent(&previous).await;
+        }
+        self.abandon_pairing().await;
+
+        *self.active.write().await = Some(agent_id.to_string());
+        self.open_agent(agent_id, true).await
+    }
+
+    /// Connects to another Agent without changing the active one, so a
+    /// deploy can go to several Agents at once. It becomes the active Agent
+    /// only when there is none.
+    ///
+    /// The Agent must already be paired: one asking for pairing is
+    /// disconnected and `WsError::PairingFailed` returned.
+    pub async fn connect_additional_agent(
+        &self,
+        agent_id: &str,
+    ) -> Result<ConnectedAgent, WsError> {
+        if let Some(existing) = self.get_connected_agent(agent_id).await {
+            return Ok(existing);
+        }
+        cancel_reconnect_for(&self.reconnect_cancel, agent_id);
+        if !self.discovered.read().await.contains_key(agent_id) {
+            return Err(WsError::Closed);
+        }
+
+        let connected = self.open_agent(agent_id, false).await?;
+        self.active
+            .write()
+            .await
+            .get_or_insert_with(|| agent_id.to_string());
+        Ok(connected)
+    }
+
+    /// Opens a connection to a discovered Agent and registers it. With
+    /// `allow_pairing`, an Agent asking for pairing is kept as the pending
+    /// pairing; otherwise it is disconnected.
+    async fn open_agent(
+        &self,
+        agent_id: &str,
+        allow_pairing: bool,
+    ) -> Result<ConnectedAgent, WsError> {
         let agent = self
             .discovered
             .read()
@@ -116,9 +254,6 @@ impl ConnectionManager {
             .cloned()
             .ok_or(WsError::Closed)?;
 
-        // Disconnect existing connection (without setting manual flag).
-        self.disconnect_agent_inner(false).await;
-
         self.set_state(agent_id, ConnectionState::Connecting).await;
 
         let ws_url = agent.websocket_address();
@@ -135,16 +270,11 @@ impl ConnectionManager {
             .and_then(|s| s.get_token(agent_id))
             .unwrap_or_default();
 
-        let hub_req = HubConnectedRequest {
-            name: self.hub.name.clone(),
-            version: self.hub.version.clone(),
-            platform: self.hub.platform.clone(),
-            hub_id: self.hub.hub_id.clone(),
-            token,
-            protocol_version: PROTOCOL_VERSION,
-        };
+        let hub_req = self.hub.hello(token);
 
-        let (client, handshake) = match WsClient::connect(&ws_url, &hub_req).await {
+        let sent_at = crate::clock::unix_millis();
+        let (client, handshake) = match WsClient::connect(&ws_url, &hub_req, &self.heartbeat).await
+        {
             Ok(r) => r,
             Err(e) => {
                 warn!(agent = %agent_id, error = %e, "connection failed");
@@ -156,6 +286,15 @@ impl ConnectionManager {
 
         match handshake {
             HandshakeResult::Connected(status) => {
+                crate::clock::report_skew(
+                    &self.events_tx,
+                    agent_id,
+                    status.agent_time,
+                    sent_at,
+                    crate::clock::unix_millis(),
+                )
+                .await;
+
                 // Check protocol compatibility before accepting.
                 match check_protocol_compatibility(status.protocol_version) {
                     constants::ProtocolCompatibility::Incompatible {
@@ -170,10 +309,7 @@ impl ConnectionManager {
                         client.close().await;
                         self.set_state(agent_id, ConnectionState::Disconnected)
                             .await;
-                        return Err(WsError::AgentError {
-                            code: 406,
-                            message: reason,
-                        });
+                        return Err(crate::ws_client::incompatible_agent(peer_version));
                     }
                     constants::ProtocolCompatibility::Deprecated { peer_version } => {
                         let msg = format!(
@@ -192,25 +328,65 @@ impl ConnectionManager {
                     constants::ProtocolCompatibility::Compatible => {}
                 }
 
+                // Incompatible versions were rejected above.
+                let profile = ProtocolProfile::negotiate(
+                    &ProtocolOffer::from_hub(&hub_req),
+                    &ProtocolOffer::from_agent(&status),
+                )
+                .unwrap_or_default();
+                debug!(agent = %agent_id, ?profile, "negotiated protocol profile");
+                crate::pairing_flow::store_renewed_token(
+                    self.token_store.as_deref(),
+                    agent_id,
+                    &status,
+                );
+
+                client.set_profile(profile.clone());
+                let client = Arc::new(client);
                 self.setup_client_callbacks(&client, agent_id).await;
 
                 let connected_agent = ConnectedAgent {
                     agent: agent.clone(),
                     status,
+                    profile,
+                    config: None,
                 };
 
-                *self.ws_client.lock().await = Some(client);
-                *self.connected.write().await =
-                    Some((agent_id.to_string(), connected_agent.clone()));
-                *self.last_known_addr.lock().await = Some((ws_url, agent.clone()));
+                self.insert_client(agent_id, client);
+                self.connected
+                    .write()
+                    .await
+                    .insert(agent_id.to_string(), connected_agent.clone());
+                self.last_known_addr
+                    .lock()
+                    .await
+                    .insert(agent_id.to_string(), (ws_url, agent.clone()));
                 self.set_state(agent_id, ConnectionState::Connected).await;
 
                 info!("connected to agent {}", agent_id);
                 Ok(connected_agent)
             }
+            HandshakeResult::NeedsPairing(_) if !allow_pairing => {
+                client.close().await;
+                self.set_state(agent_id, ConnectionState::Disconnected)
+                    .await;
+                Err(WsError::PairingFailed(
+                    "agent requires pairing; connect to it first".into(),
+                ))
+            }
             HandshakeResult::NeedsPairing(pairing) => {
+                // The agent didn't accept the stored token (revoked, or an
+                // imported token that was never valid): drop it so the new
+                // pairing replaces it.
+                if !hub_req.token.is_empty()
+                    && let Some(store) = &self.token_store
+                {
+                    warn!(agent = %agent_id, "stored token rejected, falling back to pairing");
+                    let _ = store.remove_token(agent_id);
+                }
+
                 // Store the client — it stays alive for confirm_pairing.
-                *self.ws_client.lock().await = Some(client);
+                self.insert_client(agent_id, Arc::new(client));
                 *self.pairing_agent_id.lock().await = Some(agent_id.to_string());
                 self.set_state(agent_id, ConnectionState::PairingRequired)
                     .await;
@@ -232,6 +408,49 @@ impl ConnectionManager {
         }
     }
 
+    /// Forgets the stored token for `agent_id` and connects, so the agent
+    /// starts a fresh pairing (`ConnectionEvent::PairingNeeded`).
+    ///
+    /// Returns `true` when pairing is pending, `false` if the agent accepted
+    /// the connection without a token.
+    pub async fn repair_agent(&self, agent_id: &str) -> Result<bool, WsError> {
+        if let Some(store) = &self.token_store
+            && let Err(e) = store.remove_token(agent_id)
+        {
+            warn!(agent = %agent_id, error = %e, "failed to clear token for re-pairing");
+        }
+        info!(agent = %agent_id, "re-pairing agent");
+
+        match self.connect_agent(agent_id).await {
+            Ok(_) => Ok(false),
+            Err(WsError::PairingFailed(_))
+                if self.pairing_agent_id.lock().await.as_deref() == Some(agent_id) =>
+            {
+                Ok(true)
+            }
+            Err(e) => Err(e),
+        }
+    }
+
+    /// Stores a token for `agent_id` so the next connect authenticates
+    /// without pairing. Used to migrate a pairing from another Hub machine.
+    pub fn import_agent_token(&self, agent_id: &str, token: &str) -> Result<(), PairingError> {
+        let store = self.token_store.as_ref().ok_or(PairingError::NoStore)?;
+        store.import_token(agent_id, token)?;
+        info!(agent = %agent_id, "imported agent token");
+        Ok(())
+    }
+
+    /// Returns the stored token for `agent_id`, for transfer to another Hub.
+    pub fn export_agent_token(&self, agent_id: &str) -> Result<Option<String>, PairingError> {
+        let store = self.token_store.as_ref().ok_or(PairingError::NoStore)?;
+        let token = store.get_token(agent_id);
+        if token.is_some() {
+            info!(agent = %agent_id, "exported agent token");
+        }
+        Ok(token)
+    }
+
     /// Confirms a pairing code for an Agent that requires pairing.
     ///
     /// Must be called after receiving `ConnectionEvent::PairingNeeded`.
@@ -248,11 +467,8 @@ impl ConnectionManager {
         }
 
         // Use the existing client to confirm pairing.
-        let client_guard = self.ws_client.lock().await;
-        let client = client_guard.as_ref().ok_or(WsError::Closed)?;
-
+        let client = self.client_for(agent_id)?;
         let success = client.confirm_pairing(code).await?;
-        drop(client_guard);
 
         // Save token for future connections.
         if let Some(store) = &self.token_store {
@@ -263,7 +479,7 @@ impl ConnectionManager {
         *self.pairing_agent_id.lock().await = None;
 
         // Close the pairing connection and reconnect with the token.
-        if let Some(client) = self.ws_client.lock().await.take() {
+        if let Some(client) = self.remove_client(agent_id) {
             client.close().await;
         }
 
@@ -271,9 +487,29 @@ impl ConnectionManager {
         self.connect_agent(agent_id).await
     }
 
-    /// Disconnects from the current Agent (user-initiated).
+    /// Disconnects from the active Agent (user-initiated). Other connected
+    /// Agents stay connected.
     pub async fn disconnect_agent(&self) {
-        self.disconnect_agent_inner(true).await;
+        let active = self.active.write().await.take();
+        if let Some(id) = active {
+            self.close_agent(&id).await;
+        }
+        self.abandon_pairing().await;
+    }
+
+    /// Disconnects from one Agent by ID (user-initiated).
+    pub async fn disconnect_agent_by_id(&self, agent_id: &str) {
+        self.close_agent(agent_id).await;
+    }
+
+    /// Abandons a pending pairing, so a following
+    /// [`connect_agent`](Self::connect_agent) — to this or any other Agent —
+    /// starts clean.
+    pub async fn cancel_pairing(&self) {
+        if let Some(id) = self.pairing_agent_id.lock().await.as_deref() {
+            info!(agent = %id, "pairing cancelled");
+        }
+        self.abandon_pairing().await;
     }
 
     /// Cancels any active reconnect loop and sets the agent to Disconnected.
@@ -288,42 +524,145 @@ impl ConnectionManager {
         }
     }
 
-    /// Internal disconnect. When `set_manual` is true, sets the manual
-    /// disconnect flag so the disconnect callback won't trigger reconnect.
-    async fn disconnect_agent_inner(&self, set_manual: bool) {
-        if set_manual {
-            self.manual_disconnect.store(true, Ordering::Relaxed);
-            cancel_any_reconnect(&self.reconnect_cancel);
+    /// Closes the pending pairing connection, if any.
+    async fn abandon_pairing(&self) {
+        let pairing = self.pairing_agent_id.lock().await.clone();
+        if let Some(id) = pairing {
+            self.close_agent(&id).await;
         }
-        if let Some(client) = self.ws_client.lock().await.take() {
+    }
+
+    /// Closes one Agent's connection for good: no reconnect follows.
+    async fn close_agent(&self, agent_id: &str) {
+        cancel_reconnect_for(&self.reconnect_cancel, agent_id);
+        // Removing the client first tells its disconnect callback that the
+        // close was intended.
+        if let Some(client) = self.remove_client(agent_id) {
             client.close().await;
         }
-        *self.pairing_agent_id.lock().await = None;
-        if let Some((id, _)) = self.connected.write().await.take() {
-            self.set_state(&id, ConnectionState::Disconnected).await;
-            debug!("disconnected from agent {id}");
+        let was_pairing = {
+            let mut pairing = self.pairing_agent_id.lock().await;
+            let was = pairing.as_deref() == Some(agent_id);
+            if was {
+                *pairing = None;
+            }
+            was
+        };
+        {
+            let mut active = self.active.write().await;
+            if active.as_deref() == Some(agent_id) {
+                *active = None;
+            }
+        }
+        let was_connected = self.connected.write().await.remove(agent_id).is_some();
+        // An abandoned pairing would otherwise stay in PairingRequired.
+        if was_pairing || was_connected {
+            self.set_state(agent_id, ConnectionState::Disconnected)
+                .await;
+            debug!("disconnected from agent {agent_id}");
+        }
+    }
+
+    /// Closes every connection (shutdown).
+    async fn close_all(&self) {
+        cancel_any_reconnect(&self.reconnect_cancel);
+        let ids: Vec<String> = match self.ws_clients.lock() {
+            Ok(clients) => clients.keys().cloned().collect(),
+            Err(_) => Vec::new(),
+        };
+        for id in ids {
+            self.close_agent(&id).await;
+        }
+    }
+
+    fn insert_client(&self, agent_id: &str, client: Arc<WsClient>) {
+        if let Ok(mut clients) = self.ws_clients.lock() {
+            clients.insert(agent_id.to_string(), client);
         }
     }
 
-    /// Sends a request to the connected Agent.
+    fn remove_client(&self, agent_id: &str) -> Option<Arc<WsClient>> {
+        self.ws_clients.lock().ok()?.remove(agent_id)
+    }
+
+    /// The client of `agent_id`. Cloned out of the lock so concurrent
+    /// requests, such as pipelined upload chunks, don't wait on each other.
+    fn client_for(&self, agent_id: &str) -> Result<Arc<WsClient>, WsError> {
+        self.ws_clients
+            .lock()
+            .ok()
+            .and_then(|clients| clients.get(agent_id).cloned())
+            .ok_or(WsError::Closed)
+    }
+
+    /// The active Agent's client.
+    async fn client(&self) -> Result<Arc<WsClient>, WsError> {
+        let active = self.active.read().await.clone().ok_or(WsError::Closed)?;
+        self.client_for(&active)
+    }
+
+    /// Sends a request to the active Agent.
+    ///
+    /// An Agent that answers unauthorized has dropped this Hub's token, so
+    /// a new pairing is started (`ConnectionEvent::PairingNeeded`); the
+    /// caller still gets the error.
     pub async fn send_request<T: serde::Serialize>(
         &self,
         msg_type: MessageType,
         payload: Option<&T>,
     ) -> Result<Message, WsError> {
-        let client = self.ws_client.lock().await;
-        let client = client.as_ref().ok_or(WsError::Closed)?;
-        client.send_request(msg_type, payload).await
+        let client = self.client().await?;
+        let result = client.send_request(msg_type, payload).await;
+        if let Err(e) = &result
+            && e.is_unauthorized()
+        {
+            self.repair_after_unauthorized().await;
+        }
+        result
     }
 
-    /// Sends binary data with a JSON header to the connected Agent.
+    async fn repair_after_unauthorized(&self) {
+        let Some(agent_id) = self.active.read().await.clone() else {
+            return;
+        };
+        if self.pairing_agent_id.lock().await.is_some() {
+            return;
+        }
+        warn!(agent = %agent_id, "agent rejected this Hub, pairing again");
+        if let Err(e) = self.repair_agent(&agent_id).await {
+            warn!(agent = %agent_id, error = %e, "re-pairing failed");
+        }
+    }
+
+    /// Sends binary data with a JSON header to the active Agent.
     pub async fn send_binary(
         &self,
         header: &serde_json::Value,
         data: &[u8],
     ) -> Result<Message, WsError> {
-        let client = self.ws_client.lock().await;
-        let client = client.as_ref().ok_or(WsError::Closed)?;
+        let client = self.client().await?;
+        client.send_binary(header, data).await
+    }
+
+    /// Sends a request to a connected Agent by ID.
+    pub async fn send_request_to<T: serde::Serialize>(
+        &self,
+        agent_id: &str,
+        msg_type: MessageType,
+        payload: Option<&T>,
+    ) -> Result<Message, WsError> {
+        let client = self.client_for(agent_id)?;
+        client.send_request(msg_type, payload).await
+    }
+
+    /// Sends binary data with a JSON header to a connected Agent by ID.
+    pub async fn send_binary_to(
+        &self,
+        agent_id: &str,
+        header: &serde_json::Value,
+        data: &[u8],
+    ) -> Result<Message, WsError> {
+        let client = self.client_for(agent_id)?;
         client.send_binary(header, data).await
     }
 
@@ -339,11 +678,248 @@ impl ConnectionManager {
         Ok(info)
     }
 
+    /// Fetches the compact Agent info (identity and accept state), for
+    /// polling. Falls back to the full query on agents without
+    /// `get_info_lite`.
+    pub async fn get_info_lite(&self) -> Result<AgentInfoLite, WsError> {
+        match self
+            .send_request::<()>(MessageType::GetInfoLite, None)
+            .await
+        {
+            Ok(resp) => resp
+                .parse_payload::<InfoLiteResponse>()?
+                .map(|r| r.agent)
+                .ok_or_else(|| WsError::AgentError {
+                    code: 500,
+                    message: "empty info-lite response".into(),
+                }),
+            Err(e) if e.is_not_implemented() => {
+                Ok(AgentInfoLite::from(&self.get_info().await?.agent))
+            }
+            Err(e) => Err(e),
+        }
+    }
+
+    /// Asks the connected Agent to probe its subsystems and report back.
+    pub async fn self_test(&self) -> Result<SelfTestResponse, WsError> {
+        let resp = self.send_request::<()>(MessageType::SelfTest, None).await?;
+        resp.parse_payload::<SelfTestResponse>()?
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty self-test response".into(),
+            })
+    }
+
+    /// Reads the connected Agent's configuration.
+    pub async fn get_config(&self) -> Result<ConfigResponse, WsError> {
+        let resp = self
+            .send_request::<()>(MessageType::GetConfig, None)
+            .await?;
+        let config =
+            resp.parse_payload::<ConfigResponse>()?
+                .ok_or_else(|| WsError::AgentError {
+                    code: 500,
+                    message: "empty config response".into(),
+                })?;
+        self.cache_config(&config).await;
+        Ok(config)
+    }
+
+    /// Changes the connected Agent's default install directory.
+    pub async fn set_install_path(&self, path: &str) -> Result<ConfigResponse, WsError> {
+        let req = SetInstallPathRequest {
+            path: path.to_string(),
+        };
+        let resp = self
+            .send_request(MessageType::SetInstallPath, Some(&req))
+            .await?;
+        let config =
+            resp.parse_payload::<ConfigResponse>()?
+                .ok_or_else(|| WsError::AgentError {
+                    code: 500,
+                    message: "empty config response".into(),
+                })?;
+        self.cache_config(&config).await;
+        Ok(config)
+    }
+
+    /// Stores the settings just read from the active Agent.
+    async fn cache_config(&self, config: &ConfigResponse) {
+        let Some(active) = self.active.read().await.clone() else {
+            return;
+        };
+        if let Some(agent) = self.connected.write().await.get_mut(&active) {
+            agent.config = Some(config.clone());
+        }
+    }
+
+    /// Asks the connected Agent whether it would accept a deploy of
+    /// `total_size` bytes (0 = unknown size).
+    pub async fn can_deploy(&self, total_size: i64) -> Result<CanDeployResponse, WsError> {
+        let req = CanDeployRequest { total_size };
+        let resp = self
+            .send_request(MessageType::CanDeploy, Some(&req))
+            .await?;
+        resp.parse_payload::<CanDeployResponse>()?
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty can-deploy response".into(),
+            })
+    }
+
+    /// Asks the connected Agent for the size and free space of the
+    /// filesystem holding `path` (empty = its configured install path).
+    pub async fn get_disk_usage(&self, path: &str) -> Result<DiskUsage, WsError> {
+        let req = GetDiskUsageRequest {
+            path: path.to_string(),
+        };
+        let resp = self
+            .send_request(MessageType::GetDiskUsage, Some(&req))
+            .await?;
+        resp.parse_payload::<DiskUsage>()?
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty disk-usage response".into(),
+            })
+    }
+
+    /// Lists subdirectories of `path` on the agent, creating it first when
+    /// `create` is set. An empty path lists the agent's allowed roots.
+    pub async fn browse_directory(
+        &self,
+        path: &str,
+        create: bool,
+    ) -> Result<BrowseDirectoryResponse, WsError> {
+        let req = BrowseDirectoryRequest {
+            path: path.to_string(),
+            create,
+        };
+        let resp = self
+            .send_request(MessageType::BrowseDirectory, Some(&req))
+            .await?;
+        resp.parse_payload::<BrowseDirectoryResponse>()?
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty browse-directory response".into(),
+            })
+    }
+
+    /// Lists the Steam library folders on the agent, for deploying to an
+    /// SD card or other secondary library.
+    pub async fn steam_libraries(&self) -> Result<SteamLibrariesResponse, WsError> {
+        let resp = self
+            .send_request::<()>(MessageType::GetSteamLibraries, None)
+            .await?;
+        resp.parse_payload::<SteamLibrariesResponse>()?
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty steam-libraries response".into(),
+            })
+    }
+
+    /// Reports whether Steam is running on the agent and in gaming mode.
+    /// Shortcut artwork goes through CEF, which needs Steam up.
+    pub async fn steam_status(&self) -> Result<SteamStatusResponse, WsError> {
+        let resp = self
+            .send_request::<()>(MessageType::GetSteamStatus, None)
+            .await?;
+        resp.parse_payload::<SteamStatusResponse>()?
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty steam-status response".into(),
+            })
+    }
+
+    /// Asks whether the agent's Steam CEF debugger answers, and whether
+    /// Steam must restart first.
+    pub async fn check_cef(&self) -> Result<CefStatusResponse, WsError> {
+        let resp = self.send_request::<()>(MessageType::CheckCef, None).await?;
+        resp.parse_payload::<CefStatusResponse>()?
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty cef-status response".into(),
+            })
+    }
+
+    /// Restarts Steam on the agent and waits for CEF to come up. The agent
+    /// may take longer than the request timeout; `check_cef` tells whether
+    /// it got there anyway.
+    pub async fn restart_steam(&self) -> Result<RestartSteamResponse, WsError> {
+        let resp = self
+            .send_request::<()>(MessageType::RestartSteam, None)
+            .await?;
+        resp.parse_payload::<RestartSteamResponse>()?
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty restart-steam response".into(),
+            })
+    }
+
+    /// Lists the authorized Hubs connected to the agent, this one included
+    /// (marked `current`).
+    pub async fn connected_hubs(&self) -> Result<Vec<ConnectedHubEntry>, WsError> {
+        let resp = self
+            .send_request::<()>(MessageType::ListConnectedHubs, None)
+            .await?;
+        resp.parse_payload::<ConnectedHubsResponse>()?
+            .map(|r| r.hubs)
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty connected-hubs response".into(),
+            })
+    }
+
+    /// Asks the connected Agent how far `upload_id` got. Fails with
+    /// [`constants::WS_ERR_CODE_NOT_FOUND`] once the agent has dropped the
+    /// session.
+    pub async fn get_upload_status(&self, upload_id: &str) -> Result<UploadProgressEvent, WsError> {
+        let req = GetUploadStatusRequest {
+            upload_id: upload_id.to_string(),
+        };
+        let resp = self
+            .send_request(MessageType::GetUploadStatus, Some(&req))
+            .await?;
+        resp.parse_payload::<UploadProgressEvent>()?
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty upload-status response".into(),
+            })
+    }
+
+    /// Lists the upload sessions the active Agent holds, including ones
+    /// left behind by a Hub that went away.
+    pub async fn list_uploads(&self) -> Result<Vec<UploadSessionInfo>, WsError> {
+        let resp = self
+            .send_request::<()>(MessageType::ListUploads, None)
+            .await?;
+        resp.parse_payload::<UploadsResponse>()?
+            .map(|r| r.uploads)
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty uploads response".into(),
+            })
+    }
+
+    /// Has the active Agent discard uploads idle for `idle_secs` (0 = the
+    /// agent default) and delete their partial folders. Returns the
+    /// discarded sessions.
+    pub async fn prune_uploads(&self, idle_secs: u64) -> Result<Vec<UploadSessionInfo>, WsError> {
+        let req = PruneUploadsRequest { idle_secs };
+        let resp = self
+            .send_request(MessageType::PruneUploads, Some(&req))
+            .await?;
+        resp.parse_payload::<PruneUploadsResponse>()?
+            .map(|r| r.pruned)
+            .ok_or_else(|| WsError::AgentError {
+                code: 500,
+                message: "empty prune-uploads response".into(),
+            })
+    }
+
     /// Shuts down the connection manager.
     pub async fn shutdown(&self) {
         let _ = self.cancel_tx.send(true);
-        cancel_any_reconnect(&self.reconnect_cancel);
-        self.disconnect_agent_inner(true).await;
+        self.close_all().await;
         info!("connection manager shut down");
     }
 
@@ -354,18 +930,18 @@ impl ConnectionManager {
             token_store: self.token_store.clone(),
             discovered: self.discovered.clone(),
             connected: self.connected.clone(),
-            ws_client: self.ws_client.clone(),
+            ws_clients: self.ws_clients.clone(),
             state: self.state.clone(),
             events_tx: self.events_tx.clone(),
             reconnect_cancel: self.reconnect_cancel.clone(),
-            manual_disconnect: self.manual_disconnect.clone(),
             reconnect_config: self.reconnect_config.clone(),
+            heartbeat: self.heartbeat.clone(),
             last_known_addr: self.last_known_addr.clone(),
         }
     }
 
     /// Sets up event forwarding and disconnect callbacks on a WsClient.
-    async fn setup_client_callbacks(&self, client: &WsClient, agent_id: &str) {
+    async fn setup_client_callbacks(&self, client: &Arc<WsClient>, agent_id: &str) {
         setup_ws_callbacks(client, agent_id, self.ws_context()).await;
     }
 
@@ -388,6 +964,7 @@ impl ConnectionManager {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use capydeploy_protocol::messages::ConfigChangedEvent;
 
     fn test_hub() -> HubIdentity {
         HubIdentity {
@@ -427,6 +1004,27 @@ mod tests {
         mgr.disconnect_agent().await;
     }
 
+    #[tokio::test]
+    async fn connect_additional_unknown_agent_fails() {
+        let mgr = ConnectionManager::new(test_hub(), None);
+        assert!(
+            mgr.connect_additional_agent("nonexistent-id")
+                .await
+                .is_err()
+        );
+        assert!(mgr.active.read().await.is_none());
+    }
+
+    #[tokio::test]
+    async fn send_to_unconnected_agent_fails() {
+        let mgr = ConnectionManager::new(test_hub(), None);
+        let result = mgr
+            .send_request_to::<()>("nonexistent-id", MessageType::GetInfo, None)
+            .await;
+        assert!(matches!(result, Err(WsError::Closed)));
+        assert!(mgr.get_connected_agents().await.is_empty());
+    }
+
     #[tokio::test]
     async fn shutdown_is_clean() {
         let mgr = ConnectionManager::new(test_hub(), None);
@@ -455,4 +1053,587 @@ mod tests {
         let result = mgr.confirm_pairing("agent-1", "123456").await;
         assert!(matches!(result, Err(WsError::PairingFailed(_))));
     }
+
+    #[tokio::test]
+    async fn pause_and_resume_discovery() {
+        let mgr = ConnectionManager::new(test_hub(), None);
+        let mut rx = mgr.take_events().await.unwrap();
+
+        assert!(mgr.pause_discovery());
+        assert!(!mgr.pause_discovery(), "already paused");
+        assert!(mgr.is_discovery_paused());
+        assert!(matches!(
+            rx.try_recv(),
+            Ok(ConnectionEvent::DiscoveryPaused(true))
+        ));
+
+        // A paused refresh never touches the network, so it returns at
+        // once without events.
+        tokio::time::timeout(
+            std::time::Duration::from_millis(500),
+            mgr.refresh_discovery(),
+        )
+        .await
+        .expect("paused refresh should not scan");
+        assert!(rx.try_recv().is_err());
+
+        assert!(mgr.resume_discovery());
+        assert!(!mgr.is_discovery_paused());
+        assert!(matches!(
+            rx.try_recv(),
+            Ok(ConnectionEvent::DiscoveryPaused(false))
+        ));
+        assert!(!mgr.resume_discovery(), "already running");
+    }
+
+    const MOCK_AGENT_ID: &str = "agent-1";
+    const VALID_TOKEN: &str = "valid-token-123";
+
+    /// Minimal agent that accepts [`VALID_TOKEN`] and asks for pairing
+    /// for any other token. It also honours `set_install_path`, pushing
+    /// `config_changed` like the real agent.
+    async fn spawn_mock_agent() -> u16 {
+        use capydeploy_protocol::messages::{AgentStatusResponse, PairingRequiredResponse};
+        use futures_util::{SinkExt, StreamExt};
+        use tokio_tungstenite::tungstenite::Message as WsMessage;
+
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let port = listener.local_addr().unwrap().port();
+        tokio::spawn(async move {
+            while let Ok((stream, _)) = listener.accept().await {
+                tokio::spawn(async move {
+                    let Ok(mut ws) = tokio_tungstenite::accept_async(stream).await else {
+                        return;
+                    };
+                    while let Some(Ok(frame)) = ws.next().await {
+                        let Ok(msg) =
+                            serde_json::from_str::<Message>(frame.to_text().unwrap_or(""))
+                        else {
+                            continue;
+                        };
+                        if msg.msg_type == MessageType::SetInstallPath {
+                            let req: SetInstallPathRequest = msg.parse_payload().unwrap().unwrap();
+                            let reply = msg
+                                .reply(
+                                    MessageType::ConfigResponse,
+                                    Some(&ConfigResponse {
+                                        install_path: req.path.clone(),
+                                    }),
+                                )
+                                .unwrap();
+                            let push = Message::new(
+                                "push-1",
+                                MessageType::ConfigChanged,
+                                Some(&ConfigChangedEvent {
+                                    install_path: req.path,
+                                    telemetry_enabled: true,
+                                    telemetry_interval: 3,
+                                    console_log_enabled: false,
+                                    name: "Renamed Deck".into(),
+                                    accept_connections: Some(true),
+                                }),
+                            )
+                            .unwrap();
+                            for m in [reply, push] {
+                                let json = serde_json::to_string(&m).unwrap();
+                                let _ = ws.send(WsMessage::Text(json.into())).await;
+                            }
+                            continue;
+                        }
+                        if msg.msg_type == MessageType::GetInfo {
+                            let reply = msg
+                                .reply(
+                                    MessageType::InfoResponse,
+                                    Some(&InfoResponse {
+                                        agent: capydeploy_protocol::AgentInfo {
+                                            id: MOCK_AGENT_ID.into(),
+                                            name: "Mock Agent".into(),
+                                            platform: "linux".into(),
+                                            version: "0.1.0".into(),
+                                            accept_connections: true,
+                                            supported_image_formats: vec![],
+                                            steam_login_state: String::new(),
+                                            max_concurrent_uploads: 1,
+                                            verbose: false,
+                                            protocol_version: 0,
+                                            started_at: 0,
+                                            start_reason: String::new(),
+                                        },
+                                    }),
+                                )
+                                .unwrap();
+                            let json = serde_json::to_string(&reply).unwrap();
+                            let _ = ws.send(WsMessage::Text(json.into())).await;
+                            continue;
+                        }
+                        if msg.msg_type != MessageType::HubConnected {
+                            continue;
+                        }
+                        let req: HubConnectedRequest = msg.parse_payload().unwrap().unwrap();
+                        let reply = if req.token == VALID_TOKEN {
+                            msg.reply(
+                                MessageType::AgentStatus,
+                                Some(&AgentStatusResponse {
+                                    name: "Mock Agent".into(),
+                                    version: "0.1.0".into(),
+                                    platform: "linux".into(),
+                                    accept_connections: true,
+                                    telemetry_enabled: false,
+                                    telemetry_interval: 0,
+                                    console_log_enabled: false,
+                                    protocol_version: PROTOCOL_VERSION,
+                                    capabilities: vec![],
+                                    max_binary_frame_size: 0,
+                                    agent_time: 0,
+                                    framing_version: 0,
+                                    compression: vec![],
+                                    renewed_token: String::new(),
+                                    started_at: 0,
+                                    start_reason: String::new(),
+                                }),
+                            )
+                        } else {
+                            msg.reply(
+                                MessageType::PairingRequired,
+                                Some(&PairingRequiredResponse {
+                                    code: "123456".into(),
+                                    expires_in: 60,
+                                }),
+                            )
+                        }
+                        .unwrap();
+                        let json = serde_json::to_string(&reply).unwrap();
+                        if ws.send(WsMessage::Text(json.into())).await.is_err() {
+                            break;
+                        }
+                    }
+                });
+            }
+        });
+        port
+    }
+
+    /// Manager that has "discovered" the mock agent as [`MOCK_AGENT_ID`].
+    async fn manager_with_mock_agent(store: Arc<TokenStore>) -> ConnectionManager {
+        let mgr = ConnectionManager::new(test_hub(), Some(store));
+        add_mock_agent(&mgr, MOCK_AGENT_ID).await;
+        mgr
+    }
+
+    /// Starts another mock agent and adds it to `mgr` as discovered.
+    async fn add_mock_agent(mgr: &ConnectionManager, id: &str) {
+        let port = spawn_mock_agent().await;
+        let agent = DiscoveredAgent {
+            info: capydeploy_protocol::AgentInfo {
+                id: id.into(),
+                name: "Mock Agent".into(),
+                platform: "linux".into(),
+                version: "0.1.0".into(),
+                accept_connections: true,
+                supported_image_formats: vec![],
+                steam_login_state: String::new(),
+                max_concurrent_uploads:
+                    capydeploy_protocol::constants::DEFAULT_MAX_CONCURRENT_UPLOADS,
+                verbose: false,
+                protocol_version: 0,
+                started_at: 0,
+                start_reason: String::new(),
+            },
+            host: "localhost".into(),
+            port,
+            ips: vec!["127.0.0.1".parse().unwrap()],
+            discovered_at: None,
+            last_seen: None,
+            manual: false,
+        };
+        mgr.discovered.write().await.insert(id.into(), agent);
+    }
+
+    #[tokio::test]
+    async fn imported_token_authenticates_without_pairing() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = manager_with_mock_agent(store.clone()).await;
+        let agent_id = MOCK_AGENT_ID;
+
+        mgr.import_agent_token(agent_id, VALID_TOKEN).unwrap();
+        let connected = mgr.connect_agent(agent_id).await.unwrap();
+
+        assert_eq!(connected.status.name, "Mock Agent");
+        assert_eq!(
+            mgr.get_state(agent_id).await,
+            Some(ConnectionState::Connected)
+        );
+        assert_eq!(
+            mgr.export_agent_token(agent_id).unwrap().as_deref(),
+            Some(VALID_TOKEN)
+        );
+        mgr.shutdown().await;
+    }
+
+    #[tokio::test]
+    async fn manual_agent_is_probed_and_connectable() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = ConnectionManager::new(test_hub(), Some(store));
+        let port = spawn_mock_agent().await;
+
+        let agent = mgr.add_manual_agent(" 127.0.0.1 ", port).await.unwrap();
+        assert!(agent.manual);
+        assert_eq!(agent.info.id, MOCK_AGENT_ID);
+        assert_eq!(agent.host, "127.0.0.1");
+        assert_eq!(mgr.get_discovered().await.len(), 1);
+
+        mgr.import_agent_token(MOCK_AGENT_ID, VALID_TOKEN).unwrap();
+        mgr.connect_agent(MOCK_AGENT_ID).await.unwrap();
+        mgr.disconnect_agent().await;
+
+        assert!(mgr.remove_manual_agent(MOCK_AGENT_ID).await);
+        assert!(!mgr.remove_manual_agent(MOCK_AGENT_ID).await);
+        assert!(mgr.get_discovered().await.is_empty());
+        mgr.shutdown().await;
+    }
+
+    #[tokio::test]
+    async fn manual_agent_unreachable_fails() {
+        let mgr = ConnectionManager::new(test_hub(), None);
+        let listener = std::net::TcpListener::bind("127.0.0.1:0").unwrap();
+        let port = listener.local_addr().unwrap().port();
+        drop(listener);
+
+        assert!(mgr.add_manual_agent("127.0.0.1", port).await.is_err());
+        assert!(mgr.get_discovered().await.is_empty());
+    }
+
+    #[tokio::test]
+    async fn connected_agent_moving_updates_its_address() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = manager_with_mock_agent(store).await;
+        let mut rx = mgr.take_events().await.unwrap();
+        mgr.import_agent_token(MOCK_AGENT_ID, VALID_TOKEN).unwrap();
+        let connected = mgr.connect_agent(MOCK_AGENT_ID).await.unwrap();
+
+        // Same addresses: nothing to do.
+        crate::reconnection::handle_agent_moved(&mgr.ws_context(), &connected.agent).await;
+
+        let mut moved = connected.agent.clone();
+        moved.ips = vec!["127.0.0.2".parse().unwrap()];
+        crate::reconnection::handle_agent_moved(&mgr.ws_context(), &moved).await;
+
+        let event = loop {
+            match rx.try_recv() {
+                Ok(ConnectionEvent::AgentMoved {
+                    agent_id,
+                    old_ips,
+                    new_ips,
+                }) => break (agent_id, old_ips, new_ips),
+                Ok(_) => continue,
+                Err(e) => panic!("no agent moved event: {e}"),
+            }
+        };
+        assert_eq!(event.0, MOCK_AGENT_ID);
+        assert_eq!(event.1, connected.agent.ips);
+        assert_eq!(event.2, moved.ips);
+        while let Ok(e) = rx.try_recv() {
+            assert!(
+                !matches!(e, ConnectionEvent::AgentMoved { .. }),
+                "only one move should be reported"
+            );
+        }
+
+        let now = mgr.get_connected().await.unwrap();
+        assert_eq!(now.agent.ips, moved.ips);
+        assert_eq!(
+            mgr.get_state(MOCK_AGENT_ID).await,
+            Some(ConnectionState::Connected)
+        );
+        mgr.shutdown().await;
+    }
+
+    #[tokio::test]
+    async fn invalid_imported_token_falls_back_to_pairing() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = manager_with_mock_agent(store.clone()).await;
+        let agent_id = MOCK_AGENT_ID;
+
+        mgr.import_agent_token(agent_id, "stale-token").unwrap();
+        let result = mgr.connect_agent(agent_id).await;
+
+        assert!(matches!(result, Err(WsError::PairingFailed(_))));
+        assert_eq!(
+            mgr.get_state(agent_id).await,
+            Some(ConnectionState::PairingRequired)
+        );
+        // The rejected token is dropped so pairing can replace it.
+        assert!(store.get_token(agent_id).is_none());
+        mgr.shutdown().await;
+    }
+
+    #[tokio::test]
+    async fn repair_clears_valid_token_and_starts_pairing() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = manager_with_mock_agent(store.clone()).await;
+        let mut events = mgr.take_events().await.unwrap();
+        let agent_id = MOCK_AGENT_ID;
+
+        // The old token still works; repair must not use it.
+        store.save_token(agent_id, VALID_TOKEN).unwrap();
+        assert!(mgr.repair_agent(agent_id).await.unwrap());
+
+        assert!(store.get_token(agent_id).is_none());
+        assert_eq!(
+            mgr.get_state(agent_id).await,
+            Some(ConnectionState::PairingRequired)
+        );
+        let pairing = loop {
+            match events.recv().await.unwrap() {
+                ConnectionEvent::PairingNeeded { agent_id, code, .. } => break (agent_id, code),
+                _ => continue,
+            }
+        };
+        assert_eq!(pairing, (MOCK_AGENT_ID.to_string(), "123456".to_string()));
+        mgr.shutdown().await;
+    }
+
+    #[tokio::test]
+    async fn cancel_pairing_then_connect_elsewhere_starts_clean() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = manager_with_mock_agent(store.clone()).await;
+        add_mock_agent(&mgr, "agent-2").await;
+        store.save_token("agent-2", VALID_TOKEN).unwrap();
+
+        // No token for the first agent, so it asks for pairing.
+        assert!(matches!(
+            mgr.connect_agent(MOCK_AGENT_ID).await,
+            Err(WsError::PairingFailed(_))
+        ));
+        mgr.cancel_pairing().await;
+
+        assert_eq!(
+            mgr.get_state(MOCK_AGENT_ID).await,
+            Some(ConnectionState::Disconnected)
+        );
+        assert!(mgr.pairing_agent_id.lock().await.is_none());
+        assert!(mgr.ws_clients.lock().unwrap().is_empty());
+        assert!(mgr.get_connected().await.is_none());
+
+        let connected = mgr.connect_agent("agent-2").await.unwrap();
+        assert_eq!(connected.agent.info.id, "agent-2");
+        assert_eq!(
+            mgr.get_connected().await.map(|c| c.agent.info.id),
+            Some("agent-2".to_string())
+        );
+        assert_eq!(
+            mgr.get_state("agent-2").await,
+            Some(ConnectionState::Connected)
+        );
+        // Nothing from the cancelled attempt lingers.
+        assert_eq!(
+            mgr.get_state(MOCK_AGENT_ID).await,
+            Some(ConnectionState::Disconnected)
+        );
+        assert!(store.get_token(MOCK_AGENT_ID).is_none());
+        assert!(matches!(
+            mgr.confirm_pairing(MOCK_AGENT_ID, "123456").await,
+            Err(WsError::PairingFailed(_))
+        ));
+        mgr.shutdown().await;
+    }
+
+    #[tokio::test]
+    async fn switching_agents_mid_pairing_resets_the_pending_one() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = manager_with_mock_agent(store.clone()).await;
+        add_mock_agent(&mgr, "agent-2").await;
+        store.save_token("agent-2", VALID_TOKEN).unwrap();
+
+        let _ = mgr.connect_agent(MOCK_AGENT_ID).await;
+        assert_eq!(
+            mgr.get_state(MOCK_AGENT_ID).await,
+            Some(ConnectionState::PairingRequired)
+        );
+
+        // Connecting elsewhere without cancelling first also cleans up.
+        mgr.connect_agent("agent-2").await.unwrap();
+        assert_eq!(
+            mgr.get_state(MOCK_AGENT_ID).await,
+            Some(ConnectionState::Disconnected)
+        );
+        assert!(mgr.pairing_agent_id.lock().await.is_none());
+        mgr.shutdown().await;
+    }
+
+    #[tokio::test]
+    async fn install_path_change_is_pushed_to_hub() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = manager_with_mock_agent(store.clone()).await;
+        let mut events = mgr.take_events().await.unwrap();
+        store.save_token(MOCK_AGENT_ID, VALID_TOKEN).unwrap();
+
+        let connected = mgr.connect_agent(MOCK_AGENT_ID).await.unwrap();
+        assert!(connected.config.is_none());
+        assert!(!connected.status.telemetry_enabled);
+
+        mgr.set_install_path("/run/media/sd/Games").await.unwrap();
+
+        let pushed = tokio::time::timeout(std::time::Duration::from_secs(5), async {
+            loop {
+                if let ConnectionEvent::AgentEvent {
+                    agent_id,
+                    msg_type: MessageType::ConfigChanged,
+                    message,
+                } = events.recv().await.unwrap()
+                {
+                    break (agent_id, message);
+                }
+            }
+        })
+        .await
+        .expect("config_changed event");
+        assert_eq!(pushed.0, MOCK_AGENT_ID);
+        let event: ConfigChangedEvent = pushed.1.parse_payload().unwrap().unwrap();
+        assert_eq!(event.install_path, "/run/media/sd/Games");
+
+        // The cache was updated before the event was forwarded.
+        let cached = mgr.get_connected().await.unwrap();
+        assert_eq!(
+            cached.config.map(|c| c.install_path).as_deref(),
+            Some("/run/media/sd/Games")
+        );
+        assert!(cached.status.telemetry_enabled);
+        assert_eq!(cached.status.telemetry_interval, 3);
+        assert_eq!(cached.agent.info.name, "Renamed Deck");
+        mgr.shutdown().await;
+    }
+
+    /// Collects the names of `agent_id`'s state changes until `last`.
+    async fn states_until(
+        events: &mut mpsc::Receiver<ConnectionEvent>,
+        agent_id: &str,
+        last: &str,
+    ) -> Vec<&'static str> {
+        let mut states = Vec::new();
+        tokio::time::timeout(std::time::Duration::from_secs(10), async {
+            loop {
+                if let ConnectionEvent::StateChanged {
+                    agent_id: id,
+                    state,
+                } = events.recv().await.unwrap()
+                    && id == agent_id
+                {
+                    states.push(state.name());
+                    if state.name() == last {
+                        break;
+                    }
+                }
+            }
+        })
+        .await
+        .unwrap_or_else(|_| panic!("no {last} state, got {states:?}"));
+        states
+    }
+
+    /// Drops the socket the way a network failure would: without a
+    /// user-initiated disconnect.
+    async fn drop_connection(mgr: &ConnectionManager) {
+        mgr.client().await.unwrap().close().await;
+    }
+
+    #[tokio::test]
+    async fn dropped_connection_reconnects() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = manager_with_mock_agent(store.clone()).await;
+        let mut events = mgr.take_events().await.unwrap();
+        store.save_token(MOCK_AGENT_ID, VALID_TOKEN).unwrap();
+
+        mgr.connect_agent(MOCK_AGENT_ID).await.unwrap();
+        assert_eq!(
+            states_until(&mut events, MOCK_AGENT_ID, "connected").await,
+            ["connecting", "connected"]
+        );
+
+        drop_connection(&mgr).await;
+        // Never reported as disconnected while the Hub is retrying.
+        assert_eq!(
+            states_until(&mut events, MOCK_AGENT_ID, "connected").await,
+            ["reconnecting", "connected"]
+        );
+        assert_eq!(
+            mgr.get_state(MOCK_AGENT_ID).await,
+            Some(ConnectionState::Connected)
+        );
+        assert!(mgr.get_connected().await.is_some());
+
+        // A user disconnect is final.
+        mgr.disconnect_agent().await;
+        assert_eq!(
+            states_until(&mut events, MOCK_AGENT_ID, "disconnected").await,
+            ["disconnected"]
+        );
+        tokio::time::sleep(std::time::Duration::from_millis(500)).await;
+        assert_eq!(
+            mgr.get_state(MOCK_AGENT_ID).await,
+            Some(ConnectionState::Disconnected)
+        );
+        assert!(mgr.active_state().await.is_none());
+        mgr.shutdown().await;
+    }
+
+    #[tokio::test]
+    async fn unrecoverable_drop_is_lost() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = Arc::new(TokenStore::new(tmp.path().join("tokens.json")).unwrap());
+        let mgr = manager_with_mock_agent(store.clone()).await;
+        let mut events = mgr.take_events().await.unwrap();
+        store.save_token(MOCK_AGENT_ID, VALID_TOKEN).unwrap();
+
+        mgr.connect_agent(MOCK_AGENT_ID).await.unwrap();
+        // The agent vanishes: nowhere left to reconnect to.
+        mgr.discovered.write().await.clear();
+        mgr.last_known_addr.lock().await.clear();
+        drop_connection(&mgr).await;
+        assert_eq!(
+            states_until(&mut events, MOCK_AGENT_ID, "lost").await,
+            ["connecting", "connected", "reconnecting", "lost"]
+        );
+        assert_eq!(
+            mgr.get_state(MOCK_AGENT_ID).await,
+            Some(ConnectionState::Lost)
+        );
+        assert!(mgr.get_connected().await.is_none());
+        assert_eq!(
+            mgr.active_state().await,
+            Some((MOCK_AGENT_ID.to_string(), ConnectionState::Lost))
+        );
+        mgr.shutdown().await;
+    }
+
+    #[tokio::test]
+    async fn repair_unknown_agent_fails() {
+        let mgr = ConnectionManager::new(test_hub(), None);
+        assert!(matches!(
+            mgr.repair_agent("missing").await,
+            Err(WsError::Closed)
+        ));
+    }
+
+    #[tokio::test]
+    async fn token_import_export_without_store_fails() {
+        let mgr = ConnectionManager::new(test_hub(), None);
+        assert!(matches!(
+            mgr.import_agent_token("agent-1", VALID_TOKEN),
+            Err(PairingError::NoStore)
+        ));
+        assert!(matches!(
+            mgr.export_agent_token("agent-1"),
+            Err(PairingError::NoStore)
+        ));
+    }
 }
@@ -7,7 +7,7 @@ use std::collections::HashMap;
 use std::path::{Path, PathBuf};
 use std::sync::RwLock;
 
-use tracing::debug;
+use tracing::{debug, warn};
 
 /// Errors from pairing operations.
 #[derive(Debug, thiserror::Error)]
@@ -17,26 +17,58 @@ pub enum PairingError {
 
     #[error("JSON error: {0}")]
     Json(#[from] serde_json::Error),
+
+    #[error("invalid token: {0}")]
+    InvalidToken(String),
+
+    #[error("token store unavailable")]
+    NoStore,
 }
 
+/// Maximum accepted length for a manually imported token.
+pub const MAX_TOKEN_LEN: usize = 256;
+
 /// Persistent token store for paired Agents.
 ///
 /// Tokens are cached in memory and persisted to a JSON file.
 pub struct TokenStore {
     path: PathBuf,
     tokens: RwLock<HashMap<String, String>>,
+    /// Where an unreadable token file was moved when the store started.
+    recovered_backup: Option<PathBuf>,
 }
 
 impl TokenStore {
     /// Creates a new token store, loading existing tokens from disk.
+    ///
+    /// A file that isn't valid JSON is backed up and the store starts
+    /// empty; see [`recovered_backup`](Self::recovered_backup).
     pub fn new(path: PathBuf) -> Result<Self, PairingError> {
-        let tokens = load_tokens(&path)?;
+        let (tokens, recovered_backup) = match load_tokens(&path) {
+            Ok(tokens) => (tokens, None),
+            Err(PairingError::Json(e)) => {
+                let backup = capydeploy_hub_settings::backup_corrupt(&path)?;
+                warn!(
+                    "token store {:?} is corrupted ({e}); moved to {:?}, starting empty",
+                    path, backup
+                );
+                (HashMap::new(), Some(backup))
+            }
+            Err(e) => return Err(e),
+        };
         Ok(Self {
             path,
             tokens: RwLock::new(tokens),
+            recovered_backup,
         })
     }
 
+    /// Backup of the corrupted token file this store replaced on startup,
+    /// if any. Every Agent must be paired again.
+    pub fn recovered_backup(&self) -> Option<&Path> {
+        self.recovered_backup.as_deref()
+    }
+
     /// Returns the token for an Agent, if any.
     pub fn get_token(&self, agent_id: &str) -> Option<String> {
         self.tokens.read().unwrap().get(agent_id).cloned()
@@ -51,6 +83,15 @@ impl TokenStore {
         self.persist()
     }
 
+    /// Stores a token obtained outside the pairing flow (e.g. exported on
+    /// another machine), after checking that it is plausibly well formed.
+    ///
+    /// Whether the Agent accepts it is only known on the next connect.
+    pub fn import_token(&self, agent_id: &str, token: &str) -> Result<(), PairingError> {
+        validate_token(agent_id, token)?;
+        self.save_token(agent_id, token.trim())
+    }
+
     /// Removes a token for an Agent.
     pub fn remove_token(&self, agent_id: &str) -> Result<(), PairingError> {
         {
@@ -69,15 +110,35 @@ impl TokenStore {
     fn persist(&self) -> Result<(), PairingError> {
         let map = self.tokens.read().unwrap();
         let json = serde_json::to_string_pretty(&*map)?;
-        if let Some(parent) = self.path.parent() {
-            std::fs::create_dir_all(parent)?;
-        }
-        std::fs::write(&self.path, json)?;
+        capydeploy_hub_settings::write_atomic(&self.path, json.as_bytes())?;
         debug!("persisted {} token(s) to {:?}", map.len(), self.path);
         Ok(())
     }
 }
 
+/// Checks the shape of an imported token; the Agent remains the authority
+/// on whether it is actually valid.
+fn validate_token(agent_id: &str, token: &str) -> Result<(), PairingError> {
+    if agent_id.trim().is_empty() {
+        return Err(PairingError::InvalidToken("agent ID is empty".into()));
+    }
+    let token = token.trim();
+    if token.is_empty() {
+        return Err(PairingError::InvalidToken("token is empty".into()));
+    }
+    if token.len() > MAX_TOKEN_LEN {
+        return Err(PairingError::InvalidToken(format!(
+            "token is longer than {MAX_TOKEN_LEN} characters"
+        )));
+    }
+    if !token.chars().all(|c| c.is_ascii_graphic()) {
+        return Err(PairingError::InvalidToken(
+            "token contains whitespace or non-ASCII characters".into(),
+        ));
+    }
+    Ok(())
+}
+
 /// Loads tokens from a JSON file on disk.
 fn load_tokens(path: &Path) -> Result<HashMap<String, String>, PairingError> {
     if !path.exists() {
@@ -192,6 +253,63 @@ mod tests {
         assert_eq!(ids, vec!["a", "b", "c"]);
     }
 
+    #[test]
+    fn import_token_trims_and_persists() {
+        let tmp = tempfile::tempdir().unwrap();
+        let path = tmp.path().join("tokens.json");
+        let store = TokenStore::new(path.clone()).unwrap();
+        store.import_token("agent-1", "  tok-abc123\n").unwrap();
+
+        let reloaded = TokenStore::new(path).unwrap();
+        assert_eq!(reloaded.get_token("agent-1").unwrap(), "tok-abc123");
+    }
+
+    #[test]
+    fn import_token_rejects_malformed() {
+        let (_tmp, store) = test_store();
+        for (agent, token) in [
+            ("", "tok"),
+            ("agent-1", ""),
+            ("agent-1", "   "),
+            ("agent-1", "has space"),
+            ("agent-1", "tok\u{e9}"),
+        ] {
+            assert!(
+                matches!(
+                    store.import_token(agent, token),
+                    Err(PairingError::InvalidToken(_))
+                ),
+                "{agent:?} {token:?}"
+            );
+        }
+        let long = "a".repeat(MAX_TOKEN_LEN + 1);
+        assert!(store.import_token("agent-1", &long).is_err());
+        assert!(store.agent_ids().is_empty());
+    }
+
+    #[test]
+    fn corrupted_file_is_backed_up_and_reset() {
+        let tmp = tempfile::tempdir().unwrap();
+        let path = tmp.path().join("tokens.json");
+        // Interrupted write: the JSON stops halfway.
+        std::fs::write(&path, r#"{"agent-1": "tok"#).unwrap();
+
+        let store = TokenStore::new(path.clone()).unwrap();
+        assert!(store.agent_ids().is_empty());
+        let backup = store.recovered_backup().unwrap().to_path_buf();
+        assert_eq!(
+            std::fs::read_to_string(&backup).unwrap(),
+            r#"{"agent-1": "tok"#
+        );
+        assert!(!path.exists());
+
+        // The store keeps working and the rewritten file loads cleanly.
+        store.save_token("agent-2", "fresh").unwrap();
+        let reloaded = TokenStore::new(path).unwrap();
+        assert!(reloaded.recovered_backup().is_none());
+        assert_eq!(reloaded.get_token("agent-2").as_deref(), Some("fresh"));
+    }
+
     #[test]
     fn load_missing_file_returns_empty() {
         let path = PathBuf::from("/tmp/nonexistent_capydeploy_test_tokens.json");
@@ -6,6 +6,7 @@ use capydeploy_protocol::messages::{
     PairSuccessResponse, PairingRequiredResponse,
 };
 
+use crate::pairing::TokenStore;
 use crate::ws_client::{HandshakeResult, WsClient, WsError};
 
 /// Performs the initial handshake after connecting to an Agent.
@@ -77,3 +78,62 @@ pub(crate) async fn confirm_pairing(
         _ => Err(WsError::PairingFailed("unexpected response".into())),
     }
 }
+
+/// Stores the token an Agent issued in its status reply to replace one
+/// close to expiry. Without this the Hub would have to pair again once
+/// the old token expires.
+pub(crate) fn store_renewed_token(
+    store: Option<&TokenStore>,
+    agent_id: &str,
+    status: &AgentStatusResponse,
+) {
+    if status.renewed_token.is_empty() {
+        return;
+    }
+    let Some(store) = store else {
+        return;
+    };
+    match store.save_token(agent_id, &status.renewed_token) {
+        Ok(()) => tracing::info!(agent = %agent_id, "stored renewed agent token"),
+        Err(e) => tracing::warn!(agent = %agent_id, error = %e, "failed to store renewed token"),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn status(renewed_token: &str) -> AgentStatusResponse {
+        AgentStatusResponse {
+            name: "Agent".into(),
+            version: "0.1.0".into(),
+            platform: "linux".into(),
+            accept_connections: true,
+            telemetry_enabled: false,
+            telemetry_interval: 0,
+            console_log_enabled: false,
+            protocol_version: 0,
+            capabilities: vec![],
+            max_binary_frame_size: 0,
+            agent_time: 0,
+            framing_version: 0,
+            compression: vec![],
+            renewed_token: renewed_token.into(),
+            started_at: 0,
+            start_reason: String::new(),
+        }
+    }
+
+    #[test]
+    fn renewed_token_replaces_stored_token() {
+        let tmp = tempfile::tempdir().unwrap();
+        let store = TokenStore::new(tmp.path().join("tokens.json")).unwrap();
+        store.save_token("agent-1", "old").unwrap();
+
+        store_renewed_token(Some(&store), "agent-1", &status(""));
+        assert_eq!(store.get_token("agent-1").as_deref(), Some("old"));
+
+        store_renewed_token(Some(&store), "agent-1", &status("new"));
+        assert_eq!(store.get_token("agent-1").as_deref(), Some("new"));
+    }
+}
@@ -0,0 +1,414 @@
+//! One-call setup of a fresh agent.
+//!
+//! Walks a new device through pairing, install path, CEF readiness and
+//! diagnostics so the Hub can offer a guided setup instead of five
+//! separate screens. Each step reuses an existing request; the sequence
+//! runs against [`ProvisionTarget`] so it can be tested with a stub.
+
+use std::future::Future;
+use std::pin::Pin;
+use std::time::Duration;
+
+use serde::{Deserialize, Serialize};
+use tracing::{info, warn};
+
+use capydeploy_protocol::constants::DEPLOY_BLOCKER_CEF_NOT_READY;
+use capydeploy_protocol::messages::{
+    CanDeployResponse, ConfigResponse, DeployBlocker, SelfTestResponse,
+};
+
+use crate::manager::ConnectionManager;
+use crate::ws_client::WsError;
+
+/// How often CEF readiness is re-checked while waiting.
+const CEF_POLL_INTERVAL: Duration = Duration::from_secs(2);
+
+/// Future returned by [`ProvisionTarget`] steps.
+pub type StepFuture<'a, T> = Pin<Box<dyn Future<Output = Result<T, WsError>> + Send + 'a>>;
+
+/// The agent operations provisioning is built from.
+pub trait ProvisionTarget: Send + Sync {
+    /// Connects; returns `true` when the agent is waiting for a pairing code.
+    fn connect<'a>(&'a self, agent_id: &'a str) -> StepFuture<'a, bool>;
+
+    fn confirm_pairing<'a>(&'a self, agent_id: &'a str, code: &'a str) -> StepFuture<'a, ()>;
+
+    fn get_config(&self) -> StepFuture<'_, ConfigResponse>;
+
+    fn set_install_path<'a>(&'a self, path: &'a str) -> StepFuture<'a, ConfigResponse>;
+
+    fn can_deploy(&self) -> StepFuture<'_, CanDeployResponse>;
+
+    fn self_test(&self) -> StepFuture<'_, SelfTestResponse>;
+}
+
+/// What to set up on the agent.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase", default)]
+pub struct ProvisionOptions {
+    /// Install directory to set; empty keeps the agent's current one.
+    pub install_path: String,
+    /// How long to wait for Steam's CEF debugger to come up, in seconds.
+    /// `0` checks once.
+    pub cef_wait_secs: u64,
+}
+
+/// Outcome of a provisioning run.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ProvisionSummary {
+    pub agent_id: String,
+    /// Whether a new pairing was made (false if a stored token worked).
+    pub paired: bool,
+    /// The agent's install path after provisioning.
+    pub install_path: String,
+    pub cef_ready: bool,
+    /// Self-test results; `None` on agents without `self_test`.
+    pub self_test: Option<SelfTestResponse>,
+    /// Remaining reasons a deploy would be refused.
+    pub blockers: Vec<DeployBlocker>,
+    /// Steps that were skipped or degraded.
+    pub warnings: Vec<String>,
+    /// True when the agent can take a deploy and every self-test passed.
+    pub ready: bool,
+}
+
+/// Runs the provisioning sequence against `target`.
+///
+/// `pairing_code` is called only if the agent asks for pairing; it returns
+/// the code the user typed, or `None` to abort. Connection, pairing and
+/// install path failures abort the run. CEF and diagnostic problems are
+/// reported in the summary instead, so the user sees what is left to fix.
+pub async fn provision<T, F, Fut>(
+    target: &T,
+    agent_id: &str,
+    opts: &ProvisionOptions,
+    pairing_code: F,
+) -> Result<ProvisionSummary, WsError>
+where
+    T: ProvisionTarget + ?Sized,
+    F: FnOnce(String) -> Fut,
+    Fut: Future<Output = Option<String>>,
+{
+    let mut warnings = Vec::new();
+
+    // 1. Connect and pair if needed.
+    let paired = target.connect(agent_id).await?;
+    if paired {
+        let code = pairing_code(agent_id.to_string())
+            .await
+            .filter(|c| !c.trim().is_empty())
+            .ok_or_else(|| WsError::PairingFailed("pairing cancelled".into()))?;
+        target.confirm_pairing(agent_id, code.trim()).await?;
+        info!(agent = %agent_id, "provision: paired");
+    }
+
+    // 2. Install path.
+    let install_path = if opts.install_path.trim().is_empty() {
+        target.get_config().await?.install_path
+    } else {
+        target
+            .set_install_path(opts.install_path.trim())
+            .await?
+            .install_path
+    };
+
+    // 3. CEF readiness.
+    let attempts = 1 + opts.cef_wait_secs / CEF_POLL_INTERVAL.as_secs();
+    let mut verdict = target.can_deploy().await?;
+    for _ in 1..attempts {
+        if !cef_blocked(&verdict) {
+            break;
+        }
+        tokio::time::sleep(CEF_POLL_INTERVAL).await;
+        verdict = target.can_deploy().await?;
+    }
+    let cef_ready = !cef_blocked(&verdict);
+    if !cef_ready {
+        warn!(agent = %agent_id, "provision: CEF still not ready");
+    }
+
+    // 4. Diagnostics.
+    let self_test = match target.self_test().await {
+        Ok(resp) => Some(resp),
+        Err(e) if e.is_not_implemented() => {
+            warnings.push("agent does not support self-test; diagnostics skipped".into());
+            None
+        }
+        Err(e) => return Err(e),
+    };
+
+    let ready = verdict.can_deploy && self_test.as_ref().is_none_or(|t| t.passed);
+    Ok(ProvisionSummary {
+        agent_id: agent_id.to_string(),
+        paired,
+        install_path,
+        cef_ready,
+        self_test,
+        blockers: verdict.reasons,
+        warnings,
+        ready,
+    })
+}
+
+fn cef_blocked(verdict: &CanDeployResponse) -> bool {
+    verdict
+        .reasons
+        .iter()
+        .any(|r| r.code == DEPLOY_BLOCKER_CEF_NOT_READY)
+}
+
+impl ProvisionTarget for ConnectionManager {
+    fn connect<'a>(&'a self, agent_id: &'a str) -> StepFuture<'a, bool> {
+        Box::pin(async move {
+            match self.connect_agent(agent_id).await {
+                Ok(_) => Ok(false),
+                Err(WsError::PairingFailed(reason)) => {
+                    // Also returned for real failures; only a pending
+                    // pairing means the agent is waiting for a code.
+                    let pending = self.pairing_agent_id.lock().await.as_deref() == Some(agent_id);
+                    if pending {
+                        Ok(true)
+                    } else {
+                        Err(WsError::PairingFailed(reason))
+                    }
+                }
+                Err(e) => Err(e),
+            }
+        })
+    }
+
+    fn confirm_pairing<'a>(&'a self, agent_id: &'a str, code: &'a str) -> StepFuture<'a, ()> {
+        Box::pin(async move {
+            ConnectionManager::confirm_pairing(self, agent_id, code)
+                .await
+                .map(|_| ())
+        })
+    }
+
+    fn get_config(&self) -> StepFuture<'_, ConfigResponse> {
+        Box::pin(ConnectionManager::get_config(self))
+    }
+
+    fn set_install_path<'a>(&'a self, path: &'a str) -> StepFuture<'a, ConfigResponse> {
+        Box::pin(ConnectionManager::set_install_path(self, path))
+    }
+
+    fn can_deploy(&self) -> StepFuture<'_, CanDeployResponse> {
+        Box::pin(ConnectionManager::can_deploy(self, 0))
+    }
+
+    fn self_test(&self) -> StepFuture<'_, SelfTestResponse> {
+        Box::pin(ConnectionManager::self_test(self))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use capydeploy_protocol::constants::WS_ERR_CODE_NOT_IMPLEMENTED;
+    use capydeploy_protocol::messages::SelfTestCheck;
+    use std::sync::Mutex;
+
+    /// Agent stub that records the calls made to it.
+    struct StubAgent {
+        needs_pairing: bool,
+        code: &'static str,
+        /// Number of `can_deploy` calls that report CEF as not ready.
+        cef_down_checks: Mutex<u32>,
+        self_test_supported: bool,
+        install_path: Mutex<String>,
+        calls: Mutex<Vec<String>>,
+    }
+
+    impl StubAgent {
+        fn new() -> Self {
+            Self {
+                needs_pairing: false,
+                code: "123456",
+                cef_down_checks: Mutex::new(0),
+                self_test_supported: true,
+                install_path: Mutex::new("~/Games".into()),
+                calls: Mutex::new(Vec::new()),
+            }
+        }
+
+        fn record(&self, call: String) {
+            self.calls.lock().unwrap().push(call);
+        }
+
+        fn calls(&self) -> Vec<String> {
+            self.calls.lock().unwrap().clone()
+        }
+    }
+
+    impl ProvisionTarget for StubAgent {
+        fn connect<'a>(&'a self, agent_id: &'a str) -> StepFuture<'a, bool> {
+            self.record(format!("connect {agent_id}"));
+            Box::pin(async move { Ok(self.needs_pairing) })
+        }
+
+        fn confirm_pairing<'a>(&'a self, _agent_id: &'a str, code: &'a str) -> StepFuture<'a, ()> {
+            self.record(format!("pair {code}"));
+            Box::pin(async move {
+                if code == self.code {
+                    Ok(())
+                } else {
+                    Err(WsError::PairingFailed("invalid code".into()))
+                }
+            })
+        }
+
+        fn get_config(&self) -> StepFuture<'_, ConfigResponse> {
+            self.record("get_config".into());
+            Box::pin(async move {
+                Ok(ConfigResponse {
+                    install_path: self.install_path.lock().unwrap().clone(),
+                })
+            })
+        }
+
+        fn set_install_path<'a>(&'a self, path: &'a str) -> StepFuture<'a, ConfigResponse> {
+            self.record(format!("set_install_path {path}"));
+            *self.install_path.lock().unwrap() = path.to_string();
+            Box::pin(async move {
+                Ok(ConfigResponse {
+                    install_path: path.to_string(),
+                })
+            })
+        }
+
+        fn can_deploy(&self) -> StepFuture<'_, CanDeployResponse> {
+            self.record("can_deploy".into());
+            let mut down = self.cef_down_checks.lock().unwrap();
+            let reasons = if *down > 0 {
+                *down -= 1;
+                vec![DeployBlocker {
+                    code: DEPLOY_BLOCKER_CEF_NOT_READY.into(),
+                    message: "CEF debugger unreachable".into(),
+                }]
+            } else {
+                Vec::new()
+            };
+            Box::pin(async move {
+                Ok(CanDeployResponse {
+                    can_deploy: reasons.is_empty(),
+                    reasons,
+                })
+            })
+        }
+
+        fn self_test(&self) -> StepFuture<'_, SelfTestResponse> {
+            self.record("self_test".into());
+            Box::pin(async move {
+                if !self.self_test_supported {
+                    return Err(WsError::AgentError {
+                        code: WS_ERR_CODE_NOT_IMPLEMENTED,
+                        message: "not implemented".into(),
+                    });
+                }
+                Ok(SelfTestResponse {
+                    passed: true,
+                    checks: vec![SelfTestCheck {
+                        name: "cef".into(),
+                        passed: true,
+                        detail: String::new(),
+                        duration_ms: 3,
+                    }],
+                })
+            })
+        }
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn full_provisioning_sequence() {
+        let mut stub = StubAgent::new();
+        stub.needs_pairing = true;
+        *stub.cef_down_checks.get_mut().unwrap() = 2;
+        let opts = ProvisionOptions {
+            install_path: "/run/media/deck/sd/Games".into(),
+            cef_wait_secs: 10,
+        };
+
+        let summary = provision(&stub, "agent-1", &opts, |id| async move {
+            assert_eq!(id, "agent-1");
+            Some(" 123456 ".to_string())
+        })
+        .await
+        .unwrap();
+
+        assert_eq!(
+            stub.calls(),
+            [
+                "connect agent-1",
+                "pair 123456",
+                "set_install_path /run/media/deck/sd/Games",
+                "can_deploy",
+                "can_deploy",
+                "can_deploy",
+                "self_test",
+            ]
+        );
+        assert!(summary.paired);
+        assert_eq!(summary.install_path, "/run/media/deck/sd/Games");
+        assert!(summary.cef_ready);
+        assert!(summary.blockers.is_empty());
+        assert!(summary.warnings.is_empty());
+        assert!(summary.ready);
+    }
+
+    #[tokio::test]
+    async fn cancelled_pairing_stops_early() {
+        let mut stub = StubAgent::new();
+        stub.needs_pairing = true;
+
+        let err = provision(&stub, "agent-1", &ProvisionOptions::default(), |_| async {
+            None
+        })
+        .await
+        .unwrap_err();
+        assert!(matches!(err, WsError::PairingFailed(_)));
+        assert_eq!(stub.calls(), ["connect agent-1"]);
+
+        let err = provision(&stub, "agent-1", &ProvisionOptions::default(), |_| async {
+            Some("000000".to_string())
+        })
+        .await
+        .unwrap_err();
+        assert!(matches!(err, WsError::PairingFailed(_)));
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn reports_cef_and_missing_self_test() {
+        let mut stub = StubAgent::new();
+        *stub.cef_down_checks.get_mut().unwrap() = u32::MAX;
+        stub.self_test_supported = false;
+        let opts = ProvisionOptions {
+            install_path: String::new(),
+            cef_wait_secs: 4,
+        };
+
+        let summary = provision(&stub, "agent-1", &opts, |_| async { None })
+            .await
+            .unwrap();
+
+        assert_eq!(
+            stub.calls(),
+            [
+                "connect agent-1",
+                "get_config",
+                "can_deploy",
+                "can_deploy",
+                "can_deploy",
+                "self_test",
+            ]
+        );
+        assert!(!summary.paired);
+        assert_eq!(summary.install_path, "~/Games");
+        assert!(!summary.cef_ready);
+        assert_eq!(summary.blockers[0].code, DEPLOY_BLOCKER_CEF_NOT_READY);
+        assert!(summary.self_test.is_none());
+        assert_eq!(summary.warnings.len(), 1);
+        assert!(!summary.ready);
+    }
+}
@@ -1,17 +1,18 @@
 //! WebSocket ping pump — periodic keepalive pings.
 
+use std::time::Duration;
+
 use tokio::sync::mpsc;
 use tokio_tungstenite::tungstenite;
 use tokio_util::sync::CancellationToken;
 
-use capydeploy_protocol::constants::WS_PING_PERIOD;
-
-/// Sends periodic pings to keep the connection alive.
+/// Sends a ping every `period`; `read_pump` watches for the Agent going silent.
 pub(crate) async fn ping_pump(
     write_tx: mpsc::Sender<tungstenite::Message>,
+    period: Duration,
     cancel: CancellationToken,
 ) {
-    let mut interval = tokio::time::interval(WS_PING_PERIOD);
+    let mut interval = tokio::time::interval(period);
     interval.tick().await; // Skip immediate first tick.
 
     loop {
@@ -25,8 +26,6 @@ pub(crate) async fn ping_pump(
             }
         }
     }
-
-    // WS_PONG_WAIT is used by read_pump's pong deadline.
 }
 
 #[cfg(test)]
@@ -40,7 +39,7 @@ mod tests {
 
         let c = cancel.clone();
         let handle = tokio::spawn(async move {
-            ping_pump(tx, c).await;
+            ping_pump(tx, Duration::from_secs(5), c).await;
         });
 
         cancel.cancel();
@@ -10,16 +10,18 @@ use tokio_tungstenite::tungstenite;
 use tokio_util::sync::CancellationToken;
 use tracing::{debug, trace, warn};
 
-use capydeploy_protocol::constants::{WS_MAX_MESSAGE_SIZE, WS_PONG_WAIT};
 use capydeploy_protocol::envelope::Message;
 
+use crate::types::HeartbeatConfig;
 use crate::ws_client::{DisconnectCallback, EventCallback};
 
 /// Reads messages from the WebSocket and dispatches them.
 ///
-/// Uses a pong deadline to detect dead connections: if no pong arrives
-/// within [`WS_PONG_WAIT`] after a ping was sent, the connection is
-/// considered dead and the loop exits (triggering reconnect).
+/// Detects dead connections: once `heartbeat.max_missed_pongs` ping
+/// periods pass without any frame from the Agent, the loop exits
+/// (triggering reconnect). Any frame counts, not just pongs, since pongs
+/// can queue behind large upload chunks on a slow link.
+#[allow(clippy::too_many_arguments)]
 pub(crate) async fn read_pump<S>(
     mut read: S,
     pending: Arc<Mutex<HashMap<String, oneshot::Sender<Message>>>>,
@@ -27,34 +29,42 @@ pub(crate) async fn read_pump<S>(
     on_disconnect: DisconnectCallback,
     agent_closed: Arc<AtomicBool>,
     write_tx: mpsc::Sender<tungstenite::Message>,
+    heartbeat: HeartbeatConfig,
+    max_size: usize,
     cancel: CancellationToken,
 ) where
     S: StreamExt<Item = Result<tungstenite::Message, tungstenite::Error>> + Unpin,
 {
-    // Pong deadline: any incoming message (not just Pong) resets the timer,
-    // matching Go's SetReadDeadline behavior. If nothing arrives within
-    // WS_PONG_WAIT the connection is considered dead.
-    let pong_deadline = tokio::time::sleep(WS_PONG_WAIT);
-    tokio::pin!(pong_deadline);
+    // Checked once per ping period; every frame read sets `heard`.
+    let mut pong_check = tokio::time::interval(heartbeat.ping_period);
+    pong_check.reset();
+    let mut heard = true;
+    let mut missed = 0;
 
     loop {
         tokio::select! {
             _ = cancel.cancelled() => break,
 
-            () = &mut pong_deadline => {
-                warn!("pong timeout — connection dead, closing");
-                break;
+            _ = pong_check.tick() => {
+                if heard {
+                    missed = 0;
+                } else {
+                    missed += 1;
+                    if missed >= heartbeat.max_missed_pongs {
+                        warn!(missed, "agent silent — connection dead, closing");
+                        break;
+                    }
+                }
+                heard = false;
             }
 
             msg = read.next() => {
                 match msg {
                     Some(Ok(msg)) => {
-                        // ANY incoming message resets the deadline (matches Go behavior).
-                        pong_deadline.as_mut().reset(tokio::time::Instant::now() + WS_PONG_WAIT);
-
+                        heard = true;
                         match msg {
                             tungstenite::Message::Text(text) => {
-                                handle_text_message(&text, &pending, &on_event).await;
+                                handle_text_message(&text, max_size, &pending, &on_event).await;
                             }
                             tungstenite::Message::Ping(data) => {
                                 trace!("received ping, sending pong");
@@ -96,12 +106,13 @@ pub(crate) async fn read_pump<S>(
 }
 
 /// Handles a text message from the WebSocket.
-async fn handle_text_message(
+pub(crate) async fn handle_text_message(
     text: &str,
+    max_size: usize,
     pending: &Arc<Mutex<HashMap<String, oneshot::Sender<Message>>>>,
     on_event: &Arc<Mutex<Option<EventCallback>>>,
 ) {
-    if text.len() > WS_MAX_MESSAGE_SIZE {
+    if text.len() > max_size {
         warn!("message too large ({} bytes), dropping", text.len());
         return;
     }
@@ -137,8 +148,9 @@ async fn handle_text_message(
 #[cfg(test)]
 mod tests {
     use super::*;
-    use capydeploy_protocol::constants::MessageType;
+    use capydeploy_protocol::constants::{MessageType, WS_MAX_MESSAGE_SIZE};
     use futures_util::stream;
+    use std::time::Duration;
 
     #[tokio::test]
     async fn handle_text_routes_response_to_pending() {
@@ -151,7 +163,7 @@ mod tests {
         let msg = Message::new::<()>("req-1", MessageType::Pong, None).unwrap();
         let json = serde_json::to_string(&msg).unwrap();
 
-        handle_text_message(&json, &pending, &on_event).await;
+        handle_text_message(&json, WS_MAX_MESSAGE_SIZE, &pending, &on_event).await;
 
         let resp = rx.await.unwrap();
         assert_eq!(resp.id, "req-1");
@@ -173,7 +185,7 @@ mod tests {
         let msg = Message::new::<()>("push-1", MessageType::TelemetryData, None).unwrap();
         let json = serde_json::to_string(&msg).unwrap();
 
-        handle_text_message(&json, &pending, &on_event).await;
+        handle_text_message(&json, WS_MAX_MESSAGE_SIZE, &pending, &on_event).await;
 
         let events = received.lock().unwrap();
         assert_eq!(events.len(), 1);
@@ -184,7 +196,13 @@ mod tests {
     async fn handle_text_ignores_malformed_json() {
         let pending = Arc::new(Mutex::new(HashMap::new()));
         let on_event: Arc<Mutex<Option<EventCallback>>> = Arc::new(Mutex::new(None));
-        handle_text_message("not valid json {{{", &pending, &on_event).await;
+        handle_text_message(
+            "not valid json {{{",
+            WS_MAX_MESSAGE_SIZE,
+            &pending,
+            &on_event,
+        )
+        .await;
     }
 
     #[tokio::test]
@@ -193,7 +211,7 @@ mod tests {
         let on_event: Arc<Mutex<Option<EventCallback>>> = Arc::new(Mutex::new(None));
 
         let huge = "x".repeat(WS_MAX_MESSAGE_SIZE + 1);
-        handle_text_message(&huge, &pending, &on_event).await;
+        handle_text_message(&huge, WS_MAX_MESSAGE_SIZE, &pending, &on_event).await;
     }
 
     #[tokio::test]
@@ -218,6 +236,8 @@ mod tests {
             on_disconnect,
             agent_closed,
             write_tx,
+            HeartbeatConfig::default(),
+            WS_MAX_MESSAGE_SIZE,
             cancel,
         )
         .await;
@@ -227,8 +247,8 @@ mod tests {
 
     #[tokio::test]
     async fn read_pump_timeout_on_silence() {
-        // With no messages arriving, the pong deadline should fire and
-        // trigger a disconnect within WS_PONG_WAIT.
+        // With no messages arriving, the missed pongs should trigger a
+        // disconnect.
         tokio::time::pause();
 
         let pending = Arc::new(Mutex::new(HashMap::new()));
@@ -253,6 +273,8 @@ mod tests {
             on_disconnect,
             agent_closed,
             write_tx,
+            HeartbeatConfig::default(),
+            WS_MAX_MESSAGE_SIZE,
             cancel,
         )
         .await;
@@ -263,74 +285,90 @@ mod tests {
         );
     }
 
-    #[tokio::test]
-    async fn read_pump_resets_deadline_on_any_message() {
-        // Sending a Text message just before the deadline should extend it.
-        tokio::time::pause();
-
+    /// Runs `read_pump` over `stream` until it exits and returns how long
+    /// it ran.
+    async fn run_until_disconnect<S>(stream: S, heartbeat: HeartbeatConfig) -> Duration
+    where
+        S: StreamExt<Item = Result<tungstenite::Message, tungstenite::Error>> + Unpin,
+    {
         let pending = Arc::new(Mutex::new(HashMap::new()));
         let on_event: Arc<Mutex<Option<EventCallback>>> = Arc::new(Mutex::new(None));
-        let disconnected = Arc::new(std::sync::Mutex::new(false));
-        let dc = disconnected.clone();
-        let on_disconnect: DisconnectCallback = Arc::new(Mutex::new(Some(Box::new(move || {
-            *dc.lock().unwrap() = true;
-        }))));
-
-        let cancel = CancellationToken::new();
+        let on_disconnect: DisconnectCallback = Arc::new(Mutex::new(None));
         let (write_tx, _write_rx) = mpsc::channel(16);
+        let agent_closed = Arc::new(AtomicBool::new(false));
 
-        // Build a stream that yields one Text message after (PONG_WAIT - 1s),
-        // then nothing until the extended deadline expires.
-        let wait_before_msg = WS_PONG_WAIT - std::time::Duration::from_secs(1);
-        let msg = Message::new::<()>("msg-1", MessageType::Pong, None).unwrap();
-        let json = serde_json::to_string(&msg).unwrap();
-        let text_msg: Result<tungstenite::Message, tungstenite::Error> =
-            Ok(tungstenite::Message::Text(json.into()));
+        let start = tokio::time::Instant::now();
+        read_pump(
+            stream,
+            pending,
+            on_event,
+            on_disconnect,
+            agent_closed,
+            write_tx,
+            heartbeat,
+            WS_MAX_MESSAGE_SIZE,
+            CancellationToken::new(),
+        )
+        .await;
+        start.elapsed()
+    }
 
-        // Delayed message followed by infinite pending. Box::pin for Unpin.
-        let delayed = stream::once(async move {
-            tokio::time::sleep(wait_before_msg).await;
-            text_msg
-        });
-        let combined = Box::pin(delayed.chain(stream::pending()));
+    /// Yields `frame()` every `period`, forever.
+    fn every(
+        period: Duration,
+        frame: fn() -> tungstenite::Message,
+    ) -> impl StreamExt<Item = Result<tungstenite::Message, tungstenite::Error>> + Unpin {
+        Box::pin(stream::unfold((), move |()| async move {
+            tokio::time::sleep(period).await;
+            Some((Ok(frame()), ()))
+        }))
+    }
 
-        let agent_closed = Arc::new(AtomicBool::new(false));
-        let handle = tokio::spawn(async move {
-            read_pump(
-                combined,
-                pending,
-                on_event,
-                on_disconnect,
-                agent_closed,
-                write_tx,
-                cancel,
-            )
-            .await;
-        });
-
-        // Advance past the original deadline — should NOT have timed out
-        // because the message resets the deadline.
-        tokio::time::advance(WS_PONG_WAIT + std::time::Duration::from_secs(1)).await;
-        // Yield multiple times so the spawned task processes the message and resets.
-        for _ in 0..10 {
-            tokio::task::yield_now().await;
+    fn fast_heartbeat() -> HeartbeatConfig {
+        HeartbeatConfig {
+            ping_period: Duration::from_millis(100),
+            max_missed_pongs: 2,
+            ..HeartbeatConfig::default()
         }
-        assert!(
-            !*disconnected.lock().unwrap(),
-            "should not disconnect — deadline was reset"
-        );
+    }
 
-        // Now advance past the reset deadline (from the message time).
-        tokio::time::advance(WS_PONG_WAIT).await;
-        for _ in 0..10 {
-            tokio::task::yield_now().await;
-        }
+    #[tokio::test]
+    async fn read_pump_other_traffic_keeps_connection_alive() {
+        tokio::time::pause();
 
-        // With paused time the spawned task should have completed.
-        handle.await.unwrap();
-        assert!(
-            *disconnected.lock().unwrap(),
-            "should disconnect after extended deadline"
-        );
+        // Responses keep coming while pongs are stuck behind upload chunks.
+        let text = || {
+            let msg = Message::new::<()>("push", MessageType::TelemetryData, None).unwrap();
+            tungstenite::Message::Text(serde_json::to_string(&msg).unwrap().into())
+        };
+        let texts = every(Duration::from_millis(90), text).take(20);
+        let ran = run_until_disconnect(Box::pin(texts), fast_heartbeat()).await;
+
+        // Ends when the stream does, not on a timeout.
+        assert!(ran >= Duration::from_millis(1800), "ran {ran:?}");
+    }
+
+    #[tokio::test]
+    async fn read_pump_default_window_is_a_minute() {
+        tokio::time::pause();
+
+        let silence = stream::pending::<Result<tungstenite::Message, tungstenite::Error>>();
+        let ran = run_until_disconnect(silence, HeartbeatConfig::default()).await;
+
+        // The first check passes on the initial credit.
+        assert!(ran >= Duration::from_secs(60), "ran {ran:?}");
+        assert!(ran <= Duration::from_secs(65), "ran {ran:?}");
+    }
+
+    #[tokio::test]
+    async fn read_pump_pongs_keep_connection_alive() {
+        tokio::time::pause();
+
+        let pong = || tungstenite::Message::Pong(vec![].into());
+        let pongs = every(Duration::from_millis(90), pong).take(20);
+        let ran = run_until_disconnect(Box::pin(pongs), fast_heartbeat()).await;
+
+        // Ends when the stream does, not on a pong timeout.
+        assert!(ran >= Duration::from_millis(1800), "ran {ran:?}");
     }
 }
@@ -4,9 +4,10 @@
 //! setup, and the reconnect loop.
 
 use std::collections::HashMap;
+use std::net::IpAddr;
 use std::pin::Pin;
 use std::sync::Arc;
-use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::atomic::Ordering;
 use std::time::Duration;
 
 use tokio::sync::{Mutex, RwLock, mpsc};
@@ -15,15 +16,24 @@ use tracing::{debug, info, trace, warn};
 
 use capydeploy_discovery::client::Client as DiscoveryClient;
 use capydeploy_discovery::types::DiscoveredAgent;
-use capydeploy_protocol::messages::HubConnectedRequest;
+use capydeploy_protocol::constants::MessageType;
+use capydeploy_protocol::messages::ConfigChangedEvent;
+use capydeploy_protocol::profile::{ProtocolOffer, ProtocolProfile};
 
 use crate::pairing::TokenStore;
 use crate::types::{
-    ConnectedAgent, ConnectionEvent, ConnectionState, HubIdentity, MAX_NO_MDNS_ATTEMPTS,
-    ReconnectConfig,
+    ConnectedAgent, ConnectionEvent, ConnectionState, HeartbeatConfig, HubIdentity,
+    MAX_NO_MDNS_ATTEMPTS, ReconnectConfig,
 };
 use crate::ws_client::{HandshakeResult, WsClient};
 
+/// WebSocket clients keyed by agent ID. A plain mutex so the synchronous
+/// disconnect callback can check which client is current.
+pub(crate) type ClientMap = std::sync::Mutex<HashMap<String, Arc<WsClient>>>;
+
+/// Cancel tokens of the running reconnect loops, keyed by agent ID.
+pub(crate) type ReconnectTokens = std::sync::Mutex<HashMap<String, CancellationToken>>;
+
 /// Shared state passed to free functions for WebSocket callback setup
 /// and reconnection. Avoids threading 12 separate Arc parameters.
 #[derive(Clone)]
@@ -31,49 +41,65 @@ pub(crate) struct WsContext {
     pub(crate) hub: HubIdentity,
     pub(crate) token_store: Option<Arc<TokenStore>>,
     pub(crate) discovered: Arc<RwLock<HashMap<String, DiscoveredAgent>>>,
-    pub(crate) connected: Arc<RwLock<Option<(String, ConnectedAgent)>>>,
-    pub(crate) ws_client: Arc<Mutex<Option<WsClient>>>,
+    pub(crate) connected: Arc<RwLock<HashMap<String, ConnectedAgent>>>,
+    pub(crate) ws_clients: Arc<ClientMap>,
     pub(crate) state: Arc<RwLock<HashMap<String, ConnectionState>>>,
     pub(crate) events_tx: mpsc::Sender<ConnectionEvent>,
-    pub(crate) reconnect_cancel: Arc<std::sync::Mutex<Option<(String, CancellationToken)>>>,
-    pub(crate) manual_disconnect: Arc<AtomicBool>,
+    pub(crate) reconnect_cancel: Arc<ReconnectTokens>,
     pub(crate) reconnect_config: ReconnectConfig,
-    pub(crate) last_known_addr: Arc<Mutex<Option<(String, DiscoveredAgent)>>>,
+    pub(crate) heartbeat: HeartbeatConfig,
+    pub(crate) last_known_addr: Arc<Mutex<HashMap<String, (String, DiscoveredAgent)>>>,
 }
 
-/// Cancels any active reconnect loop regardless of agent ID.
-pub(crate) fn cancel_any_reconnect(
-    reconnect_cancel: &std::sync::Mutex<Option<(String, CancellationToken)>>,
-) {
-    if let Ok(mut guard) = reconnect_cancel.lock()
-        && let Some((_, token)) = guard.take()
-    {
-        token.cancel();
-    }
-}
-
-/// Cancels the reconnect loop only if it targets the given agent.
-pub(crate) fn cancel_reconnect_for(
-    reconnect_cancel: &std::sync::Mutex<Option<(String, CancellationToken)>>,
-    agent_id: &str,
-) {
+/// Cancels every active reconnect loop.
+pub(crate) fn cancel_any_reconnect(reconnect_cancel: &ReconnectTokens) {
     if let Ok(mut guard) = reconnect_cancel.lock() {
-        let matches = guard.as_ref().is_some_and(|(id, _)| id == agent_id);
-        if matches && let Some((_, token)) = guard.take() {
+        for (_, token) in guard.drain() {
             token.cancel();
         }
     }
 }
 
+/// Cancels the reconnect loop of the given agent, if any.
+pub(crate) fn cancel_reconnect_for(reconnect_cancel: &ReconnectTokens, agent_id: &str) {
+    if let Ok(mut guard) = reconnect_cancel.lock()
+        && let Some(token) = guard.remove(agent_id)
+    {
+        token.cancel();
+    }
+}
+
 /// Sets up event forwarding and disconnect callbacks (with reconnect logic)
 /// on a [`WsClient`].
-pub(crate) async fn setup_ws_callbacks(client: &WsClient, agent_id: &str, ctx: WsContext) {
+pub(crate) async fn setup_ws_callbacks(client: &Arc<WsClient>, agent_id: &str, ctx: WsContext) {
     // Event forwarding callback.
     let events_tx = ctx.events_tx.clone();
+    let connected = ctx.connected.clone();
     let agent_id_ev = agent_id.to_string();
     client
         .set_event_callback(Box::new(move |msg_type, message| {
             trace!(msg_type = ?msg_type, agent = %agent_id_ev, "forwarding agent event to hub event loop");
+            if msg_type == MessageType::ConfigChanged {
+                // Update the cached settings before anyone hears of the change.
+                let (connected, events_tx) = (connected.clone(), events_tx.clone());
+                let agent_id = agent_id_ev.clone();
+                tokio::spawn(async move {
+                    if let Ok(Some(event)) = message.parse_payload::<ConfigChangedEvent>()
+                        && let Some(agent) = connected.write().await.get_mut(&agent_id)
+                    {
+                        debug!(agent = %agent_id, install_path = %event.install_path, "agent config changed");
+                        agent.apply_config_change(&event);
+                    }
+                    let _ = events_tx
+                        .send(ConnectionEvent::AgentEvent {
+                            agent_id,
+                            msg_type,
+                            message,
+                        })
+                        .await;
+                });
+                return;
+            }
             match events_tx.try_send(ConnectionEvent::AgentEvent {
                 agent_id: agent_id_ev.clone(),
                 msg_type,
@@ -85,54 +111,143 @@ pub(crate) async fn setup_ws_callbacks(client: &WsClient, agent_id: &str, ctx: W
         }))
         .await;
 
-    // Disconnect callback — handles manual, agent-revoked, and unexpected disconnects.
+    // Disconnect callback — handles agent-revoked and unexpected disconnects.
+    // A client the manager closed or replaced on purpose is no longer in
+    // `ws_clients`; the manager has already updated its state.
     let agent_id_dc = agent_id.to_string();
     let agent_closed = client.agent_closed();
+    let this = Arc::downgrade(client);
     let ctx_dc = ctx;
     client
         .set_disconnect_callback(Box::new(move || {
             let id = agent_id_dc.clone();
 
-            // Always clear connected state.
+            let current = ctx_dc.ws_clients.lock().is_ok_and(|clients| {
+                clients
+                    .get(&id)
+                    .is_some_and(|c| std::ptr::eq(Arc::as_ptr(c), this.as_ptr()))
+            });
+            if !current {
+                return;
+            }
+
             if let Ok(mut c) = ctx_dc.connected.try_write() {
-                *c = None;
+                c.remove(&id);
             }
 
-            let no_reconnect = ctx_dc.manual_disconnect.load(Ordering::Relaxed)
-                || agent_closed.load(Ordering::Relaxed);
+            let no_reconnect = agent_closed.load(Ordering::Relaxed);
 
-            if no_reconnect {
-                // User-initiated or agent-revoked disconnect — no reconnect.
-                if let Ok(mut s) = ctx_dc.state.try_write() {
-                    s.insert(id.clone(), ConnectionState::Disconnected);
-                }
-                let _ = ctx_dc.events_tx.try_send(ConnectionEvent::StateChanged {
-                    agent_id: id,
-                    state: ConnectionState::Disconnected,
-                });
+            // Agent-revoked disconnects are final; an unexpected one goes
+            // straight to Reconnecting, so the UI never shows the agent as
+            // disconnected in between.
+            let state = if no_reconnect {
+                ConnectionState::Disconnected
             } else {
-                // Unexpected disconnect — emit Disconnected then spawn reconnect.
-                if let Ok(mut s) = ctx_dc.state.try_write() {
-                    s.insert(id.clone(), ConnectionState::Disconnected);
-                }
-                let _ = ctx_dc.events_tx.try_send(ConnectionEvent::StateChanged {
-                    agent_id: id.clone(),
-                    state: ConnectionState::Disconnected,
-                });
-
-                // Create a cancellation token and store it.
-                let cancel = CancellationToken::new();
-                cancel_any_reconnect(&ctx_dc.reconnect_cancel);
-                if let Ok(mut guard) = ctx_dc.reconnect_cancel.lock() {
-                    *guard = Some((id.clone(), cancel.clone()));
-                }
+                ConnectionState::Reconnecting { attempt: 0 }
+            };
+            if let Ok(mut s) = ctx_dc.state.try_write() {
+                s.insert(id.clone(), state.clone());
+            }
+            let _ = ctx_dc.events_tx.try_send(ConnectionEvent::StateChanged {
+                agent_id: id.clone(),
+                state,
+            });
 
-                tokio::spawn(reconnect_loop(id, ctx_dc.clone(), cancel));
+            if !no_reconnect {
+                spawn_reconnect(&ctx_dc, &id);
             }
         }))
         .await;
 }
 
+/// Starts (or restarts) the reconnect loop of `agent_id`, cancelling any
+/// loop already running for it.
+fn spawn_reconnect(ctx: &WsContext, agent_id: &str) {
+    let cancel = CancellationToken::new();
+    cancel_reconnect_for(&ctx.reconnect_cancel, agent_id);
+    if let Ok(mut guard) = ctx.reconnect_cancel.lock() {
+        guard.insert(agent_id.to_string(), cancel.clone());
+    }
+    tokio::spawn(reconnect_loop(agent_id.to_string(), ctx.clone(), cancel));
+}
+
+/// Reacts to a discovery update for a connected or reconnecting agent whose
+/// addresses no longer match the ones the Hub dials.
+///
+/// A connected agent just gets its records updated, so later connections
+/// (data channel, reconnects) use the new address. A reconnecting one is
+/// re-dialled on the new address at once instead of waiting out its
+/// backoff. Emits [`ConnectionEvent::AgentMoved`] in both cases.
+pub(crate) async fn handle_agent_moved(ctx: &WsContext, agent: &DiscoveredAgent) {
+    let id = &agent.info.id;
+    if agent.ips.is_empty() {
+        return;
+    }
+    let state = ctx.state.read().await.get(id).cloned();
+    let old_ips = match state {
+        Some(ConnectionState::Connected) => match ctx.connected.read().await.get(id) {
+            Some(c) => c.agent.ips.clone(),
+            None => return,
+        },
+        Some(ConnectionState::Reconnecting { .. }) => {
+            match ctx.last_known_addr.lock().await.get(id) {
+                Some((_, a)) => a.ips.clone(),
+                None => return,
+            }
+        }
+        _ => return,
+    };
+    if same_ips(&old_ips, &agent.ips) {
+        return;
+    }
+
+    info!(agent = %id, old = ?old_ips, new = ?agent.ips, "agent moved to a new address");
+    ctx.last_known_addr
+        .lock()
+        .await
+        .insert(id.clone(), (agent.websocket_address(), agent.clone()));
+    if matches!(state, Some(ConnectionState::Connected)) {
+        if let Some(c) = ctx.connected.write().await.get_mut(id) {
+            c.agent = agent.clone();
+        }
+    } else {
+        spawn_reconnect(ctx, id);
+    }
+
+    let _ = ctx
+        .events_tx
+        .send(ConnectionEvent::AgentMoved {
+            agent_id: id.clone(),
+            old_ips,
+            new_ips: agent.ips.clone(),
+        })
+        .await;
+}
+
+/// Whether both lists hold the same addresses, in any order.
+fn same_ips(a: &[IpAddr], b: &[IpAddr]) -> bool {
+    let (mut a, mut b) = (a.to_vec(), b.to_vec());
+    a.sort();
+    b.sort();
+    a == b
+}
+
+/// Ends a reconnect loop that can't succeed, leaving the agent
+/// [`ConnectionState::Lost`].
+async fn give_up(ctx: &WsContext, agent_id: &str) {
+    ctx.state
+        .write()
+        .await
+        .insert(agent_id.to_string(), ConnectionState::Lost);
+    let _ = ctx
+        .events_tx
+        .send(ConnectionEvent::StateChanged {
+            agent_id: agent_id.to_string(),
+            state: ConnectionState::Lost,
+        })
+        .await;
+}
+
 /// Reconnection loop with exponential backoff.
 ///
 /// Returns a boxed future to break the recursive type cycle with
@@ -206,37 +321,17 @@ pub(crate) fn reconnect_loop(
                 no_mdns_count += 1;
                 if no_mdns_count > MAX_NO_MDNS_ATTEMPTS {
                     info!(agent = %agent_id, "too many attempts without mDNS, stopping reconnect");
-                    ctx.state
-                        .write()
-                        .await
-                        .insert(agent_id.clone(), ConnectionState::Disconnected);
-                    let _ = ctx
-                        .events_tx
-                        .send(ConnectionEvent::StateChanged {
-                            agent_id: agent_id.clone(),
-                            state: ConnectionState::Disconnected,
-                        })
-                        .await;
+                    give_up(&ctx, &agent_id).await;
                     break;
                 }
-                match ctx.last_known_addr.lock().await.clone() {
+                match ctx.last_known_addr.lock().await.get(&agent_id).cloned() {
                     Some((addr, agent)) => {
                         debug!(agent = %agent_id, no_mdns_count, "using last known address");
                         (addr, agent)
                     }
                     None => {
                         info!(agent = %agent_id, "no mDNS and no last known address, stopping reconnect");
-                        ctx.state
-                            .write()
-                            .await
-                            .insert(agent_id.clone(), ConnectionState::Disconnected);
-                        let _ = ctx
-                            .events_tx
-                            .send(ConnectionEvent::StateChanged {
-                                agent_id: agent_id.clone(),
-                                state: ConnectionState::Disconnected,
-                            })
-                            .await;
+                        give_up(&ctx, &agent_id).await;
                         break;
                     }
                 }
@@ -247,28 +342,69 @@ pub(crate) fn reconnect_loop(
                 .and_then(|s| s.get_token(&agent_id))
                 .unwrap_or_default();
 
-            let hub_req = HubConnectedRequest {
-                name: ctx.hub.name.clone(),
-                version: ctx.hub.version.clone(),
-                platform: ctx.hub.platform.clone(),
-                hub_id: ctx.hub.hub_id.clone(),
-                token,
-                protocol_version: capydeploy_protocol::constants::PROTOCOL_VERSION,
-            };
+            let hub_req = ctx.hub.hello(token);
 
-            match WsClient::connect(&ws_url, &hub_req).await {
+            let sent_at = crate::clock::unix_millis();
+            match WsClient::connect(&ws_url, &hub_req, &ctx.heartbeat).await {
                 Ok((client, HandshakeResult::Connected(status))) => {
+                    crate::clock::report_skew(
+                        &ctx.events_tx,
+                        &agent_id,
+                        status.agent_time,
+                        sent_at,
+                        crate::clock::unix_millis(),
+                    )
+                    .await;
+
+                    // The agent may have been updated while we were away.
+                    let profile = match ProtocolProfile::negotiate(
+                        &ProtocolOffer::from_hub(&hub_req),
+                        &ProtocolOffer::from_agent(&status),
+                    ) {
+                        Ok(profile) => profile,
+                        Err(reason) => {
+                            client.close().await;
+                            warn!(agent = %agent_id, "protocol incompatible, stopping reconnect: {reason}");
+                            give_up(&ctx, &agent_id).await;
+                            break;
+                        }
+                    };
+
+                    crate::pairing_flow::store_renewed_token(
+                        ctx.token_store.as_deref(),
+                        &agent_id,
+                        &status,
+                    );
+
                     // Set up callbacks on the new client (including reconnect on future disconnect).
+                    client.set_profile(profile.clone());
+                    let client = Arc::new(client);
                     setup_ws_callbacks(&client, &agent_id, ctx.clone()).await;
 
+                    // The user disconnected while the handshake ran.
+                    if cancel.is_cancelled() {
+                        client.close().await;
+                        return;
+                    }
+
                     let connected_agent = ConnectedAgent {
                         agent: fallback_agent.clone(),
                         status,
+                        profile,
+                        config: None,
                     };
 
-                    *ctx.ws_client.lock().await = Some(client);
-                    *ctx.connected.write().await = Some((agent_id.clone(), connected_agent));
-                    *ctx.last_known_addr.lock().await = Some((ws_url, fallback_agent));
+                    if let Ok(mut clients) = ctx.ws_clients.lock() {
+                        clients.insert(agent_id.clone(), client);
+                    }
+                    ctx.connected
+                        .write()
+                        .await
+                        .insert(agent_id.clone(), connected_agent);
+                    ctx.last_known_addr
+                        .lock()
+                        .await
+                        .insert(agent_id.clone(), (ws_url, fallback_agent));
                     ctx.state
                         .write()
                         .await
@@ -289,17 +425,7 @@ pub(crate) fn reconnect_loop(
                     // Token invalid — user must re-pair manually.
                     client.close().await;
                     warn!(agent = %agent_id, "agent requires re-pairing, stopping reconnect");
-                    ctx.state
-                        .write()
-                        .await
-                        .insert(agent_id.clone(), ConnectionState::Disconnected);
-                    let _ = ctx
-                        .events_tx
-                        .send(ConnectionEvent::StateChanged {
-                            agent_id: agent_id.clone(),
-                            state: ConnectionState::Disconnected,
-                        })
-                        .await;
+                    give_up(&ctx, &agent_id).await;
                     break;
                 }
                 Err(e) => {
@@ -319,12 +445,12 @@ pub(crate) fn reconnect_loop(
             }
         }
 
-        // Clean up the cancel token if it's still ours.
-        if let Ok(mut guard) = ctx.reconnect_cancel.lock() {
-            let ours = guard.as_ref().is_some_and(|(id, _)| id == &agent_id);
-            if ours {
-                *guard = None;
-            }
+        // Clean up the cancel token if it's still ours: a newer loop for
+        // this agent always cancels the previous one first.
+        if !cancel.is_cancelled()
+            && let Ok(mut guard) = ctx.reconnect_cancel.lock()
+        {
+            guard.remove(&agent_id);
         }
     }) // Box::pin
 }
@@ -334,31 +460,48 @@ mod tests {
     use super::*;
 
     #[test]
-    fn cancel_any_reconnect_clears_token() {
-        let cancel = Arc::new(std::sync::Mutex::new(None));
-        let token = CancellationToken::new();
-        *cancel.lock().unwrap() = Some(("agent-1".into(), token.clone()));
+    fn cancel_any_reconnect_clears_tokens() {
+        let cancel = ReconnectTokens::default();
+        let (t1, t2) = (CancellationToken::new(), CancellationToken::new());
+        cancel.lock().unwrap().insert("agent-1".into(), t1.clone());
+        cancel.lock().unwrap().insert("agent-2".into(), t2.clone());
 
         cancel_any_reconnect(&cancel);
 
-        assert!(cancel.lock().unwrap().is_none());
-        assert!(token.is_cancelled());
+        assert!(cancel.lock().unwrap().is_empty());
+        assert!(t1.is_cancelled());
+        assert!(t2.is_cancelled());
+    }
+
+    #[test]
+    fn same_ips_ignores_order() {
+        let a: Vec<IpAddr> = vec!["10.0.0.2".parse().unwrap(), "fe80::1".parse().unwrap()];
+        let b: Vec<IpAddr> = vec!["fe80::1".parse().unwrap(), "10.0.0.2".parse().unwrap()];
+        assert!(same_ips(&a, &b));
+        assert!(!same_ips(&a, &b[..1]));
+        assert!(!same_ips(
+            &a,
+            &["10.0.0.3".parse().unwrap(), "fe80::1".parse().unwrap()]
+        ));
     }
 
     #[test]
     fn cancel_reconnect_for_only_targets_matching_agent() {
-        let cancel = Arc::new(std::sync::Mutex::new(None));
+        let cancel = ReconnectTokens::default();
         let token = CancellationToken::new();
-        *cancel.lock().unwrap() = Some(("agent-1".into(), token.clone()));
+        cancel
+            .lock()
+            .unwrap()
+            .insert("agent-1".into(), token.clone());
 
         // Wrong agent — should not cancel.
         cancel_reconnect_for(&cancel, "agent-2");
-        assert!(cancel.lock().unwrap().is_some());
+        assert_eq!(cancel.lock().unwrap().len(), 1);
         assert!(!token.is_cancelled());
 
         // Right agent — should cancel.
         cancel_reconnect_for(&cancel, "agent-1");
-        assert!(cancel.lock().unwrap().is_none());
+        assert!(cancel.lock().unwrap().is_empty());
         assert!(token.is_cancelled());
     }
 }
@@ -1,11 +1,20 @@
 //! Public types for the hub connection manager.
 
+use std::net::IpAddr;
 use std::time::Duration;
 
 use capydeploy_discovery::types::DiscoveredAgent;
-use capydeploy_protocol::constants::MessageType;
+use capydeploy_protocol::constants::{
+    CAPABILITY_AGENT_LOGS, CAPABILITY_ARTWORK_BATCH, CAPABILITY_CHUNKED_ARTWORK,
+    CAPABILITY_DELETE_BATCH, CAPABILITY_DISK_USAGE, CAPABILITY_FILE_BROWSER,
+    CAPABILITY_PAUSE_UPLOAD, CAPABILITY_TCP_DATA_CHANNEL, MessageType, WS_MAX_MESSAGE_SIZE,
+    WS_PING_PERIOD, WS_PONG_WAIT,
+};
 use capydeploy_protocol::envelope::Message;
-use capydeploy_protocol::messages::AgentStatusResponse;
+use capydeploy_protocol::messages::{
+    AgentStatusResponse, ConfigChangedEvent, ConfigResponse, HubConnectedRequest,
+};
+use capydeploy_protocol::profile::{ProtocolOffer, ProtocolProfile};
 
 /// Connection state for an Agent.
 #[derive(Debug, Clone, PartialEq)]
@@ -18,10 +27,28 @@ pub enum ConnectionState {
     Connected,
     /// Agent requires pairing before connection.
     PairingRequired,
-    /// Connection lost, attempting to reconnect.
+    /// Connection dropped, attempting to reconnect. `attempt` is 0 until
+    /// the first retry.
     Reconnecting { attempt: u32 },
-    /// Connection lost.
+    /// Not connected, by choice or before any connection.
     Disconnected,
+    /// Connection dropped and reconnecting gave up.
+    Lost,
+}
+
+impl ConnectionState {
+    /// Name of the state as shown to the user: `disconnected`,
+    /// `connecting`, `pairing`, `connected`, `reconnecting` or `lost`.
+    pub fn name(&self) -> &'static str {
+        match self {
+            Self::Discovered | Self::Disconnected => "disconnected",
+            Self::Connecting => "connecting",
+            Self::PairingRequired => "pairing",
+            Self::Connected => "connected",
+            Self::Reconnecting { .. } => "reconnecting",
+            Self::Lost => "lost",
+        }
+    }
 }
 
 /// An Agent that has been connected.
@@ -29,6 +56,30 @@ pub enum ConnectionState {
 pub struct ConnectedAgent {
     pub agent: DiscoveredAgent,
     pub status: AgentStatusResponse,
+    /// Protocol settings negotiated with this Agent. Consult this rather
+    /// than the raw `status` fields when gating features.
+    pub profile: ProtocolProfile,
+    /// Agent settings, once read with `get_config` or pushed by the Agent.
+    pub config: Option<ConfigResponse>,
+}
+
+impl ConnectedAgent {
+    /// Applies settings the Agent pushed in a `config_changed` event.
+    pub fn apply_config_change(&mut self, event: &ConfigChangedEvent) {
+        self.config = Some(ConfigResponse {
+            install_path: event.install_path.clone(),
+        });
+        self.status.telemetry_enabled = event.telemetry_enabled;
+        self.status.telemetry_interval = event.telemetry_interval;
+        self.status.console_log_enabled = event.console_log_enabled;
+        if !event.name.is_empty() {
+            self.status.name = event.name.clone();
+            self.agent.info.name = event.name.clone();
+        }
+        if let Some(accept) = event.accept_connections {
+            self.status.accept_connections = accept;
+        }
+    }
 }
 
 /// Events emitted by the connection manager.
@@ -65,6 +116,26 @@ pub enum ConnectionEvent {
     },
     /// Agent's protocol version is deprecated (still works, but outdated).
     ProtocolWarning { agent_id: String, message: String },
+    /// The Agent's clock differs from the Hub's by more than
+    /// [`CLOCK_SKEW_WARN`](crate::clock::CLOCK_SKEW_WARN).
+    ClockSkew {
+        agent_id: String,
+        /// Agent minus Hub, in milliseconds.
+        offset_ms: i64,
+        message: String,
+    },
+    /// The Hub's local network changed; discovered agents were flushed and
+    /// mDNS browsing restarted. Carries the new local addresses.
+    NetworkChanged { addresses: Vec<IpAddr> },
+    /// Discovery was paused or resumed.
+    DiscoveryPaused(bool),
+    /// A connected or reconnecting Agent showed up on new addresses; the
+    /// Hub now dials it there.
+    AgentMoved {
+        agent_id: String,
+        old_ips: Vec<IpAddr>,
+        new_ips: Vec<IpAddr>,
+    },
 }
 
 /// Configuration for automatic reconnection with exponential backoff.
@@ -109,6 +180,33 @@ impl ReconnectConfig {
     }
 }
 
+/// Outgoing frames queued for an Agent before senders wait for room.
+pub const DEFAULT_SEND_BUFFER: usize = 256;
+
+/// Keepalive timings and send queue for a connection to an Agent.
+#[derive(Debug, Clone)]
+pub struct HeartbeatConfig {
+    /// How often the Hub pings the Agent.
+    pub ping_period: Duration,
+    /// Consecutive ping periods without any frame from the Agent after
+    /// which the connection is considered dead.
+    pub max_missed_pongs: u32,
+    /// Frames queued for the write pump. A full queue makes requests wait,
+    /// within their timeout, rather than fail.
+    pub send_buffer: usize,
+}
+
+impl Default for HeartbeatConfig {
+    fn default() -> Self {
+        Self {
+            ping_period: WS_PING_PERIOD,
+            // A minute of silence, as the Agent allows the Hub.
+            max_missed_pongs: (WS_PONG_WAIT.as_secs() / WS_PING_PERIOD.as_secs()) as u32,
+            send_buffer: DEFAULT_SEND_BUFFER,
+        }
+    }
+}
+
 /// Hub identity used in connection handshakes.
 #[derive(Debug, Clone)]
 pub struct HubIdentity {
@@ -118,6 +216,38 @@ pub struct HubIdentity {
     pub hub_id: String,
 }
 
+/// Optional capabilities the Hub advertises in `hub_connected`.
+pub const HUB_CAPABILITIES: &[&str] = &[
+    CAPABILITY_TCP_DATA_CHANNEL,
+    CAPABILITY_FILE_BROWSER,
+    CAPABILITY_CHUNKED_ARTWORK,
+    CAPABILITY_ARTWORK_BATCH,
+    CAPABILITY_DELETE_BATCH,
+    CAPABILITY_DISK_USAGE,
+    CAPABILITY_AGENT_LOGS,
+    CAPABILITY_PAUSE_UPLOAD,
+];
+
+impl HubIdentity {
+    /// Builds the `hub_connected` handshake, including the Hub's protocol
+    /// offer. The Hub reads messages up to [`WS_MAX_MESSAGE_SIZE`].
+    pub(crate) fn hello(&self, token: String) -> HubConnectedRequest {
+        let offer = ProtocolOffer::local(HUB_CAPABILITIES, WS_MAX_MESSAGE_SIZE as u64);
+        HubConnectedRequest {
+            name: self.name.clone(),
+            version: self.version.clone(),
+            platform: self.platform.clone(),
+            hub_id: self.hub_id.clone(),
+            token,
+            protocol_version: offer.version,
+            capabilities: offer.capabilities,
+            framing_version: offer.framing_version,
+            compression: offer.compression,
+            max_binary_frame_size: offer.max_binary_frame_size,
+        }
+    }
+}
+
 /// Maximum reconnect attempts without mDNS visibility before giving up.
 pub(crate) const MAX_NO_MDNS_ATTEMPTS: u32 = 30;
 
@@ -165,4 +295,21 @@ mod tests {
             );
         }
     }
+
+    #[test]
+    fn hello_advertises_read_limit() {
+        let hub = HubIdentity {
+            name: "Hub".into(),
+            version: "1.0".into(),
+            platform: "linux".into(),
+            hub_id: "h".into(),
+        };
+        let req = hub.hello(String::new());
+        assert_eq!(req.max_binary_frame_size, WS_MAX_MESSAGE_SIZE as u64);
+        assert!(
+            req.capabilities
+                .iter()
+                .any(|c| c == CAPABILITY_TCP_DATA_CHANNEL)
+        );
+    }
 }
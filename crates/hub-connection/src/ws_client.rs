@@ -4,20 +4,28 @@
 //! ping/pong keepalive, and push event dispatching.
 
 use std::collections::HashMap;
-use std::sync::Arc;
 use std::sync::atomic::AtomicBool;
+use std::sync::{Arc, RwLock};
 
 use futures_util::StreamExt;
 use tokio::sync::{Mutex, mpsc, oneshot};
 use tokio_tungstenite::tungstenite;
 
 use capydeploy_protocol::constants::{
-    MessageType, WS_BINARY_REQUEST_TIMEOUT, WS_MAX_MESSAGE_SIZE, WS_REQUEST_TIMEOUT,
+    MessageType, PROTOCOL_MIN_SUPPORTED, PROTOCOL_VERSION, ProtocolCompatibility,
+    WS_BINARY_REQUEST_TIMEOUT, WS_ERR_CODE_NOT_ACCEPTED, WS_ERR_CODE_NOT_FOUND,
+    WS_ERR_CODE_NOT_IMPLEMENTED, WS_ERR_CODE_UNAUTHORIZED, WS_ERR_CODE_UPLOAD_PAUSED,
+    WS_MAX_MESSAGE_SIZE, WS_REQUEST_TIMEOUT, binary_frame_limit, check_protocol_compatibility,
 };
 use capydeploy_protocol::envelope::Message;
 use capydeploy_protocol::messages::{
-    AgentStatusResponse, HubConnectedRequest, PairSuccessResponse, PairingRequiredResponse,
+    AgentStatusResponse, HubConnectedRequest, InfoResponse, PairSuccessResponse,
+    PairingRequiredResponse,
 };
+use capydeploy_protocol::profile::ProtocolProfile;
+use capydeploy_protocol::types::AgentInfo;
+
+use crate::types::HeartbeatConfig;
 
 /// Errors from the WebSocket client.
 #[derive(Debug, thiserror::Error)]
@@ -39,6 +47,114 @@ pub enum WsError {
 
     #[error("agent error {code}: {message}")]
     AgentError { code: i32, message: String },
+
+    /// An Agent error whose reply carried details the Hub can act on,
+    /// such as the shortcut an upload conflicts with.
+    #[error("agent error {code}: {message}")]
+    AgentErrorDetails {
+        code: i32,
+        message: String,
+        details: Box<serde_json::value::RawValue>,
+    },
+}
+
+impl WsError {
+    /// The `WS_ERR_CODE_*` the Agent answered with, if it did.
+    pub fn agent_code(&self) -> Option<i32> {
+        match self {
+            Self::AgentError { code, .. } | Self::AgentErrorDetails { code, .. } => Some(*code),
+            _ => None,
+        }
+    }
+
+    /// The details the Agent attached to its error, if they parse as `T`.
+    pub fn details<T: serde::de::DeserializeOwned>(&self) -> Option<T> {
+        match self {
+            Self::AgentErrorDetails { details, .. } => serde_json::from_str(details.get()).ok(),
+            _ => None,
+        }
+    }
+
+    /// The Agent no longer accepts this Hub; it has to pair again.
+    pub fn is_unauthorized(&self) -> bool {
+        self.agent_code() == Some(WS_ERR_CODE_UNAUTHORIZED)
+    }
+
+    pub fn is_not_found(&self) -> bool {
+        self.agent_code() == Some(WS_ERR_CODE_NOT_FOUND)
+    }
+
+    /// The Agent is too old for the request.
+    pub fn is_not_implemented(&self) -> bool {
+        self.agent_code() == Some(WS_ERR_CODE_NOT_IMPLEMENTED)
+    }
+
+    /// A chunk was refused because its upload is paused on the Agent.
+    pub fn is_upload_paused(&self) -> bool {
+        self.agent_code() == Some(WS_ERR_CODE_UPLOAD_PAUSED)
+    }
+
+    /// No reply in time; the connection may still be fine.
+    pub fn is_timeout(&self) -> bool {
+        matches!(self, Self::Timeout)
+    }
+}
+
+/// Checks an Agent's protocol version against this Hub's supported range.
+pub(crate) fn check_agent_protocol(peer_version: u32) -> Result<(), WsError> {
+    match check_protocol_compatibility(peer_version) {
+        ProtocolCompatibility::Incompatible { peer_version, .. } => {
+            Err(incompatible_agent(peer_version))
+        }
+        _ => Ok(()),
+    }
+}
+
+/// The error for an Agent outside this Hub's protocol range, saying which
+/// side needs updating.
+pub(crate) fn incompatible_agent(peer_version: u32) -> WsError {
+    let message = if peer_version < PROTOCOL_MIN_SUPPORTED {
+        format!(
+            "agent is too old (protocol v{peer_version}, this Hub needs at least \
+             v{PROTOCOL_MIN_SUPPORTED}); please update the agent"
+        )
+    } else {
+        format!(
+            "agent is newer than this Hub (protocol v{peer_version}, this Hub speaks \
+             v{PROTOCOL_VERSION}); please update the Hub"
+        )
+    };
+    WsError::AgentError {
+        code: WS_ERR_CODE_NOT_ACCEPTED,
+        message,
+    }
+}
+
+/// The error for an Agent reply carrying `err`, keeping any details.
+fn agent_error(err: &capydeploy_protocol::envelope::WsError, resp: &Message) -> WsError {
+    match &resp.payload {
+        Some(details) => WsError::AgentErrorDetails {
+            code: err.code,
+            message: err.message.clone(),
+            details: details.clone(),
+        },
+        None => WsError::AgentError {
+            code: err.code,
+            message: err.message.clone(),
+        },
+    }
+}
+
+/// The error for a request the Agent doesn't handle.
+fn unsupported(msg_type: &MessageType) -> WsError {
+    let name = serde_json::to_value(msg_type)
+        .ok()
+        .and_then(|v| v.as_str().map(str::to_string))
+        .unwrap_or_else(|| format!("{msg_type:?}"));
+    WsError::AgentError {
+        code: WS_ERR_CODE_NOT_IMPLEMENTED,
+        message: format!("agent does not support `{name}`; please update the agent"),
+    }
 }
 
 /// Result of the initial handshake with an Agent.
@@ -69,6 +185,9 @@ pub struct WsClient {
     /// with [`WS_CLOSE_TOKEN_REVOKED`]. The disconnect callback checks
     /// this to suppress automatic reconnection.
     agent_closed: Arc<AtomicBool>,
+    /// Negotiated once the handshake completes; until then requests are
+    /// not checked against it.
+    profile: RwLock<Option<ProtocolProfile>>,
     _read_handle: tokio::task::JoinHandle<()>,
     _write_handle: tokio::task::JoinHandle<()>,
     _ping_handle: tokio::task::JoinHandle<()>,
@@ -83,15 +202,52 @@ impl WsClient {
     pub async fn connect(
         url: &str,
         hub_request: &HubConnectedRequest,
+        heartbeat: &HeartbeatConfig,
     ) -> Result<(Self, HandshakeResult), WsError> {
+        // Read what we advertised; the Agent sends no more than that.
+        let max_size = binary_frame_limit(hub_request.max_binary_frame_size);
+        let client = Self::open(url, heartbeat, max_size).await?;
+        let result = crate::pairing_flow::perform_handshake(&client, hub_request).await?;
+        Ok((client, result))
+    }
+
+    /// Asks the Agent at `url` for its info without a Hub handshake, so no
+    /// pairing is started. Used to identify an Agent added by address.
+    pub async fn probe_info(url: &str) -> Result<AgentInfo, WsError> {
+        let heartbeat = HeartbeatConfig::default();
+        let client = tokio::time::timeout(
+            WS_REQUEST_TIMEOUT,
+            Self::open(url, &heartbeat, WS_MAX_MESSAGE_SIZE),
+        )
+        .await
+        .map_err(|_| WsError::Timeout)??;
+        let resp = client.send_request::<()>(MessageType::GetInfo, None).await;
+        client.close().await;
+        let info: InfoResponse = resp?.parse_payload()?.ok_or(WsError::AgentError {
+            code: 500,
+            message: "empty info response".into(),
+        })?;
+        // Agents that predate the field report 0, which passes as v1.
+        check_agent_protocol(info.agent.protocol_version)?;
+        Ok(info.agent)
+    }
+
+    /// Opens the WebSocket and starts the pumps.
+    /// Messages over `max_size` bytes are refused.
+    async fn open(
+        url: &str,
+        heartbeat: &HeartbeatConfig,
+        max_size: usize,
+    ) -> Result<Self, WsError> {
         let mut ws_config = tokio_tungstenite::tungstenite::protocol::WebSocketConfig::default();
-        ws_config.max_message_size = Some(WS_MAX_MESSAGE_SIZE);
-        ws_config.max_frame_size = Some(WS_MAX_MESSAGE_SIZE);
+        ws_config.max_message_size = Some(max_size);
+        ws_config.max_frame_size = Some(max_size);
         let (ws_stream, _) =
             tokio_tungstenite::connect_async_with_config(url, Some(ws_config), false).await?;
         let (write, read) = ws_stream.split();
 
-        let (write_tx, write_rx) = mpsc::channel::<tungstenite::Message>(256);
+        let (write_tx, write_rx) =
+            mpsc::channel::<tungstenite::Message>(heartbeat.send_buffer.max(1));
         let pending: Arc<Mutex<HashMap<String, oneshot::Sender<Message>>>> =
             Arc::new(Mutex::new(HashMap::new()));
         let on_event: Arc<Mutex<Option<EventCallback>>> = Arc::new(Mutex::new(None));
@@ -118,6 +274,8 @@ impl WsClient {
                 on_disconnect,
                 agent_closed,
                 write_tx,
+                heartbeat.clone(),
+                max_size,
                 cancel,
             ))
         };
@@ -125,23 +283,22 @@ impl WsClient {
         let ping_handle = {
             let write_tx = write_tx.clone();
             let cancel = cancel.clone();
-            tokio::spawn(crate::pumps::ping::ping_pump(write_tx, cancel))
+            let period = heartbeat.ping_period;
+            tokio::spawn(crate::pumps::ping::ping_pump(write_tx, period, cancel))
         };
 
-        let client = Self {
+        Ok(Self {
             write_tx,
             pending,
             on_event,
             on_disconnect,
             agent_closed,
+            profile: RwLock::new(None),
             _read_handle: read_handle,
             _write_handle: write_handle,
             _ping_handle: ping_handle,
             cancel,
-        };
-
-        let result = crate::pairing_flow::perform_handshake(&client, hub_request).await?;
-        Ok((client, result))
+        })
     }
 
     /// Sends a request and waits for the response.
@@ -150,35 +307,74 @@ impl WsClient {
         msg_type: MessageType,
         payload: Option<&T>,
     ) -> Result<Message, WsError> {
+        if let Some(capability) = msg_type.required_capability()
+            && !self.peer_supports(capability)
+        {
+            return Err(unsupported(&msg_type));
+        }
+
         let id = uuid::Uuid::new_v4().to_string();
-        let msg = Message::new(&id, msg_type, payload)?;
+        let msg = Message::new(&id, msg_type.clone(), payload)?;
         let json = serde_json::to_string(&msg)?;
 
+        let resp = self
+            .exchange(
+                id,
+                tungstenite::Message::Text(json.into()),
+                WS_REQUEST_TIMEOUT,
+            )
+            .await?;
+        if let Some(err) = &resp.error {
+            // Older agents answer unknown types with a bare 501.
+            if err.code == WS_ERR_CODE_NOT_IMPLEMENTED {
+                return Err(unsupported(&msg_type));
+            }
+            return Err(agent_error(err, &resp));
+        }
+        Ok(resp)
+    }
+
+    /// Queues `frame` and waits for the reply to `id`. Waiting for room in
+    /// a full send queue counts against `timeout`.
+    async fn exchange(
+        &self,
+        id: String,
+        frame: tungstenite::Message,
+        timeout: std::time::Duration,
+    ) -> Result<Message, WsError> {
+        let deadline = tokio::time::Instant::now() + timeout;
         let (tx, rx) = oneshot::channel();
         self.pending.lock().await.insert(id.clone(), tx);
 
-        self.write_tx
-            .send(tungstenite::Message::Text(json.into()))
-            .await
-            .map_err(|_| WsError::Closed)?;
-
-        let result = tokio::time::timeout(WS_REQUEST_TIMEOUT, rx).await;
+        let result = match tokio::time::timeout_at(deadline, self.write_tx.send(frame)).await {
+            Ok(Ok(())) => match tokio::time::timeout_at(deadline, rx).await {
+                Ok(Ok(resp)) => Ok(resp),
+                Ok(Err(_)) => Err(WsError::Closed),
+                Err(_) => Err(WsError::Timeout),
+            },
+            Ok(Err(_)) => Err(WsError::Closed),
+            Err(_) => Err(WsError::Timeout),
+        };
 
         // Clean up pending entry on any exit path.
         self.pending.lock().await.remove(&id);
+        result
+    }
 
-        match result {
-            Ok(Ok(resp)) => {
-                if let Some(err) = &resp.error {
-                    return Err(WsError::AgentError {
-                        code: err.code,
-                        message: err.message.clone(),
-                    });
-                }
-                Ok(resp)
-            }
-            Ok(Err(_)) => Err(WsError::Closed),
-            Err(_) => Err(WsError::Timeout),
+    /// Records the profile negotiated in the handshake. Requests for
+    /// capabilities it lacks then fail without reaching the Agent.
+    pub fn set_profile(&self, profile: ProtocolProfile) {
+        if let Ok(mut slot) = self.profile.write() {
+            *slot = Some(profile);
+        }
+    }
+
+    /// Whether the Agent negotiated `capability`. True until the
+    /// handshake has produced a profile.
+    fn peer_supports(&self, capability: &str) -> bool {
+        match self.profile.read() {
+            Ok(profile) => profile.as_ref().is_none_or(|p| p.supports(capability)),
+            Err(_) => true,
         }
     }
 
@@ -231,32 +427,19 @@ impl WsClient {
         frame.extend_from_slice(&header_bytes);
         frame.extend_from_slice(data);
 
-        let (tx, rx) = oneshot::channel();
-        self.pending.lock().await.insert(id.clone(), tx);
-
-        self.write_tx
-            .send(tungstenite::Message::Binary(frame.into()))
-            .await
-            .map_err(|_| WsError::Closed)?;
-
         // Binary transfers use a longer timeout to handle slow disk I/O
         // and network conditions during large chunk uploads.
-        let result = tokio::time::timeout(WS_BINARY_REQUEST_TIMEOUT, rx).await;
-        self.pending.lock().await.remove(&id);
-
-        match result {
-            Ok(Ok(resp)) => {
-                if let Some(err) = &resp.error {
-                    return Err(WsError::AgentError {
-                        code: err.code,
-                        message: err.message.clone(),
-                    });
-                }
-                Ok(resp)
-            }
-            Ok(Err(_)) => Err(WsError::Closed),
-            Err(_) => Err(WsError::Timeout),
+        let resp = self
+            .exchange(
+                id,
+                tungstenite::Message::Binary(frame.into()),
+                WS_BINARY_REQUEST_TIMEOUT,
+            )
+            .await?;
+        if let Some(err) = &resp.error {
+            return Err(agent_error(err, &resp));
         }
+        Ok(resp)
     }
 
     /// Returns `true` if the Agent sent a close frame with
@@ -284,28 +467,236 @@ impl Drop for WsClient {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::types::DEFAULT_SEND_BUFFER;
 
-    #[tokio::test]
-    async fn send_binary_builds_correct_wire_format() {
-        // Verify the wire frame format: [4 BE bytes len][header JSON][data].
-        let (write_tx, mut write_rx) = mpsc::channel::<tungstenite::Message>(16);
-        let pending: Arc<Mutex<HashMap<String, oneshot::Sender<Message>>>> =
-            Arc::new(Mutex::new(HashMap::new()));
-        let on_event: Arc<Mutex<Option<EventCallback>>> = Arc::new(Mutex::new(None));
-        let on_disconnect: DisconnectCallback = Arc::new(Mutex::new(None));
-        let cancel = tokio_util::sync::CancellationToken::new();
+    /// A client without pumps; frames it sends land in the returned receiver.
+    fn test_client() -> (WsClient, mpsc::Receiver<tungstenite::Message>) {
+        test_client_with_buffer(16)
+    }
 
+    fn test_client_with_buffer(
+        send_buffer: usize,
+    ) -> (WsClient, mpsc::Receiver<tungstenite::Message>) {
+        let (write_tx, write_rx) = mpsc::channel::<tungstenite::Message>(send_buffer);
         let client = WsClient {
             write_tx,
-            pending: pending.clone(),
-            on_event,
-            on_disconnect,
+            pending: Arc::new(Mutex::new(HashMap::new())),
+            on_event: Arc::new(Mutex::new(None)),
+            on_disconnect: Arc::new(Mutex::new(None)),
             agent_closed: Arc::new(AtomicBool::new(false)),
+            profile: RwLock::new(None),
             _read_handle: tokio::spawn(async {}),
             _write_handle: tokio::spawn(async {}),
             _ping_handle: tokio::spawn(async {}),
-            cancel,
+            cancel: tokio_util::sync::CancellationToken::new(),
+        };
+        (client, write_rx)
+    }
+
+    #[tokio::test]
+    async fn burst_beyond_send_buffer_is_not_dropped() {
+        let (client, mut write_rx) = test_client_with_buffer(DEFAULT_SEND_BUFFER);
+        let client = Arc::new(client);
+
+        // A slow agent: answers every request, one at a time.
+        let pending = client.pending.clone();
+        let agent = tokio::spawn(async move {
+            let mut answered = 0;
+            while let Some(tungstenite::Message::Text(text)) = write_rx.recv().await {
+                tokio::task::yield_now().await;
+                let req: Message = serde_json::from_str(&text).unwrap();
+                let reply = Message::new::<()>(&req.id, MessageType::Pong, None).unwrap();
+                if let Some(tx) = pending.lock().await.remove(&req.id) {
+                    let _ = tx.send(reply);
+                }
+                answered += 1;
+            }
+            answered
+        });
+
+        let requests = DEFAULT_SEND_BUFFER * 2;
+        let handles: Vec<_> = (0..requests)
+            .map(|_| {
+                let client = client.clone();
+                tokio::spawn(
+                    async move { client.send_request::<()>(MessageType::Ping, None).await },
+                )
+            })
+            .collect();
+        for handle in handles {
+            assert!(handle.await.unwrap().is_ok());
+        }
+
+        drop(client);
+        assert_eq!(agent.await.unwrap(), requests);
+    }
+
+    /// Splits a binary frame into its JSON header and payload.
+    fn split_binary_frame(frame: &[u8]) -> (serde_json::Value, Vec<u8>) {
+        let len = u32::from_be_bytes(frame[..4].try_into().unwrap()) as usize;
+        let header = serde_json::from_slice(&frame[4..4 + len]).unwrap();
+        (header, frame[4 + len..].to_vec())
+    }
+
+    #[tokio::test]
+    async fn concurrent_binary_replies_reach_their_waiters() {
+        const PER_KIND: usize = 64;
+        let (client, mut write_rx) = test_client_with_buffer(DEFAULT_SEND_BUFFER);
+        let client = Arc::new(client);
+
+        // Mock agent: takes every frame, then answers in reverse order
+        // through the read pump's routing, echoing what each frame carried.
+        let pending = client.pending.clone();
+        let on_event = client.on_event.clone();
+        let agent = tokio::spawn(async move {
+            let mut frames = Vec::new();
+            while frames.len() < PER_KIND * 2 {
+                match write_rx.recv().await {
+                    Some(tungstenite::Message::Binary(frame)) => frames.push(frame),
+                    other => panic!("expected a binary frame, got {other:?}"),
+                }
+            }
+            for frame in frames.into_iter().rev() {
+                let (header, data) = split_binary_frame(&frame);
+                let id = header["id"].as_str().unwrap().to_string();
+                let echo = serde_json::json!({
+                    "filePath": header["filePath"],
+                    "artworkType": header["artworkType"],
+                    "data": String::from_utf8(data).unwrap(),
+                });
+                let reply =
+                    Message::new(&id, MessageType::UploadChunkResponse, Some(&echo)).unwrap();
+                let text = serde_json::to_string(&reply).unwrap();
+                crate::pumps::read::handle_text_message(
+                    &text,
+                    WS_MAX_MESSAGE_SIZE,
+                    &pending,
+                    &on_event,
+                )
+                .await;
+            }
+        });
+
+        let mut waiters = Vec::new();
+        for n in 0..PER_KIND {
+            let chunk_client = client.clone();
+            waiters.push(tokio::spawn(async move {
+                let client = chunk_client;
+                let header = serde_json::json!({
+                    "uploadId": "u1",
+                    "filePath": format!("file-{n}.bin"),
+                    "offset": 0,
+                });
+                let data = format!("chunk-{n}");
+                let resp = client.send_binary(&header, data.as_bytes()).await.unwrap();
+                let echo: serde_json::Value = resp.parse_payload().unwrap().unwrap();
+                assert_eq!(echo["filePath"], format!("file-{n}.bin"));
+                assert_eq!(echo["data"], data);
+            }));
+            let artwork_client = client.clone();
+            waiters.push(tokio::spawn(async move {
+                let client = artwork_client;
+                let header = serde_json::json!({
+                    "type": "artwork_image",
+                    "appId": n,
+                    "artworkType": format!("grid-{n}"),
+                });
+                let data = format!("image-{n}");
+                let resp = client.send_binary(&header, data.as_bytes()).await.unwrap();
+                let echo: serde_json::Value = resp.parse_payload().unwrap().unwrap();
+                assert_eq!(echo["artworkType"], format!("grid-{n}"));
+                assert_eq!(echo["data"], data);
+            }));
+        }
+        for waiter in waiters {
+            waiter.await.unwrap();
+        }
+        agent.await.unwrap();
+        assert!(client.pending.lock().await.is_empty());
+    }
+
+    #[tokio::test]
+    async fn late_reply_after_timeout_is_not_misrouted() {
+        tokio::time::pause();
+        let (client, mut write_rx) = test_client();
+        let client = Arc::new(client);
+
+        let first = {
+            let client = client.clone();
+            tokio::spawn(async move { client.send_binary(&serde_json::json!({}), b"a").await })
+        };
+        let Some(tungstenite::Message::Binary(frame)) = write_rx.recv().await else {
+            panic!("expected a binary frame");
+        };
+        let (header, _) = split_binary_frame(&frame);
+        let stale_id = header["id"].as_str().unwrap().to_string();
+        assert!(first.await.unwrap().unwrap_err().is_timeout());
+
+        // A second request is waiting when the first one's reply turns up.
+        let second = {
+            let client = client.clone();
+            tokio::spawn(async move { client.send_binary(&serde_json::json!({}), b"b").await })
+        };
+        let Some(tungstenite::Message::Binary(frame)) = write_rx.recv().await else {
+            panic!("expected a binary frame");
+        };
+        let (header, _) = split_binary_frame(&frame);
+        let live_id = header["id"].as_str().unwrap().to_string();
+
+        for id in [&stale_id, &live_id] {
+            let reply = Message::new::<()>(id, MessageType::UploadChunkResponse, None).unwrap();
+            crate::pumps::read::handle_text_message(
+                &serde_json::to_string(&reply).unwrap(),
+                WS_MAX_MESSAGE_SIZE,
+                &client.pending,
+                &client.on_event,
+            )
+            .await;
+        }
+        assert_eq!(second.await.unwrap().unwrap().id, live_id);
+    }
+
+    #[tokio::test]
+    async fn full_send_buffer_times_out() {
+        tokio::time::pause();
+        let (client, _write_rx) = test_client_with_buffer(1);
+        client
+            .write_tx
+            .send(tungstenite::Message::Text("filler".into()))
+            .await
+            .unwrap();
+
+        let err = client
+            .send_request::<()>(MessageType::Ping, None)
+            .await
+            .unwrap_err();
+        assert!(err.is_timeout());
+        assert!(client.pending.lock().await.is_empty());
+    }
+
+    #[test]
+    fn classifies_agent_errors() {
+        let agent = |code| WsError::AgentError {
+            code,
+            message: String::new(),
         };
+        assert!(agent(WS_ERR_CODE_UNAUTHORIZED).is_unauthorized());
+        assert!(agent(WS_ERR_CODE_NOT_FOUND).is_not_found());
+        assert!(agent(WS_ERR_CODE_NOT_IMPLEMENTED).is_not_implemented());
+        assert!(unsupported(&MessageType::CheckCef).is_not_implemented());
+        assert!(agent(WS_ERR_CODE_UPLOAD_PAUSED).is_upload_paused());
+        assert!(!agent(WS_ERR_CODE_NOT_FOUND).is_unauthorized());
+
+        assert!(WsError::Timeout.is_timeout());
+        assert_eq!(WsError::Timeout.agent_code(), None);
+        assert_eq!(WsError::Closed.agent_code(), None);
+        assert!(!WsError::Closed.is_timeout());
+    }
+
+    #[tokio::test]
+    async fn send_binary_builds_correct_wire_format() {
+        // Verify the wire frame format: [4 BE bytes len][header JSON][data].
+        let (client, mut write_rx) = test_client();
 
         let header = serde_json::json!({"type": "uploadChunk", "uploadId": "u1"});
         let data = b"hello binary";
@@ -344,4 +735,56 @@ mod tests {
 
         send_handle.abort();
     }
+
+    #[tokio::test]
+    async fn request_outside_negotiated_profile_is_refused() {
+        let (client, mut write_rx) = test_client();
+        client.set_profile(ProtocolProfile::default());
+
+        let err = client
+            .send_request::<()>(MessageType::SubscribeLogs, None)
+            .await
+            .unwrap_err();
+        match err {
+            WsError::AgentError { code, message } => {
+                assert_eq!(code, WS_ERR_CODE_NOT_IMPLEMENTED);
+                assert!(message.contains("subscribe_logs"), "{message}");
+                assert!(message.contains("update the agent"), "{message}");
+            }
+            other => panic!("expected AgentError, got {other:?}"),
+        }
+        // Nothing went on the wire.
+        assert!(write_rx.try_recv().is_err());
+    }
+
+    #[test]
+    fn agent_error_keeps_details() {
+        let req = Message::new::<()>("r1", MessageType::InitUpload, None).unwrap();
+        let plain = req.reply_error(409, "busy");
+        let err = agent_error(plain.error.as_ref().unwrap(), &plain);
+        assert!(matches!(err, WsError::AgentError { code: 409, .. }));
+        assert_eq!(err.details::<serde_json::Value>(), None);
+
+        let detailed = req
+            .reply_error_with(409, "exists", &serde_json::json!({"appId": 7}))
+            .unwrap();
+        let err = agent_error(detailed.error.as_ref().unwrap(), &detailed);
+        assert_eq!(err.agent_code(), Some(409));
+        assert_eq!(err.to_string(), "agent error 409: exists");
+        let details: serde_json::Value = err.details().unwrap();
+        assert_eq!(details["appId"], 7);
+    }
+
+    #[test]
+    fn agent_protocol_version_check() {
+        assert!(check_agent_protocol(0).is_ok());
+        assert!(check_agent_protocol(PROTOCOL_VERSION).is_ok());
+        match check_agent_protocol(PROTOCOL_VERSION + 1) {
+            Err(WsError::AgentError { code, message }) => {
+                assert_eq!(code, WS_ERR_CODE_NOT_ACCEPTED);
+                assert!(message.contains("update the Hub"), "{message}");
+            }
+            other => panic!("expected AgentError, got {other:?}"),
+        }
+    }
 }
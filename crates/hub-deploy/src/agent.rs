@@ -3,6 +3,7 @@
 //! `AgentConnection` is implemented by the Hub app to bridge
 //! deploy logic to the actual WebSocket transport.
 
+use std::collections::{BTreeMap, HashMap, HashSet};
 use std::future::Future;
 use std::net::SocketAddr;
 use std::path::{Path, PathBuf};
@@ -11,18 +12,28 @@ use std::time::{Duration, Instant};
 
 use capydeploy_protocol::envelope::Message;
 use capydeploy_protocol::messages::{
-    CompleteUploadRequestFull, CompleteUploadResponseFull, FileEntry, InitUploadRequestFull,
-    InitUploadResponseFull,
+    ArtworkImageResponse, ArtworkResponse, CompleteUploadRequestFull, CompleteUploadResponseFull,
+    ConfigResponse, DiskUsage, ExpectedFile, FileEntry, FileMismatch, GetDiskUsageRequest,
+    InitUploadRequestFull, InitUploadResponseFull,
 };
 use capydeploy_protocol::types::{ShortcutConfig, UploadConfig};
-use capydeploy_transfer::ChunkReader;
+use capydeploy_transfer::{ChunkReader, Compression, RateLimiter, is_precompressed};
+use futures_util::StreamExt;
+use futures_util::stream::FuturesUnordered;
+use tokio::sync::watch;
 use tokio_util::sync::CancellationToken;
 use tracing::{debug, info, warn};
 
-use crate::artwork_selector::{build_shortcut_config, collect_local_artwork};
+use crate::artwork_selector::{build_shortcut_config, collect_local_artwork, detect_content_type};
 use crate::error::DeployError;
+use crate::launch_options::{
+    LaunchVariables, expand_template, needs_agent_install_path, uses_install_dir,
+};
+use crate::pause::PauseGate;
+use crate::resume::{ResumeManifest, ResumeStore};
 use crate::types::{
-    CompleteUploadResult, DeployConfig, DeployEvent, GameSetup, InitUploadResult, LocalArtwork,
+    ArtworkSource, CompleteUploadResult, DeployConfig, DeployEvent, DeployPreview, GameSetup,
+    InitUploadResult, LocalArtwork,
 };
 
 /// Minimum chunk size for adaptive sizing (256 KB).
@@ -31,6 +42,78 @@ const MIN_CHUNK_SIZE: usize = 256 * 1024;
 /// Initial chunk size before adaptation kicks in (1 MB).
 const INITIAL_CHUNK_SIZE: usize = 1024 * 1024;
 
+/// Default number of WS chunks kept in flight at once.
+pub const DEFAULT_CHUNK_CONCURRENCY: usize = 4;
+
+/// Times a chunked artwork upload may resume without progress before it's
+/// abandoned.
+const MAX_ARTWORK_RESUMES: usize = 3;
+
+/// Times files that failed post-install verification are resent before
+/// the deploy fails.
+const MAX_VERIFY_RETRIES: usize = 1;
+
+/// Outcome of `complete_upload`.
+enum Completion {
+    Done(CompleteUploadResult),
+    /// The agent found these files missing or damaged and kept the upload
+    /// open for them.
+    Mismatched(Vec<FileMismatch>),
+}
+
+/// Formats `bytes` in gigabytes for error messages.
+fn format_gb(bytes: u64) -> String {
+    format!("{:.1} GB", bytes as f64 / (1024.0 * 1024.0 * 1024.0))
+}
+
+/// Problems with the local side of a deploy that the agent can't see: an
+/// executable missing from the scanned files and artwork that can't be
+/// sent.
+fn local_deploy_warnings(config: &DeployConfig, files: &[FileEntry]) -> Vec<String> {
+    let mut warnings = Vec::new();
+    let exe = config.setup.executable.replace('\\', "/");
+    if !exe.is_empty() && !files.iter().any(|f| f.relative_path == exe) {
+        warnings.push(format!(
+            "executable {} is not in {}",
+            config.setup.executable, config.setup.local_path
+        ));
+    }
+
+    let slots = [
+        ("grid", &config.artwork.grid),
+        ("banner", &config.artwork.banner),
+        ("hero", &config.artwork.hero),
+        ("logo", &config.artwork.logo),
+        ("icon", &config.artwork.icon),
+    ];
+    for (slot, source) in slots {
+        match source {
+            ArtworkSource::Local(path) if !Path::new(path).is_file() => {
+                warnings.push(format!("{slot} artwork {path} does not exist"));
+            }
+            ArtworkSource::Local(path) if detect_content_type(path).is_none() => {
+                warnings.push(format!("{slot} artwork {path} is not a supported image"));
+            }
+            ArtworkSource::Remote(url) if !is_plausible_url(url) => {
+                warnings.push(format!("{slot} artwork URL {url} is not valid"));
+            }
+            _ => {}
+        }
+    }
+    warnings
+}
+
+/// Whether an http(s) URL has a host and no whitespace, the mistakes a
+/// pasted URL usually has.
+fn is_plausible_url(url: &str) -> bool {
+    let rest = url
+        .split_once("://")
+        .map(|(_, rest)| rest)
+        .unwrap_or_default();
+    let host = rest.split(['/', '?', '#']).next().unwrap_or_default();
+    !host.is_empty() && !url.contains(char::is_whitespace)
+}
+
 /// Adjusts chunk size based on round-trip time, TCP slow-start style.
 ///
 /// Targets an RTT sweet spot of 1–3 seconds per chunk:
@@ -51,6 +134,66 @@ fn adapt_chunk_size(current: usize, rtt: Duration, max: usize) -> usize {
     new_size.clamp(MIN_CHUNK_SIZE, max)
 }
 
+/// Acknowledged byte ranges of one file.
+///
+/// With several chunks in flight, acks arrive out of order. Only the
+/// contiguous prefix from the start of the file is safe to resume from.
+#[derive(Debug, Default)]
+struct AckedRanges {
+    /// End of the contiguous acknowledged prefix.
+    contiguous: i64,
+    /// Acknowledged ranges past a gap, by start offset.
+    pending: BTreeMap<i64, i64>,
+}
+
+impl AckedRanges {
+    fn starting_at(offset: i64) -> Self {
+        Self {
+            contiguous: offset,
+            pending: BTreeMap::new(),
+        }
+    }
+
+    /// Marks `[offset, end)` acknowledged. Returns the new end of the
+    /// contiguous prefix if it moved.
+    fn ack(&mut self, offset: i64, end: i64) -> Option<i64> {
+        if offset > self.contiguous {
+            self.pending.insert(offset, end);
+            return None;
+        }
+        let before = self.contiguous;
+        self.contiguous = self.contiguous.max(end);
+        while let Some(entry) = self.pending.first_entry() {
+            if *entry.key() > self.contiguous {
+                break;
+            }
+            self.contiguous = self.contiguous.max(entry.remove());
+        }
+        (self.contiguous > before).then_some(self.contiguous)
+    }
+}
+
+/// A WS chunk the agent acknowledged.
+struct SentChunk {
+    file: usize,
+    offset: i64,
+    size: usize,
+    rtt: Duration,
+}
+
+/// Progress of a WS upload, updated as chunk acks come in.
+struct WsUpload<'b> {
+    upload_id: &'b str,
+    files: &'b [FileEntry],
+    total_size: i64,
+    max_chunk_size: usize,
+    chunk_size: usize,
+    uploaded: i64,
+    acked: HashMap<usize, AckedRanges>,
+    /// Bumped each time the agent resumes the upload after a pause.
+    resumed: watch::Sender<u64>,
+}
+
 /// Abstract connection to an Agent.
 ///
 /// The Hub app implements this trait on top of `WsClient`/`ConnectionManager`.
@@ -77,18 +220,97 @@ pub trait AgentConnection: Send + Sync {
     fn agent_addr(&self) -> Option<std::net::IpAddr> {
         None
     }
+
+    /// Returns the largest binary frame the agent accepts, as advertised
+    /// in its status response.
+    fn max_binary_frame_size(&self) -> usize {
+        capydeploy_protocol::constants::WS_MAX_MESSAGE_SIZE
+    }
+
+    /// Whether the agent reassembles artwork sent in chunks. Without it,
+    /// images larger than a frame can't be sent.
+    fn supports_chunked_artwork(&self) -> bool {
+        false
+    }
+
+    /// Whether the agent accepts several artwork images in one
+    /// `apply_artwork_batch` frame.
+    fn supports_artwork_batch(&self) -> bool {
+        false
+    }
+
+    /// Whether the agent answers `get_disk_usage`, so a deploy that
+    /// doesn't fit can be refused before any bytes are sent.
+    fn supports_disk_usage(&self) -> bool {
+        false
+    }
 }
 
 /// Manages a deploy session to a single agent.
 pub struct AgentDeploy<'a> {
     conn: &'a dyn AgentConnection,
     cancel: CancellationToken,
+    resume: Option<&'a ResumeStore>,
+    rate: RateLimiter,
+    chunk_concurrency: usize,
+    compression: Compression,
+    pause: PauseGate,
+    overwrite: bool,
 }
 
 impl<'a> AgentDeploy<'a> {
     /// Creates a new deploy session.
     pub fn new(conn: &'a dyn AgentConnection, cancel: CancellationToken) -> Self {
-        Self { conn, cancel }
+        Self {
+            conn,
+            cancel,
+            resume: None,
+            rate: RateLimiter::unlimited(),
+            chunk_concurrency: DEFAULT_CHUNK_CONCURRENCY,
+            compression: Compression::None,
+            pause: PauseGate::new(),
+            overwrite: false,
+        }
+    }
+
+    /// Holds WebSocket uploads while `gate` is paused.
+    pub fn with_pause(mut self, gate: PauseGate) -> Self {
+        self.pause = gate;
+        self
+    }
+
+    /// Asks the agent to accept WS chunks compressed with `codec`. Files
+    /// in formats that are already compressed are still sent raw.
+    pub fn with_compression(mut self, codec: Compression) -> Self {
+        self.compression = codec;
+        self
+    }
+
+    /// Lets the agent replace a Steam shortcut the game already has
+    /// instead of refusing the upload with [`DeployError::ShortcutConflict`].
+    pub fn with_overwrite(mut self, overwrite: bool) -> Self {
+        self.overwrite = overwrite;
+        self
+    }
+
+    /// Keeps up to `n` WS chunks in flight at once. The agent may lower
+    /// this in its init response.
+    pub fn with_chunk_concurrency(mut self, n: usize) -> Self {
+        self.chunk_concurrency = n.max(1);
+        self
+    }
+
+    /// Caps the upload bandwidth with `rate`, shared by all files.
+    pub fn with_rate_limit(mut self, rate: RateLimiter) -> Self {
+        self.rate = rate;
+        self
+    }
+
+    /// Records upload progress in `store`, and resumes the upload it holds
+    /// for the same setup and agent instead of starting over.
+    pub fn with_resume(mut self, store: &'a ResumeStore) -> Self {
+        self.resume = Some(store);
+        self
     }
 
     /// Runs the full deploy pipeline for one agent.
@@ -105,6 +327,8 @@ impl<'a> AgentDeploy<'a> {
         events_tx: &tokio::sync::mpsc::Sender<DeployEvent>,
     ) -> Result<CompleteUploadResult, DeployError> {
         let agent_id = self.conn.agent_id().to_string();
+        config.setup.validate_install_subpath()?;
+        let config = &self.expand_launch_options(config).await?;
 
         // 1. Scan files
         self.emit_progress(events_tx, 0.0, "Scanning files...")
@@ -126,7 +350,7 @@ impl<'a> AgentDeploy<'a> {
             .await;
         self.check_cancelled()?;
 
-        let init_result = self.init_upload(&config.setup, &files, total_size).await?;
+        let init_result = self.start_upload(&config.setup, &files, total_size).await?;
 
         // Run the rest of the pipeline; on any failure, notify the agent
         // to clean up partial uploads before propagating the error.
@@ -134,13 +358,137 @@ impl<'a> AgentDeploy<'a> {
             .deploy_after_init(config, &files, total_size, &init_result, events_tx)
             .await;
 
-        if result.is_err() {
-            self.send_cancel_upload(&init_result.upload_id).await;
+        // If the agent can't be told to cancel (the connection dropped),
+        // it keeps the partial upload and the next deploy resumes it.
+        if result.is_ok() || self.send_cancel_upload(&init_result.upload_id).await {
+            self.forget_upload(&init_result.upload_id);
+        } else if let Some(store) = self.resume
+            && let Err(e) = store.flush().await
+        {
+            warn!(upload_id = %init_result.upload_id, error = %e, "failed to save resume manifest");
         }
 
         result
     }
 
+    /// Checks what [`deploy`](Self::deploy) would do without sending any
+    /// files: scans the local build, checks its executable and artwork,
+    /// and asks the agent for a dry run of the upload.
+    ///
+    /// Agents that predate dry runs open a real session instead; it is
+    /// cancelled right away and only the local checks are reported.
+    pub async fn preview(&self, config: &DeployConfig) -> Result<DeployPreview, DeployError> {
+        config.setup.validate_install_subpath()?;
+        let config = &self.expand_launch_options(config).await?;
+        let root_path = Path::new(&config.setup.local_path);
+        let (files, total_size) = crate::scanner::scan_files_for_upload(root_path)?;
+
+        let mut preview = DeployPreview {
+            agent_id: self.conn.agent_id().to_string(),
+            file_count: files.len(),
+            total_bytes: total_size,
+            transfer_bytes: total_size,
+            ..Default::default()
+        };
+        preview
+            .warnings
+            .extend(local_deploy_warnings(config, &files));
+
+        let req = self.upload_request(&config.setup, &files, total_size, "", true);
+        let resp = self
+            .conn
+            .send_request(
+                capydeploy_protocol::constants::MessageType::InitUpload,
+                &serde_json::to_value(&req)?,
+            )
+            .await?
+            .parse_payload::<InitUploadResponseFull>()?
+            .ok_or_else(|| DeployError::Upload("empty init response".into()))?;
+
+        let Some(agent) = resp.preview else {
+            if !resp.upload_id.is_empty() {
+                self.send_cancel_upload(&resp.upload_id).await;
+            }
+            preview
+                .warnings
+                .push("the agent can't preview uploads; only local files were checked".into());
+            return Ok(preview);
+        };
+        preview.transfer_bytes = agent.transfer_bytes;
+        preview.skipped_files = resp.skip_files.len();
+        preview.game_path = agent.game_path;
+        preview.available_bytes = agent.available_bytes;
+        preview.blockers = agent.blockers;
+        preview.warnings.extend(agent.warnings);
+        preview.conflict = agent.conflict;
+        Ok(preview)
+    }
+
+    /// Opens the upload session on the agent, resuming the interrupted
+    /// upload of `setup` if the agent still holds it.
+    async fn start_upload(
+        &self,
+        setup: &GameSetup,
+        files: &[FileEntry],
+        total_size: i64,
+    ) -> Result<InitUploadResult, DeployError> {
+        if let Some(manifest) = self
+            .resume
+            .and_then(|store| store.get(&setup.id, self.conn.agent_id()))
+        {
+            let upload_id = manifest.upload_id.as_str();
+            if !manifest.matches_files(files) {
+                info!(
+                    upload_id,
+                    "local files changed, discarding interrupted upload"
+                );
+                self.send_cancel_upload(upload_id).await;
+            } else {
+                match self.init_upload(setup, files, total_size, upload_id).await {
+                    Ok(init) if init.upload_id == upload_id => {
+                        info!(
+                            upload_id,
+                            acknowledged_bytes = manifest.acknowledged_bytes(),
+                            "resuming interrupted upload"
+                        );
+                        return Ok(init);
+                    }
+                    Ok(init) => {
+                        // An agent without resume support opened a new session.
+                        self.remember_upload(&init.upload_id, setup, files);
+                        return Ok(init);
+                    }
+                    Err(e) => {
+                        warn!(upload_id, error = %e, "interrupted upload is gone, starting over");
+                    }
+                }
+            }
+            self.forget_upload(upload_id);
+        }
+
+        let init = self.init_upload(setup, files, total_size, "").await?;
+        self.remember_upload(&init.upload_id, setup, files);
+        Ok(init)
+    }
+
+    fn remember_upload(&self, upload_id: &str, setup: &GameSetup, files: &[FileEntry]) {
+        let Some(store) = self.resume else {
+            return;
+        };
+        let manifest = ResumeManifest::new(upload_id, &setup.id, self.conn.agent_id(), files);
+        if let Err(e) = store.begin(manifest) {
+            warn!(upload_id, error = %e, "failed to save resume manifest");
+        }
+    }
+
+    fn forget_upload(&self, upload_id: &str) {
+        if let Some(store) = self.resume
+            && let Err(e) = store.finish(upload_id)
+        {
+            warn!(upload_id, error = %e, "failed to remove resume manifest");
+        }
+    }
+
     /// Pipeline stages 3–5 (after init). Factored out so `deploy()` can
     /// send `cancel_upload` to the agent on any failure.
     async fn deploy_after_init(
@@ -151,11 +499,14 @@ impl<'a> AgentDeploy<'a> {
         init_result: &InitUploadResult,
         events_tx: &tokio::sync::mpsc::Sender<DeployEvent>,
     ) -> Result<CompleteUploadResult, DeployError> {
+        let max_payload =
+            capydeploy_protocol::constants::max_binary_payload(self.conn.max_binary_frame_size());
         let max_chunk_size = if init_result.chunk_size > 0 {
             init_result.chunk_size as usize
         } else {
             capydeploy_transfer::DEFAULT_CHUNK_SIZE
-        };
+        }
+        .min(max_payload);
 
         // 3. Upload chunks
         self.emit_progress(events_tx, 0.1, "Uploading files...")
@@ -177,7 +528,8 @@ impl<'a> AgentDeploy<'a> {
         self.check_cancelled()?;
 
         let local_artwork = collect_local_artwork(&config.artwork);
-        self.send_artwork(&local_artwork, 0, events_tx).await;
+        self.send_artwork(&local_artwork, 0, max_payload, events_tx)
+            .await;
 
         // 5. Complete upload
         self.emit_progress(events_tx, 0.9, "Creating shortcut...")
@@ -185,9 +537,61 @@ impl<'a> AgentDeploy<'a> {
         self.check_cancelled()?;
 
         let shortcut = build_shortcut_config(&config.setup, &config.artwork);
-        let result = self
-            .complete_upload(&init_result.upload_id, &shortcut)
+        let mut retries = 0;
+        let result = loop {
+            let mismatched = match self
+                .complete_upload(&init_result.upload_id, &shortcut, files)
+                .await?
+            {
+                Completion::Done(result) => break result,
+                Completion::Mismatched(mismatched) => mismatched,
+            };
+            let names = mismatched
+                .iter()
+                .map(|m| format!("{} ({})", m.relative_path, m.reason))
+                .collect::<Vec<_>>()
+                .join(", ");
+            if retries == MAX_VERIFY_RETRIES {
+                return Err(DeployError::Upload(format!(
+                    "files failed verification after resending: {names}"
+                )));
+            }
+            retries += 1;
+            warn!(files = %names, "resending files that failed verification");
+
+            // The agent deleted them, so they go again whole.
+            let bad: HashSet<&str> = mismatched
+                .iter()
+                .map(|m| m.relative_path.as_str())
+                .collect();
+            let resend: Vec<FileEntry> = files
+                .iter()
+                .filter(|f| bad.contains(f.relative_path.as_str()))
+                .cloned()
+                .collect();
+            self.emit_progress(
+                events_tx,
+                0.9,
+                &format!("Resending {} file(s)...", resend.len()),
+            )
+            .await;
+            let resend_size = resend.iter().map(|f| f.size).sum();
+            let resend_init = InitUploadResult {
+                resume_from: None,
+                skip_files: Vec::new(),
+                ..init_result.clone()
+            };
+            self.upload_files_ws(
+                &config.setup,
+                &resend,
+                resend_size,
+                &resend_init,
+                max_chunk_size,
+                events_tx,
+            )
             .await?;
+            self.check_cancelled()?;
+        };
 
         self.emit_progress(events_tx, 1.0, "Upload complete!").await;
 
@@ -195,7 +599,8 @@ impl<'a> AgentDeploy<'a> {
     }
 
     /// Best-effort: tell the agent to clean up a failed/cancelled upload.
-    async fn send_cancel_upload(&self, upload_id: &str) {
+    /// Returns whether the agent got the request.
+    async fn send_cancel_upload(&self, upload_id: &str) -> bool {
         let payload = serde_json::json!({ "uploadId": upload_id });
         match self
             .conn
@@ -205,32 +610,126 @@ impl<'a> AgentDeploy<'a> {
             )
             .await
         {
-            Ok(_) => info!(upload_id, "sent cancel_upload to agent"),
-            Err(e) => warn!(upload_id, error = %e, "failed to send cancel_upload"),
+            Ok(_) => {
+                info!(upload_id, "sent cancel_upload to agent");
+                true
+            }
+            Err(e) => {
+                warn!(upload_id, error = %e, "failed to send cancel_upload");
+                false
+            }
+        }
+    }
+
+    /// Expands the launch option template of `config` for this agent.
+    ///
+    /// A setup without its own install directory installs into the agent's
+    /// default one, which is asked for when the template refers to it.
+    async fn expand_launch_options(
+        &self,
+        config: &DeployConfig,
+    ) -> Result<DeployConfig, DeployError> {
+        let template = &config.setup.launch_options;
+        if template.is_empty() {
+            return Ok(config.clone());
+        }
+        let agent_install_path =
+            if uses_install_dir(template) && needs_agent_install_path(&config.setup) {
+                self.agent_install_path().await?
+            } else {
+                String::new()
+            };
+        let vars = LaunchVariables::from_setup(&config.setup, &agent_install_path);
+        let mut config = config.clone();
+        config.setup.launch_options = expand_template(template, &vars)?;
+        Ok(config)
+    }
+
+    /// Reads the agent's default install directory.
+    async fn agent_install_path(&self) -> Result<String, DeployError> {
+        let resp = self
+            .conn
+            .send_request(
+                capydeploy_protocol::constants::MessageType::GetConfig,
+                &serde_json::Value::Null,
+            )
+            .await?;
+        resp.parse_payload::<ConfigResponse>()?
+            .map(|c| c.install_path)
+            .ok_or_else(|| DeployError::Agent("empty config response".into()))
+    }
+
+    /// Refuses a fresh upload that won't fit at the agent's install target.
+    ///
+    /// Agents without `get_disk_usage`, or that can't measure the target,
+    /// aren't blocked; the upload then fails on the agent if space runs out.
+    async fn check_disk_space(
+        &self,
+        setup: &GameSetup,
+        total_size: i64,
+    ) -> Result<(), DeployError> {
+        if !self.conn.supports_disk_usage() || total_size <= 0 {
+            return Ok(());
+        }
+        // Both targets live on the same filesystem as the game will.
+        let target = if setup.library_path.is_empty() {
+            &setup.install_path
+        } else {
+            &setup.library_path
+        };
+        let req = GetDiskUsageRequest {
+            path: target.clone(),
+        };
+        let resp = match self
+            .conn
+            .send_request(
+                capydeploy_protocol::constants::MessageType::GetDiskUsage,
+                &serde_json::to_value(&req)?,
+            )
+            .await
+        {
+            Ok(resp) => resp,
+            Err(e) => {
+                warn!(error = %e, "cannot check free space on the agent");
+                return Ok(());
+            }
+        };
+        let Some(usage) = resp.parse_payload::<DiskUsage>()? else {
+            return Ok(());
+        };
+
+        let needed = total_size as u64;
+        debug!(
+            path = %usage.path,
+            free_bytes = usage.free_bytes,
+            needed_bytes = needed,
+            "agent disk usage"
+        );
+        if needed > usage.free_bytes {
+            return Err(DeployError::InsufficientSpace(format!(
+                "{} needed, {} free in {}",
+                format_gb(needed),
+                format_gb(usage.free_bytes),
+                usage.path
+            )));
         }
+        Ok(())
     }
 
-    /// Initializes the upload session on the agent.
+    /// Initializes the upload session on the agent, or re-opens
+    /// `resume_upload_id` if not empty.
     async fn init_upload(
         &self,
         setup: &GameSetup,
         files: &[FileEntry],
         total_size: i64,
+        resume_upload_id: &str,
     ) -> Result<InitUploadResult, DeployError> {
-        let upload_config = UploadConfig {
-            game_name: setup.name.clone(),
-            install_path: setup.install_path.clone(),
-            executable: setup.executable.clone(),
-            launch_options: setup.launch_options.clone(),
-            tags: setup.tags.clone(),
-        };
-
-        let req = InitUploadRequestFull {
-            config: upload_config,
-            total_size,
-            files: files.to_vec(),
-        };
+        if resume_upload_id.is_empty() {
+            self.check_disk_space(setup, total_size).await?;
+        }
 
+        let req = self.upload_request(setup, files, total_size, resume_upload_id, false);
         let payload = serde_json::to_value(&req)?;
         let resp = self
             .conn
@@ -250,9 +749,47 @@ impl<'a> AgentDeploy<'a> {
             resume_from: init_resp.resume_from,
             tcp_port: init_resp.tcp_port,
             tcp_token: init_resp.tcp_token,
+            max_concurrent_chunks: init_resp.max_concurrent_chunks,
+            // Codecs this Hub doesn't know can't have been asked for.
+            compression: Compression::from_name(&init_resp.compression).unwrap_or_default(),
+            skip_files: init_resp.skip_files,
         })
     }
 
+    /// Builds the `init_upload` request for `setup`.
+    fn upload_request(
+        &self,
+        setup: &GameSetup,
+        files: &[FileEntry],
+        total_size: i64,
+        resume_upload_id: &str,
+        dry_run: bool,
+    ) -> InitUploadRequestFull {
+        let upload_config = UploadConfig {
+            game_name: setup.name.clone(),
+            install_path: setup.install_path.clone(),
+            executable: setup.executable.clone(),
+            launch_options: setup.launch_options.clone(),
+            tags: setup.tags.clone(),
+            library_path: setup.library_path.clone(),
+            install_subpath: setup.install_subpath.clone(),
+            overwrite: self.overwrite,
+            clean_install: setup.clean_install,
+        };
+
+        InitUploadRequestFull {
+            config: upload_config,
+            total_size,
+            files: files.to_vec(),
+            resume_upload_id: resume_upload_id.to_string(),
+            compression: match self.compression {
+                Compression::None => String::new(),
+                codec => codec.name().to_string(),
+            },
+            dry_run,
+        }
+    }
+
     /// Uploads all files, trying TCP data channel first with WS fallback.
     ///
     /// TCP info (port + token) is carried in the `InitUploadResult` — no need
@@ -266,8 +803,38 @@ impl<'a> AgentDeploy<'a> {
         max_chunk_size: usize,
         events_tx: &tokio::sync::mpsc::Sender<DeployEvent>,
     ) -> Result<(), DeployError> {
+        // Files the agent already has unchanged stay out of the upload, and
+        // out of its progress.
+        let (files, total_size) = if init_result.skip_files.is_empty() {
+            (files.to_vec(), total_size)
+        } else {
+            let skip: HashSet<&str> = init_result.skip_files.iter().map(String::as_str).collect();
+            let files: Vec<FileEntry> = files
+                .iter()
+                .filter(|f| !skip.contains(f.relative_path.as_str()))
+                .cloned()
+                .collect();
+            let size = files.iter().map(|f| f.size).sum();
+            info!(
+                skipped = skip.len(),
+                bytes = total_size - size,
+                "skipping files the agent already has"
+            );
+            (files, size)
+        };
+        let files = files.as_slice();
+
+        // The data channel always sends whole, raw files, so a resumed or
+        // compressed upload goes over the WebSocket.
+        let resuming = init_result
+            .resume_from
+            .as_ref()
+            .is_some_and(|offsets| !offsets.is_empty());
+        let compressing = init_result.compression != Compression::None;
         if let (Some(port), Some(token)) = (init_result.tcp_port, &init_result.tcp_token)
             && let Some(agent_ip) = self.conn.agent_addr()
+            && !resuming
+            && !compressing
         {
             let addr = SocketAddr::new(agent_ip, port);
             info!(%addr, "attempting TCP data channel");
@@ -336,12 +903,13 @@ impl<'a> AgentDeploy<'a> {
             }
         });
 
-        let result = capydeploy_data_channel::client::TcpDataClient::connect_and_send(
+        let result = capydeploy_data_channel::client::TcpDataClient::connect_and_send_limited(
             addr,
             token,
             &file_pairs,
             self.cancel.clone(),
             progress_tx,
+            &self.rate,
         )
         .await;
 
@@ -364,6 +932,9 @@ impl<'a> AgentDeploy<'a> {
     /// Chunk size starts at [`INITIAL_CHUNK_SIZE`] and adapts per-chunk based
     /// on measured RTT (TCP slow-start style). The Agent-negotiated
     /// `max_chunk_size` acts as a ceiling.
+    ///
+    /// Up to [`chunk_window`](Self::chunk_window) chunks are in flight at
+    /// once, so the link stays busy while earlier chunks are acknowledged.
     async fn upload_files_ws(
         &self,
         setup: &GameSetup,
@@ -373,160 +944,531 @@ impl<'a> AgentDeploy<'a> {
         max_chunk_size: usize,
         events_tx: &tokio::sync::mpsc::Sender<DeployEvent>,
     ) -> Result<(), DeployError> {
-        let mut chunk_size = INITIAL_CHUNK_SIZE.min(max_chunk_size);
-        let mut uploaded: i64 = 0;
+        let window = self.chunk_window(init_result);
+        let mut up = WsUpload {
+            upload_id: &init_result.upload_id,
+            files,
+            total_size,
+            max_chunk_size,
+            chunk_size: INITIAL_CHUNK_SIZE.min(max_chunk_size),
+            uploaded: 0,
+            acked: HashMap::new(),
+            resumed: watch::Sender::new(0),
+        };
+        let mut in_flight = FuturesUnordered::new();
+        debug!(window, "uploading over WebSocket");
 
-        for file_entry in files {
+        for (index, file_entry) in files.iter().enumerate() {
             self.check_cancelled()?;
 
             let local_path = Path::new(&setup.local_path).join(&file_entry.relative_path);
-            let cs = chunk_size;
-            let mut reader = tokio::task::spawn_blocking({
-                let path = local_path.clone();
-                move || ChunkReader::new(&path, cs)
-            })
-            .await
-            .map_err(|e| DeployError::Upload(format!("task join error: {e}")))??;
+            let cs = up.chunk_size;
+            let open = tokio::task::spawn_blocking(move || ChunkReader::new(&local_path, cs));
+            let mut reader = self
+                .drive(open, &mut in_flight, &mut up, events_tx)
+                .await?
+                .map_err(|e| DeployError::Upload(format!("task join error: {e}")))??;
+
+            let codec = if is_precompressed(&file_entry.relative_path) {
+                Compression::None
+            } else {
+                init_result.compression
+            };
 
             // Handle resume offset.
+            let mut resume_offset = 0;
             if let Some(ref resume_map) = init_result.resume_from
                 && let Some(&offset) = resume_map.get(&file_entry.relative_path)
                 && offset > 0
             {
                 reader.seek_to(offset)?;
-                uploaded += offset;
+                resume_offset = offset;
+                up.uploaded += offset;
             }
+            up.acked
+                .insert(index, AckedRanges::starting_at(resume_offset));
 
             // Read and send chunks.
             loop {
                 self.check_cancelled()?;
 
                 // Apply latest adaptive chunk size before reading.
-                reader.set_chunk_size(chunk_size);
+                reader.set_chunk_size(up.chunk_size);
 
-                let chunk = tokio::task::spawn_blocking({
+                let read = tokio::task::spawn_blocking({
                     let mut reader = reader;
                     move || {
-                        let chunk = reader.next_chunk();
+                        let chunk =
+                            reader
+                                .next_chunk()
+                                .map_err(DeployError::from)
+                                .and_then(|chunk| match chunk {
+                                    Some(chunk) => {
+                                        let packed = codec.compress(&chunk.data)?;
+                                        Ok(Some((chunk, packed)))
+                                    }
+                                    None => Ok(None),
+                                });
                         (reader, chunk)
                     }
-                })
-                .await
-                .map_err(|e| DeployError::Upload(format!("task join error: {e}")))?;
+                });
+                let chunk = self
+                    .drive(read, &mut in_flight, &mut up, events_tx)
+                    .await?
+                    .map_err(|e| DeployError::Upload(format!("task join error: {e}")))?;
 
                 reader = chunk.0;
                 let chunk_result = chunk.1?;
 
-                let Some(chunk_data) = chunk_result else {
+                let Some((chunk_data, packed)) = chunk_result else {
                     break;
                 };
 
                 // Send chunk as a single binary message (matching Go Hub behavior).
                 // The agent routes binary messages without "type" to the upload
                 // chunk handler, which matches by uploadId + filePath.
-                let header = serde_json::json!({
+                let mut header = serde_json::json!({
                     "uploadId": init_result.upload_id,
                     "filePath": file_entry.relative_path,
                     "offset": chunk_data.offset,
                     "checksum": chunk_data.checksum,
                 });
-
-                let start = Instant::now();
-                let _resp = self.conn.send_binary(&header, &chunk_data.data).await?;
-                let rtt = start.elapsed();
-
-                let prev_size = chunk_size;
-                chunk_size = adapt_chunk_size(chunk_size, rtt, max_chunk_size);
-                if chunk_size != prev_size {
-                    debug!(
-                        prev_bytes = prev_size,
-                        new_bytes = chunk_size,
-                        rtt_ms = rtt.as_millis() as u64,
-                        "adaptive chunk size adjusted"
-                    );
+                // The checksum stays that of the raw bytes, checked after
+                // the agent decompresses.
+                if packed.is_some() {
+                    header["compressed"] = true.into();
                 }
+                let payload = packed.unwrap_or(chunk_data.data);
 
-                uploaded += chunk_data.size as i64;
-
-                // Progress: 0.1 to 0.85.
-                if total_size > 0 {
-                    let progress = 0.1 + (uploaded as f64 / total_size as f64) * 0.75;
-                    let status = format!("Uploading: {}", file_entry.relative_path);
-                    self.emit_progress(events_tx, progress, &status).await;
+                // Wait for a free slot, then for the bandwidth budget.
+                while in_flight.len() >= window {
+                    self.next_sent_chunk(&mut in_flight, &mut up, events_tx)
+                        .await?;
                 }
+                self.drive(
+                    self.rate.acquire(payload.len()),
+                    &mut in_flight,
+                    &mut up,
+                    events_tx,
+                )
+                .await?;
+
+                let conn = self.conn;
+                let mut resumed = up.resumed.subscribe();
+                in_flight.push(async move {
+                    loop {
+                        let start = Instant::now();
+                        match conn.send_binary(&header, &payload).await {
+                            // The agent was paused before the chunk got
+                            // there; send it again once it resumes.
+                            Err(DeployError::UploadPaused) => resumed
+                                .changed()
+                                .await
+                                .map_err(|_| DeployError::UploadPaused)?,
+                            sent => {
+                                sent?;
+                                return Ok(SentChunk {
+                                    file: index,
+                                    offset: chunk_data.offset,
+                                    size: chunk_data.size,
+                                    rtt: start.elapsed(),
+                                });
+                            }
+                        }
+                    }
+                });
             }
         }
 
+        while !in_flight.is_empty() {
+            self.next_sent_chunk(&mut in_flight, &mut up, events_tx)
+                .await?;
+        }
+
         Ok(())
     }
 
-    /// Sends local artwork images to the agent.
-    async fn send_artwork(
+    /// Chunks to keep in flight: the Hub's setting, capped by what the
+    /// agent accepts. Agents that don't advertise a cap get one at a time.
+    fn chunk_window(&self, init_result: &InitUploadResult) -> usize {
+        let agent_max = (init_result.max_concurrent_chunks as usize).max(1);
+        self.chunk_concurrency.min(agent_max)
+    }
+
+    /// Awaits `fut` while handling acks of the chunks in flight, so they
+    /// don't stall behind disk reads or the rate limiter.
+    async fn drive<T, F>(
         &self,
-        artwork: &[LocalArtwork],
-        app_id: u32,
+        fut: impl Future<Output = T>,
+        in_flight: &mut FuturesUnordered<F>,
+        up: &mut WsUpload<'_>,
         events_tx: &tokio::sync::mpsc::Sender<DeployEvent>,
-    ) {
-        for art in artwork {
-            let header = serde_json::json!({
-                "type": "artwork_image",
-                "appId": app_id,
-                "artworkType": art.art_type,
-                "contentType": art.content_type,
-            });
-
-            self.emit_progress(
-                events_tx,
-                0.87,
-                &format!("Sending {} artwork...", art.art_type),
-            )
-            .await;
-
-            match self.conn.send_binary(&header, &art.data).await {
-                Ok(_) => {
-                    debug!(art_type = %art.art_type, "sent local artwork");
-                }
-                Err(e) => {
-                    warn!(art_type = %art.art_type, error = %e, "failed to send artwork");
-                }
+    ) -> Result<T, DeployError>
+    where
+        F: Future<Output = Result<SentChunk, DeployError>>,
+    {
+        tokio::pin!(fut);
+        loop {
+            tokio::select! {
+                biased;
+                _ = self.cancel.cancelled() => return Err(DeployError::Cancelled),
+                Some(sent) = in_flight.next() => self.chunk_sent(sent?, up, events_tx).await,
+                _ = self.pause.paused() => self.hold(in_flight, up, events_tx).await?,
+                out = &mut fut => return Ok(out),
             }
         }
     }
 
-    /// Completes the upload and creates a shortcut.
-    async fn complete_upload(
+    /// Holds the upload while the pause gate is closed. The agent is asked
+    /// to hold the session too, chunks already in flight are still
+    /// accounted for, and the agent is resumed before sending continues.
+    async fn hold<F>(
         &self,
-        upload_id: &str,
-        shortcut: &ShortcutConfig,
-    ) -> Result<CompleteUploadResult, DeployError> {
-        let req = CompleteUploadRequestFull {
-            upload_id: upload_id.to_string(),
-            create_shortcut: true,
-            shortcut: Some(shortcut.clone()),
+        in_flight: &mut FuturesUnordered<F>,
+        up: &mut WsUpload<'_>,
+        events_tx: &tokio::sync::mpsc::Sender<DeployEvent>,
+    ) -> Result<(), DeployError>
+    where
+        F: Future<Output = Result<SentChunk, DeployError>>,
+    {
+        let progress = if up.total_size > 0 {
+            0.1 + (up.uploaded as f64 / up.total_size as f64) * 0.75
+        } else {
+            0.1
         };
+        self.emit_progress(events_tx, progress, "Paused").await;
 
-        let payload = serde_json::to_value(&req)?;
-        let resp = self
-            .conn
-            .send_request(
-                capydeploy_protocol::constants::MessageType::CompleteUpload,
-                &payload,
+        // Agents that can't pause just stop receiving for a while.
+        let held = self
+            .send_upload_control(
+                capydeploy_protocol::constants::MessageType::PauseUpload,
+                up.upload_id,
             )
-            .await?;
+            .await;
+        if let Err(e) = &held {
+            warn!(upload_id = up.upload_id, error = %e, "agent did not pause the upload");
+        }
 
-        let complete_resp: CompleteUploadResponseFull = resp
-            .parse_payload::<CompleteUploadResponseFull>()?
-            .ok_or_else(|| DeployError::Upload("empty complete response".into()))?;
+        loop {
+            tokio::select! {
+                biased;
+                _ = self.cancel.cancelled() => return Err(DeployError::Cancelled),
+                Some(sent) = in_flight.next() => self.chunk_sent(sent?, up, events_tx).await,
+                _ = self.pause.resumed() => break,
+            }
+        }
 
-        if !complete_resp.success {
-            return Err(DeployError::Upload("upload completion failed".into()));
+        if held.is_ok() {
+            self.send_upload_control(
+                capydeploy_protocol::constants::MessageType::ResumeUpload,
+                up.upload_id,
+            )
+            .await
+            .map_err(|e| DeployError::Upload(format!("agent did not resume: {e}")))?;
+            up.resumed.send_modify(|n| *n += 1);
         }
+        self.emit_progress(events_tx, progress, "Resuming upload...")
+            .await;
+        Ok(())
+    }
 
-        Ok(CompleteUploadResult {
-            success: complete_resp.success,
-            path: complete_resp.path,
-            app_id: complete_resp.app_id,
-        })
+    /// Sends `pause_upload` or `resume_upload` for `upload_id`.
+    async fn send_upload_control(
+        &self,
+        msg_type: capydeploy_protocol::constants::MessageType,
+        upload_id: &str,
+    ) -> Result<(), DeployError> {
+        let payload = serde_json::json!({ "uploadId": upload_id });
+        self.conn.send_request(msg_type, &payload).await?;
+        Ok(())
+    }
+
+    /// Waits for one chunk in flight to be acknowledged.
+    async fn next_sent_chunk<F>(
+        &self,
+        in_flight: &mut FuturesUnordered<F>,
+        up: &mut WsUpload<'_>,
+        events_tx: &tokio::sync::mpsc::Sender<DeployEvent>,
+    ) -> Result<(), DeployError>
+    where
+        F: Future<Output = Result<SentChunk, DeployError>>,
+    {
+        tokio::select! {
+            biased;
+            _ = self.cancel.cancelled() => Err(DeployError::Cancelled),
+            sent = in_flight.next() => match sent {
+                Some(sent) => {
+                    self.chunk_sent(sent?, up, events_tx).await;
+                    Ok(())
+                }
+                None => Ok(()),
+            },
+            _ = self.pause.paused() => self.hold(in_flight, up, events_tx).await,
+        }
+    }
+
+    /// Accounts for an acknowledged chunk: adapts the chunk size, records
+    /// the resume offset and reports progress.
+    async fn chunk_sent(
+        &self,
+        sent: SentChunk,
+        up: &mut WsUpload<'_>,
+        events_tx: &tokio::sync::mpsc::Sender<DeployEvent>,
+    ) {
+        let file = &up.files[sent.file].relative_path;
+        let end = sent.offset + sent.size as i64;
+
+        if let Some(acked) = up.acked.get_mut(&sent.file)
+            && let Some(offset) = acked.ack(sent.offset, end)
+            && let Some(store) = self.resume
+            && let Err(e) = store.record(up.upload_id, file, offset).await
+        {
+            warn!(error = %e, "failed to update resume manifest");
+        }
+
+        let prev_size = up.chunk_size;
+        up.chunk_size = adapt_chunk_size(up.chunk_size, sent.rtt, up.max_chunk_size);
+        if up.chunk_size != prev_size {
+            debug!(
+                prev_bytes = prev_size,
+                new_bytes = up.chunk_size,
+                rtt_ms = sent.rtt.as_millis() as u64,
+                "adaptive chunk size adjusted"
+            );
+        }
+
+        // Acks are summed, so progress only moves forward even when
+        // chunks complete out of order.
+        up.uploaded += sent.size as i64;
+
+        // Progress: 0.1 to 0.85.
+        if up.total_size > 0 {
+            let progress = 0.1 + (up.uploaded as f64 / up.total_size as f64) * 0.75;
+            let status = format!("Uploading: {file}");
+            self.emit_progress(events_tx, progress, &status).await;
+        }
+    }
+
+    /// Sends local artwork images to the agent.
+    ///
+    /// Agents that accept batches get every image that fits in one frame
+    /// together. Any other image goes out on its own: in chunks if it's
+    /// larger than `max_payload` and the agent reassembles them, or not at
+    /// all otherwise.
+    async fn send_artwork(
+        &self,
+        artwork: &[LocalArtwork],
+        app_id: u32,
+        max_payload: usize,
+        events_tx: &tokio::sync::mpsc::Sender<DeployEvent>,
+    ) {
+        let mut singles: Vec<&LocalArtwork> = artwork.iter().collect();
+        if self.conn.supports_artwork_batch() {
+            let (batch, rest) = split_artwork_batch(artwork, max_payload);
+            if batch.len() > 1 {
+                match self.send_artwork_batch(&batch, app_id).await {
+                    Ok(resp) => {
+                        debug!(applied = ?resp.applied, "sent local artwork batch");
+                        for failed in &resp.failed {
+                            warn!(
+                                art_type = %failed.art_type,
+                                error = %failed.error,
+                                "agent rejected artwork"
+                            );
+                        }
+                        singles = rest;
+                    }
+                    Err(e) => {
+                        // Fall back to one frame per image.
+                        warn!(error = %e, "failed to send artwork batch");
+                    }
+                }
+            }
+        }
+
+        for art in singles {
+            if art.data.len() > max_payload && !self.conn.supports_chunked_artwork() {
+                warn!(
+                    art_type = %art.art_type,
+                    bytes = art.data.len(),
+                    max_payload,
+                    "artwork exceeds the agent's frame limit, skipping"
+                );
+                continue;
+            }
+
+            self.emit_progress(
+                events_tx,
+                0.87,
+                &format!("Sending {} artwork...", art.art_type),
+            )
+            .await;
+
+            match self.send_artwork_image(art, app_id, max_payload).await {
+                Ok(()) => {
+                    debug!(art_type = %art.art_type, "sent local artwork");
+                }
+                Err(e) => {
+                    warn!(art_type = %art.art_type, error = %e, "failed to send artwork");
+                }
+            }
+        }
+    }
+
+    /// Sends several artwork images in one `apply_artwork_batch` frame and
+    /// returns the agent's per-type outcome.
+    async fn send_artwork_batch(
+        &self,
+        batch: &[&LocalArtwork],
+        app_id: u32,
+    ) -> Result<ArtworkResponse, DeployError> {
+        let items: Vec<_> = batch
+            .iter()
+            .map(|art| {
+                serde_json::json!({
+                    "artworkType": art.art_type,
+                    "contentType": art.content_type,
+                    "checksum": capydeploy_transfer::checksum_bytes(&art.data),
+                })
+            })
+            .collect();
+        let header = serde_json::json!({
+            "type": "apply_artwork_batch",
+            "appId": app_id,
+            "items": items,
+        });
+
+        // One length-prefixed sub-frame per item, in order.
+        let mut payload = Vec::with_capacity(batch.iter().map(|a| 4 + a.data.len()).sum());
+        for art in batch {
+            payload.extend_from_slice(&(art.data.len() as u32).to_be_bytes());
+            payload.extend_from_slice(&art.data);
+        }
+
+        let resp = self.conn.send_binary(&header, &payload).await?;
+        resp.parse_payload::<ArtworkResponse>()
+            .ok()
+            .flatten()
+            .ok_or_else(|| DeployError::Artwork("artwork batch not acknowledged".into()))
+    }
+
+    /// Sends one artwork image with its SHA-256, split into chunks of at
+    /// most `max_payload` bytes when it doesn't fit in a frame.
+    ///
+    /// After each chunk the agent reports how many bytes it holds, and the
+    /// next chunk starts there, so a chunk the agent missed is re-sent.
+    async fn send_artwork_image(
+        &self,
+        art: &LocalArtwork,
+        app_id: u32,
+        max_payload: usize,
+    ) -> Result<(), DeployError> {
+        let total = art.data.len();
+        let chunked = total > max_payload;
+        let mut header = serde_json::json!({
+            "type": "artwork_image",
+            "appId": app_id,
+            "artworkType": art.art_type,
+            "contentType": art.content_type,
+            "checksum": capydeploy_transfer::checksum_bytes(&art.data),
+        });
+        if chunked {
+            header["totalSize"] = total.into();
+        }
+
+        let mut offset = 0;
+        let mut stalls = 0;
+        loop {
+            let end = if chunked {
+                (offset + max_payload).min(total)
+            } else {
+                total
+            };
+            header["offset"] = offset.into();
+            let resp = self
+                .conn
+                .send_binary(&header, &art.data[offset..end])
+                .await?;
+            let ack = resp.parse_payload::<ArtworkImageResponse>().ok().flatten();
+            if let Some(ack) = &ack
+                && !ack.success
+            {
+                return Err(DeployError::Artwork(ack.error.clone()));
+            }
+            if !chunked {
+                return Ok(());
+            }
+
+            let received = ack
+                .ok_or_else(|| DeployError::Artwork("artwork chunk not acknowledged".into()))?
+                .received as usize;
+            if received >= total {
+                return Ok(());
+            }
+            if received <= offset {
+                stalls += 1;
+                if stalls > MAX_ARTWORK_RESUMES {
+                    return Err(DeployError::Artwork(format!(
+                        "agent stopped accepting artwork at byte {received}"
+                    )));
+                }
+            }
+            offset = received;
+        }
+    }
+
+    /// Completes the upload and creates a shortcut, once the agent has
+    /// checked that `files` arrived with the right sizes.
+    async fn complete_upload(
+        &self,
+        upload_id: &str,
+        shortcut: &ShortcutConfig,
+        files: &[FileEntry],
+    ) -> Result<Completion, DeployError> {
+        let req = CompleteUploadRequestFull {
+            upload_id: upload_id.to_string(),
+            create_shortcut: true,
+            shortcut: Some(shortcut.clone()),
+            verify: files
+                .iter()
+                .map(|f| ExpectedFile {
+                    relative_path: f.relative_path.clone(),
+                    size: f.size,
+                    checksum: String::new(),
+                })
+                .collect(),
+        };
+
+        let payload = serde_json::to_value(&req)?;
+        let resp = self
+            .conn
+            .send_request(
+                capydeploy_protocol::constants::MessageType::CompleteUpload,
+                &payload,
+            )
+            .await?;
+
+        let complete_resp: CompleteUploadResponseFull = resp
+            .parse_payload::<CompleteUploadResponseFull>()?
+            .ok_or_else(|| DeployError::Upload("empty complete response".into()))?;
+
+        if !complete_resp.mismatched.is_empty() {
+            return Ok(Completion::Mismatched(complete_resp.mismatched));
+        }
+        if !complete_resp.success {
+            return Err(DeployError::Upload("upload completion failed".into()));
+        }
+
+        for warning in &complete_resp.warnings {
+            warn!(upload_id, "{warning}");
+        }
+
+        Ok(Completion::Done(CompleteUploadResult {
+            success: complete_resp.success,
+            path: complete_resp.path,
+            app_id: complete_resp.app_id,
+            warnings: complete_resp.warnings,
+        }))
     }
 
     fn check_cancelled(&self) -> Result<(), DeployError> {
@@ -553,12 +1495,29 @@ impl<'a> AgentDeploy<'a> {
     }
 }
 
+/// Picks the images that fit together in one batch frame of at most
+/// `max_payload` bytes, in order; the rest are returned separately.
+fn split_artwork_batch(
+    artwork: &[LocalArtwork],
+    max_payload: usize,
+) -> (Vec<&LocalArtwork>, Vec<&LocalArtwork>) {
+    let mut size = 0;
+    artwork.iter().partition(|art| {
+        let framed = 4 + art.data.len();
+        if size + framed > max_payload {
+            return false;
+        }
+        size += framed;
+        true
+    })
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
-    use crate::types::ArtworkAssignment;
-    use std::collections::HashMap;
+    use crate::types::{ArtworkAssignment, ArtworkSource};
     use std::sync::Mutex;
+    use std::sync::atomic::{AtomicBool, AtomicUsize, Ordering};
     use tokio::sync::mpsc;
 
     /// Mock agent connection that records requests.
@@ -567,6 +1526,25 @@ mod tests {
         responses: Mutex<Vec<Message>>,
         requests: Mutex<Vec<(String, serde_json::Value)>>,
         binary_sends: Mutex<Vec<(serde_json::Value, Vec<u8>)>>,
+        frame_limit: usize,
+        /// Reassembles artwork like the agent when set; completed images
+        /// are collected in `artwork_received`.
+        artwork_assembler: Option<Mutex<capydeploy_transfer::ArtworkAssembler>>,
+        artwork_received: Mutex<Vec<Vec<u8>>>,
+        /// Artwork frame (1-based) to lose, to exercise resuming.
+        lose_artwork_frame: Option<usize>,
+        /// Accepts `apply_artwork_batch` frames; their images are collected
+        /// in `artwork_received`.
+        artwork_batch: bool,
+        /// Advertises `get_disk_usage`.
+        disk_usage: bool,
+        /// How long each binary send takes to be acknowledged.
+        binary_delay: Duration,
+        binary_in_flight: AtomicUsize,
+        max_binary_in_flight: AtomicUsize,
+        /// Set between `pause_upload` and `resume_upload`; chunks that
+        /// arrive meanwhile are refused.
+        paused: AtomicBool,
     }
 
     impl MockAgent {
@@ -576,6 +1554,16 @@ mod tests {
                 responses: Mutex::new(Vec::new()),
                 requests: Mutex::new(Vec::new()),
                 binary_sends: Mutex::new(Vec::new()),
+                frame_limit: capydeploy_protocol::constants::WS_MAX_MESSAGE_SIZE,
+                artwork_assembler: None,
+                artwork_received: Mutex::new(Vec::new()),
+                lose_artwork_frame: None,
+                artwork_batch: false,
+                disk_usage: false,
+                binary_delay: Duration::ZERO,
+                binary_in_flight: AtomicUsize::new(0),
+                max_binary_in_flight: AtomicUsize::new(0),
+                paused: AtomicBool::new(false),
             }
         }
 
@@ -598,6 +1586,12 @@ mod tests {
             msg_type: capydeploy_protocol::constants::MessageType,
             payload: &serde_json::Value,
         ) -> Pin<Box<dyn Future<Output = Result<Message, DeployError>> + Send + '_>> {
+            use capydeploy_protocol::constants::MessageType;
+            match msg_type {
+                MessageType::PauseUpload => self.paused.store(true, Ordering::SeqCst),
+                MessageType::ResumeUpload => self.paused.store(false, Ordering::SeqCst),
+                _ => {}
+            }
             let msg_type_str = format!("{msg_type:?}");
             self.requests
                 .lock()
@@ -624,12 +1618,41 @@ mod tests {
                 .unwrap()
                 .push((header.clone(), data.to_vec()));
 
+            let ack = match &self.artwork_assembler {
+                Some(assembler) if header["type"] == "artwork_image" => {
+                    Some(self.assemble_artwork(assembler, header, data))
+                }
+                _ => None,
+            };
+            let batch_ack = (self.artwork_batch && header["type"] == "apply_artwork_batch")
+                .then(|| self.receive_artwork_batch(header, data));
             Box::pin(async move {
-                let msg = Message::new::<()>(
-                    "bin-resp",
-                    capydeploy_protocol::constants::MessageType::Pong,
-                    None,
-                )
+                let in_flight = self.binary_in_flight.fetch_add(1, Ordering::SeqCst) + 1;
+                self.max_binary_in_flight
+                    .fetch_max(in_flight, Ordering::SeqCst);
+                tokio::time::sleep(self.binary_delay).await;
+                self.binary_in_flight.fetch_sub(1, Ordering::SeqCst);
+                if self.paused.load(Ordering::SeqCst) {
+                    return Err(DeployError::UploadPaused);
+                }
+
+                let msg = match (ack, batch_ack) {
+                    (Some(ack), _) => Message::new(
+                        "art-resp",
+                        capydeploy_protocol::constants::MessageType::ArtworkImageResponse,
+                        Some(&ack),
+                    ),
+                    (None, Some(resp)) => Message::new(
+                        "batch-resp",
+                        capydeploy_protocol::constants::MessageType::ArtworkResponse,
+                        Some(&resp),
+                    ),
+                    (None, None) => Message::new::<()>(
+                        "bin-resp",
+                        capydeploy_protocol::constants::MessageType::Pong,
+                        None,
+                    ),
+                }
                 .unwrap();
                 Ok(msg)
             })
@@ -638,6 +1661,93 @@ mod tests {
         fn agent_id(&self) -> &str {
             &self.id
         }
+
+        fn max_binary_frame_size(&self) -> usize {
+            self.frame_limit
+        }
+
+        fn supports_chunked_artwork(&self) -> bool {
+            self.artwork_assembler.is_some()
+        }
+
+        fn supports_artwork_batch(&self) -> bool {
+            self.artwork_batch
+        }
+
+        fn supports_disk_usage(&self) -> bool {
+            self.disk_usage
+        }
+    }
+
+    impl MockAgent {
+        /// Splits a batch payload into its images and acknowledges them all.
+        fn receive_artwork_batch(
+            &self,
+            header: &serde_json::Value,
+            data: &[u8],
+        ) -> ArtworkResponse {
+            let mut rest = data;
+            while let Some((len, tail)) = rest.split_first_chunk::<4>() {
+                let len = u32::from_be_bytes(*len) as usize;
+                self.artwork_received
+                    .lock()
+                    .unwrap()
+                    .push(tail[..len].to_vec());
+                rest = &tail[len..];
+            }
+            ArtworkResponse {
+                applied: header["items"]
+                    .as_array()
+                    .unwrap()
+                    .iter()
+                    .map(|i| i["artworkType"].as_str().unwrap().to_string())
+                    .collect(),
+                failed: Vec::new(),
+            }
+        }
+
+        /// Feeds an artwork frame to `assembler` and builds the agent's
+        /// acknowledgment.
+        fn assemble_artwork(
+            &self,
+            assembler: &Mutex<capydeploy_transfer::ArtworkAssembler>,
+            header: &serde_json::Value,
+            data: &[u8],
+        ) -> ArtworkImageResponse {
+            let frames = self
+                .binary_sends
+                .lock()
+                .unwrap()
+                .iter()
+                .filter(|(h, _)| h["type"] == "artwork_image")
+                .count();
+            let offset = header["offset"].as_u64().unwrap_or_default();
+            let total_size = header["totalSize"].as_u64().unwrap_or_default();
+            let received = if self.lose_artwork_frame == Some(frames) {
+                offset
+            } else {
+                let frame = capydeploy_transfer::ArtworkFrame {
+                    offset,
+                    total_size,
+                    checksum: header["checksum"].as_str().unwrap_or_default(),
+                    data,
+                };
+                let key = header["artworkType"].as_str().unwrap_or_default();
+                match assembler.lock().unwrap().accept(key, frame).unwrap() {
+                    capydeploy_transfer::Assembled::Incomplete { received } => received,
+                    capydeploy_transfer::Assembled::Complete(image) => {
+                        self.artwork_received.lock().unwrap().push(image);
+                        total_size
+                    }
+                }
+            };
+            ArtworkImageResponse {
+                success: true,
+                artwork_type: header["artworkType"].as_str().unwrap_or_default().into(),
+                error: String::new(),
+                received,
+            }
+        }
     }
 
     fn make_init_response(upload_id: &str) -> Message {
@@ -647,6 +1757,10 @@ mod tests {
             resume_from: None,
             tcp_port: None,
             tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: String::new(),
+            skip_files: Vec::new(),
+            preview: None,
         };
         Message::new(
             "init-resp",
@@ -661,6 +1775,8 @@ mod tests {
             success,
             path: "/home/deck/Games/test".into(),
             app_id: 12345,
+            warnings: Vec::new(),
+            mismatched: Vec::new(),
         };
         Message::new(
             "complete-resp",
@@ -670,6 +1786,28 @@ mod tests {
         .unwrap()
     }
 
+    fn make_control_response(message: &str) -> Message {
+        let resp = capydeploy_protocol::messages::OperationResult {
+            success: true,
+            message: message.into(),
+        };
+        Message::new(
+            "control-resp",
+            capydeploy_protocol::constants::MessageType::OperationResult,
+            Some(&resp),
+        )
+        .unwrap()
+    }
+
+    fn request_types(mock: &MockAgent) -> Vec<String> {
+        mock.requests
+            .lock()
+            .unwrap()
+            .iter()
+            .map(|(t, _)| t.clone())
+            .collect()
+    }
+
     fn test_setup(dir: &Path) -> GameSetup {
         GameSetup {
             id: "g1".into(),
@@ -685,9 +1823,28 @@ mod tests {
             hero_image: String::new(),
             logo_image: String::new(),
             icon_image: String::new(),
+            boot_video: String::new(),
+            mark_recent: false,
+            artwork_all_users: false,
+            library_path: String::new(),
+            install_subpath: String::new(),
+            compat_tool: String::new(),
+            clean_install: false,
         }
     }
 
+    #[test]
+    fn acked_ranges_track_contiguous_prefix() {
+        let mut acked = AckedRanges::starting_at(100);
+        assert_eq!(acked.ack(300, 400), None);
+        assert_eq!(acked.ack(200, 300), None);
+        assert_eq!(acked.ack(100, 200), Some(400));
+        assert!(acked.pending.is_empty());
+        // A late duplicate doesn't move the prefix back.
+        assert_eq!(acked.ack(0, 100), None);
+        assert_eq!(acked.contiguous, 400);
+    }
+
     #[test]
     fn adapt_doubles_on_fast_rtt() {
         let size = adapt_chunk_size(1024 * 1024, Duration::from_millis(500), 16 * 1024 * 1024);
@@ -777,80 +1934,431 @@ mod tests {
         assert!(!events.is_empty());
     }
 
+    fn make_mismatch_response(path: &str) -> Message {
+        let resp = CompleteUploadResponseFull {
+            success: false,
+            path: "/home/deck/Games/test".into(),
+            app_id: 0,
+            warnings: Vec::new(),
+            mismatched: vec![FileMismatch {
+                relative_path: path.into(),
+                reason: capydeploy_protocol::constants::FILE_MISMATCH_SIZE.into(),
+                actual_size: 1,
+            }],
+        };
+        Message::new(
+            "complete-resp",
+            capydeploy_protocol::constants::MessageType::OperationResult,
+            Some(&resp),
+        )
+        .unwrap()
+    }
+
     #[tokio::test]
-    async fn deploy_cancelled_early() {
+    async fn deploy_resends_files_that_fail_verification() {
         let dir = tempfile::tempdir().unwrap();
         std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
+        std::fs::write(dir.path().join("data.pak"), b"DATA").unwrap();
 
         let mock = MockAgent::new("agent-1");
-        let cancel = CancellationToken::new();
-        cancel.cancel(); // Cancel before starting.
+        mock.push_response(make_init_response("upload-1"));
+        mock.push_response(make_mismatch_response("data.pak"));
+        mock.push_response(make_complete_response(true));
 
-        let deployer = AgentDeploy::new(&mock, cancel);
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new());
         let config = DeployConfig {
             setup: test_setup(dir.path()),
             artwork: ArtworkAssignment::default(),
         };
-
         let (events_tx, _events_rx) = mpsc::channel(64);
-        let result = deployer.deploy(&config, &events_tx).await;
-        assert!(matches!(result, Err(DeployError::Cancelled)));
+        let result = deployer.deploy(&config, &events_tx).await.unwrap();
+        assert_eq!(result.app_id, 12345);
+
+        // The manifest went with the first complete.
+        let requests = mock.requests.lock().unwrap();
+        let verify = requests[1].1["verify"].as_array().unwrap();
+        assert_eq!(verify.len(), 2);
+        assert!(
+            verify
+                .iter()
+                .any(|f| f["relativePath"] == "data.pak" && f["size"] == 4)
+        );
+
+        // Both files once, then data.pak again.
+        let sends = mock.binary_sends.lock().unwrap();
+        let paths: Vec<&str> = sends
+            .iter()
+            .map(|(h, _)| h["filePath"].as_str().unwrap())
+            .collect();
+        assert_eq!(paths.len(), 3);
+        assert_eq!(paths[2], "data.pak");
     }
 
     #[tokio::test]
-    async fn deploy_upload_failure() {
+    async fn deploy_fails_when_resent_files_still_mismatch() {
         let dir = tempfile::tempdir().unwrap();
         std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
 
         let mock = MockAgent::new("agent-1");
-        // Init response.
         mock.push_response(make_init_response("upload-1"));
-        // Complete response with failure.
-        mock.push_response(make_complete_response(false));
+        mock.push_response(make_mismatch_response("game.exe"));
+        mock.push_response(make_mismatch_response("game.exe"));
+        // cancel_upload after the failure.
+        mock.push_response(make_complete_response(true));
 
-        let cancel = CancellationToken::new();
-        let deployer = AgentDeploy::new(&mock, cancel);
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new());
         let config = DeployConfig {
             setup: test_setup(dir.path()),
             artwork: ArtworkAssignment::default(),
         };
+        let (events_tx, _events_rx) = mpsc::channel(64);
+        let err = deployer.deploy(&config, &events_tx).await.unwrap_err();
+        assert!(err.to_string().contains("game.exe (size)"), "{err}");
+        assert_eq!(mock.binary_count(), 2);
+    }
 
-        let (events_tx, _) = mpsc::channel(64);
-        let result = deployer.deploy(&config, &events_tx).await;
-        assert!(result.is_err());
+    fn make_disk_usage_response(free_bytes: u64) -> Message {
+        let usage = DiskUsage {
+            path: "/run/media/sdcard".into(),
+            total_bytes: 64 * 1024 * 1024 * 1024,
+            free_bytes,
+            used_bytes: 0,
+        };
+        Message::new(
+            "du",
+            capydeploy_protocol::constants::MessageType::DiskUsageResponse,
+            Some(&usage),
+        )
+        .unwrap()
     }
 
     #[tokio::test]
-    async fn deploy_with_resume() {
+    async fn deploy_refuses_upload_larger_than_free_space() {
         let dir = tempfile::tempdir().unwrap();
-        // 10 bytes file.
-        std::fs::write(dir.path().join("game.exe"), b"0123456789").unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
 
-        let mock = MockAgent::new("agent-1");
+        let mut mock = MockAgent::new("agent-1");
+        mock.disk_usage = true;
+        mock.push_response(make_disk_usage_response(2));
 
-        // Init response with resume point at 5 bytes.
-        let mut resume = HashMap::new();
-        resume.insert("game.exe".to_string(), 5i64);
-        let resp = InitUploadResponseFull {
-            upload_id: "upload-resume".into(),
-            chunk_size: 1024 * 1024,
-            resume_from: Some(resume),
-            tcp_port: None,
-            tcp_token: None,
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new());
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
         };
-        let init_msg = Message::new(
-            "init-resp",
-            capydeploy_protocol::constants::MessageType::UploadInitResponse,
-            Some(&resp),
-        )
-        .unwrap();
-        mock.push_response(init_msg);
 
-        // Complete.
+        let (events_tx, _) = mpsc::channel(64);
+        let result = deployer.deploy(&config, &events_tx).await;
+
+        let Err(DeployError::InsufficientSpace(msg)) = result else {
+            panic!("expected InsufficientSpace, got {result:?}");
+        };
+        assert!(msg.contains("/run/media/sdcard"));
+        // Only the disk usage request; init_upload was never sent.
+        assert_eq!(mock.request_count(), 1);
+        assert_eq!(mock.binary_count(), 0);
+    }
+
+    #[tokio::test]
+    async fn deploy_checks_free_space_before_init() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
+
+        let mut mock = MockAgent::new("agent-1");
+        mock.disk_usage = true;
+        mock.push_response(make_disk_usage_response(1024));
+        mock.push_response(make_init_response("upload-1"));
         mock.push_response(make_complete_response(true));
 
-        let cancel = CancellationToken::new();
-        let deployer = AgentDeploy::new(&mock, cancel);
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new());
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+
+        let (events_tx, _) = mpsc::channel(64);
+        let result = deployer.deploy(&config, &events_tx).await.unwrap();
+
+        assert!(result.success);
+        let requests = mock.requests.lock().unwrap();
+        assert_eq!(requests[0].0, "GetDiskUsage");
+        assert_eq!(requests[1].0, "InitUpload");
+    }
+
+    #[tokio::test]
+    async fn preview_reports_agent_dry_run() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
+        std::fs::write(dir.path().join("data.pak"), b"DATA").unwrap();
+
+        let mock = MockAgent::new("agent-1");
+        let resp = InitUploadResponseFull {
+            upload_id: String::new(),
+            chunk_size: 0,
+            resume_from: None,
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: String::new(),
+            skip_files: vec!["data.pak".into()],
+            preview: Some(capydeploy_protocol::messages::UploadPreview {
+                game_path: "/home/deck/Games/Test Game".into(),
+                transfer_bytes: 3,
+                available_bytes: Some(1),
+                blockers: vec![capydeploy_protocol::messages::DeployBlocker {
+                    code: "disk_full".into(),
+                    message: "no room".into(),
+                }],
+                warnings: vec!["artwork missing".into()],
+                conflict: Some(capydeploy_protocol::messages::ShortcutConflict {
+                    app_id: 7,
+                    name: "Test Game".into(),
+                    exe: String::new(),
+                }),
+            }),
+        };
+        mock.push_response(
+            Message::new(
+                "init-resp",
+                capydeploy_protocol::constants::MessageType::UploadInitResponse,
+                Some(&resp),
+            )
+            .unwrap(),
+        );
+
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment {
+                hero: ArtworkSource::Remote("https://cdn example.com/hero.png".into()),
+                ..Default::default()
+            },
+        };
+        let preview = AgentDeploy::new(&mock, CancellationToken::new())
+            .preview(&config)
+            .await
+            .unwrap();
+
+        assert_eq!(preview.file_count, 2);
+        assert_eq!(preview.total_bytes, 7);
+        assert_eq!(preview.transfer_bytes, 3);
+        assert_eq!(preview.skipped_files, 1);
+        assert_eq!(preview.game_path, "/home/deck/Games/Test Game");
+        assert!(!preview.can_deploy());
+        assert!(
+            preview
+                .warnings
+                .iter()
+                .any(|w| w.contains("hero artwork URL"))
+        );
+        assert!(preview.warnings.contains(&"artwork missing".to_string()));
+        assert_eq!(preview.conflict.map(|c| c.app_id), Some(7));
+
+        let requests = mock.requests.lock().unwrap();
+        assert_eq!(requests.len(), 1);
+        assert_eq!(requests[0].1["dryRun"], true);
+        assert_eq!(mock.binary_count(), 0);
+    }
+
+    #[tokio::test]
+    async fn launch_options_use_each_agents_install_path() {
+        let dir = tempfile::tempdir().unwrap();
+        let mut setup = test_setup(dir.path());
+        setup.install_path = String::new();
+        setup.launch_options = "%command% --data {installdir}/data".into();
+        let config = DeployConfig {
+            setup,
+            artwork: ArtworkAssignment::default(),
+        };
+
+        for (id, install_path) in [("deck", "/home/deck/Games"), ("ally", "D:\\Games")] {
+            let mock = MockAgent::new(id);
+            mock.push_response(
+                Message::new(
+                    "config-resp",
+                    capydeploy_protocol::constants::MessageType::ConfigResponse,
+                    Some(&ConfigResponse {
+                        install_path: install_path.into(),
+                    }),
+                )
+                .unwrap(),
+            );
+            let expanded = AgentDeploy::new(&mock, CancellationToken::new())
+                .expand_launch_options(&config)
+                .await
+                .unwrap();
+            let expected = match id {
+                "deck" => "%command% --data \"/home/deck/Games/Test Game\"/data",
+                _ => "%command% --data \"D:\\Games\\Test Game\"/data",
+            };
+            assert_eq!(expanded.setup.launch_options, expected);
+            assert_eq!(mock.requests.lock().unwrap()[0].0, "GetConfig");
+        }
+
+        // A setup with its own install directory doesn't ask the agent.
+        let mut config = config;
+        config.setup.install_path = "/games".into();
+        let mock = MockAgent::new("deck");
+        let expanded = AgentDeploy::new(&mock, CancellationToken::new())
+            .expand_launch_options(&config)
+            .await
+            .unwrap();
+        assert_eq!(
+            expanded.setup.launch_options,
+            "%command% --data \"/games/Test Game\"/data"
+        );
+        assert_eq!(mock.request_count(), 0);
+    }
+
+    #[test]
+    fn overwrite_is_sent_with_upload_request() {
+        let dir = tempfile::tempdir().unwrap();
+        let mock = MockAgent::new("agent-1");
+        let setup = test_setup(dir.path());
+
+        let req = AgentDeploy::new(&mock, CancellationToken::new()).upload_request(
+            &setup,
+            &[],
+            0,
+            "",
+            false,
+        );
+        assert!(!req.config.overwrite);
+
+        let req = AgentDeploy::new(&mock, CancellationToken::new())
+            .with_overwrite(true)
+            .upload_request(&setup, &[], 0, "", false);
+        assert!(req.config.overwrite);
+    }
+
+    #[test]
+    fn clean_install_follows_setup() {
+        let dir = tempfile::tempdir().unwrap();
+        let mock = MockAgent::new("agent-1");
+        let mut setup = test_setup(dir.path());
+        let deploy = AgentDeploy::new(&mock, CancellationToken::new());
+        assert!(
+            !deploy
+                .upload_request(&setup, &[], 0, "", false)
+                .config
+                .clean_install
+        );
+
+        setup.clean_install = true;
+        assert!(
+            deploy
+                .upload_request(&setup, &[], 0, "", false)
+                .config
+                .clean_install
+        );
+    }
+
+    #[tokio::test]
+    async fn preview_cancels_session_from_old_agent() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("other.exe"), b"EXE").unwrap();
+
+        let mock = MockAgent::new("agent-1");
+        mock.push_response(make_init_response("upload-1"));
+        mock.push_response(
+            Message::new::<()>("c", capydeploy_protocol::constants::MessageType::Pong, None)
+                .unwrap(),
+        );
+
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let preview = AgentDeploy::new(&mock, CancellationToken::new())
+            .preview(&config)
+            .await
+            .unwrap();
+
+        assert!(preview.can_deploy());
+        assert!(preview.warnings.iter().any(|w| w.contains("game.exe")));
+        assert!(preview.warnings.iter().any(|w| w.contains("can't preview")));
+        let requests = mock.requests.lock().unwrap();
+        assert_eq!(requests[1].0, "CancelUpload");
+        assert_eq!(requests[1].1["uploadId"], "upload-1");
+    }
+
+    #[tokio::test]
+    async fn deploy_cancelled_early() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
+
+        let mock = MockAgent::new("agent-1");
+        let cancel = CancellationToken::new();
+        cancel.cancel(); // Cancel before starting.
+
+        let deployer = AgentDeploy::new(&mock, cancel);
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+
+        let (events_tx, _events_rx) = mpsc::channel(64);
+        let result = deployer.deploy(&config, &events_tx).await;
+        assert!(matches!(result, Err(DeployError::Cancelled)));
+    }
+
+    #[tokio::test]
+    async fn deploy_upload_failure() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
+
+        let mock = MockAgent::new("agent-1");
+        // Init response.
+        mock.push_response(make_init_response("upload-1"));
+        // Complete response with failure.
+        mock.push_response(make_complete_response(false));
+
+        let cancel = CancellationToken::new();
+        let deployer = AgentDeploy::new(&mock, cancel);
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+
+        let (events_tx, _) = mpsc::channel(64);
+        let result = deployer.deploy(&config, &events_tx).await;
+        assert!(result.is_err());
+    }
+
+    #[tokio::test]
+    async fn deploy_with_resume() {
+        let dir = tempfile::tempdir().unwrap();
+        // 10 bytes file.
+        std::fs::write(dir.path().join("game.exe"), b"0123456789").unwrap();
+
+        let mock = MockAgent::new("agent-1");
+
+        // Init response with resume point at 5 bytes.
+        let mut resume = HashMap::new();
+        resume.insert("game.exe".to_string(), 5i64);
+        let resp = InitUploadResponseFull {
+            upload_id: "upload-resume".into(),
+            chunk_size: 1024 * 1024,
+            resume_from: Some(resume),
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: String::new(),
+            skip_files: Vec::new(),
+            preview: None,
+        };
+        let init_msg = Message::new(
+            "init-resp",
+            capydeploy_protocol::constants::MessageType::UploadInitResponse,
+            Some(&resp),
+        )
+        .unwrap();
+        mock.push_response(init_msg);
+
+        // Complete.
+        mock.push_response(make_complete_response(true));
+
+        let cancel = CancellationToken::new();
+        let deployer = AgentDeploy::new(&mock, cancel);
         let config = DeployConfig {
             setup: test_setup(dir.path()),
             artwork: ArtworkAssignment::default(),
@@ -865,4 +2373,643 @@ mod tests {
         assert_eq!(binaries.len(), 1);
         assert_eq!(binaries[0].1.len(), 5);
     }
+
+    #[tokio::test(start_paused = true)]
+    async fn deploy_respects_rate_limit() {
+        const MB: usize = 1024 * 1024;
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), vec![0u8; 3 * MB]).unwrap();
+
+        let mock = MockAgent::new("agent-1");
+        mock.push_response(make_init_response("upload-1"));
+        mock.push_response(make_complete_response(true));
+
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new())
+            .with_rate_limit(RateLimiter::new(MB as u64));
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let (events_tx, _events_rx) = mpsc::channel(64);
+        let start = tokio::time::Instant::now();
+        deployer.deploy(&config, &events_tx).await.unwrap();
+
+        // The first MB is the initial burst; the other two take 2s.
+        let elapsed = start.elapsed();
+        assert!(elapsed >= Duration::from_secs(2), "{elapsed:?}");
+        assert!(elapsed < Duration::from_secs(3), "{elapsed:?}");
+        let sent: usize = mock
+            .binary_sends
+            .lock()
+            .unwrap()
+            .iter()
+            .map(|(_, d)| d.len())
+            .sum();
+        assert_eq!(sent, 3 * MB);
+    }
+
+    /// Deploys a 1 MB file in 256 KB chunks to an agent advertising
+    /// `agent_max` concurrent chunks. Returns the most chunks the agent
+    /// saw in flight and the reported upload progress.
+    async fn pipelined_deploy(agent_max: u32, hub_max: usize) -> (usize, Vec<f64>) {
+        const KB: usize = 1024;
+        let dir = tempfile::tempdir().unwrap();
+        let data: Vec<u8> = (0..1024 * KB).map(|i| (i % 251) as u8).collect();
+        std::fs::write(dir.path().join("game.exe"), &data).unwrap();
+
+        let mut mock = MockAgent::new("agent-1");
+        mock.binary_delay = Duration::from_millis(200);
+        let resp = InitUploadResponseFull {
+            upload_id: "upload-1".into(),
+            chunk_size: 256 * KB as i32,
+            resume_from: None,
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: agent_max,
+            compression: String::new(),
+            skip_files: Vec::new(),
+            preview: None,
+        };
+        mock.push_response(
+            Message::new(
+                "init-resp",
+                capydeploy_protocol::constants::MessageType::UploadInitResponse,
+                Some(&resp),
+            )
+            .unwrap(),
+        );
+        mock.push_response(make_complete_response(true));
+
+        let deployer =
+            AgentDeploy::new(&mock, CancellationToken::new()).with_chunk_concurrency(hub_max);
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let (events_tx, mut events_rx) = mpsc::channel(64);
+        deployer.deploy(&config, &events_tx).await.unwrap();
+        drop(events_tx);
+
+        // Every byte arrives once, at its own offset.
+        let mut received = vec![0u8; data.len()];
+        let sends = mock.binary_sends.lock().unwrap();
+        assert_eq!(sends.len(), 4);
+        for (header, chunk) in sends.iter() {
+            let offset = header["offset"].as_u64().unwrap() as usize;
+            received[offset..offset + chunk.len()].copy_from_slice(chunk);
+        }
+        assert_eq!(received, data);
+
+        let mut progress = Vec::new();
+        while let Some(event) = events_rx.recv().await {
+            if let DeployEvent::Progress {
+                progress: p,
+                status,
+                ..
+            } = event
+                && status.starts_with("Uploading: ")
+            {
+                progress.push(p);
+            }
+        }
+        (mock.max_binary_in_flight.load(Ordering::SeqCst), progress)
+    }
+
+    #[tokio::test]
+    async fn paused_deploy_sends_nothing_until_resumed() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
+
+        let mock = MockAgent::new("agent-1");
+        mock.push_response(make_init_response("upload-1"));
+        mock.push_response(make_control_response("paused"));
+        mock.push_response(make_control_response("resumed"));
+        mock.push_response(make_complete_response(true));
+
+        let gate = PauseGate::new();
+        gate.pause();
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new()).with_pause(gate.clone());
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let (events_tx, _events_rx) = mpsc::channel(64);
+
+        let (result, ()) = tokio::join!(deployer.deploy(&config, &events_tx), async {
+            tokio::time::sleep(Duration::from_millis(100)).await;
+            assert_eq!(mock.binary_count(), 0);
+            assert!(mock.paused.load(Ordering::SeqCst));
+            gate.resume();
+        });
+
+        assert!(result.unwrap().success);
+        assert_eq!(mock.binary_count(), 1);
+        assert_eq!(
+            request_types(&mock),
+            [
+                "InitUpload",
+                "PauseUpload",
+                "ResumeUpload",
+                "CompleteUpload"
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn chunks_refused_while_paused_are_resent() {
+        const KB: usize = 1024;
+        let dir = tempfile::tempdir().unwrap();
+        let data: Vec<u8> = (0..1024 * KB).map(|i| (i % 251) as u8).collect();
+        std::fs::write(dir.path().join("game.exe"), &data).unwrap();
+
+        let mut mock = MockAgent::new("agent-1");
+        mock.binary_delay = Duration::from_millis(200);
+        let resp = InitUploadResponseFull {
+            upload_id: "upload-1".into(),
+            chunk_size: 256 * KB as i32,
+            resume_from: None,
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: 4,
+            compression: String::new(),
+            skip_files: Vec::new(),
+            preview: None,
+        };
+        mock.push_response(
+            Message::new(
+                "init-resp",
+                capydeploy_protocol::constants::MessageType::UploadInitResponse,
+                Some(&resp),
+            )
+            .unwrap(),
+        );
+        mock.push_response(make_control_response("paused"));
+        mock.push_response(make_control_response("resumed"));
+        mock.push_response(make_complete_response(true));
+
+        let gate = PauseGate::new();
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new()).with_pause(gate.clone());
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let (events_tx, _events_rx) = mpsc::channel(64);
+
+        // Pause while all four chunks are in flight; they reach the agent
+        // after it was paused and are refused.
+        let (result, ()) = tokio::join!(deployer.deploy(&config, &events_tx), async {
+            tokio::time::sleep(Duration::from_millis(50)).await;
+            gate.pause();
+            tokio::time::sleep(Duration::from_millis(300)).await;
+            assert_eq!(mock.binary_count(), 4);
+            gate.resume();
+        });
+        assert!(result.unwrap().success);
+
+        let sends = mock.binary_sends.lock().unwrap();
+        assert_eq!(sends.len(), 8);
+        let mut received = vec![0u8; data.len()];
+        for (header, chunk) in sends[4..].iter() {
+            let offset = header["offset"].as_u64().unwrap() as usize;
+            received[offset..offset + chunk.len()].copy_from_slice(chunk);
+        }
+        assert_eq!(received, data);
+    }
+
+    #[tokio::test]
+    async fn deploy_pipelines_chunks_up_to_agent_cap() {
+        let (max_in_flight, progress) = pipelined_deploy(4, DEFAULT_CHUNK_CONCURRENCY).await;
+        assert_eq!(max_in_flight, 4);
+        assert_eq!(progress.len(), 4);
+        assert!(progress.windows(2).all(|w| w[0] <= w[1]), "{progress:?}");
+        assert!((progress[3] - 0.85).abs() < 1e-9);
+
+        // The lower of the Hub's setting and the agent's cap wins.
+        assert_eq!(pipelined_deploy(4, 2).await.0, 2);
+        // Older agents don't advertise a cap and get one chunk at a time.
+        assert_eq!(pipelined_deploy(0, DEFAULT_CHUNK_CONCURRENCY).await.0, 1);
+    }
+
+    /// Deploys a compressible `game.exe` and an `intro.mp4` asking for
+    /// zstd, with the agent accepting `accepted`. Returns the binary sends.
+    async fn compressed_deploy(accepted: &str) -> (Vec<u8>, Vec<(serde_json::Value, Vec<u8>)>) {
+        let dir = tempfile::tempdir().unwrap();
+        let exe = b"compressible game data ".repeat(20_000);
+        std::fs::write(dir.path().join("game.exe"), &exe).unwrap();
+        std::fs::write(dir.path().join("intro.mp4"), b"movie ".repeat(1000)).unwrap();
+
+        let mock = MockAgent::new("agent-1");
+        let resp = InitUploadResponseFull {
+            upload_id: "upload-1".into(),
+            chunk_size: 1024 * 1024,
+            resume_from: None,
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: accepted.into(),
+            skip_files: Vec::new(),
+            preview: None,
+        };
+        mock.push_response(
+            Message::new(
+                "init-resp",
+                capydeploy_protocol::constants::MessageType::UploadInitResponse,
+                Some(&resp),
+            )
+            .unwrap(),
+        );
+        mock.push_response(make_complete_response(true));
+
+        let deployer =
+            AgentDeploy::new(&mock, CancellationToken::new()).with_compression(Compression::Zstd);
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let (events_tx, _events_rx) = mpsc::channel(64);
+        deployer.deploy(&config, &events_tx).await.unwrap();
+
+        let init = &mock.requests.lock().unwrap()[0].1;
+        assert_eq!(init["compression"], "zstd");
+        let sends = mock.binary_sends.lock().unwrap().clone();
+        (exe, sends)
+    }
+
+    #[tokio::test]
+    async fn deploy_compresses_chunks_when_agent_accepts() {
+        let (exe, sends) = compressed_deploy("zstd").await;
+
+        let (header, data) = sends
+            .iter()
+            .find(|(h, _)| h["filePath"] == "game.exe")
+            .unwrap();
+        assert_eq!(header["compressed"], true);
+        assert!(data.len() < exe.len() / 10);
+        let raw = Compression::Zstd.decompress(data, exe.len()).unwrap();
+        assert_eq!(raw, exe);
+        assert_eq!(
+            header["checksum"],
+            capydeploy_transfer::checksum_bytes(&exe)
+        );
+
+        // Already-compressed formats go out raw.
+        let (header, _) = sends
+            .iter()
+            .find(|(h, _)| h["filePath"] == "intro.mp4")
+            .unwrap();
+        assert!(header.get("compressed").is_none());
+    }
+
+    #[tokio::test]
+    async fn deploy_sends_raw_chunks_to_older_agents() {
+        let (exe, sends) = compressed_deploy("").await;
+        assert!(sends.iter().all(|(h, _)| h.get("compressed").is_none()));
+        let sent: usize = sends.iter().map(|(_, d)| d.len()).sum();
+        assert_eq!(sent, exe.len() + 6000);
+    }
+
+    #[tokio::test]
+    async fn deploy_skips_files_the_agent_has() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"unchanged").unwrap();
+        std::fs::write(dir.path().join("level1.dat"), b"edited level").unwrap();
+
+        let mock = MockAgent::new("agent-1");
+        let resp = InitUploadResponseFull {
+            upload_id: "upload-1".into(),
+            chunk_size: 1024 * 1024,
+            resume_from: None,
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: String::new(),
+            skip_files: vec!["game.exe".into()],
+            preview: None,
+        };
+        mock.push_response(
+            Message::new(
+                "init-resp",
+                capydeploy_protocol::constants::MessageType::UploadInitResponse,
+                Some(&resp),
+            )
+            .unwrap(),
+        );
+        mock.push_response(make_complete_response(true));
+
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new());
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let (events_tx, mut events_rx) = mpsc::channel(64);
+        deployer.deploy(&config, &events_tx).await.unwrap();
+        drop(events_tx);
+
+        // The agent gets modification times to compare against.
+        let init = &mock.requests.lock().unwrap()[0].1;
+        assert!(
+            init["files"]
+                .as_array()
+                .unwrap()
+                .iter()
+                .all(|f| f["modified"].as_i64().unwrap() > 0)
+        );
+
+        let sends = mock.binary_sends.lock().unwrap();
+        assert_eq!(sends.len(), 1);
+        assert_eq!(sends[0].0["filePath"], "level1.dat");
+        assert_eq!(sends[0].1, b"edited level");
+
+        let mut last_upload = 0.0;
+        while let Some(event) = events_rx.recv().await {
+            if let DeployEvent::Progress {
+                progress, status, ..
+            } = event
+                && status.starts_with("Uploading: ")
+            {
+                last_upload = progress;
+            }
+        }
+        assert!((last_upload - 0.85).abs() < 1e-9);
+    }
+
+    /// A store holding an interrupted upload of the files in `game_dir`.
+    fn resume_store(dir: &Path, game_dir: &Path, upload_id: &str) -> ResumeStore {
+        let store = ResumeStore::new(dir.join("resume.json")).unwrap();
+        let (files, _) = crate::scanner::scan_files_for_upload(game_dir).unwrap();
+        store
+            .begin(ResumeManifest::new(upload_id, "g1", "agent-1", &files))
+            .unwrap();
+        store
+    }
+
+    #[tokio::test]
+    async fn deploy_resumes_interrupted_upload() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"0123456789").unwrap();
+        let state = tempfile::tempdir().unwrap();
+        let store = resume_store(state.path(), dir.path(), "upload-old");
+
+        let mock = MockAgent::new("agent-1");
+        let resp = InitUploadResponseFull {
+            upload_id: "upload-old".into(),
+            chunk_size: 1024 * 1024,
+            resume_from: Some(HashMap::from([("game.exe".to_string(), 4i64)])),
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: String::new(),
+            skip_files: Vec::new(),
+            preview: None,
+        };
+        mock.push_response(
+            Message::new(
+                "init-resp",
+                capydeploy_protocol::constants::MessageType::UploadInitResponse,
+                Some(&resp),
+            )
+            .unwrap(),
+        );
+        mock.push_response(make_complete_response(true));
+
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new()).with_resume(&store);
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let (events_tx, _) = mpsc::channel(64);
+        deployer.deploy(&config, &events_tx).await.unwrap();
+
+        let requests = mock.requests.lock().unwrap();
+        assert_eq!(requests[0].1["resumeUploadId"], "upload-old");
+        let binaries = mock.binary_sends.lock().unwrap();
+        assert_eq!(binaries.len(), 1);
+        assert_eq!(binaries[0].0["offset"], 4);
+        assert_eq!(binaries[0].1, b"456789");
+        assert!(store.get("g1", "agent-1").is_none());
+    }
+
+    #[tokio::test]
+    async fn deploy_starts_over_when_upload_is_gone() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"0123456789").unwrap();
+        let state = tempfile::tempdir().unwrap();
+        let store = resume_store(state.path(), dir.path(), "upload-old");
+
+        let mock = MockAgent::new("agent-1");
+        mock.push_response(Message::error("init-resp", 404, "upload not found"));
+        mock.push_response(make_init_response("upload-new"));
+        mock.push_response(make_complete_response(true));
+
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new()).with_resume(&store);
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let (events_tx, _) = mpsc::channel(64);
+        deployer.deploy(&config, &events_tx).await.unwrap();
+
+        let requests = mock.requests.lock().unwrap();
+        assert_eq!(requests.len(), 3);
+        assert_eq!(requests[0].1["resumeUploadId"], "upload-old");
+        assert!(requests[1].1.get("resumeUploadId").is_none());
+        assert_eq!(mock.binary_sends.lock().unwrap()[0].1, b"0123456789");
+        assert!(store.get("g1", "agent-1").is_none());
+    }
+
+    #[tokio::test]
+    async fn interrupted_deploy_keeps_resume_manifest() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"0123456789").unwrap();
+        let state = tempfile::tempdir().unwrap();
+        let store = ResumeStore::new(state.path().join("resume.json")).unwrap();
+
+        // The connection is lost after the file is sent: neither
+        // complete_upload nor cancel_upload get an answer.
+        let mock = MockAgent::new("agent-1");
+        mock.push_response(make_init_response("upload-1"));
+
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new()).with_resume(&store);
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment::default(),
+        };
+        let (events_tx, _) = mpsc::channel(64);
+        assert!(deployer.deploy(&config, &events_tx).await.is_err());
+
+        let reopened = ResumeStore::new(state.path().join("resume.json")).unwrap();
+        let manifest = reopened.get("g1", "agent-1").unwrap();
+        assert_eq!(manifest.upload_id, "upload-1");
+        assert_eq!(manifest.offsets["game.exe"], 10);
+    }
+
+    #[tokio::test]
+    async fn deploy_respects_agent_frame_limit() {
+        use capydeploy_protocol::constants::{WS_MIN_BINARY_FRAME_SIZE, max_binary_payload};
+
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), vec![7u8; 6 * 1024 * 1024]).unwrap();
+        // Artwork lives outside the game folder, so it isn't uploaded.
+        let art_dir = tempfile::tempdir().unwrap();
+        let big_logo = art_dir.path().join("logo.png");
+        std::fs::write(&big_logo, vec![0u8; 3 * 1024 * 1024]).unwrap();
+        let icon = art_dir.path().join("icon.png");
+        std::fs::write(&icon, b"PNG").unwrap();
+
+        let mut mock = MockAgent::new("agent-1");
+        mock.frame_limit = WS_MIN_BINARY_FRAME_SIZE;
+        // The agent offers chunks larger than its own frame limit.
+        let resp = InitUploadResponseFull {
+            upload_id: "upload-small".into(),
+            chunk_size: 8 * 1024 * 1024,
+            resume_from: None,
+            tcp_port: None,
+            tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: String::new(),
+            skip_files: Vec::new(),
+            preview: None,
+        };
+        mock.push_response(
+            Message::new(
+                "init-resp",
+                capydeploy_protocol::constants::MessageType::UploadInitResponse,
+                Some(&resp),
+            )
+            .unwrap(),
+        );
+        mock.push_response(make_complete_response(true));
+
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new());
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment {
+                logo: ArtworkSource::Local(big_logo.to_string_lossy().into_owned()),
+                icon: ArtworkSource::Local(icon.to_string_lossy().into_owned()),
+                ..Default::default()
+            },
+        };
+
+        let (events_tx, _) = mpsc::channel(64);
+        deployer.deploy(&config, &events_tx).await.unwrap();
+
+        let max_payload = max_binary_payload(WS_MIN_BINARY_FRAME_SIZE);
+        let binaries = mock.binary_sends.lock().unwrap();
+        let (artwork, chunks): (Vec<_>, Vec<_>) = binaries
+            .iter()
+            .partition(|(header, _)| header.get("type").is_some());
+
+        assert!(chunks.iter().all(|(_, data)| data.len() <= max_payload));
+        assert_eq!(
+            chunks.iter().map(|(_, data)| data.len()).sum::<usize>(),
+            6 * 1024 * 1024
+        );
+        // The oversized logo is skipped; the icon still goes out.
+        assert_eq!(artwork.len(), 1);
+        assert_eq!(artwork[0].0["artworkType"], "icon");
+    }
+
+    #[tokio::test]
+    async fn large_artwork_is_chunked_and_resumed() {
+        use capydeploy_protocol::constants::{WS_MIN_BINARY_FRAME_SIZE, max_binary_payload};
+
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
+        let mut logo = b"\x89PNG\r\n\x1a\n".to_vec();
+        logo.resize(3 * 1024 * 1024, 0x5a);
+        let art_dir = tempfile::tempdir().unwrap();
+        let logo_path = art_dir.path().join("logo.png");
+        std::fs::write(&logo_path, &logo).unwrap();
+
+        let mut mock = MockAgent::new("agent-1");
+        mock.frame_limit = WS_MIN_BINARY_FRAME_SIZE;
+        mock.artwork_assembler = Some(Mutex::new(capydeploy_transfer::ArtworkAssembler::new()));
+        mock.lose_artwork_frame = Some(2);
+        mock.push_response(make_init_response("upload-1"));
+        mock.push_response(make_complete_response(true));
+
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new());
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment {
+                logo: ArtworkSource::Local(logo_path.to_string_lossy().into_owned()),
+                ..Default::default()
+            },
+        };
+        let (events_tx, _) = mpsc::channel(64);
+        deployer.deploy(&config, &events_tx).await.unwrap();
+
+        // The agent got the whole image, checksum verified.
+        assert_eq!(*mock.artwork_received.lock().unwrap(), vec![logo]);
+
+        let max_payload = max_binary_payload(WS_MIN_BINARY_FRAME_SIZE);
+        let binaries = mock.binary_sends.lock().unwrap();
+        let offsets: Vec<u64> = binaries
+            .iter()
+            .filter(|(h, _)| h["type"] == "artwork_image")
+            .map(|(h, data)| {
+                assert!(data.len() <= max_payload);
+                h["offset"].as_u64().unwrap()
+            })
+            .collect();
+        // The lost second chunk was sent again from the same offset.
+        assert_eq!(offsets[1], offsets[2]);
+        assert_eq!(offsets[0], 0);
+    }
+
+    #[tokio::test]
+    async fn artwork_is_sent_in_one_batch() {
+        use capydeploy_protocol::constants::{WS_MIN_BINARY_FRAME_SIZE, max_binary_payload};
+
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
+        let art_dir = tempfile::tempdir().unwrap();
+        let icon = art_dir.path().join("icon.png");
+        std::fs::write(&icon, b"ICON").unwrap();
+        let hero = art_dir.path().join("hero.png");
+        std::fs::write(&hero, b"HERO").unwrap();
+        // Too large to share a frame with the others.
+        let big_logo = art_dir.path().join("logo.png");
+        std::fs::write(
+            &big_logo,
+            vec![0u8; max_binary_payload(WS_MIN_BINARY_FRAME_SIZE) - 4],
+        )
+        .unwrap();
+
+        let mut mock = MockAgent::new("agent-1");
+        mock.frame_limit = WS_MIN_BINARY_FRAME_SIZE;
+        mock.artwork_batch = true;
+        mock.push_response(make_init_response("upload-1"));
+        mock.push_response(make_complete_response(true));
+
+        let deployer = AgentDeploy::new(&mock, CancellationToken::new());
+        let config = DeployConfig {
+            setup: test_setup(dir.path()),
+            artwork: ArtworkAssignment {
+                hero: ArtworkSource::Local(hero.to_string_lossy().into_owned()),
+                icon: ArtworkSource::Local(icon.to_string_lossy().into_owned()),
+                logo: ArtworkSource::Local(big_logo.to_string_lossy().into_owned()),
+                ..Default::default()
+            },
+        };
+        let (events_tx, _) = mpsc::channel(64);
+        deployer.deploy(&config, &events_tx).await.unwrap();
+
+        let binaries = mock.binary_sends.lock().unwrap();
+        let types: Vec<&str> = binaries
+            .iter()
+            .filter_map(|(h, _)| h["type"].as_str())
+            .collect();
+        assert_eq!(types, ["apply_artwork_batch", "artwork_image"]);
+        let batch = &binaries
+            .iter()
+            .find(|(h, _)| h["type"] == "apply_artwork_batch")
+            .unwrap()
+            .0;
+        assert_eq!(batch["items"].as_array().unwrap().len(), 2);
+
+        let received = mock.artwork_received.lock().unwrap();
+        assert!(received.contains(&b"HERO".to_vec()));
+        assert!(received.contains(&b"ICON".to_vec()));
+    }
 }
@@ -136,6 +136,8 @@ pub fn build_remote_artwork_config(assignment: &ArtworkAssignment) -> Option<Art
 /// Collects local artwork files that need to be sent via binary WS messages.
 ///
 /// Reads each `file://` path, detects content type, and returns the data.
+/// The type comes from the file's contents when recognized, else its
+/// extension.
 pub fn collect_local_artwork(assignment: &ArtworkAssignment) -> Vec<LocalArtwork> {
     let slots: [(&str, &ArtworkSource); 5] = [
         ("grid", &assignment.grid),
@@ -159,6 +161,10 @@ pub fn collect_local_artwork(assignment: &ArtworkAssignment) -> Vec<LocalArtwork
 
             match std::fs::read(path) {
                 Ok(data) => {
+                    // Trust the bytes over the extension, so a JPEG saved
+                    // as .png isn't rejected by the agent as mislabeled.
+                    let content_type = capydeploy_transfer::detect_image_type(&data)
+                        .map_or(content_type, str::to_string);
                     result.push(LocalArtwork {
                         art_type: art_type.to_string(),
                         content_type,
@@ -216,6 +222,10 @@ pub fn build_shortcut_config(
         launch_options: setup.launch_options.clone(),
         tags: parse_tags(&setup.tags),
         artwork: build_remote_artwork_config(artwork),
+        boot_video: setup.boot_video.clone(),
+        mark_recent: setup.mark_recent,
+        artwork_all_users: setup.artwork_all_users,
+        compat_tool: setup.compat_tool.clone(),
     }
 }
 
@@ -344,6 +354,13 @@ mod tests {
             hero_image: String::new(),
             logo_image: "https://cdn.com/logo.png".into(),
             icon_image: String::new(),
+            boot_video: String::new(),
+            mark_recent: false,
+            artwork_all_users: false,
+            library_path: String::new(),
+            install_subpath: String::new(),
+            compat_tool: String::new(),
+            clean_install: false,
         };
 
         let assignment = build_artwork_assignment(&setup);
@@ -370,6 +387,13 @@ mod tests {
             hero_image: String::new(),
             logo_image: String::new(),
             icon_image: String::new(),
+            boot_video: String::new(),
+            mark_recent: true,
+            artwork_all_users: false,
+            library_path: String::new(),
+            install_subpath: String::new(),
+            compat_tool: "proton_experimental".into(),
+            clean_install: false,
         };
         let assignment = build_artwork_assignment(&setup);
         let sc = build_shortcut_config(&setup, &assignment);
@@ -378,6 +402,8 @@ mod tests {
         assert_eq!(sc.exe, "my_game.exe");
         assert_eq!(sc.launch_options, "-fullscreen");
         assert_eq!(sc.tags, vec!["Action", "RPG"]);
+        assert!(sc.mark_recent);
+        assert_eq!(sc.compat_tool, "proton_experimental");
         assert!(sc.artwork.is_some());
         assert_eq!(sc.artwork.unwrap().grid, "https://cdn.com/grid.png");
     }
@@ -3,11 +3,18 @@
 //! Coordinates deployment to one or more agents, aggregates progress
 //! events, and supports cancellation.
 
+use std::sync::Arc;
+
+use capydeploy_transfer::{Compression, RateLimiter};
+use futures_util::future::join_all;
 use tokio::sync::mpsc;
 use tokio_util::sync::CancellationToken;
 use tracing::{error, info};
 
-use crate::agent::{AgentConnection, AgentDeploy};
+use crate::agent::{AgentConnection, AgentDeploy, DEFAULT_CHUNK_CONCURRENCY};
+use crate::error::DeployError;
+use crate::pause::PauseGate;
+use crate::resume::ResumeStore;
 use crate::types::{DeployConfig, DeployEvent, DeployResult};
 
 /// Orchestrates game deployment to multiple agents.
@@ -15,6 +22,12 @@ pub struct DeployOrchestrator {
     events_tx: mpsc::Sender<DeployEvent>,
     events_rx: Option<mpsc::Receiver<DeployEvent>>,
     cancel: CancellationToken,
+    pause: PauseGate,
+    resume: Option<Arc<ResumeStore>>,
+    rate: RateLimiter,
+    chunk_concurrency: usize,
+    compression: Compression,
+    overwrite: bool,
 }
 
 impl Default for DeployOrchestrator {
@@ -31,9 +44,41 @@ impl DeployOrchestrator {
             events_tx,
             events_rx: Some(events_rx),
             cancel: CancellationToken::new(),
+            pause: PauseGate::new(),
+            resume: None,
+            rate: RateLimiter::unlimited(),
+            chunk_concurrency: DEFAULT_CHUNK_CONCURRENCY,
+            compression: Compression::None,
+            overwrite: false,
         }
     }
 
+    /// Keeps uploads resumable across Hub restarts in `store`.
+    pub fn set_resume_store(&mut self, store: Arc<ResumeStore>) {
+        self.resume = Some(store);
+    }
+
+    /// Caps upload bandwidth at `bytes_per_sec`; zero means unlimited.
+    pub fn set_rate_limit(&mut self, bytes_per_sec: u64) {
+        self.rate = RateLimiter::new(bytes_per_sec);
+    }
+
+    /// Keeps up to `n` chunks in flight per agent on WebSocket uploads.
+    pub fn set_chunk_concurrency(&mut self, n: usize) {
+        self.chunk_concurrency = n;
+    }
+
+    /// Compresses WebSocket upload chunks with `codec` where agents allow.
+    pub fn set_compression(&mut self, codec: Compression) {
+        self.compression = codec;
+    }
+
+    /// Replaces shortcuts the agents already have for the game instead of
+    /// failing with a conflict.
+    pub fn set_overwrite(&mut self, overwrite: bool) {
+        self.overwrite = overwrite;
+    }
+
     /// Takes the event receiver. Can only be called once.
     pub fn take_events(&mut self) -> Option<mpsc::Receiver<DeployEvent>> {
         self.events_rx.take()
@@ -44,6 +89,11 @@ impl DeployOrchestrator {
         self.cancel.clone()
     }
 
+    /// Returns the gate that pauses this deployment's uploads.
+    pub fn pause_gate(&self) -> PauseGate {
+        self.pause.clone()
+    }
+
     /// Deploys a game to one or more agents in parallel.
     ///
     /// Each agent runs independently. If one fails, the others continue.
@@ -64,16 +114,14 @@ impl DeployOrchestrator {
             return vec![result];
         }
 
-        // Multiple agents — run in parallel.
-        // Note: we can't easily spawn tasks holding &dyn references,
-        // so we run them sequentially for now. The Hub UI will likely
-        // deploy to one agent at a time anyway.
-        let mut results = Vec::with_capacity(connections.len());
-        for conn in connections {
-            let result = self.deploy_single(conn, &config).await;
-            results.push(result);
-        }
-        results
+        // Multiple agents — run concurrently on this task; each deploy
+        // borrows its connection, so they can't be spawned.
+        join_all(
+            connections
+                .into_iter()
+                .map(|conn| self.deploy_single(conn, &config)),
+        )
+        .await
     }
 
     async fn deploy_single(
@@ -82,7 +130,15 @@ impl DeployOrchestrator {
         config: &DeployConfig,
     ) -> DeployResult {
         let agent_id = conn.agent_id().to_string();
-        let deployer = AgentDeploy::new(conn, self.cancel.clone());
+        let mut deployer = AgentDeploy::new(conn, self.cancel.clone())
+            .with_rate_limit(self.rate.clone())
+            .with_chunk_concurrency(self.chunk_concurrency)
+            .with_compression(self.compression)
+            .with_overwrite(self.overwrite)
+            .with_pause(self.pause.clone());
+        if let Some(store) = &self.resume {
+            deployer = deployer.with_resume(store);
+        }
 
         match deployer.deploy(config, &self.events_tx).await {
             Ok(result) => {
@@ -103,14 +159,19 @@ impl DeployOrchestrator {
             }
             Err(e) => {
                 let err_msg = e.to_string();
-                let _ = self
-                    .events_tx
-                    .send(DeployEvent::Failed {
+                let event = if matches!(e, DeployError::Cancelled) {
+                    info!(agent = %agent_id, "deploy cancelled");
+                    DeployEvent::Cancelled {
+                        agent_id: agent_id.clone(),
+                    }
+                } else {
+                    error!(agent = %agent_id, error = %err_msg, "deploy failed");
+                    DeployEvent::Failed {
                         agent_id: agent_id.clone(),
                         error: err_msg.clone(),
-                    })
-                    .await;
-                error!(agent = %agent_id, error = %err_msg, "deploy failed");
+                    }
+                };
+                let _ = self.events_tx.send(event).await;
 
                 DeployResult {
                     agent_id,
@@ -187,11 +248,17 @@ mod tests {
             resume_from: None,
             tcp_port: None,
             tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: String::new(),
+            skip_files: Vec::new(),
+            preview: None,
         };
         let complete = CompleteUploadResponseFull {
             success: true,
             path: "/games/test".into(),
             app_id: 42,
+            warnings: Vec::new(),
+            mismatched: Vec::new(),
         };
 
         vec![
@@ -217,6 +284,13 @@ mod tests {
                 hero_image: String::new(),
                 logo_image: String::new(),
                 icon_image: String::new(),
+                boot_video: String::new(),
+                mark_recent: false,
+                artwork_all_users: false,
+                library_path: String::new(),
+                install_subpath: String::new(),
+                compat_tool: String::new(),
+                clean_install: false,
             },
             artwork: ArtworkAssignment::default(),
         }
@@ -305,7 +379,8 @@ mod tests {
         std::fs::write(dir.path().join("game.exe"), b"X").unwrap();
 
         let conn = MockConn::new("a1", mock_responses());
-        let orch = DeployOrchestrator::new();
+        let mut orch = DeployOrchestrator::new();
+        let mut events_rx = orch.take_events().unwrap();
         let cancel = orch.cancel_token();
         cancel.cancel();
 
@@ -315,6 +390,23 @@ mod tests {
         assert_eq!(results.len(), 1);
         assert!(!results[0].success);
         assert!(results[0].error.as_deref().unwrap().contains("cancelled"));
+
+        // Reported as a cancellation, not a failure.
+        drop(orch);
+        let mut events = Vec::new();
+        while let Some(e) = events_rx.recv().await {
+            events.push(e);
+        }
+        assert!(
+            events
+                .iter()
+                .any(|e| matches!(e, DeployEvent::Cancelled { .. }))
+        );
+        assert!(
+            !events
+                .iter()
+                .any(|e| matches!(e, DeployEvent::Failed { .. }))
+        );
     }
 
     #[tokio::test]
@@ -0,0 +1,234 @@
+//! Setup detection for a dropped build folder.
+//!
+//! Guesses the game name and main executable so a folder dragged onto the
+//! Hub becomes a deployable [`GameSetup`] without filling in the form.
+
+use std::path::Path;
+
+use crate::error::DeployError;
+use crate::types::GameSetup;
+
+/// How deep below the folder root executables are looked for.
+const MAX_DEPTH: usize = 2;
+
+/// Name fragments of helper binaries that are never the game itself.
+const IGNORED_NAMES: &[&str] = &[
+    "unins",
+    "uninstall",
+    "crashhandler",
+    "crashreport",
+    "crashpad",
+    "setup",
+    "redist",
+    "vcredist",
+    "dxsetup",
+    "dotnet",
+    "updater",
+];
+
+/// Builds a setup for the build folder at `folder`.
+///
+/// The name is the folder name. The executable is chosen among Windows
+/// binaries, ELF binaries, shell scripts and AppImages, preferring one
+/// named like the folder, then the shallowest, then the largest. The
+/// returned setup has no ID; the caller assigns one when saving it.
+pub fn detect_setup(folder: &Path) -> Result<GameSetup, DeployError> {
+    if !folder.is_dir() {
+        return Err(DeployError::Detect(format!(
+            "{} is not a folder",
+            folder.display()
+        )));
+    }
+    let name = folder
+        .file_name()
+        .map(|n| n.to_string_lossy().into_owned())
+        .unwrap_or_default();
+
+    let mut candidates = Vec::new();
+    collect_candidates(folder, folder, 0, &mut candidates)?;
+
+    let wanted = normalize(&name);
+    let best = candidates
+        .into_iter()
+        .max_by_key(|c| {
+            let stem = Path::new(&c.relative_path)
+                .file_stem()
+                .map(|s| normalize(&s.to_string_lossy()))
+                .unwrap_or_default();
+            (
+                !wanted.is_empty() && stem == wanted,
+                std::cmp::Reverse(c.depth),
+                c.size,
+            )
+        })
+        .ok_or_else(|| DeployError::Detect(format!("no executable found in {name}")))?;
+
+    Ok(GameSetup {
+        id: String::new(),
+        name,
+        local_path: folder.to_string_lossy().into_owned(),
+        executable: best.relative_path,
+        launch_options: String::new(),
+        tags: String::new(),
+        install_path: String::new(),
+        griddb_game_id: 0,
+        grid_portrait: String::new(),
+        grid_landscape: String::new(),
+        hero_image: String::new(),
+        logo_image: String::new(),
+        icon_image: String::new(),
+        boot_video: String::new(),
+        mark_recent: false,
+        artwork_all_users: false,
+        library_path: String::new(),
+        install_subpath: String::new(),
+        compat_tool: String::new(),
+        clean_install: false,
+    })
+}
+
+struct Candidate {
+    /// Path relative to the folder, with `/` separators.
+    relative_path: String,
+    depth: usize,
+    size: u64,
+}
+
+fn collect_candidates(
+    root: &Path,
+    dir: &Path,
+    depth: usize,
+    out: &mut Vec<Candidate>,
+) -> Result<(), DeployError> {
+    for entry in std::fs::read_dir(dir)? {
+        let entry = entry?;
+        let path = entry.path();
+        let metadata = entry.metadata()?;
+        if metadata.is_dir() {
+            if depth + 1 < MAX_DEPTH {
+                collect_candidates(root, &path, depth + 1, out)?;
+            }
+        } else if metadata.is_file() && is_game_executable(&path) {
+            let rel = path.strip_prefix(root).map_err(std::io::Error::other)?;
+            out.push(Candidate {
+                relative_path: rel.to_string_lossy().replace('\\', "/"),
+                depth,
+                size: metadata.len(),
+            });
+        }
+    }
+    Ok(())
+}
+
+fn is_game_executable(path: &Path) -> bool {
+    let file_name = path
+        .file_name()
+        .map(|n| n.to_string_lossy().to_ascii_lowercase())
+        .unwrap_or_default();
+    if IGNORED_NAMES.iter().any(|n| file_name.contains(n)) {
+        return false;
+    }
+    match path
+        .extension()
+        .map(|e| e.to_string_lossy().to_ascii_lowercase())
+        .as_deref()
+    {
+        Some("exe" | "sh" | "appimage" | "x86_64" | "x86") => true,
+        None => is_elf(path),
+        _ => false,
+    }
+}
+
+fn is_elf(path: &Path) -> bool {
+    use std::io::Read;
+    let mut magic = [0u8; 4];
+    std::fs::File::open(path)
+        .and_then(|mut f| f.read_exact(&mut magic))
+        .is_ok_and(|()| magic == *b"\x7fELF")
+}
+
+/// Lowercases and drops everything but letters and digits, so
+/// "My_Game-1" matches "mygame1".
+fn normalize(s: &str) -> String {
+    s.chars()
+        .filter(char::is_ascii_alphanumeric)
+        .map(|c| c.to_ascii_lowercase())
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::fs;
+    use tempfile::TempDir;
+
+    fn build_folder(name: &str, files: &[(&str, &[u8])]) -> (TempDir, std::path::PathBuf) {
+        let dir = TempDir::new().unwrap();
+        let root = dir.path().join(name);
+        for (rel, data) in files {
+            let path = root.join(rel);
+            fs::create_dir_all(path.parent().unwrap()).unwrap();
+            fs::write(path, data).unwrap();
+        }
+        (dir, root)
+    }
+
+    #[test]
+    fn prefers_executable_named_like_folder() {
+        let (_dir, root) = build_folder(
+            "Hollow Knight",
+            &[
+                ("UnityCrashHandler64.exe", &[0; 4096]),
+                ("launcher.exe", &[0; 2048]),
+                ("hollow_knight.exe", &[0; 16]),
+                ("readme.txt", b"hi"),
+            ],
+        );
+        let setup = detect_setup(&root).unwrap();
+        assert_eq!(setup.name, "Hollow Knight");
+        assert_eq!(setup.executable, "hollow_knight.exe");
+        assert_eq!(setup.local_path, root.to_string_lossy());
+        assert!(setup.id.is_empty());
+    }
+
+    #[test]
+    fn falls_back_to_shallowest_then_largest() {
+        let (_dir, root) = build_folder(
+            "build-0412",
+            &[
+                ("bin/tool.exe", &[0; 8192]),
+                ("small.x86_64", &[0; 16]),
+                ("big.x86_64", &[0; 1024]),
+                ("unins000.exe", &[0; 65536]),
+            ],
+        );
+        assert_eq!(detect_setup(&root).unwrap().executable, "big.x86_64");
+    }
+
+    #[test]
+    fn finds_extensionless_elf_and_nested_binaries() {
+        let (_dir, root) = build_folder(
+            "Celeste",
+            &[
+                ("Celeste", b"\x7fELF\x02\x01\x01"),
+                ("Content/data", b"not elf"),
+            ],
+        );
+        assert_eq!(detect_setup(&root).unwrap().executable, "Celeste");
+
+        let (_dir, root) = build_folder("Game", &[("Binaries/Game.exe", &[0; 16])]);
+        assert_eq!(detect_setup(&root).unwrap().executable, "Binaries/Game.exe");
+    }
+
+    #[test]
+    fn fails_without_executable_or_folder() {
+        let (_dir, root) = build_folder("Docs", &[("manual.pdf", b"%PDF")]);
+        assert!(matches!(detect_setup(&root), Err(DeployError::Detect(_))));
+
+        let (_dir, root) = build_folder("Single", &[("game.exe", b"MZ")]);
+        assert!(matches!(
+            detect_setup(&root.join("game.exe")),
+            Err(DeployError::Detect(_))
+        ));
+    }
+}
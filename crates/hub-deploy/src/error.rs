@@ -18,12 +18,36 @@ pub enum DeployError {
     #[error("upload failed: {0}")]
     Upload(String),
 
+    #[error("not enough space on the agent: {0}")]
+    InsufficientSpace(String),
+
     #[error("artwork error: {0}")]
     Artwork(String),
 
+    #[error("invalid launch options: {0}")]
+    LaunchOptions(String),
+
+    #[error("cannot detect game: {0}")]
+    Detect(String),
+
+    #[error("cannot import shortcut: {0}")]
+    Import(String),
+
+    #[error("watch error: {0}")]
+    Watch(String),
+
     #[error("cancelled")]
     Cancelled,
 
+    /// The agent refused a chunk because the upload is paused.
+    #[error("upload paused on the agent")]
+    UploadPaused,
+
+    /// The agent already has a Steam shortcut for the game; deploy with
+    /// overwrite to replace it.
+    #[error("Steam already has a shortcut for '{}' (AppID {})", .0.name, .0.app_id)]
+    ShortcutConflict(capydeploy_protocol::messages::ShortcutConflict),
+
     #[error("SteamGridDB error: {0}")]
     SteamGridDb(#[from] capydeploy_steamgriddb::client::Error),
 
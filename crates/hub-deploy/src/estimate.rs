@@ -0,0 +1,260 @@
+//! Deploy duration estimates.
+//!
+//! Before a multi-GB push the Hub estimates how long it will take from the
+//! size of the local build and a recent throughput to the agent: the
+//! effective rate of its last successful deploys, or a short bandwidth
+//! probe when it has no history yet.
+
+use serde::{Deserialize, Serialize};
+use tokio::time::Instant;
+
+use crate::agent::AgentConnection;
+use crate::error::DeployError;
+use crate::history::DeployRecord;
+use capydeploy_protocol::constants::MessageType;
+
+/// Number of recent successful deploys averaged for the throughput.
+pub const HISTORY_SAMPLES: usize = 5;
+
+/// Size of the padding sent by the bandwidth probe (512 KB).
+pub const PROBE_BYTES: usize = 512 * 1024;
+
+/// Room left in a probe frame for the envelope around the padding.
+const PROBE_ENVELOPE_ROOM: usize = 1024;
+
+/// Where an estimate's throughput came from.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub enum ThroughputSource {
+    /// Measured over previous deploys to the agent.
+    History,
+    /// Measured by a probe just now.
+    Probe,
+}
+
+/// Estimated duration of a deploy.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DeployEstimate {
+    /// Size of the local game files, in bytes.
+    pub total_bytes: i64,
+    /// Throughput the estimate assumes, in bytes per second.
+    pub bytes_per_sec: f64,
+    /// Expected transfer time, in seconds.
+    pub duration_secs: f64,
+    pub source: ThroughputSource,
+}
+
+impl DeployEstimate {
+    /// Estimates the time to send `total_bytes` at `bytes_per_sec`.
+    pub fn new(total_bytes: i64, bytes_per_sec: f64, source: ThroughputSource) -> Self {
+        let duration_secs = if bytes_per_sec > 0.0 {
+            total_bytes.max(0) as f64 / bytes_per_sec
+        } else {
+            0.0
+        };
+        Self {
+            total_bytes,
+            bytes_per_sec,
+            duration_secs,
+            source,
+        }
+    }
+}
+
+/// Effective throughput of the newest successful deploys in `records`
+/// (newest first), or `None` if none recorded a duration.
+///
+/// Deploys are weighted by size, so one tiny deploy can't skew the rate.
+pub fn history_throughput(records: &[DeployRecord]) -> Option<f64> {
+    let (bytes, millis) = records
+        .iter()
+        .filter(|r| r.success && r.total_bytes > 0 && r.duration_ms > 0)
+        .take(HISTORY_SAMPLES)
+        .fold((0u64, 0u64), |(bytes, millis), r| {
+            (bytes + r.total_bytes as u64, millis + r.duration_ms)
+        });
+    (millis > 0).then(|| bytes as f64 * 1000.0 / millis as f64)
+}
+
+/// Measures the throughput to the agent by timing a padded `ping` against
+/// an empty one, so the round-trip latency cancels out.
+pub async fn probe_throughput(conn: &dyn AgentConnection) -> Result<f64, DeployError> {
+    let size = PROBE_BYTES.min(
+        conn.max_binary_frame_size()
+            .saturating_sub(PROBE_ENVELOPE_ROOM),
+    );
+    if size == 0 {
+        return Err(DeployError::Agent("frame limit too small to probe".into()));
+    }
+
+    let start = Instant::now();
+    conn.send_request(MessageType::Ping, &serde_json::json!({}))
+        .await?;
+    let baseline = start.elapsed();
+
+    let padded = serde_json::json!({ "probe": "0".repeat(size) });
+    let start = Instant::now();
+    conn.send_request(MessageType::Ping, &padded).await?;
+    let transfer = start
+        .elapsed()
+        .saturating_sub(baseline)
+        .max(std::time::Duration::from_millis(1));
+
+    Ok(size as f64 / transfer.as_secs_f64())
+}
+
+/// Estimates a deploy of `total_bytes` to `conn`, using the throughput of
+/// the agent's recent deploys in `history` (newest first) or a probe.
+pub async fn estimate_deploy(
+    conn: &dyn AgentConnection,
+    total_bytes: i64,
+    history: &[DeployRecord],
+) -> Result<DeployEstimate, DeployError> {
+    if let Some(rate) = history_throughput(history) {
+        return Ok(DeployEstimate::new(
+            total_bytes,
+            rate,
+            ThroughputSource::History,
+        ));
+    }
+    let rate = probe_throughput(conn).await?;
+    Ok(DeployEstimate::new(
+        total_bytes,
+        rate,
+        ThroughputSource::Probe,
+    ))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use capydeploy_protocol::envelope::Message;
+    use std::future::Future;
+    use std::pin::Pin;
+    use std::time::Duration;
+
+    const MB: i64 = 1024 * 1024;
+
+    /// Answers every request after a fixed latency plus the time its
+    /// payload takes at `bytes_per_sec`.
+    struct LinkConn {
+        latency: Duration,
+        bytes_per_sec: f64,
+    }
+
+    impl AgentConnection for LinkConn {
+        fn send_request(
+            &self,
+            _msg_type: MessageType,
+            payload: &serde_json::Value,
+        ) -> Pin<Box<dyn Future<Output = Result<Message, DeployError>> + Send + '_>> {
+            let len = payload.to_string().len();
+            Box::pin(async move {
+                let transfer = Duration::from_secs_f64(len as f64 / self.bytes_per_sec);
+                tokio::time::sleep(self.latency + transfer).await;
+                Ok(Message::new::<()>("p", MessageType::Pong, None).unwrap())
+            })
+        }
+
+        fn send_binary(
+            &self,
+            _header: &serde_json::Value,
+            _data: &[u8],
+        ) -> Pin<Box<dyn Future<Output = Result<Message, DeployError>> + Send + '_>> {
+            Box::pin(async { Err(DeployError::Agent("unused".into())) })
+        }
+
+        fn agent_id(&self) -> &str {
+            "deck"
+        }
+    }
+
+    fn record(total_bytes: i64, duration_ms: u64, success: bool) -> DeployRecord {
+        DeployRecord {
+            id: String::new(),
+            setup_id: "s".into(),
+            game_name: "Game".into(),
+            agent_id: "deck".into(),
+            agent_name: String::new(),
+            finished_at: 0,
+            success,
+            error: String::new(),
+            total_bytes,
+            app_id: 0,
+            duration_ms,
+        }
+    }
+
+    #[test]
+    fn estimate_reflects_throughput() {
+        // 8 minutes for 4.8 GB at 10 MB/s.
+        let e = DeployEstimate::new(4800 * MB, 10.0 * MB as f64, ThroughputSource::History);
+        assert_eq!(e.duration_secs, 480.0);
+
+        let faster = DeployEstimate::new(4800 * MB, 40.0 * MB as f64, ThroughputSource::History);
+        assert_eq!(faster.duration_secs, 120.0);
+
+        let unknown = DeployEstimate::new(MB, 0.0, ThroughputSource::Probe);
+        assert_eq!(unknown.duration_secs, 0.0);
+    }
+
+    #[test]
+    fn estimate_scales_with_total_size() {
+        let rate = 25.0 * MB as f64;
+        let small = DeployEstimate::new(500 * MB, rate, ThroughputSource::History);
+        let large = DeployEstimate::new(5000 * MB, rate, ThroughputSource::History);
+        assert_eq!(small.duration_secs, 20.0);
+        assert_eq!(large.duration_secs, small.duration_secs * 10.0);
+    }
+
+    #[test]
+    fn history_throughput_uses_recent_successes() {
+        let records = vec![
+            record(100 * MB, 10_000, true),
+            // Failed and unmeasured deploys don't count.
+            record(100 * MB, 1_000, false),
+            record(100 * MB, 0, true),
+            record(300 * MB, 10_000, true),
+        ];
+        // 400 MB over 20 s.
+        assert_eq!(history_throughput(&records), Some(20.0 * MB as f64));
+        assert_eq!(history_throughput(&records[1..3]), None);
+        assert_eq!(history_throughput(&[]), None);
+
+        // Only the newest samples are averaged.
+        let mut many = vec![record(10 * MB, 1_000, true); HISTORY_SAMPLES];
+        many.push(record(1000 * MB, 1_000, true));
+        assert_eq!(history_throughput(&many), Some(10.0 * MB as f64));
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn probe_measures_link_speed_without_latency() {
+        let conn = LinkConn {
+            latency: Duration::from_millis(80),
+            bytes_per_sec: 5.0 * MB as f64,
+        };
+        let rate = probe_throughput(&conn).await.unwrap();
+        assert!(
+            (rate / conn.bytes_per_sec - 1.0).abs() < 0.01,
+            "rate {rate}"
+        );
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn estimate_prefers_history_and_falls_back_to_probe() {
+        let conn = LinkConn {
+            latency: Duration::from_millis(20),
+            bytes_per_sec: 2.0 * MB as f64,
+        };
+
+        let history = [record(600 * MB, 60_000, true)];
+        let e = estimate_deploy(&conn, 1200 * MB, &history).await.unwrap();
+        assert_eq!(e.source, ThroughputSource::History);
+        assert_eq!(e.duration_secs, 120.0);
+
+        let e = estimate_deploy(&conn, 1200 * MB, &[]).await.unwrap();
+        assert_eq!(e.source, ThroughputSource::Probe);
+        assert!((e.duration_secs / 600.0 - 1.0).abs() < 0.01, "{e:?}");
+    }
+}
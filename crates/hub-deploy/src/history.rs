@@ -0,0 +1,280 @@
+//! Persistent deployment history.
+//!
+//! Every finished deploy (successful or not) is appended to a JSON file so
+//! users can look back at what was pushed to which device and when. The
+//! file is bounded: once it holds `limit` records, the oldest are dropped.
+
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+
+use serde::{Deserialize, Serialize};
+use tracing::debug;
+
+use crate::error::DeployError;
+
+/// Default number of records kept on disk.
+pub const DEFAULT_HISTORY_LIMIT: usize = 500;
+
+/// One finished deploy.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DeployRecord {
+    pub id: String,
+    pub setup_id: String,
+    pub game_name: String,
+    pub agent_id: String,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub agent_name: String,
+    /// Unix timestamp (seconds) when the deploy finished.
+    pub finished_at: i64,
+    pub success: bool,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub error: String,
+    /// Size of the local game files, in bytes.
+    pub total_bytes: i64,
+    #[serde(default, skip_serializing_if = "is_zero_u32")]
+    pub app_id: u32,
+    /// How long the deploy took, in milliseconds (0 = not measured).
+    #[serde(default, skip_serializing_if = "is_zero_u64")]
+    pub duration_ms: u64,
+}
+
+fn is_zero_u32(v: &u32) -> bool {
+    *v == 0
+}
+
+fn is_zero_u64(v: &u64) -> bool {
+    *v == 0
+}
+
+/// Narrows a history query. Empty fields match everything.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct HistoryFilter {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub agent_id: Option<String>,
+    /// Only records finished at or after this Unix timestamp.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub since: Option<i64>,
+    /// Only records finished before this Unix timestamp.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub until: Option<i64>,
+    /// Maximum number of records returned (newest first).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub limit: Option<usize>,
+}
+
+impl HistoryFilter {
+    fn matches(&self, record: &DeployRecord) -> bool {
+        self.agent_id
+            .as_deref()
+            .is_none_or(|id| id.is_empty() || record.agent_id == id)
+            && self.since.is_none_or(|t| record.finished_at >= t)
+            && self.until.is_none_or(|t| record.finished_at < t)
+    }
+}
+
+/// Bounded, disk-backed log of finished deploys.
+pub struct DeployHistory {
+    path: PathBuf,
+    limit: usize,
+    records: Mutex<Vec<DeployRecord>>,
+}
+
+impl DeployHistory {
+    /// Opens the history at `path`, keeping at most `limit` records.
+    pub fn new(path: PathBuf, limit: usize) -> Result<Self, DeployError> {
+        let mut records = load_records(&path)?;
+        trim(&mut records, limit);
+        Ok(Self {
+            path,
+            limit,
+            records: Mutex::new(records),
+        })
+    }
+
+    /// Appends a record, filling in the ID and timestamp when unset, and
+    /// drops the oldest records beyond the limit.
+    pub fn append(&self, mut record: DeployRecord) -> Result<(), DeployError> {
+        if record.id.is_empty() {
+            record.id = uuid::Uuid::new_v4().to_string();
+        }
+        if record.finished_at == 0 {
+            record.finished_at = unix_now();
+        }
+        {
+            let mut records = self.records.lock().unwrap();
+            records.push(record);
+            trim(&mut records, self.limit);
+        }
+        self.persist()
+    }
+
+    /// Returns the records matching `filter`, newest first.
+    pub fn query(&self, filter: &HistoryFilter) -> Vec<DeployRecord> {
+        let records = self.records.lock().unwrap();
+        records
+            .iter()
+            .rev()
+            .filter(|r| filter.matches(r))
+            .take(filter.limit.unwrap_or(usize::MAX))
+            .cloned()
+            .collect()
+    }
+
+    /// Removes all records.
+    pub fn clear(&self) -> Result<(), DeployError> {
+        self.records.lock().unwrap().clear();
+        self.persist()
+    }
+
+    /// Writes the current history to disk.
+    fn persist(&self) -> Result<(), DeployError> {
+        let records = self.records.lock().unwrap();
+        let json = serde_json::to_string_pretty(&*records)?;
+        if let Some(parent) = self.path.parent() {
+            std::fs::create_dir_all(parent)?;
+        }
+        std::fs::write(&self.path, json)?;
+        debug!(
+            "persisted {} deploy record(s) to {:?}",
+            records.len(),
+            self.path
+        );
+        Ok(())
+    }
+}
+
+/// Keeps only the newest `limit` records (records are in append order).
+fn trim(records: &mut Vec<DeployRecord>, limit: usize) {
+    if records.len() > limit {
+        let excess = records.len() - limit;
+        records.drain(..excess);
+    }
+}
+
+fn load_records(path: &Path) -> Result<Vec<DeployRecord>, DeployError> {
+    if !path.exists() {
+        return Ok(Vec::new());
+    }
+    let data = std::fs::read_to_string(path)?;
+    if data.trim().is_empty() {
+        return Ok(Vec::new());
+    }
+    Ok(serde_json::from_str(&data)?)
+}
+
+fn unix_now() -> i64 {
+    std::time::SystemTime::now()
+        .duration_since(std::time::UNIX_EPOCH)
+        .map(|d| d.as_secs() as i64)
+        .unwrap_or_default()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn history_path(dir: &tempfile::TempDir) -> PathBuf {
+        dir.path().join("hub").join("deploy_history.json")
+    }
+
+    fn record(game: &str, agent: &str, finished_at: i64) -> DeployRecord {
+        DeployRecord {
+            id: String::new(),
+            setup_id: format!("setup-{game}"),
+            game_name: game.into(),
+            agent_id: agent.into(),
+            agent_name: String::new(),
+            finished_at,
+            success: true,
+            error: String::new(),
+            total_bytes: 1024,
+            app_id: 0,
+            duration_ms: 0,
+        }
+    }
+
+    #[test]
+    fn append_persists_across_reopen() {
+        let dir = tempfile::tempdir().unwrap();
+        let h = DeployHistory::new(history_path(&dir), DEFAULT_HISTORY_LIMIT).unwrap();
+        h.append(record("a", "deck", 100)).unwrap();
+        h.append(DeployRecord {
+            success: false,
+            error: "disk full".into(),
+            ..record("b", "deck", 0)
+        })
+        .unwrap();
+
+        let reopened = DeployHistory::new(history_path(&dir), DEFAULT_HISTORY_LIMIT).unwrap();
+        let all = reopened.query(&HistoryFilter::default());
+        assert_eq!(all.len(), 2);
+        // Newest first; missing ID and timestamp are filled in.
+        assert_eq!(all[0].game_name, "b");
+        assert!(!all[0].success);
+        assert_eq!(all[0].error, "disk full");
+        assert!(all[0].finished_at > 100);
+        assert!(all.iter().all(|r| !r.id.is_empty()));
+    }
+
+    #[test]
+    fn filter_by_agent_and_date() {
+        let dir = tempfile::tempdir().unwrap();
+        let h = DeployHistory::new(history_path(&dir), DEFAULT_HISTORY_LIMIT).unwrap();
+        h.append(record("a", "deck", 100)).unwrap();
+        h.append(record("b", "ally", 200)).unwrap();
+        h.append(record("c", "deck", 300)).unwrap();
+
+        let deck = h.query(&HistoryFilter {
+            agent_id: Some("deck".into()),
+            ..Default::default()
+        });
+        let games: Vec<&str> = deck.iter().map(|r| r.game_name.as_str()).collect();
+        assert_eq!(games, vec!["c", "a"]);
+
+        let window = h.query(&HistoryFilter {
+            since: Some(150),
+            until: Some(300),
+            ..Default::default()
+        });
+        assert_eq!(window.len(), 1);
+        assert_eq!(window[0].game_name, "b");
+
+        let latest = h.query(&HistoryFilter {
+            limit: Some(1),
+            ..Default::default()
+        });
+        assert_eq!(latest[0].game_name, "c");
+    }
+
+    #[test]
+    fn history_is_bounded() {
+        let dir = tempfile::tempdir().unwrap();
+        let h = DeployHistory::new(history_path(&dir), 3).unwrap();
+        for i in 0..5 {
+            h.append(record(&format!("g{i}"), "deck", 100 + i)).unwrap();
+        }
+        let games: Vec<String> = h
+            .query(&HistoryFilter::default())
+            .into_iter()
+            .map(|r| r.game_name)
+            .collect();
+        assert_eq!(games, vec!["g4", "g3", "g2"]);
+
+        // A smaller limit on reopen trims the file's records too.
+        let reopened = DeployHistory::new(history_path(&dir), 2).unwrap();
+        assert_eq!(reopened.query(&HistoryFilter::default()).len(), 2);
+    }
+
+    #[test]
+    fn missing_file_is_empty_history() {
+        let dir = tempfile::tempdir().unwrap();
+        let h = DeployHistory::new(history_path(&dir), DEFAULT_HISTORY_LIMIT).unwrap();
+        assert!(h.query(&HistoryFilter::default()).is_empty());
+        h.append(record("a", "deck", 100)).unwrap();
+        h.clear().unwrap();
+        let reopened = DeployHistory::new(history_path(&dir), DEFAULT_HISTORY_LIMIT).unwrap();
+        assert!(reopened.query(&HistoryFilter::default()).is_empty());
+    }
+}
@@ -0,0 +1,416 @@
+//! Launch option templates with variable substitution.
+//!
+//! A template is an ordinary Steam launch option string that may reference
+//! variables in braces, e.g. `PROTON_LOG=1 %command% --data {installdir}`.
+//! Variables are substituted at deploy time from the game setup, separately
+//! for each agent, since a setup without its own install directory lands in
+//! the agent's default one. Steam's own `%command%` token is left untouched,
+//! and `{{` / `}}` produce literal braces.
+//!
+//! Substituted values end up in a string Steam hands to a shell, so values
+//! containing shell metacharacters are rejected instead of being escaped.
+
+use capydeploy_protocol::constants::STEAM_LIBRARY_GAMES_DIR;
+
+use crate::error::DeployError;
+use crate::types::GameSetup;
+
+/// Variables accepted in launch option templates.
+pub const LAUNCH_VARIABLES: &[&str] = &["installdir", "exe", "name"];
+
+/// Characters that must never appear in a substituted value.
+const UNSAFE_CHARS: &[char] = &[
+    '"', '\'', '`', '$', ';', '&', '|', '<', '>', '(', ')', '\n', '\r',
+];
+
+/// Values for the template variables of one game setup.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct LaunchVariables {
+    /// Game installation directory on the agent.
+    pub installdir: String,
+    /// Full path of the game executable on the agent.
+    pub exe: String,
+    /// Game display name.
+    pub name: String,
+}
+
+impl LaunchVariables {
+    /// Derives the variables from a game setup deployed to an agent whose
+    /// default install directory is `agent_install_path`.
+    ///
+    /// The agent installs into `<install_path>/<name>`, or
+    /// `<library_path>/Games/<name>` when a Steam library is targeted, with
+    /// any `install_subpath` before the name; the separator follows the
+    /// style already used by that path. A setup without an absolute
+    /// `install_path` installs into the agent's default directory; when
+    /// that isn't known either, `installdir` and `exe` are left empty.
+    pub fn from_setup(setup: &GameSetup, agent_install_path: &str) -> Self {
+        let root = if !setup.library_path.is_empty() {
+            &setup.library_path
+        } else if needs_agent_install_path(setup) {
+            agent_install_path.trim()
+        } else {
+            setup.install_path.trim()
+        };
+        if root.is_empty() {
+            return Self {
+                installdir: String::new(),
+                exe: String::new(),
+                name: setup.name.clone(),
+            };
+        }
+        let sep = if root.contains('\\') && !root.contains('/') {
+            '\\'
+        } else {
+            '/'
+        };
+        let mut base = root.trim_end_matches(['/', '\\']).to_string();
+        if !setup.library_path.is_empty() {
+            base = format!("{base}{sep}{STEAM_LIBRARY_GAMES_DIR}");
+        }
+        for part in setup
+            .install_subpath
+            .split(['/', '\\'])
+            .filter(|p| !p.is_empty() && *p != ".")
+        {
+            base = format!("{base}{sep}{part}");
+        }
+        let installdir = format!("{base}{sep}{}", setup.name);
+        let exe = format!("{installdir}{sep}{}", setup.executable);
+        Self {
+            installdir,
+            exe,
+            name: setup.name.clone(),
+        }
+    }
+
+    fn get(&self, var: &str) -> Option<&str> {
+        match var {
+            "installdir" => Some(&self.installdir),
+            "exe" => Some(&self.exe),
+            "name" => Some(&self.name),
+            _ => None,
+        }
+    }
+}
+
+/// Whether `setup` installs into the agent's default directory, so the
+/// agent's configured install path is needed to expand `{installdir}` and
+/// `{exe}`. Agents ignore an empty or relative `install_path`.
+pub fn needs_agent_install_path(setup: &GameSetup) -> bool {
+    let path = setup.install_path.trim();
+    setup.library_path.is_empty()
+        && !(path.starts_with(['/', '\\', '~'])
+            || path.get(1..3).is_some_and(|s| s == ":\\" || s == ":/"))
+}
+
+/// Whether `template` refers to a variable that depends on where the game
+/// is installed.
+pub fn uses_install_dir(template: &str) -> bool {
+    let mut found = false;
+    let _ = walk(template, |var| {
+        found |= var == "installdir" || var == "exe";
+        Ok(String::new())
+    });
+    found
+}
+
+/// Checks that a template only references known variables and is well formed.
+pub fn validate_template(template: &str) -> Result<(), DeployError> {
+    walk(template, |_| Ok(String::new())).map(|_| ())
+}
+
+/// Substitutes `vars` into `template`.
+///
+/// Fails on unknown variables, unbalanced braces, or substituted values that
+/// contain shell metacharacters. Values with whitespace are double-quoted.
+pub fn expand_template(template: &str, vars: &LaunchVariables) -> Result<String, DeployError> {
+    walk(template, |var| {
+        let value = vars
+            .get(var)
+            .ok_or_else(|| DeployError::LaunchOptions(format!("unknown variable {{{var}}}")))?;
+        if value.is_empty() {
+            return Err(DeployError::LaunchOptions(format!(
+                "{{{var}}} is unknown: the agent's install directory is not known"
+            )));
+        }
+        quote_value(var, value)
+    })
+}
+
+/// Picks the launch option template for a setup: its own launch options,
+/// or the global `default_template` when those are empty.
+pub fn launch_template<'a>(setup: &'a GameSetup, default_template: &'a str) -> &'a str {
+    if setup.launch_options.is_empty() {
+        default_template
+    } else {
+        &setup.launch_options
+    }
+}
+
+/// Resolves the final launch options for a setup deployed to an agent
+/// whose default install directory is `agent_install_path`.
+///
+/// The setup's own launch options take precedence; when empty, the global
+/// `default_template` is used.
+pub fn resolve_launch_options(
+    setup: &GameSetup,
+    default_template: &str,
+    agent_install_path: &str,
+) -> Result<String, DeployError> {
+    let template = launch_template(setup, default_template);
+    if template.is_empty() {
+        return Ok(String::new());
+    }
+    expand_template(
+        template,
+        &LaunchVariables::from_setup(setup, agent_install_path),
+    )
+}
+
+/// Scans `template`, calling `subst` for each `{variable}`.
+fn walk(
+    template: &str,
+    mut subst: impl FnMut(&str) -> Result<String, DeployError>,
+) -> Result<String, DeployError> {
+    let mut out = String::with_capacity(template.len());
+    let mut chars = template.char_indices().peekable();
+
+    while let Some((i, c)) = chars.next() {
+        match c {
+            '{' if chars.peek().map(|&(_, n)| n) == Some('{') => {
+                chars.next();
+                out.push('{');
+            }
+            '}' if chars.peek().map(|&(_, n)| n) == Some('}') => {
+                chars.next();
+                out.push('}');
+            }
+            '{' => {
+                let rest = &template[i + 1..];
+                let end = rest.find('}').ok_or_else(|| {
+                    DeployError::LaunchOptions(format!("unterminated variable at position {i}"))
+                })?;
+                let var = &rest[..end];
+                if !LAUNCH_VARIABLES.contains(&var) {
+                    return Err(DeployError::LaunchOptions(format!(
+                        "unknown variable {{{var}}} (expected one of: {})",
+                        LAUNCH_VARIABLES.join(", ")
+                    )));
+                }
+                out.push_str(&subst(var)?);
+                // Skip the variable name and the closing brace.
+                for _ in 0..=var.chars().count() {
+                    chars.next();
+                }
+            }
+            '}' => {
+                return Err(DeployError::LaunchOptions(format!(
+                    "unmatched '}}' at position {i}"
+                )));
+            }
+            _ => out.push(c),
+        }
+    }
+
+    Ok(out)
+}
+
+fn quote_value(var: &str, value: &str) -> Result<String, DeployError> {
+    if let Some(bad) = value.chars().find(|c| UNSAFE_CHARS.contains(c)) {
+        return Err(DeployError::LaunchOptions(format!(
+            "value of {{{var}}} contains unsafe character {bad:?}"
+        )));
+    }
+    if value.chars().any(char::is_whitespace) {
+        Ok(format!("\"{value}\""))
+    } else {
+        Ok(value.to_string())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn setup(name: &str, install_path: &str, exe: &str, launch: &str) -> GameSetup {
+        GameSetup {
+            id: "1".into(),
+            name: name.into(),
+            local_path: "/src".into(),
+            executable: exe.into(),
+            launch_options: launch.into(),
+            tags: String::new(),
+            install_path: install_path.into(),
+            griddb_game_id: 0,
+            grid_portrait: String::new(),
+            grid_landscape: String::new(),
+            hero_image: String::new(),
+            logo_image: String::new(),
+            icon_image: String::new(),
+            boot_video: String::new(),
+            mark_recent: false,
+            artwork_all_users: false,
+            library_path: String::new(),
+            install_subpath: String::new(),
+            compat_tool: String::new(),
+            clean_install: false,
+        }
+    }
+
+    #[test]
+    fn substitutes_all_variables() {
+        let s = setup("MyGame", "/home/deck/Games/", "bin/game.x86_64", "");
+        let vars = LaunchVariables::from_setup(&s, "");
+        let out = expand_template(
+            "%command% --dir {installdir} --exe {exe} --title {name}",
+            &vars,
+        )
+        .unwrap();
+        assert_eq!(
+            out,
+            "%command% --dir /home/deck/Games/MyGame \
+             --exe /home/deck/Games/MyGame/bin/game.x86_64 --title MyGame"
+        );
+    }
+
+    #[test]
+    fn windows_install_path_uses_backslash() {
+        let s = setup("MyGame", "C:\\Games", "game.exe", "");
+        let vars = LaunchVariables::from_setup(&s, "");
+        assert_eq!(vars.installdir, "C:\\Games\\MyGame");
+        assert_eq!(vars.exe, "C:\\Games\\MyGame\\game.exe");
+    }
+
+    #[test]
+    fn library_path_overrides_install_path() {
+        let mut s = setup("MyGame", "/home/deck/Games", "game.x86_64", "");
+        s.library_path = "/run/media/mmcblk0p1/".into();
+        let vars = LaunchVariables::from_setup(&s, "");
+        assert_eq!(vars.installdir, "/run/media/mmcblk0p1/Games/MyGame");
+        assert_eq!(vars.exe, "/run/media/mmcblk0p1/Games/MyGame/game.x86_64");
+    }
+
+    #[test]
+    fn install_subpath_is_part_of_installdir() {
+        let mut s = setup("MyGame", "/home/deck/Games/", "game.x86_64", "");
+        s.install_subpath = "jams/2026/".into();
+        let vars = LaunchVariables::from_setup(&s, "");
+        assert_eq!(vars.installdir, "/home/deck/Games/jams/2026/MyGame");
+
+        let mut s = setup("MyGame", "C:\\Games", "game.exe", "");
+        s.install_subpath = "jams/2026".into();
+        let vars = LaunchVariables::from_setup(&s, "");
+        assert_eq!(vars.exe, "C:\\Games\\jams\\2026\\MyGame\\game.exe");
+    }
+
+    #[test]
+    fn values_with_spaces_are_quoted() {
+        let s = setup("My Game", "/games", "game", "");
+        let vars = LaunchVariables::from_setup(&s, "");
+        let out = expand_template("-log {installdir}/log.txt", &vars).unwrap();
+        assert_eq!(out, "-log \"/games/My Game\"/log.txt");
+    }
+
+    #[test]
+    fn literal_braces_and_percent_command_pass_through() {
+        let s = setup("G", "/g", "g", "");
+        let vars = LaunchVariables::from_setup(&s, "");
+        let out = expand_template("%command% --json {{\"a\":1}}", &vars).unwrap();
+        assert_eq!(out, "%command% --json {\"a\":1}");
+    }
+
+    #[test]
+    fn rejects_unknown_variable() {
+        let err = validate_template("%command% {home}").unwrap_err();
+        assert!(err.to_string().contains("unknown variable {home}"), "{err}");
+    }
+
+    #[test]
+    fn rejects_malformed_braces() {
+        assert!(validate_template("--dir {installdir").is_err());
+        assert!(validate_template("--dir installdir}").is_err());
+        assert!(validate_template("{}").is_err());
+    }
+
+    #[test]
+    fn rejects_unsafe_substituted_values() {
+        for name in ["Evil; rm -rf ~", "A`id`", "$(whoami)", "x\"y", "a|b"] {
+            let s = setup(name, "/games", "game", "");
+            let vars = LaunchVariables::from_setup(&s, "");
+            let err = expand_template("--title {name}", &vars).unwrap_err();
+            assert!(
+                err.to_string().contains("unsafe character"),
+                "{name}: {err}"
+            );
+        }
+    }
+
+    #[test]
+    fn unsafe_value_in_unused_variable_is_ignored() {
+        let s = setup("Evil; rm", "/games", "game", "");
+        let vars = LaunchVariables::from_setup(&s, "");
+        assert_eq!(
+            expand_template("-fullscreen", &vars).unwrap(),
+            "-fullscreen"
+        );
+    }
+
+    #[test]
+    fn resolve_prefers_setup_over_default() {
+        let s = setup("G", "/g", "g", "-windowed {name}");
+        assert_eq!(
+            resolve_launch_options(&s, "-fullscreen", "").unwrap(),
+            "-windowed G"
+        );
+
+        let s = setup("G", "/g", "g", "");
+        assert_eq!(
+            resolve_launch_options(&s, "--root {installdir}", "").unwrap(),
+            "--root /g/G"
+        );
+        assert_eq!(resolve_launch_options(&s, "", "").unwrap(), "");
+    }
+
+    #[test]
+    fn empty_install_path_uses_agent_default() {
+        let s = setup("MyGame", "", "game.x86_64", "--root {installdir}");
+        assert!(needs_agent_install_path(&s));
+        assert_eq!(
+            resolve_launch_options(&s, "", "/home/deck/Games").unwrap(),
+            "--root /home/deck/Games/MyGame"
+        );
+        assert_eq!(
+            resolve_launch_options(&s, "", "/run/media/sd/Games").unwrap(),
+            "--root /run/media/sd/Games/MyGame"
+        );
+
+        // Never "/MyGame" when the agent's directory isn't known.
+        let err = resolve_launch_options(&s, "", "").unwrap_err();
+        assert!(err.to_string().contains("{installdir} is unknown"), "{err}");
+        let s = setup("MyGame", "", "game.x86_64", "-windowed {name}");
+        assert_eq!(
+            resolve_launch_options(&s, "", "").unwrap(),
+            "-windowed MyGame"
+        );
+    }
+
+    #[test]
+    fn relative_install_path_uses_agent_default() {
+        let s = setup("G", "Games", "g", "");
+        assert!(needs_agent_install_path(&s));
+        assert!(!needs_agent_install_path(&setup("G", "/g", "g", "")));
+        assert!(!needs_agent_install_path(&setup("G", "C:\\Games", "g", "")));
+        assert_eq!(
+            LaunchVariables::from_setup(&s, "/home/deck/Games").installdir,
+            "/home/deck/Games/G"
+        );
+    }
+
+    #[test]
+    fn detects_install_dir_variables() {
+        assert!(uses_install_dir("--root {installdir}"));
+        assert!(uses_install_dir("{exe}"));
+        assert!(!uses_install_dir("-windowed {name} {{installdir}}"));
+    }
+}
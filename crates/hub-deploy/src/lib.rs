@@ -12,24 +12,56 @@
 //! 3. **Upload** — send file chunks with resume support
 //! 4. **Artwork** — send local images via binary messages
 //! 5. **Complete** — finalize upload and create Steam shortcut
+//!
+//! [`WatchDeploy`] re-runs the pipeline whenever the local game files change.
+//! [`UploadQueue`] keeps pending deploys on disk so they survive a Hub restart;
+//! [`run_schedule`] starts the ones scheduled for later.
+//! [`ResumeStore`] remembers interrupted uploads so the next deploy picks up
+//! where the Agent left off.
+//! [`detect_setup`] turns a dropped build folder into a ready [`GameSetup`].
+//! [`setup_from_portable`] imports a shortcut definition shared from another device.
+//! [`DeployHistory`] records finished deploys for later review, and
+//! [`estimate_deploy`] uses it to predict how long the next one will take.
 
 pub mod agent;
 pub mod artwork_selector;
 pub mod deploy;
+pub mod detect;
 pub mod error;
+pub mod estimate;
+pub mod history;
+pub mod launch_options;
+pub mod pause;
+pub mod queue;
+pub mod resume;
 pub mod scanner;
+pub mod share;
 pub mod types;
+pub mod watch;
 
 // Re-export primary types for convenience.
-pub use agent::AgentConnection;
+pub use agent::{AgentConnection, DEFAULT_CHUNK_CONCURRENCY};
 pub use artwork_selector::{
     ArtworkSelector, build_artwork_assignment, build_remote_artwork_config, build_shortcut_config,
     classify_artwork_source, collect_local_artwork, detect_content_type, parse_tags,
 };
+pub use capydeploy_transfer::Compression;
 pub use deploy::DeployOrchestrator;
+pub use detect::detect_setup;
 pub use error::DeployError;
+pub use estimate::{DeployEstimate, ThroughputSource, estimate_deploy};
+pub use history::{DEFAULT_HISTORY_LIMIT, DeployHistory, DeployRecord, HistoryFilter};
+pub use launch_options::{
+    LAUNCH_VARIABLES, LaunchVariables, expand_template, launch_template, needs_agent_install_path,
+    resolve_launch_options, uses_install_dir, validate_template,
+};
+pub use pause::PauseGate;
+pub use queue::{QueueItemStatus, QueuedDeploy, UploadQueue, process_queue, run_schedule};
+pub use resume::{ResumeManifest, ResumeStore};
 pub use scanner::scan_files_for_upload;
+pub use share::setup_from_portable;
 pub use types::{
     ArtworkAssignment, ArtworkSource, CompleteUploadResult, DeployConfig, DeployEvent,
-    DeployResult, GameSetup, InitUploadResult, LocalArtwork,
+    DeployPreview, DeployResult, GameSetup, InitUploadResult, LocalArtwork,
 };
+pub use watch::{DEFAULT_WATCH_DEBOUNCE, RedeployFn, WatchDeploy};
@@ -0,0 +1,92 @@
+//! Pausing a running deploy.
+//!
+//! The Hub holds a [`PauseGate`] for the deploy in progress. While it is
+//! closed, WebSocket uploads stop sending chunks and ask the agent to hold
+//! the session; they pick up where they were once it opens again. Uploads
+//! over the TCP data channel can't be paused and run to completion.
+
+use std::sync::Arc;
+
+use tokio::sync::watch;
+
+/// Shared pause switch for a deploy. Clones control the same deploy.
+#[derive(Debug, Clone)]
+pub struct PauseGate {
+    paused: Arc<watch::Sender<bool>>,
+}
+
+impl Default for PauseGate {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl PauseGate {
+    /// Creates an open gate.
+    pub fn new() -> Self {
+        Self {
+            paused: Arc::new(watch::Sender::new(false)),
+        }
+    }
+
+    pub fn pause(&self) {
+        self.paused.send_replace(true);
+    }
+
+    pub fn resume(&self) {
+        self.paused.send_replace(false);
+    }
+
+    pub fn is_paused(&self) -> bool {
+        *self.paused.borrow()
+    }
+
+    /// Waits until the gate is closed.
+    pub(crate) async fn paused(&self) {
+        self.wait_for(true).await;
+    }
+
+    /// Waits until the gate is open.
+    pub(crate) async fn resumed(&self) {
+        self.wait_for(false).await;
+    }
+
+    async fn wait_for(&self, paused: bool) {
+        let mut rx = self.paused.subscribe();
+        // The sender lives as long as `self`, so this can't fail.
+        let _ = rx.wait_for(|p| *p == paused).await;
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::time::Duration;
+
+    use super::*;
+
+    #[tokio::test]
+    async fn waits_follow_the_gate() {
+        let gate = PauseGate::new();
+        assert!(!gate.is_paused());
+        // An open gate doesn't hold anyone back.
+        gate.resumed().await;
+
+        let waiter = tokio::spawn({
+            let gate = gate.clone();
+            async move { gate.paused().await }
+        });
+        tokio::time::sleep(Duration::from_millis(10)).await;
+        assert!(!waiter.is_finished());
+
+        gate.pause();
+        waiter.await.unwrap();
+        assert!(gate.is_paused());
+
+        let waiter = tokio::spawn({
+            let gate = gate.clone();
+            async move { gate.resumed().await }
+        });
+        gate.resume();
+        waiter.await.unwrap();
+    }
+}
@@ -0,0 +1,748 @@
+//! Persistent upload queue.
+//!
+//! Deploys queued while an upload is running (or while the target Agent is
+//! offline) are kept in order and persisted to a JSON file after every
+//! change, so a Hub restart doesn't lose them. An item that was mid-upload
+//! when the Hub went down is restored as pending and re-attempted; the
+//! Agent's resume offsets avoid re-sending data it already has.
+//!
+//! An item can carry a scheduled start time. It is held until then and
+//! started by [`run_schedule`], which keeps retrying while the target Agent
+//! is unreachable.
+//!
+//! The next item to start is the highest-priority due one, earliest in the
+//! queue among equals. The user can reorder the queue and change an item's
+//! priority at any time; the active upload is never interrupted.
+
+use std::future::Future;
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+use std::time::Duration;
+
+use serde::{Deserialize, Serialize};
+use tokio::sync::Notify;
+use tokio_util::sync::CancellationToken;
+use tracing::{debug, info, warn};
+
+use crate::error::DeployError;
+
+/// State of a queued deploy.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum QueueItemStatus {
+    /// Waiting to be deployed.
+    Pending,
+    /// Currently uploading.
+    Active,
+    /// Last attempt failed; stays queued until retried or removed.
+    Failed,
+}
+
+/// A deploy waiting in the upload queue.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct QueuedDeploy {
+    pub id: String,
+    pub setup_id: String,
+    pub agent_id: String,
+    pub status: QueueItemStatus,
+    /// Unix timestamp (seconds) when the item was queued.
+    pub queued_at: i64,
+    /// Unix timestamp (seconds) before which the item must not start.
+    /// Zero means as soon as the queue is processed.
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub scheduled_at: i64,
+    /// Items with a higher priority start first; zero is normal.
+    #[serde(default, skip_serializing_if = "is_zero_i32")]
+    pub priority: i32,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub last_error: String,
+}
+
+impl QueuedDeploy {
+    /// Whether the item may start at `now`.
+    pub fn is_due(&self, now: i64) -> bool {
+        self.status == QueueItemStatus::Pending && self.scheduled_at <= now
+    }
+}
+
+fn is_zero_i64(v: &i64) -> bool {
+    *v == 0
+}
+
+fn is_zero_i32(v: &i32) -> bool {
+    *v == 0
+}
+
+/// Ordered, disk-backed queue of pending deploys.
+pub struct UploadQueue {
+    path: PathBuf,
+    items: Mutex<Vec<QueuedDeploy>>,
+    /// Wakes [`run_schedule`] when an item is added.
+    added: Notify,
+}
+
+impl UploadQueue {
+    /// Opens the queue at `path`, restoring any items persisted earlier.
+    ///
+    /// Items that were `Active` when the Hub stopped are reset to `Pending`.
+    pub fn new(path: PathBuf) -> Result<Self, DeployError> {
+        let mut items = load_items(&path)?;
+        let mut interrupted = 0;
+        for item in items
+            .iter_mut()
+            .filter(|i| i.status == QueueItemStatus::Active)
+        {
+            item.status = QueueItemStatus::Pending;
+            interrupted += 1;
+        }
+        if !items.is_empty() {
+            info!(
+                total = items.len(),
+                interrupted, "restored upload queue from disk"
+            );
+        }
+        Ok(Self {
+            path,
+            items: Mutex::new(items),
+            added: Notify::new(),
+        })
+    }
+
+    /// Appends a deploy of `setup_id` to `agent_id` and returns its queue ID.
+    pub fn enqueue(&self, setup_id: &str, agent_id: &str) -> Result<String, DeployError> {
+        self.enqueue_at(setup_id, agent_id, 0)
+    }
+
+    /// Like [`enqueue`](Self::enqueue), but holds the deploy until the Unix
+    /// timestamp `scheduled_at` (seconds). Zero starts it right away.
+    pub fn enqueue_at(
+        &self,
+        setup_id: &str,
+        agent_id: &str,
+        scheduled_at: i64,
+    ) -> Result<String, DeployError> {
+        let item = QueuedDeploy {
+            id: uuid::Uuid::new_v4().to_string(),
+            setup_id: setup_id.to_string(),
+            agent_id: agent_id.to_string(),
+            status: QueueItemStatus::Pending,
+            queued_at: unix_now(),
+            scheduled_at,
+            priority: 0,
+            last_error: String::new(),
+        };
+        let id = item.id.clone();
+        self.items.lock().unwrap().push(item);
+        self.persist()?;
+        self.added.notify_one();
+        Ok(id)
+    }
+
+    /// Removes an item. Returns false if it wasn't queued.
+    pub fn remove(&self, id: &str) -> Result<bool, DeployError> {
+        let removed = {
+            let mut items = self.items.lock().unwrap();
+            let before = items.len();
+            items.retain(|i| i.id != id);
+            items.len() != before
+        };
+        if removed {
+            self.persist()?;
+        }
+        Ok(removed)
+    }
+
+    /// Marks a failed item as pending again.
+    pub fn retry(&self, id: &str) -> Result<bool, DeployError> {
+        let found = self.update(id, |item| {
+            item.status = QueueItemStatus::Pending;
+            item.last_error.clear();
+        });
+        if found {
+            self.persist()?;
+        }
+        Ok(found)
+    }
+
+    /// Moves the items in `order` (by ID) to the front of the queue, in that
+    /// order. Items not listed keep their relative order after them;
+    /// unknown IDs are ignored.
+    pub fn reorder(&self, order: &[String]) -> Result<(), DeployError> {
+        {
+            let mut items = self.items.lock().unwrap();
+            let mut rest = std::mem::take(&mut *items);
+            for id in order {
+                if let Some(pos) = rest.iter().position(|i| &i.id == id) {
+                    items.push(rest.remove(pos));
+                }
+            }
+            items.append(&mut rest);
+        }
+        self.persist()
+    }
+
+    /// Sets an item's priority. Returns false if it wasn't queued.
+    pub fn set_priority(&self, id: &str, priority: i32) -> Result<bool, DeployError> {
+        let found = self.update(id, |item| item.priority = priority);
+        if found {
+            self.persist()?;
+        }
+        Ok(found)
+    }
+
+    /// Returns a snapshot of all items in queue order.
+    pub fn items(&self) -> Vec<QueuedDeploy> {
+        self.items.lock().unwrap().clone()
+    }
+
+    /// Number of pending items targeting `agent_id` that are due to start.
+    pub fn pending_count(&self, agent_id: &str) -> usize {
+        let now = unix_now();
+        self.items
+            .lock()
+            .unwrap()
+            .iter()
+            .filter(|i| i.agent_id == agent_id && i.is_due(now))
+            .count()
+    }
+
+    /// Takes the next due item for `agent_id` and marks it active: the
+    /// highest-priority one, earliest in the queue among equals. Items
+    /// scheduled for later are skipped.
+    pub fn start_next(&self, agent_id: &str) -> Result<Option<QueuedDeploy>, DeployError> {
+        let now = unix_now();
+        self.start_where(|i| i.agent_id == agent_id && i.is_due(now))
+    }
+
+    /// Marks the highest-priority item matching `pred` active, the first
+    /// in queue order among equals.
+    fn start_where(
+        &self,
+        pred: impl Fn(&QueuedDeploy) -> bool,
+    ) -> Result<Option<QueuedDeploy>, DeployError> {
+        let next = {
+            let mut items = self.items.lock().unwrap();
+            items
+                .iter_mut()
+                .enumerate()
+                .filter(|(_, i)| pred(i))
+                .max_by_key(|(pos, i)| (i.priority, std::cmp::Reverse(*pos)))
+                .map(|(_, item)| {
+                    item.status = QueueItemStatus::Active;
+                    item.clone()
+                })
+        };
+        if next.is_some() {
+            self.persist()?;
+        }
+        Ok(next)
+    }
+
+    /// Records the outcome of an active item: success removes it, failure
+    /// keeps it as `Failed` with the error message.
+    pub fn finish(&self, id: &str, result: Result<(), String>) -> Result<(), DeployError> {
+        match result {
+            Ok(()) => {
+                self.remove(id)?;
+            }
+            Err(e) => {
+                self.update(id, |item| {
+                    item.status = QueueItemStatus::Failed;
+                    item.last_error = e;
+                });
+                self.persist()?;
+            }
+        }
+        Ok(())
+    }
+
+    /// Scheduled items that are due at `now`, by priority and then queue
+    /// order.
+    fn due_scheduled(&self, now: i64) -> Vec<QueuedDeploy> {
+        let mut due: Vec<QueuedDeploy> = self
+            .items
+            .lock()
+            .unwrap()
+            .iter()
+            .filter(|i| i.scheduled_at > 0 && i.is_due(now))
+            .cloned()
+            .collect();
+        due.sort_by_key(|i| std::cmp::Reverse(i.priority));
+        due
+    }
+
+    /// Earliest start time of pending items still scheduled after `now`.
+    fn next_scheduled_after(&self, now: i64) -> Option<i64> {
+        self.items
+            .lock()
+            .unwrap()
+            .iter()
+            .filter(|i| i.status == QueueItemStatus::Pending && i.scheduled_at > now)
+            .map(|i| i.scheduled_at)
+            .min()
+    }
+
+    fn update(&self, id: &str, f: impl FnOnce(&mut QueuedDeploy)) -> bool {
+        let mut items = self.items.lock().unwrap();
+        match items.iter_mut().find(|i| i.id == id) {
+            Some(item) => {
+                f(item);
+                true
+            }
+            None => false,
+        }
+    }
+
+    /// Writes the current queue to disk.
+    fn persist(&self) -> Result<(), DeployError> {
+        let items = self.items.lock().unwrap();
+        let json = serde_json::to_string_pretty(&*items)?;
+        if let Some(parent) = self.path.parent() {
+            std::fs::create_dir_all(parent)?;
+        }
+        std::fs::write(&self.path, json)?;
+        debug!(
+            "persisted {} queued deploy(s) to {:?}",
+            items.len(),
+            self.path
+        );
+        Ok(())
+    }
+}
+
+/// Deploys every pending item for `agent_id` one at a time, by priority
+/// and then queue order.
+///
+/// Returns the number of items that completed successfully. A failed item
+/// stays in the queue as `Failed` and processing continues with the next.
+/// Once `cancel` fires, no further item is started; the rest stay pending.
+pub async fn process_queue<F, Fut>(
+    queue: &UploadQueue,
+    agent_id: &str,
+    cancel: &CancellationToken,
+    mut deploy: F,
+) -> Result<usize, DeployError>
+where
+    F: FnMut(QueuedDeploy) -> Fut,
+    Fut: Future<Output = Result<(), String>>,
+{
+    let mut completed = 0;
+    loop {
+        if cancel.is_cancelled() {
+            info!(
+                agent_id,
+                pending = queue.pending_count(agent_id),
+                "queue processing cancelled"
+            );
+            break;
+        }
+        let Some(item) = queue.start_next(agent_id)? else {
+            break;
+        };
+        let id = item.id.clone();
+        let setup_id = item.setup_id.clone();
+        let result = deploy(item).await;
+        match &result {
+            Ok(()) => completed += 1,
+            Err(e) => warn!(setup_id, error = %e, "queued deploy failed"),
+        }
+        queue.finish(&id, result)?;
+    }
+    Ok(completed)
+}
+
+/// Starts scheduled deploys as they come due. Runs until a queue write
+/// fails.
+///
+/// A due item is started only when `ready` reports its agent reachable (and
+/// free to take a deploy); otherwise it stays pending and is checked again
+/// every `retry` interval, so it goes out once the agent reconnects. Items
+/// without a schedule are left to [`process_queue`].
+pub async fn run_schedule<R, RFut, F, Fut>(
+    queue: &UploadQueue,
+    retry: Duration,
+    mut ready: R,
+    mut deploy: F,
+) -> Result<(), DeployError>
+where
+    R: FnMut(String) -> RFut,
+    RFut: Future<Output = bool>,
+    F: FnMut(QueuedDeploy) -> Fut,
+    Fut: Future<Output = Result<(), String>>,
+{
+    loop {
+        let now = unix_now();
+        for item in queue.due_scheduled(now) {
+            if !ready(item.agent_id.clone()).await {
+                debug!(
+                    setup_id = item.setup_id,
+                    agent_id = item.agent_id,
+                    "scheduled deploy waiting for agent"
+                );
+                continue;
+            }
+            // Removed or started elsewhere while we waited on `ready`.
+            let Some(item) = queue.start_where(|i| i.id == item.id && i.is_due(now))? else {
+                continue;
+            };
+            info!(
+                setup_id = item.setup_id,
+                agent_id = item.agent_id,
+                "starting scheduled deploy"
+            );
+            let id = item.id.clone();
+            let result = deploy(item).await;
+            if let Err(e) = &result {
+                warn!(error = %e, "scheduled deploy failed");
+            }
+            queue.finish(&id, result)?;
+        }
+
+        let now = unix_now();
+        let wait = match queue.next_scheduled_after(now) {
+            Some(at) => retry.min(Duration::from_secs((at - now) as u64)),
+            None => retry,
+        };
+        tokio::select! {
+            _ = tokio::time::sleep(wait) => {}
+            _ = queue.added.notified() => {}
+        }
+    }
+}
+
+fn load_items(path: &Path) -> Result<Vec<QueuedDeploy>, DeployError> {
+    if !path.exists() {
+        return Ok(Vec::new());
+    }
+    let data = std::fs::read_to_string(path)?;
+    if data.trim().is_empty() {
+        return Ok(Vec::new());
+    }
+    Ok(serde_json::from_str(&data)?)
+}
+
+fn unix_now() -> i64 {
+    std::time::SystemTime::now()
+        .duration_since(std::time::UNIX_EPOCH)
+        .map(|d| d.as_secs() as i64)
+        .unwrap_or_default()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::Arc;
+
+    fn queue_path(dir: &tempfile::TempDir) -> PathBuf {
+        dir.path().join("hub").join("upload_queue.json")
+    }
+
+    #[test]
+    fn enqueue_persists_in_order() {
+        let dir = tempfile::tempdir().unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        q.enqueue("setup-a", "agent-1").unwrap();
+        q.enqueue("setup-b", "agent-1").unwrap();
+
+        let reopened = UploadQueue::new(queue_path(&dir)).unwrap();
+        let setups: Vec<String> = reopened.items().into_iter().map(|i| i.setup_id).collect();
+        assert_eq!(setups, vec!["setup-a", "setup-b"]);
+    }
+
+    #[test]
+    fn restore_resets_active_items_to_pending() {
+        let dir = tempfile::tempdir().unwrap();
+        {
+            let q = UploadQueue::new(queue_path(&dir)).unwrap();
+            q.enqueue("setup-a", "agent-1").unwrap();
+            q.enqueue("setup-b", "agent-1").unwrap();
+            // Simulate a crash mid-upload.
+            let started = q.start_next("agent-1").unwrap().unwrap();
+            assert_eq!(started.setup_id, "setup-a");
+        }
+
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        assert!(
+            q.items()
+                .iter()
+                .all(|i| i.status == QueueItemStatus::Pending)
+        );
+        assert_eq!(q.pending_count("agent-1"), 2);
+    }
+
+    #[tokio::test]
+    async fn restored_queue_is_processed_after_restart() {
+        let dir = tempfile::tempdir().unwrap();
+        {
+            let q = UploadQueue::new(queue_path(&dir)).unwrap();
+            q.enqueue("setup-a", "agent-1").unwrap();
+            q.enqueue("setup-x", "agent-2").unwrap();
+            q.enqueue("setup-b", "agent-1").unwrap();
+            q.start_next("agent-1").unwrap();
+        }
+
+        // "Restart": reopen from disk and process once agent-1 reconnects.
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        let deployed = Arc::new(Mutex::new(Vec::new()));
+        let d = deployed.clone();
+        let done = process_queue(&q, "agent-1", &CancellationToken::new(), |item| {
+            let d = d.clone();
+            async move {
+                d.lock().unwrap().push(item.setup_id);
+                Ok(())
+            }
+        })
+        .await
+        .unwrap();
+
+        assert_eq!(done, 2);
+        assert_eq!(*deployed.lock().unwrap(), vec!["setup-a", "setup-b"]);
+
+        // Only the other agent's item remains, on disk too.
+        let reopened = UploadQueue::new(queue_path(&dir)).unwrap();
+        let left = reopened.items();
+        assert_eq!(left.len(), 1);
+        assert_eq!(left[0].agent_id, "agent-2");
+    }
+
+    #[tokio::test]
+    async fn failed_items_stay_queued_and_can_be_retried() {
+        let dir = tempfile::tempdir().unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        let bad = q.enqueue("bad", "agent-1").unwrap();
+        q.enqueue("good", "agent-1").unwrap();
+
+        let done = process_queue(
+            &q,
+            "agent-1",
+            &CancellationToken::new(),
+            |item| async move {
+                if item.setup_id == "bad" {
+                    Err("disk full".to_string())
+                } else {
+                    Ok(())
+                }
+            },
+        )
+        .await
+        .unwrap();
+        assert_eq!(done, 1);
+
+        let items = q.items();
+        assert_eq!(items.len(), 1);
+        assert_eq!(items[0].status, QueueItemStatus::Failed);
+        assert_eq!(items[0].last_error, "disk full");
+        assert_eq!(q.pending_count("agent-1"), 0);
+
+        assert!(q.retry(&bad).unwrap());
+        assert_eq!(q.pending_count("agent-1"), 1);
+        assert!(q.remove(&bad).unwrap());
+        assert!(!q.remove(&bad).unwrap());
+    }
+
+    #[tokio::test]
+    async fn cancel_stops_before_the_next_item() {
+        let dir = tempfile::tempdir().unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        q.enqueue("setup-a", "agent-1").unwrap();
+        q.enqueue("setup-b", "agent-1").unwrap();
+        q.enqueue("setup-c", "agent-1").unwrap();
+
+        let cancel = CancellationToken::new();
+        let deployed = Mutex::new(Vec::new());
+        let done = process_queue(&q, "agent-1", &cancel, |item| {
+            deployed.lock().unwrap().push(item.setup_id);
+            // The user cancels while the first item uploads.
+            cancel.cancel();
+            async { Err("cancelled".to_string()) }
+        })
+        .await
+        .unwrap();
+
+        assert_eq!(done, 0);
+        assert_eq!(*deployed.lock().unwrap(), vec!["setup-a"]);
+        let statuses: Vec<QueueItemStatus> = q.items().into_iter().map(|i| i.status).collect();
+        assert_eq!(
+            statuses,
+            vec![
+                QueueItemStatus::Failed,
+                QueueItemStatus::Pending,
+                QueueItemStatus::Pending
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn scheduled_item_starts_when_due() {
+        let dir = tempfile::tempdir().unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        let at = unix_now() + 1;
+        q.enqueue_at("nightly", "agent-1", at).unwrap();
+
+        // Held back from manual processing and the resume prompt.
+        assert_eq!(q.pending_count("agent-1"), 0);
+        assert!(q.start_next("agent-1").unwrap().is_none());
+        assert_eq!(
+            UploadQueue::new(queue_path(&dir)).unwrap().items()[0].scheduled_at,
+            at
+        );
+
+        let (tx, rx) = tokio::sync::oneshot::channel();
+        let tx = Mutex::new(Some(tx));
+        let schedule = run_schedule(
+            &q,
+            Duration::from_millis(50),
+            |_| async { true },
+            |item| {
+                let started = unix_now();
+                if let Some(tx) = tx.lock().unwrap().take() {
+                    let _ = tx.send((item.setup_id, started));
+                }
+                async { Ok(()) }
+            },
+        );
+        let (setup_id, started) = tokio::select! {
+            r = schedule => panic!("schedule stopped: {r:?}"),
+            r = tokio::time::timeout(Duration::from_secs(5), rx) => r.unwrap().unwrap(),
+        };
+
+        assert_eq!(setup_id, "nightly");
+        assert!(started >= at, "started at {started}, scheduled for {at}");
+        assert!(started <= at + 1);
+        assert!(q.items().is_empty());
+    }
+
+    #[tokio::test]
+    async fn scheduled_item_waits_for_agent_to_reconnect() {
+        let dir = tempfile::tempdir().unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        // Due already, but the agent is offline.
+        q.enqueue_at("nightly", "agent-1", unix_now() - 60).unwrap();
+        q.enqueue("manual", "agent-1").unwrap();
+
+        let checks = std::sync::atomic::AtomicUsize::new(0);
+        let deployed = Mutex::new(Vec::new());
+        let (tx, rx) = tokio::sync::oneshot::channel::<()>();
+        let tx = Mutex::new(Some(tx));
+        let schedule = run_schedule(
+            &q,
+            Duration::from_millis(20),
+            |agent_id| {
+                assert_eq!(agent_id, "agent-1");
+                // Reconnects on the fourth check.
+                let online = checks.fetch_add(1, std::sync::atomic::Ordering::SeqCst) >= 3;
+                async move { online }
+            },
+            |item| {
+                deployed.lock().unwrap().push(item.setup_id);
+                if let Some(tx) = tx.lock().unwrap().take() {
+                    let _ = tx.send(());
+                }
+                async { Ok(()) }
+            },
+        );
+        tokio::select! {
+            r = schedule => panic!("schedule stopped: {r:?}"),
+            r = tokio::time::timeout(Duration::from_secs(5), rx) => r.unwrap().unwrap(),
+        }
+
+        assert_eq!(checks.load(std::sync::atomic::Ordering::SeqCst), 4);
+        // Unscheduled items are left for process_queue.
+        assert_eq!(*deployed.lock().unwrap(), vec!["nightly"]);
+        let left = q.items();
+        assert_eq!(left.len(), 1);
+        assert_eq!(left[0].setup_id, "manual");
+    }
+
+    /// Processes `agent-1`'s queue and returns the setups in the order
+    /// they were deployed.
+    async fn processing_order(q: &UploadQueue) -> Vec<String> {
+        let deployed = Mutex::new(Vec::new());
+        process_queue(q, "agent-1", &CancellationToken::new(), |item| {
+            deployed.lock().unwrap().push(item.setup_id);
+            async { Ok(()) }
+        })
+        .await
+        .unwrap();
+        deployed.into_inner().unwrap()
+    }
+
+    #[tokio::test]
+    async fn reorder_changes_processing_sequence() {
+        let dir = tempfile::tempdir().unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        let a = q.enqueue("setup-a", "agent-1").unwrap();
+        let b = q.enqueue("setup-b", "agent-1").unwrap();
+        let c = q.enqueue("setup-c", "agent-1").unwrap();
+
+        // Bump C to the front; unlisted A and B keep their order.
+        q.reorder(&[c.clone(), "unknown".into()]).unwrap();
+        let ids: Vec<String> = q.items().into_iter().map(|i| i.id).collect();
+        assert_eq!(ids, vec![c.clone(), a.clone(), b.clone()]);
+
+        // The new order survives a restart.
+        let reopened = UploadQueue::new(queue_path(&dir)).unwrap();
+        assert_eq!(reopened.items()[0].id, c);
+        q.reorder(&[b, c, a]).unwrap();
+
+        assert_eq!(
+            processing_order(&q).await,
+            vec!["setup-b", "setup-c", "setup-a"]
+        );
+    }
+
+    #[tokio::test]
+    async fn priority_picks_next_job() {
+        let dir = tempfile::tempdir().unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        q.enqueue("normal-1", "agent-1").unwrap();
+        let urgent = q.enqueue("urgent", "agent-1").unwrap();
+        q.enqueue("normal-2", "agent-1").unwrap();
+        let low = q.enqueue("low", "agent-1").unwrap();
+
+        assert!(q.set_priority(&urgent, 10).unwrap());
+        assert!(q.set_priority(&low, -1).unwrap());
+        assert!(!q.set_priority("missing", 5).unwrap());
+        assert_eq!(
+            UploadQueue::new(queue_path(&dir)).unwrap().items()[1].priority,
+            10
+        );
+
+        assert_eq!(
+            processing_order(&q).await,
+            vec!["urgent", "normal-1", "normal-2", "low"]
+        );
+    }
+
+    #[test]
+    fn priority_does_not_preempt_active_item() {
+        let dir = tempfile::tempdir().unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        q.enqueue("running", "agent-1").unwrap();
+        let started = q.start_next("agent-1").unwrap().unwrap();
+
+        let urgent = q.enqueue("urgent", "agent-1").unwrap();
+        q.set_priority(&urgent, 10).unwrap();
+        q.reorder(std::slice::from_ref(&urgent)).unwrap();
+
+        let items = q.items();
+        let running = items.iter().find(|i| i.id == started.id).unwrap();
+        assert_eq!(running.status, QueueItemStatus::Active);
+        // The urgent job is next once the active one finishes.
+        q.finish(&started.id, Ok(())).unwrap();
+        assert_eq!(q.start_next("agent-1").unwrap().unwrap().id, urgent);
+    }
+
+    #[test]
+    fn missing_or_empty_file_is_empty_queue() {
+        let dir = tempfile::tempdir().unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        assert!(q.items().is_empty());
+
+        std::fs::create_dir_all(queue_path(&dir).parent().unwrap()).unwrap();
+        std::fs::write(queue_path(&dir), "").unwrap();
+        let q = UploadQueue::new(queue_path(&dir)).unwrap();
+        assert!(q.items().is_empty());
+    }
+}
@@ -0,0 +1,341 @@
+//! Resume manifests for interrupted uploads.
+//!
+//! While an upload runs, the Hub keeps a small manifest on disk with the
+//! Agent's upload ID and the bytes the Agent acknowledged per file. If the
+//! Hub crashes or the connection drops, the next deploy of the same setup
+//! to the same Agent re-opens that upload instead of starting from zero;
+//! the Agent then reports the offsets it holds and only the rest is sent.
+//!
+//! Acknowledged offsets are written out every [`SAVE_EVERY_CHUNKS`] chunks
+//! or [`SAVE_INTERVAL`], whichever comes first, off the async runtime, and
+//! once more when the upload stops. The manifest is removed once the upload
+//! completes or is abandoned.
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+
+use capydeploy_hub_settings::write_atomic;
+use capydeploy_protocol::messages::FileEntry;
+use serde::{Deserialize, Serialize};
+use tracing::{debug, info};
+
+use crate::error::DeployError;
+
+/// An upload that can be resumed.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ResumeManifest {
+    pub upload_id: String,
+    pub setup_id: String,
+    pub agent_id: String,
+    /// Files sent by the upload, as scanned when it started.
+    pub files: Vec<FileEntry>,
+    /// Bytes the Agent acknowledged per file, from the start of the file.
+    #[serde(default, skip_serializing_if = "HashMap::is_empty")]
+    pub offsets: HashMap<String, i64>,
+}
+
+impl ResumeManifest {
+    /// Starts a manifest for a new upload of `files`.
+    pub fn new(upload_id: &str, setup_id: &str, agent_id: &str, files: &[FileEntry]) -> Self {
+        Self {
+            upload_id: upload_id.to_string(),
+            setup_id: setup_id.to_string(),
+            agent_id: agent_id.to_string(),
+            files: files.to_vec(),
+            offsets: HashMap::new(),
+        }
+    }
+
+    /// Whether the upload was of exactly `files`. If the local build changed
+    /// since, the bytes already on the Agent can't be reused.
+    pub fn matches_files(&self, files: &[FileEntry]) -> bool {
+        self.files == files
+    }
+
+    /// Total bytes the Agent acknowledged.
+    pub fn acknowledged_bytes(&self) -> i64 {
+        self.offsets.values().sum()
+    }
+}
+
+/// Acknowledged chunks after which the offsets are written out.
+pub const SAVE_EVERY_CHUNKS: usize = 32;
+
+/// Time after which recorded offsets are written out.
+pub const SAVE_INTERVAL: Duration = Duration::from_secs(2);
+
+/// Disk-backed set of resume manifests, at most one per setup and Agent.
+pub struct ResumeStore {
+    path: PathBuf,
+    manifests: Mutex<Vec<ResumeManifest>>,
+    unsaved: Mutex<Unsaved>,
+    /// Generation of the snapshot on disk. Held while writing, so a stale
+    /// snapshot never overwrites a newer one.
+    written: Arc<Mutex<u64>>,
+}
+
+/// Offsets recorded since the manifests were last snapshotted.
+struct Unsaved {
+    records: usize,
+    since: Instant,
+    /// Generation of the latest snapshot.
+    generation: u64,
+}
+
+impl ResumeStore {
+    /// Opens the store at `path`, restoring manifests of uploads that were
+    /// interrupted.
+    pub fn new(path: PathBuf) -> Result<Self, DeployError> {
+        let manifests = load_manifests(&path)?;
+        if !manifests.is_empty() {
+            info!(
+                count = manifests.len(),
+                "restored interrupted upload(s) from disk"
+            );
+        }
+        Ok(Self {
+            path,
+            manifests: Mutex::new(manifests),
+            unsaved: Mutex::new(Unsaved {
+                records: 0,
+                since: Instant::now(),
+                generation: 0,
+            }),
+            written: Arc::new(Mutex::new(0)),
+        })
+    }
+
+    /// Manifest of the interrupted upload of `setup_id` to `agent_id`.
+    pub fn get(&self, setup_id: &str, agent_id: &str) -> Option<ResumeManifest> {
+        self.manifests
+            .lock()
+            .unwrap()
+            .iter()
+            .find(|m| m.setup_id == setup_id && m.agent_id == agent_id)
+            .cloned()
+    }
+
+    /// Stores `manifest`, replacing any earlier one for the same setup and
+    /// Agent.
+    pub fn begin(&self, manifest: ResumeManifest) -> Result<(), DeployError> {
+        {
+            let mut manifests = self.manifests.lock().unwrap();
+            manifests
+                .retain(|m| !(m.setup_id == manifest.setup_id && m.agent_id == manifest.agent_id));
+            manifests.push(manifest);
+        }
+        self.persist()
+    }
+
+    /// Records that the Agent holds the first `offset` bytes of `file` for
+    /// `upload_id`. Unknown uploads are ignored.
+    ///
+    /// The file is only rewritten every [`SAVE_EVERY_CHUNKS`] records or
+    /// [`SAVE_INTERVAL`]; call [`flush`](Self::flush) when the upload stops.
+    pub async fn record(
+        &self,
+        upload_id: &str,
+        file: &str,
+        offset: i64,
+    ) -> Result<(), DeployError> {
+        {
+            let mut manifests = self.manifests.lock().unwrap();
+            let Some(manifest) = manifests.iter_mut().find(|m| m.upload_id == upload_id) else {
+                return Ok(());
+            };
+            manifest.offsets.insert(file.to_string(), offset);
+        }
+        let due = {
+            let mut unsaved = self.unsaved.lock().unwrap();
+            unsaved.records += 1;
+            unsaved.records >= SAVE_EVERY_CHUNKS || unsaved.since.elapsed() >= SAVE_INTERVAL
+        };
+        if due { self.save().await } else { Ok(()) }
+    }
+
+    /// Writes out offsets recorded since the last save, if any.
+    pub async fn flush(&self) -> Result<(), DeployError> {
+        if self.unsaved.lock().unwrap().records == 0 {
+            return Ok(());
+        }
+        self.save().await
+    }
+
+    /// Forgets `upload_id` once it has completed or been abandoned.
+    pub fn finish(&self, upload_id: &str) -> Result<(), DeployError> {
+        {
+            let mut manifests = self.manifests.lock().unwrap();
+            let before = manifests.len();
+            manifests.retain(|m| m.upload_id != upload_id);
+            if manifests.len() == before {
+                return Ok(());
+            }
+        }
+        self.persist()
+    }
+
+    /// Writes the manifests on a blocking thread.
+    async fn save(&self) -> Result<(), DeployError> {
+        let (generation, json) = self.snapshot()?;
+        let path = self.path.clone();
+        let written = self.written.clone();
+        tokio::task::spawn_blocking(move || write_snapshot(&path, &written, generation, &json))
+            .await
+            .map_err(|e| DeployError::Upload(format!("task join error: {e}")))?
+    }
+
+    fn persist(&self) -> Result<(), DeployError> {
+        let (generation, json) = self.snapshot()?;
+        write_snapshot(&self.path, &self.written, generation, &json)
+    }
+
+    /// Serializes the manifests and starts a new unsaved period.
+    fn snapshot(&self) -> Result<(u64, Vec<u8>), DeployError> {
+        let manifests = self.manifests.lock().unwrap();
+        let json = serde_json::to_vec_pretty(&*manifests)?;
+        let mut unsaved = self.unsaved.lock().unwrap();
+        unsaved.records = 0;
+        unsaved.since = Instant::now();
+        unsaved.generation += 1;
+        debug!(
+            "persisting {} resume manifest(s) to {:?}",
+            manifests.len(),
+            self.path
+        );
+        Ok((unsaved.generation, json))
+    }
+}
+
+/// Writes snapshot `generation` to `path` unless a newer one got there first.
+fn write_snapshot(
+    path: &Path,
+    written: &Mutex<u64>,
+    generation: u64,
+    json: &[u8],
+) -> Result<(), DeployError> {
+    let mut on_disk = written.lock().unwrap();
+    if *on_disk >= generation {
+        return Ok(());
+    }
+    // A crash mid-write must not leave a torn manifest behind.
+    write_atomic(path, json)?;
+    *on_disk = generation;
+    Ok(())
+}
+
+fn load_manifests(path: &Path) -> Result<Vec<ResumeManifest>, DeployError> {
+    if !path.exists() {
+        return Ok(Vec::new());
+    }
+    let data = std::fs::read_to_string(path)?;
+    if data.trim().is_empty() {
+        return Ok(Vec::new());
+    }
+    Ok(serde_json::from_str(&data)?)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn files() -> Vec<FileEntry> {
+        vec![
+            FileEntry {
+                relative_path: "game.exe".into(),
+                size: 10,
+                modified: 0,
+            },
+            FileEntry {
+                relative_path: "data/level1.pak".into(),
+                size: 100,
+                modified: 0,
+            },
+        ]
+    }
+
+    #[tokio::test]
+    async fn offsets_survive_reopen() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("resume.json");
+
+        let store = ResumeStore::new(path.clone()).unwrap();
+        store
+            .begin(ResumeManifest::new("u1", "setup", "deck", &files()))
+            .unwrap();
+        store.record("u1", "game.exe", 10).await.unwrap();
+        store.record("u1", "data/level1.pak", 40).await.unwrap();
+        store.record("other", "game.exe", 5).await.unwrap();
+        store.flush().await.unwrap();
+        drop(store);
+
+        let reopened = ResumeStore::new(path).unwrap();
+        let manifest = reopened.get("setup", "deck").unwrap();
+        assert_eq!(manifest.upload_id, "u1");
+        assert_eq!(manifest.offsets["data/level1.pak"], 40);
+        assert_eq!(manifest.acknowledged_bytes(), 50);
+        assert!(manifest.matches_files(&files()));
+        assert!(reopened.get("setup", "other-agent").is_none());
+    }
+
+    #[test]
+    fn begin_replaces_and_finish_removes() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("resume.json");
+        let store = ResumeStore::new(path.clone()).unwrap();
+
+        store
+            .begin(ResumeManifest::new("u1", "setup", "deck", &files()))
+            .unwrap();
+        store
+            .begin(ResumeManifest::new("u2", "setup", "deck", &files()[..1]))
+            .unwrap();
+        let manifest = store.get("setup", "deck").unwrap();
+        assert_eq!(manifest.upload_id, "u2");
+        assert!(!manifest.matches_files(&files()));
+
+        store.finish("u2").unwrap();
+        assert!(store.get("setup", "deck").is_none());
+        assert!(
+            ResumeStore::new(path)
+                .unwrap()
+                .get("setup", "deck")
+                .is_none()
+        );
+    }
+
+    #[tokio::test]
+    async fn offsets_are_written_in_batches() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("resume.json");
+        let store = ResumeStore::new(path.clone()).unwrap();
+        store
+            .begin(ResumeManifest::new("u1", "setup", "deck", &files()))
+            .unwrap();
+        let on_disk = || {
+            ResumeStore::new(path.clone())
+                .unwrap()
+                .get("setup", "deck")
+                .unwrap()
+                .acknowledged_bytes()
+        };
+
+        for offset in 1..SAVE_EVERY_CHUNKS as i64 {
+            store.record("u1", "data/level1.pak", offset).await.unwrap();
+        }
+        assert_eq!(on_disk(), 0);
+
+        store
+            .record("u1", "data/level1.pak", SAVE_EVERY_CHUNKS as i64)
+            .await
+            .unwrap();
+        assert_eq!(on_disk(), SAVE_EVERY_CHUNKS as i64);
+
+        store.record("u1", "game.exe", 10).await.unwrap();
+        assert_eq!(on_disk(), SAVE_EVERY_CHUNKS as i64);
+        store.flush().await.unwrap();
+        assert_eq!(on_disk(), SAVE_EVERY_CHUNKS as i64 + 10);
+    }
+}
@@ -47,6 +47,7 @@ fn walk_dir(
             files.push(FileEntry {
                 relative_path: rel_str,
                 size,
+                modified: capydeploy_transfer::modified_unix_secs(&metadata),
             });
             *total_size += size;
         }
@@ -0,0 +1,138 @@
+//! Import of shortcut definitions exported from another device.
+//!
+//! The agent's `export_shortcut` produces a [`PortableShortcut`]; importing
+//! it here turns the shared configuration back into a [`GameSetup`] bound
+//! to the importer's own copy of the build.
+
+use std::path::Path;
+
+use capydeploy_protocol::portable::{PORTABLE_SHORTCUT_VERSION, PortableShortcut};
+
+use crate::error::DeployError;
+use crate::launch_options::validate_template;
+use crate::types::GameSetup;
+
+/// Builds a game setup from a shared shortcut definition.
+///
+/// `local_path` is the importer's build folder. The setup installs to the
+/// agent's default path and has no ID; the caller assigns one when saving.
+pub fn setup_from_portable(
+    shortcut: &PortableShortcut,
+    local_path: &str,
+) -> Result<GameSetup, DeployError> {
+    if shortcut.version > PORTABLE_SHORTCUT_VERSION {
+        return Err(DeployError::Import(format!(
+            "shortcut format v{} is newer than supported v{PORTABLE_SHORTCUT_VERSION}",
+            shortcut.version
+        )));
+    }
+    if shortcut.name.trim().is_empty() {
+        return Err(DeployError::Import("shortcut has no name".into()));
+    }
+    let exe = Path::new(&shortcut.executable);
+    if shortcut.executable.is_empty()
+        || exe.is_absolute()
+        || shortcut.executable.starts_with(['/', '\\'])
+        || exe
+            .components()
+            .any(|c| matches!(c, std::path::Component::ParentDir))
+    {
+        return Err(DeployError::Import(format!(
+            "executable must be relative to the game folder: {}",
+            shortcut.executable
+        )));
+    }
+    validate_template(&shortcut.launch_options)?;
+
+    Ok(GameSetup {
+        id: String::new(),
+        name: shortcut.name.trim().to_string(),
+        local_path: local_path.to_string(),
+        executable: shortcut.executable.clone(),
+        launch_options: shortcut.launch_options.clone(),
+        tags: shortcut.tags.join(", "),
+        install_path: String::new(),
+        griddb_game_id: 0,
+        grid_portrait: String::new(),
+        grid_landscape: String::new(),
+        hero_image: String::new(),
+        logo_image: String::new(),
+        icon_image: String::new(),
+        boot_video: String::new(),
+        mark_recent: false,
+        artwork_all_users: false,
+        library_path: String::new(),
+        install_subpath: String::new(),
+        compat_tool: String::new(),
+        clean_install: false,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::artwork_selector::parse_tags;
+    use crate::launch_options::resolve_launch_options;
+    use capydeploy_protocol::types::ShortcutInfo;
+
+    fn exported() -> PortableShortcut {
+        let info = ShortcutInfo {
+            app_id: 3_000_000_001,
+            name: "Celeste".into(),
+            exe: "\"/home/deck/Games/Celeste/bin/Celeste.exe\"".into(),
+            start_dir: "\"/home/deck/Games/Celeste/\"".into(),
+            launch_options: "PROTON_LOG=1 %command% --data /home/deck/Games/Celeste/data".into(),
+            tags: vec!["Platformer".into(), "Indie".into()],
+            last_played: 0,
+            needs_compat_tool: true,
+            size_bytes: 0,
+            broken: false,
+        };
+        let json =
+            serde_json::to_string(&PortableShortcut::from_shortcut(&info, "proton_9")).unwrap();
+        serde_json::from_str(&json).unwrap()
+    }
+
+    #[test]
+    fn export_import_round_trip() {
+        let shortcut = exported();
+        let mut setup = setup_from_portable(&shortcut, "/builds/celeste").unwrap();
+
+        assert_eq!(setup.name, "Celeste");
+        assert_eq!(setup.local_path, "/builds/celeste");
+        assert_eq!(setup.executable, "bin/Celeste.exe");
+        assert_eq!(parse_tags(&setup.tags), shortcut.tags);
+        assert!(setup.id.is_empty() && setup.install_path.is_empty());
+
+        // Deploying to the teammate's device rebinds {installdir}.
+        setup.install_path = "/run/media/sd/Games".into();
+        assert_eq!(
+            resolve_launch_options(&setup, "", "").unwrap(),
+            "PROTON_LOG=1 %command% --data /run/media/sd/Games/Celeste/data"
+        );
+    }
+
+    #[test]
+    fn rejects_unsafe_or_newer_definitions() {
+        let mut shortcut = exported();
+        shortcut.executable = "/usr/bin/sh".into();
+        assert!(matches!(
+            setup_from_portable(&shortcut, "/b"),
+            Err(DeployError::Import(_))
+        ));
+
+        shortcut.executable = "../escape.sh".into();
+        assert!(setup_from_portable(&shortcut, "/b").is_err());
+
+        let mut shortcut = exported();
+        shortcut.version = PORTABLE_SHORTCUT_VERSION + 1;
+        assert!(setup_from_portable(&shortcut, "/b").is_err());
+
+        let mut shortcut = exported();
+        shortcut.launch_options = "--x {unknown}".into();
+        assert!(matches!(
+            setup_from_portable(&shortcut, "/b"),
+            Err(DeployError::LaunchOptions(_))
+        ));
+    }
+}
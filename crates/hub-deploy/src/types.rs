@@ -4,6 +4,8 @@ use std::collections::HashMap;
 
 use serde::{Deserialize, Serialize};
 
+use crate::error::DeployError;
+
 /// A saved game installation setup (Hub-side config).
 ///
 /// Mirrors Go's `config.GameSetup`.
@@ -30,12 +32,53 @@ pub struct GameSetup {
     pub logo_image: String,
     #[serde(default, skip_serializing_if = "String::is_empty")]
     pub icon_image: String,
+    /// Startup movie (.webm), relative to the game folder, offered to Steam
+    /// after install.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub boot_video: String,
+    /// Surface the game at the top of Steam's Recent view after deploy.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub mark_recent: bool,
+    /// Write artwork for every Steam account on the device with the shortcut.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub artwork_all_users: bool,
+    /// Steam library on the agent to install into; overrides `install_path`.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub library_path: String,
+    /// Subfolder of the install target to put the game folder in, e.g.
+    /// `jams/2026`, remembered so every deploy lands in the same place.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub install_subpath: String,
+    /// Steam compatibility tool to set on the shortcut (e.g.
+    /// `proton_experimental`); empty keeps the agent's default.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub compat_tool: String,
+    /// Delete the game folder on the agent before each deploy, so files
+    /// dropped from the build don't linger.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub clean_install: bool,
+}
+
+impl GameSetup {
+    /// Checks that `install_subpath` is relative and stays inside the
+    /// install target.
+    pub fn validate_install_subpath(&self) -> Result<(), DeployError> {
+        if self.install_subpath.is_empty() {
+            return Ok(());
+        }
+        capydeploy_transfer::validate_upload_path(&self.install_subpath)?;
+        Ok(())
+    }
 }
 
 fn is_zero_i32(v: &i32) -> bool {
     *v == 0
 }
 
+fn is_false(v: &bool) -> bool {
+    !v
+}
+
 /// Classification of an artwork source path.
 #[derive(Debug, Clone, Default, PartialEq, Eq)]
 pub enum ArtworkSource {
@@ -79,6 +122,8 @@ pub enum DeployEvent {
     Completed { agent_id: String },
     /// Deployment failed for an agent.
     Failed { agent_id: String, error: String },
+    /// Deployment was cancelled by the user before it finished.
+    Cancelled { agent_id: String },
 }
 
 /// Result of a single agent deployment.
@@ -97,6 +142,40 @@ pub struct DeployConfig {
     pub artwork: ArtworkAssignment,
 }
 
+/// What deploying a setup to an agent would do, found by a dry run.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DeployPreview {
+    pub agent_id: String,
+    /// Local files that make up the game.
+    pub file_count: usize,
+    pub total_bytes: i64,
+    /// Bytes that would actually be sent; files the agent already has
+    /// unchanged are left out.
+    pub transfer_bytes: i64,
+    pub skipped_files: usize,
+    /// Directory the agent would install into.
+    pub game_path: String,
+    /// Free space at the install location, if the agent could measure it.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub available_bytes: Option<u64>,
+    /// Problems that would make the deploy fail.
+    pub blockers: Vec<capydeploy_protocol::messages::DeployBlocker>,
+    /// Problems that wouldn't stop it, such as missing artwork.
+    pub warnings: Vec<String>,
+    /// Shortcut the agent already has for the game. The deploy is refused
+    /// unless it overwrites it.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub conflict: Option<capydeploy_protocol::messages::ShortcutConflict>,
+}
+
+impl DeployPreview {
+    /// Whether nothing found would stop the deploy.
+    pub fn can_deploy(&self) -> bool {
+        self.blockers.is_empty()
+    }
+}
+
 /// Response from InitUpload on the agent side.
 #[derive(Debug, Clone)]
 pub struct InitUploadResult {
@@ -107,6 +186,12 @@ pub struct InitUploadResult {
     pub tcp_port: Option<u16>,
     /// TCP data channel authentication token.
     pub tcp_token: Option<String>,
+    /// Chunks the agent accepts in flight at once (0 = one at a time).
+    pub max_concurrent_chunks: u32,
+    /// Codec the agent accepted for WS chunks.
+    pub compression: capydeploy_transfer::Compression,
+    /// Files the agent already has unchanged; they aren't sent.
+    pub skip_files: Vec<String>,
 }
 
 /// Response from CompleteUpload on the agent side.
@@ -115,6 +200,8 @@ pub struct CompleteUploadResult {
     pub success: bool,
     pub path: String,
     pub app_id: u32,
+    /// Non-fatal issues reported by the agent.
+    pub warnings: Vec<String>,
 }
 
 #[cfg(test)]
@@ -137,11 +224,39 @@ mod tests {
             hero_image: String::new(),
             logo_image: String::new(),
             icon_image: String::new(),
+            boot_video: String::new(),
+            mark_recent: false,
+            artwork_all_users: false,
+            library_path: String::new(),
+            install_subpath: String::new(),
+            compat_tool: String::new(),
+            clean_install: false,
         };
         let json = serde_json::to_string(&setup).unwrap();
         assert!(!json.contains("launch_options"));
         assert!(!json.contains("griddb_game_id"));
+        assert!(!json.contains("install_subpath"));
         let parsed: GameSetup = serde_json::from_str(&json).unwrap();
         assert_eq!(setup, parsed);
     }
+
+    #[test]
+    fn install_subpath_must_stay_inside_target() {
+        let mut setup: GameSetup = serde_json::from_str(
+            r#"{"id":"g","name":"Game","local_path":"/src","executable":"game","install_path":""}"#,
+        )
+        .unwrap();
+        assert!(setup.validate_install_subpath().is_ok());
+
+        setup.install_subpath = "jams/2026".into();
+        assert!(setup.validate_install_subpath().is_ok());
+
+        for escaping in ["../elsewhere", "jams/../../etc", "/etc"] {
+            setup.install_subpath = escaping.into();
+            assert!(
+                setup.validate_install_subpath().is_err(),
+                "{escaping} accepted"
+            );
+        }
+    }
 }
@@ -0,0 +1,290 @@
+//! Watch-deploy mode: re-deploy a game setup when its local files change.
+//!
+//! A filesystem watcher on the setup's `local_path` feeds change
+//! notifications into a debounce loop. Once the tree has been quiet for
+//! the debounce window, the redeploy callback runs. Changes that arrive
+//! while a redeploy is in flight are coalesced into a single follow-up run.
+
+use std::future::Future;
+use std::path::Path;
+use std::pin::Pin;
+use std::time::Duration;
+
+use notify::{RecommendedWatcher, RecursiveMode, Watcher};
+use tokio::sync::mpsc;
+use tokio_util::sync::CancellationToken;
+use tracing::{debug, info, warn};
+
+use crate::error::DeployError;
+
+/// Default quiet period before a change burst triggers a redeploy.
+pub const DEFAULT_WATCH_DEBOUNCE: Duration = Duration::from_millis(750);
+
+/// Capacity of the change notification channel. Overflow is harmless:
+/// a single pending notification is enough to schedule the next run.
+const CHANGE_CHANNEL_SIZE: usize = 64;
+
+/// Callback invoked for each debounced redeploy.
+pub type RedeployFn = Box<dyn FnMut() -> Pin<Box<dyn Future<Output = ()> + Send>> + Send>;
+
+/// An active watch-deploy session.
+///
+/// Dropping the session (or calling [`WatchDeploy::stop`]) stops the
+/// watcher and the debounce loop. A redeploy already in progress is
+/// allowed to finish; cancelling it is the caller's responsibility.
+pub struct WatchDeploy {
+    cancel: CancellationToken,
+    _watcher: RecommendedWatcher,
+}
+
+impl WatchDeploy {
+    /// Starts watching `root` recursively and calls `redeploy` after each
+    /// burst of changes has settled for `debounce`.
+    pub fn start(
+        root: &Path,
+        debounce: Duration,
+        redeploy: RedeployFn,
+    ) -> Result<Self, DeployError> {
+        let (tx, rx) = mpsc::channel(CHANGE_CHANNEL_SIZE);
+
+        let mut watcher =
+            notify::recommended_watcher(move |res: notify::Result<notify::Event>| match res {
+                Ok(event) if event.kind.is_access() => {}
+                Ok(_) => {
+                    let _ = tx.try_send(());
+                }
+                Err(e) => warn!(error = %e, "watch-deploy watcher error"),
+            })
+            .map_err(|e| DeployError::Watch(e.to_string()))?;
+
+        watcher
+            .watch(root, RecursiveMode::Recursive)
+            .map_err(|e| DeployError::Watch(format!("{}: {e}", root.display())))?;
+
+        let cancel = CancellationToken::new();
+        tokio::spawn(debounce_loop(rx, debounce, cancel.clone(), redeploy));
+
+        info!(path = %root.display(), "watch-deploy started");
+
+        Ok(Self {
+            cancel,
+            _watcher: watcher,
+        })
+    }
+
+    /// Stops the session. Pending (not yet started) redeploys are discarded.
+    pub fn stop(&self) {
+        self.cancel.cancel();
+    }
+
+    /// Returns whether the session is still running.
+    pub fn is_running(&self) -> bool {
+        !self.cancel.is_cancelled()
+    }
+}
+
+impl Drop for WatchDeploy {
+    fn drop(&mut self) {
+        self.cancel.cancel();
+    }
+}
+
+/// Waits for change notifications and runs `redeploy` once per settled burst.
+///
+/// Every notification restarts the debounce timer, so a rapid sequence of
+/// saves produces exactly one redeploy. Notifications received during a
+/// redeploy stay queued in `rx` and schedule one more run afterwards.
+pub(crate) async fn debounce_loop(
+    mut rx: mpsc::Receiver<()>,
+    debounce: Duration,
+    cancel: CancellationToken,
+    mut redeploy: RedeployFn,
+) {
+    loop {
+        // Wait for the first change of a burst.
+        tokio::select! {
+            _ = cancel.cancelled() => break,
+            msg = rx.recv() => {
+                if msg.is_none() {
+                    break;
+                }
+            }
+        }
+
+        // Extend the window while changes keep arriving.
+        let deadline = tokio::time::sleep(debounce);
+        tokio::pin!(deadline);
+        loop {
+            tokio::select! {
+                _ = cancel.cancelled() => return,
+                () = &mut deadline => break,
+                msg = rx.recv() => {
+                    if msg.is_none() {
+                        break;
+                    }
+                    deadline.as_mut().reset(tokio::time::Instant::now() + debounce);
+                }
+            }
+        }
+
+        if cancel.is_cancelled() {
+            break;
+        }
+
+        debug!("watch-deploy: changes settled, redeploying");
+        redeploy().await;
+    }
+
+    debug!("watch-deploy loop stopped");
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::Arc;
+    use std::sync::atomic::{AtomicUsize, Ordering};
+
+    const DEBOUNCE: Duration = Duration::from_millis(500);
+
+    fn counting_redeploy(count: Arc<AtomicUsize>, work: Duration) -> RedeployFn {
+        Box::new(move || {
+            let count = count.clone();
+            Box::pin(async move {
+                tokio::time::sleep(work).await;
+                count.fetch_add(1, Ordering::SeqCst);
+            })
+        })
+    }
+
+    async fn settle(d: Duration) {
+        tokio::time::sleep(d).await;
+        for _ in 0..10 {
+            tokio::task::yield_now().await;
+        }
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn rapid_changes_trigger_single_redeploy() {
+        let count = Arc::new(AtomicUsize::new(0));
+        let (tx, rx) = mpsc::channel(CHANGE_CHANNEL_SIZE);
+        let cancel = CancellationToken::new();
+        tokio::spawn(debounce_loop(
+            rx,
+            DEBOUNCE,
+            cancel.clone(),
+            counting_redeploy(count.clone(), Duration::ZERO),
+        ));
+
+        for _ in 0..10 {
+            tx.send(()).await.unwrap();
+            settle(Duration::from_millis(100)).await;
+        }
+        assert_eq!(count.load(Ordering::SeqCst), 0, "still within debounce");
+
+        settle(DEBOUNCE * 2).await;
+        assert_eq!(count.load(Ordering::SeqCst), 1);
+        cancel.cancel();
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn separate_bursts_trigger_separate_redeploys() {
+        let count = Arc::new(AtomicUsize::new(0));
+        let (tx, rx) = mpsc::channel(CHANGE_CHANNEL_SIZE);
+        let cancel = CancellationToken::new();
+        tokio::spawn(debounce_loop(
+            rx,
+            DEBOUNCE,
+            cancel.clone(),
+            counting_redeploy(count.clone(), Duration::ZERO),
+        ));
+
+        tx.send(()).await.unwrap();
+        settle(DEBOUNCE * 2).await;
+        tx.send(()).await.unwrap();
+        settle(DEBOUNCE * 2).await;
+
+        assert_eq!(count.load(Ordering::SeqCst), 2);
+        cancel.cancel();
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn changes_during_redeploy_coalesce_into_one_followup() {
+        let count = Arc::new(AtomicUsize::new(0));
+        let (tx, rx) = mpsc::channel(CHANGE_CHANNEL_SIZE);
+        let cancel = CancellationToken::new();
+        let work = Duration::from_secs(5);
+        tokio::spawn(debounce_loop(
+            rx,
+            DEBOUNCE,
+            cancel.clone(),
+            counting_redeploy(count.clone(), work),
+        ));
+
+        tx.send(()).await.unwrap();
+        // Debounce elapses, first redeploy is now running.
+        settle(DEBOUNCE + Duration::from_millis(100)).await;
+        for _ in 0..5 {
+            tx.send(()).await.unwrap();
+        }
+
+        settle(work * 4).await;
+        assert_eq!(count.load(Ordering::SeqCst), 2);
+        cancel.cancel();
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn cancel_discards_pending_redeploy() {
+        let count = Arc::new(AtomicUsize::new(0));
+        let (tx, rx) = mpsc::channel(CHANGE_CHANNEL_SIZE);
+        let cancel = CancellationToken::new();
+        let handle = tokio::spawn(debounce_loop(
+            rx,
+            DEBOUNCE,
+            cancel.clone(),
+            counting_redeploy(count.clone(), Duration::ZERO),
+        ));
+
+        tx.send(()).await.unwrap();
+        settle(Duration::from_millis(100)).await;
+        cancel.cancel();
+        handle.await.unwrap();
+
+        assert_eq!(count.load(Ordering::SeqCst), 0);
+    }
+
+    #[tokio::test]
+    async fn file_write_triggers_redeploy() {
+        let dir = tempfile::tempdir().unwrap();
+        let count = Arc::new(AtomicUsize::new(0));
+        let session = WatchDeploy::start(
+            dir.path(),
+            Duration::from_millis(100),
+            counting_redeploy(count.clone(), Duration::ZERO),
+        )
+        .unwrap();
+
+        std::fs::write(dir.path().join("game.exe"), b"v1").unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"v2").unwrap();
+
+        let deadline = std::time::Instant::now() + Duration::from_secs(5);
+        while count.load(Ordering::SeqCst) == 0 && std::time::Instant::now() < deadline {
+            tokio::time::sleep(Duration::from_millis(50)).await;
+        }
+        assert!(count.load(Ordering::SeqCst) >= 1);
+
+        session.stop();
+        assert!(!session.is_running());
+    }
+
+    #[test]
+    fn start_on_missing_path_fails() {
+        let rt = tokio::runtime::Runtime::new().unwrap();
+        let _guard = rt.enter();
+        let result = WatchDeploy::start(
+            Path::new("/nonexistent/watch/deploy/path"),
+            DEBOUNCE,
+            Box::new(|| Box::pin(async {})),
+        );
+        assert!(matches!(result, Err(DeployError::Watch(_))));
+    }
+}
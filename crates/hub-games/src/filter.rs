@@ -0,0 +1,156 @@
+//! Filtering of the installed-games list.
+//!
+//! Applied Hub-side over the list returned by
+//! [`GamesManager::get_installed_games`](crate::GamesManager::get_installed_games),
+//! using the tags, folder size and broken flag the agent reports.
+
+use serde::{Deserialize, Serialize};
+
+use crate::types::InstalledGame;
+
+/// Criteria for narrowing the installed-games list. Every set criterion
+/// must match; the default filter keeps everything.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase", default)]
+pub struct GamesFilter {
+    /// Tags the game must all have (case-insensitive).
+    pub tags: Vec<String>,
+    /// Minimum folder size in bytes; 0 for no minimum.
+    pub min_size: u64,
+    /// Maximum folder size in bytes; 0 for no maximum.
+    pub max_size: u64,
+    /// Keep only games whose executable is missing.
+    pub broken_only: bool,
+}
+
+impl GamesFilter {
+    /// Whether `game` passes the filter.
+    ///
+    /// Games with an unknown size (reported as 0) never pass a size bound.
+    pub fn matches(&self, game: &InstalledGame) -> bool {
+        if self.broken_only && !game.broken {
+            return false;
+        }
+        let sized = self.min_size > 0 || self.max_size > 0;
+        if sized && game.size_bytes == 0 {
+            return false;
+        }
+        if self.min_size > 0 && game.size_bytes < self.min_size {
+            return false;
+        }
+        if self.max_size > 0 && game.size_bytes > self.max_size {
+            return false;
+        }
+        self.tags.iter().all(|want| {
+            game.tags
+                .iter()
+                .any(|have| have.trim().eq_ignore_ascii_case(want.trim()))
+        })
+    }
+
+    /// Keeps the games that pass the filter, preserving order.
+    pub fn apply(&self, games: Vec<InstalledGame>) -> Vec<InstalledGame> {
+        games.into_iter().filter(|g| self.matches(g)).collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const GB: u64 = 1024 * 1024 * 1024;
+
+    fn game(name: &str, tags: &[&str], size_bytes: u64, broken: bool) -> InstalledGame {
+        InstalledGame {
+            name: name.into(),
+            path: format!("/games/{name}"),
+            size: String::new(),
+            app_id: 0,
+            needs_compat_tool: false,
+            tags: tags.iter().map(|t| t.to_string()).collect(),
+            size_bytes,
+            broken,
+        }
+    }
+
+    fn library() -> Vec<InstalledGame> {
+        vec![
+            game("Celeste", &["Platformer", "Indie"], GB / 2, false),
+            game("Hades", &["Roguelike", "Indie"], 12 * GB, false),
+            game("Old Build", &["Platformer"], 3 * GB, true),
+            game("Legacy", &[], 0, true),
+        ]
+    }
+
+    fn names(games: &[InstalledGame]) -> Vec<&str> {
+        games.iter().map(|g| g.name.as_str()).collect()
+    }
+
+    #[test]
+    fn default_filter_keeps_everything() {
+        assert_eq!(GamesFilter::default().apply(library()).len(), 4);
+    }
+
+    #[test]
+    fn filters_by_tag() {
+        let filter = GamesFilter {
+            tags: vec!["indie".into()],
+            ..Default::default()
+        };
+        assert_eq!(names(&filter.apply(library())), ["Celeste", "Hades"]);
+
+        let filter = GamesFilter {
+            tags: vec!["Indie".into(), "Platformer".into()],
+            ..Default::default()
+        };
+        assert_eq!(names(&filter.apply(library())), ["Celeste"]);
+    }
+
+    #[test]
+    fn filters_by_size() {
+        let over_5gb = GamesFilter {
+            min_size: 5 * GB,
+            ..Default::default()
+        };
+        assert_eq!(names(&over_5gb.apply(library())), ["Hades"]);
+
+        let under_5gb = GamesFilter {
+            max_size: 5 * GB,
+            ..Default::default()
+        };
+        // "Legacy" has no known size, so it can't be shown to be small.
+        assert_eq!(names(&under_5gb.apply(library())), ["Celeste", "Old Build"]);
+
+        let between = GamesFilter {
+            min_size: GB,
+            max_size: 5 * GB,
+            ..Default::default()
+        };
+        assert_eq!(names(&between.apply(library())), ["Old Build"]);
+    }
+
+    #[test]
+    fn filters_broken_only() {
+        let filter = GamesFilter {
+            broken_only: true,
+            ..Default::default()
+        };
+        assert_eq!(names(&filter.apply(library())), ["Old Build", "Legacy"]);
+
+        let combined = GamesFilter {
+            broken_only: true,
+            tags: vec!["platformer".into()],
+            ..Default::default()
+        };
+        assert_eq!(names(&combined.apply(library())), ["Old Build"]);
+    }
+
+    #[test]
+    fn deserializes_partial_filter() {
+        let filter: GamesFilter =
+            serde_json::from_str(r#"{"minSize":5368709120,"brokenOnly":true}"#).unwrap();
+        assert_eq!(filter.min_size, 5 * GB);
+        assert!(filter.broken_only);
+        assert!(filter.tags.is_empty());
+    }
+}
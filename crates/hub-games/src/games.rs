@@ -10,14 +10,19 @@ use std::pin::Pin;
 use capydeploy_protocol::constants::MessageType;
 use capydeploy_protocol::envelope::Message;
 use capydeploy_protocol::messages::{
-    DeleteGameRequest, DeleteGameResponse, ListShortcutsRequest, SetGameLogWrapperRequest,
-    ShortcutsListResponse, SteamUsersResponse,
+    AppliedArtworkResponse, DeleteGameRequest, DeleteGameResponse, DeleteGamesBatchRequest,
+    DeleteGamesBatchResponse, ExportShortcutRequest, ExportShortcutResponse, GetArtworkRequest,
+    LaunchGameRequest, LaunchGameResponse, ListShortcutsRequest, RenameGameRequest,
+    RenameGameResponse, RestartSteamResponse, SetGameLogWrapperRequest, ShortcutsListResponse,
+    SteamUsersResponse, UpdateShortcutRequest, UpdateShortcutResponse,
 };
+use capydeploy_protocol::portable::PortableShortcut;
 use capydeploy_protocol::telemetry::SetGameLogWrapperResponse;
 use tracing::{debug, warn};
 
 use crate::error::GamesError;
-use crate::types::{ArtworkUpdate, InstalledGame};
+use crate::types::{ArtworkUpdate, InstalledGame, ShortcutUpdate};
+use crate::wipe::{WipeFailure, WipePlan, WipeReport};
 
 /// Abstract connection to an Agent.
 ///
@@ -61,26 +66,12 @@ impl GamesManager {
         &self,
         conn: &dyn AgentConnection,
     ) -> Result<Vec<InstalledGame>, GamesError> {
-        // 1. Get Steam users.
-        let payload = serde_json::json!({});
-        let resp = conn
-            .send_request(MessageType::GetSteamUsers, &payload)
-            .await?;
-
-        let users_resp: SteamUsersResponse = resp
-            .parse_payload::<SteamUsersResponse>()?
-            .ok_or_else(|| GamesError::Agent("empty steam users response".into()))?;
-
-        if users_resp.users.is_empty() {
+        // 1. Get the first Steam user.
+        let Some(user_id) = self.first_steam_user(conn).await? else {
             return Ok(Vec::new());
-        }
-
-        // 2. List shortcuts for the first user.
-        let user_id: u32 = users_resp.users[0]
-            .id
-            .parse()
-            .map_err(|e| GamesError::Agent(format!("invalid user id: {e}")))?;
+        };
 
+        // 2. List shortcuts for that user.
         let list_req = ListShortcutsRequest { user_id };
         let payload = serde_json::to_value(&list_req)?;
         let resp = conn
@@ -98,14 +89,43 @@ impl GamesManager {
             .map(|sc| InstalledGame {
                 name: sc.name,
                 path: sc.start_dir,
-                size: "N/A".into(),
+                size: format_size(sc.size_bytes),
                 app_id: sc.app_id,
+                needs_compat_tool: sc.needs_compat_tool,
+                tags: sc.tags,
+                size_bytes: sc.size_bytes,
+                broken: sc.broken,
             })
             .collect();
 
         Ok(games)
     }
 
+    /// Returns the ID of the agent's first Steam user, the one games are
+    /// installed for, or `None` when the agent has no Steam users.
+    pub async fn first_steam_user(
+        &self,
+        conn: &dyn AgentConnection,
+    ) -> Result<Option<u32>, GamesError> {
+        let payload = serde_json::json!({});
+        let resp = conn
+            .send_request(MessageType::GetSteamUsers, &payload)
+            .await?;
+
+        let users_resp: SteamUsersResponse = resp
+            .parse_payload::<SteamUsersResponse>()?
+            .ok_or_else(|| GamesError::Agent("empty steam users response".into()))?;
+
+        users_resp
+            .users
+            .first()
+            .map(|u| {
+                u.id.parse()
+                    .map_err(|e| GamesError::Agent(format!("invalid user id: {e}")))
+            })
+            .transpose()
+    }
+
     /// Deletes a game from the agent.
     ///
     /// The agent handles everything internally: user detection, file deletion,
@@ -126,6 +146,146 @@ impl GamesManager {
         Ok(delete_resp)
     }
 
+    /// Deletes several games in one `delete_games_batch` request.
+    ///
+    /// The agent removes them under one Steam connection and, with
+    /// `restart_steam`, restarts Steam once after the last one. The
+    /// response has one result per AppID, in order; a game that fails
+    /// doesn't stop the rest.
+    pub async fn delete_games(
+        &self,
+        conn: &dyn AgentConnection,
+        app_ids: &[u32],
+        restart_steam: bool,
+    ) -> Result<DeleteGamesBatchResponse, GamesError> {
+        let req = DeleteGamesBatchRequest {
+            app_ids: app_ids.to_vec(),
+            restart_steam,
+        };
+        let payload = serde_json::to_value(&req)?;
+        let resp = conn
+            .send_request(MessageType::DeleteGamesBatch, &payload)
+            .await?;
+
+        resp.parse_payload::<DeleteGamesBatchResponse>()?
+            .ok_or_else(|| GamesError::Agent("empty delete games batch response".into()))
+    }
+
+    /// Deletes every game in `plan`, then restarts Steam once so the
+    /// removed shortcuts disappear from the library.
+    ///
+    /// A game the agent fails to delete is reported in
+    /// [`WipeReport::failed`] and doesn't stop the rest.
+    pub async fn wipe_games(
+        &self,
+        conn: &dyn AgentConnection,
+        plan: &WipePlan,
+    ) -> Result<WipeReport, GamesError> {
+        let mut report = WipeReport {
+            preserved: plan.preserved,
+            ..Default::default()
+        };
+        for game in &plan.games {
+            match self.delete_game(conn, game.app_id).await {
+                Ok(_) => {
+                    report.removed.push(game.name.clone());
+                    report.reclaimed_bytes += game.size_bytes;
+                }
+                Err(e) => {
+                    warn!(
+                        app_id = game.app_id,
+                        "wipe: failed to delete {}: {e}", game.name
+                    );
+                    report.failed.push(WipeFailure {
+                        app_id: game.app_id,
+                        name: game.name.clone(),
+                        error: e.to_string(),
+                    });
+                }
+            }
+        }
+        if report.removed.is_empty() {
+            return Ok(report);
+        }
+
+        // The games are gone either way, so a failed restart only shows
+        // up in the report.
+        report.steam_restarted = match conn
+            .send_request(MessageType::RestartSteam, &serde_json::json!({}))
+            .await
+        {
+            Ok(resp) => resp
+                .parse_payload::<RestartSteamResponse>()?
+                .is_some_and(|r| r.success),
+            Err(e) => {
+                warn!("wipe: failed to restart Steam: {e}");
+                false
+            }
+        };
+        Ok(report)
+    }
+
+    /// Renames an installed game on the agent.
+    ///
+    /// The agent updates the shortcut name and migrates artwork to the
+    /// recomputed AppID, rolling back on failure. The returned response
+    /// carries the new AppID.
+    pub async fn rename_game(
+        &self,
+        conn: &dyn AgentConnection,
+        app_id: u32,
+        new_name: &str,
+    ) -> Result<RenameGameResponse, GamesError> {
+        let req = RenameGameRequest {
+            app_id,
+            new_name: new_name.to_string(),
+        };
+        let payload = serde_json::to_value(&req)?;
+        let resp = conn.send_request(MessageType::RenameGame, &payload).await?;
+
+        let rename_resp: RenameGameResponse = resp
+            .parse_payload::<RenameGameResponse>()?
+            .ok_or_else(|| GamesError::Agent("empty rename game response".into()))?;
+
+        Ok(rename_resp)
+    }
+
+    /// Exports a game's shortcut definition without device-specific paths,
+    /// for importing as a setup on another Hub.
+    pub async fn export_shortcut(
+        &self,
+        conn: &dyn AgentConnection,
+        app_id: u32,
+    ) -> Result<PortableShortcut, GamesError> {
+        let payload = serde_json::to_value(ExportShortcutRequest { app_id })?;
+        let resp = conn
+            .send_request(MessageType::ExportShortcut, &payload)
+            .await?;
+
+        let export_resp: ExportShortcutResponse =
+            resp.parse_payload::<ExportShortcutResponse>()?
+                .ok_or_else(|| GamesError::Agent("empty export shortcut response".into()))?;
+
+        Ok(export_resp.shortcut)
+    }
+
+    /// Fetches the artwork files currently on a game's shortcut, for
+    /// preview. Types with no file are listed in `missing`.
+    pub async fn get_artwork(
+        &self,
+        conn: &dyn AgentConnection,
+        app_id: u32,
+    ) -> Result<AppliedArtworkResponse, GamesError> {
+        let payload = serde_json::to_value(GetArtworkRequest {
+            app_id,
+            user_id: String::new(),
+        })?;
+        let resp = conn.send_request(MessageType::GetArtwork, &payload).await?;
+
+        resp.parse_payload::<AppliedArtworkResponse>()?
+            .ok_or_else(|| GamesError::Agent("empty artwork response".into()))
+    }
+
     /// Updates artwork for an installed game.
     ///
     /// For each non-empty field in `artwork`:
@@ -208,6 +368,52 @@ impl GamesManager {
         Ok(wrapper_resp)
     }
 
+    /// Changes an installed game's shortcut in place, keeping its artwork.
+    ///
+    /// A new name gives the shortcut a new AppID (returned in the response);
+    /// the agent moves the artwork over.
+    pub async fn update_shortcut(
+        &self,
+        conn: &dyn AgentConnection,
+        user_id: u32,
+        app_id: u32,
+        update: &ShortcutUpdate,
+    ) -> Result<UpdateShortcutResponse, GamesError> {
+        let req = UpdateShortcutRequest {
+            user_id,
+            app_id,
+            name: update.name.clone(),
+            launch_options: update.launch_options.clone(),
+            tags: update.tags.clone(),
+            start_dir: update.start_dir.clone(),
+        };
+        if req.is_empty() {
+            return Err(GamesError::Agent("nothing to update".into()));
+        }
+        let payload = serde_json::to_value(&req)?;
+        let resp = conn
+            .send_request(MessageType::UpdateShortcut, &payload)
+            .await?;
+
+        resp.parse_payload::<UpdateShortcutResponse>()?
+            .ok_or_else(|| GamesError::Agent("empty update shortcut response".into()))
+    }
+
+    /// Starts an installed game through Steam on the agent, e.g. for a smoke
+    /// test right after deploying it.
+    pub async fn launch_game(
+        &self,
+        conn: &dyn AgentConnection,
+        app_id: u32,
+    ) -> Result<LaunchGameResponse, GamesError> {
+        let req = LaunchGameRequest { app_id };
+        let payload = serde_json::to_value(&req)?;
+        let resp = conn.send_request(MessageType::LaunchGame, &payload).await?;
+
+        resp.parse_payload::<LaunchGameResponse>()?
+            .ok_or_else(|| GamesError::Agent("empty launch game response".into()))
+    }
+
     /// Resolves an artwork source string to (data, content_type).
     async fn resolve_artwork_source(&self, src: &str) -> Result<(Vec<u8>, String), GamesError> {
         if let Some(path) = src.strip_prefix("file://") {
@@ -251,6 +457,18 @@ impl GamesManager {
     }
 }
 
+/// Formats a game folder size for display; "N/A" when the agent didn't
+/// report one (older agents).
+fn format_size(bytes: u64) -> String {
+    const UNITS: &[&str] = &["B", "KB", "MB", "GB", "TB"];
+    if bytes == 0 {
+        return "N/A".into();
+    }
+    let i = ((bytes as f64).log(1024.0).floor() as usize).min(UNITS.len() - 1);
+    let val = bytes as f64 / 1024f64.powi(i as i32);
+    format!("{val:.1} {}", UNITS[i])
+}
+
 /// Detects MIME content type from a file path extension.
 fn detect_content_type(path: &str) -> Option<&'static str> {
     let ext = Path::new(path)
@@ -271,7 +489,7 @@ fn detect_content_type(path: &str) -> Option<&'static str> {
 #[cfg(test)]
 mod tests {
     use super::*;
-    use capydeploy_protocol::messages::SteamUser;
+    use capydeploy_protocol::messages::{GameDeleteResult, SteamUser};
     use capydeploy_protocol::types::ShortcutInfo;
     use std::sync::Mutex;
 
@@ -377,6 +595,16 @@ mod tests {
         Message::new("d1", MessageType::OperationResult, Some(&resp)).unwrap()
     }
 
+    fn make_rename_response(old_app_id: u32, app_id: u32, name: &str) -> Message {
+        let resp = RenameGameResponse {
+            old_app_id,
+            app_id,
+            name: name.into(),
+            artwork_migrated: 2,
+        };
+        Message::new("r1", MessageType::OperationResult, Some(&resp)).unwrap()
+    }
+
     fn make_log_wrapper_response(app_id: u32, enabled: bool) -> Message {
         let resp = SetGameLogWrapperResponse { app_id, enabled };
         Message::new("lw1", MessageType::SetGameLogWrapper, Some(&resp)).unwrap()
@@ -403,6 +631,9 @@ mod tests {
                 launch_options: String::new(),
                 tags: vec![],
                 last_played: 0,
+                needs_compat_tool: false,
+                size_bytes: 0,
+                broken: false,
             },
             ShortcutInfo {
                 app_id: 200,
@@ -412,6 +643,9 @@ mod tests {
                 launch_options: String::new(),
                 tags: vec![],
                 last_played: 0,
+                needs_compat_tool: true,
+                size_bytes: 2048,
+                broken: true,
             },
         ];
 
@@ -433,6 +667,11 @@ mod tests {
         assert_eq!(games[0].app_id, 100);
         assert_eq!(games[1].name, "Game B");
         assert_eq!(games[1].app_id, 200);
+        assert!(!games[0].needs_compat_tool);
+        assert!(games[1].needs_compat_tool);
+        assert_eq!(games[1].size, "2.0 KB");
+        assert_eq!(games[1].size_bytes, 2048);
+        assert!(games[1].broken && !games[0].broken);
         assert_eq!(conn.request_count(), 2);
     }
 
@@ -478,6 +717,181 @@ mod tests {
         assert!(result.is_err());
     }
 
+    #[tokio::test]
+    async fn delete_games_sends_all_app_ids_in_one_request() {
+        let resp = DeleteGamesBatchResponse {
+            results: vec![
+                GameDeleteResult {
+                    app_id: 1,
+                    success: true,
+                    game_name: "Celeste".into(),
+                    error: String::new(),
+                },
+                GameDeleteResult {
+                    app_id: 2,
+                    success: false,
+                    game_name: String::new(),
+                    error: "game not found".into(),
+                },
+            ],
+            steam_restarted: true,
+        };
+        let msg = Message::new("b1", MessageType::DeleteGamesBatchResponse, Some(&resp)).unwrap();
+        let conn = MockConn::new("agent-1", vec![msg]);
+
+        let mgr = GamesManager::new(reqwest::Client::new());
+        let got = mgr.delete_games(&conn, &[1, 2], true).await.unwrap();
+
+        assert_eq!(got, resp);
+        assert_eq!(conn.request_count(), 1);
+        let payload = conn.last_request_payload();
+        assert_eq!(payload["appIds"], serde_json::json!([1, 2]));
+        assert_eq!(payload["restartSteam"], true);
+    }
+
+    // -----------------------------------------------------------------------
+    // wipe_games
+    // -----------------------------------------------------------------------
+
+    fn installed(name: &str, app_id: u32, size_bytes: u64) -> InstalledGame {
+        InstalledGame {
+            name: name.into(),
+            path: format!("/games/{name}"),
+            size: String::new(),
+            app_id,
+            needs_compat_tool: false,
+            tags: vec![],
+            size_bytes,
+            broken: false,
+        }
+    }
+
+    fn make_restart_response(success: bool) -> Message {
+        let resp = RestartSteamResponse {
+            success,
+            message: String::new(),
+        };
+        Message::new("rs1", MessageType::SteamResponse, Some(&resp)).unwrap()
+    }
+
+    #[tokio::test]
+    async fn wipe_removes_only_deployed_games_and_restarts_once() {
+        let games = vec![
+            installed("Celeste", 100, 2048),
+            installed("Retroarch", 200, 4096),
+            installed("Hades", 300, 1024),
+        ];
+        let plan = WipePlan::new(games, &std::collections::HashSet::from([100, 300]));
+
+        let conn = MockConn::new(
+            "agent-1",
+            vec![
+                make_delete_response("success", "Celeste"),
+                make_delete_response("success", "Hades"),
+                make_restart_response(true),
+            ],
+        );
+        let mgr = GamesManager::new(reqwest::Client::new());
+        let report = mgr.wipe_games(&conn, &plan).await.unwrap();
+
+        assert_eq!(report.removed, ["Celeste", "Hades"]);
+        assert!(report.failed.is_empty());
+        assert_eq!(report.preserved, 1);
+        assert_eq!(report.reclaimed_bytes, 3072);
+        assert!(report.steam_restarted);
+
+        let requests = conn.requests.lock().unwrap();
+        let sent: Vec<_> = requests
+            .iter()
+            .map(|(t, p)| (t.as_str(), p["appId"].clone()))
+            .collect();
+        assert_eq!(
+            sent,
+            [
+                ("DeleteGame", serde_json::json!(100)),
+                ("DeleteGame", serde_json::json!(300)),
+                ("RestartSteam", serde_json::Value::Null),
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn wipe_reports_failures_and_skips_restart_when_nothing_removed() {
+        let plan = WipePlan::new(
+            vec![installed("Celeste", 100, 2048)],
+            &std::collections::HashSet::from([100]),
+        );
+        let conn = MockConn::new("agent-1", vec![]);
+        let mgr = GamesManager::new(reqwest::Client::new());
+        let report = mgr.wipe_games(&conn, &plan).await.unwrap();
+
+        assert!(report.removed.is_empty());
+        assert_eq!(report.failed.len(), 1);
+        assert_eq!(report.failed[0].app_id, 100);
+        assert_eq!(report.reclaimed_bytes, 0);
+        assert!(!report.steam_restarted);
+        // Only the failed delete; no restart.
+        assert_eq!(conn.request_count(), 1);
+    }
+
+    // -----------------------------------------------------------------------
+    // rename_game
+    // -----------------------------------------------------------------------
+
+    #[tokio::test]
+    async fn rename_game_sends_request_and_returns_new_app_id() {
+        let conn = MockConn::new("agent-1", vec![make_rename_response(42, 43, "New Name")]);
+
+        let mgr = GamesManager::new(reqwest::Client::new());
+        let resp = mgr.rename_game(&conn, 42, "New Name").await.unwrap();
+
+        assert_eq!(resp.old_app_id, 42);
+        assert_eq!(resp.app_id, 43);
+        assert_eq!(resp.artwork_migrated, 2);
+
+        let (msg_type, payload) = conn.requests.lock().unwrap().last().cloned().unwrap();
+        assert_eq!(msg_type, "RenameGame");
+        assert_eq!(payload["appId"], 42);
+        assert_eq!(payload["newName"], "New Name");
+    }
+
+    #[tokio::test]
+    async fn rename_game_agent_error_propagates() {
+        let conn = MockConn::new("agent-1", vec![]);
+
+        let mgr = GamesManager::new(reqwest::Client::new());
+        assert!(mgr.rename_game(&conn, 42, "X").await.is_err());
+    }
+
+    // -----------------------------------------------------------------------
+    // get_artwork
+    // -----------------------------------------------------------------------
+
+    #[tokio::test]
+    async fn get_artwork_returns_images_and_missing_types() {
+        let resp = AppliedArtworkResponse {
+            app_id: 42,
+            images: vec![capydeploy_protocol::messages::AppliedArtwork {
+                artwork_type: "hero".into(),
+                content_type: "image/png".into(),
+                size: 3,
+                data: b"png".to_vec(),
+                too_large: false,
+            }],
+            missing: vec!["logo".into()],
+        };
+        let msg = Message::new("a1", MessageType::AppliedArtworkResponse, Some(&resp)).unwrap();
+        let conn = MockConn::new("agent-1", vec![msg]);
+
+        let mgr = GamesManager::new(reqwest::Client::new());
+        let got = mgr.get_artwork(&conn, 42).await.unwrap();
+        assert_eq!(got, resp);
+
+        let (msg_type, payload) = conn.requests.lock().unwrap().last().cloned().unwrap();
+        assert_eq!(msg_type, "GetArtwork");
+        assert_eq!(payload, serde_json::json!({ "appId": 42 }));
+    }
+
     // -----------------------------------------------------------------------
     // update_game_artwork
     // -----------------------------------------------------------------------
@@ -560,6 +974,68 @@ mod tests {
         assert!(!resp.enabled);
     }
 
+    // -----------------------------------------------------------------------
+    // update_shortcut
+    // -----------------------------------------------------------------------
+
+    #[tokio::test]
+    async fn update_shortcut_sends_only_changed_fields() {
+        let resp = UpdateShortcutResponse {
+            old_app_id: 42,
+            app_id: 43,
+            warnings: Vec::new(),
+        };
+        let msg = Message::new("us1", MessageType::OperationResult, Some(&resp)).unwrap();
+        let conn = MockConn::new("agent-1", vec![msg]);
+
+        let mgr = GamesManager::new(reqwest::Client::new());
+        let update = ShortcutUpdate {
+            name: Some("New Name".into()),
+            tags: Some(vec!["RPG".into()]),
+            ..Default::default()
+        };
+        let resp = mgr.update_shortcut(&conn, 7, 42, &update).await.unwrap();
+        assert_eq!(resp.app_id, 43);
+
+        let payload = conn.last_request_payload();
+        assert_eq!(payload["userId"], 7);
+        assert_eq!(payload["name"], "New Name");
+        assert_eq!(payload["tags"][0], "RPG");
+        assert!(payload.get("launchOptions").is_none());
+    }
+
+    #[tokio::test]
+    async fn update_shortcut_without_changes_is_rejected() {
+        let conn = MockConn::new("agent-1", vec![]);
+        let mgr = GamesManager::new(reqwest::Client::new());
+        let err = mgr
+            .update_shortcut(&conn, 7, 42, &ShortcutUpdate::default())
+            .await
+            .unwrap_err();
+        assert!(matches!(err, GamesError::Agent(_)));
+        assert_eq!(conn.request_count(), 0);
+    }
+
+    // -----------------------------------------------------------------------
+    // launch_game
+    // -----------------------------------------------------------------------
+
+    #[tokio::test]
+    async fn launch_game_sends_app_id() {
+        let resp = LaunchGameResponse {
+            app_id: 42,
+            launched: true,
+        };
+        let msg = Message::new("l1", MessageType::LaunchGameResponse, Some(&resp)).unwrap();
+        let conn = MockConn::new("agent-1", vec![msg]);
+
+        let mgr = GamesManager::new(reqwest::Client::new());
+        let resp = mgr.launch_game(&conn, 42).await.unwrap();
+
+        assert!(resp.launched);
+        assert_eq!(conn.last_request_payload()["appId"], 42);
+    }
+
     // -----------------------------------------------------------------------
     // detect_content_type
     // -----------------------------------------------------------------------
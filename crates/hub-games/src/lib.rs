@@ -8,15 +8,24 @@
 //! # Operations
 //!
 //! - **List** — get all installed (non-Steam) games via shortcuts
+//! - **Filter** — narrow the list by tag, size, or broken status
 //! - **Delete** — remove a game (agent handles files + shortcut + Steam restart)
+//! - **Wipe** — remove every game this Hub deployed, with a single Steam restart
+//! - **Rename** — rename a game, migrating artwork to the new AppID
+//! - **Update** — change a shortcut's name, launch options, tags or start dir
+//!   in place
 //! - **Artwork** — update artwork from local files or remote URLs
 //! - **Log wrapper** — enable/disable game log wrapper
 
 pub mod error;
+pub mod filter;
 pub mod games;
 pub mod types;
+pub mod wipe;
 
 // Re-export primary types for convenience.
 pub use error::GamesError;
+pub use filter::GamesFilter;
 pub use games::{AgentConnection, GamesManager};
-pub use types::{ArtworkUpdate, InstalledGame};
+pub use types::{ArtworkUpdate, InstalledGame, ShortcutUpdate};
+pub use wipe::{WipeFailure, WipePlan, WipeReport};
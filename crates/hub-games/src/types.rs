@@ -10,6 +10,21 @@ pub struct InstalledGame {
     pub path: String,
     pub size: String,
     pub app_id: u32,
+    /// Windows game on a Linux agent without a compat tool (e.g. Proton).
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub needs_compat_tool: bool,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub tags: Vec<String>,
+    /// Size of the game folder in bytes; 0 when the agent didn't report it.
+    #[serde(default)]
+    pub size_bytes: u64,
+    /// The shortcut's executable is missing on the agent.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub broken: bool,
+}
+
+fn is_false(v: &bool) -> bool {
+    !v
 }
 
 /// Artwork URLs to update for an installed game.
@@ -26,3 +41,17 @@ pub struct ArtworkUpdate {
     pub logo: String,
     pub icon: String,
 }
+
+/// Changes to an installed game's shortcut; `None` leaves a field as is.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ShortcutUpdate {
+    #[serde(default)]
+    pub name: Option<String>,
+    #[serde(default)]
+    pub launch_options: Option<String>,
+    #[serde(default)]
+    pub tags: Option<Vec<String>>,
+    #[serde(default)]
+    pub start_dir: Option<String>,
+}
@@ -0,0 +1,144 @@
+//! Bulk removal of the games this Hub deployed.
+//!
+//! The Hub's deploy history records the AppID of every shortcut it created
+//! on an agent. A wipe is planned against that registry only, so Steam's own
+//! games and shortcuts added by the user or other tools are never selected,
+//! whatever their name or location.
+
+use std::collections::HashSet;
+
+use serde::{Deserialize, Serialize};
+
+use crate::types::InstalledGame;
+
+/// Games selected for a wipe, for the user to confirm.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WipePlan {
+    /// Games the Hub deployed, in the agent's order.
+    pub games: Vec<InstalledGame>,
+    /// Number of installed games left alone.
+    pub preserved: usize,
+    /// Combined folder size of the selected games, in bytes.
+    pub reclaimable_bytes: u64,
+}
+
+impl WipePlan {
+    /// Selects the installed games whose AppID is in `deployed`.
+    ///
+    /// AppID 0 (unknown) never matches, so a game the agent couldn't
+    /// identify is always preserved.
+    pub fn new(installed: Vec<InstalledGame>, deployed: &HashSet<u32>) -> Self {
+        let (games, preserved): (Vec<_>, Vec<_>) = installed
+            .into_iter()
+            .partition(|g| g.app_id != 0 && deployed.contains(&g.app_id));
+        let reclaimable_bytes = games.iter().map(|g| g.size_bytes).sum();
+        Self {
+            games,
+            preserved: preserved.len(),
+            reclaimable_bytes,
+        }
+    }
+
+    /// Keeps only the games whose AppID the user confirmed; the others
+    /// count as preserved. A game deployed after the plan was shown is
+    /// thus never removed.
+    pub fn retain_confirmed(&mut self, confirmed: &HashSet<u32>) {
+        let (games, dropped): (Vec<_>, Vec<_>) = std::mem::take(&mut self.games)
+            .into_iter()
+            .partition(|g| confirmed.contains(&g.app_id));
+        self.games = games;
+        self.preserved += dropped.len();
+        self.reclaimable_bytes = self.games.iter().map(|g| g.size_bytes).sum();
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.games.is_empty()
+    }
+}
+
+/// A game the agent failed to delete.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WipeFailure {
+    pub app_id: u32,
+    pub name: String,
+    pub error: String,
+}
+
+/// Outcome of a wipe.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WipeReport {
+    /// Names of the games removed.
+    pub removed: Vec<String>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub failed: Vec<WipeFailure>,
+    /// Number of installed games left alone.
+    pub preserved: usize,
+    /// Combined folder size of the removed games, in bytes.
+    pub reclaimed_bytes: u64,
+    /// Steam was restarted once after the deletions.
+    pub steam_restarted: bool,
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const GB: u64 = 1024 * 1024 * 1024;
+
+    fn game(name: &str, app_id: u32, size_bytes: u64) -> InstalledGame {
+        InstalledGame {
+            name: name.into(),
+            path: format!("/games/{name}"),
+            size: String::new(),
+            app_id,
+            needs_compat_tool: false,
+            tags: vec![],
+            size_bytes,
+            broken: false,
+        }
+    }
+
+    #[test]
+    fn selects_only_deployed_games() {
+        let installed = vec![
+            game("Celeste", 100, 2 * GB),
+            game("Emulator", 200, GB),
+            game("Hades", 300, 10 * GB),
+            game("Unknown", 0, GB),
+        ];
+        let deployed = HashSet::from([100, 300, 0, 999]);
+
+        let plan = WipePlan::new(installed, &deployed);
+        let names: Vec<_> = plan.games.iter().map(|g| g.name.as_str()).collect();
+        assert_eq!(names, ["Celeste", "Hades"]);
+        assert_eq!(plan.preserved, 2);
+        assert_eq!(plan.reclaimable_bytes, 12 * GB);
+    }
+
+    #[test]
+    fn empty_registry_selects_nothing() {
+        let plan = WipePlan::new(vec![game("Celeste", 100, GB)], &HashSet::new());
+        assert!(plan.is_empty());
+        assert_eq!(plan.preserved, 1);
+        assert_eq!(plan.reclaimable_bytes, 0);
+    }
+
+    #[test]
+    fn only_confirmed_games_are_kept() {
+        let installed = vec![
+            game("Celeste", 100, 2 * GB),
+            game("Hades", 300, 10 * GB),
+            game("Deployed later", 400, GB),
+        ];
+        let mut plan = WipePlan::new(installed, &HashSet::from([100, 300, 400]));
+
+        plan.retain_confirmed(&HashSet::from([100, 300, 999]));
+        let names: Vec<_> = plan.games.iter().map(|g| g.name.as_str()).collect();
+        assert_eq!(names, ["Celeste", "Hades"]);
+        assert_eq!(plan.preserved, 1);
+        assert_eq!(plan.reclaimable_bytes, 12 * GB);
+    }
+}
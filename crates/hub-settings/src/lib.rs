@@ -1,3 +1,5 @@
+pub mod persist;
 pub mod toast;
 
+pub use persist::{backup_corrupt, write_atomic};
 pub use toast::{Toast, ToastQueue, ToastType};
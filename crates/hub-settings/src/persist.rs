@@ -0,0 +1,110 @@
+//! Crash-safe storage for the Hub's JSON files.
+//!
+//! Files are replaced with write-temp-then-rename, so an interrupted save
+//! leaves either the old or the new contents, never a truncated mix. A file
+//! that still fails to parse is moved aside with [`backup_corrupt`] so the
+//! caller can start over with defaults without destroying the evidence.
+
+use std::ffi::OsString;
+use std::io::{self, Write};
+use std::path::{Path, PathBuf};
+use std::time::{SystemTime, UNIX_EPOCH};
+
+/// Replaces `path` with `data` atomically.
+///
+/// The data is written and synced to a temporary file next to `path`, then
+/// renamed over it. Parent directories are created as needed.
+pub fn write_atomic(path: &Path, data: &[u8]) -> io::Result<()> {
+    if let Some(parent) = path.parent().filter(|p| !p.as_os_str().is_empty()) {
+        std::fs::create_dir_all(parent)?;
+    }
+    let tmp = sibling(path, ".tmp");
+    let result = (|| {
+        let mut file = std::fs::File::create(&tmp)?;
+        file.write_all(data)?;
+        file.sync_all()?;
+        std::fs::rename(&tmp, path)
+    })();
+    if result.is_err() {
+        let _ = std::fs::remove_file(&tmp);
+    }
+    result
+}
+
+/// Moves a corrupted file aside to `<name>.corrupt-<unix seconds>` and
+/// returns the backup path.
+pub fn backup_corrupt(path: &Path) -> io::Result<PathBuf> {
+    let secs = SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_secs())
+        .unwrap_or_default();
+    let mut backup = sibling(path, &format!(".corrupt-{secs}"));
+    let mut n = 1;
+    while backup.exists() {
+        backup = sibling(path, &format!(".corrupt-{secs}-{n}"));
+        n += 1;
+    }
+    std::fs::rename(path, &backup)?;
+    Ok(backup)
+}
+
+/// `path` with `suffix` appended to its file name.
+fn sibling(path: &Path, suffix: &str) -> PathBuf {
+    let mut name = path.file_name().map(OsString::from).unwrap_or_default();
+    name.push(suffix);
+    path.with_file_name(name)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn write_atomic_replaces_contents() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("nested").join("config.json");
+
+        write_atomic(&path, b"{\"a\":1}").unwrap();
+        write_atomic(&path, b"{\"a\":2}").unwrap();
+        assert_eq!(std::fs::read_to_string(&path).unwrap(), "{\"a\":2}");
+
+        // No temporary file is left behind.
+        let names: Vec<_> = std::fs::read_dir(path.parent().unwrap())
+            .unwrap()
+            .map(|e| e.unwrap().file_name())
+            .collect();
+        assert_eq!(names, ["config.json"]);
+    }
+
+    #[test]
+    fn failed_write_keeps_previous_file() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("config.json");
+        write_atomic(&path, b"old").unwrap();
+
+        // A directory squatting on the temp name makes the write fail
+        // before the rename.
+        std::fs::create_dir(sibling(&path, ".tmp")).unwrap();
+        assert!(write_atomic(&path, b"new").is_err());
+        assert_eq!(std::fs::read_to_string(&path).unwrap(), "old");
+    }
+
+    #[test]
+    fn backup_moves_file_aside() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("tokens.json");
+
+        std::fs::write(&path, "{broken").unwrap();
+        let first = backup_corrupt(&path).unwrap();
+        assert!(!path.exists());
+        assert_eq!(std::fs::read_to_string(&first).unwrap(), "{broken");
+        let name = first.file_name().unwrap().to_string_lossy().into_owned();
+        assert!(name.starts_with("tokens.json.corrupt-"), "{name}");
+
+        // A second backup in the same second doesn't clobber the first.
+        std::fs::write(&path, "{again").unwrap();
+        let second = backup_corrupt(&path).unwrap();
+        assert_ne!(first, second);
+        assert_eq!(std::fs::read_to_string(&first).unwrap(), "{broken");
+    }
+}
@@ -19,6 +19,19 @@ pub const WS_PING_PERIOD: Duration = Duration::from_secs(5);
 /// Maximum message size in bytes (50 MB).
 pub const WS_MAX_MESSAGE_SIZE: usize = 50 * 1024 * 1024;
 
+/// Smallest binary frame limit an agent may advertise (2 MB).
+pub const WS_MIN_BINARY_FRAME_SIZE: usize = 2 * 1024 * 1024;
+
+/// Binary frame limit for agents on low-memory devices (8 MB).
+pub const WS_LOW_MEMORY_BINARY_FRAME_SIZE: usize = 8 * 1024 * 1024;
+
+/// Devices with less total RAM than this get
+/// [`WS_LOW_MEMORY_BINARY_FRAME_SIZE`] by default.
+pub const LOW_MEMORY_THRESHOLD_BYTES: u64 = 4 * 1024 * 1024 * 1024;
+
+/// Room kept in each binary frame for the length prefix and JSON header.
+pub const WS_BINARY_HEADER_RESERVE: usize = 64 * 1024;
+
 /// Size for binary chunks (4 MB).
 pub const WS_CHUNK_SIZE: usize = 4 * 1024 * 1024;
 
@@ -55,30 +68,74 @@ pub enum MessageType {
     SetConsoleLogFilter,
     #[serde(rename = "set_console_log_enabled")]
     SetConsoleLogEnabled,
+    #[serde(rename = "set_verbose")]
+    SetVerbose,
+    #[serde(rename = "subscribe_logs")]
+    SubscribeLogs,
     #[serde(rename = "set_game_log_wrapper")]
     SetGameLogWrapper,
     #[serde(rename = "ping")]
     Ping,
     #[serde(rename = "get_info")]
     GetInfo,
+    #[serde(rename = "get_info_lite")]
+    GetInfoLite,
     #[serde(rename = "get_config")]
     GetConfig,
+    #[serde(rename = "set_install_path")]
+    SetInstallPath,
+    #[serde(rename = "self_test")]
+    SelfTest,
+    #[serde(rename = "can_deploy")]
+    CanDeploy,
+    #[serde(rename = "browse_directory")]
+    BrowseDirectory,
+    #[serde(rename = "get_disk_usage")]
+    GetDiskUsage,
     #[serde(rename = "get_steam_users")]
     GetSteamUsers,
+    #[serde(rename = "get_steam_libraries")]
+    GetSteamLibraries,
+    #[serde(rename = "get_steam_status")]
+    GetSteamStatus,
+    #[serde(rename = "list_connected_hubs")]
+    ListConnectedHubs,
     #[serde(rename = "list_shortcuts")]
     ListShortcuts,
+    #[serde(rename = "export_shortcut")]
+    ExportShortcut,
     #[serde(rename = "create_shortcut")]
     CreateShortcut,
+    #[serde(rename = "create_shortcuts_batch")]
+    CreateShortcutsBatch,
+    #[serde(rename = "update_shortcut")]
+    UpdateShortcut,
     #[serde(rename = "delete_shortcut")]
     DeleteShortcut,
     #[serde(rename = "delete_game")]
     DeleteGame,
+    #[serde(rename = "delete_games_batch")]
+    DeleteGamesBatch,
+    #[serde(rename = "rename_game")]
+    RenameGame,
+    #[serde(rename = "launch_game")]
+    LaunchGame,
     #[serde(rename = "apply_artwork")]
     ApplyArtwork,
     #[serde(rename = "send_artwork_image")]
     SendArtworkImage,
+    /// Binary frame carrying several artwork images for one app; answered
+    /// with an `artwork_response`.
+    #[serde(rename = "apply_artwork_batch")]
+    ApplyArtworkBatch,
+    /// Reads back the artwork files on a shortcut.
+    #[serde(rename = "get_artwork")]
+    GetArtwork,
     #[serde(rename = "restart_steam")]
     RestartSteam,
+    /// Asks whether the Steam CEF debugger is reachable.
+    #[serde(rename = "check_cef")]
+    CheckCef,
     #[serde(rename = "init_upload")]
     InitUpload,
     #[serde(rename = "upload_chunk")]
@@ -87,28 +144,73 @@ pub enum MessageType {
     CompleteUpload,
     #[serde(rename = "cancel_upload")]
     CancelUpload,
+    #[serde(rename = "get_upload_status")]
+    GetUploadStatus,
+    #[serde(rename = "list_uploads")]
+    ListUploads,
+    #[serde(rename = "prune_uploads")]
+    PruneUploads,
+    /// Holds an upload session: chunks are refused until it resumes.
+    #[serde(rename = "pause_upload")]
+    PauseUpload,
+    #[serde(rename = "resume_upload")]
+    ResumeUpload,
 
     // Responses from Agent to Hub
     #[serde(rename = "pong")]
     Pong,
     #[serde(rename = "info_response")]
     InfoResponse,
+    #[serde(rename = "info_lite_response")]
+    InfoLiteResponse,
     #[serde(rename = "config_response")]
     ConfigResponse,
+    #[serde(rename = "self_test_response")]
+    SelfTestResponse,
+    #[serde(rename = "can_deploy_response")]
+    CanDeployResponse,
+    #[serde(rename = "browse_directory_response")]
+    BrowseDirectoryResponse,
+    #[serde(rename = "disk_usage_response")]
+    DiskUsageResponse,
     #[serde(rename = "steam_users_response")]
     SteamUsersResponse,
+    #[serde(rename = "steam_libraries_response")]
+    SteamLibrariesResponse,
+    #[serde(rename = "steam_status_response")]
+    SteamStatusResponse,
+    #[serde(rename = "connected_hubs_response")]
+    ConnectedHubsResponse,
     #[serde(rename = "shortcuts_response")]
     ShortcutsResponse,
+    #[serde(rename = "export_shortcut_response")]
+    ExportShortcutResponse,
+    #[serde(rename = "launch_game_response")]
+    LaunchGameResponse,
+    #[serde(rename = "create_shortcuts_batch_response")]
+    CreateShortcutsBatchResponse,
+    #[serde(rename = "delete_games_batch_response")]
+    DeleteGamesBatchResponse,
+    #[serde(rename = "subscribe_logs_response")]
+    SubscribeLogsResponse,
     #[serde(rename = "artwork_response")]
     ArtworkResponse,
     #[serde(rename = "artwork_image_response")]
     ArtworkImageResponse,
+    #[serde(rename = "applied_artwork_response")]
+    AppliedArtworkResponse,
     #[serde(rename = "steam_response")]
     SteamResponse,
+    #[serde(rename = "cef_status_response")]
+    CefStatusResponse,
     #[serde(rename = "upload_init_response")]
     UploadInitResponse,
     #[serde(rename = "upload_chunk_response")]
     UploadChunkResponse,
+    #[serde(rename = "uploads_response")]
+    UploadsResponse,
+    #[serde(rename = "prune_uploads_response")]
+    PruneUploadsResponse,
     #[serde(rename = "operation_result")]
     OperationResult,
     #[serde(rename = "error")]
@@ -153,6 +255,10 @@ pub enum MessageType {
     ConsoleLogData,
     #[serde(rename = "game_log_wrapper_status")]
     GameLogWrapperStatus,
+    #[serde(rename = "config_changed")]
+    ConfigChanged,
+    #[serde(rename = "log_line")]
+    LogLine,
 
     /// Forward compatibility: unknown message types deserialize here.
     #[serde(other)]
@@ -166,6 +272,128 @@ pub enum MessageType {
 /// Capability: agent supports remote file browsing.
 pub const CAPABILITY_FILE_BROWSER: &str = "file_browser";
 
+/// Capability: uploads may use the TCP data channel instead of WebSocket
+/// chunks. Same value as `capydeploy_data_channel::CAPABILITY_TCP_DATA_CHANNEL`.
+pub const CAPABILITY_TCP_DATA_CHANNEL: &str = "tcp_data_channel";
+
+/// Capability: artwork images larger than a frame may be sent in
+/// checksummed chunks that the agent reassembles.
+pub const CAPABILITY_CHUNKED_ARTWORK: &str = "chunked_artwork";
+
+/// Capability: agent accepts all artwork for an app in one
+/// `apply_artwork_batch` binary frame.
+pub const CAPABILITY_ARTWORK_BATCH: &str = "artwork_batch";
+
+/// Largest artwork file returned by `get_artwork` (4 MiB); bigger ones,
+/// typically animated, are listed with their size but no data.
+pub const ARTWORK_PREVIEW_MAX_SIZE: u64 = 4 * 1024 * 1024;
+
+/// Capability: agent creates several shortcuts in one
+/// `create_shortcuts_batch` request.
+pub const CAPABILITY_SHORTCUT_BATCH: &str = "shortcut_batch";
+
+/// Capability: agent deletes several games in one `delete_games_batch`
+/// request.
+pub const CAPABILITY_DELETE_BATCH: &str = "delete_batch";
+
+/// Capability: agent reports the free space at an install target via
+/// `get_disk_usage`.
+pub const CAPABILITY_DISK_USAGE: &str = "disk_usage";
+
+/// Capability: agent streams its own log to a Hub that sent
+/// `subscribe_logs`.
+pub const CAPABILITY_AGENT_LOGS: &str = "agent_logs";
+
+/// Capability: agent holds an upload session on `pause_upload` until
+/// `resume_upload`.
+pub const CAPABILITY_PAUSE_UPLOAD: &str = "pause_upload";
+
+impl MessageType {
+    /// Capability both sides must have negotiated before this request may
+    /// be sent, or `None` for requests every agent understands.
+    pub fn required_capability(&self) -> Option<&'static str> {
+        match self {
+            Self::FsList
+            | Self::FsMkdir
+            | Self::FsRename
+            | Self::FsCopy
+            | Self::FsDelete
+            | Self::FsDownload
+            | Self::FsUpload => Some(CAPABILITY_FILE_BROWSER),
+            Self::ApplyArtworkBatch => Some(CAPABILITY_ARTWORK_BATCH),
+            Self::CreateShortcutsBatch => Some(CAPABILITY_SHORTCUT_BATCH),
+            Self::DeleteGamesBatch => Some(CAPABILITY_DELETE_BATCH),
+            Self::GetDiskUsage => Some(CAPABILITY_DISK_USAGE),
+            Self::SubscribeLogs => Some(CAPABILITY_AGENT_LOGS),
+            Self::PauseUpload | Self::ResumeUpload => Some(CAPABILITY_PAUSE_UPLOAD),
+            _ => None,
+        }
+    }
+}
+
+// ---------------------------------------------------------------------------
+// Upload limits
+// ---------------------------------------------------------------------------
+
+/// Concurrent uploads an agent accepts unless it advertises otherwise.
+/// Uploads share Steam and the disk, so one at a time is the safe default.
+pub const DEFAULT_MAX_CONCURRENT_UPLOADS: u32 = 1;
+
+/// Idle time after which `prune_uploads` discards an upload session when
+/// the request doesn't set one, in seconds.
+pub const DEFAULT_UPLOAD_PRUNE_IDLE_SECS: u64 = 600;
+
+/// Post-install verification: the file isn't in the game folder.
+pub const FILE_MISMATCH_MISSING: &str = "missing";
+/// Post-install verification: the file has a different size.
+pub const FILE_MISMATCH_SIZE: &str = "size";
+/// Post-install verification: the file's SHA-256 differs.
+pub const FILE_MISMATCH_CHECKSUM: &str = "checksum";
+
+// ---------------------------------------------------------------------------
+// Deploy preflight blockers (`can_deploy_response` reason codes)
+// ---------------------------------------------------------------------------
+
+/// Not enough free space in the install directory.
+pub const DEPLOY_BLOCKER_DISK_FULL: &str = "disk_full";
+/// The install directory cannot be created or written to.
+pub const DEPLOY_BLOCKER_PATH_NOT_WRITABLE: &str = "path_not_writable";
+/// No Steam installation (or no Steam user) was found.
+pub const DEPLOY_BLOCKER_STEAM_NOT_INSTALLED: &str = "steam_not_installed";
+/// The agent is not accepting connections.
+pub const DEPLOY_BLOCKER_NOT_ACCEPTING: &str = "not_accepting_connections";
+/// Steam's CEF debugger is unreachable, so shortcuts can't be created.
+pub const DEPLOY_BLOCKER_CEF_NOT_READY: &str = "cef_not_ready";
+/// The requested install location is malformed or outside the allowed roots.
+pub const DEPLOY_BLOCKER_INVALID_PATH: &str = "invalid_path";
+/// The agent is already running as many uploads as it accepts.
+pub const DEPLOY_BLOCKER_UPLOAD_LIMIT: &str = "upload_limit";
+
+// ---------------------------------------------------------------------------
+// Steam login state (`AgentInfo.steamLoginState`)
+// ---------------------------------------------------------------------------
+
+/// A Steam account is signed in with a network connection.
+pub const STEAM_LOGIN_LOGGED_IN: &str = "logged_in";
+/// A Steam account is signed in but Steam is in offline mode.
+pub const STEAM_LOGIN_OFFLINE: &str = "offline";
+/// No account is signed in (or Steam isn't running).
+pub const STEAM_LOGIN_LOGGED_OUT: &str = "logged_out";
+/// The login state couldn't be read.
+pub const STEAM_LOGIN_UNKNOWN: &str = "unknown";
+
+// ---------------------------------------------------------------------------
+// Agent server start reason (`AgentInfo.startReason`)
+// ---------------------------------------------------------------------------
+
+/// The server started with the agent. Agents don't restart their server
+/// yet, so this is the only reason reported today.
+pub const AGENT_START_INITIAL: &str = "initial";
+
+/// Folder inside a Steam library that games deployed there (via
+/// `UploadConfig.libraryPath`) are installed under.
+pub const STEAM_LIBRARY_GAMES_DIR: &str = "Games";
+
 // ---------------------------------------------------------------------------
 // Filesystem limits
 // ---------------------------------------------------------------------------
@@ -193,6 +421,11 @@ pub const WS_ERR_CODE_NOT_ACCEPTED: i32 = 406;
 pub const WS_ERR_CODE_CONFLICT: i32 = 409;
 pub const WS_ERR_CODE_INTERNAL: i32 = 500;
 pub const WS_ERR_CODE_NOT_IMPLEMENTED: i32 = 501;
+/// Chunk refused because its upload is paused; send it again once the
+/// upload resumes.
+pub const WS_ERR_CODE_UPLOAD_PAUSED: i32 = 423;
+/// Steam isn't running or can't take the request in its current state.
+pub const WS_ERR_CODE_UNAVAILABLE: i32 = 503;
 
 // ---------------------------------------------------------------------------
 // Protocol versioning
@@ -247,6 +480,34 @@ pub fn check_protocol_compatibility(peer_version: u32) -> ProtocolCompatibility
     ProtocolCompatibility::Compatible
 }
 
+/// Resolves the binary frame limit an agent advertised in
+/// `AgentStatusResponse`. `0` (older agents) means [`WS_MAX_MESSAGE_SIZE`];
+/// other values are clamped to the supported range.
+pub fn binary_frame_limit(advertised: u64) -> usize {
+    if advertised == 0 {
+        return WS_MAX_MESSAGE_SIZE;
+    }
+    usize::try_from(advertised)
+        .unwrap_or(usize::MAX)
+        .clamp(WS_MIN_BINARY_FRAME_SIZE, WS_MAX_MESSAGE_SIZE)
+}
+
+/// Default binary frame limit for a device with `total_memory` bytes of
+/// RAM (`0` = unknown).
+pub fn default_binary_frame_limit(total_memory: u64) -> usize {
+    if total_memory > 0 && total_memory < LOW_MEMORY_THRESHOLD_BYTES {
+        WS_LOW_MEMORY_BINARY_FRAME_SIZE
+    } else {
+        WS_MAX_MESSAGE_SIZE
+    }
+}
+
+/// Largest binary payload (file chunk, image) that fits in a frame of
+/// `frame_limit` bytes once the header is added.
+pub fn max_binary_payload(frame_limit: usize) -> usize {
+    frame_limit.saturating_sub(WS_BINARY_HEADER_RESERVE)
+}
+
 // ---------------------------------------------------------------------------
 // Console log levels
 // ---------------------------------------------------------------------------
@@ -281,6 +542,28 @@ pub fn log_level_bit(level: &str) -> u32 {
 mod tests {
     use super::*;
 
+    #[test]
+    fn binary_frame_limit_resolution() {
+        assert_eq!(binary_frame_limit(0), WS_MAX_MESSAGE_SIZE);
+        assert_eq!(binary_frame_limit(16 * 1024 * 1024), 16 * 1024 * 1024);
+        assert_eq!(binary_frame_limit(1024), WS_MIN_BINARY_FRAME_SIZE);
+        assert_eq!(binary_frame_limit(u64::MAX), WS_MAX_MESSAGE_SIZE);
+
+        assert_eq!(
+            default_binary_frame_limit(2 * 1024 * 1024 * 1024),
+            WS_LOW_MEMORY_BINARY_FRAME_SIZE
+        );
+        assert_eq!(
+            default_binary_frame_limit(16 * 1024 * 1024 * 1024),
+            WS_MAX_MESSAGE_SIZE
+        );
+        assert_eq!(default_binary_frame_limit(0), WS_MAX_MESSAGE_SIZE);
+
+        let payload = max_binary_payload(WS_LOW_MEMORY_BINARY_FRAME_SIZE);
+        assert!(payload < WS_LOW_MEMORY_BINARY_FRAME_SIZE);
+        assert!(payload >= WS_CHUNK_SIZE);
+    }
+
     #[test]
     fn message_type_serialization() {
         assert_eq!(
@@ -295,6 +578,30 @@ mod tests {
             serde_json::to_string(&MessageType::UploadProgress).unwrap(),
             "\"upload_progress\""
         );
+        assert_eq!(
+            serde_json::to_string(&MessageType::GetUploadStatus).unwrap(),
+            "\"get_upload_status\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::ListUploads).unwrap(),
+            "\"list_uploads\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::PruneUploadsResponse).unwrap(),
+            "\"prune_uploads_response\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::PauseUpload).unwrap(),
+            "\"pause_upload\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::ResumeUpload).unwrap(),
+            "\"resume_upload\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::ApplyArtworkBatch).unwrap(),
+            "\"apply_artwork_batch\""
+        );
     }
 
     #[test]
@@ -313,6 +620,214 @@ mod tests {
         );
     }
 
+    #[test]
+    fn export_shortcut_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::ExportShortcut).unwrap(),
+            "\"export_shortcut\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::ExportShortcutResponse).unwrap(),
+            "\"export_shortcut_response\""
+        );
+    }
+
+    #[test]
+    fn create_shortcuts_batch_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::CreateShortcutsBatch).unwrap(),
+            "\"create_shortcuts_batch\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::CreateShortcutsBatchResponse).unwrap(),
+            "\"create_shortcuts_batch_response\""
+        );
+    }
+
+    #[test]
+    fn delete_games_batch_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::DeleteGamesBatch).unwrap(),
+            "\"delete_games_batch\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::DeleteGamesBatchResponse).unwrap(),
+            "\"delete_games_batch_response\""
+        );
+    }
+
+    #[test]
+    fn steam_libraries_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::GetSteamLibraries).unwrap(),
+            "\"get_steam_libraries\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::SteamLibrariesResponse).unwrap(),
+            "\"steam_libraries_response\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::GetSteamStatus).unwrap(),
+            "\"get_steam_status\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::SteamStatusResponse).unwrap(),
+            "\"steam_status_response\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::CheckCef).unwrap(),
+            "\"check_cef\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::CefStatusResponse).unwrap(),
+            "\"cef_status_response\""
+        );
+    }
+
+    #[test]
+    fn connected_hubs_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::ListConnectedHubs).unwrap(),
+            "\"list_connected_hubs\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::ConnectedHubsResponse).unwrap(),
+            "\"connected_hubs_response\""
+        );
+    }
+
+    #[test]
+    fn config_changed_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::ConfigChanged).unwrap(),
+            "\"config_changed\""
+        );
+    }
+
+    #[test]
+    fn set_verbose_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::SetVerbose).unwrap(),
+            "\"set_verbose\""
+        );
+    }
+
+    #[test]
+    fn set_install_path_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::SetInstallPath).unwrap(),
+            "\"set_install_path\""
+        );
+    }
+
+    #[test]
+    fn self_test_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::SelfTest).unwrap(),
+            "\"self_test\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::SelfTestResponse).unwrap(),
+            "\"self_test_response\""
+        );
+    }
+
+    #[test]
+    fn can_deploy_message_types() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::CanDeploy).unwrap(),
+            "\"can_deploy\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::CanDeployResponse).unwrap(),
+            "\"can_deploy_response\""
+        );
+    }
+
+    #[test]
+    fn info_lite_message_types() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::GetInfoLite).unwrap(),
+            "\"get_info_lite\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::InfoLiteResponse).unwrap(),
+            "\"info_lite_response\""
+        );
+    }
+
+    #[test]
+    fn browse_directory_message_types() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::BrowseDirectory).unwrap(),
+            "\"browse_directory\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::BrowseDirectoryResponse).unwrap(),
+            "\"browse_directory_response\""
+        );
+    }
+
+    #[test]
+    fn disk_usage_message_types() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::GetDiskUsage).unwrap(),
+            "\"get_disk_usage\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::DiskUsageResponse).unwrap(),
+            "\"disk_usage_response\""
+        );
+    }
+
+    #[test]
+    fn agent_log_message_types() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::SubscribeLogs).unwrap(),
+            "\"subscribe_logs\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::SubscribeLogsResponse).unwrap(),
+            "\"subscribe_logs_response\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::LogLine).unwrap(),
+            "\"log_line\""
+        );
+    }
+
+    #[test]
+    fn get_artwork_message_types() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::GetArtwork).unwrap(),
+            "\"get_artwork\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::AppliedArtworkResponse).unwrap(),
+            "\"applied_artwork_response\""
+        );
+    }
+
+    #[test]
+    fn rename_game_message_type_serialization() {
+        assert_eq!(
+            serde_json::to_string(&MessageType::RenameGame).unwrap(),
+            "\"rename_game\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::UpdateShortcut).unwrap(),
+            "\"update_shortcut\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::LaunchGame).unwrap(),
+            "\"launch_game\""
+        );
+        assert_eq!(
+            serde_json::to_string(&MessageType::LaunchGameResponse).unwrap(),
+            "\"launch_game_response\""
+        );
+    }
+
     #[test]
     fn message_type_deserialization() {
         let mt: MessageType = serde_json::from_str("\"hub_connected\"").unwrap();
@@ -363,4 +878,23 @@ mod tests {
                 if peer_version == PROTOCOL_VERSION + 1
         ));
     }
+
+    #[test]
+    fn capability_gated_requests() {
+        assert_eq!(
+            MessageType::FsDelete.required_capability(),
+            Some(CAPABILITY_FILE_BROWSER)
+        );
+        assert_eq!(
+            MessageType::SubscribeLogs.required_capability(),
+            Some(CAPABILITY_AGENT_LOGS)
+        );
+        assert_eq!(
+            MessageType::ResumeUpload.required_capability(),
+            Some(CAPABILITY_PAUSE_UPLOAD)
+        );
+        assert_eq!(MessageType::GetInfo.required_capability(), None);
+        // Responses are never gated.
+        assert_eq!(MessageType::FsListResponse.required_capability(), None);
+    }
 }
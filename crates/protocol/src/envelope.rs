@@ -82,6 +82,22 @@ impl Message {
     pub fn reply_error(&self, code: i32, message: impl Into<String>) -> Self {
         Message::error(&self.id, code, message)
     }
+
+    /// Creates an error response that also carries `details` as its
+    /// payload, for errors the peer can act on.
+    pub fn reply_error_with<T: Serialize>(
+        &self,
+        code: i32,
+        message: impl Into<String>,
+        details: &T,
+    ) -> Result<Self, serde_json::Error> {
+        let mut reply = Message::new(&self.id, MessageType::Error, Some(details))?;
+        reply.error = Some(WsError {
+            code,
+            message: message.into(),
+        });
+        Ok(reply)
+    }
 }
 
 #[cfg(test)]
@@ -124,6 +140,12 @@ mod tests {
             version: "0.1.0".into(),
             accept_connections: true,
             supported_image_formats: vec![],
+            steam_login_state: String::new(),
+            max_concurrent_uploads: crate::constants::DEFAULT_MAX_CONCURRENT_UPLOADS,
+            verbose: false,
+            protocol_version: 0,
+            started_at: 0,
+            start_reason: String::new(),
         };
         let resp = InfoResponse {
             agent: info.clone(),
@@ -169,4 +191,21 @@ mod tests {
         assert_eq!(reply.id, "req-99");
         assert_eq!(reply.msg_type, MessageType::Error);
     }
+
+    #[test]
+    fn reply_error_with_details() {
+        let original = Message::new::<()>("req-7", MessageType::InitUpload, None).unwrap();
+        let details = serde_json::json!({"appId": 42});
+        let reply = original
+            .reply_error_with(409, "conflict", &details)
+            .unwrap();
+        assert_eq!(reply.id, "req-7");
+        assert_eq!(reply.error.as_ref().unwrap().code, 409);
+
+        let json = serde_json::to_string(&reply).unwrap();
+        let parsed: Message = serde_json::from_str(&json).unwrap();
+        assert_eq!(parsed.error, reply.error);
+        let payload: serde_json::Value = parsed.parse_payload().unwrap().unwrap();
+        assert_eq!(payload, details);
+    }
 }
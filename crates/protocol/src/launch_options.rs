@@ -0,0 +1,290 @@
+//! Launch option checks shared by Hub and Agent.
+//!
+//! Steam hands launch options to a shell, so a stray quote or a misplaced
+//! `%command%` breaks the shortcut without any error at creation time. This
+//! is validation, not sandboxing: it flags the common mistakes so the user
+//! sees them before deploying.
+
+use std::fmt;
+
+/// Steam's placeholder for the game command line.
+pub const COMMAND_TOKEN: &str = "%command%";
+
+/// A likely mistake in a launch option string.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum LaunchOptionIssue {
+    /// A quote character is never closed.
+    UnbalancedQuote(char),
+    /// `%command%` appears more than once.
+    RepeatedCommand,
+    /// `%command%` is inside quotes, so the shell never expands it.
+    QuotedCommand,
+    /// Something that looks like `%command%` but isn't (case, missing `%`).
+    MalformedCommand(String),
+    /// Leading `VAR=value` assignments without `%command%`; Steam passes
+    /// them to the game as arguments instead of setting the environment.
+    EnvWithoutCommand,
+}
+
+impl fmt::Display for LaunchOptionIssue {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Self::UnbalancedQuote(q) => write!(f, "unbalanced {q} quote"),
+            Self::RepeatedCommand => write!(f, "{COMMAND_TOKEN} appears more than once"),
+            Self::QuotedCommand => write!(f, "{COMMAND_TOKEN} is inside quotes"),
+            Self::MalformedCommand(found) => {
+                write!(f, "\"{found}\" looks like a misspelled {COMMAND_TOKEN}")
+            }
+            Self::EnvWithoutCommand => write!(
+                f,
+                "environment variables need {COMMAND_TOKEN} after them, e.g. VAR=1 {COMMAND_TOKEN}"
+            ),
+        }
+    }
+}
+
+/// Returns the likely mistakes in `options`, in the order found.
+pub fn check_launch_options(options: &str) -> Vec<LaunchOptionIssue> {
+    let mut issues = Vec::new();
+    let words = match split_words(options) {
+        Ok(words) => words,
+        Err(quote) => {
+            issues.push(LaunchOptionIssue::UnbalancedQuote(quote));
+            return issues;
+        }
+    };
+
+    let mut commands = 0;
+    for word in &words {
+        if word.quoted && word.text.contains(COMMAND_TOKEN) {
+            issues.push(LaunchOptionIssue::QuotedCommand);
+        }
+        if !word.quoted && word.text == COMMAND_TOKEN {
+            commands += 1;
+        } else if !word.quoted && is_malformed_command(&word.text) {
+            issues.push(LaunchOptionIssue::MalformedCommand(word.text.clone()));
+        }
+    }
+    if commands > 1 {
+        issues.push(LaunchOptionIssue::RepeatedCommand);
+    }
+    // A quoted or misspelled %command% already explains the problem.
+    if commands == 0
+        && issues.is_empty()
+        && words.first().is_some_and(|w| is_env_assignment(&w.text))
+    {
+        issues.push(LaunchOptionIssue::EnvWithoutCommand);
+    }
+    issues
+}
+
+/// Trims and collapses runs of whitespace outside quotes.
+///
+/// Strings with unbalanced quotes are only trimmed.
+pub fn normalize_launch_options(options: &str) -> String {
+    let trimmed = options.trim();
+    if split_words(trimmed).is_err() {
+        return trimmed.to_string();
+    }
+
+    let mut out = String::with_capacity(trimmed.len());
+    let mut quote: Option<char> = None;
+    let mut chars = trimmed.chars().peekable();
+    while let Some(c) = chars.next() {
+        match quote {
+            Some(q) => {
+                out.push(c);
+                if c == '\\' && q == '"' {
+                    if let Some(next) = chars.next() {
+                        out.push(next);
+                    }
+                } else if c == q {
+                    quote = None;
+                }
+            }
+            None if c.is_whitespace() => {
+                while chars.peek().is_some_and(|n| n.is_whitespace()) {
+                    chars.next();
+                }
+                out.push(' ');
+            }
+            None => {
+                if c == '"' || c == '\'' {
+                    quote = Some(c);
+                }
+                out.push(c);
+            }
+        }
+    }
+    out
+}
+
+struct Word {
+    text: String,
+    /// Whether any part of the word was quoted.
+    quoted: bool,
+}
+
+/// Splits like a POSIX shell (quotes and backslashes only). Returns the
+/// unclosed quote character on error.
+fn split_words(s: &str) -> Result<Vec<Word>, char> {
+    let mut words = Vec::new();
+    let mut current: Option<Word> = None;
+    let mut chars = s.chars();
+    while let Some(c) = chars.next() {
+        match c {
+            c if c.is_whitespace() => {
+                if let Some(w) = current.take() {
+                    words.push(w);
+                }
+            }
+            '"' | '\'' => {
+                let word = current.get_or_insert_with(|| Word {
+                    text: String::new(),
+                    quoted: false,
+                });
+                word.quoted = true;
+                loop {
+                    match chars.next() {
+                        None => return Err(c),
+                        Some(q) if q == c => break,
+                        Some('\\') if c == '"' => {
+                            if let Some(escaped) = chars.next() {
+                                word.text.push(escaped);
+                            }
+                        }
+                        Some(other) => word.text.push(other),
+                    }
+                }
+            }
+            '\\' => {
+                let word = current.get_or_insert_with(|| Word {
+                    text: String::new(),
+                    quoted: false,
+                });
+                if let Some(escaped) = chars.next() {
+                    word.text.push(escaped);
+                }
+            }
+            _ => current
+                .get_or_insert_with(|| Word {
+                    text: String::new(),
+                    quoted: false,
+                })
+                .text
+                .push(c),
+        }
+    }
+    if let Some(w) = current {
+        words.push(w);
+    }
+    Ok(words)
+}
+
+fn is_malformed_command(word: &str) -> bool {
+    let lower = word.to_ascii_lowercase();
+    (lower != word && lower == COMMAND_TOKEN)
+        || matches!(
+            lower.as_str(),
+            "%command" | "command%" | "%%command%%" | "$command" | "{command}"
+        )
+}
+
+fn is_env_assignment(word: &str) -> bool {
+    match word.split_once('=') {
+        Some((name, _)) => {
+            !name.is_empty()
+                && !name.starts_with(|c: char| c.is_ascii_digit())
+                && name.chars().all(|c| c.is_ascii_alphanumeric() || c == '_')
+        }
+        None => false,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn well_formed_options_pass() {
+        for opts in [
+            "",
+            "-fullscreen -novid",
+            "PROTON_LOG=1 %command% --data \"/home/deck/My Games\"",
+            "gamemoderun %command%",
+            "--name 'it''s fine'",
+            r#"--title "say \"hi\"""#,
+        ] {
+            assert!(check_launch_options(opts).is_empty(), "{opts}");
+        }
+    }
+
+    #[test]
+    fn unbalanced_quotes() {
+        assert_eq!(
+            check_launch_options("--data \"/home/deck/My Games"),
+            vec![LaunchOptionIssue::UnbalancedQuote('"')]
+        );
+        assert_eq!(
+            check_launch_options("--name 'oops"),
+            vec![LaunchOptionIssue::UnbalancedQuote('\'')]
+        );
+        // An escaped quote doesn't close the string.
+        assert_eq!(
+            check_launch_options(r#"--title "abc\""#),
+            vec![LaunchOptionIssue::UnbalancedQuote('"')]
+        );
+    }
+
+    #[test]
+    fn misplaced_command() {
+        assert_eq!(
+            check_launch_options("%command% -a %command%"),
+            vec![LaunchOptionIssue::RepeatedCommand]
+        );
+        assert_eq!(
+            check_launch_options("\"PROTON_LOG=1 %command%\""),
+            vec![LaunchOptionIssue::QuotedCommand]
+        );
+        assert_eq!(
+            check_launch_options("PROTON_LOG=1 %COMMAND%"),
+            vec![LaunchOptionIssue::MalformedCommand("%COMMAND%".into())]
+        );
+        assert_eq!(
+            check_launch_options("DXVK_HUD=1 %command"),
+            vec![LaunchOptionIssue::MalformedCommand("%command".into())]
+        );
+    }
+
+    #[test]
+    fn env_vars_need_command() {
+        assert_eq!(
+            check_launch_options("PROTON_LOG=1 -fullscreen"),
+            vec![LaunchOptionIssue::EnvWithoutCommand]
+        );
+        // Plain arguments with '=' aren't assignments.
+        assert!(check_launch_options("--width=1280").is_empty());
+    }
+
+    #[test]
+    fn normalize_collapses_whitespace_outside_quotes() {
+        assert_eq!(
+            normalize_launch_options("  PROTON_LOG=1   %command%\t--data  \"a  b\"  "),
+            "PROTON_LOG=1 %command% --data \"a  b\""
+        );
+        assert_eq!(normalize_launch_options("  \"open  "), "\"open");
+    }
+
+    #[test]
+    fn issue_messages() {
+        assert_eq!(
+            LaunchOptionIssue::QuotedCommand.to_string(),
+            "%command% is inside quotes"
+        );
+        assert!(
+            LaunchOptionIssue::EnvWithoutCommand
+                .to_string()
+                .contains("VAR=1 %command%")
+        );
+    }
+}
@@ -1,7 +1,10 @@
 pub mod console_log;
 pub mod constants;
 pub mod envelope;
+pub mod launch_options;
 pub mod messages;
+pub mod portable;
+pub mod profile;
 pub mod telemetry;
 pub mod types;
 
@@ -2,7 +2,10 @@ use std::collections::HashMap;
 
 use serde::{Deserialize, Serialize};
 
-use crate::types::{AgentInfo, ArtworkConfig, ShortcutConfig, ShortcutInfo, UploadConfig};
+use crate::portable::PortableShortcut;
+use crate::types::{
+    AgentInfo, AgentInfoLite, ArtworkConfig, ShortcutConfig, ShortcutInfo, UploadConfig,
+};
 
 // ---------------------------------------------------------------------------
 // Request payloads
@@ -48,6 +51,60 @@ pub struct CancelUploadRequest {
     pub upload_id: String,
 }
 
+/// Pauses or resumes an upload (`pause_upload` / `resume_upload`).
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct UploadControlRequest {
+    pub upload_id: String,
+}
+
+/// Asks how far an upload got on the agent, e.g. after the Hub reconnects.
+/// Answered with an [`UploadProgressEvent`].
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GetUploadStatusRequest {
+    pub upload_id: String,
+}
+
+/// An upload session held by the agent, as listed by `list_uploads`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct UploadSessionInfo {
+    pub upload_id: String,
+    pub game_name: String,
+    /// Progress, 0-100.
+    pub percentage: f64,
+    /// When the session was created, in Unix milliseconds (agent clock).
+    pub started_at: i64,
+    /// Seconds since data last arrived for the session.
+    pub idle_secs: u64,
+    /// Held by `pause_upload`; paused sessions are never pruned.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub paused: bool,
+}
+
+/// Upload sessions held by the agent, including ones whose Hub went away.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct UploadsResponse {
+    pub uploads: Vec<UploadSessionInfo>,
+}
+
+/// Discards upload sessions idle for longer than `idle_secs` (0 = the
+/// [`DEFAULT_UPLOAD_PRUNE_IDLE_SECS`](crate::constants::DEFAULT_UPLOAD_PRUNE_IDLE_SECS)
+/// default), deleting their partial game folders.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PruneUploadsRequest {
+    #[serde(default, skip_serializing_if = "is_zero_u64")]
+    pub idle_secs: u64,
+}
+
+/// Sessions removed by `prune_uploads`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct PruneUploadsResponse {
+    pub pruned: Vec<UploadSessionInfo>,
+}
+
 /// Sets which log levels the agent should collect.
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -61,6 +118,29 @@ pub struct SetConsoleLogEnabledRequest {
     pub enabled: bool,
 }
 
+/// Turns verbose agent logging on or off at runtime.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SetVerboseRequest {
+    pub enabled: bool,
+}
+
+/// Starts or stops streaming the agent's own log to this Hub. On
+/// subscribe the agent answers with up to `backlog` recent lines.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SubscribeLogsRequest {
+    pub enabled: bool,
+    #[serde(default, skip_serializing_if = "is_zero_u32")]
+    pub backlog: u32,
+}
+
+/// Answer to `subscribe_logs`, oldest line first.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SubscribeLogsResponse {
+    pub enabled: bool,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub lines: Vec<AgentLogLine>,
+}
+
 /// Creates a Steam shortcut.
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -69,7 +149,8 @@ pub struct CreateShortcutRequest {
     pub shortcut: ShortcutConfig,
 }
 
-/// Removes a Steam shortcut.
+/// Removes a Steam shortcut, identified by AppID, by exe + start dir, or
+/// by name (in that order of precedence).
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
 pub struct DeleteShortcutRequest {
@@ -78,6 +159,50 @@ pub struct DeleteShortcutRequest {
     pub app_id: u32,
     #[serde(default, skip_serializing_if = "String::is_empty")]
     pub name: String,
+    /// Exact executable path; set together with `start_dir`.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub exe: String,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub start_dir: String,
+}
+
+/// Changes an existing shortcut in place, keeping its artwork. Only the
+/// fields that are set are changed; `Some("")` clears launch options.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct UpdateShortcutRequest {
+    pub user_id: u32,
+    pub app_id: u32,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub name: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub launch_options: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub tags: Option<Vec<String>>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub start_dir: Option<String>,
+}
+
+impl UpdateShortcutRequest {
+    /// Whether the request changes anything.
+    pub fn is_empty(&self) -> bool {
+        self.name.is_none()
+            && self.launch_options.is_none()
+            && self.tags.is_none()
+            && self.start_dir.is_none()
+    }
+}
+
+/// Result of `update_shortcut`. Steam keeps a shortcut's AppID through
+/// every change, so `app_id` equals `old_app_id`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct UpdateShortcutResponse {
+    pub old_app_id: u32,
+    pub app_id: u32,
+    /// Non-fatal problems, e.g. tags that couldn't be written yet.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub warnings: Vec<String>,
 }
 
 /// Lists shortcuts for a user.
@@ -87,6 +212,53 @@ pub struct ListShortcutsRequest {
     pub user_id: u32,
 }
 
+/// Requests a shareable definition of an installed shortcut.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExportShortcutRequest {
+    pub app_id: u32,
+}
+
+/// Response for `export_shortcut`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ExportShortcutResponse {
+    pub shortcut: PortableShortcut,
+}
+
+/// Creates several shortcuts at once, e.g. after deploying multiple games.
+/// The agent reuses one Steam connection for all of them.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct CreateShortcutsBatchRequest {
+    /// Shortcuts with absolute `exe` and `start_dir` paths.
+    pub shortcuts: Vec<ShortcutConfig>,
+}
+
+/// Outcome of one shortcut in a batch.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ShortcutCreateResult {
+    pub name: String,
+    pub success: bool,
+    #[serde(default, skip_serializing_if = "is_zero_u32")]
+    pub app_id: u32,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub error: String,
+    /// Non-fatal problems, e.g. a requested compat tool that isn't installed.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub warnings: Vec<String>,
+}
+
+/// Response for `create_shortcuts_batch`, with one result per requested
+/// shortcut, in order.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CreateShortcutsBatchResponse {
+    pub results: Vec<ShortcutCreateResult>,
+    /// Steam had to be restarted (once) to reach it.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub steam_restarted: bool,
+}
+
 /// Requests artwork application.
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -103,6 +275,42 @@ pub struct DeleteGameRequest {
     pub app_id: u32,
 }
 
+/// Deletes several games at once. The agent shares one Steam connection
+/// across them and, if asked, restarts Steam once at the end.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DeleteGamesBatchRequest {
+    pub app_ids: Vec<u32>,
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub restart_steam: bool,
+}
+
+/// Requests renaming a deployed game (shortcut name + artwork migration).
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct RenameGameRequest {
+    pub app_id: u32,
+    pub new_name: String,
+}
+
+/// Starts an installed game through Steam on the agent's device.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LaunchGameRequest {
+    pub app_id: u32,
+}
+
+/// Reply to `launch_game`. A game Steam doesn't know is answered with a
+/// 404 error; Steam being unreachable, or not in gaming mode on Linux,
+/// with 503 and 409.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LaunchGameResponse {
+    pub app_id: u32,
+    /// Steam accepted the launch; the game may still take a while to start.
+    pub launched: bool,
+}
+
 /// Enables or disables the game log wrapper for a specific game.
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -130,6 +338,19 @@ pub struct HubConnectedRequest {
     /// Protocol version advertised by the Hub (0 = legacy/pre-negotiation).
     #[serde(default, skip_serializing_if = "is_zero_u32")]
     pub protocol_version: u32,
+    /// Optional capabilities the Hub supports. See
+    /// [`ProtocolProfile`](crate::profile::ProtocolProfile).
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub capabilities: Vec<String>,
+    /// Highest binary framing version the Hub speaks (0 = legacy v1).
+    #[serde(default, skip_serializing_if = "is_zero_u32")]
+    pub framing_version: u32,
+    /// Compression codecs the Hub supports for binary payloads.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub compression: Vec<String>,
+    /// Largest WebSocket message the Hub reads (0 = the default limit).
+    #[serde(default, skip_serializing_if = "is_zero_u64")]
+    pub max_binary_frame_size: u64,
 }
 
 /// Agent's response to a Hub connection.
@@ -149,6 +370,30 @@ pub struct AgentStatusResponse {
     /// Optional capabilities advertised by the Agent (e.g. `["tcp_data_channel"]`).
     #[serde(default, skip_serializing_if = "Vec::is_empty")]
     pub capabilities: Vec<String>,
+    /// Largest WebSocket frame the Agent reads, in bytes (0 = the 50 MB
+    /// default). See [`binary_frame_limit`](crate::constants::binary_frame_limit).
+    #[serde(default, skip_serializing_if = "is_zero_u64")]
+    pub max_binary_frame_size: u64,
+    /// Agent wall clock when the status was sent, Unix milliseconds
+    /// (0 = not reported). Lets the Hub detect clock skew.
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub agent_time: i64,
+    /// Highest binary framing version the Agent speaks (0 = legacy v1).
+    #[serde(default, skip_serializing_if = "is_zero_u32")]
+    pub framing_version: u32,
+    /// Compression codecs the Agent supports for binary payloads.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub compression: Vec<String>,
+    /// Replacement for a token close to expiry. The Hub stores it in place
+    /// of the one it connected with.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub renewed_token: String,
+    /// When the Agent's server started, Unix milliseconds (0 = not
+    /// reported), and why; as in [`AgentInfo`](crate::types::AgentInfo).
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub started_at: i64,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub start_reason: String,
 }
 
 /// Sent when a Hub needs to pair.
@@ -194,12 +439,24 @@ pub struct SetConsoleLogEnabledResponse {
     pub enabled: bool,
 }
 
+/// Confirms the verbose logging state.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SetVerboseResponse {
+    pub enabled: bool,
+}
+
 /// Contains agent information.
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 pub struct InfoResponse {
     pub agent: AgentInfo,
 }
 
+/// Contains the compact agent information.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct InfoLiteResponse {
+    pub agent: AgentInfoLite,
+}
+
 /// Acknowledges upload initialization.
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -235,8 +492,12 @@ pub struct ShortcutResponse {
 
 /// Contains Steam status.
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
 pub struct SteamStatusResponse {
     pub running: bool,
+    /// Steam is in Big Picture / Game Mode (gamescope on Linux).
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub gaming_mode: bool,
     #[serde(default, skip_serializing_if = "String::is_empty")]
     pub path: String,
 }
@@ -257,6 +518,159 @@ pub struct ConfigResponse {
     pub install_path: String,
 }
 
+/// Request for `set_install_path`: changes the agent's default install
+/// directory. The path must lie under the agent's allowed roots and is
+/// created if missing; the reply is a `config_response`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SetInstallPathRequest {
+    pub path: String,
+}
+
+/// Push event (`config_changed`) sent when the agent's settings change,
+/// whether locally or at a Hub's request. Carries the full new settings.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ConfigChangedEvent {
+    pub install_path: String,
+    pub telemetry_enabled: bool,
+    pub telemetry_interval: i32,
+    pub console_log_enabled: bool,
+    /// Agent display name (empty from agents that don't report it).
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub name: String,
+    /// Whether the agent accepts Hub connections (absent from agents that
+    /// don't report it).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub accept_connections: Option<bool>,
+}
+
+/// One line of the agent's own log, pushed as `log_line` to a subscribed
+/// Hub. Secrets such as tokens are redacted before it leaves the agent.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct AgentLogLine {
+    /// Unix milliseconds.
+    pub timestamp: i64,
+    /// `error`, `warn`, `info`, `debug` or `trace`.
+    pub level: String,
+    /// Module that logged the line.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub target: String,
+    pub message: String,
+}
+
+/// Result of an agent self-test.
+///
+/// Unlike `get_info`, every check actively probes its subsystem.
+/// `passed` is true only when all checks passed.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SelfTestResponse {
+    pub passed: bool,
+    pub checks: Vec<SelfTestCheck>,
+}
+
+/// Outcome of a single self-test probe.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SelfTestCheck {
+    pub name: String,
+    pub passed: bool,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub detail: String,
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub duration_ms: i64,
+}
+
+/// Request for `can_deploy`: asks whether a deploy would be accepted.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CanDeployRequest {
+    /// Expected size of the game in bytes (0 = unknown, skips the space check).
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub total_size: i64,
+}
+
+/// Verdict for `can_deploy`.
+///
+/// `can_deploy` is true only when `reasons` is empty.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CanDeployResponse {
+    pub can_deploy: bool,
+    #[serde(default)]
+    pub reasons: Vec<DeployBlocker>,
+}
+
+/// A condition preventing a deploy.
+///
+/// `code` is one of the `DEPLOY_BLOCKER_*` constants; `message` is a
+/// human-readable explanation.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct DeployBlocker {
+    pub code: String,
+    pub message: String,
+}
+
+/// Request for `browse_directory`: lists subdirectories of `path` on the
+/// Agent, for picking an install target.
+///
+/// An empty `path` lists the allowed roots. With `create`, `path` is created
+/// first (it must still resolve inside an allowed root).
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BrowseDirectoryRequest {
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub path: String,
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub create: bool,
+}
+
+/// Listing for `browse_directory`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BrowseDirectoryResponse {
+    /// Resolved absolute path that was listed (empty when listing roots).
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub path: String,
+    /// Parent directory, empty at an allowed root.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub parent: String,
+    /// Roots the Hub may browse under.
+    pub roots: Vec<String>,
+    /// Subdirectories of `path`, sorted by name.
+    pub entries: Vec<DirectoryEntry>,
+}
+
+/// A subdirectory in a `browse_directory` listing.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct DirectoryEntry {
+    pub name: String,
+    pub path: String,
+}
+
+/// Request for `get_disk_usage`. An empty `path` means the Agent's
+/// configured install path.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GetDiskUsageRequest {
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub path: String,
+}
+
+/// Size of the filesystem holding a path, answered for `get_disk_usage`.
+///
+/// `free_bytes` is what an unprivileged user can still write, so
+/// `used_bytes + free_bytes` may be less than `total_bytes`.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DiskUsage {
+    /// Resolved absolute path that was measured.
+    pub path: String,
+    pub total_bytes: u64,
+    pub free_bytes: u64,
+    pub used_bytes: u64,
+}
+
 // ---------------------------------------------------------------------------
 // Steam payloads
 // ---------------------------------------------------------------------------
@@ -280,6 +694,44 @@ pub struct SteamUsersResponse {
     pub users: Vec<SteamUser>,
 }
 
+/// A Steam library folder on the Agent (internal drive, SD card, ...).
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SteamLibrary {
+    pub path: String,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub label: String,
+    /// Where deployed games go on this library.
+    pub install_path: String,
+}
+
+/// List of Steam library folders.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SteamLibrariesResponse {
+    pub libraries: Vec<SteamLibrary>,
+}
+
+/// An authorized Hub currently connected to the Agent.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ConnectedHubEntry {
+    pub name: String,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub ip: String,
+    /// When the Hub connected, in Unix milliseconds (Agent clock).
+    pub connected_since: i64,
+    /// This entry is the Hub that asked.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub current: bool,
+}
+
+/// Authorized Hubs connected to the Agent. Only an authorized Hub may ask,
+/// and unpaired connections are never listed.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ConnectedHubsResponse {
+    pub hubs: Vec<ConnectedHubEntry>,
+}
+
 /// List of shortcuts.
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 pub struct ShortcutsListResponse {
@@ -314,6 +766,42 @@ pub struct DeleteGameResponse {
     pub steam_restarted: bool,
 }
 
+/// Outcome of one game in a `delete_games_batch`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GameDeleteResult {
+    pub app_id: u32,
+    pub success: bool,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub game_name: String,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub error: String,
+}
+
+/// Response for `delete_games_batch`, with one result per requested AppID,
+/// in order.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DeleteGamesBatchResponse {
+    pub results: Vec<GameDeleteResult>,
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub steam_restarted: bool,
+}
+
+/// Result of a game rename.
+///
+/// Steam keeps the shortcut's AppID when renaming it, so `app_id` equals
+/// `old_app_id` and no artwork is migrated.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct RenameGameResponse {
+    pub old_app_id: u32,
+    pub app_id: u32,
+    pub name: String,
+    #[serde(default, skip_serializing_if = "is_zero_u32")]
+    pub artwork_migrated: u32,
+}
+
 // ---------------------------------------------------------------------------
 // Artwork payloads
 // ---------------------------------------------------------------------------
@@ -342,6 +830,49 @@ pub struct ArtworkImageResponse {
     pub artwork_type: String,
     #[serde(default, skip_serializing_if = "String::is_empty")]
     pub error: String,
+    /// For a chunked image: bytes the agent holds, i.e. the offset the
+    /// next chunk must start at, or the total size once it's complete.
+    #[serde(default, skip_serializing_if = "is_zero_u64")]
+    pub received: u64,
+}
+
+/// Requests the artwork currently on a shortcut.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GetArtworkRequest {
+    pub app_id: u32,
+    /// Steam account to read from; empty means the shortcut's owner.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub user_id: String,
+}
+
+/// Response for `get_artwork`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AppliedArtworkResponse {
+    pub app_id: u32,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub images: Vec<AppliedArtwork>,
+    /// Artwork types with no file on the agent.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub missing: Vec<String>,
+}
+
+/// One artwork file found on a shortcut.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AppliedArtwork {
+    /// `grid`, `hero`, `logo`, `icon` or `portrait`.
+    pub artwork_type: String,
+    pub content_type: String,
+    /// File size in bytes.
+    pub size: u64,
+    /// File contents; empty when the file exceeds
+    /// [`ARTWORK_PREVIEW_MAX_SIZE`](crate::constants::ARTWORK_PREVIEW_MAX_SIZE).
+    #[serde(default, with = "base64_bytes", skip_serializing_if = "Vec::is_empty")]
+    pub data: Vec<u8>,
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub too_large: bool,
 }
 
 // ---------------------------------------------------------------------------
@@ -380,6 +911,18 @@ pub struct RestartSteamResponse {
     pub message: String,
 }
 
+/// Whether shortcuts and artwork can go through the Steam CEF debugger.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CefStatusResponse {
+    pub available: bool,
+    pub steam_running: bool,
+    /// Steam is running but was started before remote debugging was
+    /// enabled; it has to restart before CEF answers.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub restart_pending: bool,
+}
+
 // ---------------------------------------------------------------------------
 // Upload extended payloads
 // ---------------------------------------------------------------------------
@@ -391,6 +934,19 @@ pub struct InitUploadRequestFull {
     pub config: UploadConfig,
     pub total_size: i64,
     pub files: Vec<FileEntry>,
+    /// Upload to continue instead of starting a new one. The agent answers
+    /// with the same ID and the bytes it already holds per file, or 404 if
+    /// the session is gone.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub resume_upload_id: String,
+    /// Codec the Hub would like to compress chunks with: "none", "gzip" or
+    /// "zstd". Empty means none.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub compression: String,
+    /// Only validate the request: the agent answers with a `preview` of
+    /// what the upload would do and opens no session.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub dry_run: bool,
 }
 
 /// A file in the upload manifest.
@@ -399,6 +955,10 @@ pub struct InitUploadRequestFull {
 pub struct FileEntry {
     pub relative_path: String,
     pub size: i64,
+    /// Last modification time in Unix seconds, 0 if unknown. With the
+    /// size, lets the agent skip files it already has unchanged.
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub modified: i64,
 }
 
 /// Upload response with chunk size configuration.
@@ -415,6 +975,58 @@ pub struct InitUploadResponseFull {
     /// TCP data channel authentication token.
     #[serde(default, skip_serializing_if = "Option::is_none")]
     pub tcp_token: Option<String>,
+    /// Chunks the Hub may have in flight at once; zero (older agents)
+    /// means one at a time.
+    #[serde(default, skip_serializing_if = "is_zero_u32")]
+    pub max_concurrent_chunks: u32,
+    /// Codec the agent accepts for this upload's chunks; empty (older
+    /// agents) means none.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub compression: String,
+    /// Files already installed unchanged at the target, which the Hub
+    /// doesn't need to send.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub skip_files: Vec<String>,
+    /// Answer to a dry run; `upload_id` is empty then. Agents without dry
+    /// run support ignore the flag and open a real session instead.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub preview: Option<UploadPreview>,
+}
+
+/// What an upload would do, reported for a dry run.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct UploadPreview {
+    /// Directory the game would be installed into.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub game_path: String,
+    /// Bytes left to send once unchanged files are skipped.
+    pub transfer_bytes: i64,
+    /// Free space at the install location, if it could be measured.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub available_bytes: Option<u64>,
+    /// Problems that would make the upload fail.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub blockers: Vec<DeployBlocker>,
+    /// Things worth knowing that don't stop the upload, such as artwork
+    /// that can't be applied.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub warnings: Vec<String>,
+    /// Existing shortcut the upload would collide with unless it asks to
+    /// overwrite.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub conflict: Option<ShortcutConflict>,
+}
+
+/// A Steam shortcut that already has an upload's name or executable.
+/// Sent as the payload of a `WS_ERR_CODE_CONFLICT` reply to `init_upload`.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ShortcutConflict {
+    pub app_id: u32,
+    pub name: String,
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub exe: String,
 }
 
 /// Upload chunk with full metadata.
@@ -437,6 +1049,33 @@ pub struct CompleteUploadRequestFull {
     pub create_shortcut: bool,
     #[serde(default, skip_serializing_if = "Option::is_none")]
     pub shortcut: Option<ShortcutConfig>,
+    /// Files the installed folder must contain. When set, the agent checks
+    /// them before creating the shortcut; older agents ignore it.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub verify: Vec<ExpectedFile>,
+}
+
+/// A file the Hub expects after an upload.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExpectedFile {
+    pub relative_path: String,
+    pub size: i64,
+    /// SHA-256 in hex; only the size is checked when empty.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub checksum: String,
+}
+
+/// A file that failed post-install verification.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FileMismatch {
+    pub relative_path: String,
+    /// One of the `FILE_MISMATCH_*` constants.
+    pub reason: String,
+    /// Size found on disk; 0 when missing.
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub actual_size: i64,
 }
 
 /// Upload completion result with path and app ID.
@@ -448,6 +1087,14 @@ pub struct CompleteUploadResponseFull {
     pub path: String,
     #[serde(default, skip_serializing_if = "is_zero_u32")]
     pub app_id: u32,
+    /// Non-fatal problems, e.g. a boot video Steam can't use.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub warnings: Vec<String>,
+    /// Files that failed verification. When set, `success` is false, no
+    /// shortcut was created, and the upload stays open so the Hub can
+    /// send these files again and complete once more.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub mismatched: Vec<FileMismatch>,
 }
 
 /// Notifies the Hub that a TCP data channel is ready for file transfer.
@@ -471,6 +1118,18 @@ pub struct UploadProgressEvent {
     #[serde(default, skip_serializing_if = "String::is_empty")]
     pub current_file: String,
     pub percentage: f64,
+    /// Bytes of `current_file` received so far (0 = not reported).
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub file_transferred_bytes: i64,
+    /// Size of `current_file` (0 = not reported).
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub file_total_bytes: i64,
+    /// Recent transfer speed, smoothed (0 = not measured yet).
+    #[serde(default, skip_serializing_if = "is_zero_f64")]
+    pub bytes_per_sec: f64,
+    /// Time left at `bytes_per_sec`, in seconds.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub estimated_seconds_remaining: Option<u64>,
 }
 
 // ---------------------------------------------------------------------------
@@ -588,6 +1247,14 @@ fn is_zero_u32(v: &u32) -> bool {
     *v == 0
 }
 
+fn is_zero_u64(v: &u64) -> bool {
+    *v == 0
+}
+
+fn is_zero_f64(v: &f64) -> bool {
+    *v == 0.0
+}
+
 fn is_false(v: &bool) -> bool {
     !v
 }
@@ -636,12 +1303,20 @@ mod tests {
             hub_id: String::new(),
             token: String::new(),
             protocol_version: 0,
+            capabilities: vec![],
+            framing_version: 0,
+            compression: vec![],
+            max_binary_frame_size: 0,
         };
         let json = serde_json::to_string(&req).unwrap();
         assert!(!json.contains("platform"));
         assert!(!json.contains("hubId"));
         assert!(!json.contains("token"));
         assert!(!json.contains("protocolVersion"));
+        assert!(!json.contains("capabilities"));
+        assert!(!json.contains("framingVersion"));
+        assert!(!json.contains("compression"));
+        assert!(!json.contains("maxBinaryFrameSize"));
     }
 
     #[test]
@@ -653,6 +1328,10 @@ mod tests {
             hub_id: String::new(),
             token: String::new(),
             protocol_version: 1,
+            capabilities: vec![],
+            framing_version: 0,
+            compression: vec![],
+            max_binary_frame_size: 0,
         };
         let json = serde_json::to_string(&req).unwrap();
         assert!(json.contains("\"protocolVersion\":1"));
@@ -670,12 +1349,23 @@ mod tests {
             console_log_enabled: false,
             protocol_version: 1,
             capabilities: vec![],
+            max_binary_frame_size: 0,
+            agent_time: 0,
+            framing_version: 0,
+            compression: vec![],
+            renewed_token: String::new(),
+            started_at: 0,
+            start_reason: String::new(),
         };
         let json = serde_json::to_string(&resp).unwrap();
         assert!(json.contains("\"acceptConnections\":true"));
         assert!(json.contains("\"protocolVersion\":1"));
-        // Empty capabilities should be omitted.
+        // Empty capabilities and the default frame limit should be omitted.
         assert!(!json.contains("capabilities"));
+        assert!(!json.contains("maxBinaryFrameSize"));
+        assert!(!json.contains("agentTime"));
+        assert!(!json.contains("renewedToken"));
+        assert!(!json.contains("startedAt"));
         let parsed: AgentStatusResponse = serde_json::from_str(&json).unwrap();
         assert_eq!(resp, parsed);
     }
@@ -692,9 +1382,20 @@ mod tests {
             console_log_enabled: false,
             protocol_version: 1,
             capabilities: vec!["tcp_data_channel".into()],
+            max_binary_frame_size: 8 * 1024 * 1024,
+            agent_time: 1_700_000_000_000,
+            framing_version: 1,
+            compression: vec![],
+            renewed_token: "new-token".into(),
+            started_at: 1_700_000_000_000,
+            start_reason: "initial".into(),
         };
         let json = serde_json::to_string(&resp).unwrap();
         assert!(json.contains("\"capabilities\":[\"tcp_data_channel\"]"));
+        assert!(json.contains("\"renewedToken\":\"new-token\""));
+        assert!(json.contains("\"maxBinaryFrameSize\":8388608"));
+        assert!(json.contains("\"agentTime\":1700000000000"));
+        assert!(json.contains("\"startReason\":\"initial\""));
         let parsed: AgentStatusResponse = serde_json::from_str(&json).unwrap();
         assert_eq!(resp, parsed);
     }
@@ -709,6 +1410,7 @@ mod tests {
         }"#;
         let resp: AgentStatusResponse = serde_json::from_str(json).unwrap();
         assert!(resp.capabilities.is_empty());
+        assert_eq!(resp.max_binary_frame_size, 0);
     }
 
     #[test]
@@ -725,6 +1427,221 @@ mod tests {
         assert_eq!(evt, parsed);
     }
 
+    #[test]
+    fn init_upload_request_resume_id() {
+        let json = r#"{
+            "config": {"gameName": "Game", "installPath": "", "executable": "game.exe"},
+            "totalSize": 10,
+            "files": [{"relativePath": "game.exe", "size": 10}]
+        }"#;
+        let mut req: InitUploadRequestFull = serde_json::from_str(json).unwrap();
+        assert!(req.resume_upload_id.is_empty());
+        assert!(
+            !serde_json::to_string(&req)
+                .unwrap()
+                .contains("resumeUploadId")
+        );
+
+        req.resume_upload_id = "u1".into();
+        let json = serde_json::to_string(&req).unwrap();
+        assert!(json.contains("\"resumeUploadId\":\"u1\""));
+        let parsed: InitUploadRequestFull = serde_json::from_str(&json).unwrap();
+        assert_eq!(req, parsed);
+    }
+
+    #[test]
+    fn init_upload_compression_negotiation() {
+        let json = r#"{
+            "config": {"gameName": "Game", "installPath": "", "executable": "game.exe"},
+            "totalSize": 10,
+            "files": [{"relativePath": "game.exe", "size": 10}],
+            "compression": "zstd"
+        }"#;
+        let req: InitUploadRequestFull = serde_json::from_str(json).unwrap();
+        assert_eq!(req.compression, "zstd");
+
+        // Older agents leave the codec out of their reply.
+        let resp: InitUploadResponseFull =
+            serde_json::from_str(r#"{"uploadId": "u1", "chunkSize": 1024}"#).unwrap();
+        assert!(resp.compression.is_empty());
+        let resp = InitUploadResponseFull {
+            compression: "zstd".into(),
+            ..resp
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(json.contains("\"compression\":\"zstd\""));
+    }
+
+    #[test]
+    fn init_upload_delta_fields() {
+        // Older Hubs don't send modification times.
+        let entry: FileEntry =
+            serde_json::from_str(r#"{"relativePath": "game.exe", "size": 10}"#).unwrap();
+        assert_eq!(entry.modified, 0);
+        assert!(!serde_json::to_string(&entry).unwrap().contains("modified"));
+
+        let entry = FileEntry {
+            modified: 1_700_000_000,
+            ..entry
+        };
+        let json = serde_json::to_string(&entry).unwrap();
+        assert!(json.contains("\"modified\":1700000000"));
+
+        let resp: InitUploadResponseFull = serde_json::from_str(
+            r#"{"uploadId": "u1", "chunkSize": 1024, "skipFiles": ["game.exe"]}"#,
+        )
+        .unwrap();
+        assert_eq!(resp.skip_files, vec!["game.exe"]);
+    }
+
+    #[test]
+    fn complete_upload_verification_fields() {
+        // Older Hubs send no manifest, older agents report no mismatches.
+        let req: CompleteUploadRequestFull =
+            serde_json::from_str(r#"{"uploadId": "u1", "createShortcut": true}"#).unwrap();
+        assert!(req.verify.is_empty());
+        assert!(!serde_json::to_string(&req).unwrap().contains("verify"));
+
+        let req = CompleteUploadRequestFull {
+            verify: vec![ExpectedFile {
+                relative_path: "game.exe".into(),
+                size: 10,
+                checksum: String::new(),
+            }],
+            ..req
+        };
+        let json = serde_json::to_string(&req).unwrap();
+        assert!(json.contains(r#""verify":[{"relativePath":"game.exe","size":10}]"#));
+        let parsed: CompleteUploadRequestFull = serde_json::from_str(&json).unwrap();
+        assert_eq!(parsed, req);
+
+        let resp = CompleteUploadResponseFull {
+            success: false,
+            path: "/games/Test".into(),
+            app_id: 0,
+            warnings: Vec::new(),
+            mismatched: vec![FileMismatch {
+                relative_path: "data.pak".into(),
+                reason: crate::constants::FILE_MISMATCH_SIZE.into(),
+                actual_size: 4,
+            }],
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(json.contains(r#""reason":"size""#));
+        assert!(json.contains(r#""actualSize":4"#));
+        let parsed: CompleteUploadResponseFull = serde_json::from_str(&json).unwrap();
+        assert_eq!(parsed, resp);
+    }
+
+    #[test]
+    fn get_upload_status_request_json() {
+        let req = GetUploadStatusRequest {
+            upload_id: "u1".into(),
+        };
+        assert_eq!(serde_json::to_string(&req).unwrap(), r#"{"uploadId":"u1"}"#);
+    }
+
+    #[test]
+    fn upload_progress_event_file_fields() {
+        let evt = UploadProgressEvent {
+            upload_id: "u1".into(),
+            transferred_bytes: 300,
+            total_bytes: 1000,
+            current_file: "data/big.pak".into(),
+            percentage: 30.0,
+            file_transferred_bytes: 200,
+            file_total_bytes: 800,
+            bytes_per_sec: 1.5e9,
+            estimated_seconds_remaining: Some(240),
+        };
+        let json = serde_json::to_string(&evt).unwrap();
+        assert!(json.contains("\"estimatedSecondsRemaining\":240"));
+        assert!(json.contains("\"fileTransferredBytes\":200"));
+        assert!(json.contains("\"fileTotalBytes\":800"));
+        let parsed: UploadProgressEvent = serde_json::from_str(&json).unwrap();
+        assert_eq!(evt, parsed);
+
+        let legacy: UploadProgressEvent = serde_json::from_str(
+            r#"{"uploadId":"u1","transferredBytes":1,"totalBytes":2,"percentage":50.0}"#,
+        )
+        .unwrap();
+        assert_eq!(legacy.file_total_bytes, 0);
+        assert_eq!(legacy.bytes_per_sec, 0.0);
+        assert!(legacy.estimated_seconds_remaining.is_none());
+    }
+
+    #[test]
+    fn update_shortcut_request_only_sends_changes() {
+        let req = UpdateShortcutRequest {
+            user_id: 1,
+            app_id: 42,
+            launch_options: Some(String::new()),
+            ..Default::default()
+        };
+        assert!(!req.is_empty());
+        assert_eq!(
+            serde_json::to_string(&req).unwrap(),
+            r#"{"userId":1,"appId":42,"launchOptions":""}"#
+        );
+        let parsed: UpdateShortcutRequest =
+            serde_json::from_str(r#"{"userId":1,"appId":42,"tags":["RPG"]}"#).unwrap();
+        assert_eq!(parsed.tags, Some(vec!["RPG".to_string()]));
+        assert!(parsed.name.is_none());
+        assert!(
+            UpdateShortcutRequest {
+                user_id: 1,
+                app_id: 42,
+                ..Default::default()
+            }
+            .is_empty()
+        );
+    }
+
+    #[test]
+    fn launch_game_roundtrip() {
+        let req = LaunchGameRequest {
+            app_id: 3_000_000_001,
+        };
+        assert_eq!(
+            serde_json::to_string(&req).unwrap(),
+            r#"{"appId":3000000001}"#
+        );
+        let resp = LaunchGameResponse {
+            app_id: 3_000_000_001,
+            launched: true,
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert_eq!(json, r#"{"appId":3000000001,"launched":true}"#);
+        let parsed: LaunchGameResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(parsed, resp);
+    }
+
+    #[test]
+    fn uploads_response_roundtrip() {
+        let resp = UploadsResponse {
+            uploads: vec![UploadSessionInfo {
+                upload_id: "u1".into(),
+                game_name: "Game".into(),
+                percentage: 42.5,
+                started_at: 1_700_000_000_000,
+                idle_secs: 30,
+                paused: true,
+            }],
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(json.contains("\"startedAt\":1700000000000"));
+        assert!(json.contains("\"idleSecs\":30"));
+        assert!(json.contains("\"paused\":true"));
+        let parsed: UploadsResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+
+        // The default idle threshold is omitted.
+        let req = PruneUploadsRequest::default();
+        assert_eq!(serde_json::to_string(&req).unwrap(), "{}");
+        let req: PruneUploadsRequest = serde_json::from_str(r#"{"idleSecs":60}"#).unwrap();
+        assert_eq!(req.idle_secs, 60);
+    }
+
     #[test]
     fn init_upload_response_full_roundtrip() {
         let mut resume = HashMap::new();
@@ -735,9 +1652,14 @@ mod tests {
             resume_from: Some(resume),
             tcp_port: Some(54321),
             tcp_token: Some("abc123".into()),
+            max_concurrent_chunks: 4,
+            compression: String::new(),
+            skip_files: Vec::new(),
+            preview: None,
         };
         let json = serde_json::to_string(&resp).unwrap();
         assert!(json.contains("\"tcpPort\":54321"));
+        assert!(json.contains("\"maxConcurrentChunks\":4"));
         assert!(json.contains("\"tcpToken\":\"abc123\""));
         let parsed: InitUploadResponseFull = serde_json::from_str(&json).unwrap();
         assert_eq!(resp, parsed);
@@ -755,6 +1677,48 @@ mod tests {
         assert!(resp.resume_from.is_none());
         assert!(resp.tcp_port.is_none());
         assert!(resp.tcp_token.is_none());
+        assert_eq!(resp.max_concurrent_chunks, 0);
+    }
+
+    #[test]
+    fn init_upload_dry_run_preview() {
+        // Older Hubs never ask for a dry run.
+        let req: InitUploadRequestFull = serde_json::from_str(
+            r#"{"config":{"gameName":"G","installPath":"","executable":"g.exe"},"totalSize":1,"files":[]}"#,
+        )
+        .unwrap();
+        assert!(!req.dry_run);
+        assert!(!serde_json::to_string(&req).unwrap().contains("dryRun"));
+
+        let resp: InitUploadResponseFull =
+            serde_json::from_str(r#"{"uploadId": "u1", "chunkSize": 1024}"#).unwrap();
+        assert!(resp.preview.is_none());
+
+        let resp = InitUploadResponseFull {
+            upload_id: String::new(),
+            preview: Some(UploadPreview {
+                game_path: "/games/G".into(),
+                transfer_bytes: 10,
+                available_bytes: Some(5),
+                blockers: vec![DeployBlocker {
+                    code: "disk_full".into(),
+                    message: "no room".into(),
+                }],
+                warnings: Vec::new(),
+                conflict: Some(ShortcutConflict {
+                    app_id: 42,
+                    name: "G".into(),
+                    exe: String::new(),
+                }),
+            }),
+            ..resp
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(json.contains("\"transferBytes\":10"));
+        assert!(!json.contains("warnings"));
+        assert!(json.contains("\"conflict\":{\"appId\":42,\"name\":\"G\"}"));
+        let parsed: InitUploadResponseFull = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
     }
 
     #[test]
@@ -765,6 +1729,10 @@ mod tests {
             resume_from: None,
             tcp_port: None,
             tcp_token: None,
+            max_concurrent_chunks: 0,
+            compression: String::new(),
+            skip_files: Vec::new(),
+            preview: None,
         };
         let json = serde_json::to_string(&resp).unwrap();
         assert!(!json.contains("tcpPort"));
@@ -788,16 +1756,368 @@ mod tests {
         assert_eq!(evt, parsed);
     }
 
+    #[test]
+    fn config_changed_event_roundtrip() {
+        let evt = ConfigChangedEvent {
+            install_path: "/run/media/sd/Games".into(),
+            telemetry_enabled: true,
+            telemetry_interval: 2,
+            console_log_enabled: false,
+            name: "Steam Deck".into(),
+            accept_connections: Some(true),
+        };
+        let json = serde_json::to_string(&evt).unwrap();
+        assert!(json.contains("\"installPath\":\"/run/media/sd/Games\""));
+        assert!(json.contains("\"telemetryInterval\":2"));
+        assert!(json.contains("\"acceptConnections\":true"));
+        let parsed: ConfigChangedEvent = serde_json::from_str(&json).unwrap();
+        assert_eq!(evt, parsed);
+
+        // Older agents only sent the install path and telemetry settings.
+        let legacy: ConfigChangedEvent = serde_json::from_str(
+            r#"{"installPath":"/g","telemetryEnabled":false,"telemetryInterval":1,"consoleLogEnabled":false}"#,
+        )
+        .unwrap();
+        assert!(legacy.name.is_empty());
+        assert_eq!(legacy.accept_connections, None);
+    }
+
     #[test]
     fn delete_shortcut_omit_empty() {
         let req = DeleteShortcutRequest {
             user_id: 12345,
             app_id: 0,
             name: String::new(),
+            exe: String::new(),
+            start_dir: String::new(),
         };
         let json = serde_json::to_string(&req).unwrap();
         assert!(!json.contains("appId"));
         assert!(!json.contains("name"));
+        assert!(!json.contains("exe"));
+        assert!(!json.contains("startDir"));
+
+        let by_target = DeleteShortcutRequest {
+            exe: "/games/a/game.sh".into(),
+            start_dir: "/games/a".into(),
+            ..req
+        };
+        let json = serde_json::to_string(&by_target).unwrap();
+        assert!(json.contains("\"startDir\":\"/games/a\""));
+        let parsed: DeleteShortcutRequest = serde_json::from_str(&json).unwrap();
+        assert_eq!(parsed, by_target);
+    }
+
+    #[test]
+    fn create_shortcuts_batch_response_omit_empty() {
+        let resp = CreateShortcutsBatchResponse {
+            results: vec![
+                ShortcutCreateResult {
+                    name: "Celeste".into(),
+                    success: true,
+                    app_id: 3_000_000_001,
+                    error: String::new(),
+                    warnings: vec!["compat tool proton_5 is not installed".into()],
+                },
+                ShortcutCreateResult {
+                    name: "Hades".into(),
+                    success: false,
+                    app_id: 0,
+                    error: "JS exception".into(),
+                    warnings: Vec::new(),
+                },
+            ],
+            steam_restarted: false,
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert_eq!(
+            json,
+            r#"{"results":[{"name":"Celeste","success":true,"appId":3000000001,"warnings":["compat tool proton_5 is not installed"]},{"name":"Hades","success":false,"error":"JS exception"}]}"#
+        );
+        let parsed: CreateShortcutsBatchResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+    }
+
+    #[test]
+    fn delete_games_batch_roundtrip() {
+        let req = DeleteGamesBatchRequest {
+            app_ids: vec![3_000_000_001, 3_000_000_002],
+            restart_steam: false,
+        };
+        let json = serde_json::to_string(&req).unwrap();
+        assert_eq!(json, r#"{"appIds":[3000000001,3000000002]}"#);
+        assert_eq!(
+            serde_json::from_str::<DeleteGamesBatchRequest>(&json).unwrap(),
+            req
+        );
+
+        let resp = DeleteGamesBatchResponse {
+            results: vec![
+                GameDeleteResult {
+                    app_id: 3_000_000_001,
+                    success: true,
+                    game_name: "Celeste".into(),
+                    error: String::new(),
+                },
+                GameDeleteResult {
+                    app_id: 3_000_000_002,
+                    success: false,
+                    game_name: String::new(),
+                    error: "game not found".into(),
+                },
+            ],
+            steam_restarted: true,
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert_eq!(
+            json,
+            r#"{"results":[{"appId":3000000001,"success":true,"gameName":"Celeste"},{"appId":3000000002,"success":false,"error":"game not found"}],"steamRestarted":true}"#
+        );
+        let parsed: DeleteGamesBatchResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(parsed, resp);
+    }
+
+    #[test]
+    fn connected_hubs_response_roundtrip() {
+        let resp = ConnectedHubsResponse {
+            hubs: vec![
+                ConnectedHubEntry {
+                    name: "Bob's PC".into(),
+                    ip: "192.168.1.20".into(),
+                    connected_since: 1_700_000_000_000,
+                    current: false,
+                },
+                ConnectedHubEntry {
+                    name: "Studio".into(),
+                    ip: String::new(),
+                    connected_since: 1_700_000_500_000,
+                    current: true,
+                },
+            ],
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(json.contains("\"connectedSince\":1700000000000"));
+        assert_eq!(json.matches("\"current\"").count(), 1);
+        assert_eq!(json.matches("\"ip\"").count(), 1);
+        let parsed: ConnectedHubsResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+    }
+
+    #[test]
+    fn rename_game_roundtrip() {
+        let req = RenameGameRequest {
+            app_id: 3_000_000_001,
+            new_name: "New Name".into(),
+        };
+        let json = serde_json::to_string(&req).unwrap();
+        assert!(json.contains("\"newName\":\"New Name\""));
+        let parsed: RenameGameRequest = serde_json::from_str(&json).unwrap();
+        assert_eq!(req, parsed);
+
+        let resp = RenameGameResponse {
+            old_app_id: 3_000_000_001,
+            app_id: 3_000_000_002,
+            name: "New Name".into(),
+            artwork_migrated: 0,
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(json.contains("\"oldAppId\""));
+        assert!(!json.contains("artworkMigrated"));
+        let parsed: RenameGameResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+    }
+
+    #[test]
+    fn self_test_response_roundtrip() {
+        let resp = SelfTestResponse {
+            passed: false,
+            checks: vec![
+                SelfTestCheck {
+                    name: "steam_paths".into(),
+                    passed: true,
+                    detail: String::new(),
+                    duration_ms: 0,
+                },
+                SelfTestCheck {
+                    name: "cef".into(),
+                    passed: false,
+                    detail: "connection refused".into(),
+                    duration_ms: 12,
+                },
+            ],
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(json.contains("\"durationMs\":12"));
+        assert_eq!(json.matches("detail").count(), 1);
+        let parsed: SelfTestResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+    }
+
+    #[test]
+    fn can_deploy_roundtrip() {
+        let req = CanDeployRequest { total_size: 0 };
+        assert_eq!(serde_json::to_string(&req).unwrap(), "{}");
+        let parsed: CanDeployRequest = serde_json::from_str("{\"totalSize\":1024}").unwrap();
+        assert_eq!(parsed.total_size, 1024);
+
+        let resp = CanDeployResponse {
+            can_deploy: false,
+            reasons: vec![DeployBlocker {
+                code: "disk_full".into(),
+                message: "need 2 GB, 1 GB free".into(),
+            }],
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(json.contains("\"canDeploy\":false"));
+        let parsed: CanDeployResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+    }
+
+    #[test]
+    fn browse_directory_roundtrip() {
+        let req = BrowseDirectoryRequest::default();
+        assert_eq!(serde_json::to_string(&req).unwrap(), "{}");
+        let parsed: BrowseDirectoryRequest =
+            serde_json::from_str(r#"{"path":"/home/deck/Games","create":true}"#).unwrap();
+        assert!(parsed.create);
+
+        let resp = BrowseDirectoryResponse {
+            path: "/home/deck".into(),
+            parent: String::new(),
+            roots: vec!["/home/deck".into()],
+            entries: vec![DirectoryEntry {
+                name: "Games".into(),
+                path: "/home/deck/Games".into(),
+            }],
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(!json.contains("parent"));
+        let parsed: BrowseDirectoryResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+    }
+
+    #[test]
+    fn disk_usage_roundtrip() {
+        let req = GetDiskUsageRequest::default();
+        assert_eq!(serde_json::to_string(&req).unwrap(), "{}");
+
+        let usage = DiskUsage {
+            path: "/run/media/mmcblk0p1".into(),
+            total_bytes: 512_000_000_000,
+            free_bytes: 40_000_000_000,
+            used_bytes: 470_000_000_000,
+        };
+        let json = serde_json::to_string(&usage).unwrap();
+        assert_eq!(
+            json,
+            r#"{"path":"/run/media/mmcblk0p1","totalBytes":512000000000,"freeBytes":40000000000,"usedBytes":470000000000}"#
+        );
+        let parsed: DiskUsage = serde_json::from_str(&json).unwrap();
+        assert_eq!(usage, parsed);
+    }
+
+    #[test]
+    fn steam_status_roundtrip() {
+        let resp = SteamStatusResponse {
+            running: true,
+            gaming_mode: true,
+            path: "/home/deck/.steam/steam".into(),
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert_eq!(
+            json,
+            r#"{"running":true,"gamingMode":true,"path":"/home/deck/.steam/steam"}"#
+        );
+        let parsed: SteamStatusResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+
+        let stopped: SteamStatusResponse = serde_json::from_str(r#"{"running":false}"#).unwrap();
+        assert!(!stopped.gaming_mode);
+        assert!(stopped.path.is_empty());
+    }
+
+    #[test]
+    fn cef_status_roundtrip() {
+        let resp = CefStatusResponse {
+            available: false,
+            steam_running: true,
+            restart_pending: true,
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert_eq!(
+            json,
+            r#"{"available":false,"steamRunning":true,"restartPending":true}"#
+        );
+        let parsed: CefStatusResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+
+        let ready = CefStatusResponse {
+            available: true,
+            steam_running: true,
+            restart_pending: false,
+        };
+        assert_eq!(
+            serde_json::to_string(&ready).unwrap(),
+            r#"{"available":true,"steamRunning":true}"#
+        );
+    }
+
+    #[test]
+    fn subscribe_logs_roundtrip() {
+        let req = SubscribeLogsRequest {
+            enabled: false,
+            backlog: 0,
+        };
+        assert_eq!(serde_json::to_string(&req).unwrap(), r#"{"enabled":false}"#);
+
+        let resp = SubscribeLogsResponse {
+            enabled: true,
+            lines: vec![AgentLogLine {
+                timestamp: 1_700_000_000_000,
+                level: "warn".into(),
+                target: "capydeploy_agent::handlers::upload".into(),
+                message: "install failed: disk full".into(),
+            }],
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        let parsed: SubscribeLogsResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+
+        let line: AgentLogLine =
+            serde_json::from_str(r#"{"timestamp":1,"level":"info","message":"hi"}"#).unwrap();
+        assert!(line.target.is_empty());
+    }
+
+    #[test]
+    fn applied_artwork_roundtrip() {
+        let resp = AppliedArtworkResponse {
+            app_id: 42,
+            images: vec![
+                AppliedArtwork {
+                    artwork_type: "hero".into(),
+                    content_type: "image/png".into(),
+                    size: 5,
+                    data: b"Hello".to_vec(),
+                    too_large: false,
+                },
+                AppliedArtwork {
+                    artwork_type: "portrait".into(),
+                    content_type: "image/webp".into(),
+                    size: 9_000_000,
+                    data: Vec::new(),
+                    too_large: true,
+                },
+            ],
+            missing: vec!["logo".into()],
+        };
+        let json = serde_json::to_string(&resp).unwrap();
+        assert!(json.contains(r#""data":"SGVsbG8=""#));
+        assert!(json.contains(r#""tooLarge":true"#));
+        let parsed: AppliedArtworkResponse = serde_json::from_str(&json).unwrap();
+        assert_eq!(resp, parsed);
+
+        let empty: AppliedArtworkResponse = serde_json::from_str(r#"{"appId":7}"#).unwrap();
+        assert!(empty.images.is_empty() && empty.missing.is_empty());
     }
 
     #[test]
@@ -0,0 +1,150 @@
+//! Portable shortcut definitions for sharing between Hubs.
+//!
+//! An exported shortcut carries only what a teammate needs to recreate
+//! it: the executable relative to the game folder, launch options with the
+//! game folder replaced by the `{installdir}` template variable, tags and
+//! the compat tool. Absolute paths from the exporting device are dropped.
+
+use serde::{Deserialize, Serialize};
+
+use crate::types::ShortcutInfo;
+
+/// Current [`PortableShortcut`] format version.
+pub const PORTABLE_SHORTCUT_VERSION: u32 = 1;
+
+/// Launch option variable standing for the game folder.
+const INSTALLDIR_VAR: &str = "{installdir}";
+
+/// A shortcut definition without device-specific paths.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PortableShortcut {
+    pub version: u32,
+    pub name: String,
+    /// Executable path relative to the game folder, with `/` separators.
+    pub executable: String,
+    /// Launch options as a Hub template (`{installdir}`, escaped braces).
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub launch_options: String,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub tags: Vec<String>,
+    /// Steam compat tool internal name, e.g. `proton_experimental`.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub compat_tool: String,
+}
+
+impl PortableShortcut {
+    /// Builds the portable form of an installed shortcut.
+    ///
+    /// The game folder is the shortcut's start dir. An executable outside
+    /// it is reduced to its file name.
+    pub fn from_shortcut(info: &ShortcutInfo, compat_tool: &str) -> Self {
+        let exe = info.exe.trim_matches('"').replace('\\', "/");
+        let start_dir = info.start_dir.trim_matches('"').replace('\\', "/");
+        let start_dir = start_dir.trim_end_matches('/');
+
+        let executable = match exe.strip_prefix(start_dir) {
+            Some(rest) if !start_dir.is_empty() && rest.starts_with('/') => {
+                rest.trim_start_matches('/').to_string()
+            }
+            _ => exe.rsplit('/').next().unwrap_or_default().to_string(),
+        };
+
+        Self {
+            version: PORTABLE_SHORTCUT_VERSION,
+            name: info.name.clone(),
+            executable,
+            launch_options: template_launch_options(&info.launch_options, &info.start_dir),
+            tags: info.tags.clone(),
+            compat_tool: compat_tool.to_string(),
+        }
+    }
+}
+
+/// Escapes literal braces, then replaces the game folder with
+/// `{installdir}`. The folder is matched with either separator style.
+fn template_launch_options(options: &str, start_dir: &str) -> String {
+    let escaped = options.replace('{', "{{").replace('}', "}}");
+    let dir = start_dir.trim_matches('"').trim_end_matches(['/', '\\']);
+    if dir.is_empty() {
+        return escaped;
+    }
+    let alt = if dir.contains('\\') {
+        dir.replace('\\', "/")
+    } else {
+        dir.replace('/', "\\")
+    };
+    escaped
+        .replace(dir, INSTALLDIR_VAR)
+        .replace(&alt, INSTALLDIR_VAR)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn shortcut(exe: &str, start_dir: &str, launch_options: &str) -> ShortcutInfo {
+        ShortcutInfo {
+            app_id: 3_000_000_001,
+            name: "Celeste".into(),
+            exe: exe.into(),
+            start_dir: start_dir.into(),
+            launch_options: launch_options.into(),
+            tags: vec!["Platformer".into()],
+            last_played: 1_700_000_000,
+            needs_compat_tool: false,
+            size_bytes: 0,
+            broken: false,
+        }
+    }
+
+    #[test]
+    fn strips_device_paths() {
+        let info = shortcut(
+            "\"/home/deck/Games/Celeste/bin/Celeste.exe\"",
+            "\"/home/deck/Games/Celeste/\"",
+            "PROTON_LOG=1 %command% --data /home/deck/Games/Celeste/data",
+        );
+        let p = PortableShortcut::from_shortcut(&info, "proton_experimental");
+
+        assert_eq!(p.version, PORTABLE_SHORTCUT_VERSION);
+        assert_eq!(p.executable, "bin/Celeste.exe");
+        assert_eq!(
+            p.launch_options,
+            "PROTON_LOG=1 %command% --data {installdir}/data"
+        );
+        assert_eq!(p.compat_tool, "proton_experimental");
+
+        let json = serde_json::to_string(&p).unwrap();
+        assert!(!json.contains("/home/deck"));
+        assert!(!json.contains("appId"));
+        let parsed: PortableShortcut = serde_json::from_str(&json).unwrap();
+        assert_eq!(parsed, p);
+    }
+
+    #[test]
+    fn windows_paths_and_literal_braces() {
+        let info = shortcut(
+            r"C:\Games\Celeste\Celeste.exe",
+            r"C:\Games\Celeste",
+            r"-cfg {fast} -log C:\Games\Celeste\log.txt",
+        );
+        let p = PortableShortcut::from_shortcut(&info, "");
+        assert_eq!(p.executable, "Celeste.exe");
+        assert_eq!(p.launch_options, r"-cfg {{fast}} -log {installdir}\log.txt");
+    }
+
+    #[test]
+    fn exe_outside_start_dir_keeps_file_name() {
+        let info = shortcut("/usr/bin/gamescope-game", "/home/deck/Games/Other", "");
+        let p = PortableShortcut::from_shortcut(&info, "");
+        assert_eq!(p.executable, "gamescope-game");
+
+        // A sibling folder sharing a prefix is not "inside".
+        let info = shortcut("/games/Celeste2/run.sh", "/games/Celeste", "");
+        assert_eq!(
+            PortableShortcut::from_shortcut(&info, "").executable,
+            "run.sh"
+        );
+    }
+}
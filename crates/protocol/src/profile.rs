@@ -0,0 +1,272 @@
+//! Effective protocol profile negotiated during the handshake.
+//!
+//! Each side advertises what it supports — protocol version, binary
+//! framing version, compression codecs, optional capabilities and the
+//! largest frame it reads — in `hub_connected` and `agent_status`. Both
+//! sides then compute the same [`ProtocolProfile`] from the two offers and
+//! consult it instead of checking individual fields, so a new feature only
+//! needs an entry in the offer and a rule in [`ProtocolProfile::negotiate`].
+
+use std::collections::BTreeSet;
+
+use crate::constants::{
+    CAPABILITY_FILE_BROWSER, CAPABILITY_TCP_DATA_CHANNEL, PROTOCOL_VERSION, ProtocolCompatibility,
+    binary_frame_limit, check_protocol_compatibility,
+};
+use crate::messages::{AgentStatusResponse, HubConnectedRequest};
+
+/// Binary frame layout this build speaks: a 4-byte big-endian header
+/// length, the JSON header, then the payload.
+pub const BINARY_FRAMING_VERSION: u32 = 1;
+
+/// Compression codecs this build can apply to binary payloads. None yet;
+/// payloads are sent as-is.
+pub const SUPPORTED_COMPRESSION: &[&str] = &[];
+
+/// Capabilities a peer is assumed to have when it advertises none. Builds
+/// from before the profile exchange supported these unconditionally.
+pub const LEGACY_CAPABILITIES: &[&str] = &[CAPABILITY_TCP_DATA_CHANNEL, CAPABILITY_FILE_BROWSER];
+
+/// What one side of a connection supports.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct ProtocolOffer {
+    /// Protocol version (0 = legacy, treated as v1).
+    pub version: u32,
+    /// Highest binary framing version (0 = legacy, treated as v1).
+    pub framing_version: u32,
+    /// Supported compression codecs.
+    pub compression: Vec<String>,
+    /// Optional capabilities. Empty means [`LEGACY_CAPABILITIES`].
+    pub capabilities: Vec<String>,
+    /// Largest WebSocket frame this side reads (0 = the default limit).
+    pub max_binary_frame_size: u64,
+}
+
+impl ProtocolOffer {
+    /// This build's offer with the given capabilities and frame limit.
+    pub fn local(capabilities: &[&str], max_binary_frame_size: u64) -> Self {
+        Self {
+            version: PROTOCOL_VERSION,
+            framing_version: BINARY_FRAMING_VERSION,
+            compression: SUPPORTED_COMPRESSION
+                .iter()
+                .map(|c| c.to_string())
+                .collect(),
+            capabilities: capabilities.iter().map(|c| c.to_string()).collect(),
+            max_binary_frame_size,
+        }
+    }
+
+    /// The Hub's offer, as carried in `hub_connected`.
+    pub fn from_hub(req: &HubConnectedRequest) -> Self {
+        Self {
+            version: req.protocol_version,
+            framing_version: req.framing_version,
+            compression: req.compression.clone(),
+            capabilities: req.capabilities.clone(),
+            max_binary_frame_size: req.max_binary_frame_size,
+        }
+    }
+
+    /// The Agent's offer, as carried in `agent_status`.
+    pub fn from_agent(status: &AgentStatusResponse) -> Self {
+        Self {
+            version: status.protocol_version,
+            framing_version: status.framing_version,
+            compression: status.compression.clone(),
+            capabilities: status.capabilities.clone(),
+            max_binary_frame_size: status.max_binary_frame_size,
+        }
+    }
+
+    fn effective_capabilities(&self) -> BTreeSet<&str> {
+        if self.capabilities.is_empty() {
+            LEGACY_CAPABILITIES.iter().copied().collect()
+        } else {
+            self.capabilities.iter().map(String::as_str).collect()
+        }
+    }
+}
+
+/// Settings both sides agreed on for one connection.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ProtocolProfile {
+    /// Protocol version in use: the lower of the two.
+    pub version: u32,
+    /// Binary framing version in use: the lower of the two.
+    pub framing_version: u32,
+    /// Compression codec for binary payloads, if both support one.
+    pub compression: Option<String>,
+    /// Capabilities both sides have.
+    pub capabilities: BTreeSet<String>,
+    /// Largest frame either side may send, in bytes: the smaller limit.
+    pub max_binary_frame_size: usize,
+}
+
+impl Default for ProtocolProfile {
+    /// The profile of two legacy peers, used until a handshake completes.
+    fn default() -> Self {
+        Self::negotiate(&ProtocolOffer::default(), &ProtocolOffer::default())
+            .expect("legacy offers are compatible")
+    }
+}
+
+impl ProtocolProfile {
+    /// Computes the common-denominator profile of `local` and `peer`.
+    ///
+    /// The result is the same whichever side calls it. Fails with the
+    /// reason when the peer's protocol version is unsupported.
+    pub fn negotiate(local: &ProtocolOffer, peer: &ProtocolOffer) -> Result<Self, String> {
+        if let ProtocolCompatibility::Incompatible { reason, .. } =
+            check_protocol_compatibility(peer.version)
+        {
+            return Err(reason);
+        }
+        let legacy_one = |v: u32| v.max(1);
+
+        let peer_caps = peer.effective_capabilities();
+        let capabilities = local
+            .effective_capabilities()
+            .intersection(&peer_caps)
+            .map(|c| c.to_string())
+            .collect();
+
+        // The choice must not depend on which side computes it, so take
+        // the lowest-sorting shared codec rather than either preference.
+        let compression = local
+            .compression
+            .iter()
+            .filter(|c| peer.compression.contains(c))
+            .min()
+            .cloned();
+
+        Ok(Self {
+            version: legacy_one(local.version).min(legacy_one(peer.version)),
+            framing_version: legacy_one(local.framing_version)
+                .min(legacy_one(peer.framing_version)),
+            compression,
+            capabilities,
+            max_binary_frame_size: binary_frame_limit(local.max_binary_frame_size)
+                .min(binary_frame_limit(peer.max_binary_frame_size)),
+        })
+    }
+
+    /// Whether both sides advertised `capability`.
+    pub fn supports(&self, capability: &str) -> bool {
+        self.capabilities.contains(capability)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::constants::{WS_MAX_MESSAGE_SIZE, WS_MIN_BINARY_FRAME_SIZE};
+
+    fn offer(version: u32, framing: u32, caps: &[&str], frame: u64) -> ProtocolOffer {
+        ProtocolOffer {
+            version,
+            framing_version: framing,
+            compression: vec![],
+            capabilities: caps.iter().map(|c| c.to_string()).collect(),
+            max_binary_frame_size: frame,
+        }
+    }
+
+    #[test]
+    fn common_denominator_of_mismatched_offers() {
+        let hub = offer(1, 2, &[CAPABILITY_TCP_DATA_CHANNEL, "future_thing"], 0);
+        let agent = offer(
+            1,
+            1,
+            &[CAPABILITY_TCP_DATA_CHANNEL, CAPABILITY_FILE_BROWSER],
+            WS_MIN_BINARY_FRAME_SIZE as u64,
+        );
+
+        let profile = ProtocolProfile::negotiate(&hub, &agent).unwrap();
+        assert_eq!(profile.version, 1);
+        assert_eq!(profile.framing_version, 1);
+        assert_eq!(profile.compression, None);
+        assert!(profile.supports(CAPABILITY_TCP_DATA_CHANNEL));
+        assert!(!profile.supports(CAPABILITY_FILE_BROWSER));
+        assert!(!profile.supports("future_thing"));
+        assert_eq!(profile.max_binary_frame_size, WS_MIN_BINARY_FRAME_SIZE);
+
+        // Both sides arrive at the same profile.
+        assert_eq!(ProtocolProfile::negotiate(&agent, &hub).unwrap(), profile);
+    }
+
+    #[test]
+    fn legacy_peer_gets_baseline_capabilities() {
+        let local = ProtocolOffer::local(&[CAPABILITY_TCP_DATA_CHANNEL, "future_thing"], 0);
+        let legacy = ProtocolOffer::default();
+
+        let profile = ProtocolProfile::negotiate(&local, &legacy).unwrap();
+        assert_eq!(profile.version, 1);
+        assert_eq!(profile.framing_version, 1);
+        assert!(profile.supports(CAPABILITY_TCP_DATA_CHANNEL));
+        assert!(!profile.supports("future_thing"));
+        assert_eq!(profile.max_binary_frame_size, WS_MAX_MESSAGE_SIZE);
+
+        let baseline = ProtocolProfile::default();
+        assert!(baseline.supports(CAPABILITY_TCP_DATA_CHANNEL));
+        assert!(baseline.supports(CAPABILITY_FILE_BROWSER));
+    }
+
+    #[test]
+    fn picks_shared_compression_regardless_of_caller() {
+        let mut a = offer(1, 1, &[], 0);
+        a.compression = vec!["zstd".into(), "lz4".into()];
+        let mut b = offer(1, 1, &[], 0);
+        b.compression = vec!["lz4".into(), "zstd".into(), "gzip".into()];
+
+        let ab = ProtocolProfile::negotiate(&a, &b).unwrap();
+        let ba = ProtocolProfile::negotiate(&b, &a).unwrap();
+        assert_eq!(ab.compression.as_deref(), Some("lz4"));
+        assert_eq!(ab, ba);
+
+        b.compression = vec!["gzip".into()];
+        assert_eq!(
+            ProtocolProfile::negotiate(&a, &b).unwrap().compression,
+            None
+        );
+    }
+
+    #[test]
+    fn rejects_unsupported_peer_version() {
+        let local = ProtocolOffer::local(&[], 0);
+        let future = offer(PROTOCOL_VERSION + 1, 1, &[], 0);
+        assert!(ProtocolProfile::negotiate(&local, &future).is_err());
+    }
+
+    #[test]
+    fn offers_round_trip_through_handshake_messages() {
+        let req = HubConnectedRequest {
+            name: "Hub".into(),
+            version: "1.0".into(),
+            platform: String::new(),
+            hub_id: "h".into(),
+            token: String::new(),
+            protocol_version: PROTOCOL_VERSION,
+            capabilities: vec![CAPABILITY_TCP_DATA_CHANNEL.into()],
+            framing_version: BINARY_FRAMING_VERSION,
+            compression: vec![],
+            max_binary_frame_size: 8 * 1024 * 1024,
+        };
+        let json = serde_json::to_string(&req).unwrap();
+        let back: HubConnectedRequest = serde_json::from_str(&json).unwrap();
+        let hub = ProtocolOffer::from_hub(&back);
+        assert_eq!(hub.capabilities, [CAPABILITY_TCP_DATA_CHANNEL]);
+        assert_eq!(hub.framing_version, BINARY_FRAMING_VERSION);
+        assert_eq!(hub.max_binary_frame_size, 8 * 1024 * 1024);
+
+        // A pre-profile Hub omits the new fields entirely.
+        let legacy: HubConnectedRequest =
+            serde_json::from_str(r#"{"name":"Old","version":"0.9"}"#).unwrap();
+        let old = ProtocolOffer::from_hub(&legacy);
+        assert!(old.capabilities.is_empty() && old.framing_version == 0);
+        let profile =
+            ProtocolProfile::negotiate(&ProtocolOffer::local(LEGACY_CAPABILITIES, 0), &old)
+                .unwrap();
+        assert!(profile.supports(CAPABILITY_TCP_DATA_CHANNEL));
+    }
+}
@@ -1,5 +1,7 @@
 use serde::{Deserialize, Serialize};
 
+use crate::constants::DEFAULT_MAX_CONCURRENT_UPLOADS;
+
 /// Information about a discovered agent.
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -11,6 +13,58 @@ pub struct AgentInfo {
     pub accept_connections: bool,
     #[serde(default, skip_serializing_if = "Vec::is_empty")]
     pub supported_image_formats: Vec<String>,
+    /// Steam account state, one of the `STEAM_LOGIN_*` constants. Empty for
+    /// agents that don't report it.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub steam_login_state: String,
+    /// Uploads the agent accepts at once; `init_upload` beyond this is
+    /// rejected with a conflict. Older agents are assumed to take one.
+    #[serde(default = "default_max_concurrent_uploads")]
+    pub max_concurrent_uploads: u32,
+    /// Verbose logging is on (see `set_verbose`).
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub verbose: bool,
+    /// The agent's `PROTOCOL_VERSION`, so a Hub can tell an outdated agent
+    /// apart before relying on newer messages. 0 for agents that predate
+    /// the field.
+    #[serde(default, skip_serializing_if = "is_zero_u32")]
+    pub protocol_version: u32,
+    /// When the agent's server started listening, in Unix milliseconds, so
+    /// a Hub can show its uptime. 0 for agents that don't report it.
+    #[serde(default, skip_serializing_if = "is_zero_i64")]
+    pub started_at: i64,
+    /// Why the server last started, one of the `AGENT_START_*` constants.
+    /// Empty for agents that don't report it.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub start_reason: String,
+}
+
+fn default_max_concurrent_uploads() -> u32 {
+    DEFAULT_MAX_CONCURRENT_UPLOADS
+}
+
+/// The identifying subset of [`AgentInfo`], for frequent polling where
+/// capabilities and Steam state aren't needed.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AgentInfoLite {
+    pub id: String,
+    pub name: String,
+    pub platform: String,
+    pub version: String,
+    pub accept_connections: bool,
+}
+
+impl From<&AgentInfo> for AgentInfoLite {
+    fn from(info: &AgentInfo) -> Self {
+        Self {
+            id: info.id.clone(),
+            name: info.name.clone(),
+            platform: info.platform.clone(),
+            version: info.version.clone(),
+            accept_connections: info.accept_connections,
+        }
+    }
 }
 
 /// Configuration for uploading a game.
@@ -24,6 +78,23 @@ pub struct UploadConfig {
     pub launch_options: String,
     #[serde(default, skip_serializing_if = "String::is_empty")]
     pub tags: String,
+    /// Steam library root to install into instead of `install_path`. The
+    /// Agent installs under its `Games` folder and registers the library
+    /// with Steam if needed.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub library_path: String,
+    /// Relative subfolder of the install path (or library) that the game
+    /// folder is created in. It must not leave the install path.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub install_subpath: String,
+    /// Replace a Steam shortcut with the same name or executable instead
+    /// of having the Agent refuse the upload with a conflict.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub overwrite: bool,
+    /// Remove the existing game folder before writing, instead of merging
+    /// the new files into it.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub clean_install: bool,
 }
 
 /// Configuration for creating a Steam shortcut.
@@ -39,6 +110,22 @@ pub struct ShortcutConfig {
     pub tags: Vec<String>,
     #[serde(default, skip_serializing_if = "Option::is_none")]
     pub artwork: Option<ArtworkConfig>,
+    /// Boot video (.webm) path relative to the install directory. The Agent
+    /// offers it to Steam as a startup movie once the shortcut exists.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub boot_video: String,
+    /// Bump the new shortcut's last-played time so it sorts first in
+    /// Steam's Recent view. Best effort; the Agent warns when it can't.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub mark_recent: bool,
+    /// Also write artwork for every other Steam account on the device that
+    /// has this shortcut, not just the one it was created under.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub artwork_all_users: bool,
+    /// Steam compatibility tool to force for the shortcut, e.g.
+    /// `proton_experimental`. Empty lets the Agent pick its default.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub compat_tool: String,
 }
 
 /// Artwork paths for a shortcut.
@@ -70,12 +157,33 @@ pub struct ShortcutInfo {
     pub tags: Vec<String>,
     #[serde(default, skip_serializing_if = "is_zero_i64")]
     pub last_played: i64,
+    /// True when the exe is a Windows binary on Linux with no compat tool assigned.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub needs_compat_tool: bool,
+    /// Size of the game folder (`start_dir`) in bytes; 0 when unknown.
+    #[serde(default, skip_serializing_if = "is_zero_u64")]
+    pub size_bytes: u64,
+    /// True when the shortcut's executable no longer exists on disk.
+    #[serde(default, skip_serializing_if = "is_false")]
+    pub broken: bool,
 }
 
 fn is_zero_i64(v: &i64) -> bool {
     *v == 0
 }
 
+fn is_zero_u64(v: &u64) -> bool {
+    *v == 0
+}
+
+fn is_zero_u32(v: &u32) -> bool {
+    *v == 0
+}
+
+fn is_false(v: &bool) -> bool {
+    !v
+}
+
 /// Current state of an upload.
 #[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
 pub enum UploadStatus {
@@ -120,6 +228,7 @@ impl UploadProgress {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::constants::{AGENT_START_INITIAL, STEAM_LOGIN_OFFLINE};
 
     #[test]
     fn agent_info_json_roundtrip() {
@@ -130,10 +239,96 @@ mod tests {
             version: "0.6.0".into(),
             accept_connections: true,
             supported_image_formats: vec!["png".into(), "jpg".into()],
+            steam_login_state: STEAM_LOGIN_OFFLINE.into(),
+            max_concurrent_uploads: 2,
+            verbose: true,
+            protocol_version: 1,
+            started_at: 1_700_000_000_000,
+            start_reason: AGENT_START_INITIAL.into(),
         };
         let json = serde_json::to_string(&info).unwrap();
+        assert!(json.contains(r#""steamLoginState":"offline""#));
+        assert!(json.contains(r#""maxConcurrentUploads":2"#));
+        assert!(json.contains(r#""verbose":true"#));
+        assert!(json.contains(r#""protocolVersion":1"#));
+        assert!(json.contains(r#""startedAt":1700000000000"#));
+        assert!(json.contains(r#""startReason":"initial""#));
         let parsed: AgentInfo = serde_json::from_str(&json).unwrap();
         assert_eq!(info, parsed);
+
+        // Older agents omit the field.
+        let legacy: AgentInfo = serde_json::from_str(
+            r#"{"id":"a","name":"A","platform":"linux","version":"0.5.0","acceptConnections":true}"#,
+        )
+        .unwrap();
+        assert!(legacy.steam_login_state.is_empty());
+        assert!(!legacy.verbose);
+        assert_eq!(legacy.protocol_version, 0);
+        assert_eq!(legacy.started_at, 0);
+        assert!(legacy.start_reason.is_empty());
+        assert_eq!(
+            legacy.max_concurrent_uploads,
+            DEFAULT_MAX_CONCURRENT_UPLOADS
+        );
+    }
+
+    #[test]
+    fn agent_info_lite_omits_heavy_fields() {
+        let info = AgentInfo {
+            id: "test-id".into(),
+            name: "Test Agent".into(),
+            platform: "linux".into(),
+            version: "0.6.0".into(),
+            accept_connections: true,
+            supported_image_formats: vec!["png".into(), "webp".into()],
+            steam_login_state: STEAM_LOGIN_OFFLINE.into(),
+            max_concurrent_uploads: 2,
+            verbose: false,
+            protocol_version: 0,
+            started_at: 0,
+            start_reason: String::new(),
+        };
+        let full = serde_json::to_string(&info).unwrap();
+        assert!(full.contains("supportedImageFormats"));
+        assert!(full.contains("steamLoginState"));
+
+        let lite = AgentInfoLite::from(&info);
+        let json = serde_json::to_string(&lite).unwrap();
+        assert!(!json.contains("supportedImageFormats"));
+        assert!(!json.contains("steamLoginState"));
+        assert!(json.contains(r#""acceptConnections":true"#));
+
+        // A full info payload parses as lite (unknown fields are ignored).
+        let parsed: AgentInfoLite = serde_json::from_str(&full).unwrap();
+        assert_eq!(parsed, lite);
+    }
+
+    #[test]
+    fn shortcut_config_mark_recent() {
+        let json = r#"{"name":"Game","exe":"game.sh","startDir":"/games/g","markRecent":true}"#;
+        let cfg: ShortcutConfig = serde_json::from_str(json).unwrap();
+        assert!(cfg.mark_recent);
+        assert!(
+            serde_json::to_string(&cfg)
+                .unwrap()
+                .contains("\"markRecent\":true")
+        );
+
+        // Omitted when false; older Hubs never send it.
+        let plain = ShortcutConfig {
+            mark_recent: false,
+            ..cfg
+        };
+        assert!(
+            !serde_json::to_string(&plain)
+                .unwrap()
+                .contains("markRecent")
+        );
+        let legacy: ShortcutConfig =
+            serde_json::from_str(r#"{"name":"Game","exe":"game.sh","startDir":""}"#).unwrap();
+        assert!(!legacy.mark_recent);
+        assert!(!legacy.artwork_all_users);
+        assert!(legacy.compat_tool.is_empty());
     }
 
     #[test]
@@ -146,11 +341,22 @@ mod tests {
             launch_options: String::new(),
             tags: vec![],
             last_played: 0,
+            needs_compat_tool: false,
+            size_bytes: 0,
+            broken: false,
         };
         let json = serde_json::to_string(&info).unwrap();
         assert!(!json.contains("launchOptions"));
         assert!(!json.contains("tags"));
         assert!(!json.contains("lastPlayed"));
+        assert!(!json.contains("needsCompatTool"));
+
+        let flagged = ShortcutInfo {
+            needs_compat_tool: true,
+            ..info
+        };
+        let json = serde_json::to_string(&flagged).unwrap();
+        assert!(json.contains("\"needsCompatTool\":true"));
     }
 
     #[test]
@@ -203,9 +409,17 @@ mod tests {
             executable: "game.exe".into(),
             launch_options: String::new(),
             tags: String::new(),
+            library_path: String::new(),
+            install_subpath: String::new(),
+            overwrite: false,
+            clean_install: false,
         };
         let json = serde_json::to_string(&cfg).unwrap();
         assert!(!json.contains("launchOptions"));
         assert!(!json.contains("tags"));
+        assert!(!json.contains("libraryPath"));
+        assert!(!json.contains("installSubpath"));
+        assert!(!json.contains("overwrite"));
+        assert!(!json.contains("cleanInstall"));
     }
 }
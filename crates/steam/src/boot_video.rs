@@ -0,0 +1,224 @@
+//! Boot (startup) videos for deployed games.
+//!
+//! Steam's gamepad UI plays one startup movie, picked under
+//! Settings > Customization from the `.webm` files in
+//! `<steam>/config/uioverrides/movies`. There is no per-game slot, so a
+//! game's video is installed there under a name derived from its AppID:
+//! it becomes selectable in Steam and can be removed with the game.
+//! Anything Steam can't play is skipped with a warning rather than failing
+//! the deploy.
+
+use std::fs;
+use std::io::Read;
+use std::path::{Path, PathBuf};
+
+use crate::SteamError;
+use crate::paths::Paths;
+
+/// Largest boot video accepted (32 MB); Steam loads the whole file at boot.
+pub const BOOT_VIDEO_MAX_SIZE: u64 = 32 * 1024 * 1024;
+
+/// The only container Steam plays as a startup movie.
+pub const BOOT_VIDEO_EXTENSION: &str = "webm";
+
+/// EBML magic that starts every WebM (Matroska) file.
+const WEBM_MAGIC: [u8; 4] = [0x1A, 0x45, 0xDF, 0xA3];
+
+/// Result of [`install_boot_video`].
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum BootVideoOutcome {
+    /// The video was copied to this path.
+    Installed(PathBuf),
+    /// The video was not installed; the string explains why.
+    Skipped(String),
+}
+
+/// Checks that `path` is a boot video Steam can play.
+///
+/// Returns the file size, or a human-readable reason it is unsupported.
+pub fn check_boot_video(path: &Path) -> Result<u64, String> {
+    let ext = path
+        .extension()
+        .map(|e| e.to_string_lossy().to_ascii_lowercase())
+        .unwrap_or_default();
+    if ext != BOOT_VIDEO_EXTENSION {
+        let shown = if ext.is_empty() {
+            "no extension".to_string()
+        } else {
+            format!(".{ext}")
+        };
+        return Err(format!(
+            "unsupported boot video format ({shown}): Steam only plays .{BOOT_VIDEO_EXTENSION} startup movies"
+        ));
+    }
+
+    let size = fs::metadata(path)
+        .map_err(|e| format!("boot video {} not readable: {e}", path.display()))?
+        .len();
+    if size == 0 {
+        return Err(format!("boot video {} is empty", path.display()));
+    }
+    if size > BOOT_VIDEO_MAX_SIZE {
+        return Err(format!(
+            "boot video is {} MB, larger than the {} MB limit",
+            size / (1024 * 1024),
+            BOOT_VIDEO_MAX_SIZE / (1024 * 1024)
+        ));
+    }
+
+    let mut magic = [0u8; 4];
+    fs::File::open(path)
+        .and_then(|mut f| f.read_exact(&mut magic))
+        .map_err(|e| format!("boot video {} not readable: {e}", path.display()))?;
+    if magic != WEBM_MAGIC {
+        return Err(format!(
+            "boot video {} is not a valid WebM file",
+            path.display()
+        ));
+    }
+
+    Ok(size)
+}
+
+/// Installs `source` as the boot video for `app_id`.
+///
+/// Unsupported videos return [`BootVideoOutcome::Skipped`]; only I/O
+/// failures while copying a valid video are errors.
+pub fn install_boot_video(
+    paths: &Paths,
+    app_id: u32,
+    source: &Path,
+) -> Result<BootVideoOutcome, SteamError> {
+    if let Err(reason) = check_boot_video(source) {
+        return Ok(BootVideoOutcome::Skipped(reason));
+    }
+
+    fs::create_dir_all(paths.boot_video_dir())
+        .map_err(|e| SteamError::Io(format!("failed to create movies dir: {e}")))?;
+    let dest = paths.boot_video_path(app_id);
+    fs::copy(source, &dest)
+        .map_err(|e| SteamError::Io(format!("failed to copy boot video: {e}")))?;
+    Ok(BootVideoOutcome::Installed(dest))
+}
+
+/// Removes the boot video installed for `app_id`. Returns false if none existed.
+pub fn remove_boot_video(paths: &Paths, app_id: u32) -> Result<bool, SteamError> {
+    match fs::remove_file(paths.boot_video_path(app_id)) {
+        Ok(()) => Ok(true),
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(false),
+        Err(e) => Err(SteamError::Io(format!("failed to remove boot video: {e}"))),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn temp_dir(name: &str) -> PathBuf {
+        let dir = std::env::temp_dir().join(format!("capydeploy_bootvideo_{name}"));
+        let _ = fs::remove_dir_all(&dir);
+        fs::create_dir_all(&dir).unwrap();
+        dir
+    }
+
+    fn webm(size: usize) -> Vec<u8> {
+        let mut data = WEBM_MAGIC.to_vec();
+        data.resize(size.max(WEBM_MAGIC.len()), 0);
+        data
+    }
+
+    #[test]
+    fn boot_video_path_is_under_uioverrides_movies() {
+        let paths = Paths::with_base("/home/deck/.steam/steam");
+        assert_eq!(
+            paths.boot_video_path(3000000001),
+            PathBuf::from(
+                "/home/deck/.steam/steam/config/uioverrides/movies/capydeploy_3000000001.webm"
+            )
+        );
+    }
+
+    #[test]
+    fn install_copies_video_into_movies_dir() {
+        let tmp = temp_dir("install");
+        let paths = Paths::with_base(tmp.join("steam"));
+        let src = tmp.join("intro.WebM");
+        fs::write(&src, webm(1024)).unwrap();
+
+        let outcome = install_boot_video(&paths, 42, &src).unwrap();
+
+        let dest = paths.boot_video_path(42);
+        assert_eq!(outcome, BootVideoOutcome::Installed(dest.clone()));
+        assert_eq!(fs::read(&dest).unwrap(), webm(1024));
+
+        assert!(remove_boot_video(&paths, 42).unwrap());
+        assert!(!dest.exists());
+        assert!(!remove_boot_video(&paths, 42).unwrap());
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn unsupported_format_is_skipped_with_warning() {
+        let tmp = temp_dir("unsupported");
+        let paths = Paths::with_base(tmp.join("steam"));
+        let src = tmp.join("intro.mp4");
+        fs::write(&src, b"\x00\x00\x00\x18ftypmp42").unwrap();
+
+        match install_boot_video(&paths, 42, &src).unwrap() {
+            BootVideoOutcome::Skipped(reason) => {
+                assert!(
+                    reason.contains("unsupported boot video format (.mp4)"),
+                    "{reason}"
+                );
+            }
+            other => panic!("expected skip, got {other:?}"),
+        }
+        assert!(!paths.boot_video_dir().exists());
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn check_rejects_bad_videos() {
+        let tmp = temp_dir("check");
+
+        let fake = tmp.join("fake.webm");
+        fs::write(&fake, b"not a video").unwrap();
+        assert!(
+            check_boot_video(&fake)
+                .unwrap_err()
+                .contains("not a valid WebM")
+        );
+
+        let empty = tmp.join("empty.webm");
+        fs::write(&empty, b"").unwrap();
+        assert!(check_boot_video(&empty).unwrap_err().contains("empty"));
+
+        let missing = tmp.join("missing.webm");
+        assert!(
+            check_boot_video(&missing)
+                .unwrap_err()
+                .contains("not readable")
+        );
+
+        let no_ext = tmp.join("intro");
+        fs::write(&no_ext, webm(16)).unwrap();
+        assert!(
+            check_boot_video(&no_ext)
+                .unwrap_err()
+                .contains("no extension")
+        );
+
+        let big = tmp.join("big.webm");
+        let f = fs::File::create(&big).unwrap();
+        f.set_len(BOOT_VIDEO_MAX_SIZE + 1).unwrap();
+        assert!(check_boot_video(&big).unwrap_err().contains("limit"));
+
+        let ok = tmp.join("ok.webm");
+        fs::write(&ok, webm(64)).unwrap();
+        assert_eq!(check_boot_video(&ok).unwrap(), 64);
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+}
@@ -2,6 +2,12 @@
 //!
 //! Connects to Steam's CEF debug endpoint at `localhost:8080`, finds the
 //! `SharedJSContext` or `SP` tab, and evaluates JavaScript via WebSocket.
+//!
+//! Port 8080 is popular with dev servers. When something other than Steam
+//! answers there, the tab discovery fails with
+//! [`SteamError::CefPortConflict`] instead of a confusing parse error. The
+//! port can be overridden with the `CAPYDEPLOY_CEF_PORT` environment
+//! variable, e.g. when Steam's debugger is forwarded elsewhere.
 
 use std::time::Duration;
 
@@ -11,8 +17,11 @@ use tokio_tungstenite::tungstenite::protocol::Message as WsMessage;
 
 use crate::SteamError;
 
-/// CEF debug HTTP endpoint.
-const CEF_HTTP_ENDPOINT: &str = "127.0.0.1:8080";
+/// Port Steam's CEF debugger listens on.
+pub const DEFAULT_CEF_PORT: u16 = 8080;
+
+/// Environment variable overriding [`DEFAULT_CEF_PORT`].
+pub const CEF_PORT_ENV: &str = "CAPYDEPLOY_CEF_PORT";
 
 /// Timeout for the HTTP tab discovery request.
 const HTTP_TIMEOUT: Duration = Duration::from_secs(5);
@@ -23,6 +32,9 @@ const WS_HANDSHAKE_TIMEOUT: Duration = Duration::from_secs(5);
 /// Read timeout for each WebSocket message.
 const WS_READ_TIMEOUT: Duration = Duration::from_secs(10);
 
+/// Times a session reconnects for one evaluation when the socket drops.
+const MAX_RECONNECTS: u32 = 2;
+
 /// CEF artwork asset type constants (match Steam's internal enum).
 pub const CEF_ASSET_GRID_PORTRAIT: i32 = 0;
 pub const CEF_ASSET_HERO: i32 = 1;
@@ -61,19 +73,26 @@ pub struct CefClient {
 }
 
 impl CefClient {
-    /// Creates a new client targeting the local Steam CEF debugger.
+    /// Creates a new client targeting the local Steam CEF debugger, on
+    /// the port from `CAPYDEPLOY_CEF_PORT` if set.
     pub fn new() -> Self {
+        Self::with_port(cef_port(std::env::var(CEF_PORT_ENV).ok().as_deref()))
+    }
+
+    /// Creates a client targeting the CEF debugger on a local `port`.
+    pub fn with_port(port: u16) -> Self {
         Self {
-            endpoint: CEF_HTTP_ENDPOINT.to_string(),
+            endpoint: format!("127.0.0.1:{port}"),
         }
     }
 
     /// Fetches the list of debuggable tabs from CEF.
+    ///
+    /// Fails with [`SteamError::CefPortConflict`] if the port is answered
+    /// by something other than Steam's debugger.
     pub async fn get_tabs(&self) -> Result<Vec<CefTab>, SteamError> {
         let raw = http_get_json(&self.endpoint).await?;
-        let tabs: Vec<CefTab> = serde_json::from_str(&raw)
-            .map_err(|e| SteamError::Cef(format!("failed to parse CEF tabs: {e}")))?;
-        Ok(tabs)
+        parse_tabs(&self.endpoint, &raw)
     }
 
     /// Finds the best tab for JS evaluation (SharedJSContext > SP).
@@ -101,11 +120,17 @@ impl CefClient {
     ///
     /// Returns the raw JSON value from the evaluation result.
     pub async fn evaluate(&self, js_expr: &str) -> Result<serde_json::Value, SteamError> {
+        let mut session = self.session().await?;
+        let value = session.evaluate(js_expr).await?;
+        session.close().await;
+        Ok(value)
+    }
+
+    /// Opens a connection to Steam's JS context for several evaluations.
+    pub async fn session(&self) -> Result<CefSession, SteamError> {
         let tabs = self.get_tabs().await?;
         let tab = Self::find_js_context(&tabs)?;
-        let ws_url = &tab.web_socket_debugger_url;
-
-        evaluate_async(ws_url, js_expr).await
+        CefSession::connect(&tab.web_socket_debugger_url).await
     }
 
     /// Evaluates JS and ignores the return value (for void operations).
@@ -126,44 +151,35 @@ impl CefClient {
         start_dir: &str,
         launch_options: &str,
     ) -> Result<u32, SteamError> {
-        let js = format!(
-            "SteamClient.Apps.AddShortcut({}, {}, {}, {})",
-            js_string(name),
-            js_string(exe),
-            js_string(start_dir),
-            js_string(launch_options),
-        );
-
-        let result = self.evaluate(&js).await?;
-
-        let app_id = result.as_f64().map(|v| v as u32).ok_or_else(|| {
-            SteamError::Cef(format!(
-                "failed to parse AddShortcut result: expected number, got {result}"
-            ))
-        })?;
+        let js = add_shortcut_js(name, exe, start_dir, launch_options);
+        parse_app_id(&self.evaluate(&js).await?)
+    }
 
-        if app_id == 0 {
-            return Err(SteamError::Cef(format!(
-                "AddShortcut returned invalid appID: {app_id}"
-            )));
+    /// Creates each of `shortcuts` over a single connection, returning the
+    /// AppID or error of each in order. Fails as a whole only when Steam
+    /// can't be reached.
+    pub async fn create_shortcuts(
+        &self,
+        shortcuts: &[NewShortcut],
+    ) -> Result<Vec<Result<u32, SteamError>>, SteamError> {
+        let mut session = self.session().await?;
+        let mut results = Vec::with_capacity(shortcuts.len());
+        for shortcut in shortcuts {
+            results.push(session.create_shortcut(shortcut).await);
         }
-
-        Ok(app_id)
+        session.close().await;
+        Ok(results)
     }
 
     /// Removes a Steam shortcut by AppID.
     pub async fn remove_shortcut(&self, app_id: u32) -> Result<(), SteamError> {
-        let js = format!("SteamClient.Apps.RemoveShortcut({app_id})");
-        self.evaluate_void(&js).await
+        self.evaluate_void(&remove_shortcut_js(app_id)).await
     }
 
     /// Renames a shortcut.
     pub async fn set_shortcut_name(&self, app_id: u32, name: &str) -> Result<(), SteamError> {
-        let js = format!(
-            "SteamClient.Apps.SetShortcutName({app_id}, {})",
-            js_string(name),
-        );
-        self.evaluate_void(&js).await
+        self.evaluate_void(&set_shortcut_name_js(app_id, name))
+            .await
     }
 
     /// Sets launch options for a shortcut.
@@ -172,11 +188,13 @@ impl CefClient {
         app_id: u32,
         options: &str,
     ) -> Result<(), SteamError> {
-        let js = format!(
-            "SteamClient.Apps.SetShortcutLaunchOptions({app_id}, {})",
-            js_string(options),
-        );
-        self.evaluate_void(&js).await
+        self.evaluate_void(&set_launch_options_js(app_id, options))
+            .await
+    }
+
+    /// Sets the working directory a shortcut starts in.
+    pub async fn set_shortcut_start_dir(&self, app_id: u32, dir: &str) -> Result<(), SteamError> {
+        self.evaluate_void(&set_start_dir_js(app_id, dir)).await
     }
 
     /// Sets the compatibility tool (e.g. Proton) for a shortcut.
@@ -185,11 +203,25 @@ impl CefClient {
         app_id: u32,
         tool_name: &str,
     ) -> Result<(), SteamError> {
-        let js = format!(
-            "SteamClient.Apps.SpecifyCompatTool({app_id}, {})",
-            js_string(tool_name),
-        );
-        self.evaluate_void(&js).await
+        self.evaluate_void(&specify_compat_tool_js(app_id, tool_name))
+            .await
+    }
+
+    /// Bumps a shortcut's last-played time so it sorts first in Recent.
+    ///
+    /// Steam exposes no setter for this; the timestamp is written on the
+    /// app overview the library UI sorts by. Returns `Ok(false)` when the
+    /// running client doesn't expose that overview.
+    pub async fn mark_recent(&self, app_id: u32) -> Result<bool, SteamError> {
+        let result = self.evaluate(&mark_recent_js(app_id, unix_now())).await?;
+        parse_bool_result(&result, "mark-recent")
+    }
+
+    /// Starts an app the way the library's Play button does. Returns
+    /// `Ok(false)` when Steam doesn't know the app.
+    pub async fn launch_app(&self, app_id: u32) -> Result<bool, SteamError> {
+        let result = self.evaluate(&launch_app_js(app_id)).await?;
+        parse_bool_result(&result, "launch")
     }
 
     /// Applies custom artwork to a Steam app.
@@ -199,14 +231,12 @@ impl CefClient {
         base64_data: &str,
         asset_type: i32,
     ) -> Result<(), SteamError> {
-        // Clear first, then set (matching Go behavior).
-        self.clear_custom_artwork(app_id, asset_type).await?;
-
-        let js = format!(
-            "SteamClient.Apps.SetCustomArtworkForApp({app_id}, {}, \"png\", {asset_type})",
-            js_string(base64_data),
-        );
-        self.evaluate_void(&js).await
+        let mut session = self.session().await?;
+        let result = session
+            .set_custom_artwork(app_id, base64_data, asset_type)
+            .await;
+        session.close().await;
+        result
     }
 
     /// Clears custom artwork from a Steam app.
@@ -215,8 +245,8 @@ impl CefClient {
         app_id: u32,
         asset_type: i32,
     ) -> Result<(), SteamError> {
-        let js = format!("SteamClient.Apps.ClearCustomArtworkForApp({app_id}, {asset_type})");
-        self.evaluate_void(&js).await
+        self.evaluate_void(&clear_custom_artwork_js(app_id, asset_type))
+            .await
     }
 }
 
@@ -226,10 +256,389 @@ impl Default for CefClient {
     }
 }
 
+/// A shortcut to create with [`CefSession::create_shortcut`].
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct NewShortcut {
+    pub name: String,
+    pub exe: String,
+    pub start_dir: String,
+    pub launch_options: String,
+    /// Compatibility tool to assign, e.g. `proton_experimental`. Empty for
+    /// none.
+    pub compat_tool: String,
+}
+
+type CefStream =
+    tokio_tungstenite::WebSocketStream<tokio_tungstenite::MaybeTlsStream<tokio::net::TcpStream>>;
+
+/// An open CDP connection to Steam's JS context.
+///
+/// Each [`CefClient`] call connects anew; a session stays open across
+/// calls, which is much faster for a run of operations such as creating
+/// the shortcuts of a multi-game deploy or applying a batch of artwork.
+///
+/// When Steam drops the socket (it does while busy), the session
+/// reconnects to the same tab and evaluates the expression again. Every
+/// request carries its own CDP message ID, so replies never get mixed up.
+pub struct CefSession {
+    ws: CefStream,
+    ws_url: String,
+    next_id: i32,
+    /// Set once the socket is known to be dead; the next evaluation
+    /// reconnects before sending.
+    broken: bool,
+}
+
+/// Why a single evaluation attempt failed.
+enum EvalFailure {
+    /// The request never reached Steam, so resending is safe.
+    NotSent(SteamError),
+    /// The socket dropped while waiting for the reply; the expression may
+    /// already have run.
+    Dropped(SteamError),
+    /// Steam answered with an error or didn't answer in time.
+    Failed(SteamError),
+}
+
+impl CefSession {
+    /// Connects to a tab's `webSocketDebuggerUrl`.
+    pub async fn connect(ws_url: &str) -> Result<Self, SteamError> {
+        Ok(Self {
+            ws: open_ws(ws_url).await?,
+            ws_url: ws_url.to_string(),
+            next_id: 1,
+            broken: false,
+        })
+    }
+
+    /// Replaces a dropped socket with a new connection to the same tab.
+    async fn reconnect(&mut self) -> Result<(), SteamError> {
+        self.ws = open_ws(&self.ws_url).await?;
+        self.broken = false;
+        Ok(())
+    }
+
+    /// Evaluates a JavaScript expression via CDP `Runtime.evaluate`,
+    /// reconnecting and evaluating again if the socket drops.
+    pub async fn evaluate(&mut self, js_expr: &str) -> Result<serde_json::Value, SteamError> {
+        self.evaluate_retrying(js_expr, true).await
+    }
+
+    /// Like [`evaluate`](Self::evaluate), but only resends after a drop
+    /// when `resend_after_drop` is set or the request never went out, so
+    /// non-idempotent calls don't run twice.
+    async fn evaluate_retrying(
+        &mut self,
+        js_expr: &str,
+        resend_after_drop: bool,
+    ) -> Result<serde_json::Value, SteamError> {
+        let mut reconnects = 0;
+        loop {
+            if self.broken {
+                self.reconnect().await?;
+            }
+            let err = match self.evaluate_once(js_expr).await {
+                Ok(value) => return Ok(value),
+                Err(EvalFailure::Failed(e)) => return Err(e),
+                Err(EvalFailure::Dropped(e)) if !resend_after_drop => {
+                    self.broken = true;
+                    return Err(e);
+                }
+                Err(EvalFailure::NotSent(e) | EvalFailure::Dropped(e)) => e,
+            };
+            self.broken = true;
+            if reconnects >= MAX_RECONNECTS {
+                return Err(err);
+            }
+            reconnects += 1;
+            tracing::debug!("CEF connection lost ({err}), reconnecting");
+        }
+    }
+
+    /// Sends one `Runtime.evaluate` request and waits for its reply.
+    async fn evaluate_once(&mut self, js_expr: &str) -> Result<serde_json::Value, EvalFailure> {
+        let id = self.next_id;
+        self.next_id += 1;
+
+        let msg = CdpRequest {
+            id,
+            method: "Runtime.evaluate".into(),
+            params: serde_json::json!({
+                "expression": js_expr,
+                "returnByValue": true,
+                "awaitPromise": true,
+            }),
+        };
+
+        let json = serde_json::to_string(&msg).map_err(|e| {
+            EvalFailure::Failed(SteamError::Cef(format!(
+                "failed to serialize CDP message: {e}"
+            )))
+        })?;
+
+        self.ws
+            .send(WsMessage::Text(json.into()))
+            .await
+            .map_err(|e| {
+                EvalFailure::NotSent(SteamError::Cef(format!("failed to send CEF message: {e}")))
+            })?;
+
+        // Read responses until we get the one with our ID.
+        loop {
+            let frame = tokio::time::timeout(WS_READ_TIMEOUT, self.ws.next())
+                .await
+                .map_err(|_| {
+                    EvalFailure::Failed(SteamError::Cef("CEF response read timeout".into()))
+                })?
+                .ok_or_else(|| {
+                    EvalFailure::Dropped(SteamError::Cef(
+                        "CEF WebSocket closed unexpectedly".into(),
+                    ))
+                })?
+                .map_err(|e| {
+                    EvalFailure::Dropped(SteamError::Cef(format!(
+                        "failed to read CEF response: {e}"
+                    )))
+                })?;
+
+            let text = match frame {
+                WsMessage::Text(t) => t,
+                _ => continue,
+            };
+
+            let resp: CdpResponse = match serde_json::from_str(&text) {
+                Ok(r) => r,
+                Err(_) => continue, // Skip non-response messages (events, etc.)
+            };
+
+            if resp.id != id {
+                continue;
+            }
+
+            let eval_result = resp.result.ok_or_else(|| {
+                EvalFailure::Failed(SteamError::Cef("CEF response missing result".into()))
+            })?;
+
+            if let Some(exception) = eval_result.exception_details {
+                return Err(EvalFailure::Failed(SteamError::Cef(format!(
+                    "JS exception: {exception}"
+                ))));
+            }
+
+            return Ok(eval_result.result.value);
+        }
+    }
+
+    /// Creates a Steam shortcut and returns the assigned AppID.
+    ///
+    /// Not resent when the socket drops after the request went out, as
+    /// that could create the shortcut twice.
+    pub async fn add_shortcut(
+        &mut self,
+        name: &str,
+        exe: &str,
+        start_dir: &str,
+        launch_options: &str,
+    ) -> Result<u32, SteamError> {
+        let js = add_shortcut_js(name, exe, start_dir, launch_options);
+        parse_app_id(&self.evaluate_retrying(&js, false).await?)
+    }
+
+    /// Removes a Steam shortcut by AppID.
+    pub async fn remove_shortcut(&mut self, app_id: u32) -> Result<(), SteamError> {
+        self.evaluate(&remove_shortcut_js(app_id)).await?;
+        Ok(())
+    }
+
+    /// Sets launch options for a shortcut.
+    pub async fn set_shortcut_launch_options(
+        &mut self,
+        app_id: u32,
+        options: &str,
+    ) -> Result<(), SteamError> {
+        self.evaluate(&set_launch_options_js(app_id, options))
+            .await?;
+        Ok(())
+    }
+
+    /// Applies custom artwork to a Steam app, clearing the old one first.
+    pub async fn set_custom_artwork(
+        &mut self,
+        app_id: u32,
+        base64_data: &str,
+        asset_type: i32,
+    ) -> Result<(), SteamError> {
+        self.clear_custom_artwork(app_id, asset_type).await?;
+        self.evaluate(&set_custom_artwork_js(app_id, base64_data, asset_type))
+            .await?;
+        Ok(())
+    }
+
+    /// Clears custom artwork from a Steam app.
+    pub async fn clear_custom_artwork(
+        &mut self,
+        app_id: u32,
+        asset_type: i32,
+    ) -> Result<(), SteamError> {
+        self.evaluate(&clear_custom_artwork_js(app_id, asset_type))
+            .await?;
+        Ok(())
+    }
+
+    /// Renames a shortcut.
+    pub async fn set_shortcut_name(&mut self, app_id: u32, name: &str) -> Result<(), SteamError> {
+        self.evaluate(&set_shortcut_name_js(app_id, name)).await?;
+        Ok(())
+    }
+
+    /// Sets the working directory a shortcut starts in.
+    pub async fn set_shortcut_start_dir(
+        &mut self,
+        app_id: u32,
+        dir: &str,
+    ) -> Result<(), SteamError> {
+        self.evaluate(&set_start_dir_js(app_id, dir)).await?;
+        Ok(())
+    }
+
+    /// Sets the compatibility tool (e.g. Proton) for a shortcut.
+    pub async fn specify_compat_tool(
+        &mut self,
+        app_id: u32,
+        tool_name: &str,
+    ) -> Result<(), SteamError> {
+        self.evaluate(&specify_compat_tool_js(app_id, tool_name))
+            .await?;
+        Ok(())
+    }
+
+    /// Lists the internal names of the compatibility tools Steam offers for
+    /// an app, e.g. `proton_experimental`.
+    pub async fn available_compat_tools(&mut self, app_id: u32) -> Result<Vec<String>, SteamError> {
+        let result = self.evaluate(&available_compat_tools_js(app_id)).await?;
+        parse_compat_tool_names(&result)
+    }
+
+    /// Sets `tool_name` as the shortcut's compatibility tool if Steam has it
+    /// installed. Returns `Ok(false)` without changing anything when it
+    /// doesn't; when the installed tools can't be listed the tool is set
+    /// anyway.
+    pub async fn specify_installed_compat_tool(
+        &mut self,
+        app_id: u32,
+        tool_name: &str,
+    ) -> Result<bool, SteamError> {
+        match self.available_compat_tools(app_id).await {
+            Ok(tools) if !tools.iter().any(|t| t == tool_name) => return Ok(false),
+            Ok(_) => {}
+            Err(e) => tracing::debug!("failed to list compat tools for {app_id}: {e}"),
+        }
+        self.specify_compat_tool(app_id, tool_name).await?;
+        Ok(true)
+    }
+
+    /// See [`CefClient::mark_recent`].
+    pub async fn mark_recent(&mut self, app_id: u32) -> Result<bool, SteamError> {
+        let result = self.evaluate(&mark_recent_js(app_id, unix_now())).await?;
+        parse_bool_result(&result, "mark-recent")
+    }
+
+    /// Adds `shortcut`, then names it and assigns its compat tool.
+    ///
+    /// `AddShortcut` ignores the name it's given and uses the executable's
+    /// file name, hence the rename. Failing to rename or to set the compat
+    /// tool is only logged, since the shortcut exists by then.
+    pub async fn create_shortcut(&mut self, shortcut: &NewShortcut) -> Result<u32, SteamError> {
+        let app_id = self
+            .add_shortcut(
+                &shortcut.name,
+                &shortcut.exe,
+                &shortcut.start_dir,
+                &shortcut.launch_options,
+            )
+            .await?;
+        if let Err(e) = self.set_shortcut_name(app_id, &shortcut.name).await {
+            tracing::warn!("failed to set name of shortcut {app_id}: {e}");
+        }
+        if !shortcut.compat_tool.is_empty() {
+            match self
+                .specify_installed_compat_tool(app_id, &shortcut.compat_tool)
+                .await
+            {
+                Ok(true) => {}
+                Ok(false) => tracing::warn!(
+                    "compat tool {} is not installed; shortcut {app_id} keeps the default",
+                    shortcut.compat_tool
+                ),
+                Err(e) => tracing::warn!("failed to set compat tool of shortcut {app_id}: {e}"),
+            }
+        }
+        Ok(app_id)
+    }
+
+    /// Closes the connection gracefully.
+    pub async fn close(mut self) {
+        let _ = self.ws.close(None).await;
+    }
+}
+
 // ---------------------------------------------------------------------------
 // Internal helpers
 // ---------------------------------------------------------------------------
 
+/// Opens a WebSocket to a tab's debugger URL.
+async fn open_ws(ws_url: &str) -> Result<CefStream, SteamError> {
+    let (ws, _) = tokio::time::timeout(
+        WS_HANDSHAKE_TIMEOUT,
+        tokio_tungstenite::connect_async(ws_url),
+    )
+    .await
+    .map_err(|_| SteamError::Cef("CEF WebSocket handshake timeout".into()))?
+    .map_err(|e| SteamError::Cef(format!("failed to connect to CEF WebSocket: {e}")))?;
+    Ok(ws)
+}
+
+/// Parses a CEF port override, falling back to [`DEFAULT_CEF_PORT`] when
+/// unset or invalid.
+fn cef_port(value: Option<&str>) -> u16 {
+    value
+        .and_then(|v| v.trim().parse::<u16>().ok())
+        .filter(|&p| p != 0)
+        .unwrap_or(DEFAULT_CEF_PORT)
+}
+
+/// Error for a debug port answered by something other than Steam.
+fn port_conflict(addr: &str, found: &str) -> SteamError {
+    SteamError::CefPortConflict(format!(
+        "{addr} is answered by another process ({found}), not Steam's CEF debugger; \
+         stop that process (often a dev server) and restart Steam, or set \
+         {CEF_PORT_ENV} to the port Steam's debugger listens on"
+    ))
+}
+
+/// Whether a tab belongs to Steam's client UI rather than some other
+/// Chromium exposing DevTools on the port.
+fn is_steam_tab(tab: &CefTab) -> bool {
+    matches!(tab.title.as_str(), "SharedJSContext" | "SP" | "Steam")
+        || tab.url.contains("steamloopback.host")
+}
+
+/// Parses the `/json` tab list served at `addr`, checking it comes from
+/// Steam.
+///
+/// An empty list is accepted: Steam serves one while its UI is starting.
+fn parse_tabs(addr: &str, raw: &str) -> Result<Vec<CefTab>, SteamError> {
+    let tabs: Vec<CefTab> = serde_json::from_str(raw)
+        .map_err(|_| port_conflict(addr, "/json is not a DevTools tab list"))?;
+    if !tabs.is_empty() && !tabs.iter().any(is_steam_tab) {
+        return Err(port_conflict(
+            addr,
+            "a DevTools endpoint without Steam tabs",
+        ));
+    }
+    Ok(tabs)
+}
+
 /// Escapes a string for safe JavaScript literal embedding.
 ///
 /// Uses JSON encoding which produces valid JS string literals.
@@ -237,6 +646,141 @@ fn js_string(s: &str) -> String {
     serde_json::to_string(s).unwrap_or_else(|_| "\"\"".to_string())
 }
 
+fn add_shortcut_js(name: &str, exe: &str, start_dir: &str, launch_options: &str) -> String {
+    format!(
+        "SteamClient.Apps.AddShortcut({}, {}, {}, {})",
+        js_string(name),
+        js_string(exe),
+        js_string(start_dir),
+        js_string(launch_options),
+    )
+}
+
+/// Parses the AppID `AddShortcut` resolves to.
+fn parse_app_id(result: &serde_json::Value) -> Result<u32, SteamError> {
+    let app_id = result.as_f64().map(|v| v as u32).ok_or_else(|| {
+        SteamError::Cef(format!(
+            "failed to parse AddShortcut result: expected number, got {result}"
+        ))
+    })?;
+
+    if app_id == 0 {
+        return Err(SteamError::Cef(format!(
+            "AddShortcut returned invalid appID: {app_id}"
+        )));
+    }
+
+    Ok(app_id)
+}
+
+fn set_shortcut_name_js(app_id: u32, name: &str) -> String {
+    format!(
+        "SteamClient.Apps.SetShortcutName({app_id}, {})",
+        js_string(name),
+    )
+}
+
+fn remove_shortcut_js(app_id: u32) -> String {
+    format!("SteamClient.Apps.RemoveShortcut({app_id})")
+}
+
+fn set_launch_options_js(app_id: u32, options: &str) -> String {
+    format!(
+        "SteamClient.Apps.SetShortcutLaunchOptions({app_id}, {})",
+        js_string(options),
+    )
+}
+
+fn set_start_dir_js(app_id: u32, dir: &str) -> String {
+    format!(
+        "SteamClient.Apps.SetShortcutStartDir({app_id}, {})",
+        js_string(dir),
+    )
+}
+
+fn set_custom_artwork_js(app_id: u32, base64_data: &str, asset_type: i32) -> String {
+    format!(
+        "SteamClient.Apps.SetCustomArtworkForApp({app_id}, {}, \"png\", {asset_type})",
+        js_string(base64_data),
+    )
+}
+
+fn clear_custom_artwork_js(app_id: u32, asset_type: i32) -> String {
+    format!("SteamClient.Apps.ClearCustomArtworkForApp({app_id}, {asset_type})")
+}
+
+fn specify_compat_tool_js(app_id: u32, tool_name: &str) -> String {
+    format!(
+        "SteamClient.Apps.SpecifyCompatTool({app_id}, {})",
+        js_string(tool_name),
+    )
+}
+
+fn available_compat_tools_js(app_id: u32) -> String {
+    format!(
+        "(async () => (await SteamClient.Apps.GetAvailableCompatTools({app_id})) \
+            .map(t => t.strToolName))()"
+    )
+}
+
+fn parse_compat_tool_names(result: &serde_json::Value) -> Result<Vec<String>, SteamError> {
+    result
+        .as_array()
+        .map(|tools| {
+            tools
+                .iter()
+                .filter_map(|t| t.as_str().map(str::to_string))
+                .collect()
+        })
+        .ok_or_else(|| {
+            SteamError::Cef(format!(
+                "unexpected compat tool list: expected array, got {result}"
+            ))
+        })
+}
+
+fn unix_now() -> u64 {
+    std::time::SystemTime::now()
+        .duration_since(std::time::UNIX_EPOCH)
+        .map(|d| d.as_secs())
+        .unwrap_or_default()
+}
+
+/// JS that sets `rt_last_time_played` on the app overview, evaluating to
+/// whether the client supports it.
+fn mark_recent_js(app_id: u32, now: u64) -> String {
+    format!(
+        "(() => {{ \
+            const ov = typeof appStore !== 'undefined' && appStore.GetAppOverviewByAppID({app_id}); \
+            if (!ov || !('rt_last_time_played' in ov)) return false; \
+            ov.rt_last_time_played = {now}; \
+            return true; \
+        }})()"
+    )
+}
+
+/// JS that runs an app by its overview's game ID (shortcuts have 64-bit
+/// IDs distinct from their AppID), evaluating to whether the app exists.
+fn launch_app_js(app_id: u32) -> String {
+    format!(
+        "(() => {{ \
+            const ov = typeof appStore !== 'undefined' && appStore.GetAppOverviewByAppID({app_id}); \
+            if (!ov) return false; \
+            SteamClient.Apps.RunGame(ov.m_gameid || '{app_id}', '', -1, 100); \
+            return true; \
+        }})()"
+    )
+}
+
+/// Parses the bool a JS helper evaluates to; `what` names it in errors.
+fn parse_bool_result(result: &serde_json::Value, what: &str) -> Result<bool, SteamError> {
+    result.as_bool().ok_or_else(|| {
+        SteamError::Cef(format!(
+            "unexpected {what} result: expected bool, got {result}"
+        ))
+    })
+}
+
 /// CDP message sent to the browser.
 #[derive(Serialize)]
 struct CdpRequest {
@@ -265,70 +809,6 @@ struct CdpResultValue {
     pub value: serde_json::Value,
 }
 
-/// Evaluates JS via a CDP WebSocket connection.
-async fn evaluate_async(ws_url: &str, js_expr: &str) -> Result<serde_json::Value, SteamError> {
-    let (mut ws, _) = tokio::time::timeout(
-        WS_HANDSHAKE_TIMEOUT,
-        tokio_tungstenite::connect_async(ws_url),
-    )
-    .await
-    .map_err(|_| SteamError::Cef("CEF WebSocket handshake timeout".into()))?
-    .map_err(|e| SteamError::Cef(format!("failed to connect to CEF WebSocket: {e}")))?;
-
-    let msg = CdpRequest {
-        id: 1,
-        method: "Runtime.evaluate".into(),
-        params: serde_json::json!({
-            "expression": js_expr,
-            "returnByValue": true,
-            "awaitPromise": true,
-        }),
-    };
-
-    let json = serde_json::to_string(&msg)
-        .map_err(|e| SteamError::Cef(format!("failed to serialize CDP message: {e}")))?;
-
-    ws.send(WsMessage::Text(json.into()))
-        .await
-        .map_err(|e| SteamError::Cef(format!("failed to send CEF message: {e}")))?;
-
-    // Read responses until we get the one with our ID.
-    loop {
-        let frame = tokio::time::timeout(WS_READ_TIMEOUT, ws.next())
-            .await
-            .map_err(|_| SteamError::Cef("CEF response read timeout".into()))?
-            .ok_or_else(|| SteamError::Cef("CEF WebSocket closed unexpectedly".into()))?
-            .map_err(|e| SteamError::Cef(format!("failed to read CEF response: {e}")))?;
-
-        let text = match frame {
-            WsMessage::Text(t) => t,
-            _ => continue,
-        };
-
-        let resp: CdpResponse = match serde_json::from_str(&text) {
-            Ok(r) => r,
-            Err(_) => continue, // Skip non-response messages (events, etc.)
-        };
-
-        if resp.id != 1 {
-            continue;
-        }
-
-        let eval_result = resp
-            .result
-            .ok_or_else(|| SteamError::Cef("CEF response missing result".into()))?;
-
-        if let Some(exception) = eval_result.exception_details {
-            return Err(SteamError::Cef(format!("JS exception: {exception}")));
-        }
-
-        // Close the WebSocket gracefully.
-        let _ = ws.close(None).await;
-
-        return Ok(eval_result.result.value);
-    }
-}
-
 /// Minimal HTTP GET to fetch JSON from the CEF debug endpoint.
 ///
 /// Steam's CEF server does NOT close the TCP connection after responding
@@ -350,6 +830,19 @@ async fn http_get_json(addr: &str) -> Result<String, SteamError> {
         .map_err(|e| SteamError::Cef(format!("failed to send HTTP request: {e}")))?;
 
     let mut reader = BufReader::new(reader);
+    let mut status_line = String::new();
+    tokio::time::timeout(HTTP_TIMEOUT, reader.read_line(&mut status_line))
+        .await
+        .map_err(|_| port_conflict(addr, "no HTTP response"))?
+        .map_err(|e| SteamError::Cef(format!("failed to read HTTP status: {e}")))?;
+    if !status_line.starts_with("HTTP/") {
+        return Err(port_conflict(addr, "not an HTTP server"));
+    }
+    let status = status_line.split_whitespace().nth(1).unwrap_or_default();
+    if status != "200" {
+        return Err(port_conflict(addr, &format!("GET /json returned {status}")));
+    }
+
     let mut content_length: Option<usize> = None;
 
     // Read headers line by line until empty line (\r\n).
@@ -372,8 +865,9 @@ async fn http_get_json(addr: &str) -> Result<String, SteamError> {
         }
     }
 
-    let len = content_length
-        .ok_or_else(|| SteamError::Cef("CEF response missing Content-Length header".into()))?;
+    // Steam always sends a Content-Length; a server that doesn't isn't it.
+    let len =
+        content_length.ok_or_else(|| port_conflict(addr, "response without Content-Length"))?;
 
     let mut body = vec![0u8; len];
     reader
@@ -381,13 +875,14 @@ async fn http_get_json(addr: &str) -> Result<String, SteamError> {
         .await
         .map_err(|e| SteamError::Cef(format!("failed to read CEF response body: {e}")))?;
 
-    String::from_utf8(body)
-        .map_err(|e| SteamError::Cef(format!("CEF response is not valid UTF-8: {e}")))
+    String::from_utf8(body).map_err(|_| port_conflict(addr, "response is not UTF-8 text"))
 }
 
 #[cfg(test)]
 mod tests {
     use super::*;
+    use std::sync::Arc;
+    use std::sync::atomic::{AtomicUsize, Ordering};
 
     #[test]
     fn js_string_escaping() {
@@ -402,6 +897,40 @@ mod tests {
         assert_eq!(js_string("日本語ゲーム"), r#""日本語ゲーム""#);
     }
 
+    #[test]
+    fn mark_recent_js_targets_app() {
+        let js = mark_recent_js(3_000_000_001, 1_700_000_000);
+        assert!(js.contains("GetAppOverviewByAppID(3000000001)"));
+        assert!(js.contains("rt_last_time_played = 1700000000"));
+        assert!(js.starts_with("(() => {"));
+    }
+
+    #[test]
+    fn mark_recent_supported_and_unsupported() {
+        assert!(parse_bool_result(&serde_json::json!(true), "mark-recent").unwrap());
+        assert!(!parse_bool_result(&serde_json::json!(false), "mark-recent").unwrap());
+        assert!(parse_bool_result(&serde_json::Value::Null, "mark-recent").is_err());
+    }
+
+    #[test]
+    fn compat_tool_names_are_parsed() {
+        assert!(available_compat_tools_js(42).contains("GetAvailableCompatTools(42)"));
+        let tools = serde_json::json!(["proton_experimental", "proton_9", null]);
+        assert_eq!(
+            parse_compat_tool_names(&tools).unwrap(),
+            vec!["proton_experimental", "proton_9"]
+        );
+        assert!(parse_compat_tool_names(&serde_json::Value::Null).is_err());
+    }
+
+    #[test]
+    fn launch_app_js_runs_by_game_id() {
+        let js = launch_app_js(3_000_000_001);
+        assert!(js.contains("GetAppOverviewByAppID(3000000001)"));
+        assert!(js.contains("SteamClient.Apps.RunGame(ov.m_gameid || '3000000001'"));
+        assert!(js.contains("return false"));
+    }
+
     #[test]
     fn artwork_type_mapping() {
         assert_eq!(artwork_type_to_cef_asset("grid"), Some(0));
@@ -472,6 +1001,239 @@ mod tests {
         assert!(result.is_err());
     }
 
+    /// Serves one canned HTTP response on a local port and returns it.
+    async fn serve_once(response: String) -> u16 {
+        use tokio::io::{AsyncReadExt, AsyncWriteExt};
+
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let port = listener.local_addr().unwrap().port();
+        tokio::spawn(async move {
+            let (mut stream, _) = listener.accept().await.unwrap();
+            let mut buf = [0u8; 1024];
+            let _ = stream.read(&mut buf).await;
+            stream.write_all(response.as_bytes()).await.unwrap();
+            // Like Steam, keep the connection open after responding.
+            tokio::time::sleep(Duration::from_secs(1)).await;
+        });
+        port
+    }
+
+    fn http_ok(body: &str) -> String {
+        format!(
+            "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: {}\r\n\r\n{body}",
+            body.len()
+        )
+    }
+
+    const STEAM_TABS: &str = r#"[{"description":"","devtoolsFrontendUrl":"/devtools/inspector.html","id":"A1","title":"SharedJSContext","type":"page","url":"https://steamloopback.host/index.html","webSocketDebuggerUrl":"ws://127.0.0.1:8080/devtools/page/A1"}]"#;
+
+    #[tokio::test]
+    async fn accepts_steam_cef_tab_list() {
+        let port = serve_once(http_ok(STEAM_TABS)).await;
+        let tabs = CefClient::with_port(port).get_tabs().await.unwrap();
+        assert_eq!(tabs.len(), 1);
+        assert_eq!(CefClient::find_js_context(&tabs).unwrap().id, "A1");
+    }
+
+    #[tokio::test]
+    async fn foreign_http_server_is_a_port_conflict() {
+        let not_found = "HTTP/1.1 404 Not Found\r\nContent-Type: text/html\r\nContent-Length: 9\r\n\r\nNot Found";
+        let port = serve_once(not_found.into()).await;
+        let err = CefClient::with_port(port).get_tabs().await.unwrap_err();
+        assert!(matches!(err, SteamError::CefPortConflict(_)), "{err}");
+        assert!(err.to_string().contains("404"), "{err}");
+
+        // A dev server answering every path with its own JSON.
+        let port = serve_once(http_ok(r#"{"status":"ok"}"#)).await;
+        let err = CefClient::with_port(port).get_tabs().await.unwrap_err();
+        assert!(matches!(err, SteamError::CefPortConflict(_)), "{err}");
+
+        // Chunked responses never come from Steam.
+        let chunked = "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n2\r\n[]\r\n0\r\n\r\n";
+        let port = serve_once(chunked.into()).await;
+        let err = CefClient::with_port(port).get_tabs().await.unwrap_err();
+        assert!(matches!(err, SteamError::CefPortConflict(_)), "{err}");
+    }
+
+    #[test]
+    fn foreign_devtools_endpoint_is_a_port_conflict() {
+        let chrome = r#"[{"id":"B2","title":"New Tab","type":"page","url":"chrome://newtab/","webSocketDebuggerUrl":"ws://127.0.0.1:8080/devtools/page/B2"}]"#;
+        let err = parse_tabs("127.0.0.1:8080", chrome).unwrap_err();
+        assert!(matches!(err, SteamError::CefPortConflict(_)));
+        assert!(err.to_string().contains(CEF_PORT_ENV));
+
+        // Steam serves an empty list while its UI starts.
+        assert!(parse_tabs("127.0.0.1:8080", "[]").unwrap().is_empty());
+        assert_eq!(parse_tabs("127.0.0.1:8080", STEAM_TABS).unwrap().len(), 1);
+    }
+
+    /// Mock CEF debugger WebSocket answering `Runtime.evaluate`:
+    /// `AddShortcut` resolves to increasing AppIDs, or throws for an exe
+    /// named "Broken". The `drop_request`-th request (1-based) closes the
+    /// connection instead of being answered. Returns its URL, the number
+    /// of connections accepted and every expression evaluated.
+    async fn mock_cef_ws(
+        drop_request: Option<usize>,
+    ) -> (String, Arc<AtomicUsize>, Arc<std::sync::Mutex<Vec<String>>>) {
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let url = format!("ws://{}/devtools/page/A1", listener.local_addr().unwrap());
+        let connections = Arc::new(AtomicUsize::new(0));
+        let expressions = Arc::new(std::sync::Mutex::new(Vec::new()));
+        let (conns, exprs) = (connections.clone(), expressions.clone());
+        tokio::spawn(async move {
+            let mut next_app_id = 3_000_000_001u32;
+            let mut requests = 0;
+            while let Ok((stream, _)) = listener.accept().await {
+                conns.fetch_add(1, Ordering::SeqCst);
+                let mut ws = tokio_tungstenite::accept_async(stream).await.unwrap();
+                while let Some(Ok(WsMessage::Text(text))) = ws.next().await {
+                    let req: serde_json::Value = serde_json::from_str(&text).unwrap();
+                    let expr = req["params"]["expression"].as_str().unwrap().to_string();
+                    requests += 1;
+                    if drop_request == Some(requests) {
+                        break;
+                    }
+                    let result = if expr.contains("Broken") {
+                        serde_json::json!({
+                            "result": { "type": "object" },
+                            "exceptionDetails": { "text": "Uncaught" },
+                        })
+                    } else if expr.contains("GetAvailableCompatTools") {
+                        serde_json::json!({ "result": { "type": "object", "value": ["proton_experimental"] } })
+                    } else if expr.starts_with("SteamClient.Apps.AddShortcut") {
+                        next_app_id += 1;
+                        serde_json::json!({ "result": { "type": "number", "value": next_app_id - 1 } })
+                    } else {
+                        serde_json::json!({ "result": { "type": "undefined" } })
+                    };
+                    exprs.lock().unwrap().push(expr);
+                    let resp = serde_json::json!({ "id": req["id"], "result": result });
+                    ws.send(WsMessage::Text(resp.to_string().into()))
+                        .await
+                        .unwrap();
+                }
+            }
+        });
+        (url, connections, expressions)
+    }
+
+    #[tokio::test]
+    async fn batch_creates_shortcuts_over_one_connection() {
+        let (ws_url, connections, expressions) = mock_cef_ws(None).await;
+        let tabs = format!(
+            r#"[{{"id":"A1","title":"SharedJSContext","type":"page","url":"https://steamloopback.host/index.html","webSocketDebuggerUrl":"{ws_url}"}}]"#
+        );
+        let port = serve_once(http_ok(&tabs)).await;
+
+        let shortcut = |name: &str, exe: &str, compat_tool: &str| NewShortcut {
+            name: name.into(),
+            exe: format!("/games/{name}/{exe}"),
+            start_dir: format!("/games/{name}"),
+            launch_options: String::new(),
+            compat_tool: compat_tool.into(),
+        };
+        let shortcuts = [
+            shortcut("Celeste", "Celeste", ""),
+            shortcut("Broken", "Broken.exe", "proton_experimental"),
+            shortcut("Hades", "Hades.exe", "proton_experimental"),
+            shortcut("Tunic", "Tunic.exe", "proton_5"),
+        ];
+        let results = CefClient::with_port(port)
+            .create_shortcuts(&shortcuts)
+            .await
+            .unwrap();
+
+        assert_eq!(results.len(), 4);
+        assert_eq!(results[0].as_ref().unwrap(), &3_000_000_001);
+        assert!(matches!(results[1], Err(SteamError::Cef(_))));
+        assert_eq!(results[2].as_ref().unwrap(), &3_000_000_002);
+        // A missing compat tool doesn't fail the shortcut.
+        assert_eq!(results[3].as_ref().unwrap(), &3_000_000_003);
+        assert_eq!(connections.load(Ordering::SeqCst), 1);
+
+        let expressions = expressions.lock().unwrap();
+        let adds = expressions
+            .iter()
+            .filter(|e| e.starts_with("SteamClient.Apps.AddShortcut"))
+            .count();
+        assert_eq!(adds, 4);
+        assert!(expressions.contains(&set_shortcut_name_js(3_000_000_002, "Hades")));
+        assert!(expressions.contains(&specify_compat_tool_js(
+            3_000_000_002,
+            "proton_experimental"
+        )));
+        assert!(
+            !expressions
+                .iter()
+                .any(|e| e.contains("SpecifyCompatTool") && e.contains("proton_5"))
+        );
+        // Celeste: add + rename; Broken: add; Hades: add + rename + list +
+        // compat; Tunic: add + rename + list.
+        assert_eq!(expressions.len(), 10);
+    }
+
+    #[tokio::test]
+    async fn session_reconnects_when_socket_drops() {
+        let (ws_url, connections, expressions) = mock_cef_ws(Some(2)).await;
+        let mut session = CefSession::connect(&ws_url).await.unwrap();
+
+        // Clear goes through; the set is dropped, then evaluated again on
+        // a new connection.
+        session
+            .set_custom_artwork(3_000_000_001, "aGVybw==", CEF_ASSET_HERO)
+            .await
+            .unwrap();
+        session
+            .set_custom_artwork(3_000_000_001, "bG9nbw==", CEF_ASSET_LOGO)
+            .await
+            .unwrap();
+        session.close().await;
+
+        assert_eq!(connections.load(Ordering::SeqCst), 2);
+        let expressions = expressions.lock().unwrap();
+        assert_eq!(
+            *expressions,
+            [
+                clear_custom_artwork_js(3_000_000_001, CEF_ASSET_HERO),
+                set_custom_artwork_js(3_000_000_001, "aGVybw==", CEF_ASSET_HERO),
+                clear_custom_artwork_js(3_000_000_001, CEF_ASSET_LOGO),
+                set_custom_artwork_js(3_000_000_001, "bG9nbw==", CEF_ASSET_LOGO),
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn dropped_add_shortcut_is_not_resent() {
+        let (ws_url, connections, expressions) = mock_cef_ws(Some(1)).await;
+        let mut session = CefSession::connect(&ws_url).await.unwrap();
+
+        let err = session
+            .add_shortcut("Celeste", "/games/Celeste", "/games", "")
+            .await
+            .unwrap_err();
+        assert!(matches!(err, SteamError::Cef(_)), "{err}");
+
+        // The next call reconnects first.
+        assert_eq!(
+            session
+                .add_shortcut("Hades", "/games/Hades", "/games", "")
+                .await
+                .unwrap(),
+            3_000_000_001
+        );
+        assert_eq!(connections.load(Ordering::SeqCst), 2);
+        assert_eq!(expressions.lock().unwrap().len(), 1);
+    }
+
+    #[test]
+    fn cef_port_override() {
+        assert_eq!(cef_port(None), DEFAULT_CEF_PORT);
+        assert_eq!(cef_port(Some("9222")), 9222);
+        assert_eq!(cef_port(Some(" 8081 ")), 8081);
+        assert_eq!(cef_port(Some("0")), DEFAULT_CEF_PORT);
+        assert_eq!(cef_port(Some("steam")), DEFAULT_CEF_PORT);
+    }
+
     #[test]
     fn find_js_context_empty_tabs() {
         let result = CefClient::find_js_context(&[]);
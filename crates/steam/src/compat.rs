@@ -0,0 +1,382 @@
+//! Compatibility tool detection for non-Steam shortcuts.
+//!
+//! A Windows (PE) executable added as a shortcut on Linux only launches
+//! when a compatibility tool such as Proton is assigned to it. Steam keeps
+//! those assignments in the `CompatToolMapping` section of the text VDF
+//! `<steam>/config/config.vdf`, keyed by AppID.
+
+use std::collections::HashMap;
+use std::fs;
+use std::io::{Read, Seek, SeekFrom};
+use std::path::Path;
+
+use capydeploy_protocol::ShortcutInfo;
+
+use crate::SteamError;
+use crate::vdf::{Token, tokenize};
+
+/// Executable format, detected from the file's magic bytes.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ExeFormat {
+    /// Windows Portable Executable: an `.exe` with an `MZ` stub pointing
+    /// at a `PE` header.
+    Pe,
+    /// Windows Installer package (`.msi`).
+    Msi,
+    /// Windows batch script (`.bat` / `.cmd`).
+    Batch,
+    /// Linux ELF binary.
+    Elf,
+    /// Script with a shebang line.
+    Script,
+    /// Unreadable or unrecognized file.
+    Unknown,
+}
+
+/// Magic bytes of an OLE compound file, the container of `.msi` packages.
+const OLE_MAGIC: &[u8; 8] = b"\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1";
+
+/// Detects the executable format of `path` from its first bytes.
+///
+/// Only an `.exe` whose `MZ` stub leads to a `PE` header is PE, and only
+/// an `.msi` that is an OLE compound file is MSI. Batch files have no
+/// magic and are recognized by extension. Falls back to the extension
+/// when the file can't be read, so a missing `.exe` is still classified
+/// as PE.
+pub fn detect_exe_format(path: &Path) -> ExeFormat {
+    let ext = extension(path);
+    let mut file = match fs::File::open(path) {
+        Ok(file) => file,
+        Err(_) => return format_from_extension(ext.as_deref()),
+    };
+    let mut magic = [0u8; 8];
+    let n = match file.read(&mut magic) {
+        Ok(n) => n,
+        Err(_) => return format_from_extension(ext.as_deref()),
+    };
+    match ext.as_deref() {
+        Some("exe") if n >= 2 && &magic[..2] == b"MZ" && has_pe_header(&mut file) => {
+            return ExeFormat::Pe;
+        }
+        Some("msi") if n == 8 && &magic == OLE_MAGIC => return ExeFormat::Msi,
+        Some("bat" | "cmd") => return ExeFormat::Batch,
+        _ => {}
+    }
+    match &magic[..n] {
+        [0x7f, b'E', b'L', b'F', ..] => ExeFormat::Elf,
+        [b'#', b'!', ..] => ExeFormat::Script,
+        _ => ExeFormat::Unknown,
+    }
+}
+
+/// Whether the DOS header at the start of `file` points (`e_lfanew`, at
+/// offset 0x3c) to a `PE\0\0` signature.
+fn has_pe_header(file: &mut fs::File) -> bool {
+    let mut offset = [0u8; 4];
+    let mut signature = [0u8; 4];
+    file.seek(SeekFrom::Start(0x3c)).is_ok()
+        && file.read_exact(&mut offset).is_ok()
+        && file
+            .seek(SeekFrom::Start(u64::from(u32::from_le_bytes(offset))))
+            .is_ok()
+        && file.read_exact(&mut signature).is_ok()
+        && &signature == b"PE\0\0"
+}
+
+fn extension(path: &Path) -> Option<String> {
+    path.extension()
+        .map(|e| e.to_string_lossy().to_ascii_lowercase())
+}
+
+fn format_from_extension(ext: Option<&str>) -> ExeFormat {
+    match ext {
+        Some("exe") => ExeFormat::Pe,
+        Some("msi") => ExeFormat::Msi,
+        Some("bat" | "cmd") => ExeFormat::Batch,
+        Some("sh") => ExeFormat::Script,
+        _ => ExeFormat::Unknown,
+    }
+}
+
+/// Returns whether a shortcut with the given executable format needs a
+/// compatibility tool that isn't assigned.
+///
+/// Only Windows programs (PE executables, MSI packages and batch files)
+/// on a Linux host need one.
+pub fn needs_compat_tool(format: ExeFormat, host_is_linux: bool, tool_assigned: bool) -> bool {
+    host_is_linux
+        && matches!(format, ExeFormat::Pe | ExeFormat::Msi | ExeFormat::Batch)
+        && !tool_assigned
+}
+
+/// Loads the AppID → compat tool name mapping from Steam's `config.vdf`.
+///
+/// A missing file yields an empty mapping.
+pub fn load_compat_tool_mapping(path: &Path) -> Result<HashMap<u32, String>, SteamError> {
+    match fs::read_to_string(path) {
+        Ok(text) => parse_compat_tool_mapping(&text),
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(HashMap::new()),
+        Err(e) => Err(SteamError::Io(format!(
+            "failed to read {}: {e}",
+            path.display()
+        ))),
+    }
+}
+
+/// Sets `needs_compat_tool` on each shortcut.
+pub fn annotate_compat_tools(
+    shortcuts: &mut [ShortcutInfo],
+    mapping: &HashMap<u32, String>,
+    host_is_linux: bool,
+) {
+    for sc in shortcuts {
+        let exe = sc.exe.trim_matches('"');
+        let assigned = mapping.get(&sc.app_id).is_some_and(|t| !t.is_empty());
+        sc.needs_compat_tool =
+            needs_compat_tool(detect_exe_format(Path::new(exe)), host_is_linux, assigned);
+    }
+}
+
+/// Parses the `CompatToolMapping` section out of a text VDF document.
+fn parse_compat_tool_mapping(text: &str) -> Result<HashMap<u32, String>, SteamError> {
+    let tokens = tokenize(text)?;
+    let mut mapping = HashMap::new();
+    let mut stack: Vec<String> = Vec::new();
+    let mut pending_key: Option<String> = None;
+
+    for token in tokens {
+        match token {
+            Token::Open => {
+                let key = pending_key
+                    .take()
+                    .ok_or_else(|| SteamError::Vdf("'{' without a key".into()))?;
+                stack.push(key);
+            }
+            Token::Close => {
+                if stack.pop().is_none() {
+                    return Err(SteamError::Vdf("unbalanced '}'".into()));
+                }
+            }
+            Token::Str(s) => match pending_key.take() {
+                None => pending_key = Some(s),
+                Some(key) => {
+                    // key/value pair inside CompatToolMapping/<appid>
+                    let n = stack.len();
+                    if n >= 2
+                        && stack[n - 2].eq_ignore_ascii_case("CompatToolMapping")
+                        && key.eq_ignore_ascii_case("name")
+                        && let Ok(app_id) = stack[n - 1].parse::<u32>()
+                    {
+                        mapping.insert(app_id, s);
+                    }
+                }
+            },
+        }
+    }
+
+    if !stack.is_empty() {
+        return Err(SteamError::Vdf("unterminated object".into()));
+    }
+    Ok(mapping)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const CONFIG_VDF: &str = r#"
+"InstallConfigStore"
+{
+	"Software"
+	{
+		"Valve"
+		{
+			"Steam"
+			{
+				"CompatToolMapping"
+				{
+					"3000000001"
+					{
+						"name"		"proton_9"
+						"config"		""
+						"priority"		"250"
+					}
+					"3000000002"
+					{
+						"name"		""
+						"config"		""
+						"priority"		"250"
+					}
+				}
+				"name"		"not-a-mapping"
+			}
+		}
+	}
+}
+"#;
+
+    fn temp_dir(name: &str) -> std::path::PathBuf {
+        let dir = std::env::temp_dir().join(format!("capydeploy_compat_{name}"));
+        let _ = fs::remove_dir_all(&dir);
+        fs::create_dir_all(&dir).unwrap();
+        dir
+    }
+
+    fn shortcut(app_id: u32, exe: &str) -> ShortcutInfo {
+        ShortcutInfo {
+            app_id,
+            name: format!("Game {app_id}"),
+            exe: exe.into(),
+            start_dir: String::new(),
+            launch_options: String::new(),
+            tags: vec![],
+            last_played: 0,
+            needs_compat_tool: false,
+            size_bytes: 0,
+            broken: false,
+        }
+    }
+
+    /// A minimal PE image: DOS stub whose `e_lfanew` points at `PE\0\0`.
+    fn pe_image() -> Vec<u8> {
+        let mut image = vec![0u8; 0x48];
+        image[..2].copy_from_slice(b"MZ");
+        image[0x3c..0x40].copy_from_slice(&0x40u32.to_le_bytes());
+        image[0x40..0x44].copy_from_slice(b"PE\0\0");
+        image
+    }
+
+    #[test]
+    fn detect_formats_from_magic() {
+        let dir = temp_dir("magic");
+        let pe = dir.join("game.exe");
+        let elf = dir.join("linux.exe"); // extension must not win over magic
+        let script = dir.join("run");
+        let other = dir.join("data");
+        fs::write(&pe, pe_image()).unwrap();
+        fs::write(&elf, b"\x7fELF\x02\x01").unwrap();
+        fs::write(&script, b"#!/bin/sh\n").unwrap();
+        fs::write(&other, b"hello").unwrap();
+
+        assert_eq!(detect_exe_format(&pe), ExeFormat::Pe);
+        assert_eq!(detect_exe_format(&elf), ExeFormat::Elf);
+        assert_eq!(detect_exe_format(&script), ExeFormat::Script);
+        assert_eq!(detect_exe_format(&other), ExeFormat::Unknown);
+        let _ = fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn pe_needs_exe_extension_and_pe_header() {
+        let dir = temp_dir("pe");
+        let dos_only = dir.join("stub.exe");
+        let renamed = dir.join("game.bin");
+        fs::write(&dos_only, b"MZ\x90\x00rest").unwrap();
+        fs::write(&renamed, pe_image()).unwrap();
+
+        assert_eq!(detect_exe_format(&dos_only), ExeFormat::Unknown);
+        assert_eq!(detect_exe_format(&renamed), ExeFormat::Unknown);
+        let _ = fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn detect_msi_and_batch() {
+        let dir = temp_dir("msi");
+        let msi = dir.join("setup.MSI");
+        let fake_msi = dir.join("fake.msi");
+        let bat = dir.join("start.bat");
+        let cmd = dir.join("start.cmd");
+        fs::write(&msi, [OLE_MAGIC.as_slice(), b"rest"].concat()).unwrap();
+        fs::write(&fake_msi, b"MZ").unwrap();
+        fs::write(&bat, b"@echo off\r\ngame.exe\r\n").unwrap();
+        fs::write(&cmd, b"game.exe").unwrap();
+
+        assert_eq!(detect_exe_format(&msi), ExeFormat::Msi);
+        assert_eq!(detect_exe_format(&fake_msi), ExeFormat::Unknown);
+        assert_eq!(detect_exe_format(&bat), ExeFormat::Batch);
+        assert_eq!(detect_exe_format(&cmd), ExeFormat::Batch);
+        assert_eq!(
+            detect_exe_format(Path::new("/nonexistent/setup.msi")),
+            ExeFormat::Msi
+        );
+        let _ = fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn detect_missing_file_uses_extension() {
+        assert_eq!(
+            detect_exe_format(Path::new("/nonexistent/Game.EXE")),
+            ExeFormat::Pe
+        );
+        assert_eq!(
+            detect_exe_format(Path::new("/nonexistent/game")),
+            ExeFormat::Unknown
+        );
+    }
+
+    #[test]
+    fn needs_compat_tool_matrix() {
+        assert!(needs_compat_tool(ExeFormat::Pe, true, false));
+        assert!(!needs_compat_tool(ExeFormat::Pe, true, true));
+        assert!(!needs_compat_tool(ExeFormat::Pe, false, false));
+        assert!(needs_compat_tool(ExeFormat::Msi, true, false));
+        assert!(needs_compat_tool(ExeFormat::Batch, true, false));
+        assert!(!needs_compat_tool(ExeFormat::Batch, true, true));
+        assert!(!needs_compat_tool(ExeFormat::Elf, true, false));
+        assert!(!needs_compat_tool(ExeFormat::Script, true, false));
+        assert!(!needs_compat_tool(ExeFormat::Unknown, true, false));
+    }
+
+    #[test]
+    fn parse_mapping_from_config_vdf() {
+        let mapping = parse_compat_tool_mapping(CONFIG_VDF).unwrap();
+        assert_eq!(
+            mapping.get(&3000000001).map(String::as_str),
+            Some("proton_9")
+        );
+        assert_eq!(mapping.get(&3000000002).map(String::as_str), Some(""));
+        assert_eq!(mapping.len(), 2);
+    }
+
+    #[test]
+    fn parse_mapping_rejects_unbalanced() {
+        assert!(parse_compat_tool_mapping("\"a\" { \"b\" \"c\"").is_err());
+        assert!(parse_compat_tool_mapping("}").is_err());
+        assert!(parse_compat_tool_mapping("\"a\" \"unterminated").is_err());
+    }
+
+    #[test]
+    fn load_missing_config_is_empty() {
+        let mapping = load_compat_tool_mapping(Path::new("/nonexistent/config.vdf")).unwrap();
+        assert!(mapping.is_empty());
+    }
+
+    #[test]
+    fn annotate_classifies_shortcuts() {
+        let dir = temp_dir("annotate");
+        let win_with_tool = dir.join("a.exe");
+        let win_without_tool = dir.join("b.exe");
+        let win_empty_tool = dir.join("c.exe");
+        let native = dir.join("d.x86_64");
+        fs::write(&win_with_tool, pe_image()).unwrap();
+        fs::write(&win_without_tool, pe_image()).unwrap();
+        fs::write(&win_empty_tool, pe_image()).unwrap();
+        fs::write(&native, b"\x7fELF").unwrap();
+
+        let quoted = |p: &Path| format!("\"{}\"", p.display());
+        let mut shortcuts = vec![
+            shortcut(3000000001, &quoted(&win_with_tool)),
+            shortcut(3000000003, &quoted(&win_without_tool)),
+            shortcut(3000000002, &quoted(&win_empty_tool)),
+            shortcut(3000000004, &quoted(&native)),
+        ];
+        let mapping = parse_compat_tool_mapping(CONFIG_VDF).unwrap();
+
+        annotate_compat_tools(&mut shortcuts, &mapping, true);
+        let flags: Vec<bool> = shortcuts.iter().map(|s| s.needs_compat_tool).collect();
+        assert_eq!(flags, vec![false, true, true, false]);
+
+        annotate_compat_tools(&mut shortcuts, &mapping, false);
+        assert!(shortcuts.iter().all(|s| !s.needs_compat_tool));
+        let _ = fs::remove_dir_all(&dir);
+    }
+}
@@ -1,5 +1,9 @@
+pub mod boot_video;
 pub mod cef;
+pub mod compat;
 pub mod controller;
+pub mod library;
+pub mod login;
 pub mod paths;
 #[cfg(target_os = "linux")]
 pub mod paths_linux;
@@ -10,12 +14,22 @@ pub mod users;
 pub mod vdf;
 
 // Re-export primary types.
-pub use cef::{CefClient, artwork_type_to_cef_asset};
+pub use boot_video::{BootVideoOutcome, check_boot_video, install_boot_video, remove_boot_video};
+pub use cef::{CefClient, CefSession, NewShortcut, artwork_type_to_cef_asset};
+pub use compat::{
+    ExeFormat, annotate_compat_tools, detect_exe_format, load_compat_tool_mapping,
+    needs_compat_tool,
+};
 pub use controller::Controller;
+pub use library::{LibraryFolder, ensure_library_folder, load_library_folders};
+pub use login::{LoginState, active_user_id, get_login_state};
 pub use paths::{ArtworkType, Paths};
-pub use shortcuts::{ShortcutManager, convert_to_shortcut_info, generate_app_id};
+pub use shortcuts::{
+    ArtworkMigration, ArtworkScope, ShortcutManager, annotate_install_state,
+    convert_to_shortcut_info, generate_app_id, resolve_delete_target,
+};
 pub use users::{User, get_users, get_users_with_paths, u32_to_user_id, user_id_to_u32};
-pub use vdf::load_shortcuts_vdf;
+pub use vdf::{load_shortcuts_vdf, set_shortcut_tags_vdf};
 
 /// Errors for Steam operations.
 #[derive(Debug, thiserror::Error)]
@@ -46,4 +60,13 @@ pub enum SteamError {
 
     #[error("CEF error: {0}")]
     Cef(String),
+
+    #[error("CEF port conflict: {0}")]
+    CefPortConflict(String),
+
+    #[error("shortcut not found: {0}")]
+    ShortcutNotFound(String),
+
+    #[error("ambiguous shortcut: {0}")]
+    AmbiguousShortcut(String),
 }
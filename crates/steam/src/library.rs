@@ -0,0 +1,268 @@
+//! Steam library folders.
+//!
+//! Steam lists its library folders (the internal drive plus any SD card or
+//! external drive) in the text VDF `<steam>/steamapps/libraryfolders.vdf`,
+//! one numbered entry per folder. Each library root also holds a
+//! `libraryfolder.vdf` marker and a `steamapps` directory.
+
+use std::fs;
+use std::path::{Path, PathBuf};
+
+use capydeploy_protocol::constants::STEAM_LIBRARY_GAMES_DIR;
+
+use crate::SteamError;
+use crate::vdf::flatten_text_vdf;
+
+/// A Steam library folder.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct LibraryFolder {
+    /// Library root, e.g. `/run/media/mmcblk0p1`.
+    pub path: PathBuf,
+    /// User-assigned label; empty for most libraries.
+    pub label: String,
+}
+
+impl LibraryFolder {
+    /// Directory deployed games go to on this library.
+    pub fn install_dir(&self) -> PathBuf {
+        self.path.join(STEAM_LIBRARY_GAMES_DIR)
+    }
+}
+
+/// Loads the library folders from `libraryfolders.vdf`, in Steam's order.
+///
+/// A missing file yields no libraries.
+pub fn load_library_folders(path: &Path) -> Result<Vec<LibraryFolder>, SteamError> {
+    match fs::read_to_string(path) {
+        Ok(text) => Ok(parse_library_folders(&text)?
+            .into_iter()
+            .map(|(_, f)| f)
+            .collect()),
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(Vec::new()),
+        Err(e) => Err(SteamError::Io(format!(
+            "failed to read {}: {e}",
+            path.display()
+        ))),
+    }
+}
+
+/// Registers `library` in `libraryfolders.vdf` unless it's already listed,
+/// and creates the library's `steamapps` directory and marker file.
+///
+/// Returns `true` if the library was added. Steam only picks up the new
+/// entry on its next start.
+pub fn ensure_library_folder(vdf_path: &Path, library: &Path) -> Result<bool, SteamError> {
+    let io_err = |p: &Path, e: std::io::Error| {
+        SteamError::Io(format!("failed to write {}: {e}", p.display()))
+    };
+
+    let steamapps = library.join("steamapps");
+    fs::create_dir_all(&steamapps).map_err(|e| io_err(&steamapps, e))?;
+    let marker = library.join("libraryfolder.vdf");
+    if !marker.exists() {
+        let text = "\"libraryfolder\"\n{\n\t\"contentid\"\t\t\"0\"\n\t\"label\"\t\t\"\"\n}\n";
+        fs::write(&marker, text).map_err(|e| io_err(&marker, e))?;
+    }
+
+    let text = match fs::read_to_string(vdf_path) {
+        Ok(text) => text,
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => "\"libraryfolders\"\n{\n}\n".into(),
+        Err(e) => {
+            return Err(SteamError::Io(format!(
+                "failed to read {}: {e}",
+                vdf_path.display()
+            )));
+        }
+    };
+    let existing = parse_library_folders(&text)?;
+    if existing.iter().any(|(_, f)| same_path(&f.path, library)) {
+        return Ok(false);
+    }
+
+    let index = existing.last().map_or(0, |(i, _)| i + 1);
+    let text = append_library(&text, index, library)?;
+    if let Some(parent) = vdf_path.parent() {
+        fs::create_dir_all(parent).map_err(|e| io_err(parent, e))?;
+    }
+    fs::write(vdf_path, text).map_err(|e| io_err(vdf_path, e))?;
+    Ok(true)
+}
+
+/// Parses the numbered entries of a `libraryfolders.vdf` document, sorted
+/// by index.
+fn parse_library_folders(text: &str) -> Result<Vec<(u32, LibraryFolder)>, SteamError> {
+    let values = flatten_text_vdf(text)?;
+    let mut folders: Vec<(u32, LibraryFolder)> = values
+        .iter()
+        .filter_map(|(key, path)| {
+            let index = key
+                .strip_prefix("libraryfolders/")?
+                .strip_suffix("/path")?
+                .parse::<u32>()
+                .ok()?;
+            let label = values
+                .get(&format!("libraryfolders/{index}/label"))
+                .cloned()
+                .unwrap_or_default();
+            Some((
+                index,
+                LibraryFolder {
+                    path: PathBuf::from(path),
+                    label,
+                },
+            ))
+        })
+        .collect();
+    folders.sort_by_key(|(index, _)| *index);
+    Ok(folders)
+}
+
+/// Inserts a new numbered entry before the closing brace of the document.
+fn append_library(text: &str, index: u32, library: &Path) -> Result<String, SteamError> {
+    let end = text
+        .rfind('}')
+        .ok_or_else(|| SteamError::Vdf("libraryfolders.vdf has no root object".into()))?;
+    let path = library
+        .to_string_lossy()
+        .replace('\\', "\\\\")
+        .replace('"', "\\\"");
+    let entry = format!(
+        "\t\"{index}\"\n\t{{\n\t\t\"path\"\t\t\"{path}\"\n\t\t\"label\"\t\t\"\"\n\t\t\"contentid\"\t\t\"0\"\n\t\t\"totalsize\"\t\t\"0\"\n\t\t\"apps\"\n\t\t{{\n\t\t}}\n\t}}\n"
+    );
+    let mut out = String::with_capacity(text.len() + entry.len());
+    out.push_str(&text[..end]);
+    out.push_str(&entry);
+    out.push_str(&text[end..]);
+    Ok(out)
+}
+
+/// Compares library paths, ignoring trailing separators.
+fn same_path(a: &Path, b: &Path) -> bool {
+    a.components().eq(b.components())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::vdf::{build_test_vdf, load_shortcuts_vdf};
+
+    fn temp_dir(name: &str) -> PathBuf {
+        let dir = std::env::temp_dir().join(format!("capydeploy_library_{name}"));
+        let _ = fs::remove_dir_all(&dir);
+        fs::create_dir_all(&dir).unwrap();
+        dir
+    }
+
+    const LIBRARY_FOLDERS: &str = r#"
+"libraryfolders"
+{
+	"0"
+	{
+		"path"		"/home/deck/.local/share/Steam"
+		"label"		""
+		"apps"
+		{
+			"228980"		"258"
+		}
+	}
+	"2"
+	{
+		"path"		"/run/media/mmcblk0p1"
+		"label"		"SD"
+	}
+}
+"#;
+
+    #[test]
+    fn parses_library_folders_in_order() {
+        let folders = parse_library_folders(LIBRARY_FOLDERS).unwrap();
+        assert_eq!(folders.len(), 2);
+        assert_eq!(
+            folders[0].1.path,
+            PathBuf::from("/home/deck/.local/share/Steam")
+        );
+        assert_eq!(folders[1].0, 2);
+        assert_eq!(folders[1].1.path, PathBuf::from("/run/media/mmcblk0p1"));
+        assert_eq!(folders[1].1.label, "SD");
+        assert_eq!(
+            folders[1].1.install_dir(),
+            PathBuf::from("/run/media/mmcblk0p1/Games")
+        );
+    }
+
+    #[test]
+    fn registers_a_new_library_once() {
+        let dir = temp_dir("once");
+        let vdf = dir.join("steamapps").join("libraryfolders.vdf");
+        fs::create_dir_all(vdf.parent().unwrap()).unwrap();
+        fs::write(&vdf, LIBRARY_FOLDERS).unwrap();
+        let sd = dir.join("sdcard");
+
+        assert!(ensure_library_folder(&vdf, &sd).unwrap());
+        assert!(!ensure_library_folder(&vdf, &sd).unwrap());
+
+        let text = fs::read_to_string(&vdf).unwrap();
+        let folders = parse_library_folders(&text).unwrap();
+        assert_eq!(folders.len(), 3);
+        // Indices needn't be contiguous; the new one goes after the last.
+        assert_eq!(folders[2].0, 3);
+        assert_eq!(folders[2].1.path, sd);
+        assert!(sd.join("steamapps").is_dir());
+        assert!(sd.join("libraryfolder.vdf").is_file());
+        // The existing entries survive untouched.
+        assert!(text.contains("\"228980\""));
+
+        let _ = fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn registers_into_a_missing_file() {
+        let dir = temp_dir("missing");
+        let vdf = dir.join("steamapps").join("libraryfolders.vdf");
+        let sd = dir.join("sd");
+        assert!(ensure_library_folder(&vdf, &sd).unwrap());
+        assert_eq!(load_library_folders(&vdf).unwrap()[0].path, sd);
+
+        let _ = fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn deploys_to_secondary_library_and_points_shortcut_there() {
+        let dir = temp_dir("deploy");
+        let vdf = dir.join("steam/steamapps/libraryfolders.vdf");
+        let sd = dir.join("sd");
+        ensure_library_folder(&vdf, &sd).unwrap();
+        let library = load_library_folders(&vdf)
+            .unwrap()
+            .into_iter()
+            .find(|f| f.path == sd)
+            .unwrap();
+
+        // The agent copies the game under the library's install dir...
+        let game_dir = library.install_dir().join("Celeste");
+        fs::create_dir_all(&game_dir).unwrap();
+        fs::write(game_dir.join("Celeste.x86_64"), b"\x7fELF").unwrap();
+        assert!(sd.join("Games/Celeste/Celeste.x86_64").is_file());
+
+        // ...and the shortcut references the exe on that library.
+        let exe = game_dir
+            .join("Celeste.x86_64")
+            .to_string_lossy()
+            .into_owned();
+        let start_dir = game_dir.to_string_lossy().into_owned();
+        let shortcuts = dir.join("shortcuts.vdf");
+        fs::write(
+            &shortcuts,
+            build_test_vdf(&[("Celeste", &format!("\"{exe}\""), &start_dir, 1)]),
+        )
+        .unwrap();
+
+        let added = &load_shortcuts_vdf(&shortcuts).unwrap()[0];
+        let target = Path::new(added.exe.trim_matches('"'));
+        assert!(target.starts_with(&sd));
+        assert!(target.is_file());
+        assert_eq!(Path::new(added.start_dir.trim_matches('"')), game_dir);
+
+        let _ = fs::remove_dir_all(&dir);
+    }
+}
@@ -0,0 +1,248 @@
+//! Steam account login state.
+//!
+//! CEF calls (artwork, renames, compat tools) need the Steam client up and
+//! signed in; in offline mode they mostly work but anything that reaches
+//! Valve's servers doesn't. The state is read from Steam's own files:
+//! `ActiveProcess/ActiveUser` (registry.vdf on Linux, the registry on
+//! Windows) says whether an account is signed in, and `loginusers.vdf`
+//! says whether that account chose offline mode.
+
+use std::collections::HashMap;
+use std::fmt;
+
+use capydeploy_protocol::constants::{
+    STEAM_LOGIN_LOGGED_IN, STEAM_LOGIN_LOGGED_OUT, STEAM_LOGIN_OFFLINE, STEAM_LOGIN_UNKNOWN,
+};
+
+use crate::paths::Paths;
+use crate::vdf::flatten_text_vdf;
+
+/// Offset between a SteamID64 and the 32-bit account ID Steam uses for
+/// `ActiveUser` and userdata folders.
+const STEAM_ID64_BASE: u64 = 76_561_197_960_265_728;
+
+/// Whether a Steam account is signed in.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum LoginState {
+    LoggedIn,
+    Offline,
+    LoggedOut,
+    Unknown,
+}
+
+impl LoginState {
+    /// Returns the protocol string for this state.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            LoginState::LoggedIn => STEAM_LOGIN_LOGGED_IN,
+            LoginState::Offline => STEAM_LOGIN_OFFLINE,
+            LoginState::LoggedOut => STEAM_LOGIN_LOGGED_OUT,
+            LoginState::Unknown => STEAM_LOGIN_UNKNOWN,
+        }
+    }
+}
+
+impl fmt::Display for LoginState {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(self.as_str())
+    }
+}
+
+/// Reads the current login state from the local Steam installation.
+pub fn get_login_state(paths: &Paths) -> LoginState {
+    let login_users = std::fs::read_to_string(paths.login_users_path())
+        .ok()
+        .and_then(|text| flatten_text_vdf(&text).ok())
+        .unwrap_or_default();
+    resolve_login_state(read_active_user(), &login_users)
+}
+
+/// Returns the userdata ID of the signed-in account, if any.
+pub fn active_user_id() -> Option<String> {
+    read_active_user()
+        .filter(|&id| id != 0)
+        .map(|id| id.to_string())
+}
+
+/// Derives the login state from the `ActiveUser` account ID and the
+/// flattened `loginusers.vdf`.
+pub(crate) fn resolve_login_state(
+    active_user: Option<u32>,
+    login_users: &HashMap<String, String>,
+) -> LoginState {
+    let account_id = match active_user {
+        None => return LoginState::Unknown,
+        Some(0) => return LoginState::LoggedOut,
+        Some(id) => id,
+    };
+
+    let steam_id = (STEAM_ID64_BASE + u64::from(account_id)).to_string();
+    let user_key = if login_users
+        .keys()
+        .any(|k| k.starts_with(&format!("users/{steam_id}/")))
+    {
+        Some(steam_id)
+    } else {
+        // Fall back to the account Steam last signed in with.
+        login_users
+            .iter()
+            .find(|(k, v)| k.ends_with("/mostrecent") && v.as_str() == "1")
+            .and_then(|(k, _)| k.split('/').nth(1).map(str::to_string))
+    };
+
+    let offline = user_key.is_some_and(|id| {
+        login_users
+            .get(&format!("users/{id}/wantsofflinemode"))
+            .is_some_and(|v| v == "1")
+    });
+    if offline {
+        LoginState::Offline
+    } else {
+        LoginState::LoggedIn
+    }
+}
+
+/// Extracts `ActiveProcess/ActiveUser` from a flattened registry.vdf.
+pub(crate) fn active_user_from_registry_vdf(registry: &HashMap<String, String>) -> Option<u32> {
+    registry
+        .get("registry/hkcu/software/valve/steam/activeprocess/activeuser")
+        .and_then(|v| v.trim().parse().ok())
+}
+
+#[cfg(target_os = "linux")]
+fn read_active_user() -> Option<u32> {
+    let home = std::env::var_os("HOME")?;
+    let path = std::path::Path::new(&home)
+        .join(".steam")
+        .join("registry.vdf");
+    let text = std::fs::read_to_string(path).ok()?;
+    active_user_from_registry_vdf(&flatten_text_vdf(&text).ok()?)
+}
+
+#[cfg(target_os = "windows")]
+fn read_active_user() -> Option<u32> {
+    use winreg::RegKey;
+    use winreg::enums::HKEY_CURRENT_USER;
+
+    let key = RegKey::predef(HKEY_CURRENT_USER)
+        .open_subkey(r"Software\Valve\Steam\ActiveProcess")
+        .ok()?;
+    key.get_value::<u32, _>("ActiveUser").ok()
+}
+
+#[cfg(not(any(target_os = "linux", target_os = "windows")))]
+fn read_active_user() -> Option<u32> {
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const LOGIN_USERS: &str = r#"
+"users"
+{
+	"76561198000000001"
+	{
+		"AccountName"		"deck"
+		"PersonaName"		"Deck"
+		"RememberPassword"		"1"
+		"WantsOfflineMode"		"0"
+		"MostRecent"		"0"
+	}
+	"76561198000000002"
+	{
+		"AccountName"		"travel"
+		"PersonaName"		"Travel"
+		"WantsOfflineMode"		"1"
+		"MostRecent"		"1"
+	}
+}
+"#;
+
+    const REGISTRY: &str = r#"
+"Registry"
+{
+	"HKCU"
+	{
+		"Software"
+		{
+			"Valve"
+			{
+				"Steam"
+				{
+					"ActiveProcess"
+					{
+						"pid"		"4242"
+						"ActiveUser"		"39734274"
+					}
+				}
+			}
+		}
+	}
+}
+"#;
+
+    fn users() -> HashMap<String, String> {
+        flatten_text_vdf(LOGIN_USERS).unwrap()
+    }
+
+    // 76561198000000001 - STEAM_ID64_BASE
+    const DECK: u32 = 39_734_273;
+    const TRAVEL: u32 = 39_734_274;
+
+    #[test]
+    fn active_online_account_is_logged_in() {
+        assert_eq!(
+            resolve_login_state(Some(DECK), &users()),
+            LoginState::LoggedIn
+        );
+    }
+
+    #[test]
+    fn active_account_in_offline_mode() {
+        assert_eq!(
+            resolve_login_state(Some(TRAVEL), &users()),
+            LoginState::Offline
+        );
+    }
+
+    #[test]
+    fn zero_active_user_is_logged_out() {
+        assert_eq!(
+            resolve_login_state(Some(0), &users()),
+            LoginState::LoggedOut
+        );
+    }
+
+    #[test]
+    fn missing_active_user_is_unknown() {
+        assert_eq!(resolve_login_state(None, &users()), LoginState::Unknown);
+    }
+
+    #[test]
+    fn unknown_account_falls_back_to_most_recent() {
+        // Account not in loginusers.vdf: the most recent one (offline) decides.
+        assert_eq!(resolve_login_state(Some(7), &users()), LoginState::Offline);
+        // No loginusers.vdf at all: signed in, assume online.
+        assert_eq!(
+            resolve_login_state(Some(7), &HashMap::new()),
+            LoginState::LoggedIn
+        );
+    }
+
+    #[test]
+    fn reads_active_user_from_registry_vdf() {
+        let registry = flatten_text_vdf(REGISTRY).unwrap();
+        assert_eq!(active_user_from_registry_vdf(&registry), Some(TRAVEL));
+        assert_eq!(active_user_from_registry_vdf(&HashMap::new()), None);
+    }
+
+    #[test]
+    fn login_state_wire_strings() {
+        assert_eq!(LoginState::LoggedIn.as_str(), "logged_in");
+        assert_eq!(LoginState::Offline.as_str(), "offline");
+        assert_eq!(LoginState::LoggedOut.as_str(), "logged_out");
+        assert_eq!(LoginState::Unknown.to_string(), "unknown");
+    }
+}
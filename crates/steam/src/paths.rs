@@ -79,6 +79,21 @@ impl Paths {
         &self.base_dir
     }
 
+    /// Returns the path to Steam's global `config/config.vdf`.
+    pub fn config_vdf_path(&self) -> PathBuf {
+        self.base_dir.join("config").join("config.vdf")
+    }
+
+    /// Returns the path to `steamapps/libraryfolders.vdf` (the library list).
+    pub fn library_folders_vdf_path(&self) -> PathBuf {
+        self.base_dir.join("steamapps").join("libraryfolders.vdf")
+    }
+
+    /// Returns the path to `config/loginusers.vdf` (accounts signed in on this machine).
+    pub fn login_users_path(&self) -> PathBuf {
+        self.base_dir.join("config").join("loginusers.vdf")
+    }
+
     /// Returns the userdata directory.
     pub fn user_data_dir(&self) -> PathBuf {
         self.base_dir.join("userdata")
@@ -126,6 +141,20 @@ impl Paths {
         self.grid_dir(user_id)
             .join(artwork_filename(app_id, art_type, ext))
     }
+
+    /// Returns the directory Steam scans for custom startup movies.
+    pub fn boot_video_dir(&self) -> PathBuf {
+        self.base_dir
+            .join("config")
+            .join("uioverrides")
+            .join("movies")
+    }
+
+    /// Returns the path of the boot video installed for a shortcut.
+    pub fn boot_video_path(&self, app_id: u32) -> PathBuf {
+        self.boot_video_dir()
+            .join(format!("capydeploy_{app_id}.webm"))
+    }
 }
 
 /// Generates the filename for artwork based on type.
@@ -1,12 +1,24 @@
 use std::collections::HashMap;
 use std::fs;
+use std::future::Future;
+use std::path::{Path, PathBuf};
 
+use capydeploy_protocol::messages::DeleteShortcutRequest;
 use capydeploy_protocol::{ShortcutConfig, ShortcutInfo};
 use crc32fast::Hasher;
 
 use crate::SteamError;
 use crate::paths::{ArtworkType, Paths};
 
+/// Which Steam accounts receive filesystem artwork for a shortcut.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ArtworkScope {
+    /// Only the account the shortcut was created under.
+    Owner,
+    /// The owner plus every other account whose shortcuts.vdf lists the app.
+    AllUsersWithShortcut,
+}
+
 /// Handles Steam shortcut operations and artwork management.
 pub struct ShortcutManager {
     paths: Paths,
@@ -62,7 +74,7 @@ impl ShortcutManager {
         let grid_dir = self.paths.grid_dir(user_id);
         let mut result = HashMap::new();
 
-        let extensions = ["png", "jpg", "jpeg", "ico"];
+        let extensions = ["png", "jpg", "jpeg", "webp", "gif", "ico"];
         let art_types = [
             (ArtworkType::Grid, format!("{app_id}")),
             (ArtworkType::Hero, format!("{app_id}_hero")),
@@ -104,6 +116,141 @@ impl ShortcutManager {
         Ok(())
     }
 
+    /// Returns the user IDs artwork for `app_id` should be written for.
+    ///
+    /// The owner is `active_user` (the signed-in account, which CEF creates
+    /// shortcuts under) when it has a userdata folder; otherwise the first
+    /// user with shortcuts, then the first user.
+    pub fn artwork_users(
+        &self,
+        app_id: u32,
+        active_user: Option<&str>,
+        scope: ArtworkScope,
+    ) -> Result<Vec<String>, SteamError> {
+        let mut users = crate::users::get_users_with_paths(&self.paths)?;
+        users.sort_by(|a, b| a.id.cmp(&b.id));
+        let owner = active_user
+            .and_then(|id| users.iter().find(|u| u.id == id))
+            .or_else(|| users.iter().find(|u| u.has_shortcuts))
+            .or_else(|| users.first())
+            .ok_or(SteamError::UserNotFound)?;
+
+        let mut ids = vec![owner.id.clone()];
+        if scope == ArtworkScope::AllUsersWithShortcut {
+            for user in users.iter().filter(|u| u.id != owner.id && u.has_shortcuts) {
+                let listed = crate::vdf::load_shortcuts_vdf(&self.paths.shortcuts_path(&user.id))
+                    .map(|shortcuts| shortcuts.iter().any(|s| s.app_id == app_id))
+                    .unwrap_or(false);
+                if listed {
+                    ids.push(user.id.clone());
+                }
+            }
+        }
+        Ok(ids)
+    }
+
+    /// Returns the user whose shortcuts.vdf lists `app_id`, checking
+    /// `active_user` first. A shortcut Steam hasn't saved yet belongs to the
+    /// account [`Self::artwork_users`] picks as owner.
+    pub fn shortcut_owner(
+        &self,
+        app_id: u32,
+        active_user: Option<&str>,
+    ) -> Result<String, SteamError> {
+        let mut users = crate::users::get_users_with_paths(&self.paths)?;
+        users.sort_by(|a, b| a.id.cmp(&b.id));
+        users.sort_by_key(|u| Some(u.id.as_str()) != active_user);
+        let listed = users.iter().filter(|u| u.has_shortcuts).find(|u| {
+            crate::vdf::load_shortcuts_vdf(&self.paths.shortcuts_path(&u.id))
+                .map(|shortcuts| shortcuts.iter().any(|s| s.app_id == app_id))
+                .unwrap_or(false)
+        });
+        match listed {
+            Some(user) => Ok(user.id.clone()),
+            None => Ok(self
+                .artwork_users(app_id, active_user, ArtworkScope::Owner)?
+                .remove(0)),
+        }
+    }
+
+    /// Moves every existing artwork file from `old_app_id` to `new_app_id`.
+    ///
+    /// Files keep their type suffix and extension. If any move fails (or a
+    /// destination already exists), the moves done so far are undone and
+    /// the error is returned, leaving the grid directory untouched.
+    pub fn migrate_artwork(
+        &self,
+        user_id: &str,
+        old_app_id: u32,
+        new_app_id: u32,
+    ) -> Result<ArtworkMigration, SteamError> {
+        let mut migration = ArtworkMigration::default();
+        if old_app_id == new_app_id {
+            return Ok(migration);
+        }
+
+        let existing = self.find_existing_artwork(user_id, old_app_id)?;
+
+        // Deterministic order so partial failures are reproducible.
+        for art_type in ArtworkType::all() {
+            let Some(src) = existing.get(art_type) else {
+                continue;
+            };
+            let src = PathBuf::from(src);
+            let ext = src.extension().and_then(|e| e.to_str()).unwrap_or("png");
+            let dst = self.paths.artwork_path(user_id, new_app_id, *art_type, ext);
+
+            let result = if dst.exists() {
+                Err(SteamError::Io(format!(
+                    "artwork destination already exists: {}",
+                    dst.display()
+                )))
+            } else {
+                fs::rename(&src, &dst)
+                    .map_err(|e| SteamError::Io(format!("failed to move {}: {e}", src.display())))
+            };
+
+            if let Err(e) = result {
+                if let Err(rb) = migration.rollback() {
+                    tracing::error!("artwork migration rollback failed: {rb}");
+                }
+                return Err(e);
+            }
+            migration.moves.push((src, dst));
+        }
+
+        Ok(migration)
+    }
+
+    /// Renames a shortcut's artwork from `old_app_id` to `new_app_id`, then
+    /// runs `apply` (typically the CEF rename).
+    ///
+    /// If `apply` fails, the artwork is moved back so the shortcut and its
+    /// images stay consistent. Returns the number of artwork files moved.
+    pub async fn rename_with_artwork<F, Fut>(
+        &self,
+        user_id: &str,
+        old_app_id: u32,
+        new_app_id: u32,
+        apply: F,
+    ) -> Result<usize, SteamError>
+    where
+        F: FnOnce() -> Fut,
+        Fut: Future<Output = Result<(), SteamError>>,
+    {
+        let migration = self.migrate_artwork(user_id, old_app_id, new_app_id)?;
+        let moved = migration.len();
+
+        if let Err(e) = apply().await {
+            if let Err(rb) = migration.rollback() {
+                tracing::error!("artwork migration rollback failed: {rb}");
+            }
+            return Err(e);
+        }
+
+        Ok(moved)
+    }
+
     /// Removes all artwork for an app ID.
     pub fn delete_artwork(&self, user_id: &str, app_id: u32) -> Result<(), SteamError> {
         let existing = self.find_existing_artwork(user_id, app_id)?;
@@ -116,6 +263,59 @@ impl ShortcutManager {
         }
         Ok(())
     }
+
+    /// Removes the boot video installed for a shortcut, if any.
+    pub fn delete_boot_video(&self, app_id: u32) -> Result<bool, SteamError> {
+        crate::boot_video::remove_boot_video(&self.paths, app_id)
+    }
+
+    /// Resolves the AppID of the shortcut a delete request targets, looking
+    /// it up in the user's shortcuts.vdf unless the request carries one.
+    pub fn resolve_delete_target(
+        &self,
+        user_id: &str,
+        req: &DeleteShortcutRequest,
+    ) -> Result<u32, SteamError> {
+        if req.app_id != 0 {
+            return Ok(req.app_id);
+        }
+        let shortcuts = crate::vdf::load_shortcuts_vdf(Path::new(&self.shortcuts_path(user_id)))?;
+        resolve_delete_target(&shortcuts, req)
+    }
+}
+
+/// Record of artwork files moved by [`ShortcutManager::migrate_artwork`].
+#[derive(Debug, Default)]
+pub struct ArtworkMigration {
+    moves: Vec<(PathBuf, PathBuf)>,
+}
+
+impl ArtworkMigration {
+    /// Returns the number of files moved.
+    pub fn len(&self) -> usize {
+        self.moves.len()
+    }
+
+    /// Returns true if no files were moved.
+    pub fn is_empty(&self) -> bool {
+        self.moves.is_empty()
+    }
+
+    /// Moves every file back to its original location (newest first).
+    pub fn rollback(self) -> Result<(), SteamError> {
+        let mut first_err = None;
+        for (src, dst) in self.moves.into_iter().rev() {
+            if let Err(e) = fs::rename(&dst, &src)
+                && first_err.is_none()
+            {
+                first_err = Some(SteamError::Io(format!(
+                    "failed to restore {}: {e}",
+                    src.display()
+                )));
+            }
+        }
+        first_err.map_or(Ok(()), Err)
+    }
 }
 
 /// Generates a Steam shortcut app ID from executable path and name.
@@ -129,6 +329,55 @@ pub fn generate_app_id(exe: &str, name: &str) -> u32 {
     (crc | 0x80000000) | 0x02000000
 }
 
+/// Picks the shortcut a delete request targets from `shortcuts`.
+///
+/// An AppID is taken as-is. An exe + start dir pair must match exactly
+/// (ignoring Steam's surrounding quotes), so shortcuts sharing a name stay
+/// distinguishable; a bare name must be unique.
+pub fn resolve_delete_target(
+    shortcuts: &[ShortcutInfo],
+    req: &DeleteShortcutRequest,
+) -> Result<u32, SteamError> {
+    if req.app_id != 0 {
+        return Ok(req.app_id);
+    }
+    let unquote = |s: &str| s.trim_matches('"').to_string();
+    let (what, matches): (String, Vec<u32>) = if !req.exe.is_empty() {
+        let (exe, start_dir) = (unquote(&req.exe), unquote(&req.start_dir));
+        (
+            format!("exe {exe:?} in {start_dir:?}"),
+            shortcuts
+                .iter()
+                .filter(|sc| unquote(&sc.exe) == exe && unquote(&sc.start_dir) == start_dir)
+                .map(|sc| sc.app_id)
+                .collect(),
+        )
+    } else if !req.name.is_empty() {
+        (
+            format!("name {:?}", req.name),
+            shortcuts
+                .iter()
+                .filter(|sc| sc.name == req.name)
+                .map(|sc| sc.app_id)
+                .collect(),
+        )
+    } else {
+        return Err(SteamError::ShortcutNotFound(
+            "no AppID, exe or name given".into(),
+        ));
+    };
+
+    match matches.as_slice() {
+        [] => Err(SteamError::ShortcutNotFound(what)),
+        [app_id] => Ok(*app_id),
+        [first, rest @ ..] if rest.iter().all(|id| id == first) => Ok(*first),
+        _ => Err(SteamError::AmbiguousShortcut(format!(
+            "{} shortcuts match {what}",
+            matches.len()
+        ))),
+    }
+}
+
 /// Converts a `ShortcutConfig` to a `ShortcutInfo`.
 pub fn convert_to_shortcut_info(cfg: &ShortcutConfig) -> ShortcutInfo {
     ShortcutInfo {
@@ -139,9 +388,48 @@ pub fn convert_to_shortcut_info(cfg: &ShortcutConfig) -> ShortcutInfo {
         launch_options: cfg.launch_options.clone(),
         tags: cfg.tags.clone(),
         last_played: 0,
+        needs_compat_tool: false,
+        size_bytes: 0,
+        broken: false,
     }
 }
 
+/// Fills in `broken` (the executable is gone) and `size_bytes` (size of
+/// the game folder) for each shortcut from the local filesystem.
+pub fn annotate_install_state(shortcuts: &mut [ShortcutInfo]) {
+    for sc in shortcuts {
+        let exe = sc.exe.trim_matches('"');
+        sc.broken = !exe.is_empty() && !Path::new(exe).exists();
+        let start_dir = Path::new(sc.start_dir.trim_matches('"'));
+        sc.size_bytes = if start_dir.is_dir() {
+            dir_size(start_dir)
+        } else {
+            0
+        };
+    }
+}
+
+/// Total size of the regular files under `dir`. Symlinks aren't followed
+/// and unreadable entries are skipped.
+fn dir_size(dir: &Path) -> u64 {
+    let Ok(entries) = fs::read_dir(dir) else {
+        return 0;
+    };
+    entries
+        .flatten()
+        .filter_map(|entry| {
+            let meta = entry.path().symlink_metadata().ok()?;
+            if meta.is_dir() {
+                Some(dir_size(&entry.path()))
+            } else if meta.is_file() {
+                Some(meta.len())
+            } else {
+                None
+            }
+        })
+        .sum()
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -161,6 +449,95 @@ mod tests {
         assert_ne!(id & 0x02000000, 0);
     }
 
+    fn delete_by(name: &str, exe: &str, start_dir: &str) -> DeleteShortcutRequest {
+        DeleteShortcutRequest {
+            user_id: 1,
+            app_id: 0,
+            name: name.into(),
+            exe: exe.into(),
+            start_dir: start_dir.into(),
+        }
+    }
+
+    #[test]
+    fn delete_by_exe_and_start_dir_picks_exact_shortcut() {
+        let (sm, tmp) = temp_manager("delete_target");
+        let paths = Paths::with_base(&tmp);
+        fs::create_dir_all(paths.config_dir("1")).unwrap();
+        let vdf = crate::vdf::build_test_vdf(&[
+            (
+                "Game",
+                "\"/games/stable/game.sh\"",
+                "\"/games/stable\"",
+                101,
+            ),
+            ("Game", "\"/games/beta/game.sh\"", "\"/games/beta\"", 102),
+            (
+                "Game",
+                "\"/games/beta/game.sh\"",
+                "\"/games/beta/bin\"",
+                103,
+            ),
+        ]);
+        fs::write(paths.shortcuts_path("1"), vdf).unwrap();
+
+        // The name alone can't tell them apart.
+        assert!(matches!(
+            sm.resolve_delete_target("1", &delete_by("Game", "", "")),
+            Err(SteamError::AmbiguousShortcut(_))
+        ));
+        assert_eq!(
+            sm.resolve_delete_target(
+                "1",
+                &delete_by("Game", "/games/beta/game.sh", "/games/beta")
+            )
+            .unwrap(),
+            102
+        );
+        assert_eq!(
+            sm.resolve_delete_target(
+                "1",
+                &delete_by("", "/games/beta/game.sh", "/games/beta/bin")
+            )
+            .unwrap(),
+            103
+        );
+        assert!(matches!(
+            sm.resolve_delete_target("1", &delete_by("", "/games/beta/game.sh", "/games")),
+            Err(SteamError::ShortcutNotFound(_))
+        ));
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn delete_target_precedence() {
+        let shortcuts = [convert_to_shortcut_info(&ShortcutConfig {
+            name: "Solo".into(),
+            exe: "/g/solo".into(),
+            start_dir: "/g".into(),
+            launch_options: String::new(),
+            tags: Vec::new(),
+            artwork: None,
+            boot_video: String::new(),
+            mark_recent: false,
+            artwork_all_users: false,
+            compat_tool: String::new(),
+        })];
+        let solo = shortcuts[0].app_id;
+        assert_eq!(
+            resolve_delete_target(&shortcuts, &delete_by("Solo", "", "")).unwrap(),
+            solo
+        );
+        // An explicit AppID is trusted without a lookup.
+        let by_id = DeleteShortcutRequest {
+            app_id: 7,
+            ..delete_by("Solo", "", "")
+        };
+        assert_eq!(resolve_delete_target(&shortcuts, &by_id).unwrap(), 7);
+        assert!(resolve_delete_target(&shortcuts, &delete_by("", "", "")).is_err());
+    }
+
     #[test]
     fn generate_app_id_different_inputs() {
         let id1 = generate_app_id("/bin/a", "Game A");
@@ -168,6 +545,215 @@ mod tests {
         assert_ne!(id1, id2);
     }
 
+    fn temp_manager(name: &str) -> (ShortcutManager, PathBuf) {
+        let tmp = std::env::temp_dir().join(format!("capydeploy_test_{name}"));
+        let _ = fs::remove_dir_all(&tmp);
+        let sm = ShortcutManager::with_paths(Paths::with_base(&tmp));
+        sm.ensure_grid_dir("1").unwrap();
+        (sm, tmp)
+    }
+
+    fn write_art(sm: &ShortcutManager, app_id: u32, art_type: ArtworkType, data: &[u8]) {
+        sm.save_artwork("1", app_id, art_type, data, "png").unwrap();
+    }
+
+    /// Steam dir with users 111 (no shortcuts), 222 (has app 500) and
+    /// 333 (other shortcuts only).
+    fn multi_user_manager(name: &str) -> (ShortcutManager, PathBuf) {
+        let tmp = std::env::temp_dir().join(format!("capydeploy_test_{name}"));
+        let _ = fs::remove_dir_all(&tmp);
+        let paths = Paths::with_base(&tmp);
+        fs::create_dir_all(paths.user_dir("111")).unwrap();
+        for (user, app_id) in [("222", 500), ("333", 600)] {
+            fs::create_dir_all(paths.config_dir(user)).unwrap();
+            let vdf = crate::vdf::build_test_vdf(&[("Game", "/g/game", "/g", app_id)]);
+            fs::write(paths.shortcuts_path(user), vdf).unwrap();
+        }
+        (ShortcutManager::with_paths(paths), tmp)
+    }
+
+    #[test]
+    fn artwork_lands_in_active_users_grid() {
+        let (sm, tmp) = multi_user_manager("artwork_owner");
+
+        let users = sm
+            .artwork_users(500, Some("111"), ArtworkScope::Owner)
+            .unwrap();
+        assert_eq!(users, vec!["111"]);
+        for user in &users {
+            sm.save_artwork(user, 500, ArtworkType::Hero, b"hero", "png")
+                .unwrap();
+        }
+        assert_eq!(sm.find_existing_artwork("111", 500).unwrap().len(), 1);
+        assert!(sm.find_existing_artwork("222", 500).unwrap().is_empty());
+
+        // Unknown active user: first account with shortcuts owns it.
+        let users = sm
+            .artwork_users(500, Some("999"), ArtworkScope::Owner)
+            .unwrap();
+        assert_eq!(users, vec!["222"]);
+        let users = sm.artwork_users(500, None, ArtworkScope::Owner).unwrap();
+        assert_eq!(users, vec!["222"]);
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn shortcut_owner_is_the_listing_user() {
+        let (sm, tmp) = multi_user_manager("shortcut_owner");
+
+        // Listed by 222 only, whoever is signed in.
+        assert_eq!(sm.shortcut_owner(500, Some("111")).unwrap(), "222");
+        assert_eq!(sm.shortcut_owner(600, None).unwrap(), "333");
+        // Not saved by Steam yet: the signed-in account.
+        assert_eq!(sm.shortcut_owner(700, Some("111")).unwrap(), "111");
+        assert_eq!(sm.shortcut_owner(700, None).unwrap(), "222");
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn artwork_across_users_with_shortcut() {
+        let (sm, tmp) = multi_user_manager("artwork_all");
+
+        let users = sm
+            .artwork_users(500, Some("111"), ArtworkScope::AllUsersWithShortcut)
+            .unwrap();
+        // 333 has shortcuts, but not this one.
+        assert_eq!(users, vec!["111", "222"]);
+        for user in &users {
+            sm.save_artwork(user, 500, ArtworkType::Grid, b"grid", "png")
+                .unwrap();
+        }
+        assert_eq!(sm.find_existing_artwork("111", 500).unwrap().len(), 1);
+        assert_eq!(sm.find_existing_artwork("222", 500).unwrap().len(), 1);
+        assert!(sm.find_existing_artwork("333", 500).unwrap().is_empty());
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn artwork_users_without_userdata() {
+        let tmp = std::env::temp_dir().join("capydeploy_test_artwork_none");
+        let _ = fs::remove_dir_all(&tmp);
+        fs::create_dir_all(tmp.join("userdata")).unwrap();
+        let sm = ShortcutManager::with_paths(Paths::with_base(&tmp));
+        assert!(matches!(
+            sm.artwork_users(500, None, ArtworkScope::Owner),
+            Err(SteamError::UserNotFound)
+        ));
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn find_existing_artwork_sees_animated_formats() {
+        let (sm, tmp) = temp_manager("find_animated");
+        sm.save_artwork("1", 100, ArtworkType::Hero, b"webp", "webp")
+            .unwrap();
+        sm.save_artwork("1", 100, ArtworkType::Portrait, b"gif", "gif")
+            .unwrap();
+
+        let found = sm.find_existing_artwork("1", 100).unwrap();
+        assert_eq!(found.len(), 2);
+        assert!(found[&ArtworkType::Hero].ends_with("100_hero.webp"));
+        assert!(found[&ArtworkType::Portrait].ends_with("100p.gif"));
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn migrate_artwork_moves_all_types() {
+        let (sm, tmp) = temp_manager("migrate_all");
+        write_art(&sm, 100, ArtworkType::Grid, b"grid");
+        write_art(&sm, 100, ArtworkType::Hero, b"hero");
+        write_art(&sm, 100, ArtworkType::Portrait, b"portrait");
+
+        let migration = sm.migrate_artwork("1", 100, 200).unwrap();
+        assert_eq!(migration.len(), 3);
+
+        assert!(sm.find_existing_artwork("1", 100).unwrap().is_empty());
+        let moved = sm.find_existing_artwork("1", 200).unwrap();
+        assert_eq!(moved.len(), 3);
+        assert_eq!(fs::read(&moved[&ArtworkType::Hero]).unwrap(), b"hero");
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn migrate_artwork_same_id_is_noop() {
+        let (sm, tmp) = temp_manager("migrate_same");
+        write_art(&sm, 100, ArtworkType::Grid, b"grid");
+
+        let migration = sm.migrate_artwork("1", 100, 100).unwrap();
+        assert!(migration.is_empty());
+        assert_eq!(sm.find_existing_artwork("1", 100).unwrap().len(), 1);
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[test]
+    fn migrate_artwork_conflict_rolls_back() {
+        let (sm, tmp) = temp_manager("migrate_conflict");
+        write_art(&sm, 100, ArtworkType::Grid, b"grid");
+        write_art(&sm, 100, ArtworkType::Hero, b"hero");
+        write_art(&sm, 100, ArtworkType::Logo, b"logo");
+        // Pre-existing destination for the logo forces a mid-way failure.
+        write_art(&sm, 200, ArtworkType::Logo, b"other");
+
+        assert!(sm.migrate_artwork("1", 100, 200).is_err());
+
+        let original = sm.find_existing_artwork("1", 100).unwrap();
+        assert_eq!(original.len(), 3);
+        let target = sm.find_existing_artwork("1", 200).unwrap();
+        assert_eq!(target.len(), 1);
+        assert_eq!(fs::read(&target[&ArtworkType::Logo]).unwrap(), b"other");
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[tokio::test]
+    async fn rename_with_artwork_preserves_association() {
+        let (sm, tmp) = temp_manager("rename_ok");
+        let old_id = generate_app_id("/games/a/game.sh", "Old Name");
+        let new_id = generate_app_id("/games/a/game.sh", "New Name");
+        write_art(&sm, old_id, ArtworkType::Grid, b"grid");
+        write_art(&sm, old_id, ArtworkType::Icon, b"icon");
+
+        let moved = sm
+            .rename_with_artwork("1", old_id, new_id, || async { Ok(()) })
+            .await
+            .unwrap();
+        assert_eq!(moved, 2);
+
+        let art = sm.find_existing_artwork("1", new_id).unwrap();
+        assert_eq!(fs::read(&art[&ArtworkType::Grid]).unwrap(), b"grid");
+        assert_eq!(fs::read(&art[&ArtworkType::Icon]).unwrap(), b"icon");
+        assert!(sm.find_existing_artwork("1", old_id).unwrap().is_empty());
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
+    #[tokio::test]
+    async fn rename_with_artwork_rolls_back_on_apply_failure() {
+        let (sm, tmp) = temp_manager("rename_fail");
+        write_art(&sm, 100, ArtworkType::Grid, b"grid");
+        write_art(&sm, 100, ArtworkType::Hero, b"hero");
+
+        let result = sm
+            .rename_with_artwork("1", 100, 200, || async {
+                Err(SteamError::Cef("simulated failure".into()))
+            })
+            .await;
+        assert!(matches!(result, Err(SteamError::Cef(_))));
+
+        let art = sm.find_existing_artwork("1", 100).unwrap();
+        assert_eq!(art.len(), 2);
+        assert_eq!(fs::read(&art[&ArtworkType::Hero]).unwrap(), b"hero");
+        assert!(sm.find_existing_artwork("1", 200).unwrap().is_empty());
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
+
     #[test]
     fn convert_to_shortcut_info_basic() {
         let cfg = ShortcutConfig {
@@ -177,6 +763,10 @@ mod tests {
             launch_options: "--fullscreen".into(),
             tags: vec!["RPG".into()],
             artwork: None,
+            boot_video: String::new(),
+            mark_recent: false,
+            artwork_all_users: false,
+            compat_tool: String::new(),
         };
         let info = convert_to_shortcut_info(&cfg);
         assert_eq!(info.name, "Test");
@@ -185,4 +775,49 @@ mod tests {
         assert_eq!(info.tags, vec!["RPG"]);
         assert_eq!(info.last_played, 0);
     }
+
+    #[test]
+    fn annotate_install_state_flags_missing_exe_and_sizes_folder() {
+        let tmp = std::env::temp_dir().join("capydeploy_test_install_state");
+        let _ = fs::remove_dir_all(&tmp);
+        fs::create_dir_all(tmp.join("game/data")).unwrap();
+        fs::write(tmp.join("game/game.x86_64"), vec![0u8; 1000]).unwrap();
+        fs::write(tmp.join("game/data/pak0"), vec![0u8; 24]).unwrap();
+
+        let shortcut = |name: &str, exe: String, start_dir: String| {
+            convert_to_shortcut_info(&ShortcutConfig {
+                name: name.into(),
+                exe,
+                start_dir,
+                launch_options: String::new(),
+                tags: vec![],
+                artwork: None,
+                boot_video: String::new(),
+                mark_recent: false,
+                artwork_all_users: false,
+                compat_tool: String::new(),
+            })
+        };
+        let game = tmp.join("game");
+        let mut list = vec![
+            shortcut(
+                "Present",
+                format!("\"{}\"", game.join("game.x86_64").display()),
+                format!("\"{}\"", game.display()),
+            ),
+            shortcut(
+                "Gone",
+                tmp.join("gone/gone.exe").display().to_string(),
+                tmp.join("gone").display().to_string(),
+            ),
+        ];
+        annotate_install_state(&mut list);
+
+        assert!(!list[0].broken);
+        assert_eq!(list[0].size_bytes, 1024);
+        assert!(list[1].broken);
+        assert_eq!(list[1].size_bytes, 0);
+
+        let _ = fs::remove_dir_all(&tmp);
+    }
 }
@@ -1,4 +1,6 @@
+use std::collections::HashMap;
 use std::fs;
+use std::ops::Range;
 use std::path::Path;
 
 use capydeploy_protocol::ShortcutInfo;
@@ -18,6 +20,142 @@ pub fn load_shortcuts_vdf(path: &Path) -> Result<Vec<ShortcutInfo>, SteamError>
     parse_shortcuts_vdf(&data)
 }
 
+/// Replaces the tags of the shortcut with `app_id` in a shortcuts file.
+///
+/// Steam only reads the file at startup and rewrites it whenever its own
+/// shortcuts change, so the new tags show after Steam restarts. Returns
+/// `Ok(false)` when the file has no such shortcut (e.g. Steam hasn't saved
+/// a freshly created one yet).
+pub fn set_shortcut_tags_vdf(
+    path: &Path,
+    app_id: u32,
+    tags: &[String],
+) -> Result<bool, SteamError> {
+    let data = fs::read(path)
+        .map_err(|e| SteamError::Vdf(format!("failed to read shortcuts file: {e}")))?;
+    let Some(updated) = replace_shortcut_tags(&data, app_id, tags)? else {
+        return Ok(false);
+    };
+
+    // Write next to the original and swap, so a crash can't truncate it.
+    let tmp = path.with_extension("vdf.tmp");
+    fs::write(&tmp, &updated)
+        .and_then(|_| fs::rename(&tmp, path))
+        .map_err(|e| SteamError::Vdf(format!("failed to write shortcuts file: {e}")))?;
+    Ok(true)
+}
+
+/// Returns `data` with the tags object of the shortcut with `app_id`
+/// replaced (or added), or `None` when there is no such shortcut.
+fn replace_shortcut_tags(
+    data: &[u8],
+    app_id: u32,
+    tags: &[String],
+) -> Result<Option<Vec<u8>>, SteamError> {
+    let mut pos = shortcuts_body_start(data)?;
+    while pos < data.len() && data[pos] != VDF_TYPE_END {
+        if data[pos] != VDF_TYPE_OBJECT {
+            return Err(SteamError::Vdf(format!(
+                "expected object marker for shortcut at pos {pos}, got 0x{:02x}",
+                data[pos]
+            )));
+        }
+        let (_, entry_start) = read_string(data, pos + 1)?;
+        let entry = scan_shortcut_entry(data, entry_start)?;
+        if entry.app_id == app_id {
+            let span = entry.tags.unwrap_or(entry.end..entry.end);
+            let mut out = Vec::with_capacity(data.len() + 64);
+            out.extend_from_slice(&data[..span.start]);
+            encode_tags(&mut out, tags);
+            out.extend_from_slice(&data[span.end..]);
+            return Ok(Some(out));
+        }
+        pos = entry.end + 1;
+    }
+    Ok(None)
+}
+
+/// Checks the root `shortcuts` object and returns where its entries start.
+fn shortcuts_body_start(data: &[u8]) -> Result<usize, SteamError> {
+    if data.len() < 3 || data[0] != VDF_TYPE_OBJECT {
+        return Err(SteamError::Vdf("not a shortcuts file".into()));
+    }
+    let (name, pos) = read_string(data, 1)?;
+    if name != "shortcuts" {
+        return Err(SteamError::Vdf(format!(
+            "expected root key 'shortcuts', got '{name}'"
+        )));
+    }
+    Ok(pos)
+}
+
+/// Where the interesting parts of one shortcut entry are.
+struct EntrySpans {
+    app_id: u32,
+    /// The whole `tags` field, type marker through end marker.
+    tags: Option<Range<usize>>,
+    /// Position of the entry's end marker.
+    end: usize,
+}
+
+fn scan_shortcut_entry(data: &[u8], mut pos: usize) -> Result<EntrySpans, SteamError> {
+    let mut app_id = 0;
+    let mut tags = None;
+    while pos < data.len() {
+        if data[pos] == VDF_TYPE_END {
+            return Ok(EntrySpans {
+                app_id,
+                tags,
+                end: pos,
+            });
+        }
+        let field_start = pos;
+        let type_byte = data[pos];
+        let (key, value_pos) = read_string(data, pos + 1)?;
+        pos = match type_byte {
+            VDF_TYPE_STRING => read_string(data, value_pos)?.1,
+            VDF_TYPE_INT32 => {
+                let bytes = data.get(value_pos..value_pos + 4).ok_or_else(|| {
+                    SteamError::Vdf(format!("unexpected end of data reading int32 for '{key}'"))
+                })?;
+                if key.eq_ignore_ascii_case("appid") {
+                    app_id = u32::from_le_bytes([bytes[0], bytes[1], bytes[2], bytes[3]]);
+                }
+                value_pos + 4
+            }
+            VDF_TYPE_OBJECT => {
+                let end = skip_object(data, value_pos)?;
+                if key.eq_ignore_ascii_case("tags") {
+                    tags = Some(field_start..end);
+                }
+                end
+            }
+            _ => {
+                return Err(SteamError::Vdf(format!(
+                    "unknown type marker 0x{type_byte:02x} for key '{key}' at pos {field_start}"
+                )));
+            }
+        };
+    }
+    Err(SteamError::Vdf(
+        "unexpected end of data in shortcut entry".into(),
+    ))
+}
+
+/// Appends a `tags` object holding `tags` under keys "0", "1", ...
+fn encode_tags(out: &mut Vec<u8>, tags: &[String]) {
+    out.push(VDF_TYPE_OBJECT);
+    out.extend_from_slice(b"tags\x00");
+    for (i, tag) in tags.iter().enumerate() {
+        out.push(VDF_TYPE_STRING);
+        out.extend_from_slice(i.to_string().as_bytes());
+        out.push(0x00);
+        out.extend_from_slice(tag.as_bytes());
+        out.push(0x00);
+    }
+    out.push(VDF_TYPE_END);
+}
+
 /// Parses binary VDF data into shortcuts.
 fn parse_shortcuts_vdf(data: &[u8]) -> Result<Vec<ShortcutInfo>, SteamError> {
     if data.len() < 3 {
@@ -82,6 +220,9 @@ fn parse_shortcut_entry(data: &[u8], mut pos: usize) -> Result<(ShortcutInfo, us
         launch_options: String::new(),
         tags: vec![],
         last_played: 0,
+        needs_compat_tool: false,
+        size_bytes: 0,
+        broken: false,
     };
 
     while pos < data.len() {
@@ -242,55 +383,159 @@ fn read_string(data: &[u8], pos: usize) -> Result<(String, usize), SteamError> {
     )))
 }
 
-#[cfg(test)]
-mod tests {
-    use super::*;
+/// Flattens a text VDF document into a map of lowercase, `/`-joined key
+/// paths to values, e.g. `users/76561198000000000/mostrecent` → `1`.
+pub(crate) fn flatten_text_vdf(text: &str) -> Result<HashMap<String, String>, SteamError> {
+    let mut values = HashMap::new();
+    let mut stack: Vec<String> = Vec::new();
+    let mut pending_key: Option<String> = None;
+
+    for token in tokenize(text)? {
+        match token {
+            Token::Open => {
+                let key = pending_key
+                    .take()
+                    .ok_or_else(|| SteamError::Vdf("'{' without a key".into()))?;
+                stack.push(key.to_ascii_lowercase());
+            }
+            Token::Close => {
+                if stack.pop().is_none() {
+                    return Err(SteamError::Vdf("unbalanced '}'".into()));
+                }
+            }
+            Token::Str(s) => match pending_key.take() {
+                None => pending_key = Some(s),
+                Some(key) => {
+                    let mut path = stack.join("/");
+                    if !path.is_empty() {
+                        path.push('/');
+                    }
+                    path.push_str(&key.to_ascii_lowercase());
+                    values.insert(path, s);
+                }
+            },
+        }
+    }
 
-    /// Builds a minimal valid shortcuts.vdf binary.
-    fn build_test_vdf(shortcuts: &[(&str, &str, &str, u32)]) -> Vec<u8> {
-        let mut data = Vec::new();
-        // Root: \x00 "shortcuts" \x00
-        data.push(VDF_TYPE_OBJECT);
-        data.extend_from_slice(b"shortcuts\x00");
+    if !stack.is_empty() {
+        return Err(SteamError::Vdf("unterminated object".into()));
+    }
+    Ok(values)
+}
 
-        for (i, (name, exe, start_dir, app_id)) in shortcuts.iter().enumerate() {
-            // Entry: \x00 "<index>" \x00
-            data.push(VDF_TYPE_OBJECT);
-            data.extend_from_slice(i.to_string().as_bytes());
-            data.push(0x00);
-
-            // appid (int32)
-            data.push(VDF_TYPE_INT32);
-            data.extend_from_slice(b"appid\x00");
-            data.extend_from_slice(&app_id.to_le_bytes());
-
-            // AppName (string)
-            data.push(VDF_TYPE_STRING);
-            data.extend_from_slice(b"AppName\x00");
-            data.extend_from_slice(name.as_bytes());
-            data.push(0x00);
-
-            // Exe (string)
-            data.push(VDF_TYPE_STRING);
-            data.extend_from_slice(b"Exe\x00");
-            data.extend_from_slice(exe.as_bytes());
-            data.push(0x00);
-
-            // StartDir (string)
-            data.push(VDF_TYPE_STRING);
-            data.extend_from_slice(b"StartDir\x00");
-            data.extend_from_slice(start_dir.as_bytes());
-            data.push(0x00);
-
-            // End of entry
-            data.push(VDF_TYPE_END);
+/// Token of a text VDF document (config.vdf, loginusers.vdf, ...).
+pub(crate) enum Token {
+    Str(String),
+    Open,
+    Close,
+}
+
+/// Splits a text VDF document into tokens.
+pub(crate) fn tokenize(text: &str) -> Result<Vec<Token>, SteamError> {
+    let mut tokens = Vec::new();
+    let mut chars = text.chars().peekable();
+
+    while let Some(c) = chars.next() {
+        match c {
+            '{' => tokens.push(Token::Open),
+            '}' => tokens.push(Token::Close),
+            '"' => {
+                let mut s = String::new();
+                loop {
+                    match chars.next() {
+                        Some('\\') => {
+                            if let Some(esc) = chars.next() {
+                                s.push(match esc {
+                                    'n' => '\n',
+                                    't' => '\t',
+                                    other => other,
+                                });
+                            }
+                        }
+                        Some('"') => break,
+                        Some(ch) => s.push(ch),
+                        None => return Err(SteamError::Vdf("unterminated string".into())),
+                    }
+                }
+                tokens.push(Token::Str(s));
+            }
+            '/' if chars.peek() == Some(&'/') => {
+                // Line comment.
+                for ch in chars.by_ref() {
+                    if ch == '\n' {
+                        break;
+                    }
+                }
+            }
+            c if c.is_whitespace() => {}
+            other => {
+                // Unquoted token (rare in config.vdf, but valid VDF).
+                let mut s = String::from(other);
+                while let Some(&ch) = chars.peek() {
+                    if ch.is_whitespace() || ch == '{' || ch == '}' || ch == '"' {
+                        break;
+                    }
+                    s.push(ch);
+                    chars.next();
+                }
+                tokens.push(Token::Str(s));
+            }
         }
+    }
 
-        // End of root
+    Ok(tokens)
+}
+
+#[cfg(test)]
+/// Builds a minimal valid shortcuts.vdf binary.
+pub(crate) fn build_test_vdf(shortcuts: &[(&str, &str, &str, u32)]) -> Vec<u8> {
+    let mut data = Vec::new();
+    // Root: \x00 "shortcuts" \x00
+    data.push(VDF_TYPE_OBJECT);
+    data.extend_from_slice(b"shortcuts\x00");
+
+    for (i, (name, exe, start_dir, app_id)) in shortcuts.iter().enumerate() {
+        // Entry: \x00 "<index>" \x00
+        data.push(VDF_TYPE_OBJECT);
+        data.extend_from_slice(i.to_string().as_bytes());
+        data.push(0x00);
+
+        // appid (int32)
+        data.push(VDF_TYPE_INT32);
+        data.extend_from_slice(b"appid\x00");
+        data.extend_from_slice(&app_id.to_le_bytes());
+
+        // AppName (string)
+        data.push(VDF_TYPE_STRING);
+        data.extend_from_slice(b"AppName\x00");
+        data.extend_from_slice(name.as_bytes());
+        data.push(0x00);
+
+        // Exe (string)
+        data.push(VDF_TYPE_STRING);
+        data.extend_from_slice(b"Exe\x00");
+        data.extend_from_slice(exe.as_bytes());
+        data.push(0x00);
+
+        // StartDir (string)
+        data.push(VDF_TYPE_STRING);
+        data.extend_from_slice(b"StartDir\x00");
+        data.extend_from_slice(start_dir.as_bytes());
+        data.push(0x00);
+
+        // End of entry
         data.push(VDF_TYPE_END);
-        data
     }
 
+    // End of root
+    data.push(VDF_TYPE_END);
+    data
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
     #[test]
     fn parse_empty_shortcuts() {
         let data = build_test_vdf(&[]);
@@ -298,6 +543,42 @@ mod tests {
         assert!(shortcuts.is_empty());
     }
 
+    #[test]
+    fn replace_tags_adds_and_replaces() {
+        let data = build_test_vdf(&[("A", "/a", "/", 1), ("B", "/b", "/", 2)]);
+        let tags = vec!["RPG".to_string(), "Jam".to_string()];
+
+        let added = replace_shortcut_tags(&data, 2, &tags).unwrap().unwrap();
+        let shortcuts = parse_shortcuts_vdf(&added).unwrap();
+        assert!(shortcuts[0].tags.is_empty());
+        assert_eq!(shortcuts[1].tags, tags);
+        assert_eq!(shortcuts[1].name, "B");
+
+        let replaced = replace_shortcut_tags(&added, 2, &["Demo".to_string()])
+            .unwrap()
+            .unwrap();
+        let shortcuts = parse_shortcuts_vdf(&replaced).unwrap();
+        assert_eq!(shortcuts[1].tags, vec!["Demo"]);
+        assert_eq!(shortcuts.len(), 2);
+
+        assert!(replace_shortcut_tags(&data, 3, &tags).unwrap().is_none());
+    }
+
+    #[test]
+    fn set_shortcut_tags_vdf_writes_file() {
+        let dir = std::env::temp_dir().join("capydeploy_test_vdf_tags");
+        let _ = fs::remove_dir_all(&dir);
+        fs::create_dir_all(&dir).unwrap();
+        let path = dir.join("shortcuts.vdf");
+        fs::write(&path, build_test_vdf(&[("A", "/a", "/", 7)])).unwrap();
+
+        assert!(set_shortcut_tags_vdf(&path, 7, &["Co-op".to_string()]).unwrap());
+        assert_eq!(load_shortcuts_vdf(&path).unwrap()[0].tags, vec!["Co-op"]);
+        assert!(!set_shortcut_tags_vdf(&path, 8, &[]).unwrap());
+        assert!(!dir.join("shortcuts.vdf.tmp").exists());
+        let _ = fs::remove_dir_all(&dir);
+    }
+
     #[test]
     fn parse_single_shortcut() {
         let data = build_test_vdf(&[("Test Game", "/usr/bin/game", "/home/user", 12345)]);
@@ -2,8 +2,26 @@
 //!
 //! Images are stored under `~/.config/capydeploy/cache/images/game_<ID>/`
 //! with filenames derived from the SHA-256 hash of the source URL.
+//!
+//! JSON responses of the search and listing endpoints are cached next to
+//! them under `~/.config/capydeploy/cache/metadata/`, keyed by the hash of
+//! the request URL and expired by file age.
+//!
+//! The image cache is capped in size (see [`set_image_cache_max_size`]).
+//! Saving past the cap evicts the least recently used images, going by
+//! file mtime, which reads refresh.
 
 use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::time::{Duration, SystemTime};
+
+/// How long cached API responses stay fresh by default.
+pub const DEFAULT_METADATA_CACHE_TTL: Duration = Duration::from_secs(60 * 60);
+
+/// Default size cap of the image cache.
+pub const DEFAULT_IMAGE_CACHE_MAX_SIZE: u64 = 500 * 1024 * 1024;
+
+static IMAGE_CACHE_MAX_SIZE: AtomicU64 = AtomicU64::new(DEFAULT_IMAGE_CACHE_MAX_SIZE);
 
 use sha2::{Digest, Sha256};
 
@@ -56,6 +74,26 @@ pub fn save_image_to_cache(
     save_image_to_cache_in(&base, game_id, image_url, data, content_type)
 }
 
+/// Sets the size cap of the image cache in bytes; 0 means unlimited.
+///
+/// Applies to later saves; call [`enforce_image_cache_max_size`] to trim
+/// the cache right away.
+pub fn set_image_cache_max_size(bytes: u64) {
+    IMAGE_CACHE_MAX_SIZE.store(bytes, Ordering::Relaxed);
+}
+
+/// Returns the size cap of the image cache in bytes; 0 means unlimited.
+pub fn image_cache_max_size() -> u64 {
+    IMAGE_CACHE_MAX_SIZE.load(Ordering::Relaxed)
+}
+
+/// Evicts least recently used images until the cache fits its cap.
+/// Returns the number of bytes freed.
+pub fn enforce_image_cache_max_size() -> Result<u64, CacheError> {
+    let base = image_cache_dir()?;
+    Ok(evict_lru_in(&base, image_cache_max_size(), None))
+}
+
 /// Clears all cached images.
 pub fn clear_image_cache() -> Result<(), CacheError> {
     let base = image_cache_dir()?;
@@ -68,10 +106,63 @@ pub fn get_cache_size() -> Result<u64, CacheError> {
     Ok(cache_size_in(&base))
 }
 
+/// Returns the metadata cache directory.
+///
+/// Creates the directory if it doesn't exist.
+pub fn metadata_cache_dir() -> Result<PathBuf, CacheError> {
+    let base = config_dir().ok_or(CacheError::NoCacheDir)?;
+    metadata_cache_dir_in(&base)
+}
+
+/// Clears all cached API responses.
+pub fn clear_metadata_cache() -> Result<(), CacheError> {
+    let base = metadata_cache_dir()?;
+    clear_cache_in(&base)
+}
+
 // ---------------------------------------------------------------------------
 // Internal functions accepting an explicit base directory (testable).
 // ---------------------------------------------------------------------------
 
+fn metadata_cache_dir_in(config_base: &Path) -> Result<PathBuf, CacheError> {
+    let cache_dir = config_base
+        .join("capydeploy")
+        .join("cache")
+        .join("metadata");
+    std::fs::create_dir_all(&cache_dir)?;
+    Ok(cache_dir)
+}
+
+/// Returns the cached response for `key` if it is younger than `ttl`.
+pub(crate) fn get_cached_metadata_in(
+    metadata_dir: &Path,
+    key: &str,
+    ttl: Duration,
+) -> Option<Vec<u8>> {
+    let path = metadata_dir.join(format!("{}.json", hash_url(key)));
+    let age = std::fs::metadata(&path)
+        .ok()?
+        .modified()
+        .ok()?
+        .elapsed()
+        .ok()?;
+    if age >= ttl {
+        return None;
+    }
+    std::fs::read(path).ok()
+}
+
+pub(crate) fn save_metadata_to_cache_in(
+    metadata_dir: &Path,
+    key: &str,
+    data: &[u8],
+) -> Result<(), CacheError> {
+    std::fs::create_dir_all(metadata_dir)?;
+    let path = metadata_dir.join(format!("{}.json", hash_url(key)));
+    std::fs::write(path, data)?;
+    Ok(())
+}
+
 fn image_cache_dir_in(config_base: &Path) -> Result<PathBuf, CacheError> {
     let cache_dir = config_base.join("capydeploy").join("cache").join("images");
     std::fs::create_dir_all(&cache_dir)?;
@@ -84,7 +175,7 @@ fn game_cache_dir_in(images_dir: &Path, game_id: i32) -> Result<PathBuf, CacheEr
     Ok(game_dir)
 }
 
-fn get_cached_image_in(
+pub(crate) fn get_cached_image_in(
     images_dir: &Path,
     game_id: i32,
     image_url: &str,
@@ -98,6 +189,7 @@ fn get_cached_image_in(
         let name = name.to_string_lossy();
         if name.starts_with(&hash) {
             let data = std::fs::read(entry.path())?;
+            touch(&entry.path());
             let ext = entry
                 .path()
                 .extension()
@@ -115,7 +207,7 @@ fn get_cached_image_in(
     )))
 }
 
-fn get_cached_image_path_in(
+pub(crate) fn get_cached_image_path_in(
     images_dir: &Path,
     game_id: i32,
     image_url: &str,
@@ -138,21 +230,83 @@ fn get_cached_image_path_in(
     )))
 }
 
-fn save_image_to_cache_in(
+pub(crate) fn save_image_to_cache_in(
+    images_dir: &Path,
+    game_id: i32,
+    image_url: &str,
+    data: &[u8],
+    content_type: &str,
+) -> Result<(), CacheError> {
+    save_image_capped_in(
+        images_dir,
+        game_id,
+        image_url,
+        data,
+        content_type,
+        image_cache_max_size(),
+    )
+}
+
+/// Saves an image and evicts older ones past `max_size` (0 = unlimited).
+fn save_image_capped_in(
     images_dir: &Path,
     game_id: i32,
     image_url: &str,
     data: &[u8],
     content_type: &str,
+    max_size: u64,
 ) -> Result<(), CacheError> {
     let game_dir = game_cache_dir_in(images_dir, game_id)?;
     let hash = hash_url(image_url);
     let ext = content_type_to_ext(content_type);
     let path = game_dir.join(format!("{hash}{ext}"));
-    std::fs::write(path, data)?;
+    std::fs::write(&path, data)?;
+    evict_lru_in(images_dir, max_size, Some(&path));
     Ok(())
 }
 
+/// Deletes the least recently used images until the cache is at most
+/// `max_size` bytes, never deleting `keep`. Returns the bytes freed.
+fn evict_lru_in(images_dir: &Path, max_size: u64, keep: Option<&Path>) -> u64 {
+    if max_size == 0 {
+        return 0;
+    }
+    let mut files = Vec::new();
+    collect_files(images_dir, &mut files);
+    let mut total: u64 = files.iter().map(|(_, size, _)| size).sum();
+    if total <= max_size {
+        return 0;
+    }
+
+    files.sort_by_key(|(_, _, used)| *used);
+    let mut freed = 0;
+    for (path, size, _) in files {
+        if total <= max_size {
+            break;
+        }
+        if keep == Some(path.as_path()) {
+            continue;
+        }
+        match std::fs::remove_file(&path) {
+            Ok(()) => {
+                total -= size;
+                freed += size;
+            }
+            Err(e) => {
+                tracing::warn!(path = %path.display(), error = %e, "failed to evict cached image")
+            }
+        }
+    }
+    freed
+}
+
+/// Marks a cached file as just used for LRU eviction.
+fn touch(path: &Path) {
+    if let Ok(file) = std::fs::File::options().write(true).open(path) {
+        let _ = file.set_modified(SystemTime::now());
+    }
+}
+
 fn clear_cache_in(images_dir: &Path) -> Result<(), CacheError> {
     let entries = std::fs::read_dir(images_dir)?;
     for entry in entries.flatten() {
@@ -187,6 +341,22 @@ fn walk_dir(dir: &Path, size: &mut u64) {
     }
 }
 
+/// Recursively lists files with their size and last-used time.
+fn collect_files(dir: &Path, files: &mut Vec<(PathBuf, u64, SystemTime)>) {
+    let Ok(entries) = std::fs::read_dir(dir) else {
+        return;
+    };
+    for entry in entries.flatten() {
+        let path = entry.path();
+        if path.is_dir() {
+            collect_files(&path, files);
+        } else if let Ok(meta) = entry.metadata() {
+            let used = meta.modified().unwrap_or(SystemTime::UNIX_EPOCH);
+            files.push((path, meta.len(), used));
+        }
+    }
+}
+
 /// Creates a deterministic filename hash from a URL.
 ///
 /// Uses first 16 bytes of SHA-256 (32 hex characters).
@@ -205,6 +375,16 @@ fn content_type_to_ext(content_type: &str) -> &'static str {
     }
 }
 
+/// Guesses an image content type from the extension in a URL path.
+pub fn content_type_for_url(url: &str) -> String {
+    let path = url.split(['?', '#']).next().unwrap_or_default();
+    let ext = path
+        .rsplit_once('.')
+        .map(|(_, ext)| ext.to_ascii_lowercase())
+        .unwrap_or_default();
+    ext_to_content_type(if ext == "jpeg" { "jpg" } else { &ext })
+}
+
 /// Maps a file extension to a content type.
 fn ext_to_content_type(ext: &str) -> String {
     match ext {
@@ -306,6 +486,74 @@ mod tests {
         assert_eq!(ct, "image/png");
     }
 
+    /// Backdates a cached image so eviction order doesn't depend on
+    /// filesystem timestamp resolution.
+    fn set_used(images: &Path, game_id: i32, url: &str, secs_ago: u64) {
+        let path = get_cached_image_path_in(images, game_id, url).unwrap();
+        let file = std::fs::File::options().write(true).open(path).unwrap();
+        file.set_modified(SystemTime::now() - Duration::from_secs(secs_ago))
+            .unwrap();
+    }
+
+    #[test]
+    fn saving_past_the_cap_evicts_least_recently_used() {
+        let (_tmp, images) = test_images_dir();
+        let urls: Vec<String> = (0..4).map(|i| format!("https://a.com/{i}.png")).collect();
+
+        for (i, url) in urls.iter().take(3).enumerate() {
+            save_image_capped_in(&images, 1, url, &[0; 100], "image/png", 300).unwrap();
+            set_used(&images, 1, url, 100 - i as u64 * 10);
+        }
+        // Reading the oldest image makes it the most recently used.
+        get_cached_image_in(&images, 1, &urls[0]).unwrap();
+
+        save_image_capped_in(&images, 2, &urls[3], &[0; 100], "image/png", 300).unwrap();
+
+        assert!(get_cached_image_path_in(&images, 1, &urls[1]).is_err());
+        for url in [&urls[0], &urls[2]] {
+            assert!(get_cached_image_path_in(&images, 1, url).is_ok());
+        }
+        assert!(get_cached_image_path_in(&images, 2, &urls[3]).is_ok());
+        assert_eq!(cache_size_in(&images), 300);
+    }
+
+    #[test]
+    fn new_image_larger_than_the_cap_is_kept() {
+        let (_tmp, images) = test_images_dir();
+        save_image_capped_in(
+            &images,
+            1,
+            "https://a.com/a.png",
+            &[0; 50],
+            "image/png",
+            100,
+        )
+        .unwrap();
+        save_image_capped_in(
+            &images,
+            1,
+            "https://a.com/b.png",
+            &[0; 200],
+            "image/png",
+            100,
+        )
+        .unwrap();
+
+        assert!(get_cached_image_path_in(&images, 1, "https://a.com/a.png").is_err());
+        assert!(get_cached_image_path_in(&images, 1, "https://a.com/b.png").is_ok());
+    }
+
+    #[test]
+    fn zero_cap_is_unlimited() {
+        let (_tmp, images) = test_images_dir();
+        for i in 0..3 {
+            let url = format!("https://a.com/{i}.png");
+            save_image_capped_in(&images, 1, &url, &[0; 100], "image/png", 0).unwrap();
+        }
+        assert_eq!(cache_size_in(&images), 300);
+        assert_eq!(evict_lru_in(&images, 0, None), 0);
+    }
+
     #[test]
     fn get_cached_image_not_found() {
         let (_tmp, images) = test_images_dir();
@@ -371,6 +619,33 @@ mod tests {
         assert_eq!(size_after, data.len() as u64);
     }
 
+    #[test]
+    fn metadata_cache_roundtrip() {
+        let tmp = tempfile::tempdir().unwrap();
+        let dir = metadata_cache_dir_in(tmp.path()).unwrap();
+        let key = "https://api/search/autocomplete/portal";
+
+        assert!(get_cached_metadata_in(&dir, key, Duration::from_secs(60)).is_none());
+
+        save_metadata_to_cache_in(&dir, key, br#"{"data":[]}"#).unwrap();
+        assert_eq!(
+            get_cached_metadata_in(&dir, key, Duration::from_secs(60)).unwrap(),
+            br#"{"data":[]}"#
+        );
+        assert!(
+            get_cached_metadata_in(&dir, "https://api/other", Duration::from_secs(60)).is_none()
+        );
+    }
+
+    #[test]
+    fn metadata_cache_expires() {
+        let tmp = tempfile::tempdir().unwrap();
+        let dir = metadata_cache_dir_in(tmp.path()).unwrap();
+
+        save_metadata_to_cache_in(&dir, "key", b"{}").unwrap();
+        assert!(get_cached_metadata_in(&dir, "key", Duration::ZERO).is_none());
+    }
+
     #[test]
     fn game_cache_dir_contains_game_id() {
         let (_tmp, images) = test_images_dir();
@@ -378,4 +653,15 @@ mod tests {
         assert!(dir.to_string_lossy().contains("game_42"));
         assert!(dir.exists());
     }
+
+    #[test]
+    fn content_type_for_url_uses_extension() {
+        assert_eq!(content_type_for_url("https://cdn/a/b.png"), "image/png");
+        assert_eq!(
+            content_type_for_url("https://cdn/a/b.WEBP?x=1"),
+            "image/webp"
+        );
+        assert_eq!(content_type_for_url("https://cdn/a/b.jpeg"), "image/jpeg");
+        assert_eq!(content_type_for_url("https://cdn/a/b"), "image/jpeg");
+    }
 }
@@ -1,14 +1,33 @@
 //! SteamGridDB API client.
 //!
 //! Async HTTP client using `reqwest` with Bearer token authentication.
+//! API requests are rate limited client-side and retried when the server
+//! answers 429 or 503.
+
+use std::path::PathBuf;
+use std::time::Duration;
 
 use percent_encoding::{NON_ALPHANUMERIC, utf8_percent_encode};
-use reqwest::header::{AUTHORIZATION, HeaderMap, HeaderValue};
+use reqwest::StatusCode;
+use reqwest::header::{AUTHORIZATION, HeaderMap, HeaderValue, RETRY_AFTER};
 
+use crate::cache::{self, CacheError};
+use crate::limiter::{DEFAULT_REQUESTS_PER_SEC, RateLimiter};
+use crate::prefetch::{self, PrefetchStats};
 use crate::types::{ApiResponse, ImageData, ImageFilters, SearchResult};
 
 const DEFAULT_BASE_URL: &str = "https://www.steamgriddb.com/api/v2";
 
+/// Attempts per API request, including the first one.
+const MAX_ATTEMPTS: u32 = 3;
+
+/// Wait before retrying when the server sends no usable Retry-After.
+const DEFAULT_RETRY_DELAY: Duration = Duration::from_secs(1);
+
+/// Longest Retry-After honored; beyond it the request fails right away
+/// rather than leaving the UI hanging.
+const MAX_RETRY_DELAY: Duration = Duration::from_secs(10);
+
 /// Errors from the SteamGridDB client.
 #[derive(Debug, thiserror::Error)]
 pub enum Error {
@@ -23,12 +42,32 @@ pub enum Error {
 
     #[error("invalid API key")]
     InvalidKey,
+
+    /// The API kept answering 429/503 after all retries.
+    #[error("rate limited by SteamGridDB{}", retry_after_suffix(.retry_after))]
+    RateLimited { retry_after: Option<Duration> },
+}
+
+fn retry_after_suffix(retry_after: &Option<Duration>) -> String {
+    match retry_after {
+        Some(d) => format!(", retry after {}s", d.as_secs()),
+        None => String::new(),
+    }
 }
 
 /// SteamGridDB API client.
 pub struct Client {
     http: reqwest::Client,
     base_url: String,
+    limiter: RateLimiter,
+    metadata_cache: Option<MetadataCache>,
+    refresh_metadata: bool,
+}
+
+/// On-disk cache for JSON API responses.
+struct MetadataCache {
+    dir: PathBuf,
+    ttl: Duration,
 }
 
 impl Client {
@@ -47,9 +86,50 @@ impl Client {
         Ok(Self {
             http,
             base_url: DEFAULT_BASE_URL.to_string(),
+            limiter: RateLimiter::new(DEFAULT_REQUESTS_PER_SEC),
+            metadata_cache: None,
+            refresh_metadata: false,
         })
     }
 
+    /// Limits API requests to `per_sec` per second (0 disables limiting).
+    pub fn with_rate_limit(mut self, per_sec: u32) -> Self {
+        self.limiter = RateLimiter::new(per_sec);
+        self
+    }
+
+    /// Caches search and listing responses on disk for `ttl` (usually
+    /// [`DEFAULT_METADATA_CACHE_TTL`](crate::cache::DEFAULT_METADATA_CACHE_TTL)).
+    /// A zero TTL disables the cache, which is also the default.
+    pub fn with_metadata_cache_ttl(mut self, ttl: Duration) -> Self {
+        self.metadata_cache = if ttl.is_zero() {
+            None
+        } else {
+            match cache::metadata_cache_dir() {
+                Ok(dir) => Some(MetadataCache { dir, ttl }),
+                Err(e) => {
+                    tracing::warn!(error = %e, "metadata cache unavailable");
+                    None
+                }
+            }
+        };
+        self
+    }
+
+    /// Skips cached responses and fetches fresh ones, which then replace
+    /// the cached copies.
+    pub fn with_metadata_refresh(mut self, refresh: bool) -> Self {
+        self.refresh_metadata = refresh;
+        self
+    }
+
+    /// Sets a custom metadata cache directory (for testing).
+    #[cfg(test)]
+    pub(crate) fn with_metadata_cache_dir(mut self, dir: PathBuf, ttl: Duration) -> Self {
+        self.metadata_cache = Some(MetadataCache { dir, ttl });
+        self
+    }
+
     /// Sets a custom base URL (for testing).
     #[cfg(test)]
     pub(crate) fn with_base_url(mut self, url: String) -> Self {
@@ -57,33 +137,95 @@ impl Client {
         self
     }
 
-    /// Performs an authenticated GET request.
-    async fn get(&self, endpoint: &str, params: &[(String, String)]) -> Result<Vec<u8>, Error> {
+    /// Performs an authenticated GET request against the API, waiting for
+    /// the rate limiter and retrying 429/503 responses. Fresh responses in
+    /// the metadata cache are returned without a request.
+    async fn do_request(
+        &self,
+        endpoint: &str,
+        params: &[(String, String)],
+    ) -> Result<Vec<u8>, Error> {
         let url = format!("{}{}", self.base_url, endpoint);
-        let resp = self.http.get(&url).query(params).send().await?;
-        let status = resp.status();
+        let cache_key = metadata_cache_key(&url, params);
+
+        if let Some(mc) = &self.metadata_cache
+            && !self.refresh_metadata
+            && let Some(body) = cache::get_cached_metadata_in(&mc.dir, &cache_key, mc.ttl)
+        {
+            return Ok(body);
+        }
+
+        let body = self.send_with_retry(&url, params).await?;
+
+        if let Some(mc) = &self.metadata_cache
+            && let Err(e) = cache::save_metadata_to_cache_in(&mc.dir, &cache_key, &body)
+        {
+            tracing::warn!(url, error = %e, "failed to cache SteamGridDB response");
+        }
+        Ok(body)
+    }
+
+    async fn send_with_retry(
+        &self,
+        url: &str,
+        params: &[(String, String)],
+    ) -> Result<Vec<u8>, Error> {
+        let mut attempt = 1;
+        loop {
+            self.limiter.acquire().await;
+            let resp = self.http.get(url).query(params).send().await?;
+            let status = resp.status();
+
+            if status.is_success() {
+                return Ok(resp.bytes().await?.to_vec());
+            }
+
+            if status == StatusCode::TOO_MANY_REQUESTS || status == StatusCode::SERVICE_UNAVAILABLE
+            {
+                let retry_after = parse_retry_after(resp.headers());
+                let delay = retry_after.unwrap_or(DEFAULT_RETRY_DELAY * attempt);
+                if attempt >= MAX_ATTEMPTS || delay > MAX_RETRY_DELAY {
+                    return Err(Error::RateLimited { retry_after });
+                }
+                tracing::debug!(
+                    status = status.as_u16(),
+                    attempt,
+                    delay_ms = delay.as_millis() as u64,
+                    "SteamGridDB throttled request, retrying"
+                );
+                tokio::time::sleep(delay).await;
+                attempt += 1;
+                continue;
+            }
 
-        if !status.is_success() {
             let body = resp.text().await.unwrap_or_default();
             return Err(Error::Api {
                 status: status.as_u16(),
                 body,
             });
         }
-
-        Ok(resp.bytes().await?.to_vec())
     }
 
     /// Searches for games by name.
     pub async fn search(&self, term: &str) -> Result<Vec<SearchResult>, Error> {
         let encoded = utf8_percent_encode(term, NON_ALPHANUMERIC).to_string();
         let body = self
-            .get(&format!("/search/autocomplete/{encoded}"), &[])
+            .do_request(&format!("/search/autocomplete/{encoded}"), &[])
             .await?;
         let resp: ApiResponse<Vec<SearchResult>> = serde_json::from_slice(&body)?;
         Ok(resp.data)
     }
 
+    /// Looks up the SteamGridDB game for a Steam AppID. More reliable than
+    /// [`search`](Self::search) when the AppID is known.
+    pub async fn get_game_by_steam_app_id(&self, app_id: u32) -> Result<SearchResult, Error> {
+        let body = self
+            .do_request(&format!("/games/steam/{app_id}"), &[])
+            .await?;
+        let resp: ApiResponse<SearchResult> = serde_json::from_slice(&body)?;
+        Ok(resp.data)
+    }
+
     /// Returns grid images for a game.
     pub async fn get_grids(
         &self,
@@ -92,7 +234,9 @@ impl Client {
         page: i32,
     ) -> Result<Vec<ImageData>, Error> {
         let params = build_params(filters, page);
-        let body = self.get(&format!("/grids/game/{game_id}"), &params).await?;
+        let body = self
+            .do_request(&format!("/grids/game/{game_id}"), &params)
+            .await?;
         let resp: ApiResponse<Vec<ImageData>> = serde_json::from_slice(&body)?;
         Ok(resp.data)
     }
@@ -106,7 +250,7 @@ impl Client {
     ) -> Result<Vec<ImageData>, Error> {
         let params = build_params(filters, page);
         let body = self
-            .get(&format!("/heroes/game/{game_id}"), &params)
+            .do_request(&format!("/heroes/game/{game_id}"), &params)
             .await?;
         let resp: ApiResponse<Vec<ImageData>> = serde_json::from_slice(&body)?;
         Ok(resp.data)
@@ -120,7 +264,9 @@ impl Client {
         page: i32,
     ) -> Result<Vec<ImageData>, Error> {
         let params = build_params(filters, page);
-        let body = self.get(&format!("/logos/game/{game_id}"), &params).await?;
+        let body = self
+            .do_request(&format!("/logos/game/{game_id}"), &params)
+            .await?;
         let resp: ApiResponse<Vec<ImageData>> = serde_json::from_slice(&body)?;
         Ok(resp.data)
     }
@@ -133,7 +279,9 @@ impl Client {
         page: i32,
     ) -> Result<Vec<ImageData>, Error> {
         let params = build_params(filters, page);
-        let body = self.get(&format!("/icons/game/{game_id}"), &params).await?;
+        let body = self
+            .do_request(&format!("/icons/game/{game_id}"), &params)
+            .await?;
         let resp: ApiResponse<Vec<ImageData>> = serde_json::from_slice(&body)?;
         Ok(resp.data)
     }
@@ -150,6 +298,53 @@ impl Client {
         }
         Ok(resp.bytes().await?.to_vec())
     }
+
+    /// Downloads `urls` into the image cache for `game_id` in the
+    /// background-friendly way used while browsing: bounded concurrency,
+    /// already-cached images skipped, oversized images dropped.
+    pub async fn prefetch_images(
+        &self,
+        game_id: i32,
+        urls: Vec<String>,
+        concurrency: usize,
+    ) -> Result<PrefetchStats, CacheError> {
+        let http = self.http.clone();
+        prefetch::prefetch_images(game_id, urls, concurrency, move |url| {
+            let http = http.clone();
+            async move {
+                let resp = http.get(&url).send().await?;
+                let status = resp.status();
+                if !status.is_success() {
+                    return Err(Error::Api {
+                        status: status.as_u16(),
+                        body: "download failed".into(),
+                    });
+                }
+                let content_type = cache::content_type_for_url(&url);
+                Ok((resp.bytes().await?.to_vec(), content_type))
+            }
+        })
+        .await
+    }
+}
+
+/// Builds the metadata cache key from the request URL and query.
+fn metadata_cache_key(url: &str, params: &[(String, String)]) -> String {
+    let query: Vec<String> = params.iter().map(|(k, v)| format!("{k}={v}")).collect();
+    format!("{url}?{}", query.join("&"))
+}
+
+/// Parses a Retry-After header given in seconds. The HTTP-date form isn't
+/// used by SteamGridDB and falls back to the default delay.
+fn parse_retry_after(headers: &HeaderMap) -> Option<Duration> {
+    let secs = headers
+        .get(RETRY_AFTER)?
+        .to_str()
+        .ok()?
+        .trim()
+        .parse()
+        .ok()?;
+    Some(Duration::from_secs(secs))
 }
 
 /// Builds query parameters from filters and page.
@@ -244,6 +439,45 @@ mod tests {
         (url, handle)
     }
 
+    /// Starts a mock HTTP server that answers one connection per entry of
+    /// `responses` (raw status line plus headers, without the body) and
+    /// counts the requests served.
+    async fn mock_server_sequence(
+        responses: Vec<&'static str>,
+        body: &'static str,
+    ) -> (
+        String,
+        std::sync::Arc<std::sync::atomic::AtomicUsize>,
+        tokio::task::JoinHandle<()>,
+    ) {
+        let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let port = listener.local_addr().unwrap().port();
+        let url = format!("http://127.0.0.1:{port}");
+        let served = std::sync::Arc::new(std::sync::atomic::AtomicUsize::new(0));
+        let counter = served.clone();
+
+        let handle = tokio::spawn(async move {
+            for head in responses {
+                let Ok((mut stream, _)) = listener.accept().await else {
+                    return;
+                };
+                let mut buf = vec![0u8; 8192];
+                let _ = stream.read(&mut buf).await;
+                counter.fetch_add(1, std::sync::atomic::Ordering::SeqCst);
+
+                let resp = format!(
+                    "{head}\r\nContent-Type: application/json\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{}",
+                    body.len(),
+                    body
+                );
+                let _ = stream.write_all(resp.as_bytes()).await;
+                let _ = stream.shutdown().await;
+            }
+        });
+
+        (url, served, handle)
+    }
+
     #[tokio::test]
     async fn search_returns_results() {
         let json = r#"{"success":true,"data":[
@@ -263,6 +497,38 @@ mod tests {
         handle.abort();
     }
 
+    #[tokio::test]
+    async fn get_game_by_steam_app_id_returns_game() {
+        let json = r#"{"success":true,"data":
+            {"id":5248,"name":"Half-Life 2","types":["steam"],"verified":true,"release_date":1100563200}
+        }"#;
+        let (url, handle) = mock_server(json).await;
+
+        let client = Client::new("test-key").unwrap().with_base_url(url);
+        let game = client.get_game_by_steam_app_id(220).await.unwrap();
+
+        assert_eq!(game.id, 5248);
+        assert_eq!(game.name, "Half-Life 2");
+        assert!(game.verified);
+
+        handle.abort();
+    }
+
+    #[tokio::test]
+    async fn get_game_by_steam_app_id_not_found() {
+        let (url, handle) =
+            mock_server_error(404, r#"{"success":false,"errors":["Game not found"]}"#).await;
+
+        let client = Client::new("test-key").unwrap().with_base_url(url);
+        let err = client.get_game_by_steam_app_id(1).await.unwrap_err();
+        assert!(
+            matches!(err, Error::Api { status: 404, .. }),
+            "unexpected error: {err}"
+        );
+
+        handle.abort();
+    }
+
     #[tokio::test]
     async fn get_grids_returns_images() {
         let json = r#"{"success":true,"data":[
@@ -340,6 +606,127 @@ mod tests {
         handle.abort();
     }
 
+    #[tokio::test]
+    async fn throttled_request_is_retried() {
+        let (url, served, handle) = mock_server_sequence(
+            vec![
+                "HTTP/1.1 429 Too Many Requests\r\nRetry-After: 0",
+                "HTTP/1.1 503 Service Unavailable\r\nRetry-After: 0",
+                "HTTP/1.1 200 OK",
+            ],
+            r#"{"success":true,"data":[{"id":1,"name":"Retried"}]}"#,
+        )
+        .await;
+
+        let client = Client::new("test-key").unwrap().with_base_url(url);
+        let results = client.search("Retried").await.unwrap();
+
+        assert_eq!(results[0].name, "Retried");
+        assert_eq!(served.load(std::sync::atomic::Ordering::SeqCst), 3);
+
+        handle.abort();
+    }
+
+    #[tokio::test]
+    async fn exhausted_retries_return_rate_limited() {
+        let (url, served, handle) = mock_server_sequence(
+            vec!["HTTP/1.1 429 Too Many Requests\r\nRetry-After: 0"; 4],
+            r#"{"success":false}"#,
+        )
+        .await;
+
+        let client = Client::new("test-key").unwrap().with_base_url(url);
+        let err = client.get_grids(42, None, 0).await.unwrap_err();
+
+        assert!(
+            matches!(err, Error::RateLimited { retry_after: Some(d) } if d.is_zero()),
+            "unexpected error: {err}"
+        );
+        assert_eq!(served.load(std::sync::atomic::Ordering::SeqCst), 3);
+
+        handle.abort();
+    }
+
+    #[tokio::test]
+    async fn long_retry_after_fails_without_waiting() {
+        let (url, served, handle) = mock_server_sequence(
+            vec!["HTTP/1.1 429 Too Many Requests\r\nRetry-After: 3600"],
+            r#"{"success":false}"#,
+        )
+        .await;
+
+        let client = Client::new("test-key").unwrap().with_base_url(url);
+        let err = client.get_heroes(42, None, 0).await.unwrap_err();
+
+        assert!(matches!(err, Error::RateLimited { .. }));
+        assert!(err.to_string().contains("3600s"));
+        assert_eq!(served.load(std::sync::atomic::Ordering::SeqCst), 1);
+
+        handle.abort();
+    }
+
+    #[tokio::test]
+    async fn metadata_cache_serves_repeated_requests() {
+        let tmp = tempfile::tempdir().unwrap();
+        let (url, served, handle) = mock_server_sequence(
+            vec!["HTTP/1.1 200 OK", "HTTP/1.1 200 OK"],
+            r#"{"success":true,"data":[{"id":7,"name":"Cached"}]}"#,
+        )
+        .await;
+
+        let client = Client::new("test-key")
+            .unwrap()
+            .with_base_url(url)
+            .with_metadata_cache_dir(tmp.path().to_path_buf(), Duration::from_secs(60));
+        assert_eq!(client.search("Cached").await.unwrap()[0].id, 7);
+        assert_eq!(client.search("Cached").await.unwrap()[0].id, 7);
+        assert_eq!(served.load(std::sync::atomic::Ordering::SeqCst), 1);
+
+        // A different page is a different cache entry.
+        let _ = client.get_grids(7, None, 1).await;
+        assert_eq!(served.load(std::sync::atomic::Ordering::SeqCst), 2);
+
+        handle.abort();
+    }
+
+    #[tokio::test]
+    async fn metadata_refresh_bypasses_cache() {
+        let tmp = tempfile::tempdir().unwrap();
+        let (url, served, handle) = mock_server_sequence(
+            vec!["HTTP/1.1 200 OK", "HTTP/1.1 200 OK"],
+            r#"{"success":true,"data":[{"id":8,"name":"Fresh"}]}"#,
+        )
+        .await;
+
+        let dir = tmp.path().to_path_buf();
+        let client = Client::new("test-key")
+            .unwrap()
+            .with_base_url(url)
+            .with_metadata_cache_dir(dir.clone(), Duration::from_secs(60));
+        client.search("Fresh").await.unwrap();
+
+        let client = client.with_metadata_refresh(true);
+        client.search("Fresh").await.unwrap();
+        assert_eq!(served.load(std::sync::atomic::Ordering::SeqCst), 2);
+
+        handle.abort();
+    }
+
+    #[test]
+    fn parse_retry_after_seconds() {
+        let mut headers = HeaderMap::new();
+        assert_eq!(parse_retry_after(&headers), None);
+
+        headers.insert(RETRY_AFTER, HeaderValue::from_static("5"));
+        assert_eq!(parse_retry_after(&headers), Some(Duration::from_secs(5)));
+
+        headers.insert(
+            RETRY_AFTER,
+            HeaderValue::from_static("Wed, 21 Oct 2015 07:28:00 GMT"),
+        );
+        assert_eq!(parse_retry_after(&headers), None);
+    }
+
     #[test]
     fn client_new_succeeds() {
         let client = Client::new("valid-key");
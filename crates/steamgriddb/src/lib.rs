@@ -5,7 +5,14 @@
 
 pub mod cache;
 pub mod client;
+pub mod limiter;
+pub mod prefetch;
 pub mod types;
 
+pub use cache::{DEFAULT_IMAGE_CACHE_MAX_SIZE, DEFAULT_METADATA_CACHE_TTL};
 pub use client::Client;
-pub use types::{ImageData, ImageFilters, SearchResult};
+pub use limiter::DEFAULT_REQUESTS_PER_SEC;
+pub use prefetch::{DEFAULT_PREFETCH_CONCURRENCY, PrefetchStats};
+pub use types::{
+    GRID_MIME_TYPES, ICON_MIME_TYPES, ImageData, ImageFilters, LOGO_MIME_TYPES, SearchResult,
+};
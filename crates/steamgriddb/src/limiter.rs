@@ -0,0 +1,99 @@
+//! Token-bucket rate limiter for SteamGridDB API requests.
+//!
+//! The bucket holds up to one second's worth of requests, so short bursts
+//! (opening the artwork picker fires several searches at once) go out
+//! immediately and sustained browsing settles at the configured rate.
+
+use std::time::{Duration, Instant};
+
+use tokio::sync::Mutex;
+
+/// Requests per second allowed by default.
+pub const DEFAULT_REQUESTS_PER_SEC: u32 = 10;
+
+/// Token bucket shared by all requests of a [`Client`](crate::Client).
+pub(crate) struct RateLimiter {
+    per_sec: f64,
+    bucket: Mutex<Bucket>,
+}
+
+struct Bucket {
+    /// Available tokens; negative while callers wait for reserved ones.
+    tokens: f64,
+    refilled_at: Instant,
+}
+
+impl RateLimiter {
+    /// Creates a limiter allowing `per_sec` requests per second. 0 disables
+    /// limiting.
+    pub(crate) fn new(per_sec: u32) -> Self {
+        Self {
+            per_sec: f64::from(per_sec),
+            bucket: Mutex::new(Bucket {
+                tokens: f64::from(per_sec),
+                refilled_at: Instant::now(),
+            }),
+        }
+    }
+
+    /// Waits until a request may be sent.
+    pub(crate) async fn acquire(&self) {
+        if self.per_sec <= 0.0 {
+            return;
+        }
+        let wait = {
+            let mut bucket = self.bucket.lock().await;
+            let now = Instant::now();
+            let elapsed = now.duration_since(bucket.refilled_at).as_secs_f64();
+            bucket.tokens = (bucket.tokens + elapsed * self.per_sec).min(self.per_sec);
+            bucket.refilled_at = now;
+
+            // Reserve a token even when none is left, so waiters are served
+            // in order without holding the lock while they sleep.
+            bucket.tokens -= 1.0;
+            if bucket.tokens >= 0.0 {
+                return;
+            }
+            Duration::from_secs_f64(-bucket.tokens / self.per_sec)
+        };
+        tokio::time::sleep(wait).await;
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn burst_up_to_rate_is_immediate() {
+        let limiter = RateLimiter::new(5);
+        let start = Instant::now();
+        for _ in 0..5 {
+            limiter.acquire().await;
+        }
+        assert!(start.elapsed() < Duration::from_millis(100));
+    }
+
+    #[tokio::test]
+    async fn requests_beyond_burst_wait() {
+        let limiter = RateLimiter::new(4);
+        for _ in 0..4 {
+            limiter.acquire().await;
+        }
+        let start = Instant::now();
+        limiter.acquire().await;
+        limiter.acquire().await;
+        // Two more tokens at 4/s take about half a second.
+        assert!(start.elapsed() >= Duration::from_millis(400));
+    }
+
+    #[tokio::test]
+    async fn zero_rate_disables_limiting() {
+        let limiter = RateLimiter::new(0);
+        let start = Instant::now();
+        for _ in 0..100 {
+            limiter.acquire().await;
+        }
+        assert!(start.elapsed() < Duration::from_millis(100));
+    }
+}
@@ -0,0 +1,238 @@
+//! Background prefetch of SteamGridDB images into the disk cache.
+//!
+//! While the user browses a page of results, the Hub prefetches the next
+//! page's images so scrolling doesn't stall on each thumbnail. Downloads
+//! run with bounded concurrency and oversized images are dropped instead
+//! of cached, which keeps both memory use and request bursts in check.
+
+use std::future::Future;
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
+
+use tokio::sync::Semaphore;
+use tokio::task::JoinSet;
+use tracing::{debug, warn};
+
+use crate::cache;
+use crate::client::Error;
+
+/// Default number of images downloaded in parallel during prefetch.
+pub const DEFAULT_PREFETCH_CONCURRENCY: usize = 4;
+
+/// Images larger than this are not cached by prefetch (10 MB).
+pub const MAX_PREFETCH_IMAGE_SIZE: usize = 10 * 1024 * 1024;
+
+/// Outcome counters for a prefetch run.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct PrefetchStats {
+    /// Images downloaded and written to the cache.
+    pub fetched: usize,
+    /// Images skipped because they were already cached.
+    pub already_cached: usize,
+    /// Images skipped because they exceeded [`MAX_PREFETCH_IMAGE_SIZE`].
+    pub too_large: usize,
+    /// Images whose download or cache write failed.
+    pub failed: usize,
+}
+
+/// Prefetches `urls` into the image cache for `game_id`.
+///
+/// `fetch` downloads one URL and returns `(data, content_type)`. At most
+/// `concurrency` downloads are in flight at any time.
+pub async fn prefetch_images<F, Fut>(
+    game_id: i32,
+    urls: Vec<String>,
+    concurrency: usize,
+    fetch: F,
+) -> Result<PrefetchStats, cache::CacheError>
+where
+    F: Fn(String) -> Fut + Send + Sync + 'static,
+    Fut: Future<Output = Result<(Vec<u8>, String), Error>> + Send + 'static,
+{
+    let images_dir = cache::image_cache_dir()?;
+    Ok(prefetch_images_in(images_dir, game_id, urls, concurrency, fetch).await)
+}
+
+/// Same as [`prefetch_images`] with an explicit cache directory (testable).
+pub(crate) async fn prefetch_images_in<F, Fut>(
+    images_dir: PathBuf,
+    game_id: i32,
+    urls: Vec<String>,
+    concurrency: usize,
+    fetch: F,
+) -> PrefetchStats
+where
+    F: Fn(String) -> Fut + Send + Sync + 'static,
+    Fut: Future<Output = Result<(Vec<u8>, String), Error>> + Send + 'static,
+{
+    let mut stats = PrefetchStats::default();
+    let semaphore = Arc::new(Semaphore::new(concurrency.max(1)));
+    let fetch = Arc::new(fetch);
+    let images_dir = Arc::new(images_dir);
+    let mut tasks = JoinSet::new();
+
+    for url in urls {
+        if url.is_empty() {
+            continue;
+        }
+        if is_cached(&images_dir, game_id, &url) {
+            stats.already_cached += 1;
+            continue;
+        }
+
+        let semaphore = semaphore.clone();
+        let fetch = fetch.clone();
+        let images_dir = images_dir.clone();
+        tasks.spawn(async move {
+            let Ok(_permit) = semaphore.acquire_owned().await else {
+                return Outcome::Failed;
+            };
+            match fetch(url.clone()).await {
+                Ok((data, _)) if data.len() > MAX_PREFETCH_IMAGE_SIZE => Outcome::TooLarge,
+                Ok((data, content_type)) => {
+                    match cache::save_image_to_cache_in(
+                        &images_dir,
+                        game_id,
+                        &url,
+                        &data,
+                        &content_type,
+                    ) {
+                        Ok(()) => Outcome::Fetched,
+                        Err(e) => {
+                            warn!(url, error = %e, "prefetch: failed to cache image");
+                            Outcome::Failed
+                        }
+                    }
+                }
+                Err(e) => {
+                    debug!(url, error = %e, "prefetch: download failed");
+                    Outcome::Failed
+                }
+            }
+        });
+    }
+
+    while let Some(res) = tasks.join_next().await {
+        match res {
+            Ok(Outcome::Fetched) => stats.fetched += 1,
+            Ok(Outcome::TooLarge) => stats.too_large += 1,
+            Ok(Outcome::Failed) | Err(_) => stats.failed += 1,
+        }
+    }
+
+    stats
+}
+
+enum Outcome {
+    Fetched,
+    TooLarge,
+    Failed,
+}
+
+fn is_cached(images_dir: &Path, game_id: i32, url: &str) -> bool {
+    cache::get_cached_image_path_in(images_dir, game_id, url).is_ok()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::atomic::{AtomicUsize, Ordering};
+    use std::time::Duration;
+
+    fn test_images_dir() -> (tempfile::TempDir, PathBuf) {
+        let tmp = tempfile::tempdir().unwrap();
+        let images = tmp.path().join("images");
+        std::fs::create_dir_all(&images).unwrap();
+        (tmp, images)
+    }
+
+    fn urls(n: usize) -> Vec<String> {
+        (0..n)
+            .map(|i| format!("https://cdn.example.com/thumb/{i}.png"))
+            .collect()
+    }
+
+    #[tokio::test]
+    async fn prefetch_populates_cache_within_concurrency_limit() {
+        let (_tmp, images) = test_images_dir();
+        let in_flight = Arc::new(AtomicUsize::new(0));
+        let peak = Arc::new(AtomicUsize::new(0));
+
+        let (f, p) = (in_flight.clone(), peak.clone());
+        let stats = prefetch_images_in(images.clone(), 7, urls(12), 3, move |url| {
+            let (f, p) = (f.clone(), p.clone());
+            async move {
+                let now = f.fetch_add(1, Ordering::SeqCst) + 1;
+                p.fetch_max(now, Ordering::SeqCst);
+                tokio::time::sleep(Duration::from_millis(20)).await;
+                f.fetch_sub(1, Ordering::SeqCst);
+                Ok((url.into_bytes(), "image/png".to_string()))
+            }
+        })
+        .await;
+
+        assert_eq!(stats.fetched, 12);
+        assert_eq!(stats.failed, 0);
+        assert!(peak.load(Ordering::SeqCst) <= 3, "exceeded concurrency");
+        assert!(peak.load(Ordering::SeqCst) >= 2, "should run in parallel");
+
+        for url in urls(12) {
+            let (data, content_type) = cache::get_cached_image_in(&images, 7, &url).unwrap();
+            assert_eq!(data, url.as_bytes());
+            assert_eq!(content_type, "image/png");
+        }
+    }
+
+    #[tokio::test]
+    async fn prefetch_skips_already_cached() {
+        let (_tmp, images) = test_images_dir();
+        let all = urls(4);
+        cache::save_image_to_cache_in(&images, 7, &all[0], b"old", "image/png").unwrap();
+
+        let calls = Arc::new(AtomicUsize::new(0));
+        let c = calls.clone();
+        let stats = prefetch_images_in(images.clone(), 7, all, 2, move |_url| {
+            c.fetch_add(1, Ordering::SeqCst);
+            async { Ok((b"new".to_vec(), "image/png".to_string())) }
+        })
+        .await;
+
+        assert_eq!(stats.already_cached, 1);
+        assert_eq!(stats.fetched, 3);
+        assert_eq!(calls.load(Ordering::SeqCst), 3);
+    }
+
+    #[tokio::test]
+    async fn prefetch_drops_oversized_and_counts_failures() {
+        let (_tmp, images) = test_images_dir();
+        let all = urls(3);
+        let stats = prefetch_images_in(images.clone(), 7, all.clone(), 2, |url| async move {
+            if url.ends_with("0.png") {
+                Ok((
+                    vec![0u8; MAX_PREFETCH_IMAGE_SIZE + 1],
+                    "image/png".to_string(),
+                ))
+            } else if url.ends_with("1.png") {
+                Err(Error::Api {
+                    status: 404,
+                    body: "download failed".into(),
+                })
+            } else {
+                Ok((b"ok".to_vec(), "image/jpeg".to_string()))
+            }
+        })
+        .await;
+
+        assert_eq!(
+            stats,
+            PrefetchStats {
+                fetched: 1,
+                already_cached: 0,
+                too_large: 1,
+                failed: 1,
+            }
+        );
+        assert!(cache::get_cached_image_in(&images, 7, &all[0]).is_err());
+        assert!(cache::get_cached_image_in(&images, 7, &all[2]).is_ok());
+    }
+}
@@ -3,7 +3,7 @@
 use serde::{Deserialize, Serialize};
 
 /// A game search result from the SteamGridDB API.
-#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
 pub struct SearchResult {
     pub id: i32,
     pub name: String,
@@ -68,6 +68,65 @@ pub struct ImageFilters {
     pub show_humor: bool,
 }
 
+/// Formats SteamGridDB serves for grids and heroes.
+pub const GRID_MIME_TYPES: &[&str] = &["image/png", "image/jpeg", "image/webp"];
+
+/// Formats SteamGridDB serves for logos.
+pub const LOGO_MIME_TYPES: &[&str] = &["image/png", "image/webp"];
+
+/// Formats SteamGridDB serves for icons.
+pub const ICON_MIME_TYPES: &[&str] = &["image/png", "image/vnd.microsoft.icon"];
+
+impl ImageFilters {
+    /// Narrows `mime_type` to the `offered` types an agent can apply.
+    ///
+    /// `formats` are the agent's short format names ("png", "jpeg", ...);
+    /// an empty list means unknown and leaves the filter alone. Returns
+    /// `false` when none of the requested types is supported, so there is
+    /// nothing worth querying.
+    pub fn restrict_to_formats(&mut self, formats: &[String], offered: &[&str]) -> bool {
+        if formats.is_empty() {
+            return true;
+        }
+        let requested: Vec<&str> = self
+            .mime_type
+            .split(',')
+            .map(str::trim)
+            .filter(|m| !m.is_empty() && *m != "All Formats")
+            .collect();
+        let unfiltered = requested.is_empty();
+        let candidates = if unfiltered {
+            offered.to_vec()
+        } else {
+            requested
+        };
+        let allowed: Vec<&str> = candidates
+            .iter()
+            .copied()
+            .filter(|m| mime_supported(m, formats))
+            .collect();
+        if allowed.is_empty() {
+            return false;
+        }
+        if !(unfiltered && allowed.len() == offered.len()) {
+            self.mime_type = allowed.join(",");
+        }
+        true
+    }
+}
+
+/// Whether an agent reporting `formats` can apply images of `mime`.
+fn mime_supported(mime: &str, formats: &[String]) -> bool {
+    let name = match mime.trim_start_matches("image/") {
+        "vnd.microsoft.icon" | "x-icon" => "ico",
+        other => other,
+    };
+    formats.iter().any(|f| {
+        let f = f.to_ascii_lowercase();
+        f == name || (name == "jpeg" && f == "jpg")
+    })
+}
+
 /// API response wrapper (internal).
 #[derive(Debug, Deserialize)]
 pub(crate) struct ApiResponse<T> {
@@ -150,6 +209,47 @@ mod tests {
         assert!(json.contains("showNsfw"));
     }
 
+    fn formats(names: &[&str]) -> Vec<String> {
+        names.iter().map(|n| n.to_string()).collect()
+    }
+
+    #[test]
+    fn restrict_to_formats_narrows_all_formats() {
+        let mut f = ImageFilters::default();
+        assert!(f.restrict_to_formats(&formats(&["png", "jpg"]), GRID_MIME_TYPES));
+        assert_eq!(f.mime_type, "image/png,image/jpeg");
+
+        let mut f = ImageFilters::default();
+        assert!(f.restrict_to_formats(&formats(&["png", "ico"]), ICON_MIME_TYPES));
+        assert!(f.mime_type.is_empty(), "everything offered is supported");
+    }
+
+    #[test]
+    fn restrict_to_formats_drops_unsupported_selection() {
+        let mut f = ImageFilters {
+            mime_type: "image/webp,image/png".into(),
+            ..Default::default()
+        };
+        assert!(f.restrict_to_formats(&formats(&["png"]), GRID_MIME_TYPES));
+        assert_eq!(f.mime_type, "image/png");
+
+        let mut f = ImageFilters {
+            mime_type: "image/webp".into(),
+            ..Default::default()
+        };
+        assert!(!f.restrict_to_formats(&formats(&["png"]), LOGO_MIME_TYPES));
+    }
+
+    #[test]
+    fn restrict_to_formats_unknown_agent_formats() {
+        let mut f = ImageFilters {
+            mime_type: "image/webp".into(),
+            ..Default::default()
+        };
+        assert!(f.restrict_to_formats(&[], GRID_MIME_TYPES));
+        assert_eq!(f.mime_type, "image/webp");
+    }
+
     #[test]
     fn api_response_parse() {
         let json = r#"{"success":true,"data":[{"id":1,"name":"Game"}]}"#;
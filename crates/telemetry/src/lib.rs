@@ -18,3 +18,8 @@ mod platform;
 mod platform;
 
 pub use collector::Collector;
+
+/// Total physical memory in bytes, or `0` if it can't be read.
+pub fn total_memory_bytes() -> u64 {
+    u64::try_from(platform::read_mem_info().0).unwrap_or(0)
+}
@@ -0,0 +1,396 @@
+//! Artwork image transfer: format validation and chunk reassembly.
+//!
+//! Images normally travel in one binary frame. One larger than the frame
+//! limit is split into chunks that carry their offset, the total size and
+//! the SHA-256 of the whole image; the [`ArtworkAssembler`] puts them back
+//! together, tells the sender where to resume after a gap, and verifies the
+//! checksum once the last byte arrives.
+//!
+//! Either way the agent checks the bytes really are the image format the
+//! sender declared before saving them, so a CDN error page served with an
+//! `image/png` label never ends up as a game's artwork.
+
+use std::collections::HashMap;
+
+use crate::TransferError;
+use crate::chunked::checksum_bytes;
+
+/// Largest artwork image accepted, so a bogus total size can't make the
+/// agent buffer without bound (64 MiB).
+pub const MAX_ARTWORK_BYTES: u64 = 64 * 1024 * 1024;
+
+/// Short names of the formats [`detect_image_type`] recognizes, which are
+/// the formats an agent can validate and save as artwork.
+pub const SUPPORTED_IMAGE_FORMATS: &[&str] = &["png", "jpeg", "webp", "gif", "ico"];
+
+/// Detects an image's MIME type from its leading bytes.
+pub fn detect_image_type(data: &[u8]) -> Option<&'static str> {
+    if data.starts_with(b"\x89PNG\r\n\x1a\n") {
+        Some("image/png")
+    } else if data.starts_with(b"\xff\xd8\xff") {
+        Some("image/jpeg")
+    } else if data.len() >= 12 && &data[..4] == b"RIFF" && &data[8..12] == b"WEBP" {
+        Some("image/webp")
+    } else if data.starts_with(b"GIF87a") || data.starts_with(b"GIF89a") {
+        Some("image/gif")
+    } else if data.starts_with(b"\x00\x00\x01\x00") {
+        Some("image/x-icon")
+    } else {
+        None
+    }
+}
+
+/// Maps MIME type aliases to the name [`detect_image_type`] reports.
+fn canonical_image_type(content_type: &str) -> String {
+    let ct = content_type
+        .split(';')
+        .next()
+        .unwrap_or_default()
+        .trim()
+        .to_ascii_lowercase();
+    match ct.as_str() {
+        "image/jpg" | "image/pjpeg" => "image/jpeg".into(),
+        "image/vnd.microsoft.icon" | "image/ico" => "image/x-icon".into(),
+        _ => ct,
+    }
+}
+
+/// Checks that `data` is an image of the declared `content_type` and
+/// returns its actual MIME type.
+///
+/// An empty `content_type` accepts any recognized image.
+pub fn validate_image(content_type: &str, data: &[u8]) -> Result<&'static str, TransferError> {
+    let Some(actual) = detect_image_type(data) else {
+        let head = data.iter().copied().find(|b| !b.is_ascii_whitespace());
+        let what = if head == Some(b'<') {
+            "an HTML/XML document"
+        } else if data.is_empty() {
+            "empty"
+        } else {
+            "not a recognized image"
+        };
+        return Err(TransferError::InvalidArtwork(format!(
+            "declared {content_type} but the data is {what}"
+        )));
+    };
+    let declared = canonical_image_type(content_type);
+    if !declared.is_empty() && declared != actual {
+        return Err(TransferError::InvalidArtwork(format!(
+            "declared {content_type} but the data is {actual}"
+        )));
+    }
+    Ok(actual)
+}
+
+/// One frame of an artwork transfer.
+#[derive(Debug, Clone, Copy)]
+pub struct ArtworkFrame<'a> {
+    /// Byte offset of `data` within the image.
+    pub offset: u64,
+    /// Size of the whole image; 0 for an image sent in a single frame.
+    pub total_size: u64,
+    /// Hex SHA-256 of the whole image; optional for single frames.
+    pub checksum: &'a str,
+    pub data: &'a [u8],
+}
+
+/// Result of feeding a frame to the [`ArtworkAssembler`].
+#[derive(Debug, PartialEq, Eq)]
+pub enum Assembled {
+    /// More bytes are needed; the sender continues from `received`.
+    Incomplete { received: u64 },
+    /// The whole image, checksum verified.
+    Complete(Vec<u8>),
+}
+
+struct PartialImage {
+    total_size: u64,
+    checksum: String,
+    data: Vec<u8>,
+}
+
+/// Reassembles chunked artwork images, one per key (typically the AppID
+/// and artwork type).
+#[derive(Default)]
+pub struct ArtworkAssembler {
+    partial: HashMap<String, PartialImage>,
+}
+
+impl ArtworkAssembler {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Adds a frame of the image identified by `key`.
+    ///
+    /// A frame at offset 0 (re)starts the image. A frame that doesn't
+    /// continue where the buffered data ends is ignored and answered with
+    /// the offset to resume from. A checksum mismatch drops the image.
+    pub fn accept(
+        &mut self,
+        key: &str,
+        frame: ArtworkFrame<'_>,
+    ) -> Result<Assembled, TransferError> {
+        if frame.total_size == 0 {
+            verify_checksum(frame.checksum, frame.data)?;
+            return Ok(Assembled::Complete(frame.data.to_vec()));
+        }
+        if frame.total_size > MAX_ARTWORK_BYTES {
+            return Err(TransferError::InvalidArtwork(format!(
+                "image of {} bytes exceeds the {MAX_ARTWORK_BYTES}-byte limit",
+                frame.total_size
+            )));
+        }
+        if frame.checksum.is_empty() {
+            return Err(TransferError::InvalidArtwork(
+                "chunked image without a checksum".into(),
+            ));
+        }
+
+        if frame.offset == 0 {
+            self.partial.insert(
+                key.to_string(),
+                PartialImage {
+                    total_size: frame.total_size,
+                    checksum: frame.checksum.to_string(),
+                    data: Vec::with_capacity(frame.total_size as usize),
+                },
+            );
+        }
+        let Some(image) = self.partial.get_mut(key) else {
+            return Ok(Assembled::Incomplete { received: 0 });
+        };
+        if image.total_size != frame.total_size || image.checksum != frame.checksum {
+            // A different image under the same key; start over.
+            self.partial.remove(key);
+            return Ok(Assembled::Incomplete { received: 0 });
+        }
+
+        let received = image.data.len() as u64;
+        if frame.offset != received {
+            return Ok(Assembled::Incomplete { received });
+        }
+        if received + frame.data.len() as u64 > image.total_size {
+            self.partial.remove(key);
+            return Err(TransferError::InvalidArtwork(
+                "chunk runs past the declared image size".into(),
+            ));
+        }
+        image.data.extend_from_slice(frame.data);
+        if (image.data.len() as u64) < image.total_size {
+            return Ok(Assembled::Incomplete {
+                received: image.data.len() as u64,
+            });
+        }
+
+        let image = self.partial.remove(key).expect("image present");
+        verify_checksum(&image.checksum, &image.data)?;
+        Ok(Assembled::Complete(image.data))
+    }
+}
+
+fn verify_checksum(expected: &str, data: &[u8]) -> Result<(), TransferError> {
+    if !expected.is_empty() && !checksum_bytes(data).eq_ignore_ascii_case(expected) {
+        return Err(TransferError::ChecksumMismatch);
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// A PNG signature followed by filler, `len` bytes long.
+    fn png(len: usize) -> Vec<u8> {
+        let mut data = b"\x89PNG\r\n\x1a\n".to_vec();
+        data.resize(len, 0x42);
+        data
+    }
+
+    fn frame<'a>(image: &'a [u8], sum: &'a str, offset: usize, len: usize) -> ArtworkFrame<'a> {
+        let end = (offset + len).min(image.len());
+        ArtworkFrame {
+            offset: offset as u64,
+            total_size: image.len() as u64,
+            checksum: sum,
+            data: &image[offset..end],
+        }
+    }
+
+    #[test]
+    fn detects_image_formats() {
+        assert_eq!(detect_image_type(&png(16)), Some("image/png"));
+        assert_eq!(
+            detect_image_type(b"\xff\xd8\xff\xe0JFIF"),
+            Some("image/jpeg")
+        );
+        assert_eq!(
+            detect_image_type(b"RIFF\0\0\0\0WEBPVP8 "),
+            Some("image/webp")
+        );
+        assert_eq!(detect_image_type(b"GIF89a.."), Some("image/gif"));
+        assert_eq!(detect_image_type(b"\0\0\x01\0\x01\0"), Some("image/x-icon"));
+        assert_eq!(detect_image_type(b"<html>"), None);
+    }
+
+    #[test]
+    fn supported_formats_match_detection() {
+        let detected: Vec<&str> = [
+            png(16).as_slice(),
+            b"\xff\xd8\xff\xe0JFIF",
+            b"RIFF\0\0\0\0WEBPVP8 ",
+            b"GIF89a..",
+            b"\0\0\x01\0\x01\0",
+        ]
+        .iter()
+        .filter_map(|data| detect_image_type(data))
+        .map(|mime| match mime {
+            "image/x-icon" => "ico",
+            other => other.trim_start_matches("image/"),
+        })
+        .collect();
+        assert_eq!(detected, SUPPORTED_IMAGE_FORMATS);
+    }
+
+    #[test]
+    fn rejects_mislabeled_image() {
+        let page = b"\n  <!DOCTYPE html><html><body>502 Bad Gateway</body></html>";
+        let err = validate_image("image/png", page).unwrap_err();
+        assert!(matches!(err, TransferError::InvalidArtwork(_)));
+        assert!(err.to_string().contains("HTML"), "{err}");
+
+        let jpeg = b"\xff\xd8\xff\xdb rest of jpeg";
+        let err = validate_image("image/png", jpeg).unwrap_err();
+        assert!(err.to_string().contains("image/jpeg"), "{err}");
+
+        assert!(validate_image("image/png", b"").is_err());
+    }
+
+    #[test]
+    fn accepts_matching_and_aliased_types() {
+        assert_eq!(validate_image("image/png", &png(32)).unwrap(), "image/png");
+        let jpeg = b"\xff\xd8\xff\xe0 jpeg";
+        assert_eq!(validate_image("image/jpg", jpeg).unwrap(), "image/jpeg");
+        assert_eq!(
+            validate_image("IMAGE/JPEG; q=1", jpeg).unwrap(),
+            "image/jpeg"
+        );
+        assert_eq!(validate_image("", jpeg).unwrap(), "image/jpeg");
+    }
+
+    #[test]
+    fn reassembles_valid_chunked_image() {
+        let image = png(10_000);
+        let sum = checksum_bytes(&image);
+        let mut asm = ArtworkAssembler::new();
+
+        let mut offset = 0;
+        let result = loop {
+            match asm
+                .accept("100/grid", frame(&image, &sum, offset, 4096))
+                .unwrap()
+            {
+                Assembled::Incomplete { received } => offset = received as usize,
+                Assembled::Complete(data) => break data,
+            }
+        };
+        assert_eq!(result, image);
+        assert_eq!(validate_image("image/png", &result).unwrap(), "image/png");
+    }
+
+    #[test]
+    fn resumes_after_a_gap() {
+        let image = png(3000);
+        let sum = checksum_bytes(&image);
+        let mut asm = ArtworkAssembler::new();
+
+        assert_eq!(
+            asm.accept("k", frame(&image, &sum, 0, 1000)).unwrap(),
+            Assembled::Incomplete { received: 1000 }
+        );
+        // The frame at 1000 was lost; the next one is refused with the
+        // offset to resume from.
+        assert_eq!(
+            asm.accept("k", frame(&image, &sum, 2000, 1000)).unwrap(),
+            Assembled::Incomplete { received: 1000 }
+        );
+        assert_eq!(
+            asm.accept("k", frame(&image, &sum, 1000, 1000)).unwrap(),
+            Assembled::Incomplete { received: 2000 }
+        );
+        assert_eq!(
+            asm.accept("k", frame(&image, &sum, 2000, 1000)).unwrap(),
+            Assembled::Complete(image.clone())
+        );
+
+        // Nothing buffered for an unknown image.
+        assert_eq!(
+            asm.accept("other", frame(&image, &sum, 1000, 1000))
+                .unwrap(),
+            Assembled::Incomplete { received: 0 }
+        );
+    }
+
+    #[test]
+    fn corrupt_chunk_fails_checksum() {
+        let image = png(2048);
+        let sum = checksum_bytes(&image);
+        let mut corrupt = image.clone();
+        corrupt[1500] ^= 0xff;
+        let mut asm = ArtworkAssembler::new();
+
+        asm.accept("k", frame(&corrupt, &sum, 0, 1024)).unwrap();
+        let err = asm
+            .accept("k", frame(&corrupt, &sum, 1024, 1024))
+            .unwrap_err();
+        assert!(matches!(err, TransferError::ChecksumMismatch));
+        // The bad image was dropped; a retry starts from scratch.
+        assert_eq!(
+            asm.accept("k", frame(&image, &sum, 1024, 1024)).unwrap(),
+            Assembled::Incomplete { received: 0 }
+        );
+    }
+
+    #[test]
+    fn single_frame_checksum_is_optional() {
+        let image = png(64);
+        let mut asm = ArtworkAssembler::new();
+        let whole = |checksum| ArtworkFrame {
+            offset: 0,
+            total_size: 0,
+            checksum,
+            data: &image,
+        };
+
+        assert_eq!(
+            asm.accept("k", whole("")).unwrap(),
+            Assembled::Complete(image.clone())
+        );
+        let sum = checksum_bytes(&image);
+        assert!(asm.accept("k", whole(&sum)).is_ok());
+        assert!(matches!(
+            asm.accept("k", whole("deadbeef")),
+            Err(TransferError::ChecksumMismatch)
+        ));
+    }
+
+    #[test]
+    fn rejects_oversized_or_unchecked_chunks() {
+        let mut asm = ArtworkAssembler::new();
+        let oversized = ArtworkFrame {
+            offset: 0,
+            total_size: MAX_ARTWORK_BYTES + 1,
+            checksum: "abc",
+            data: b"x",
+        };
+        assert!(asm.accept("k", oversized).is_err());
+
+        let unchecked = ArtworkFrame {
+            offset: 0,
+            total_size: 10,
+            checksum: "",
+            data: b"x",
+        };
+        assert!(asm.accept("k", unchecked).is_err());
+    }
+}
@@ -158,8 +158,9 @@ impl ChunkWriter {
     /// - Verifies checksum if non-empty.
     /// - Updates internal written-offset tracking.
     pub fn write_chunk(&mut self, chunk: &Chunk) -> Result<(), TransferError> {
-        // Validate path before joining to prevent directory traversal.
-        crate::validate_upload_path(&chunk.file_path)?;
+        // Resolve the destination first so nothing is written outside
+        // the game directory, even through a symlink.
+        let full_path = crate::safe_join(&self.base_path, &chunk.file_path)?;
 
         // Verify checksum before writing.
         if !chunk.checksum.is_empty() {
@@ -169,8 +170,6 @@ impl ChunkWriter {
             }
         }
 
-        let full_path = self.base_path.join(&chunk.file_path);
-
         // Create parent directories.
         if let Some(parent) = full_path.parent() {
             std::fs::create_dir_all(parent)?;
@@ -0,0 +1,144 @@
+//! Per-chunk upload compression.
+
+use std::io::{Read, Write};
+
+use crate::TransferError;
+
+/// Extensions of formats that are already compressed; chunks of these
+/// files are sent raw.
+const PRECOMPRESSED_EXTENSIONS: &[&str] = &[
+    "7z", "avi", "bik", "bk2", "br", "bz2", "cab", "gz", "jar", "jpeg", "jpg", "lz4", "m4a", "mkv",
+    "mp3", "mp4", "ogg", "ogv", "opus", "png", "rar", "usm", "webm", "webp", "xz", "zip", "zst",
+];
+
+/// Compression codec negotiated for an upload.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub enum Compression {
+    #[default]
+    None,
+    Gzip,
+    Zstd,
+}
+
+impl Compression {
+    /// Parses a codec name as sent in `InitUpload`. An empty name means
+    /// none; unknown names return `None`.
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name {
+            "" | "none" => Some(Self::None),
+            "gzip" => Some(Self::Gzip),
+            "zstd" => Some(Self::Zstd),
+            _ => None,
+        }
+    }
+
+    /// Wire name of the codec.
+    pub fn name(self) -> &'static str {
+        match self {
+            Self::None => "none",
+            Self::Gzip => "gzip",
+            Self::Zstd => "zstd",
+        }
+    }
+
+    /// Compresses `data`, or returns `None` when that wouldn't make it
+    /// smaller and the chunk should go out raw.
+    pub fn compress(self, data: &[u8]) -> Result<Option<Vec<u8>>, TransferError> {
+        let out = match self {
+            Self::None => return Ok(None),
+            Self::Gzip => {
+                let mut enc =
+                    flate2::write::GzEncoder::new(Vec::new(), flate2::Compression::fast());
+                enc.write_all(data)?;
+                enc.finish()?
+            }
+            Self::Zstd => zstd::bulk::compress(data, 0)?,
+        };
+        Ok((out.len() < data.len()).then_some(out))
+    }
+
+    /// Decompresses a chunk, refusing to inflate past `max_len` bytes.
+    pub fn decompress(self, data: &[u8], max_len: usize) -> Result<Vec<u8>, TransferError> {
+        let reader: Box<dyn Read + '_> = match self {
+            Self::None => return Ok(data.to_vec()),
+            Self::Gzip => Box::new(flate2::read::GzDecoder::new(data)),
+            Self::Zstd => Box::new(zstd::stream::read::Decoder::new(data)?),
+        };
+        let mut out = Vec::new();
+        reader.take(max_len as u64 + 1).read_to_end(&mut out)?;
+        if out.len() > max_len {
+            return Err(TransferError::Compression(format!(
+                "chunk inflates past {max_len} bytes"
+            )));
+        }
+        Ok(out)
+    }
+}
+
+/// Whether `path` is a format that's already compressed, so compressing
+/// its chunks again would only cost CPU.
+pub fn is_precompressed(path: &str) -> bool {
+    std::path::Path::new(path)
+        .extension()
+        .and_then(|ext| ext.to_str())
+        .is_some_and(|ext| {
+            PRECOMPRESSED_EXTENSIONS
+                .iter()
+                .any(|known| ext.eq_ignore_ascii_case(known))
+        })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn sample() -> Vec<u8> {
+        b"level geometry, textures and sounds ".repeat(4096)
+    }
+
+    #[test]
+    fn codecs_round_trip() {
+        let data = sample();
+        for codec in [Compression::Gzip, Compression::Zstd] {
+            let packed = codec.compress(&data).unwrap().unwrap();
+            assert!(packed.len() < data.len() / 10, "{codec:?}");
+            assert_eq!(codec.decompress(&packed, data.len()).unwrap(), data);
+            assert_eq!(Compression::from_name(codec.name()), Some(codec));
+        }
+        assert_eq!(Compression::from_name(""), Some(Compression::None));
+        assert_eq!(Compression::from_name("brotli"), None);
+        assert!(Compression::None.compress(&data).unwrap().is_none());
+    }
+
+    #[test]
+    fn incompressible_data_stays_raw() {
+        // Bytes from a simple LCG don't compress.
+        let mut x: u32 = 1;
+        let noise: Vec<u8> = (0..64 * 1024)
+            .map(|_| {
+                x = x.wrapping_mul(1_664_525).wrapping_add(1_013_904_223);
+                (x >> 24) as u8
+            })
+            .collect();
+        assert!(Compression::Zstd.compress(&noise).unwrap().is_none());
+        assert!(Compression::Gzip.compress(&noise).unwrap().is_none());
+    }
+
+    #[test]
+    fn decompress_rejects_oversized_output() {
+        let data = sample();
+        let packed = Compression::Zstd.compress(&data).unwrap().unwrap();
+        let err = Compression::Zstd
+            .decompress(&packed, data.len() - 1)
+            .unwrap_err();
+        assert!(matches!(err, TransferError::Compression(_)));
+    }
+
+    #[test]
+    fn precompressed_by_extension() {
+        assert!(is_precompressed("movies/intro.MP4"));
+        assert!(is_precompressed("assets.zip"));
+        assert!(!is_precompressed("game.exe"));
+        assert!(!is_precompressed("README"));
+    }
+}
@@ -0,0 +1,123 @@
+//! Delta sync: spotting files that are already installed unchanged.
+//!
+//! The Hub reports each file's size and modification time. After an
+//! upload the agent stamps the installed files with the Hub's times, so
+//! on the next upload to the same place a file whose size and time still
+//! match hasn't changed and can be skipped.
+
+use std::fs::{File, Metadata};
+use std::path::Path;
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+
+use capydeploy_protocol::messages::FileEntry;
+
+use crate::{safe_join, validate_upload_path};
+
+/// Modification time of `meta` in Unix seconds, 0 if unavailable.
+pub fn modified_unix_secs(meta: &Metadata) -> i64 {
+    meta.modified()
+        .ok()
+        .and_then(|t| t.duration_since(UNIX_EPOCH).ok())
+        .map_or(0, |d| d.as_secs() as i64)
+}
+
+/// Files of `files` already under `root` with the same size and
+/// modification time. Files without a known time are always sent.
+pub fn unchanged_files(root: &Path, files: &[FileEntry]) -> Vec<String> {
+    files
+        .iter()
+        .filter(|f| f.modified > 0 && validate_upload_path(&f.relative_path).is_ok())
+        .filter(|f| {
+            std::fs::metadata(root.join(&f.relative_path)).is_ok_and(|meta| {
+                meta.is_file()
+                    && meta.len() as i64 == f.size
+                    && modified_unix_secs(&meta) == f.modified
+            })
+        })
+        .map(|f| f.relative_path.clone())
+        .collect()
+}
+
+/// Sets the modification time of each of `files` under `root` to the
+/// one the Hub reported, so a later upload can recognize them.
+pub fn stamp_modified(root: &Path, files: &[FileEntry]) -> std::io::Result<()> {
+    for f in files.iter().filter(|f| f.modified > 0) {
+        let Ok(path) = safe_join(root, &f.relative_path) else {
+            continue;
+        };
+        let time = SystemTime::UNIX_EPOCH + Duration::from_secs(f.modified as u64);
+        File::options().write(true).open(path)?.set_modified(time)?;
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn entry(path: &str, size: i64, modified: i64) -> FileEntry {
+        FileEntry {
+            relative_path: path.into(),
+            size,
+            modified,
+        }
+    }
+
+    #[test]
+    fn stamped_files_are_unchanged() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::create_dir_all(dir.path().join("data")).unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"exe").unwrap();
+        std::fs::write(dir.path().join("data/level1.dat"), b"level").unwrap();
+
+        let files = [
+            entry("game.exe", 3, 1_700_000_000),
+            entry("data/level1.dat", 5, 1_700_000_100),
+        ];
+        assert!(unchanged_files(dir.path(), &files).is_empty());
+
+        stamp_modified(dir.path(), &files).unwrap();
+        assert_eq!(
+            unchanged_files(dir.path(), &files),
+            vec!["game.exe".to_string(), "data/level1.dat".to_string()]
+        );
+
+        // An edited level, a new file, and one without a time go out.
+        let next = [
+            entry("game.exe", 3, 1_700_000_000),
+            entry("data/level1.dat", 5, 1_700_000_200),
+            entry("data/level2.dat", 5, 1_700_000_100),
+            entry("readme.txt", 3, 0),
+        ];
+        assert_eq!(unchanged_files(dir.path(), &next), vec!["game.exe"]);
+    }
+
+    #[test]
+    fn size_change_is_detected() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"exe").unwrap();
+        stamp_modified(dir.path(), &[entry("game.exe", 3, 1_700_000_000)]).unwrap();
+
+        let files = [entry("game.exe", 4, 1_700_000_000)];
+        assert!(unchanged_files(dir.path(), &files).is_empty());
+    }
+
+    #[test]
+    fn other_install_path_sends_everything() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"exe").unwrap();
+        let files = [entry("game.exe", 3, 1_700_000_000)];
+        stamp_modified(dir.path(), &files).unwrap();
+
+        let elsewhere = tempfile::tempdir().unwrap();
+        assert!(unchanged_files(elsewhere.path(), &files).is_empty());
+    }
+
+    #[test]
+    fn unsafe_paths_are_never_skipped() {
+        let dir = tempfile::tempdir().unwrap();
+        let files = [entry("../outside.txt", 3, 1_700_000_000)];
+        assert!(unchanged_files(dir.path(), &files).is_empty());
+        stamp_modified(dir.path(), &files).unwrap();
+    }
+}
@@ -2,17 +2,37 @@
 //!
 //! Port of `pkg/transfer/` from the Go codebase.
 
+mod artwork;
 mod chunked;
+mod compress;
+mod delta;
+mod limit;
 mod progress;
+mod rate;
+mod staging;
 mod types;
 mod validation;
+mod verify;
 
+pub use artwork::{
+    ArtworkAssembler, ArtworkFrame, Assembled, MAX_ARTWORK_BYTES, SUPPORTED_IMAGE_FORMATS,
+    detect_image_type, validate_image,
+};
 pub use chunked::{
     ChecksumError, ChunkReader, ChunkWriter, calculate_file_checksum, checksum_bytes,
 };
-pub use progress::{ProgressTracker, SpeedCalculator};
+pub use compress::{Compression, is_precompressed};
+pub use delta::{modified_unix_secs, stamp_modified, unchanged_files};
+pub use limit::{UploadLimiter, UploadPermit};
+pub use progress::{ProgressTracker, SpeedCalculator, ThroughputMeter};
+pub use rate::RateLimiter;
+pub use staging::{
+    MoveMethod, Staging, choose_staging_dir, clear_staging_state, merge_into_place,
+    move_into_place, prepare_staging, replace_into_place, same_filesystem, save_staging_state,
+};
 pub use types::{Chunk, UploadSession};
-pub use validation::validate_upload_path;
+pub use validation::{resolve_install_subpath, safe_join, validate_upload_path};
+pub use verify::verify_installed;
 
 /// Default chunk size: 4 MiB.
 ///
@@ -37,4 +57,10 @@ pub enum TransferError {
 
     #[error("invalid path: {0}")]
     InvalidPath(String),
+
+    #[error("invalid artwork: {0}")]
+    InvalidArtwork(String),
+
+    #[error("compression error: {0}")]
+    Compression(String),
 }
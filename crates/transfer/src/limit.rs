@@ -0,0 +1,95 @@
+//! Cap on simultaneous upload sessions.
+
+use std::sync::Arc;
+use std::sync::atomic::{AtomicUsize, Ordering};
+
+/// Hands out a bounded number of upload slots.
+///
+/// Cloning shares the same slots. A slot is held by its [`UploadPermit`]
+/// and released when the permit is dropped, so storing the permit in the
+/// session frees the slot however the session ends.
+#[derive(Debug, Clone)]
+pub struct UploadLimiter {
+    max: usize,
+    active: Arc<AtomicUsize>,
+}
+
+/// A held upload slot; released on drop.
+#[derive(Debug)]
+pub struct UploadPermit {
+    active: Arc<AtomicUsize>,
+}
+
+impl UploadLimiter {
+    /// Creates a limiter allowing `max` concurrent uploads (at least one).
+    pub fn new(max: usize) -> Self {
+        Self {
+            max: max.max(1),
+            active: Arc::new(AtomicUsize::new(0)),
+        }
+    }
+
+    /// Takes a slot, or returns `None` when all slots are in use.
+    pub fn try_acquire(&self) -> Option<UploadPermit> {
+        self.active
+            .fetch_update(Ordering::AcqRel, Ordering::Acquire, |n| {
+                (n < self.max).then_some(n + 1)
+            })
+            .ok()
+            .map(|_| UploadPermit {
+                active: Arc::clone(&self.active),
+            })
+    }
+
+    /// Maximum number of concurrent uploads.
+    pub fn max(&self) -> usize {
+        self.max
+    }
+
+    /// Number of slots currently held.
+    pub fn active(&self) -> usize {
+        self.active.load(Ordering::Acquire)
+    }
+}
+
+impl Drop for UploadPermit {
+    fn drop(&mut self) {
+        self.active.fetch_sub(1, Ordering::AcqRel);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn rejects_beyond_limit() {
+        let limiter = UploadLimiter::new(2);
+        let a = limiter.try_acquire().unwrap();
+        let _b = limiter.try_acquire().unwrap();
+        assert!(limiter.try_acquire().is_none());
+        assert_eq!(limiter.active(), 2);
+
+        drop(a);
+        assert_eq!(limiter.active(), 1);
+        assert!(limiter.try_acquire().is_some());
+    }
+
+    #[test]
+    fn zero_means_one() {
+        let limiter = UploadLimiter::new(0);
+        assert_eq!(limiter.max(), 1);
+        let _p = limiter.try_acquire().unwrap();
+        assert!(limiter.try_acquire().is_none());
+    }
+
+    #[test]
+    fn clones_share_slots() {
+        let limiter = UploadLimiter::new(1);
+        let other = limiter.clone();
+        let p = limiter.try_acquire().unwrap();
+        assert!(other.try_acquire().is_none());
+        drop(p);
+        assert!(other.try_acquire().is_some());
+    }
+}
@@ -218,6 +218,78 @@ impl SpeedCalculator {
     }
 }
 
+/// Time constant of [`ThroughputMeter`]'s moving average: older speed
+/// fades to a third of its weight over this many seconds.
+const THROUGHPUT_TIME_CONSTANT_SECS: f64 = 3.0;
+
+/// Samples closer together than this are folded into the next one, so
+/// bursts of pipelined chunks don't swing the speed.
+const THROUGHPUT_MIN_INTERVAL: Duration = Duration::from_millis(100);
+
+/// Transfer speed as an exponential moving average of the byte count over
+/// time. Cheap enough to update on every chunk; unlike
+/// [`SpeedCalculator`] it keeps no samples and needs no lock of its own.
+#[derive(Debug, Clone, Default)]
+pub struct ThroughputMeter {
+    bytes_per_sec: f64,
+    last: Option<(Instant, i64)>,
+}
+
+impl ThroughputMeter {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Records that `total` bytes have been transferred so far.
+    pub fn record(&mut self, total: i64) {
+        self.record_at(total, Instant::now());
+    }
+
+    fn record_at(&mut self, total: i64, now: Instant) {
+        let Some((at, bytes)) = self.last else {
+            self.last = Some((now, total));
+            return;
+        };
+        // Fewer bytes than before: files are being resent from scratch.
+        if total < bytes {
+            self.last = Some((now, total));
+            return;
+        }
+        let elapsed = now.duration_since(at);
+        if elapsed < THROUGHPUT_MIN_INTERVAL {
+            return;
+        }
+        let secs = elapsed.as_secs_f64();
+        let rate = (total - bytes) as f64 / secs;
+        self.bytes_per_sec = if self.bytes_per_sec == 0.0 {
+            rate
+        } else {
+            let weight = 1.0 - (-secs / THROUGHPUT_TIME_CONSTANT_SECS).exp();
+            self.bytes_per_sec + weight * (rate - self.bytes_per_sec)
+        };
+        self.last = Some((now, total));
+    }
+
+    /// Starts a new interval at the next sample, e.g. after a pause, so
+    /// time spent not transferring isn't counted as a slow transfer. The
+    /// speed measured so far stays as the estimate until then.
+    pub fn restart(&mut self) {
+        self.last = None;
+    }
+
+    /// Current speed; 0 until two samples are far enough apart.
+    pub fn bytes_per_sec(&self) -> f64 {
+        self.bytes_per_sec
+    }
+
+    /// Seconds to transfer `remaining` bytes at the current speed, or
+    /// `None` while the speed is unknown.
+    pub fn eta_secs(&self, remaining: i64) -> Option<u64> {
+        (self.bytes_per_sec > 0.0)
+            .then(|| (remaining.max(0) as f64 / self.bytes_per_sec).ceil() as u64)
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -233,11 +305,16 @@ mod tests {
                 executable: "test.exe".into(),
                 launch_options: String::new(),
                 tags: String::new(),
+                library_path: String::new(),
+                install_subpath: String::new(),
+                overwrite: false,
+                clean_install: false,
             },
             1024,
             vec![FileEntry {
                 relative_path: "test.exe".into(),
                 size: 1024,
+                modified: 0,
             }],
         ))
     }
@@ -362,4 +439,34 @@ mod tests {
         // Should not panic or deadlock.
         let _ = calc.bytes_per_second();
     }
+
+    #[test]
+    fn throughput_meter_averages_and_restarts() {
+        let mut meter = ThroughputMeter::new();
+        let start = Instant::now();
+        meter.record_at(0, start);
+        assert_eq!(meter.bytes_per_sec(), 0.0);
+        assert_eq!(meter.eta_secs(100), None);
+
+        // 1000 B/s for the first second is taken as is.
+        meter.record_at(1000, start + Duration::from_secs(1));
+        assert!((meter.bytes_per_sec() - 1000.0).abs() < 1e-6);
+        assert_eq!(meter.eta_secs(2500), Some(3));
+
+        // Too close to the last sample to count.
+        meter.record_at(5000, start + Duration::from_millis(1050));
+        assert!((meter.bytes_per_sec() - 1000.0).abs() < 1e-6);
+
+        // A faster second pulls the average up, but not all the way.
+        meter.record_at(4000, start + Duration::from_secs(2));
+        let speed = meter.bytes_per_sec();
+        assert!(speed > 1000.0 && speed < 3000.0, "{speed}");
+
+        // A pause doesn't drag the speed down once restarted.
+        meter.restart();
+        meter.record_at(4000, start + Duration::from_secs(60));
+        assert_eq!(meter.bytes_per_sec(), speed);
+        meter.record_at(4000 + speed as i64, start + Duration::from_secs(61));
+        assert!((meter.bytes_per_sec() - speed).abs() < 1.0);
+    }
 }
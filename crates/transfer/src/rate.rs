@@ -0,0 +1,112 @@
+//! Upload bandwidth cap.
+
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use tokio::time::Instant;
+
+/// Token bucket capping the bytes sent per second.
+///
+/// Cloning shares the same bucket, so every file of an upload draws from
+/// one budget. A limit of zero means unlimited.
+#[derive(Debug, Clone, Default)]
+pub struct RateLimiter {
+    bucket: Option<Arc<Mutex<Bucket>>>,
+}
+
+#[derive(Debug)]
+struct Bucket {
+    bytes_per_sec: u64,
+    /// Bytes that may be sent right away; negative while paying off a
+    /// send larger than the bucket.
+    tokens: f64,
+    refilled_at: Instant,
+}
+
+impl RateLimiter {
+    /// Creates a limiter allowing `bytes_per_sec`, with at most one
+    /// second's worth sent in a burst. Zero means unlimited.
+    pub fn new(bytes_per_sec: u64) -> Self {
+        let bucket = (bytes_per_sec > 0).then(|| {
+            Arc::new(Mutex::new(Bucket {
+                bytes_per_sec,
+                tokens: bytes_per_sec as f64,
+                refilled_at: Instant::now(),
+            }))
+        });
+        Self { bucket }
+    }
+
+    /// A limiter that never waits.
+    pub fn unlimited() -> Self {
+        Self::default()
+    }
+
+    /// The configured limit, zero if unlimited.
+    pub fn bytes_per_sec(&self) -> u64 {
+        self.bucket
+            .as_ref()
+            .map_or(0, |b| b.lock().unwrap().bytes_per_sec)
+    }
+
+    /// Waits until `bytes` may be sent under the limit.
+    pub async fn acquire(&self, bytes: usize) {
+        let Some(bucket) = &self.bucket else {
+            return;
+        };
+        let wait = {
+            let mut b = bucket.lock().unwrap();
+            let now = Instant::now();
+            let rate = b.bytes_per_sec as f64;
+            let elapsed = now.duration_since(b.refilled_at).as_secs_f64();
+            b.tokens = (b.tokens + elapsed * rate).min(rate);
+            b.refilled_at = now;
+            b.tokens -= bytes as f64;
+            if b.tokens < 0.0 {
+                Duration::from_secs_f64(-b.tokens / rate)
+            } else {
+                Duration::ZERO
+            }
+        };
+        if !wait.is_zero() {
+            tokio::time::sleep(wait).await;
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test(start_paused = true)]
+    async fn unlimited_never_waits() {
+        let limiter = RateLimiter::new(0);
+        assert_eq!(limiter.bytes_per_sec(), 0);
+        let start = Instant::now();
+        limiter.acquire(100 * 1024 * 1024).await;
+        assert_eq!(start.elapsed(), Duration::ZERO);
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn caps_sustained_rate() {
+        let limiter = RateLimiter::new(1000);
+        let start = Instant::now();
+        // The first second's worth goes out as a burst; the other 4000
+        // bytes take four more seconds.
+        for _ in 0..10 {
+            limiter.acquire(500).await;
+        }
+        assert_eq!(start.elapsed(), Duration::from_secs(4));
+    }
+
+    #[tokio::test(start_paused = true)]
+    async fn clones_share_budget() {
+        let limiter = RateLimiter::new(1000);
+        let other = limiter.clone();
+        let start = Instant::now();
+        limiter.acquire(1000).await;
+        other.acquire(2000).await;
+        assert_eq!(start.elapsed(), Duration::from_secs(2));
+        assert_eq!(other.bytes_per_sec(), 1000);
+    }
+}
@@ -0,0 +1,562 @@
+//! Staging directory placement for installs.
+//!
+//! An install is written to a staging directory and moved to its final
+//! path when complete. The move is a cheap rename only when both paths are
+//! on the same filesystem (e.g. both on the SD card); otherwise every byte
+//! is copied again. Staging is therefore placed next to the target.
+
+use std::collections::HashMap;
+use std::io::{self, Write};
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicU64, Ordering};
+
+use capydeploy_protocol::messages::FileEntry;
+use serde::{Deserialize, Serialize};
+
+use crate::TransferError;
+use crate::validation::safe_join;
+
+/// Where an install is staged, and why if it's not ideal.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Staging {
+    pub dir: PathBuf,
+    /// Set when staging ended up on a different filesystem than the
+    /// target, so finishing the install will copy instead of rename.
+    pub warning: Option<String>,
+}
+
+/// How a staged install reached its final path.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum MoveMethod {
+    Renamed,
+    /// Cross-device fallback: copied, then the staging dir was removed.
+    Copied,
+}
+
+/// Returns the staging directory for `target` (`.<name>.partial` beside
+/// it), creating it. Falls back to the system temp dir with a warning when
+/// the sibling can't be created or lands on another filesystem.
+pub fn choose_staging_dir(target: &Path) -> Staging {
+    let name = target
+        .file_name()
+        .map(|n| n.to_string_lossy().into_owned())
+        .unwrap_or_else(|| "install".into());
+    let partial = format!(".{name}.partial");
+
+    if let Some(parent) = target.parent() {
+        let sibling = parent.join(&partial);
+        if std::fs::create_dir_all(&sibling).is_ok() {
+            if same_filesystem(&sibling, target) {
+                return Staging {
+                    dir: sibling,
+                    warning: None,
+                };
+            }
+            let _ = std::fs::remove_dir(&sibling);
+        }
+    }
+
+    let dir = std::env::temp_dir()
+        .join("capydeploy-staging")
+        .join(partial);
+    let _ = std::fs::create_dir_all(&dir);
+    let warning = (!same_filesystem(&dir, target)).then(|| {
+        format!(
+            "staging for {} is on a different filesystem; the install will be copied instead of renamed",
+            target.display()
+        )
+    });
+    Staging { dir, warning }
+}
+
+/// How far an upload into a staging directory got, saved beside it
+/// (`<dir>.json`) so the upload can resume after the agent lost track of it.
+#[derive(Debug, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct StagingState {
+    files: Vec<FileEntry>,
+    /// Bytes received from the start of each file.
+    received: HashMap<String, i64>,
+}
+
+fn staging_state_path(staging: &Path) -> PathBuf {
+    let mut name = staging.file_name().unwrap_or_default().to_os_string();
+    name.push(".json");
+    staging.with_file_name(name)
+}
+
+/// Prepares `staging` for an upload of `files`. A directory left by an
+/// upload of the same manifest is kept, and the bytes it already holds per
+/// file are returned; anything else there is removed first.
+pub fn prepare_staging(
+    staging: &Path,
+    files: &[FileEntry],
+) -> Result<HashMap<String, i64>, TransferError> {
+    if let Some(received) = staged_offsets(staging, files) {
+        return Ok(received);
+    }
+    match std::fs::remove_dir_all(staging) {
+        Err(e) if e.kind() != io::ErrorKind::NotFound => return Err(e.into()),
+        _ => {}
+    }
+    clear_staging_state(staging);
+    std::fs::create_dir_all(staging)?;
+    Ok(HashMap::new())
+}
+
+/// The saved offsets of `files` in `staging`, if it was saved for the same
+/// manifest. An offset is only trusted when the staged file is at least
+/// that long.
+fn staged_offsets(staging: &Path, files: &[FileEntry]) -> Option<HashMap<String, i64>> {
+    if !staging.is_dir() {
+        return None;
+    }
+    let json = std::fs::read(staging_state_path(staging)).ok()?;
+    let state: StagingState = serde_json::from_slice(&json).ok()?;
+    if state.files != files {
+        return None;
+    }
+    let received = files
+        .iter()
+        .filter_map(|f| {
+            let offset = state.received.get(&f.relative_path)?.min(&f.size);
+            let path = safe_join(staging, &f.relative_path).ok()?;
+            let len = std::fs::metadata(path).ok()?.len() as i64;
+            (*offset > 0 && len >= *offset).then(|| (f.relative_path.clone(), *offset))
+        })
+        .collect();
+    Some(received)
+}
+
+/// Saves the manifest of the upload staged in `staging` and the bytes
+/// received per file, for [`prepare_staging`] to resume from.
+///
+/// The staged files are flushed to disk first, and only bytes that made it
+/// there are recorded, so a power loss can't leave offsets pointing past
+/// the data. Writes go through a temporary file, so a reader never sees
+/// half of one.
+pub fn save_staging_state(
+    staging: &Path,
+    files: &[FileEntry],
+    received: &HashMap<String, i64>,
+) -> Result<(), TransferError> {
+    static SAVES: AtomicU64 = AtomicU64::new(0);
+    let state = StagingState {
+        files: files.to_vec(),
+        received: sync_staged(staging, received),
+    };
+    let json = serde_json::to_vec(&state).map_err(io::Error::other)?;
+    let path = staging_state_path(staging);
+    let tmp = path.with_extension(format!("{}.tmp", SAVES.fetch_add(1, Ordering::Relaxed)));
+    let write = |tmp: &Path| -> io::Result<()> {
+        let mut file = std::fs::File::create(tmp)?;
+        file.write_all(&json)?;
+        file.sync_all()
+    };
+    write(&tmp)
+        .and_then(|()| std::fs::rename(&tmp, &path))
+        .inspect_err(|_| {
+            let _ = std::fs::remove_file(&tmp);
+        })?;
+    Ok(())
+}
+
+/// Flushes the staged data of each file in `received` to disk, returning
+/// the offsets now covered by it. Files that can't be synced are left out.
+fn sync_staged(staging: &Path, received: &HashMap<String, i64>) -> HashMap<String, i64> {
+    received
+        .iter()
+        .filter_map(|(relative, &offset)| {
+            let path = safe_join(staging, relative).ok()?;
+            let file = std::fs::OpenOptions::new().write(true).open(path).ok()?;
+            file.sync_data().ok()?;
+            let synced = offset.min(file.metadata().ok()?.len() as i64);
+            (synced > 0).then(|| (relative.clone(), synced))
+        })
+        .collect()
+}
+
+/// Removes the state saved by [`save_staging_state`].
+pub fn clear_staging_state(staging: &Path) {
+    let _ = std::fs::remove_file(staging_state_path(staging));
+}
+
+/// Moves a finished staging directory to `target`, copying when a rename
+/// would cross filesystems.
+pub fn move_into_place(staging: &Path, target: &Path) -> Result<MoveMethod, TransferError> {
+    move_with(staging, target, |from, to| std::fs::rename(from, to))
+}
+
+/// Moves a finished staging directory into `target`. A missing target is
+/// renamed into place whole; an existing one has each staged file renamed
+/// over its counterpart, so files the upload didn't send stay.
+pub fn merge_into_place(staging: &Path, target: &Path) -> Result<MoveMethod, TransferError> {
+    merge_with(staging, target, &|from: &Path, to: &Path| {
+        std::fs::rename(from, to)
+    })
+}
+
+/// Replaces `target` with a finished staging directory. The old install is
+/// renamed aside (`.<name>.old`) and only deleted once the new one is in
+/// place; if the move fails, it is put back.
+pub fn replace_into_place(staging: &Path, target: &Path) -> Result<MoveMethod, TransferError> {
+    replace_with(staging, target, |from, to| std::fs::rename(from, to))
+}
+
+fn replace_with(
+    staging: &Path,
+    target: &Path,
+    rename: impl Fn(&Path, &Path) -> io::Result<()>,
+) -> Result<MoveMethod, TransferError> {
+    if !target.exists() {
+        return move_with(staging, target, rename);
+    }
+    let name = target
+        .file_name()
+        .map(|n| n.to_string_lossy().into_owned())
+        .unwrap_or_else(|| "install".into());
+    let old = target.with_file_name(format!(".{name}.old"));
+    // Left behind by a replace that was interrupted after succeeding.
+    if old.exists() {
+        remove_path(&old)?;
+    }
+    // Beside the target, so this rename never crosses filesystems.
+    std::fs::rename(target, &old)?;
+    match move_with(staging, target, rename) {
+        Ok(method) => {
+            let _ = remove_path(&old);
+            Ok(method)
+        }
+        Err(e) => {
+            // A copy may have got part way.
+            if target.exists() {
+                let _ = remove_path(target);
+            }
+            std::fs::rename(&old, target)?;
+            Err(e)
+        }
+    }
+}
+
+fn remove_path(path: &Path) -> io::Result<()> {
+    if path.is_dir() {
+        std::fs::remove_dir_all(path)
+    } else {
+        std::fs::remove_file(path)
+    }
+}
+
+fn merge_with(
+    staging: &Path,
+    target: &Path,
+    rename: &impl Fn(&Path, &Path) -> io::Result<()>,
+) -> Result<MoveMethod, TransferError> {
+    if !target.is_dir() {
+        if target.exists() {
+            std::fs::remove_file(target)?;
+        }
+        return move_with(staging, target, rename);
+    }
+    let mut method = MoveMethod::Renamed;
+    for entry in std::fs::read_dir(staging)? {
+        let entry = entry?;
+        let from = entry.path();
+        let to = target.join(entry.file_name());
+        let moved = if entry.file_type()?.is_dir() {
+            merge_with(&from, &to, rename)?
+        } else {
+            if to.is_dir() {
+                std::fs::remove_dir_all(&to)?;
+            }
+            match rename(&from, &to) {
+                Ok(()) => MoveMethod::Renamed,
+                Err(e) if e.kind() == io::ErrorKind::CrossesDevices => {
+                    std::fs::copy(&from, &to)?;
+                    MoveMethod::Copied
+                }
+                Err(e) => return Err(e.into()),
+            }
+        };
+        if moved == MoveMethod::Copied {
+            method = MoveMethod::Copied;
+        }
+    }
+    std::fs::remove_dir_all(staging)?;
+    Ok(method)
+}
+
+fn move_with(
+    staging: &Path,
+    target: &Path,
+    rename: impl Fn(&Path, &Path) -> io::Result<()>,
+) -> Result<MoveMethod, TransferError> {
+    if let Some(parent) = target.parent() {
+        std::fs::create_dir_all(parent)?;
+    }
+    match rename(staging, target) {
+        Ok(()) => Ok(MoveMethod::Renamed),
+        Err(e) if e.kind() == io::ErrorKind::CrossesDevices => {
+            copy_dir(staging, target)?;
+            std::fs::remove_dir_all(staging)?;
+            Ok(MoveMethod::Copied)
+        }
+        Err(e) => Err(e.into()),
+    }
+}
+
+fn copy_dir(from: &Path, to: &Path) -> io::Result<()> {
+    std::fs::create_dir_all(to)?;
+    for entry in std::fs::read_dir(from)? {
+        let entry = entry?;
+        let dest = to.join(entry.file_name());
+        if entry.file_type()?.is_dir() {
+            copy_dir(&entry.path(), &dest)?;
+        } else {
+            std::fs::copy(entry.path(), &dest)?;
+        }
+    }
+    Ok(())
+}
+
+/// Whether `a` and `b` are on the same filesystem. Paths that don't exist
+/// yet are judged by their nearest existing ancestor.
+pub fn same_filesystem(a: &Path, b: &Path) -> bool {
+    match (existing_ancestor(a), existing_ancestor(b)) {
+        (Some(a), Some(b)) => device_of(&a) == device_of(&b),
+        _ => false,
+    }
+}
+
+fn existing_ancestor(path: &Path) -> Option<PathBuf> {
+    path.ancestors()
+        .find(|p| p.exists())
+        .map(|p| p.canonicalize().unwrap_or_else(|_| p.to_path_buf()))
+}
+
+#[cfg(unix)]
+fn device_of(path: &Path) -> Option<u64> {
+    use std::os::unix::fs::MetadataExt;
+    std::fs::metadata(path).ok().map(|m| m.dev())
+}
+
+/// Volumes are identified by drive or share prefix on Windows.
+#[cfg(not(unix))]
+fn device_of(path: &Path) -> Option<std::ffi::OsString> {
+    match path.components().next() {
+        Some(std::path::Component::Prefix(p)) => Some(p.as_os_str().to_ascii_lowercase()),
+        _ => None,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tempfile::TempDir;
+
+    #[test]
+    fn staging_sits_beside_target() {
+        let dir = TempDir::new().unwrap();
+        let target = dir.path().join("Games").join("Celeste");
+
+        let staging = choose_staging_dir(&target);
+        assert_eq!(
+            staging.dir,
+            dir.path().join("Games").join(".Celeste.partial")
+        );
+        assert!(staging.dir.is_dir());
+        assert!(staging.warning.is_none());
+        assert!(same_filesystem(&staging.dir, &target));
+    }
+
+    #[test]
+    fn unusable_parent_falls_back_to_temp() {
+        let dir = TempDir::new().unwrap();
+        // A file where the parent directory should be.
+        let blocker = dir.path().join("Games");
+        std::fs::write(&blocker, b"").unwrap();
+        let target = blocker.join("Celeste");
+
+        let staging = choose_staging_dir(&target);
+        assert!(staging.dir.starts_with(std::env::temp_dir()));
+        assert!(staging.dir.is_dir());
+        // Whether it warns depends on where the temp dir lives.
+        assert_eq!(
+            staging.warning.is_some(),
+            !same_filesystem(&staging.dir, &target)
+        );
+        let _ = std::fs::remove_dir_all(&staging.dir);
+    }
+
+    fn entry(path: &str, size: i64) -> FileEntry {
+        FileEntry {
+            relative_path: path.into(),
+            size,
+            modified: 0,
+        }
+    }
+
+    #[test]
+    fn staging_is_kept_for_the_same_manifest() {
+        let dir = TempDir::new().unwrap();
+        let staging = dir.path().join(".game.partial");
+        let files = vec![
+            entry("a.bin", 10),
+            entry("data/b.bin", 10),
+            entry("c.bin", 10),
+        ];
+
+        assert!(prepare_staging(&staging, &files).unwrap().is_empty());
+        std::fs::write(staging.join("a.bin"), [0u8; 10]).unwrap();
+        std::fs::create_dir_all(staging.join("data")).unwrap();
+        std::fs::write(staging.join("data/b.bin"), [0u8; 4]).unwrap();
+        let received = HashMap::from([
+            ("a.bin".to_string(), 10),
+            ("data/b.bin".to_string(), 4),
+            // Claimed, but the file isn't there.
+            ("c.bin".to_string(), 6),
+        ]);
+        save_staging_state(&staging, &files, &received).unwrap();
+
+        let resumed = prepare_staging(&staging, &files).unwrap();
+        assert_eq!(
+            resumed,
+            HashMap::from([("a.bin".to_string(), 10), ("data/b.bin".to_string(), 4)])
+        );
+        assert!(staging.join("a.bin").exists());
+
+        // A different manifest starts over.
+        let changed = vec![entry("a.bin", 12), entry("data/b.bin", 10)];
+        assert!(prepare_staging(&staging, &changed).unwrap().is_empty());
+        assert!(staging.is_dir());
+        assert!(!staging.join("a.bin").exists());
+        assert!(!staging_state_path(&staging).exists());
+
+        // So does a folder nobody saved state for.
+        std::fs::write(staging.join("stray"), b"x").unwrap();
+        assert!(prepare_staging(&staging, &changed).unwrap().is_empty());
+        assert!(!staging.join("stray").exists());
+    }
+
+    #[test]
+    fn saved_offsets_cover_only_synced_bytes() {
+        let dir = TempDir::new().unwrap();
+        let staging = dir.path().join(".game.partial");
+        let files = vec![entry("a.bin", 10), entry("b.bin", 10)];
+        prepare_staging(&staging, &files).unwrap();
+        std::fs::write(staging.join("a.bin"), [0u8; 4]).unwrap();
+
+        // Reported further than the data written, and not written at all.
+        let received = HashMap::from([("a.bin".to_string(), 10), ("b.bin".to_string(), 6)]);
+        save_staging_state(&staging, &files, &received).unwrap();
+
+        let json = std::fs::read(staging_state_path(&staging)).unwrap();
+        let state: StagingState = serde_json::from_slice(&json).unwrap();
+        assert_eq!(state.received, HashMap::from([("a.bin".to_string(), 4)]));
+    }
+
+    #[test]
+    fn move_renames_on_same_filesystem() {
+        let dir = TempDir::new().unwrap();
+        let staging = dir.path().join(".game.partial");
+        std::fs::create_dir_all(staging.join("data")).unwrap();
+        std::fs::write(staging.join("data").join("a.bin"), b"abc").unwrap();
+        let target = dir.path().join("game");
+
+        assert_eq!(
+            move_into_place(&staging, &target).unwrap(),
+            MoveMethod::Renamed
+        );
+        assert_eq!(std::fs::read(target.join("data/a.bin")).unwrap(), b"abc");
+        assert!(!staging.exists());
+    }
+
+    #[test]
+    fn merge_keeps_files_not_staged() {
+        let dir = TempDir::new().unwrap();
+        let target = dir.path().join("game");
+        std::fs::create_dir_all(target.join("data")).unwrap();
+        std::fs::write(target.join("game.sh"), b"old").unwrap();
+        std::fs::write(target.join("data").join("save.dat"), b"keep").unwrap();
+        std::fs::write(target.join("assets"), b"now a folder").unwrap();
+
+        let staging = dir.path().join(".game.partial");
+        std::fs::create_dir_all(staging.join("data")).unwrap();
+        std::fs::create_dir_all(staging.join("assets")).unwrap();
+        std::fs::write(staging.join("game.sh"), b"new").unwrap();
+        std::fs::write(staging.join("data").join("a.bin"), b"abc").unwrap();
+        std::fs::write(staging.join("assets").join("b.png"), b"png").unwrap();
+
+        assert_eq!(
+            merge_into_place(&staging, &target).unwrap(),
+            MoveMethod::Renamed
+        );
+        assert_eq!(std::fs::read(target.join("game.sh")).unwrap(), b"new");
+        assert_eq!(std::fs::read(target.join("data/a.bin")).unwrap(), b"abc");
+        assert_eq!(
+            std::fs::read(target.join("data/save.dat")).unwrap(),
+            b"keep"
+        );
+        assert_eq!(std::fs::read(target.join("assets/b.png")).unwrap(), b"png");
+        assert!(!staging.exists());
+
+        // With nothing there yet the whole directory is renamed.
+        let fresh = dir.path().join("other");
+        std::fs::create_dir_all(staging.join("bin")).unwrap();
+        std::fs::write(staging.join("bin").join("x"), b"x").unwrap();
+        merge_into_place(&staging, &fresh).unwrap();
+        assert_eq!(std::fs::read(fresh.join("bin/x")).unwrap(), b"x");
+        assert!(!staging.exists());
+    }
+
+    #[test]
+    fn replace_removes_old_install_only_after_move() {
+        let dir = TempDir::new().unwrap();
+        let target = dir.path().join("game");
+        std::fs::create_dir_all(&target).unwrap();
+        std::fs::write(target.join("old.bin"), b"old").unwrap();
+        let staging = dir.path().join(".game.partial");
+        std::fs::create_dir_all(&staging).unwrap();
+        std::fs::write(staging.join("new.bin"), b"new").unwrap();
+
+        // A failed move leaves the old install where it was.
+        let denied = |_: &Path, _: &Path| Err(io::Error::from(io::ErrorKind::PermissionDenied));
+        assert!(replace_with(&staging, &target, denied).is_err());
+        assert_eq!(std::fs::read(target.join("old.bin")).unwrap(), b"old");
+        assert!(!target.join("new.bin").exists());
+        assert!(staging.join("new.bin").exists());
+        assert!(!dir.path().join(".game.old").exists());
+
+        assert_eq!(
+            replace_into_place(&staging, &target).unwrap(),
+            MoveMethod::Renamed
+        );
+        assert_eq!(std::fs::read(target.join("new.bin")).unwrap(), b"new");
+        assert!(!target.join("old.bin").exists());
+        assert!(!dir.path().join(".game.old").exists());
+        assert!(!staging.exists());
+    }
+
+    #[test]
+    fn cross_device_move_copies() {
+        let dir = TempDir::new().unwrap();
+        let staging = dir.path().join(".game.partial");
+        std::fs::create_dir_all(staging.join("data")).unwrap();
+        std::fs::write(staging.join("game.sh"), b"#!/bin/sh").unwrap();
+        std::fs::write(staging.join("data").join("a.bin"), b"abc").unwrap();
+        let target = dir.path().join("sd").join("game");
+
+        let exdev = |_: &Path, _: &Path| Err(io::Error::from(io::ErrorKind::CrossesDevices));
+        assert_eq!(
+            move_with(&staging, &target, exdev).unwrap(),
+            MoveMethod::Copied
+        );
+        assert_eq!(std::fs::read(target.join("game.sh")).unwrap(), b"#!/bin/sh");
+        assert_eq!(std::fs::read(target.join("data/a.bin")).unwrap(), b"abc");
+        assert!(!staging.exists());
+
+        // Other rename errors are not papered over with a copy.
+        let denied = |_: &Path, _: &Path| Err(io::Error::from(io::ErrorKind::PermissionDenied));
+        assert!(move_with(&target, &dir.path().join("x"), denied).is_err());
+        assert!(target.exists());
+    }
+}
@@ -195,6 +195,10 @@ mod tests {
             executable: "game.exe".into(),
             launch_options: String::new(),
             tags: String::new(),
+            library_path: String::new(),
+            install_subpath: String::new(),
+            overwrite: false,
+            clean_install: false,
         }
     }
 
@@ -203,10 +207,12 @@ mod tests {
             FileEntry {
                 relative_path: "game.exe".into(),
                 size: 1024,
+                modified: 0,
             },
             FileEntry {
                 relative_path: "data/level1.dat".into(),
                 size: 2048,
+                modified: 0,
             },
         ]
     }
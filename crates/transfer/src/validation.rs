@@ -1,4 +1,4 @@
-use std::path::{Component, Path};
+use std::path::{Component, Path, PathBuf};
 
 use crate::TransferError;
 
@@ -46,6 +46,49 @@ pub fn validate_upload_path(file_path: &str) -> Result<(), TransferError> {
     Ok(())
 }
 
+/// Joins the upload path `rel` onto `root`, refusing anything that would
+/// land outside `root`.
+///
+/// `rel` is first checked with [`validate_upload_path`]. The deepest part
+/// of the result that already exists is then resolved, so a symlink left
+/// inside the game folder can't redirect a write elsewhere. Parts that
+/// don't exist yet are created as plain directories and files.
+pub fn safe_join(root: &Path, rel: &str) -> Result<PathBuf, TransferError> {
+    validate_upload_path(rel)?;
+    let joined = root.join(rel);
+
+    // Nothing below a missing root exists yet, so nothing can point away.
+    let Ok(canonical_root) = std::fs::canonicalize(root) else {
+        return Ok(joined);
+    };
+    let Some(existing) = joined.ancestors().find(|a| a.symlink_metadata().is_ok()) else {
+        return Ok(joined);
+    };
+    let escapes = match std::fs::canonicalize(existing) {
+        Ok(resolved) => !resolved.starts_with(&canonical_root),
+        // A dangling symlink: its target is unknown, so don't follow it.
+        Err(_) => true,
+    };
+    if escapes {
+        return Err(TransferError::InvalidPath(format!(
+            "path leaves the game directory: {rel}"
+        )));
+    }
+    Ok(joined)
+}
+
+/// Resolves the directory a game folder is created in: `base`, or the
+/// optional `subpath` below it.
+///
+/// The subpath must stay inside `base`; it is checked like an upload path.
+pub fn resolve_install_subpath(base: &Path, subpath: &str) -> Result<PathBuf, TransferError> {
+    if subpath.is_empty() {
+        return Ok(base.to_path_buf());
+    }
+    validate_upload_path(subpath)?;
+    Ok(base.join(subpath))
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -113,4 +156,82 @@ mod tests {
     fn rejects_parent_then_file() {
         assert!(validate_upload_path("../file.txt").is_err());
     }
+
+    #[test]
+    fn install_subpath_is_applied() {
+        let base = Path::new("/games");
+        assert_eq!(resolve_install_subpath(base, "").unwrap(), base);
+        assert_eq!(
+            resolve_install_subpath(base, "jam/2026").unwrap(),
+            Path::new("/games/jam/2026")
+        );
+    }
+
+    #[test]
+    fn escaping_install_subpath_is_rejected() {
+        let base = Path::new("/games");
+        for subpath in ["..", "../other", "jam/../../etc", "/etc"] {
+            assert!(
+                resolve_install_subpath(base, subpath).is_err(),
+                "{subpath} accepted"
+            );
+        }
+    }
+
+    #[test]
+    fn safe_join_stays_inside_root() {
+        let root = tempfile::tempdir().unwrap();
+        std::fs::create_dir(root.path().join("data")).unwrap();
+
+        assert_eq!(
+            safe_join(root.path(), "data/new/level.bin").unwrap(),
+            root.path().join("data/new/level.bin")
+        );
+        assert_eq!(
+            safe_join(root.path(), "./game.exe").unwrap(),
+            root.path().join("./game.exe")
+        );
+        for rel in ["../../.bashrc", "data/../../x", "/etc/passwd", ""] {
+            assert!(safe_join(root.path(), rel).is_err(), "{rel} accepted");
+        }
+    }
+
+    #[test]
+    fn safe_join_missing_root_is_lexical() {
+        let root = tempfile::tempdir().unwrap();
+        let missing = root.path().join("not-yet");
+        assert!(safe_join(&missing, "a/b.txt").is_ok());
+        assert!(safe_join(&missing, "../b.txt").is_err());
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn safe_join_refuses_symlinks_out_of_root() {
+        use std::os::unix::fs::symlink;
+
+        let root = tempfile::tempdir().unwrap();
+        let outside = tempfile::tempdir().unwrap();
+        symlink(outside.path(), root.path().join("linkdir")).unwrap();
+        symlink(outside.path().join(".bashrc"), root.path().join("dangling")).unwrap();
+        std::fs::write(outside.path().join("target.txt"), b"x").unwrap();
+        symlink(
+            outside.path().join("target.txt"),
+            root.path().join("linkfile"),
+        )
+        .unwrap();
+
+        for rel in [
+            "linkdir/.bashrc",
+            "linkdir/sub/file",
+            "dangling",
+            "linkfile",
+        ] {
+            assert!(safe_join(root.path(), rel).is_err(), "{rel} accepted");
+        }
+
+        // Links that stay inside the root are fine.
+        std::fs::create_dir(root.path().join("real")).unwrap();
+        symlink(root.path().join("real"), root.path().join("alias")).unwrap();
+        assert!(safe_join(root.path(), "alias/file").is_ok());
+    }
 }
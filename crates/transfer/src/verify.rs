@@ -0,0 +1,110 @@
+//! Post-install verification: checking the installed files against the
+//! Hub's manifest, to catch truncation the per-chunk acks don't.
+
+use std::path::Path;
+
+use capydeploy_protocol::constants::{
+    FILE_MISMATCH_CHECKSUM, FILE_MISMATCH_MISSING, FILE_MISMATCH_SIZE,
+};
+use capydeploy_protocol::messages::{ExpectedFile, FileMismatch};
+
+use crate::{calculate_file_checksum, safe_join};
+
+/// Files of `expected` that are missing under `root` or differ from the
+/// manifest. Checksums are only computed for entries that carry one, and
+/// only once the size matches.
+pub fn verify_installed(root: &Path, expected: &[ExpectedFile]) -> Vec<FileMismatch> {
+    expected
+        .iter()
+        .filter_map(|f| check_file(root, f))
+        .collect()
+}
+
+fn check_file(root: &Path, f: &ExpectedFile) -> Option<FileMismatch> {
+    let mismatch = |reason: &str, actual_size: i64| FileMismatch {
+        relative_path: f.relative_path.clone(),
+        reason: reason.into(),
+        actual_size,
+    };
+
+    // Paths that escape the folder were refused at `init_upload`.
+    let path = safe_join(root, &f.relative_path).ok()?;
+    let Some(meta) = std::fs::metadata(&path).ok().filter(|m| m.is_file()) else {
+        return Some(mismatch(FILE_MISMATCH_MISSING, 0));
+    };
+    let size = meta.len() as i64;
+    if size != f.size {
+        return Some(mismatch(FILE_MISMATCH_SIZE, size));
+    }
+    if !f.checksum.is_empty() {
+        let matches =
+            calculate_file_checksum(&path).is_ok_and(|sum| sum.eq_ignore_ascii_case(&f.checksum));
+        if !matches {
+            return Some(mismatch(FILE_MISMATCH_CHECKSUM, size));
+        }
+    }
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::checksum_bytes;
+
+    fn expected(path: &str, size: i64, checksum: &str) -> ExpectedFile {
+        ExpectedFile {
+            relative_path: path.into(),
+            size,
+            checksum: checksum.into(),
+        }
+    }
+
+    #[test]
+    fn intact_install_passes() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::create_dir(dir.path().join("data")).unwrap();
+        std::fs::write(dir.path().join("game.exe"), b"EXE").unwrap();
+        std::fs::write(dir.path().join("data/level.bin"), b"level").unwrap();
+
+        let manifest = [
+            expected("game.exe", 3, &checksum_bytes(b"EXE")),
+            expected("data/level.bin", 5, ""),
+        ];
+        assert!(verify_installed(dir.path(), &manifest).is_empty());
+    }
+
+    #[test]
+    fn reports_missing_truncated_and_corrupt_files() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("short.pak"), b"ab").unwrap();
+        std::fs::write(dir.path().join("flipped.pak"), b"abcd").unwrap();
+        std::fs::create_dir(dir.path().join("dir.pak")).unwrap();
+
+        let manifest = [
+            expected("gone.exe", 3, ""),
+            expected("short.pak", 4, ""),
+            expected("flipped.pak", 4, &checksum_bytes(b"abce")),
+            expected("dir.pak", 0, ""),
+        ];
+        let found = verify_installed(dir.path(), &manifest);
+        let reasons: Vec<(&str, &str, i64)> = found
+            .iter()
+            .map(|m| (m.relative_path.as_str(), m.reason.as_str(), m.actual_size))
+            .collect();
+        assert_eq!(
+            reasons,
+            [
+                ("gone.exe", FILE_MISMATCH_MISSING, 0),
+                ("short.pak", FILE_MISMATCH_SIZE, 2),
+                ("flipped.pak", FILE_MISMATCH_CHECKSUM, 4),
+                ("dir.pak", FILE_MISMATCH_MISSING, 0),
+            ]
+        );
+    }
+
+    #[test]
+    fn unsafe_paths_are_not_checked() {
+        let dir = tempfile::tempdir().unwrap();
+        assert!(verify_installed(dir.path(), &[expected("../outside", 1, "")]).is_empty());
+    }
+}